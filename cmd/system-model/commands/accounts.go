@@ -0,0 +1,174 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	acProvider "github.com/nalej/system-model/internal/pkg/provider/account"
+	nrProvider "github.com/nalej/system-model/internal/pkg/provider/name_reservation"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	pProvider "github.com/nalej/system-model/internal/pkg/provider/project"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/account"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var accountsConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var accountsAccountID string
+var accountsState string
+var accountsStates []string
+var accountsReason string
+var accountsFile string
+
+var accountsCmd = &cobra.Command{
+	Use:   "accounts",
+	Short: "Manage the lifecycle of the accounts",
+	Long:  `Change the state of the accounts, cascading it to their projects, and remove the accounts without projects`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var accountsStateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Change the state of an account",
+	Long:  `Change the state of an account to active, suspended, deactivated or pending_deletion, recording the reason. The active projects are suspended when the account stops being active`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		changeAccountState()
+	},
+}
+
+var accountsRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Remove an account",
+	Long:  `Remove an account without projects, unlinking its organizations`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		removeAccount()
+	},
+}
+
+var accountsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the accounts",
+	Long:  `List the accounts, optionally only those in the given states`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		listAccounts()
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{accountsStateCmd, accountsRemoveCmd, accountsListCmd} {
+		cmd.Flags().StringVar(&accountsConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+		cmd.Flags().IntVar(&accountsConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+		cmd.Flags().StringVar(&accountsConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+		accountsCmd.AddCommand(cmd)
+	}
+	accountsStateCmd.Flags().StringVar(&accountsAccountID, "account", "", "Account identifier")
+	accountsStateCmd.Flags().StringVar(&accountsState, "state", "", "New state: active, suspended, deactivated or pending_deletion")
+	accountsStateCmd.Flags().StringVar(&accountsReason, "reason", "", "Reason of the change, required for a non active state")
+	accountsRemoveCmd.Flags().StringVar(&accountsAccountID, "account", "", "Account identifier")
+	accountsListCmd.Flags().StringSliceVar(&accountsStates, "state", []string{}, "States of the listed accounts. All the accounts are listed if not set")
+	accountsListCmd.Flags().StringVarP(&accountsFile, "output", "o", "", "Output file. The accounts are printed if not set")
+	rootCmd.AddCommand(accountsCmd)
+}
+
+func validateAccountsConfig() {
+	accountsConfig.Port = 1
+	accountsConfig.UseDBScyllaProviders = true
+	vErr := accountsConfig.Validate()
+	if vErr != nil {
+		log.Fatal().Str("trace", vErr.DebugReport()).Msg("invalid configuration")
+	}
+}
+
+func requireAccountsAccount() {
+	if accountsAccountID == "" {
+		log.Fatal().Msg("account identifier must be set")
+	}
+}
+
+// runAccountManager creates an account manager connected to the database and executes an operation with it.
+func runAccountManager(config server.Config, operation func(manager account.Manager)) {
+	address, port, keyspace := config.ScyllaDBAddress, config.ScyllaDBPort, config.KeySpace
+	accounts := acProvider.NewScyllaAccountProvider(address, port, keyspace)
+	defer accounts.Disconnect()
+	names := nrProvider.NewScyllaNameReservationProvider(address, port, keyspace)
+	defer names.Disconnect()
+	organizations := orgProvider.NewScyllaOrganizationProvider(address, port, keyspace)
+	defer organizations.Disconnect()
+	projects := pProvider.NewScyllaProjectProvider(address, port, keyspace)
+	defer projects.Disconnect()
+
+	operation(account.NewManager(accounts, names, organizations, projects))
+}
+
+func changeAccountState() {
+	validateAccountsConfig()
+	requireAccountsAccount()
+	state, exists := entities.AccountStateFromString[accountsState]
+	if !exists {
+		log.Fatal().Str("state", accountsState).Msg("invalid account state")
+	}
+
+	runAccountManager(accountsConfig, func(manager account.Manager) {
+		err := manager.ChangeAccountState(accountsAccountID, state, accountsReason)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot change the state of the account")
+		}
+		log.Info().Str("accountId", accountsAccountID).Str("state", accountsState).Msg("account state changed")
+	})
+}
+
+func removeAccount() {
+	validateAccountsConfig()
+	requireAccountsAccount()
+
+	runAccountManager(accountsConfig, func(manager account.Manager) {
+		err := manager.RemoveAccount(accountsAccountID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot remove the account")
+		}
+		log.Info().Str("accountId", accountsAccountID).Msg("account removed")
+	})
+}
+
+func listAccounts() {
+	validateAccountsConfig()
+	states := make([]entities.AccountState, 0)
+	for _, name := range accountsStates {
+		state, exists := entities.AccountStateFromString[name]
+		if !exists {
+			log.Fatal().Str("state", name).Msg("invalid account state")
+		}
+		states = append(states, state)
+	}
+
+	runAccountManager(accountsConfig, func(manager account.Manager) {
+		accounts, err := manager.ListAccounts(states...)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot list the accounts")
+		}
+		writeJSON(accounts, accountsFile, "accounts")
+	})
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-application-go"
+	"github.com/nalej/grpc-infrastructure-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	devProvider "github.com/nalej/system-model/internal/pkg/provider/device"
+	ipamProvider "github.com/nalej/system-model/internal/pkg/provider/ipam"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	qProvider "github.com/nalej/system-model/internal/pkg/provider/quota"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/application"
+	"github.com/nalej/system-model/internal/pkg/server/ipam"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var applicationsConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var applicationsOrganizationID string
+var applicationsInstanceID string
+var applicationsFile string
+var applicationsClusterID string
+var applicationsFqdn string
+
+var instancesCmd = &cobra.Command{
+	Use:   "instances",
+	Short: "Inspect the application instances",
+	Long:  `Inspect the application instances of an organization`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var instanceDriftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Report the drift of an application instance",
+	Long:  `Compare an application instance with the parametrized descriptor it was created from and report the differences as JSON`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		reportInstanceDrift()
+	},
+}
+
+var endpointsCmd = &cobra.Command{
+	Use:   "endpoints",
+	Short: "Inspect the application endpoints",
+	Long:  `Inspect the public endpoints of the application instances, as seen by the DNS and ingress components`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var listEndpointsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the endpoints of an organization or of a cluster",
+	Long:  `List the endpoints of the instances of an organization, or of the service instances deployed on a cluster, as JSON`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		listEndpoints()
+	},
+}
+
+var resolveEndpointCmd = &cobra.Command{
+	Use:   "resolve",
+	Short: "Resolve a public FQDN",
+	Long:  `Retrieve the endpoints of a public FQDN under the public host domain as JSON`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		resolveEndpoint()
+	},
+}
+
+func init() {
+	endpointsCmd.PersistentFlags().StringVar(&applicationsConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+	endpointsCmd.PersistentFlags().IntVar(&applicationsConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+	endpointsCmd.PersistentFlags().StringVar(&applicationsConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+	endpointsCmd.PersistentFlags().StringVar(&applicationsConfig.PublicHostDomain, "publicHost", "nalej.cluster.local", "Public Hostname for the domain")
+	endpointsCmd.PersistentFlags().StringVarP(&applicationsFile, "output", "o", "", "Output file. The result is printed if not set")
+	listEndpointsCmd.Flags().StringVar(&applicationsOrganizationID, "org", "", "Organization identifier")
+	listEndpointsCmd.Flags().StringVar(&applicationsClusterID, "clusterId", "", "Cluster identifier. The endpoints of all the clusters are listed if not set")
+	resolveEndpointCmd.Flags().StringVar(&applicationsFqdn, "fqdn", "", "Public FQDN to resolve")
+	endpointsCmd.AddCommand(listEndpointsCmd, resolveEndpointCmd)
+	rootCmd.AddCommand(endpointsCmd)
+
+	instancesCmd.PersistentFlags().StringVar(&applicationsConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+	instancesCmd.PersistentFlags().IntVar(&applicationsConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+	instancesCmd.PersistentFlags().StringVar(&applicationsConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+	instancesCmd.PersistentFlags().StringVar(&applicationsOrganizationID, "org", "", "Organization identifier")
+	instancesCmd.PersistentFlags().StringVarP(&applicationsFile, "output", "o", "", "Output file. The result is printed if not set")
+	instanceDriftCmd.Flags().StringVar(&applicationsInstanceID, "instanceId", "", "Application instance identifier")
+	instancesCmd.AddCommand(instanceDriftCmd)
+	rootCmd.AddCommand(instancesCmd)
+}
+
+// runApplicationManager creates an application manager connected to the database and executes an operation with it.
+func runApplicationManager(config server.Config, operation func(organizations orgProvider.Provider, applications appProvider.Provider, manager application.Manager)) {
+	config.Port = 1
+	config.UseDBScyllaProviders = true
+	vErr := config.Validate()
+	if vErr != nil {
+		log.Fatal().Str("trace", vErr.DebugReport()).Msg("invalid configuration")
+	}
+
+	address, port, keyspace := config.ScyllaDBAddress, config.ScyllaDBPort, config.KeySpace
+	organizations := orgProvider.NewScyllaOrganizationProvider(address, port, keyspace)
+	defer organizations.Disconnect()
+	applications := appProvider.NewScyllaApplicationProvider(address, port, keyspace)
+	defer applications.Disconnect()
+	devices := devProvider.NewScyllaDeviceProvider(address, port, keyspace)
+	defer devices.Disconnect()
+	ipRanges := ipamProvider.NewScyllaIPAMProvider(address, port, keyspace)
+	defer ipRanges.Disconnect()
+	settings := organization_setting.NewScyllaOrganizationSettingProvider(address, port, keyspace)
+	defer settings.Disconnect()
+	quotas := qProvider.NewScyllaQuotaProvider(address, port, keyspace)
+	defer quotas.Disconnect()
+	pool, err := cidr.ParsePool(config.IPAMPool, config.IPAMBlockPrefixLength)
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("invalid ip pool")
+	}
+
+	operation(organizations, applications, application.NewManager(organizations, applications, devices, config.PublicHostDomain,
+		ipam.NewManager(organizations, ipRanges, pool), quota.NewManager(organizations, settings, quotas)))
+}
+
+func reportInstanceDrift() {
+	if applicationsOrganizationID == "" || applicationsInstanceID == "" {
+		log.Fatal().Msg("org and instanceId must be set")
+	}
+	runApplicationManager(applicationsConfig, func(organizations orgProvider.Provider, applications appProvider.Provider, manager application.Manager) {
+		drift, err := manager.GetAppInstanceDrift(&grpc_application_go.AppInstanceId{
+			OrganizationId: applicationsOrganizationID,
+			AppInstanceId:  applicationsInstanceID,
+		})
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot compute the drift of the instance")
+		}
+		writeJSON(drift, applicationsFile, "instance drift")
+	})
+}
+
+func listEndpoints() {
+	if applicationsOrganizationID == "" {
+		log.Fatal().Msg("org must be set")
+	}
+	runApplicationManager(applicationsConfig, func(organizations orgProvider.Provider, applications appProvider.Provider, manager application.Manager) {
+		var endpoints []entities.AppEndpoint
+		var err derrors.Error
+		if applicationsClusterID == "" {
+			endpoints, err = manager.ListOrganizationEndpoints(&grpc_organization_go.OrganizationId{OrganizationId: applicationsOrganizationID})
+		} else {
+			endpoints, err = manager.ListClusterEndpoints(&grpc_infrastructure_go.ClusterId{
+				OrganizationId: applicationsOrganizationID,
+				ClusterId:      applicationsClusterID,
+			})
+		}
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot list the endpoints")
+		}
+		writeJSON(endpoints, applicationsFile, "endpoints")
+	})
+}
+
+func resolveEndpoint() {
+	if applicationsFqdn == "" {
+		log.Fatal().Msg("fqdn must be set")
+	}
+	runApplicationManager(applicationsConfig, func(organizations orgProvider.Provider, applications appProvider.Provider, manager application.Manager) {
+		endpoints, err := manager.ResolvePublicFqdn(applicationsFqdn)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot resolve the fqdn")
+		}
+		writeJSON(endpoints, applicationsFile, "endpoints")
+	})
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	catProvider "github.com/nalej/system-model/internal/pkg/provider/catalog"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/application"
+	"github.com/nalej/system-model/internal/pkg/server/catalog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var catalogConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var catalogOrganizationID string
+var catalogEntryID string
+var catalogVersion string
+var catalogDescriptorID string
+var catalogDescription string
+var catalogLogo string
+var catalogCategory string
+var catalogVisibility string
+var catalogVisibleOrganizations []string
+var catalogQuery string
+var catalogName string
+var catalogFile string
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Manage the application catalog",
+	Long:  `Publish application descriptors as versioned catalog entries shared across organizations, and instantiate them in the descriptors of an organization`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var catalogPublishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Publish a descriptor in the catalog",
+	Long:  `Publish the current contents of a descriptor as a new version of a catalog entry. A new entry is created if no entry is given`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		publishCatalogEntry()
+	},
+}
+
+var catalogListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the catalog entries visible to an organization",
+	Long:  `List the latest version of the catalog entries visible to an organization, filtered by a text and a category`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		listCatalogEntries()
+	},
+}
+
+var catalogInstantiateCmd = &cobra.Command{
+	Use:   "instantiate",
+	Short: "Copy a catalog entry into the descriptors of an organization",
+	Long:  `Copy a catalog entry into the descriptors of an organization. The descriptor quota of the organization is enforced`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		instantiateCatalogEntry()
+	},
+}
+
+var catalogUnpublishCmd = &cobra.Command{
+	Use:   "unpublish",
+	Short: "Remove a version of a catalog entry",
+	Long:  `Remove a version of a catalog entry. Only the publisher organization can unpublish an entry, and the descriptors already instantiated are not affected`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		unpublishCatalogEntry()
+	},
+}
+
+func init() {
+	catalogCmd.PersistentFlags().StringVar(&catalogConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+	catalogCmd.PersistentFlags().IntVar(&catalogConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+	catalogCmd.PersistentFlags().StringVar(&catalogConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+	catalogCmd.PersistentFlags().StringVar(&catalogOrganizationID, "org", "", "Organization identifier")
+	for _, cmd := range []*cobra.Command{catalogPublishCmd, catalogInstantiateCmd, catalogUnpublishCmd} {
+		cmd.Flags().StringVar(&catalogEntryID, "entryId", "", "Catalog entry identifier")
+		cmd.Flags().StringVar(&catalogVersion, "version", "", "Version of the catalog entry")
+	}
+	catalogPublishCmd.Flags().StringVar(&catalogDescriptorID, "descriptorId", "", "Application descriptor identifier")
+	catalogPublishCmd.Flags().StringVar(&catalogDescription, "description", "", "Description of the application")
+	catalogPublishCmd.Flags().StringVar(&catalogLogo, "logo", "", "URL of the logo of the application")
+	catalogPublishCmd.Flags().StringVar(&catalogCategory, "category", "", "Category of the application")
+	catalogPublishCmd.Flags().StringVar(&catalogVisibility, "visibility", "ALL", "Visibility of the entry (ALL or SELECTED)")
+	catalogPublishCmd.Flags().StringSliceVar(&catalogVisibleOrganizations, "visibleOrgs", nil, "Organizations that can see the entry when the visibility is SELECTED")
+	catalogListCmd.Flags().StringVar(&catalogQuery, "query", "", "Text to be found in the name, description or labels of the entries")
+	catalogListCmd.Flags().StringVar(&catalogCategory, "category", "", "Category of the entries")
+	catalogListCmd.Flags().StringVarP(&catalogFile, "output", "o", "", "Output file. The entries are printed if not set")
+	catalogInstantiateCmd.Flags().StringVar(&catalogName, "name", "", "Name of the new descriptor. The name of the entry is used if not set")
+	catalogCmd.AddCommand(catalogPublishCmd, catalogListCmd, catalogInstantiateCmd, catalogUnpublishCmd)
+	rootCmd.AddCommand(catalogCmd)
+}
+
+// runCatalogManager creates a catalog manager connected to the database and executes an operation with it.
+func runCatalogManager(config server.Config, operation func(manager catalog.Manager)) {
+	runApplicationManager(config, func(organizations orgProvider.Provider, applications appProvider.Provider, appManager application.Manager) {
+		entries := catProvider.NewScyllaCatalogProvider(config.ScyllaDBAddress, config.ScyllaDBPort, config.KeySpace)
+		defer entries.Disconnect()
+		operation(catalog.NewManager(organizations, applications, entries, appManager))
+	})
+}
+
+func publishCatalogEntry() {
+	visibility, exists := entities.CatalogVisibilityFromString[strings.ToUpper(catalogVisibility)]
+	if !exists {
+		log.Fatal().Str("visibility", catalogVisibility).Msg("invalid visibility")
+	}
+	runCatalogManager(catalogConfig, func(manager catalog.Manager) {
+		entry, err := manager.PublishDescriptor(entities.PublishCatalogEntryRequest{
+			OrganizationId:       catalogOrganizationID,
+			AppDescriptorId:      catalogDescriptorID,
+			CatalogEntryId:       catalogEntryID,
+			Version:              catalogVersion,
+			Description:          catalogDescription,
+			Logo:                 catalogLogo,
+			Category:             catalogCategory,
+			Visibility:           visibility,
+			VisibleOrganizations: catalogVisibleOrganizations,
+		})
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot publish the descriptor")
+		}
+		log.Info().Str("catalogEntryId", entry.CatalogEntryId).Str("version", entry.Version).Msg("descriptor published")
+	})
+}
+
+func listCatalogEntries() {
+	runCatalogManager(catalogConfig, func(manager catalog.Manager) {
+		entries, err := manager.ListEntries(entities.CatalogSearchRequest{OrganizationId: catalogOrganizationID,
+			Query: catalogQuery, Category: catalogCategory})
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot list the catalog entries")
+		}
+		writeJSON(entries, catalogFile, "catalog entries")
+	})
+}
+
+func instantiateCatalogEntry() {
+	runCatalogManager(catalogConfig, func(manager catalog.Manager) {
+		descriptor, err := manager.InstantiateEntry(entities.InstantiateCatalogEntryRequest{OrganizationId: catalogOrganizationID,
+			CatalogEntryId: catalogEntryID, Version: catalogVersion, Name: catalogName})
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot instantiate the catalog entry")
+		}
+		log.Info().Str("appDescriptorId", descriptor.AppDescriptorId).Str("name", descriptor.Name).Msg("catalog entry instantiated")
+	})
+}
+
+func unpublishCatalogEntry() {
+	runCatalogManager(catalogConfig, func(manager catalog.Manager) {
+		err := manager.UnpublishEntry(catalogOrganizationID, catalogEntryID, catalogVersion)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot unpublish the catalog entry")
+		}
+		log.Info().Str("catalogEntryId", catalogEntryID).Str("version", catalogVersion).Msg("catalog entry unpublished")
+	})
+}
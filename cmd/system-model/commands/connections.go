@@ -0,0 +1,184 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-application-network-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	appNetProvider "github.com/nalej/system-model/internal/pkg/provider/application_network"
+	ipamProvider "github.com/nalej/system-model/internal/pkg/provider/ipam"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	qProvider "github.com/nalej/system-model/internal/pkg/provider/quota"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/application_network"
+	"github.com/nalej/system-model/internal/pkg/server/ipam"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var connectionsConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var connectionsOrganizationID string
+var connectionsSourceInstanceID string
+var connectionsTargetInstanceID string
+var connectionsInboundName string
+var connectionsOutboundName string
+var connectionsSourceClusterID string
+var connectionsTargetClusterID string
+var connectionsStatus string
+var connectionsFile string
+
+var connectionsCmd = &cobra.Command{
+	Use:   "connections",
+	Short: "Manage the connections between application instances",
+	Long:  `Report the health of the connections between application instances and set the status of their links`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var connectionsHealthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Report the health of the connections",
+	Long:  `Report the health of a connection, or of all the connections of an organization if no connection is given, with the status of each link`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		reportConnectionHealth()
+	},
+}
+
+var connectionsLinkCmd = &cobra.Command{
+	Use:   "link",
+	Short: "Set the status of the link of a connection",
+	Long:  `Set the status of the link of a connection between a source and a target cluster, adding the link if it does not exist. The status of the connection is derived again from its links`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		setConnectionLink()
+	},
+}
+
+func init() {
+	connectionsCmd.PersistentFlags().StringVar(&connectionsConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+	connectionsCmd.PersistentFlags().IntVar(&connectionsConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+	connectionsCmd.PersistentFlags().StringVar(&connectionsConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+	connectionsCmd.PersistentFlags().StringVar(&connectionsConfig.ConnectionStatusPolicy, "connectionStatusPolicy",
+		string(entities.DefaultConnectionStatusPolicy), "Policy used to derive the status of a connection from its links (all, majority or any)")
+	connectionsCmd.PersistentFlags().StringVar(&connectionsOrganizationID, "org", "", "Organization identifier")
+	connectionsCmd.PersistentFlags().StringVar(&connectionsSourceInstanceID, "source", "", "Application instance identifier of the source of the connection")
+	connectionsCmd.PersistentFlags().StringVar(&connectionsTargetInstanceID, "target", "", "Application instance identifier of the target of the connection")
+	connectionsCmd.PersistentFlags().StringVar(&connectionsInboundName, "inbound", "", "Name of the inbound network interface of the connection")
+	connectionsCmd.PersistentFlags().StringVar(&connectionsOutboundName, "outbound", "", "Name of the outbound network interface of the connection")
+	connectionsHealthCmd.Flags().StringVarP(&connectionsFile, "output", "o", "", "Output file. The health is printed if not set")
+	connectionsLinkCmd.Flags().StringVar(&connectionsSourceClusterID, "sourceCluster", "", "Cluster identifier where the source is deployed")
+	connectionsLinkCmd.Flags().StringVar(&connectionsTargetClusterID, "targetCluster", "", "Cluster identifier where the target is deployed")
+	connectionsLinkCmd.Flags().StringVar(&connectionsStatus, "status", "", "Status of the link (WAITING, ESTABLISHED, TERMINATED or FAILED)")
+	connectionsCmd.AddCommand(connectionsHealthCmd, connectionsLinkCmd)
+	rootCmd.AddCommand(connectionsCmd)
+}
+
+// runConnectionManager creates an application network manager connected to the database and executes an operation
+// with it.
+func runConnectionManager(config server.Config, operation func(manager application_network.Manager)) {
+	config.Port = 1
+	config.UseDBScyllaProviders = true
+	vErr := config.Validate()
+	if vErr != nil {
+		log.Fatal().Str("trace", vErr.DebugReport()).Msg("invalid configuration")
+	}
+
+	address, port, keyspace := config.ScyllaDBAddress, config.ScyllaDBPort, config.KeySpace
+	organizations := orgProvider.NewScyllaOrganizationProvider(address, port, keyspace)
+	defer organizations.Disconnect()
+	applications := appProvider.NewScyllaApplicationProvider(address, port, keyspace)
+	defer applications.Disconnect()
+	connections := appNetProvider.NewScyllaApplicationNetworkProvider(address, port, keyspace)
+	defer connections.Disconnect()
+	ipRanges := ipamProvider.NewScyllaIPAMProvider(address, port, keyspace)
+	defer ipRanges.Disconnect()
+	settings := organization_setting.NewScyllaOrganizationSettingProvider(address, port, keyspace)
+	defer settings.Disconnect()
+	quotas := qProvider.NewScyllaQuotaProvider(address, port, keyspace)
+	defer quotas.Disconnect()
+	pool, err := cidr.ParsePool(config.IPAMPool, config.IPAMBlockPrefixLength)
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("invalid ip pool")
+	}
+
+	operation(application_network.NewManager(organizations, applications, connections,
+		ipam.NewManager(organizations, ipRanges, pool), entities.ConnectionStatusPolicy(config.ConnectionStatusPolicy),
+		quota.NewManager(organizations, settings, quotas)))
+}
+
+func reportConnectionHealth() {
+	runConnectionManager(connectionsConfig, func(manager application_network.Manager) {
+		if connectionsSourceInstanceID == "" && connectionsTargetInstanceID == "" {
+			health, err := manager.ListConnectionHealth(&grpc_organization_go.OrganizationId{OrganizationId: connectionsOrganizationID})
+			if err != nil {
+				log.Fatal().Str("trace", err.DebugReport()).Msg("cannot retrieve the health of the connections")
+			}
+			writeJSON(health, connectionsFile, "connection health")
+			return
+		}
+		health, err := manager.GetConnectionHealth(&grpc_application_network_go.ConnectionInstanceId{
+			OrganizationId:   connectionsOrganizationID,
+			SourceInstanceId: connectionsSourceInstanceID,
+			TargetInstanceId: connectionsTargetInstanceID,
+			InboundName:      connectionsInboundName,
+			OutboundName:     connectionsOutboundName,
+		})
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot retrieve the health of the connection")
+		}
+		writeJSON(health, connectionsFile, "connection health")
+	})
+}
+
+func setConnectionLink() {
+	value, exists := grpc_application_network_go.ConnectionStatus_value[strings.ToUpper(connectionsStatus)]
+	if !exists {
+		log.Fatal().Str("status", connectionsStatus).Msg("invalid link status")
+	}
+	link := entities.ConnectionInstanceLink{
+		OrganizationId:   connectionsOrganizationID,
+		SourceInstanceId: connectionsSourceInstanceID,
+		SourceClusterId:  connectionsSourceClusterID,
+		TargetInstanceId: connectionsTargetInstanceID,
+		TargetClusterId:  connectionsTargetClusterID,
+		InboundName:      connectionsInboundName,
+		OutboundName:     connectionsOutboundName,
+		Status:           entities.ConnectionStatusFromGRPC[grpc_application_network_go.ConnectionStatus(value)],
+	}
+	runConnectionManager(connectionsConfig, func(manager application_network.Manager) {
+		err := manager.UpdateConnectionInstanceLink(link)
+		if err != nil && err.Type() == derrors.NotFound {
+			err = manager.AddConnectionInstanceLink(link)
+		}
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot set the status of the link")
+		}
+		log.Info().Str("sourceClusterId", link.SourceClusterId).Str("targetClusterId", link.TargetClusterId).
+			Str("status", strings.ToUpper(connectionsStatus)).Msg("link status set")
+	})
+}
@@ -0,0 +1,283 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/nalej/grpc-application-go"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/descriptor"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/application"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"os"
+	"time"
+)
+
+// descriptorTimeout is the maximum time to wait for the system model to answer.
+const descriptorTimeout = time.Second * 30
+
+var systemModelAddress string
+var organizationID string
+var descriptorFile string
+var descriptorFormat string
+var appDescriptorID string
+var credentialsFile string
+var revisionsConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var fromRevision int64
+var toRevision int64
+
+var descriptorCmd = &cobra.Command{
+	Use:   "descriptor",
+	Short: "Manage application descriptors",
+	Long:  `Import and export application descriptors using human-editable YAML or JSON files`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var importDescriptorCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import an application descriptor from a file",
+	Long:  `Import an application descriptor from a YAML or JSON file. The descriptor is validated before being sent to the system model`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		importDescriptor()
+	},
+}
+
+var exportDescriptorCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export an application descriptor to a file",
+	Long:  `Export an existing application descriptor to a YAML or JSON file`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		exportDescriptor()
+	},
+}
+
+var reviseDescriptorCmd = &cobra.Command{
+	Use:   "revise",
+	Short: "Create a new revision of an application descriptor from a file",
+	Long:  `Replace the contents of an application descriptor with a YAML or JSON file, creating a new revision if the contents change. The descriptor keeps its identifier and labels`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		reviseDescriptor()
+	},
+}
+
+var listRevisionsCmd = &cobra.Command{
+	Use:   "revisions",
+	Short: "List the revisions of an application descriptor",
+	Long:  `List the revisions of an application descriptor ordered by revision number`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		listDescriptorRevisions()
+	},
+}
+
+var diffRevisionsCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show the differences between two revisions of an application descriptor",
+	Long:  `Show the structured differences between two revisions of an application descriptor`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		diffDescriptorRevisions()
+	},
+}
+
+func init() {
+	descriptorCmd.PersistentFlags().StringVar(&systemModelAddress, "systemModelAddress", "localhost:8800", "System Model address (host:port)")
+	descriptorCmd.PersistentFlags().StringVar(&organizationID, "org", "", "Organization identifier")
+	descriptorCmd.PersistentFlags().StringVar(&descriptorFormat, "format", "", "File format (yaml or json). Inferred from the file extension if not set")
+	importDescriptorCmd.Flags().StringVarP(&descriptorFile, "file", "f", "", "Descriptor file")
+	importDescriptorCmd.Flags().StringVar(&credentialsFile, "credentials", "", "File with the credentials of the image repositories per service")
+	exportDescriptorCmd.Flags().StringVarP(&descriptorFile, "output", "o", "", "Output file. The descriptor is printed if not set")
+	exportDescriptorCmd.Flags().StringVar(&appDescriptorID, "descriptorId", "", "Application descriptor identifier")
+	for _, cmd := range []*cobra.Command{reviseDescriptorCmd, listRevisionsCmd, diffRevisionsCmd} {
+		cmd.Flags().StringVar(&revisionsConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+		cmd.Flags().IntVar(&revisionsConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+		cmd.Flags().StringVar(&revisionsConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+		cmd.Flags().StringVar(&appDescriptorID, "descriptorId", "", "Application descriptor identifier")
+	}
+	reviseDescriptorCmd.Flags().StringVarP(&descriptorFile, "file", "f", "", "Descriptor file")
+	reviseDescriptorCmd.Flags().StringVar(&credentialsFile, "credentials", "", "File with the credentials of the image repositories per service")
+	for _, cmd := range []*cobra.Command{listRevisionsCmd, diffRevisionsCmd} {
+		cmd.Flags().StringVarP(&descriptorFile, "output", "o", "", "Output file. The result is printed if not set")
+	}
+	diffRevisionsCmd.Flags().Int64Var(&fromRevision, "from", entities.InitialDescriptorRevision, "Revision to compare from")
+	diffRevisionsCmd.Flags().Int64Var(&toRevision, "to", 0, "Revision to compare to")
+	descriptorCmd.AddCommand(importDescriptorCmd)
+	descriptorCmd.AddCommand(exportDescriptorCmd)
+	descriptorCmd.AddCommand(reviseDescriptorCmd, listRevisionsCmd, diffRevisionsCmd)
+	rootCmd.AddCommand(descriptorCmd)
+}
+
+// getFormat returns the format selected by the user, if any.
+func getFormat() descriptor.Format {
+	if descriptorFormat == "" {
+		return ""
+	}
+	format, err := descriptor.ParseFormat(descriptorFormat)
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("invalid format")
+	}
+	return format
+}
+
+// getApplicationsClient creates a client to the applications service of the system model.
+func getApplicationsClient() (grpc_application_go.ApplicationsClient, *grpc.ClientConn) {
+	conn, err := grpc.Dial(systemModelAddress, grpc.WithInsecure())
+	if err != nil {
+		log.Fatal().Err(err).Str("address", systemModelAddress).Msg("cannot connect to the system model")
+	}
+	return grpc_application_go.NewApplicationsClient(conn), conn
+}
+
+// readAddRequest reads a descriptor file with the credentials of its image repositories, and builds a valid request
+// to add it.
+func readAddRequest() *grpc_application_go.AddAppDescriptorRequest {
+	if organizationID == "" || descriptorFile == "" {
+		log.Fatal().Msg("org and file must be set")
+	}
+	file, err := descriptor.ReadFile(descriptorFile, getFormat())
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("cannot read descriptor")
+	}
+	if credentialsFile != "" {
+		credentials, err := descriptor.ReadCredentialsFile(credentialsFile, "")
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot read credentials")
+		}
+		err = file.SetCredentials(credentials)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("invalid credentials")
+		}
+	}
+	addRequest, err := file.ToAddRequest(organizationID, entities.GenerateUUID())
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("invalid descriptor")
+	}
+	err = descriptor.ValidateAddRequest(addRequest)
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("invalid descriptor")
+	}
+	return addRequest
+}
+
+func importDescriptor() {
+	addRequest := readAddRequest()
+
+	client, conn := getApplicationsClient()
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), descriptorTimeout)
+	defer cancel()
+	added, aErr := client.AddAppDescriptor(ctx, addRequest)
+	if aErr != nil {
+		log.Fatal().Err(aErr).Msg("cannot add descriptor")
+	}
+	log.Info().Str("organizationID", added.OrganizationId).Str("appDescriptorID", added.AppDescriptorId).Msg("descriptor imported")
+}
+
+func exportDescriptor() {
+	if organizationID == "" || appDescriptorID == "" {
+		log.Fatal().Msg("org and descriptorId must be set")
+	}
+	client, conn := getApplicationsClient()
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), descriptorTimeout)
+	defer cancel()
+	retrieved, gErr := client.GetAppDescriptor(ctx, &grpc_application_go.AppDescriptorId{
+		OrganizationId:  organizationID,
+		AppDescriptorId: appDescriptorID,
+	})
+	if gErr != nil {
+		log.Fatal().Err(gErr).Msg("cannot retrieve descriptor")
+	}
+
+	file := descriptor.NewFileFromGRPC(retrieved)
+	format := getFormat()
+	if descriptorFile == "" {
+		if format == "" {
+			format = descriptor.YAMLFormat
+		}
+		content, err := descriptor.Marshal(file, format)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot serialize descriptor")
+		}
+		fmt.Fprintln(os.Stdout, string(content))
+		return
+	}
+	err := descriptor.WriteFile(descriptorFile, file, format)
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("cannot write descriptor")
+	}
+	log.Info().Str("file", descriptorFile).Msg("descriptor exported")
+}
+
+// getDescriptorID returns the identifier of the descriptor selected by the user.
+func getDescriptorID() *grpc_application_go.AppDescriptorId {
+	if organizationID == "" || appDescriptorID == "" {
+		log.Fatal().Msg("org and descriptorId must be set")
+	}
+	return &grpc_application_go.AppDescriptorId{OrganizationId: organizationID, AppDescriptorId: appDescriptorID}
+}
+
+func reviseDescriptor() {
+	descriptorID := getDescriptorID()
+	addRequest := readAddRequest()
+	runApplicationManager(revisionsConfig, func(organizations orgProvider.Provider, applications appProvider.Provider, manager application.Manager) {
+		revised, err := manager.ReviseAppDescriptor(descriptorID.AppDescriptorId, addRequest)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot revise descriptor")
+		}
+		log.Info().Str("appDescriptorID", revised.AppDescriptorId).Int64("revision", revised.Revision).Msg("descriptor revised")
+	})
+}
+
+func listDescriptorRevisions() {
+	descriptorID := getDescriptorID()
+	runApplicationManager(revisionsConfig, func(organizations orgProvider.Provider, applications appProvider.Provider, manager application.Manager) {
+		revisions, err := manager.ListAppDescriptorRevisions(descriptorID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot list descriptor revisions")
+		}
+		writeJSON(revisions, descriptorFile, "descriptor revisions")
+	})
+}
+
+func diffDescriptorRevisions() {
+	descriptorID := getDescriptorID()
+	if toRevision == 0 {
+		log.Fatal().Msg("to must be set")
+	}
+	runApplicationManager(revisionsConfig, func(organizations orgProvider.Provider, applications appProvider.Provider, manager application.Manager) {
+		diff, err := manager.GetAppDescriptorDiff(descriptorID, fromRevision, toRevision)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot compare descriptor revisions")
+		}
+		writeJSON(diff, descriptorFile, "descriptor diff")
+	})
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	clusterProvider "github.com/nalej/system-model/internal/pkg/provider/cluster"
+	devProvider "github.com/nalej/system-model/internal/pkg/provider/device"
+	ipamProvider "github.com/nalej/system-model/internal/pkg/provider/ipam"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	qProvider "github.com/nalej/system-model/internal/pkg/provider/quota"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/application"
+	"github.com/nalej/system-model/internal/pkg/server/ipam"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
+	"github.com/nalej/system-model/internal/pkg/server/zt_garbage_collector"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+var gcConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var gcOrganizationID string
+var gcDryRun bool
+var gcLegacy bool
+var gcFile string
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove stale ZeroTier members and proxies",
+	Long:  `Remove the ZeroTier members and proxies whose instances, service group instances or clusters no longer exist, once the grace period has passed, and report what was removed`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		collectZtGarbage()
+	},
+}
+
+func init() {
+	gcCmd.Flags().StringVar(&gcConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+	gcCmd.Flags().IntVar(&gcConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+	gcCmd.Flags().StringVar(&gcConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+	gcCmd.Flags().StringVar(&gcOrganizationID, "org", "", "Organization identifier. All the organizations are collected if not set")
+	gcCmd.Flags().DurationVar(&gcConfig.ZtGCGracePeriod, "gracePeriod", 15*time.Minute, "Time an orphan zt member or proxy is kept before being removed")
+	gcCmd.Flags().BoolVar(&gcDryRun, "dryRun", false, "Report the orphans without removing them")
+	gcCmd.Flags().BoolVar(&gcLegacy, "legacy", false, "Remove the orphans without creation time, added before it was recorded")
+	gcCmd.Flags().StringVarP(&gcFile, "output", "o", "", "Output file. The report is printed if not set")
+	rootCmd.AddCommand(gcCmd)
+}
+
+func collectZtGarbage() {
+	gcConfig.Port = 1
+	gcConfig.UseDBScyllaProviders = true
+	vErr := gcConfig.Validate()
+	if vErr != nil {
+		log.Fatal().Str("trace", vErr.DebugReport()).Msg("invalid configuration")
+	}
+
+	organizations := orgProvider.NewScyllaOrganizationProvider(gcConfig.ScyllaDBAddress, gcConfig.ScyllaDBPort, gcConfig.KeySpace)
+	defer organizations.Disconnect()
+	applications := appProvider.NewScyllaApplicationProvider(gcConfig.ScyllaDBAddress, gcConfig.ScyllaDBPort, gcConfig.KeySpace)
+	defer applications.Disconnect()
+	clusters := clusterProvider.NewScyllaClusterProvider(gcConfig.ScyllaDBAddress, gcConfig.ScyllaDBPort, gcConfig.KeySpace)
+	defer clusters.Disconnect()
+	devices := devProvider.NewScyllaDeviceProvider(gcConfig.ScyllaDBAddress, gcConfig.ScyllaDBPort, gcConfig.KeySpace)
+	defer devices.Disconnect()
+	ipRanges := ipamProvider.NewScyllaIPAMProvider(gcConfig.ScyllaDBAddress, gcConfig.ScyllaDBPort, gcConfig.KeySpace)
+	defer ipRanges.Disconnect()
+	settings := organization_setting.NewScyllaOrganizationSettingProvider(gcConfig.ScyllaDBAddress, gcConfig.ScyllaDBPort, gcConfig.KeySpace)
+	defer settings.Disconnect()
+	quotas := qProvider.NewScyllaQuotaProvider(gcConfig.ScyllaDBAddress, gcConfig.ScyllaDBPort, gcConfig.KeySpace)
+	defer quotas.Disconnect()
+	pool, err := cidr.ParsePool(gcConfig.IPAMPool, gcConfig.IPAMBlockPrefixLength)
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("invalid ip pool")
+	}
+	appManager := application.NewManager(organizations, applications, devices, gcConfig.PublicHostDomain,
+		ipam.NewManager(organizations, ipRanges, pool), quota.NewManager(organizations, settings, quotas))
+	manager := zt_garbage_collector.NewManager(organizations, applications, clusters, appManager, gcConfig.ZtGCGracePeriod)
+	manager.CollectLegacy = gcLegacy
+
+	var reports []entities.ZtGarbageReport
+	if gcOrganizationID == "" {
+		reports, err = manager.CollectAll(gcDryRun)
+	} else {
+		var report *entities.ZtGarbageReport
+		report, err = manager.Collect(&grpc_organization_go.OrganizationId{OrganizationId: gcOrganizationID}, gcDryRun)
+		if report != nil {
+			reports = []entities.ZtGarbageReport{*report}
+		}
+	}
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("cannot collect the zt garbage")
+	}
+
+	content, mErr := json.MarshalIndent(reports, "", "  ")
+	if mErr != nil {
+		log.Fatal().Err(mErr).Msg("cannot marshal the garbage collection report")
+	}
+	if gcFile == "" {
+		fmt.Fprintln(os.Stdout, string(content))
+		return
+	}
+	wErr := ioutil.WriteFile(gcFile, content, 0644)
+	if wErr != nil {
+		log.Fatal().Err(wErr).Str("file", gcFile).Msg("cannot write the garbage collection report")
+	}
+	log.Info().Str("file", gcFile).Int("organizations", len(reports)).Msg("garbage collection report written")
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	iProvider "github.com/nalej/system-model/internal/pkg/provider/invitation"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/invitation"
+	"github.com/nalej/system-model/internal/pkg/server/user"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"time"
+)
+
+var invitationsConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var invitationsOrganizationID string
+var invitationsInvitationID string
+var invitationsEmail string
+var invitationsRoleID string
+var invitationsInvitedBy string
+var invitationsExpiration time.Duration
+var invitationsToken string
+var invitationsName string
+var invitationsLastName string
+var invitationsTitle string
+var invitationsFile string
+
+var invitationsCmd = &cobra.Command{
+	Use:   "invitations",
+	Short: "Manage the invitations to the organizations",
+	Long:  `Invite emails to become members of an organization with a role, and accept or revoke the invitations`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var invitationsInviteCmd = &cobra.Command{
+	Use:   "invite",
+	Short: "Invite an email to an organization",
+	Long:  `Invite an email to become a member of an organization with a role. The token of the invitation is only printed once`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		inviteEmail()
+	},
+}
+
+var invitationsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the invitations of an organization",
+	Long:  `List the invitations of an organization`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		listInvitations()
+	},
+}
+
+var invitationsRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke an invitation",
+	Long:  `Revoke an invitation that has not been accepted`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		revokeInvitation()
+	},
+}
+
+var invitationsAcceptCmd = &cobra.Command{
+	Use:   "accept",
+	Short: "Accept an invitation",
+	Long:  `Accept an invitation with its token, adding the user to the organization with the role of the invitation`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		acceptInvitation()
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{invitationsInviteCmd, invitationsListCmd, invitationsRevokeCmd, invitationsAcceptCmd} {
+		cmd.Flags().StringVar(&invitationsConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+		cmd.Flags().IntVar(&invitationsConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+		cmd.Flags().StringVar(&invitationsConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+		cmd.Flags().StringVar(&invitationsConfig.BlobDirectory, "blobDirectory", "", "Directory where the photos are stored. They are stored in the database if not set")
+		invitationsCmd.AddCommand(cmd)
+	}
+	for _, cmd := range []*cobra.Command{invitationsInviteCmd, invitationsListCmd, invitationsRevokeCmd} {
+		cmd.Flags().StringVar(&invitationsOrganizationID, "organization", "", "Organization identifier")
+	}
+	invitationsInviteCmd.Flags().StringVar(&invitationsEmail, "email", "", "Email of the invited user")
+	invitationsInviteCmd.Flags().StringVar(&invitationsRoleID, "role", "", "Role assigned to the user when the invitation is accepted")
+	invitationsInviteCmd.Flags().StringVar(&invitationsInvitedBy, "invitedBy", "", "Email of the user that creates the invitation")
+	invitationsInviteCmd.Flags().DurationVar(&invitationsExpiration, "expiration", 0, "Time the invitation can be accepted. The invitation.ttl setting of the organization is used if not set")
+	invitationsInviteCmd.Flags().StringVarP(&invitationsFile, "output", "o", "", "Output file. The invitation and its token are printed if not set")
+	invitationsListCmd.Flags().StringVarP(&invitationsFile, "output", "o", "", "Output file. The invitations are printed if not set")
+	invitationsRevokeCmd.Flags().StringVar(&invitationsInvitationID, "invitation", "", "Invitation identifier")
+	invitationsAcceptCmd.Flags().StringVar(&invitationsToken, "token", "", "Token of the invitation")
+	invitationsAcceptCmd.Flags().StringVar(&invitationsName, "name", "", "Name of the user")
+	invitationsAcceptCmd.Flags().StringVar(&invitationsLastName, "lastName", "", "Last name of the user")
+	invitationsAcceptCmd.Flags().StringVar(&invitationsTitle, "title", "", "Title of the user")
+	rootCmd.AddCommand(invitationsCmd)
+}
+
+func validateInvitationsConfig() {
+	invitationsConfig.Port = 1
+	invitationsConfig.UseDBScyllaProviders = true
+	vErr := invitationsConfig.Validate()
+	if vErr != nil {
+		log.Fatal().Str("trace", vErr.DebugReport()).Msg("invalid configuration")
+	}
+}
+
+// runInvitationManager creates an invitation manager connected to the database and executes an operation with it.
+// The invitation manager reads the settings with the connection opened for the quotas of the user manager.
+func runInvitationManager(config server.Config, operation func(manager invitation.Manager)) {
+	runUserManager(config, func(organizations orgProvider.Provider, invitations iProvider.Provider, userManager user.Manager) {
+		operation(invitation.NewManager(organizations, invitations, userManager.Quota.SettingProvider, userManager))
+	})
+}
+
+func inviteEmail() {
+	validateInvitationsConfig()
+	if invitationsExpiration < 0 {
+		log.Fatal().Str("expiration", invitationsExpiration.String()).Msg("expiration cannot be negative")
+	}
+	var expiresAt int64
+	if invitationsExpiration > 0 {
+		expiresAt = time.Now().Add(invitationsExpiration).Unix()
+	}
+
+	runInvitationManager(invitationsConfig, func(manager invitation.Manager) {
+		added, token, err := manager.Invite(invitationsOrganizationID, invitationsEmail, invitationsRoleID, invitationsInvitedBy, expiresAt)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot invite the email")
+		}
+		writeJSON(struct {
+			Invitation *entities.Invitation `json:"invitation"`
+			Token      string               `json:"token"`
+		}{added, token}, invitationsFile, "invitation")
+	})
+}
+
+func listInvitations() {
+	validateInvitationsConfig()
+
+	runInvitationManager(invitationsConfig, func(manager invitation.Manager) {
+		invitations, err := manager.ListInvitations(invitationsOrganizationID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot list the invitations")
+		}
+		writeJSON(invitations, invitationsFile, "invitations")
+	})
+}
+
+func revokeInvitation() {
+	validateInvitationsConfig()
+
+	runInvitationManager(invitationsConfig, func(manager invitation.Manager) {
+		_, err := manager.RevokeInvitation(invitationsOrganizationID, invitationsInvitationID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot revoke the invitation")
+		}
+		log.Info().Str("invitationId", invitationsInvitationID).Msg("invitation revoked")
+	})
+}
+
+func acceptInvitation() {
+	validateInvitationsConfig()
+
+	runInvitationManager(invitationsConfig, func(manager invitation.Manager) {
+		added, err := manager.AcceptInvitation(invitationsToken, invitationsName, invitationsLastName, invitationsTitle)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot accept the invitation")
+		}
+		log.Info().Str("organizationId", added.OrganizationId).Str("email", added.Email).Msg("invitation accepted")
+	})
+}
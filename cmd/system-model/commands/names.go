@@ -0,0 +1,156 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	acProvider "github.com/nalej/system-model/internal/pkg/provider/account"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	"github.com/nalej/system-model/internal/pkg/provider/blob"
+	clusterProvider "github.com/nalej/system-model/internal/pkg/provider/cluster"
+	devProvider "github.com/nalej/system-model/internal/pkg/provider/device"
+	nrProvider "github.com/nalej/system-model/internal/pkg/provider/name_reservation"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	pProvider "github.com/nalej/system-model/internal/pkg/provider/project"
+	qProvider "github.com/nalej/system-model/internal/pkg/provider/quota"
+	rProvider "github.com/nalej/system-model/internal/pkg/provider/role"
+	saProvider "github.com/nalej/system-model/internal/pkg/provider/service_account"
+	uProvider "github.com/nalej/system-model/internal/pkg/provider/user"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/account"
+	"github.com/nalej/system-model/internal/pkg/server/device"
+	"github.com/nalej/system-model/internal/pkg/server/organization"
+	"github.com/nalej/system-model/internal/pkg/server/photo"
+	"github.com/nalej/system-model/internal/pkg/server/project"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
+	"github.com/nalej/system-model/internal/pkg/server/role"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+)
+
+var namesConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var namesFile string
+
+var namesCmd = &cobra.Command{
+	Use:   "names",
+	Short: "Manage the reservations of unique names",
+	Long:  `Manage the reservations that keep the names of organizations, accounts, projects, device groups and roles unique`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var reserveNamesCmd = &cobra.Command{
+	Use:   "reserve",
+	Short: "Reserve the names of existing entities",
+	Long:  `Reserve the names of the organizations, accounts, projects, device groups and roles created before names were reserved, and report the names used by several entities`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		reserveNames()
+	},
+}
+
+func init() {
+	reserveNamesCmd.Flags().StringVar(&namesConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+	reserveNamesCmd.Flags().IntVar(&namesConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+	reserveNamesCmd.Flags().StringVar(&namesConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+	reserveNamesCmd.Flags().StringVarP(&namesFile, "output", "o", "", "Output file. The conflicts are printed if not set")
+	namesCmd.AddCommand(reserveNamesCmd)
+	rootCmd.AddCommand(namesCmd)
+}
+
+func reserveNames() {
+	namesConfig.Port = 1
+	namesConfig.UseDBScyllaProviders = true
+	vErr := namesConfig.Validate()
+	if vErr != nil {
+		log.Fatal().Str("trace", vErr.DebugReport()).Msg("invalid configuration")
+	}
+
+	address, port, keyspace := namesConfig.ScyllaDBAddress, namesConfig.ScyllaDBPort, namesConfig.KeySpace
+	names := nrProvider.NewScyllaNameReservationProvider(address, port, keyspace)
+	defer names.Disconnect()
+	organizations := orgProvider.NewScyllaOrganizationProvider(address, port, keyspace)
+	defer organizations.Disconnect()
+	settings := organization_setting.NewScyllaOrganizationSettingProvider(address, port, keyspace)
+	defer settings.Disconnect()
+	users := uProvider.NewScyllaUserProvider(address, port, keyspace)
+	defer users.Disconnect()
+	serviceAccounts := saProvider.NewScyllaServiceAccountProvider(address, port, keyspace)
+	defer serviceAccounts.Disconnect()
+	accounts := acProvider.NewScyllaAccountProvider(address, port, keyspace)
+	defer accounts.Disconnect()
+	projects := pProvider.NewScyllaProjectProvider(address, port, keyspace)
+	defer projects.Disconnect()
+	devices := devProvider.NewScyllaDeviceProvider(address, port, keyspace)
+	defer devices.Disconnect()
+	quotas := qProvider.NewScyllaQuotaProvider(address, port, keyspace)
+	defer quotas.Disconnect()
+	clusters := clusterProvider.NewScyllaClusterProvider(address, port, keyspace)
+	defer clusters.Disconnect()
+	applications := appProvider.NewScyllaApplicationProvider(address, port, keyspace)
+	defer applications.Disconnect()
+
+	blobs := blob.NewScyllaBlobProvider(address, port, keyspace)
+	defer blobs.Disconnect()
+	roles := rProvider.NewSScyllaRoleProvider(address, port, keyspace)
+	defer roles.Disconnect()
+
+	roleManager := role.NewManager(organizations, roles, names, nil)
+	orgManager := organization.NewManager(organizations, settings, users, serviceAccounts, names, photo.NewManager(blobs),
+		roleManager, false)
+	accountManager := account.NewManager(accounts, names, organizations, projects)
+	projectManager := project.NewManager(accounts, projects, names, organizations, clusters, applications, devices)
+	deviceManager := device.NewManager(devices, organizations, names, quota.NewManager(organizations, settings, quotas))
+
+	conflicts := make([]entities.NameConflict, 0)
+	for _, reserve := range []func() ([]entities.NameConflict, derrors.Error){orgManager.ReserveOrganizationNames,
+		accountManager.ReserveAccountNames, projectManager.ReserveProjectNames, deviceManager.ReserveDeviceGroupNames,
+		roleManager.ReserveRoleNames} {
+		found, err := reserve()
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot reserve the names")
+		}
+		conflicts = append(conflicts, found...)
+	}
+	if len(conflicts) > 0 {
+		log.Warn().Int("conflicts", len(conflicts)).Msg("names used by several entities must be renamed")
+	}
+
+	content, mErr := json.MarshalIndent(conflicts, "", "  ")
+	if mErr != nil {
+		log.Fatal().Err(mErr).Msg("cannot marshal the name conflicts")
+	}
+	if namesFile == "" {
+		fmt.Fprintln(os.Stdout, string(content))
+		return
+	}
+	wErr := ioutil.WriteFile(namesFile, content, 0644)
+	if wErr != nil {
+		log.Fatal().Err(wErr).Str("file", namesFile).Msg("cannot write the name conflicts")
+	}
+	log.Info().Str("file", namesFile).Int("conflicts", len(conflicts)).Msg("name conflicts written")
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/blob"
+	iProvider "github.com/nalej/system-model/internal/pkg/provider/invitation"
+	nrProvider "github.com/nalej/system-model/internal/pkg/provider/name_reservation"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/organization"
+	"github.com/nalej/system-model/internal/pkg/server/photo"
+	"github.com/nalej/system-model/internal/pkg/server/role"
+	"github.com/nalej/system-model/internal/pkg/server/user"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var photosConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+
+var photosCmd = &cobra.Command{
+	Use:   "photos",
+	Short: "Manage the photos of the organizations and users",
+	Long:  `Manage the photos of the organizations and users kept in the blob store`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var migratePhotosCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Move the existing photos to the blob store",
+	Long:  `Move the photos of the organizations and users added before the blob store was introduced, creating their thumbnails`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		migratePhotos()
+	},
+}
+
+func init() {
+	migratePhotosCmd.Flags().StringVar(&photosConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+	migratePhotosCmd.Flags().IntVar(&photosConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+	migratePhotosCmd.Flags().StringVar(&photosConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+	migratePhotosCmd.Flags().StringVar(&photosConfig.BlobDirectory, "blobDirectory", "", "Directory where the photos are stored. They are stored in the database if not set")
+	photosCmd.AddCommand(migratePhotosCmd)
+	rootCmd.AddCommand(photosCmd)
+}
+
+// connectBlobProvider returns the blob provider of the photos, kept in the file system if a directory is configured,
+// and the function that disconnects it.
+func connectBlobProvider(config server.Config) (blob.Provider, func()) {
+	if config.BlobDirectory != "" {
+		return blob.NewFileSystemBlobProvider(config.BlobDirectory), func() {}
+	}
+	blobs := blob.NewScyllaBlobProvider(config.ScyllaDBAddress, config.ScyllaDBPort, config.KeySpace)
+	return blobs, func() { blobs.Disconnect() }
+}
+
+func migratePhotos() {
+	photosConfig.Port = 1
+	photosConfig.UseDBScyllaProviders = true
+	vErr := photosConfig.Validate()
+	if vErr != nil {
+		log.Fatal().Str("trace", vErr.DebugReport()).Msg("invalid configuration")
+	}
+
+	runUserManager(photosConfig, func(organizations orgProvider.Provider, invitations iProvider.Provider, manager user.Manager) {
+		address, port, keyspace := photosConfig.ScyllaDBAddress, photosConfig.ScyllaDBPort, photosConfig.KeySpace
+		settings := organization_setting.NewScyllaOrganizationSettingProvider(address, port, keyspace)
+		defer settings.Disconnect()
+		names := nrProvider.NewScyllaNameReservationProvider(address, port, keyspace)
+		defer names.Disconnect()
+		orgManager := organization.NewManager(organizations, settings, manager.UserProvider, manager.ServiceAccountProvider, names,
+			manager.Photos, role.NewManager(organizations, manager.RoleProvider, names, nil), false)
+
+		migrated, err := orgManager.MigratePhotos()
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Int("migrated", migrated).Msg("cannot migrate the organization photos")
+		}
+		log.Info().Int("migrated", migrated).Msg("organization photos moved to the blob store")
+		migrated, err = manager.MigratePhotos()
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Int("migrated", migrated).Msg("cannot migrate the user photos")
+		}
+		log.Info().Int("migrated", migrated).Msg("user photos moved to the blob store")
+	})
+}
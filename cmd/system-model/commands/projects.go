@@ -0,0 +1,282 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nalej/grpc-account-go"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	acProvider "github.com/nalej/system-model/internal/pkg/provider/account"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	clusterProvider "github.com/nalej/system-model/internal/pkg/provider/cluster"
+	devProvider "github.com/nalej/system-model/internal/pkg/provider/device"
+	nrProvider "github.com/nalej/system-model/internal/pkg/provider/name_reservation"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	pProvider "github.com/nalej/system-model/internal/pkg/provider/project"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/account"
+	"github.com/nalej/system-model/internal/pkg/server/project"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+)
+
+var projectsConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var projectsAccountID string
+var projectsProjectID string
+var projectsOrganizationID string
+var projectsResourceType string
+var projectsResourceID string
+var projectsFile string
+var projectsState string
+var projectsStates []string
+var projectsReason string
+
+var projectsCmd = &cobra.Command{
+	Use:   "projects",
+	Short: "Manage the projects of the accounts and their resources",
+	Long:  `Link organizations to accounts, assign the resources of the organizations to the projects of their account, and manage the state of the projects`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var projectsLinkCmd = &cobra.Command{
+	Use:   "link",
+	Short: "Link an organization to an account",
+	Long:  `Link an organization to an account. An organization can only be linked to one account`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		linkOrganization()
+	},
+}
+
+var projectsUnlinkCmd = &cobra.Command{
+	Use:   "unlink",
+	Short: "Unlink an organization from an account",
+	Long:  `Remove the link between an organization and an account`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		unlinkOrganization()
+	},
+}
+
+var projectsMoveCmd = &cobra.Command{
+	Use:   "move",
+	Short: "Assign a resource of an organization to a project",
+	Long:  `Assign a cluster, application descriptor, application instance or device group of an organization to a project of its account. The resource is removed from its project if no project is set`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		moveToProject()
+	},
+}
+
+var projectsResourcesCmd = &cobra.Command{
+	Use:   "resources",
+	Short: "List the resources of a project",
+	Long:  `List the resources of the organizations of an account that are assigned to a project`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		listProjectResources()
+	},
+}
+
+var projectsStateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Change the state of a project",
+	Long:  `Change the state of a project to active, suspended, deactivated or pending_deletion, recording the reason`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		changeProjectState()
+	},
+}
+
+var projectsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the projects of an account",
+	Long:  `List the projects of an account, optionally only those in the given states`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		listProjects()
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{projectsLinkCmd, projectsUnlinkCmd, projectsMoveCmd, projectsResourcesCmd,
+		projectsStateCmd, projectsListCmd} {
+		cmd.Flags().StringVar(&projectsConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+		cmd.Flags().IntVar(&projectsConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+		cmd.Flags().StringVar(&projectsConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+		cmd.Flags().StringVar(&projectsAccountID, "account", "", "Account identifier")
+		projectsCmd.AddCommand(cmd)
+	}
+	projectsLinkCmd.Flags().StringVar(&projectsOrganizationID, "org", "", "Organization identifier")
+	projectsUnlinkCmd.Flags().StringVar(&projectsOrganizationID, "org", "", "Organization identifier")
+	projectsMoveCmd.Flags().StringVar(&projectsOrganizationID, "org", "", "Organization identifier")
+	projectsMoveCmd.Flags().StringVar(&projectsProjectID, "project", "", "Project identifier. The resource is removed from its project if not set")
+	projectsMoveCmd.Flags().StringVar(&projectsResourceType, "type", "", "Resource type: cluster, app_descriptor, app_instance or device_group")
+	projectsMoveCmd.Flags().StringVar(&projectsResourceID, "id", "", "Resource identifier")
+	projectsResourcesCmd.Flags().StringVar(&projectsProjectID, "project", "", "Project identifier")
+	projectsResourcesCmd.Flags().StringVarP(&projectsFile, "output", "o", "", "Output file. The resources are printed if not set")
+	projectsStateCmd.Flags().StringVar(&projectsProjectID, "project", "", "Project identifier")
+	projectsStateCmd.Flags().StringVar(&projectsState, "state", "", "New state: active, suspended, deactivated or pending_deletion")
+	projectsStateCmd.Flags().StringVar(&projectsReason, "reason", "", "Reason of the change, required for a non active state")
+	projectsListCmd.Flags().StringSliceVar(&projectsStates, "state", []string{}, "States of the listed projects. All the projects are listed if not set")
+	projectsListCmd.Flags().StringVarP(&projectsFile, "output", "o", "", "Output file. The projects are printed if not set")
+	rootCmd.AddCommand(projectsCmd)
+}
+
+func validateProjectsConfig() {
+	projectsConfig.Port = 1
+	projectsConfig.UseDBScyllaProviders = true
+	vErr := projectsConfig.Validate()
+	if vErr != nil {
+		log.Fatal().Str("trace", vErr.DebugReport()).Msg("invalid configuration")
+	}
+	if projectsAccountID == "" {
+		log.Fatal().Msg("account identifier must be set")
+	}
+}
+
+func linkOrganization() {
+	validateProjectsConfig()
+
+	runAccountManager(projectsConfig, func(manager account.Manager) {
+		err := manager.LinkOrganization(projectsAccountID, projectsOrganizationID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot link the organization")
+		}
+		log.Info().Str("accountId", projectsAccountID).Str("organizationId", projectsOrganizationID).Msg("organization linked")
+	})
+}
+
+func unlinkOrganization() {
+	validateProjectsConfig()
+
+	runAccountManager(projectsConfig, func(manager account.Manager) {
+		err := manager.UnlinkOrganization(projectsAccountID, projectsOrganizationID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot unlink the organization")
+		}
+		log.Info().Str("accountId", projectsAccountID).Str("organizationId", projectsOrganizationID).Msg("organization unlinked")
+	})
+}
+
+// runProjectManager creates a project manager connected to the database and executes an operation with it.
+func runProjectManager(config server.Config, operation func(manager project.Manager)) {
+	address, port, keyspace := config.ScyllaDBAddress, config.ScyllaDBPort, config.KeySpace
+	accounts := acProvider.NewScyllaAccountProvider(address, port, keyspace)
+	defer accounts.Disconnect()
+	projects := pProvider.NewScyllaProjectProvider(address, port, keyspace)
+	defer projects.Disconnect()
+	names := nrProvider.NewScyllaNameReservationProvider(address, port, keyspace)
+	defer names.Disconnect()
+	organizations := orgProvider.NewScyllaOrganizationProvider(address, port, keyspace)
+	defer organizations.Disconnect()
+	clusters := clusterProvider.NewScyllaClusterProvider(address, port, keyspace)
+	defer clusters.Disconnect()
+	applications := appProvider.NewScyllaApplicationProvider(address, port, keyspace)
+	defer applications.Disconnect()
+	devices := devProvider.NewScyllaDeviceProvider(address, port, keyspace)
+	defer devices.Disconnect()
+
+	operation(project.NewManager(accounts, projects, names, organizations, clusters, applications, devices))
+}
+
+func moveToProject() {
+	validateProjectsConfig()
+
+	runProjectManager(projectsConfig, func(manager project.Manager) {
+		err := manager.MoveToProject(projectsAccountID, projectsProjectID, projectsOrganizationID,
+			entities.ProjectResourceType(projectsResourceType), projectsResourceID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot move the resource")
+		}
+		log.Info().Str("projectId", projectsProjectID).Str("type", projectsResourceType).
+			Str("resourceId", projectsResourceID).Msg("resource moved")
+	})
+}
+
+func listProjectResources() {
+	validateProjectsConfig()
+
+	runProjectManager(projectsConfig, func(manager project.Manager) {
+		resources, err := manager.ListProjectResources(projectsAccountID, projectsProjectID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot list the resources of the project")
+		}
+		writeJSON(resources, projectsFile, "project resources")
+	})
+}
+
+func changeProjectState() {
+	validateProjectsConfig()
+	state, exists := entities.ProjectStateFromString[projectsState]
+	if !exists {
+		log.Fatal().Str("state", projectsState).Msg("invalid project state")
+	}
+
+	runProjectManager(projectsConfig, func(manager project.Manager) {
+		err := manager.ChangeProjectState(projectsAccountID, projectsProjectID, state, projectsReason)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot change the state of the project")
+		}
+		log.Info().Str("projectId", projectsProjectID).Str("state", projectsState).Msg("project state changed")
+	})
+}
+
+func listProjects() {
+	validateProjectsConfig()
+	states := make([]entities.ProjectState, 0)
+	for _, name := range projectsStates {
+		state, exists := entities.ProjectStateFromString[name]
+		if !exists {
+			log.Fatal().Str("state", name).Msg("invalid project state")
+		}
+		states = append(states, state)
+	}
+
+	runProjectManager(projectsConfig, func(manager project.Manager) {
+		projects, err := manager.ListAccountProjects(&grpc_account_go.AccountId{AccountId: projectsAccountID}, states...)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot list the projects")
+		}
+		writeJSON(projects, projectsFile, "projects")
+	})
+}
+
+// writeJSON writes a value as JSON in a file, or prints it if no file is set.
+func writeJSON(value interface{}, file string, description string) {
+	content, mErr := json.MarshalIndent(value, "", "  ")
+	if mErr != nil {
+		log.Fatal().Err(mErr).Str("content", description).Msg("cannot marshal the content")
+	}
+	if file == "" {
+		fmt.Fprintln(os.Stdout, string(content))
+		return
+	}
+	wErr := ioutil.WriteFile(file, content, 0644)
+	if wErr != nil {
+		log.Fatal().Err(wErr).Str("file", file).Str("content", description).Msg("cannot write the content")
+	}
+	log.Info().Str("file", file).Str("content", description).Msg("content written")
+}
@@ -0,0 +1,297 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appNetProvider "github.com/nalej/system-model/internal/pkg/provider/application_network"
+	devProvider "github.com/nalej/system-model/internal/pkg/provider/device"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	qProvider "github.com/nalej/system-model/internal/pkg/provider/quota"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+)
+
+var quotasConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var quotasOrganizationID string
+var quotasResource string
+var quotasLimit int64
+var quotasFile string
+
+var quotasCmd = &cobra.Command{
+	Use:   "quotas",
+	Short: "Manage the quotas of the organizations",
+	Long:  `Manage the limits of the resources an organization can create, and report their usage`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var quotasUsageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report the usage of the quotas of an organization",
+	Long:  `Report the number of resources of each type an organization has, with respect to their limits`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		reportQuotaUsage()
+	},
+}
+
+var quotasSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Set the limit of a resource of an organization",
+	Long:  `Set the limit of a resource of an organization, replacing the system default`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		setQuotaLimit()
+	},
+}
+
+var quotasResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Restore the default limit of a resource of an organization",
+	Long:  `Remove the limit of a resource of an organization, so the system default is used`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		resetQuotaLimit()
+	},
+}
+
+var quotasRecountCmd = &cobra.Command{
+	Use:   "recount",
+	Short: "Count the existing resources of the organizations",
+	Long:  `Replace the usage of the quotas with the number of existing resources, including those created before quotas were enforced`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		recountQuotaUsage()
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{quotasUsageCmd, quotasSetCmd, quotasResetCmd, quotasRecountCmd} {
+		cmd.Flags().StringVar(&quotasConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+		cmd.Flags().IntVar(&quotasConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+		cmd.Flags().StringVar(&quotasConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+		quotasCmd.AddCommand(cmd)
+	}
+	quotasUsageCmd.Flags().StringVar(&quotasOrganizationID, "org", "", "Organization identifier")
+	quotasUsageCmd.Flags().StringVarP(&quotasFile, "output", "o", "", "Output file. The report is printed if not set")
+	quotasSetCmd.Flags().StringVar(&quotasOrganizationID, "org", "", "Organization identifier")
+	quotasSetCmd.Flags().StringVar(&quotasResource, "resource", "", "Limited resource")
+	quotasSetCmd.Flags().Int64Var(&quotasLimit, "limit", 0, "Maximum number of resources of the organization")
+	quotasResetCmd.Flags().StringVar(&quotasOrganizationID, "org", "", "Organization identifier")
+	quotasResetCmd.Flags().StringVar(&quotasResource, "resource", "", "Limited resource")
+	quotasRecountCmd.Flags().StringVar(&quotasOrganizationID, "org", "", "Organization identifier. All the organizations are counted if not set")
+	quotasRecountCmd.Flags().StringVarP(&quotasFile, "output", "o", "", "Output file. The report is printed if not set")
+	rootCmd.AddCommand(quotasCmd)
+}
+
+func validateQuotasConfig() {
+	quotasConfig.Port = 1
+	quotasConfig.UseDBScyllaProviders = true
+	vErr := quotasConfig.Validate()
+	if vErr != nil {
+		log.Fatal().Str("trace", vErr.DebugReport()).Msg("invalid configuration")
+	}
+}
+
+func requireQuotasOrganization() {
+	if quotasOrganizationID == "" {
+		log.Fatal().Msg("organization identifier must be set")
+	}
+}
+
+func reportQuotaUsage() {
+	validateQuotasConfig()
+	requireQuotasOrganization()
+
+	address, port, keyspace := quotasConfig.ScyllaDBAddress, quotasConfig.ScyllaDBPort, quotasConfig.KeySpace
+	organizations := orgProvider.NewScyllaOrganizationProvider(address, port, keyspace)
+	defer organizations.Disconnect()
+	settings := organization_setting.NewScyllaOrganizationSettingProvider(address, port, keyspace)
+	defer settings.Disconnect()
+	quotas := qProvider.NewScyllaQuotaProvider(address, port, keyspace)
+	defer quotas.Disconnect()
+	manager := quota.NewManager(organizations, settings, quotas)
+
+	report, err := manager.Usage(quotasOrganizationID)
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("cannot report the quota usage")
+	}
+	writeQuotaReport(report)
+}
+
+func setQuotaLimit() {
+	validateQuotasConfig()
+	requireQuotasOrganization()
+
+	address, port, keyspace := quotasConfig.ScyllaDBAddress, quotasConfig.ScyllaDBPort, quotasConfig.KeySpace
+	organizations := orgProvider.NewScyllaOrganizationProvider(address, port, keyspace)
+	defer organizations.Disconnect()
+	settings := organization_setting.NewScyllaOrganizationSettingProvider(address, port, keyspace)
+	defer settings.Disconnect()
+	quotas := qProvider.NewScyllaQuotaProvider(address, port, keyspace)
+	defer quotas.Disconnect()
+	manager := quota.NewManager(organizations, settings, quotas)
+
+	err := manager.SetLimit(quotasOrganizationID, entities.QuotaResource(quotasResource), quotasLimit)
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("cannot set the quota limit")
+	}
+	log.Info().Str("organizationId", quotasOrganizationID).Str("resource", quotasResource).
+		Int64("limit", quotasLimit).Msg("quota limit set")
+}
+
+func resetQuotaLimit() {
+	validateQuotasConfig()
+	requireQuotasOrganization()
+
+	address, port, keyspace := quotasConfig.ScyllaDBAddress, quotasConfig.ScyllaDBPort, quotasConfig.KeySpace
+	organizations := orgProvider.NewScyllaOrganizationProvider(address, port, keyspace)
+	defer organizations.Disconnect()
+	settings := organization_setting.NewScyllaOrganizationSettingProvider(address, port, keyspace)
+	defer settings.Disconnect()
+	quotas := qProvider.NewScyllaQuotaProvider(address, port, keyspace)
+	defer quotas.Disconnect()
+	manager := quota.NewManager(organizations, settings, quotas)
+
+	err := manager.ResetLimit(quotasOrganizationID, entities.QuotaResource(quotasResource))
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("cannot reset the quota limit")
+	}
+	log.Info().Str("organizationId", quotasOrganizationID).Str("resource", quotasResource).Msg("quota limit reset")
+}
+
+// countResources counts the existing resources of each type of an organization.
+func countResources(organizations orgProvider.Provider, devices devProvider.Provider, connections appNetProvider.Provider,
+	organizationID string) (map[entities.QuotaResource]int64, derrors.Error) {
+	counts := make(map[entities.QuotaResource]int64, len(entities.QuotaResources))
+	for resource, list := range map[entities.QuotaResource]func(string) ([]string, derrors.Error){
+		entities.ClusterQuota:    organizations.ListClusters,
+		entities.NodeQuota:       organizations.ListNodes,
+		entities.DescriptorQuota: organizations.ListDescriptors,
+		entities.InstanceQuota:   organizations.ListInstances,
+		entities.UserQuota:       organizations.ListUsers,
+	} {
+		ids, err := list(organizationID)
+		if err != nil {
+			return nil, err
+		}
+		counts[resource] = int64(len(ids))
+	}
+	groups, err := devices.ListDeviceGroups(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	counts[entities.DeviceGroupQuota] = int64(len(groups))
+	for _, group := range groups {
+		groupDevices, err := devices.ListDevices(organizationID, group.DeviceGroupId)
+		if err != nil {
+			return nil, err
+		}
+		counts[entities.DeviceQuota] += int64(len(groupDevices))
+	}
+	connectionInstances, err := connections.ListConnectionInstances(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	counts[entities.AppNetworkQuota] = int64(len(connectionInstances))
+	return counts, nil
+}
+
+func recountQuotaUsage() {
+	validateQuotasConfig()
+
+	address, port, keyspace := quotasConfig.ScyllaDBAddress, quotasConfig.ScyllaDBPort, quotasConfig.KeySpace
+	organizations := orgProvider.NewScyllaOrganizationProvider(address, port, keyspace)
+	defer organizations.Disconnect()
+	settings := organization_setting.NewScyllaOrganizationSettingProvider(address, port, keyspace)
+	defer settings.Disconnect()
+	quotas := qProvider.NewScyllaQuotaProvider(address, port, keyspace)
+	defer quotas.Disconnect()
+	devices := devProvider.NewScyllaDeviceProvider(address, port, keyspace)
+	defer devices.Disconnect()
+	connections := appNetProvider.NewScyllaApplicationNetworkProvider(address, port, keyspace)
+	defer connections.Disconnect()
+	manager := quota.NewManager(organizations, settings, quotas)
+
+	organizationIDs := []string{quotasOrganizationID}
+	if quotasOrganizationID == "" {
+		list, err := organizations.List()
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot list the organizations")
+		}
+		organizationIDs = make([]string, 0, len(list))
+		for _, org := range list {
+			organizationIDs = append(organizationIDs, org.ID)
+		}
+	}
+
+	report := make([]entities.QuotaReport, 0)
+	for _, organizationID := range organizationIDs {
+		counts, err := countResources(organizations, devices, connections, organizationID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Str("organizationId", organizationID).Msg("cannot count the resources")
+		}
+		for _, resource := range entities.QuotaResources {
+			err = manager.SetUsage(organizationID, resource, counts[resource])
+			if err != nil {
+				log.Fatal().Str("trace", err.DebugReport()).Str("organizationId", organizationID).Msg("cannot set the quota usage")
+			}
+		}
+		usage, err := manager.Usage(organizationID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Str("organizationId", organizationID).Msg("cannot report the quota usage")
+		}
+		for _, resourceUsage := range usage {
+			if resourceUsage.Used > resourceUsage.Limit {
+				log.Warn().Str("organizationId", organizationID).Str("resource", string(resourceUsage.Resource)).
+					Int64("used", resourceUsage.Used).Int64("limit", resourceUsage.Limit).Msg("organization is over its quota")
+			}
+		}
+		report = append(report, usage...)
+	}
+	log.Info().Int("organizations", len(organizationIDs)).Msg("quota usage recounted")
+	writeQuotaReport(report)
+}
+
+func writeQuotaReport(report []entities.QuotaReport) {
+	content, mErr := json.MarshalIndent(report, "", "  ")
+	if mErr != nil {
+		log.Fatal().Err(mErr).Msg("cannot marshal the quota report")
+	}
+	if quotasFile == "" {
+		fmt.Fprintln(os.Stdout, string(content))
+		return
+	}
+	wErr := ioutil.WriteFile(quotasFile, content, 0644)
+	if wErr != nil {
+		log.Fatal().Err(wErr).Str("file", quotasFile).Msg("cannot write the quota report")
+	}
+	log.Info().Str("file", quotasFile).Msg("quota report written")
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"encoding/json"
+	"github.com/nalej/grpc-role-go"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	nrProvider "github.com/nalej/system-model/internal/pkg/provider/name_reservation"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	rProvider "github.com/nalej/system-model/internal/pkg/provider/role"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/role"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+)
+
+var rolesConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var rolesOrganizationID string
+var rolesRoleID string
+var rolesName string
+var rolesDescription string
+var rolesFile string
+var rolesPermissions []string
+
+var rolesCmd = &cobra.Command{
+	Use:   "roles",
+	Short: "Manage the roles of the organizations",
+	Long:  `Manage the roles of the organizations and the templates of the roles created for the new organizations`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var rolesTemplatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Print the role templates",
+	Long:  `Print the templates of the roles created for the new organizations`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		printRoleTemplates()
+	},
+}
+
+var rolesBootstrapCmd = &cobra.Command{
+	Use:   "bootstrap",
+	Short: "Create the roles of the organizations without roles",
+	Long:  `Create the roles defined by the templates in the organizations that have no roles, such as the organizations added before the role templates were introduced`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		bootstrapRoles()
+	},
+}
+
+var rolesUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update the name or the description of a role",
+	Long:  `Update the name or the description of a role. The names of the roles are unique in an organization, and the internal roles cannot be modified`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		updateRole(cmd.Flags().Changed("name"), cmd.Flags().Changed("description"))
+	},
+}
+
+var rolesPermissionsCmd = &cobra.Command{
+	Use:   "permissions",
+	Short: "Set the permissions of a role",
+	Long:  `Replace the permissions granted by a role. The permissions of the internal roles cannot be changed`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		setRolePermissions()
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{rolesBootstrapCmd, rolesUpdateCmd, rolesPermissionsCmd} {
+		cmd.Flags().StringVar(&rolesConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+		cmd.Flags().IntVar(&rolesConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+		cmd.Flags().StringVar(&rolesConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+	}
+	for _, cmd := range []*cobra.Command{rolesTemplatesCmd, rolesBootstrapCmd} {
+		cmd.Flags().StringVar(&rolesConfig.RoleTemplatesFile, "roleTemplates", "", "JSON file with the roles created for the new organizations. The default roles are created if not set")
+	}
+	rolesTemplatesCmd.Flags().StringVarP(&rolesFile, "output", "o", "", "Output file. The templates are printed if not set")
+	rolesUpdateCmd.Flags().StringVar(&rolesOrganizationID, "organization", "", "Organization identifier")
+	rolesUpdateCmd.Flags().StringVar(&rolesRoleID, "role", "", "Role identifier")
+	rolesUpdateCmd.Flags().StringVar(&rolesName, "name", "", "New name of the role")
+	rolesUpdateCmd.Flags().StringVar(&rolesDescription, "description", "", "New description of the role")
+	rolesPermissionsCmd.Flags().StringVar(&rolesOrganizationID, "organization", "", "Organization identifier")
+	rolesPermissionsCmd.Flags().StringVar(&rolesRoleID, "role", "", "Role identifier")
+	rolesPermissionsCmd.Flags().StringSliceVar(&rolesPermissions, "permission", []string{}, "Permission granted by the role as resource:action, it can be repeated")
+	rolesPermissionsCmd.Flags().StringVarP(&rolesFile, "file", "f", "", "JSON file with the list of permissions granted by the role, used instead of --permission to set labels")
+	rolesCmd.AddCommand(rolesTemplatesCmd, rolesBootstrapCmd, rolesUpdateCmd, rolesPermissionsCmd)
+	rootCmd.AddCommand(rolesCmd)
+}
+
+func validateRolesConfig() {
+	rolesConfig.Port = 1
+	rolesConfig.UseDBScyllaProviders = true
+	vErr := rolesConfig.Validate()
+	if vErr != nil {
+		log.Fatal().Str("trace", vErr.DebugReport()).Msg("invalid configuration")
+	}
+}
+
+// runRoleManager creates a role manager connected to the database and executes an operation with it.
+func runRoleManager(config server.Config, operation func(manager role.Manager)) {
+	templates, err := config.LoadRoleTemplates()
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("invalid role templates")
+	}
+	address, port, keyspace := config.ScyllaDBAddress, config.ScyllaDBPort, config.KeySpace
+	organizations := orgProvider.NewScyllaOrganizationProvider(address, port, keyspace)
+	defer organizations.Disconnect()
+	roles := rProvider.NewSScyllaRoleProvider(address, port, keyspace)
+	defer roles.Disconnect()
+	names := nrProvider.NewScyllaNameReservationProvider(address, port, keyspace)
+	defer names.Disconnect()
+
+	operation(role.NewManager(organizations, roles, names, templates))
+}
+
+func printRoleTemplates() {
+	templates, err := rolesConfig.LoadRoleTemplates()
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("invalid role templates")
+	}
+	writeJSON(templates, rolesFile, "role templates")
+}
+
+func bootstrapRoles() {
+	validateRolesConfig()
+
+	runRoleManager(rolesConfig, func(manager role.Manager) {
+		bootstrapped, err := manager.BootstrapRoles()
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Int("organizations", bootstrapped).Msg("cannot create the roles")
+		}
+		log.Info().Int("organizations", bootstrapped).Msg("roles created")
+	})
+}
+
+func updateRole(updateName bool, updateDescription bool) {
+	validateRolesConfig()
+
+	runRoleManager(rolesConfig, func(manager role.Manager) {
+		updated, err := manager.UpdateRole(entities.UpdateRoleRequest{OrganizationId: rolesOrganizationID, RoleId: rolesRoleID,
+			UpdateName: updateName, Name: rolesName, UpdateDescription: updateDescription, Description: rolesDescription})
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot update the role")
+		}
+		log.Info().Str("roleId", updated.RoleId).Str("name", updated.Name).Msg("role updated")
+	})
+}
+
+// readPermissions reads a JSON list of permissions.
+func readPermissions(file string) []entities.Permission {
+	content, rErr := ioutil.ReadFile(file)
+	if rErr != nil {
+		log.Fatal().Err(rErr).Str("file", file).Msg("cannot read the permissions")
+	}
+	permissions := make([]entities.Permission, 0)
+	uErr := json.Unmarshal(content, &permissions)
+	if uErr != nil {
+		log.Fatal().Err(uErr).Str("file", file).Msg("cannot parse the permissions")
+	}
+	return permissions
+}
+
+func setRolePermissions() {
+	validateRolesConfig()
+	permissions := parsePermissions(rolesPermissions)
+	if rolesFile != "" {
+		permissions = readPermissions(rolesFile)
+	}
+
+	runRoleManager(rolesConfig, func(manager role.Manager) {
+		updated, err := manager.SetRolePermissions(&grpc_role_go.RoleId{OrganizationId: rolesOrganizationID, RoleId: rolesRoleID}, permissions)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot set the permissions of the role")
+		}
+		log.Info().Str("roleId", updated.RoleId).Int("permissions", len(updated.Permissions)).Msg("role permissions set")
+	})
+}
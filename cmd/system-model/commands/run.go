@@ -19,9 +19,12 @@
 package commands
 
 import (
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/server"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"time"
 )
 
 var config = server.Config{}
@@ -48,5 +51,22 @@ func init() {
 	runCmd.Flags().IntVar(&config.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
 	runCmd.Flags().StringVar(&config.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
 	runCmd.Flags().StringVar(&config.PublicHostDomain, "publicHost", "nalej.cluster.local", "Public Hostname for the domain")
+	runCmd.Flags().StringVar(&config.IPAMPool, "ipamPool", cidr.DefaultPool, "Network from which the IP ranges of the organizations are allocated")
+	runCmd.Flags().IntVar(&config.IPAMBlockPrefixLength, "ipamBlockPrefixLength", cidr.DefaultBlockPrefixLength, "Prefix length of the allocated IP ranges")
+	runCmd.Flags().StringVar(&config.ConnectionStatusPolicy, "connectionStatusPolicy", string(entities.DefaultConnectionStatusPolicy),
+		"Policy to derive the status of the connections from their links: all, majority or any")
+	runCmd.Flags().DurationVar(&config.ZtGCInterval, "ztGCInterval", time.Hour, "Period of the garbage collection of orphan zt members and proxies, 0 to disable it")
+	runCmd.Flags().DurationVar(&config.ZtGCGracePeriod, "ztGCGracePeriod", 15*time.Minute, "Time an orphan zt member or proxy is kept before being removed")
+	runCmd.Flags().StringVar(&config.TLSCertFile, "tlsCert", "", "Server certificate. TLS is disabled if not set")
+	runCmd.Flags().StringVar(&config.TLSKeyFile, "tlsKey", "", "Private key of the server certificate")
+	runCmd.Flags().StringVar(&config.TLSClientCAFile, "tlsClientCA", "", "CA of the client certificates. Mutual TLS is disabled if not set")
+	runCmd.Flags().StringVar(&config.JWTSecretFile, "jwtSecretFile", "", "File with the secret of the HS256 signed tokens")
+	runCmd.Flags().StringVar(&config.JWTPublicKeyFile, "jwtPublicKey", "", "Public key or certificate of the RS256 signed tokens")
+	runCmd.Flags().StringVar(&config.JWTIssuer, "jwtIssuer", "", "Issuer expected in the tokens")
+	runCmd.Flags().StringVar(&config.JWTAudience, "jwtAudience", "", "Audience expected in the tokens")
+	runCmd.Flags().BoolVar(&config.UnauthenticatedReflection, "unauthenticatedReflection", false, "Allow the use of the gRPC reflection service without authentication")
+	runCmd.Flags().BoolVar(&config.StrictSettings, "strictSettings", false, "Reject the organization settings that are not defined in the settings schema")
+	runCmd.Flags().StringVar(&config.RoleTemplatesFile, "roleTemplates", "", "JSON file with the roles created for the new organizations. The default roles are created if not set")
+	runCmd.Flags().StringVar(&config.BlobDirectory, "blobDirectory", "", "Directory where the photos are stored. They are stored in the database if not set")
 
 }
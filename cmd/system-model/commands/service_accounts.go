@@ -0,0 +1,264 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	iProvider "github.com/nalej/system-model/internal/pkg/provider/invitation"
+	nrProvider "github.com/nalej/system-model/internal/pkg/provider/name_reservation"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/organization"
+	"github.com/nalej/system-model/internal/pkg/server/role"
+	"github.com/nalej/system-model/internal/pkg/server/user"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"strings"
+	"time"
+)
+
+var serviceAccountsConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var serviceAccountsOrganizationID string
+var serviceAccountsServiceAccountID string
+var serviceAccountsName string
+var serviceAccountsDescription string
+var serviceAccountsKeyID string
+var serviceAccountsPermissions []string
+var serviceAccountsExpiration time.Duration
+var serviceAccountsKey string
+var serviceAccountsFile string
+
+var serviceAccountsCmd = &cobra.Command{
+	Use:   "serviceaccounts",
+	Short: "Manage the service accounts of the organizations",
+	Long:  `Manage the service accounts of the organizations and their API keys, and verify the API keys`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var serviceAccountsAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a service account to an organization",
+	Long:  `Add an enabled service account to an organization`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		addServiceAccount()
+	},
+}
+
+var serviceAccountsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the service accounts of an organization",
+	Long:  `List the service accounts of an organization`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		listServiceAccounts()
+	},
+}
+
+var serviceAccountsCreateKeyCmd = &cobra.Command{
+	Use:   "createKey",
+	Short: "Create an API key for a service account",
+	Long:  `Create an API key granting a set of permissions to a service account. The plain key is only printed once`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		createAPIKey()
+	},
+}
+
+var serviceAccountsListKeysCmd = &cobra.Command{
+	Use:   "listKeys",
+	Short: "List the API keys of a service account",
+	Long:  `List the API keys of a service account, including the revoked keys`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		listAPIKeys()
+	},
+}
+
+var serviceAccountsRevokeKeyCmd = &cobra.Command{
+	Use:   "revokeKey",
+	Short: "Revoke an API key",
+	Long:  `Revoke an API key. Revoked keys are kept so their use can be audited`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		revokeAPIKey()
+	},
+}
+
+var serviceAccountsVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify an API key",
+	Long:  `Verify a plain API key as a gateway does, printing the service account and the permissions of valid keys or the reason a key is rejected`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		verifyAPIKey()
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{serviceAccountsAddCmd, serviceAccountsListCmd, serviceAccountsCreateKeyCmd,
+		serviceAccountsListKeysCmd, serviceAccountsRevokeKeyCmd, serviceAccountsVerifyCmd} {
+		cmd.Flags().StringVar(&serviceAccountsConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+		cmd.Flags().IntVar(&serviceAccountsConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+		cmd.Flags().StringVar(&serviceAccountsConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+		serviceAccountsCmd.AddCommand(cmd)
+	}
+	for _, cmd := range []*cobra.Command{serviceAccountsAddCmd, serviceAccountsListCmd, serviceAccountsCreateKeyCmd,
+		serviceAccountsListKeysCmd, serviceAccountsRevokeKeyCmd} {
+		cmd.Flags().StringVar(&serviceAccountsOrganizationID, "organization", "", "Organization identifier")
+	}
+	for _, cmd := range []*cobra.Command{serviceAccountsCreateKeyCmd, serviceAccountsListKeysCmd} {
+		cmd.Flags().StringVar(&serviceAccountsServiceAccountID, "serviceAccount", "", "Service account identifier")
+	}
+	serviceAccountsAddCmd.Flags().StringVar(&serviceAccountsName, "name", "", "Name of the service account")
+	serviceAccountsAddCmd.Flags().StringVar(&serviceAccountsDescription, "description", "", "Description of the service account")
+	serviceAccountsListCmd.Flags().StringVarP(&serviceAccountsFile, "output", "o", "", "Output file. The service accounts are printed if not set")
+	serviceAccountsCreateKeyCmd.Flags().StringSliceVar(&serviceAccountsPermissions, "permission", []string{}, "Permission granted by the key as resource:action, it can be repeated")
+	serviceAccountsCreateKeyCmd.Flags().DurationVar(&serviceAccountsExpiration, "expiration", 0, "Time the key is valid. The key does not expire if not set")
+	serviceAccountsCreateKeyCmd.Flags().StringVarP(&serviceAccountsFile, "output", "o", "", "Output file. The key and the plain key are printed if not set")
+	serviceAccountsListKeysCmd.Flags().StringVarP(&serviceAccountsFile, "output", "o", "", "Output file. The keys are printed if not set")
+	serviceAccountsRevokeKeyCmd.Flags().StringVar(&serviceAccountsKeyID, "key", "", "Key identifier")
+	serviceAccountsVerifyCmd.Flags().StringVar(&serviceAccountsKey, "key", "", "Plain API key")
+	serviceAccountsVerifyCmd.Flags().StringVarP(&serviceAccountsFile, "output", "o", "", "Output file. The verification is printed if not set")
+	rootCmd.AddCommand(serviceAccountsCmd)
+}
+
+func validateServiceAccountsConfig() {
+	serviceAccountsConfig.Port = 1
+	serviceAccountsConfig.UseDBScyllaProviders = true
+	vErr := serviceAccountsConfig.Validate()
+	if vErr != nil {
+		log.Fatal().Str("trace", vErr.DebugReport()).Msg("invalid configuration")
+	}
+}
+
+// runServiceAccountManagers creates the organization manager, that manages the service accounts, and the user
+// manager, that manages their keys, and executes an operation with them.
+func runServiceAccountManagers(config server.Config, operation func(orgManager organization.Manager, userManager user.Manager)) {
+	runUserManager(config, func(organizations orgProvider.Provider, invitations iProvider.Provider, manager user.Manager) {
+		address, port, keyspace := config.ScyllaDBAddress, config.ScyllaDBPort, config.KeySpace
+		settings := organization_setting.NewScyllaOrganizationSettingProvider(address, port, keyspace)
+		defer settings.Disconnect()
+		names := nrProvider.NewScyllaNameReservationProvider(address, port, keyspace)
+		defer names.Disconnect()
+		orgManager := organization.NewManager(organizations, settings, manager.UserProvider, manager.ServiceAccountProvider, names,
+			manager.Photos, role.NewManager(organizations, manager.RoleProvider, names, nil), false)
+		operation(orgManager, manager)
+	})
+}
+
+// parsePermissions parses the permissions of a key given as resource:action.
+func parsePermissions(values []string) []entities.Permission {
+	permissions := make([]entities.Permission, 0, len(values))
+	for _, value := range values {
+		parts := strings.Split(value, ":")
+		if len(parts) != 2 {
+			log.Fatal().Str("permission", value).Msg("permissions must be set as resource:action")
+		}
+		permissions = append(permissions, entities.Permission{Resource: entities.ResourceKind(parts[0]), Action: entities.Action(parts[1])})
+	}
+	return permissions
+}
+
+func addServiceAccount() {
+	validateServiceAccountsConfig()
+
+	runServiceAccountManagers(serviceAccountsConfig, func(orgManager organization.Manager, userManager user.Manager) {
+		added, err := orgManager.AddServiceAccount(serviceAccountsOrganizationID, serviceAccountsName, serviceAccountsDescription)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot add the service account")
+		}
+		log.Info().Str("serviceAccountId", added.ServiceAccountId).Str("name", added.Name).Msg("service account added")
+	})
+}
+
+func listServiceAccounts() {
+	validateServiceAccountsConfig()
+
+	runServiceAccountManagers(serviceAccountsConfig, func(orgManager organization.Manager, userManager user.Manager) {
+		accounts, err := orgManager.ListServiceAccounts(serviceAccountsOrganizationID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot list the service accounts")
+		}
+		writeJSON(accounts, serviceAccountsFile, "service accounts")
+	})
+}
+
+func createAPIKey() {
+	validateServiceAccountsConfig()
+	if serviceAccountsExpiration < 0 {
+		log.Fatal().Str("expiration", serviceAccountsExpiration.String()).Msg("expiration cannot be negative")
+	}
+	var expiresAt int64
+	if serviceAccountsExpiration > 0 {
+		expiresAt = time.Now().Add(serviceAccountsExpiration).Unix()
+	}
+	permissions := parsePermissions(serviceAccountsPermissions)
+
+	runServiceAccountManagers(serviceAccountsConfig, func(orgManager organization.Manager, userManager user.Manager) {
+		key, plainKey, err := userManager.CreateAPIKey(serviceAccountsOrganizationID, serviceAccountsServiceAccountID, permissions, expiresAt)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot create the api key")
+		}
+		writeJSON(struct {
+			Key      *entities.APIKey `json:"key"`
+			PlainKey string           `json:"plain_key"`
+		}{key, plainKey}, serviceAccountsFile, "api key")
+	})
+}
+
+func listAPIKeys() {
+	validateServiceAccountsConfig()
+
+	runServiceAccountManagers(serviceAccountsConfig, func(orgManager organization.Manager, userManager user.Manager) {
+		keys, err := userManager.ListAPIKeys(serviceAccountsOrganizationID, serviceAccountsServiceAccountID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot list the api keys")
+		}
+		writeJSON(keys, serviceAccountsFile, "api keys")
+	})
+}
+
+func revokeAPIKey() {
+	validateServiceAccountsConfig()
+
+	runServiceAccountManagers(serviceAccountsConfig, func(orgManager organization.Manager, userManager user.Manager) {
+		_, err := userManager.RevokeAPIKey(serviceAccountsOrganizationID, serviceAccountsKeyID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot revoke the api key")
+		}
+		log.Info().Str("keyId", serviceAccountsKeyID).Msg("api key revoked")
+	})
+}
+
+func verifyAPIKey() {
+	validateServiceAccountsConfig()
+
+	runServiceAccountManagers(serviceAccountsConfig, func(orgManager organization.Manager, userManager user.Manager) {
+		verification, err := userManager.VerifyAPIKey(serviceAccountsKey)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot verify the api key")
+		}
+		writeJSON(verification, serviceAccountsFile, "api key verification")
+	})
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"fmt"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	appNetProvider "github.com/nalej/system-model/internal/pkg/provider/application_network"
+	ipamProvider "github.com/nalej/system-model/internal/pkg/provider/ipam"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	qProvider "github.com/nalej/system-model/internal/pkg/provider/quota"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/application_network"
+	"github.com/nalej/system-model/internal/pkg/server/ipam"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
+	"github.com/nalej/system-model/internal/pkg/topology"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+)
+
+var topologyConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var topologyOrganizationID string
+var topologyFormat string
+var topologyFile string
+
+var topologyCmd = &cobra.Command{
+	Use:   "topology",
+	Short: "Export the connection graph of an organization",
+	Long:  `Export the connections between the application instances of an organization as JSON or Graphviz DOT, including the issues detected in the graph`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		exportTopology()
+	},
+}
+
+func init() {
+	topologyCmd.Flags().StringVar(&topologyConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+	topologyCmd.Flags().IntVar(&topologyConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+	topologyCmd.Flags().StringVar(&topologyConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+	topologyCmd.Flags().StringVar(&topologyOrganizationID, "org", "", "Organization identifier")
+	topologyCmd.Flags().StringVar(&topologyFormat, "format", "json", "Output format (json or dot)")
+	topologyCmd.Flags().StringVarP(&topologyFile, "output", "o", "", "Output file. The graph is printed if not set")
+	rootCmd.AddCommand(topologyCmd)
+}
+
+func exportTopology() {
+	if topologyOrganizationID == "" {
+		log.Fatal().Msg("org must be set")
+	}
+	topologyConfig.Port = 1
+	topologyConfig.UseDBScyllaProviders = true
+	vErr := topologyConfig.Validate()
+	if vErr != nil {
+		log.Fatal().Str("trace", vErr.DebugReport()).Msg("invalid configuration")
+	}
+	format, err := topology.ParseFormat(topologyFormat)
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("invalid format")
+	}
+
+	organizations := orgProvider.NewScyllaOrganizationProvider(topologyConfig.ScyllaDBAddress, topologyConfig.ScyllaDBPort, topologyConfig.KeySpace)
+	defer organizations.Disconnect()
+	applications := appProvider.NewScyllaApplicationProvider(topologyConfig.ScyllaDBAddress, topologyConfig.ScyllaDBPort, topologyConfig.KeySpace)
+	defer applications.Disconnect()
+	connections := appNetProvider.NewScyllaApplicationNetworkProvider(topologyConfig.ScyllaDBAddress, topologyConfig.ScyllaDBPort, topologyConfig.KeySpace)
+	defer connections.Disconnect()
+	ipRanges := ipamProvider.NewScyllaIPAMProvider(topologyConfig.ScyllaDBAddress, topologyConfig.ScyllaDBPort, topologyConfig.KeySpace)
+	defer ipRanges.Disconnect()
+	settings := organization_setting.NewScyllaOrganizationSettingProvider(topologyConfig.ScyllaDBAddress, topologyConfig.ScyllaDBPort, topologyConfig.KeySpace)
+	defer settings.Disconnect()
+	quotas := qProvider.NewScyllaQuotaProvider(topologyConfig.ScyllaDBAddress, topologyConfig.ScyllaDBPort, topologyConfig.KeySpace)
+	defer quotas.Disconnect()
+	pool, err := cidr.ParsePool(topologyConfig.IPAMPool, topologyConfig.IPAMBlockPrefixLength)
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("invalid ip pool")
+	}
+	manager := application_network.NewManager(organizations, applications, connections,
+		ipam.NewManager(organizations, ipRanges, pool), entities.ConnectionStatusPolicy(topologyConfig.ConnectionStatusPolicy),
+		quota.NewManager(organizations, settings, quotas))
+
+	graph, err := manager.GetConnectionGraph(&grpc_organization_go.OrganizationId{OrganizationId: topologyOrganizationID})
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("cannot build the connection graph")
+	}
+	for _, issue := range graph.Issues {
+		log.Warn().Str("type", string(issue.Type)).Str("instance", issue.AppInstanceId).
+			Str("interface", issue.InterfaceName).Str("connection", issue.ConnectionId).
+			Strs("instances", issue.Instances).Msg("connection graph issue")
+	}
+	content, err := graph.Render(format)
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("cannot render the connection graph")
+	}
+
+	if topologyFile == "" {
+		fmt.Fprint(os.Stdout, string(content))
+		return
+	}
+	wErr := ioutil.WriteFile(topologyFile, content, 0644)
+	if wErr != nil {
+		log.Fatal().Err(wErr).Str("file", topologyFile).Msg("cannot write the connection graph")
+	}
+	log.Info().Str("file", topologyFile).Int("issues", len(graph.Issues)).Msg("connection graph exported")
+}
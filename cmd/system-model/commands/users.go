@@ -0,0 +1,307 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	iProvider "github.com/nalej/system-model/internal/pkg/provider/invitation"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	qProvider "github.com/nalej/system-model/internal/pkg/provider/quota"
+	rProvider "github.com/nalej/system-model/internal/pkg/provider/role"
+	saProvider "github.com/nalej/system-model/internal/pkg/provider/service_account"
+	uProvider "github.com/nalej/system-model/internal/pkg/provider/user"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/personal_data"
+	"github.com/nalej/system-model/internal/pkg/server/photo"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
+	"github.com/nalej/system-model/internal/pkg/server/user"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+var usersConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var usersEmail string
+var usersFile string
+var usersOrganizationID string
+var usersRoleID string
+var usersState string
+var usersAction string
+var usersResourceKind string
+var usersResourceID string
+var usersResourceLabels map[string]string
+
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Manage the users of the organizations",
+	Long:  `Manage the users of the organizations`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var migrateUsersCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Create the memberships of existing users",
+	Long:  `Create the organization memberships of the users added before users could belong to several organizations`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		migrateUsers()
+	},
+}
+
+var exportUserCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the personal data of an email",
+	Long:  `Export all the information held about an email address as a JSON document`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		exportPersonalData()
+	},
+}
+
+var eraseUserCmd = &cobra.Command{
+	Use:   "erase",
+	Short: "Erase the personal data of an email",
+	Long:  `Remove or anonymize all the information held about an email address, and print a report verifying the erasure`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		erasePersonalData()
+	},
+}
+
+var assignRoleCmd = &cobra.Command{
+	Use:     "assignRole",
+	Aliases: []string{"assign-role"},
+	Short:   "Assign a role to a user",
+	Long:    `Assign a role of an organization to a member of the organization`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		assignRole()
+	},
+}
+
+var unassignRoleCmd = &cobra.Command{
+	Use:     "unassignRole",
+	Aliases: []string{"unassign-role"},
+	Short:   "Remove a role from a user",
+	Long:    `Remove a role of an organization from a member of the organization`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		unassignRole()
+	},
+}
+
+var setMembershipStateCmd = &cobra.Command{
+	Use:     "setState",
+	Aliases: []string{"set-state"},
+	Short:   "Change the state of the membership of a user",
+	Long:    `Activate or suspend the membership of a user in an organization. Suspended members are not authorized`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		setMembershipState()
+	},
+}
+
+var authorizeUserCmd = &cobra.Command{
+	Use:   "authorize",
+	Short: "Check if a user is allowed an action on a resource",
+	Long:  `Check if any of the roles of a user in an organization grants an action on a resource. The decision is printed as JSON, and the command exits with status 1 if the action is not allowed`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		authorizeUser()
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{assignRoleCmd, unassignRoleCmd, setMembershipStateCmd, authorizeUserCmd} {
+		cmd.Flags().StringVar(&usersOrganizationID, "org", "", "Organization identifier")
+		cmd.Flags().StringVar(&usersEmail, "email", "", "Email of the user")
+	}
+	for _, cmd := range []*cobra.Command{assignRoleCmd, unassignRoleCmd} {
+		cmd.Flags().StringVar(&usersRoleID, "role", "", "Role identifier")
+	}
+	setMembershipStateCmd.Flags().StringVar(&usersState, "state", "", "State of the membership (ACTIVE or SUSPENDED)")
+	authorizeUserCmd.Flags().StringVar(&usersAction, "action", "", "Action on the resource (read, create, update or delete)")
+	authorizeUserCmd.Flags().StringVar(&usersResourceKind, "resource", "", "Kind of resource (cluster, app_instance, device_group, ...)")
+	authorizeUserCmd.Flags().StringVar(&usersResourceID, "resourceId", "", "Resource identifier, empty for actions on all the resources of a kind")
+	authorizeUserCmd.Flags().StringToStringVar(&usersResourceLabels, "label", map[string]string{}, "Label of the resource as key=value, it can be repeated")
+	for _, cmd := range []*cobra.Command{migrateUsersCmd, exportUserCmd, eraseUserCmd, assignRoleCmd, unassignRoleCmd, setMembershipStateCmd, authorizeUserCmd} {
+		cmd.Flags().StringVar(&usersConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+		cmd.Flags().IntVar(&usersConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+		cmd.Flags().StringVar(&usersConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+		cmd.Flags().StringVar(&usersConfig.BlobDirectory, "blobDirectory", "", "Directory where the photos are stored. They are stored in the database if not set")
+		usersCmd.AddCommand(cmd)
+	}
+	exportUserCmd.Flags().StringVar(&usersEmail, "email", "", "Email of the user")
+	exportUserCmd.Flags().StringVarP(&usersFile, "output", "o", "", "Output file. The data is printed if not set")
+	eraseUserCmd.Flags().StringVar(&usersEmail, "email", "", "Email of the user")
+	eraseUserCmd.Flags().StringVarP(&usersFile, "output", "o", "", "Output file. The report is printed if not set")
+	rootCmd.AddCommand(usersCmd)
+}
+
+func validateUsersConfig() {
+	usersConfig.Port = 1
+	usersConfig.UseDBScyllaProviders = true
+	vErr := usersConfig.Validate()
+	if vErr != nil {
+		log.Fatal().Str("trace", vErr.DebugReport()).Msg("invalid configuration")
+	}
+}
+
+// runUserManager creates a user manager connected to the database and executes an operation with it and the
+// providers of the organizations and the invitations.
+func runUserManager(config server.Config, operation func(organizations orgProvider.Provider, invitations iProvider.Provider, manager user.Manager)) {
+	address, port, keyspace := config.ScyllaDBAddress, config.ScyllaDBPort, config.KeySpace
+	organizations := orgProvider.NewScyllaOrganizationProvider(address, port, keyspace)
+	defer organizations.Disconnect()
+	users := uProvider.NewScyllaUserProvider(address, port, keyspace)
+	defer users.Disconnect()
+	roles := rProvider.NewSScyllaRoleProvider(address, port, keyspace)
+	defer roles.Disconnect()
+	serviceAccounts := saProvider.NewScyllaServiceAccountProvider(address, port, keyspace)
+	defer serviceAccounts.Disconnect()
+	settings := organization_setting.NewScyllaOrganizationSettingProvider(address, port, keyspace)
+	defer settings.Disconnect()
+	quotas := qProvider.NewScyllaQuotaProvider(address, port, keyspace)
+	defer quotas.Disconnect()
+	invitations := iProvider.NewScyllaInvitationProvider(address, port, keyspace)
+	defer invitations.Disconnect()
+	blobs, disconnect := connectBlobProvider(config)
+	defer disconnect()
+
+	operation(organizations, invitations, user.NewManager(organizations, users, roles, serviceAccounts,
+		quota.NewManager(organizations, settings, quotas), photo.NewManager(blobs)))
+}
+
+func migrateUsers() {
+	validateUsersConfig()
+
+	runUserManager(usersConfig, func(organizations orgProvider.Provider, invitations iProvider.Provider, manager user.Manager) {
+		migrated, err := manager.MigrateMemberships()
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Int("migrated", migrated).Msg("cannot migrate the users")
+		}
+		log.Info().Int("migrated", migrated).Msg("user memberships created")
+	})
+}
+
+func exportPersonalData() {
+	validateUsersConfig()
+
+	runUserManager(usersConfig, func(organizations orgProvider.Provider, invitations iProvider.Provider, manager user.Manager) {
+		dataManager := personal_data.NewManager(organizations, invitations, manager)
+		exported, err := dataManager.Export(usersEmail)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot export the personal data")
+		}
+		writeJSON(exported, usersFile, "personal data")
+	})
+}
+
+func erasePersonalData() {
+	validateUsersConfig()
+
+	runUserManager(usersConfig, func(organizations orgProvider.Provider, invitations iProvider.Provider, manager user.Manager) {
+		dataManager := personal_data.NewManager(organizations, invitations, manager)
+		report, err := dataManager.Erase(usersEmail)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot erase the personal data")
+		}
+		writeJSON(report, usersFile, "erasure report")
+		if !report.Verified {
+			log.Fatal().Strs("remaining", report.Remaining).Msg("personal data remains after the erasure")
+		}
+	})
+}
+
+// getUserID returns the identifier of the member selected by the user.
+func getUserID() *grpc_user_go.UserId {
+	if usersOrganizationID == "" || usersEmail == "" {
+		log.Fatal().Msg("org and email must be set")
+	}
+	return &grpc_user_go.UserId{OrganizationId: usersOrganizationID, Email: usersEmail}
+}
+
+func assignRole() {
+	validateUsersConfig()
+	userID := getUserID()
+
+	runUserManager(usersConfig, func(organizations orgProvider.Provider, invitations iProvider.Provider, manager user.Manager) {
+		membership, err := manager.AssignRole(userID, usersRoleID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot assign the role")
+		}
+		log.Info().Str("email", membership.Email).Strs("roleIds", membership.RoleIds).Msg("role assigned")
+	})
+}
+
+func unassignRole() {
+	validateUsersConfig()
+	userID := getUserID()
+
+	runUserManager(usersConfig, func(organizations orgProvider.Provider, invitations iProvider.Provider, manager user.Manager) {
+		membership, err := manager.UnassignRole(userID, usersRoleID)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot remove the role")
+		}
+		log.Info().Str("email", membership.Email).Strs("roleIds", membership.RoleIds).Msg("role removed")
+	})
+}
+
+func setMembershipState() {
+	validateUsersConfig()
+	userID := getUserID()
+	var state entities.MembershipState
+	for value, name := range entities.MembershipStateToString {
+		if name == strings.ToUpper(usersState) {
+			state = value
+		}
+	}
+
+	runUserManager(usersConfig, func(organizations orgProvider.Provider, invitations iProvider.Provider, manager user.Manager) {
+		membership, err := manager.SetMembershipState(userID, state)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot change the state of the membership")
+		}
+		log.Info().Str("email", membership.Email).Str("state", entities.MembershipStateToString[membership.State]).Msg("membership state changed")
+	})
+}
+
+func authorizeUser() {
+	validateUsersConfig()
+	userID := getUserID()
+	resource := &entities.Resource{OrganizationId: usersOrganizationID, Kind: entities.ResourceKind(usersResourceKind),
+		Id: usersResourceID, Labels: usersResourceLabels}
+
+	runUserManager(usersConfig, func(organizations orgProvider.Provider, invitations iProvider.Provider, manager user.Manager) {
+		decision, err := manager.Authorize(userID, entities.Action(usersAction), resource)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot authorize the user")
+		}
+		writeJSON(decision, usersFile, "authorization decision")
+		if !decision.Allowed {
+			os.Exit(1)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"fmt"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/dns"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	zoneProvider "github.com/nalej/system-model/internal/pkg/provider/dns_zone"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server"
+	"github.com/nalej/system-model/internal/pkg/server/application"
+	"github.com/nalej/system-model/internal/pkg/server/dns_zone"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+)
+
+var zoneConfig = server.Config{IPAMPool: cidr.DefaultPool, IPAMBlockPrefixLength: cidr.DefaultBlockPrefixLength,
+	ConnectionStatusPolicy: string(entities.DefaultConnectionStatusPolicy)}
+var zoneOrganizationID string
+var zoneFormat string
+var zoneFile string
+
+var zoneCmd = &cobra.Command{
+	Use:   "zone",
+	Short: "Manage the DNS zone of the application endpoints",
+	Long:  `Manage the DNS zone of the application endpoints`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		cmd.Help()
+	},
+}
+
+var exportZoneCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the current DNS zone",
+	Long:  `Export the endpoints of an organization, or of the whole system, as an RFC 1035 zone file or as CoreDNS records`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		exportZone()
+	},
+}
+
+func init() {
+	exportZoneCmd.Flags().StringVar(&zoneConfig.ScyllaDBAddress, "scyllaDBAddress", "", "address to connect to scylla database")
+	exportZoneCmd.Flags().IntVar(&zoneConfig.ScyllaDBPort, "scyllaDBPort", 9042, "port to connect to scylla database")
+	exportZoneCmd.Flags().StringVar(&zoneConfig.KeySpace, "scyllaDBKeyspace", "", "keyspace of scylla database")
+	exportZoneCmd.Flags().StringVar(&zoneConfig.PublicHostDomain, "publicHost", "nalej.cluster.local", "Public Hostname for the domain")
+	exportZoneCmd.Flags().StringVar(&zoneOrganizationID, "org", "", "Organization identifier. All the organizations are exported if not set")
+	exportZoneCmd.Flags().StringVar(&zoneFormat, "format", "zone", "Output format (zone or coredns)")
+	exportZoneCmd.Flags().StringVarP(&zoneFile, "output", "o", "", "Output file. The zone is printed if not set")
+	zoneCmd.AddCommand(exportZoneCmd)
+	rootCmd.AddCommand(zoneCmd)
+}
+
+func exportZone() {
+	format, err := dns.ParseFormat(zoneFormat)
+	if err != nil {
+		log.Fatal().Str("trace", err.DebugReport()).Msg("invalid format")
+	}
+
+	runApplicationManager(zoneConfig, func(organizations orgProvider.Provider, applications appProvider.Provider, appManager application.Manager) {
+		zones := zoneProvider.NewScyllaDNSZoneProvider(zoneConfig.ScyllaDBAddress, zoneConfig.ScyllaDBPort, zoneConfig.KeySpace)
+		defer zones.Disconnect()
+		manager := dns_zone.NewManager(organizations, zones, appManager)
+
+		var zone *dns.Zone
+		if zoneOrganizationID == "" {
+			zone, err = manager.ExportSystemZone()
+		} else {
+			zone, err = manager.ExportOrganizationZone(&grpc_organization_go.OrganizationId{OrganizationId: zoneOrganizationID})
+		}
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot export zone")
+		}
+		content, err := zone.Render(format)
+		if err != nil {
+			log.Fatal().Str("trace", err.DebugReport()).Msg("cannot render zone")
+		}
+
+		if zoneFile == "" {
+			fmt.Fprint(os.Stdout, string(content))
+			return
+		}
+		wErr := ioutil.WriteFile(zoneFile, content, 0644)
+		if wErr != nil {
+			log.Fatal().Err(wErr).Str("file", zoneFile).Msg("cannot write zone")
+		}
+		log.Info().Str("file", zoneFile).Int64("serial", zone.Serial).Msg("zone exported")
+	})
+}
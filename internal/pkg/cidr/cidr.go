@@ -0,0 +1,134 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cidr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/nalej/derrors"
+	"net"
+)
+
+// DefaultPool is the default network from which the IP ranges of the organizations are allocated.
+const DefaultPool = "10.192.0.0/10"
+
+// DefaultBlockPrefixLength is the default prefix length of the allocated IP ranges.
+const DefaultBlockPrefixLength = 24
+
+// maxBlockPrefixLength is the longest prefix that leaves room for at least two hosts.
+const maxBlockPrefixLength = 30
+
+// Pool of IPv4 addresses divided in blocks of the same size.
+type Pool struct {
+	// Network of the pool.
+	Network *net.IPNet
+	// BlockPrefixLength with the prefix length of the blocks.
+	BlockPrefixLength int
+}
+
+// Parse an IPv4 network in CIDR notation. The host bits of the address are cleared.
+func Parse(cidr string) (*net.IPNet, derrors.Error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, derrors.NewInvalidArgumentError("invalid CIDR", err).WithParams(cidr)
+	}
+	if network.IP.To4() == nil {
+		return nil, derrors.NewInvalidArgumentError("only IPv4 networks are supported").WithParams(cidr)
+	}
+	return network, nil
+}
+
+// ParsePool parses the network of a pool and checks that it can be divided in blocks of the given prefix length.
+func ParsePool(cidr string, blockPrefixLength int) (*Pool, derrors.Error) {
+	network, err := Parse(cidr)
+	if err != nil {
+		return nil, err
+	}
+	ones, _ := network.Mask.Size()
+	if blockPrefixLength < ones || blockPrefixLength > maxBlockPrefixLength {
+		return nil, derrors.NewInvalidArgumentError(
+			fmt.Sprintf("block prefix length must be between %d and %d", ones, maxBlockPrefixLength)).WithParams(cidr, blockPrefixLength)
+	}
+	return &Pool{Network: network, BlockPrefixLength: blockPrefixLength}, nil
+}
+
+// String returns the network of the pool in CIDR notation.
+func (p *Pool) String() string {
+	return p.Network.String()
+}
+
+// Blocks returns the number of blocks of the pool.
+func (p *Pool) Blocks() int64 {
+	ones, _ := p.Network.Mask.Size()
+	return int64(1) << uint(p.BlockPrefixLength-ones)
+}
+
+// Block returns the network of a block of the pool.
+func (p *Pool) Block(index int64) *net.IPNet {
+	size := uint32(1) << uint(32-p.BlockPrefixLength)
+	return &net.IPNet{
+		IP:   fromUint32(toUint32(p.Network.IP) + uint32(index)*size),
+		Mask: net.CIDRMask(p.BlockPrefixLength, 32),
+	}
+}
+
+// Overlaps checks if two networks share any address.
+func Overlaps(a *net.IPNet, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// Hosts returns the number of addresses of a network that can be assigned to hosts, excluding the network and the
+// broadcast addresses.
+func Hosts(network *net.IPNet) int64 {
+	ones, bits := network.Mask.Size()
+	size := int64(1) << uint(bits-ones)
+	if size <= 2 {
+		return size
+	}
+	return size - 2
+}
+
+// Host returns the host address of a network with the given index, starting at zero.
+func Host(network *net.IPNet, index int64) net.IP {
+	first := toUint32(network.IP)
+	if Hosts(network) > 2 {
+		first++
+	}
+	return fromUint32(first + uint32(index))
+}
+
+// IsHost checks if an address can be assigned to a host of a network.
+func IsHost(network *net.IPNet, ip net.IP) bool {
+	if !network.Contains(ip) {
+		return false
+	}
+	offset := int64(toUint32(ip) - toUint32(network.IP))
+	if Hosts(network) <= 2 {
+		return true
+	}
+	return offset >= 1 && offset <= Hosts(network)
+}
+
+func toUint32(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func fromUint32(value uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, value)
+	return ip
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cidr
+
+import (
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"net"
+)
+
+var _ = ginkgo.Describe("CIDR", func() {
+
+	mustParse := func(cidr string) *net.IPNet {
+		network, err := Parse(cidr)
+		gomega.Expect(err).To(gomega.Succeed())
+		return network
+	}
+
+	ginkgo.It("should divide a pool in blocks", func() {
+		pool, err := ParsePool("10.0.0.0/16", 24)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(pool.Blocks()).Should(gomega.Equal(int64(256)))
+		gomega.Expect(pool.Block(0).String()).Should(gomega.Equal("10.0.0.0/24"))
+		gomega.Expect(pool.Block(255).String()).Should(gomega.Equal("10.0.255.0/24"))
+	})
+	ginkgo.It("should reject invalid pools", func() {
+		_, err := ParsePool("10.0.0.0/16", 8)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		_, err = ParsePool("10.0.0.0/16", 31)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		_, err = ParsePool("fd00::/64", 96)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		_, err = ParsePool("10.0.0.0", 24)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+	ginkgo.It("should detect overlapping networks", func() {
+		gomega.Expect(Overlaps(mustParse("10.0.0.0/16"), mustParse("10.0.4.0/24"))).Should(gomega.BeTrue())
+		gomega.Expect(Overlaps(mustParse("10.0.4.0/24"), mustParse("10.0.0.0/16"))).Should(gomega.BeTrue())
+		gomega.Expect(Overlaps(mustParse("10.0.4.0/24"), mustParse("10.0.5.0/24"))).Should(gomega.BeFalse())
+	})
+	ginkgo.It("should list the host addresses of a network", func() {
+		network := mustParse("10.0.4.0/24")
+		gomega.Expect(Hosts(network)).Should(gomega.Equal(int64(254)))
+		gomega.Expect(Host(network, 0).String()).Should(gomega.Equal("10.0.4.1"))
+		gomega.Expect(Host(network, 253).String()).Should(gomega.Equal("10.0.4.254"))
+		gomega.Expect(IsHost(network, net.ParseIP("10.0.4.0"))).Should(gomega.BeFalse())
+		gomega.Expect(IsHost(network, net.ParseIP("10.0.4.255"))).Should(gomega.BeFalse())
+		gomega.Expect(IsHost(network, net.ParseIP("10.0.4.7"))).Should(gomega.BeTrue())
+		gomega.Expect(IsHost(network, net.ParseIP("10.0.5.7"))).Should(gomega.BeFalse())
+	})
+})
@@ -0,0 +1,543 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package descriptor
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-application-go"
+	"strings"
+)
+
+// File is the human-editable representation of an application descriptor. Identifiers are not part of the file,
+// they are generated by the system model when the descriptor is imported. Enumerated values are written using the
+// names of the gRPC enums (e.g., SAME_CLUSTER, DOCKER, APP_SERVICES).
+type File struct {
+	// Name of the application.
+	Name string `json:"name" yaml:"name"`
+	// Labels defined by the user.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// ConfigurationOptions defines a key-value map of configuration options.
+	ConfigurationOptions map[string]string `json:"configuration_options,omitempty" yaml:"configuration_options,omitempty"`
+	// EnvironmentVariables defines a key-value map of environment variables passed to all running services.
+	EnvironmentVariables map[string]string `json:"environment_variables,omitempty" yaml:"environment_variables,omitempty"`
+	// Rules that define the connectivity between the elements of an application.
+	Rules []SecurityRuleFile `json:"rules,omitempty" yaml:"rules,omitempty"`
+	// Groups with the Service collocation strategies.
+	Groups []ServiceGroupFile `json:"groups" yaml:"groups"`
+	// Parameters with the parameters of an application
+	Parameters []ParameterFile `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	// InboundNetInterfaces with a list of inbounds
+	InboundNetInterfaces []InboundNetInterfaceFile `json:"inbound_net_interfaces,omitempty" yaml:"inbound_net_interfaces,omitempty"`
+	// OutboundNetInterfaces with a list of outbounds
+	OutboundNetInterfaces []OutboundNetInterfaceFile `json:"outbound_net_interfaces,omitempty" yaml:"outbound_net_interfaces,omitempty"`
+}
+
+// SecurityRuleFile with the file representation of a security rule.
+type SecurityRuleFile struct {
+	Name                   string   `json:"name" yaml:"name"`
+	TargetServiceGroupName string   `json:"target_service_group_name,omitempty" yaml:"target_service_group_name,omitempty"`
+	TargetServiceName      string   `json:"target_service_name,omitempty" yaml:"target_service_name,omitempty"`
+	TargetPort             int32    `json:"target_port,omitempty" yaml:"target_port,omitempty"`
+	Access                 string   `json:"access,omitempty" yaml:"access,omitempty"`
+	AuthServiceGroupName   string   `json:"auth_service_group_name,omitempty" yaml:"auth_service_group_name,omitempty"`
+	AuthServices           []string `json:"auth_services,omitempty" yaml:"auth_services,omitempty"`
+	DeviceGroupNames       []string `json:"device_group_names,omitempty" yaml:"device_group_names,omitempty"`
+	InboundNetInterface    string   `json:"inbound_net_interface,omitempty" yaml:"inbound_net_interface,omitempty"`
+	OutboundNetInterface   string   `json:"outbound_net_interface,omitempty" yaml:"outbound_net_interface,omitempty"`
+}
+
+// ServiceGroupSpecsFile with the file representation of the deployment specs of a group.
+type ServiceGroupSpecsFile struct {
+	Replicas            int32             `json:"replicas,omitempty" yaml:"replicas,omitempty"`
+	MultiClusterReplica bool              `json:"multi_cluster_replica,omitempty" yaml:"multi_cluster_replica,omitempty"`
+	DeploymentSelectors map[string]string `json:"deployment_selectors,omitempty" yaml:"deployment_selectors,omitempty"`
+}
+
+// ServiceGroupFile with the file representation of a service group.
+type ServiceGroupFile struct {
+	Name     string                 `json:"name" yaml:"name"`
+	Policy   string                 `json:"policy,omitempty" yaml:"policy,omitempty"`
+	Specs    *ServiceGroupSpecsFile `json:"specs,omitempty" yaml:"specs,omitempty"`
+	Labels   map[string]string      `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Services []ServiceFile          `json:"services" yaml:"services"`
+}
+
+// CredentialsFile with the credentials required to access an image repository. The passwords are not exported, they
+// are set when the descriptor is imported from a separate credentials file.
+type CredentialsFile struct {
+	Username         string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password         string `json:"password,omitempty" yaml:"password,omitempty"`
+	Email            string `json:"email,omitempty" yaml:"email,omitempty"`
+	DockerRepository string `json:"docker_repository,omitempty" yaml:"docker_repository,omitempty"`
+}
+
+// Credentials with the credentials of the image repositories indexed by service name. They are kept in their own file
+// so the descriptor files can be committed without secrets.
+type Credentials map[string]CredentialsFile
+
+// DeploySpecsFile with the resource specs of a service.
+type DeploySpecsFile struct {
+	Cpu      int64 `json:"cpu,omitempty" yaml:"cpu,omitempty"`
+	Memory   int64 `json:"memory,omitempty" yaml:"memory,omitempty"`
+	Replicas int32 `json:"replicas,omitempty" yaml:"replicas,omitempty"`
+}
+
+// StorageFile with the storage requirements of a service.
+type StorageFile struct {
+	Size      int64  `json:"size,omitempty" yaml:"size,omitempty"`
+	MountPath string `json:"mount_path,omitempty" yaml:"mount_path,omitempty"`
+	Type      string `json:"type,omitempty" yaml:"type,omitempty"`
+}
+
+// EndpointFile with the file representation of a port endpoint.
+type EndpointFile struct {
+	Type    string            `json:"type,omitempty" yaml:"type,omitempty"`
+	Path    string            `json:"path,omitempty" yaml:"path,omitempty"`
+	Options map[string]string `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// PortFile with the file representation of an exposed port.
+type PortFile struct {
+	Name         string         `json:"name,omitempty" yaml:"name,omitempty"`
+	InternalPort int32          `json:"internal_port,omitempty" yaml:"internal_port,omitempty"`
+	ExposedPort  int32          `json:"exposed_port,omitempty" yaml:"exposed_port,omitempty"`
+	Endpoints    []EndpointFile `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
+}
+
+// ConfigFileFile with the file representation of a configuration file. The content is kept as plain text so
+// it can be edited by hand.
+type ConfigFileFile struct {
+	Name      string `json:"name" yaml:"name"`
+	Content   string `json:"content,omitempty" yaml:"content,omitempty"`
+	MountPath string `json:"mount_path,omitempty" yaml:"mount_path,omitempty"`
+}
+
+// ServiceFile with the file representation of a service.
+type ServiceFile struct {
+	Name                 string            `json:"name" yaml:"name"`
+	Type                 string            `json:"type,omitempty" yaml:"type,omitempty"`
+	Image                string            `json:"image" yaml:"image"`
+	Credentials          *CredentialsFile  `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+	Specs                *DeploySpecsFile  `json:"specs,omitempty" yaml:"specs,omitempty"`
+	Storage              []StorageFile     `json:"storage,omitempty" yaml:"storage,omitempty"`
+	ExposedPorts         []PortFile        `json:"exposed_ports,omitempty" yaml:"exposed_ports,omitempty"`
+	EnvironmentVariables map[string]string `json:"environment_variables,omitempty" yaml:"environment_variables,omitempty"`
+	Configs              []ConfigFileFile  `json:"configs,omitempty" yaml:"configs,omitempty"`
+	Labels               map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	DeployAfter          []string          `json:"deploy_after,omitempty" yaml:"deploy_after,omitempty"`
+	RunArguments         []string          `json:"run_arguments,omitempty" yaml:"run_arguments,omitempty"`
+}
+
+// ParameterFile with the file representation of a descriptor parameter.
+type ParameterFile struct {
+	Name         string   `json:"name" yaml:"name"`
+	Description  string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Path         string   `json:"path" yaml:"path"`
+	Type         string   `json:"type,omitempty" yaml:"type,omitempty"`
+	DefaultValue string   `json:"default_value,omitempty" yaml:"default_value,omitempty"`
+	Category     string   `json:"category,omitempty" yaml:"category,omitempty"`
+	EnumValues   []string `json:"enum_values,omitempty" yaml:"enum_values,omitempty"`
+	Required     bool     `json:"required,omitempty" yaml:"required,omitempty"`
+}
+
+// InboundNetInterfaceFile with the name of an inbound interface.
+type InboundNetInterfaceFile struct {
+	Name string `json:"name" yaml:"name"`
+}
+
+// OutboundNetInterfaceFile with the name of an outbound interface.
+type OutboundNetInterfaceFile struct {
+	Name     string `json:"name" yaml:"name"`
+	Required bool   `json:"required,omitempty" yaml:"required,omitempty"`
+}
+
+// parseEnum converts the textual name of an enum into its value. Empty values are translated into the default
+// value of the enum.
+func parseEnum(field string, value string, values map[string]int32) (int32, derrors.Error) {
+	if value == "" {
+		return 0, nil
+	}
+	converted, exists := values[strings.ToUpper(value)]
+	if !exists {
+		return 0, derrors.NewInvalidArgumentError("invalid value").WithParams(field, value)
+	}
+	return converted, nil
+}
+
+// ToAddRequest builds the request required to add the descriptor to an organization.
+func (f *File) ToAddRequest(organizationID string, requestID string) (*grpc_application_go.AddAppDescriptorRequest, derrors.Error) {
+	rules := make([]*grpc_application_go.SecurityRule, 0, len(f.Rules))
+	for _, r := range f.Rules {
+		access, err := parseEnum("access", r.Access, grpc_application_go.PortAccess_value)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, &grpc_application_go.SecurityRule{
+			Name:                   r.Name,
+			TargetServiceGroupName: r.TargetServiceGroupName,
+			TargetServiceName:      r.TargetServiceName,
+			TargetPort:             r.TargetPort,
+			Access:                 grpc_application_go.PortAccess(access),
+			AuthServiceGroupName:   r.AuthServiceGroupName,
+			AuthServices:           r.AuthServices,
+			DeviceGroupNames:       r.DeviceGroupNames,
+			InboundNetInterface:    r.InboundNetInterface,
+			OutboundNetInterface:   r.OutboundNetInterface,
+		})
+	}
+
+	groups := make([]*grpc_application_go.ServiceGroup, 0, len(f.Groups))
+	for _, g := range f.Groups {
+		group, err := g.toGRPC()
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+
+	parameters := make([]*grpc_application_go.AppParameter, 0, len(f.Parameters))
+	for _, p := range f.Parameters {
+		paramType, err := parseEnum("parameter type", p.Type, grpc_application_go.ParamDataType_value)
+		if err != nil {
+			return nil, err
+		}
+		category, err := parseEnum("parameter category", p.Category, grpc_application_go.ParamCategory_value)
+		if err != nil {
+			return nil, err
+		}
+		parameters = append(parameters, &grpc_application_go.AppParameter{
+			Name:         p.Name,
+			Description:  p.Description,
+			Path:         p.Path,
+			Type:         grpc_application_go.ParamDataType(paramType),
+			DefaultValue: p.DefaultValue,
+			Category:     grpc_application_go.ParamCategory(category),
+			EnumValues:   p.EnumValues,
+			Required:     p.Required,
+		})
+	}
+
+	inbounds := make([]*grpc_application_go.InboundNetworkInterface, 0, len(f.InboundNetInterfaces))
+	for _, inbound := range f.InboundNetInterfaces {
+		inbounds = append(inbounds, &grpc_application_go.InboundNetworkInterface{Name: inbound.Name})
+	}
+	outbounds := make([]*grpc_application_go.OutboundNetworkInterface, 0, len(f.OutboundNetInterfaces))
+	for _, outbound := range f.OutboundNetInterfaces {
+		outbounds = append(outbounds, &grpc_application_go.OutboundNetworkInterface{Name: outbound.Name, Required: outbound.Required})
+	}
+
+	return &grpc_application_go.AddAppDescriptorRequest{
+		RequestId:             requestID,
+		OrganizationId:        organizationID,
+		Name:                  f.Name,
+		ConfigurationOptions:  f.ConfigurationOptions,
+		EnvironmentVariables:  f.EnvironmentVariables,
+		Labels:                f.Labels,
+		Rules:                 rules,
+		Groups:                groups,
+		Parameters:            parameters,
+		InboundNetInterfaces:  inbounds,
+		OutboundNetInterfaces: outbounds,
+	}, nil
+}
+
+func (g *ServiceGroupFile) toGRPC() (*grpc_application_go.ServiceGroup, derrors.Error) {
+	policy, err := parseEnum("policy", g.Policy, grpc_application_go.CollocationPolicy_value)
+	if err != nil {
+		return nil, err
+	}
+	services := make([]*grpc_application_go.Service, 0, len(g.Services))
+	for _, s := range g.Services {
+		service, err := s.toGRPC()
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, service)
+	}
+	var specs *grpc_application_go.ServiceGroupDeploymentSpecs
+	if g.Specs != nil {
+		specs = &grpc_application_go.ServiceGroupDeploymentSpecs{
+			Replicas:            g.Specs.Replicas,
+			MultiClusterReplica: g.Specs.MultiClusterReplica,
+			DeploymentSelectors: g.Specs.DeploymentSelectors,
+		}
+	}
+	return &grpc_application_go.ServiceGroup{
+		Name:     g.Name,
+		Services: services,
+		Policy:   grpc_application_go.CollocationPolicy(policy),
+		Specs:    specs,
+		Labels:   g.Labels,
+	}, nil
+}
+
+func (s *ServiceFile) toGRPC() (*grpc_application_go.Service, derrors.Error) {
+	serviceType, err := parseEnum("service type", s.Type, grpc_application_go.ServiceType_value)
+	if err != nil {
+		return nil, err
+	}
+
+	var credentials *grpc_application_go.ImageCredentials
+	if s.Credentials != nil {
+		credentials = &grpc_application_go.ImageCredentials{
+			Username:         s.Credentials.Username,
+			Password:         s.Credentials.Password,
+			Email:            s.Credentials.Email,
+			DockerRepository: s.Credentials.DockerRepository,
+		}
+	}
+	var specs *grpc_application_go.DeploySpecs
+	if s.Specs != nil {
+		specs = &grpc_application_go.DeploySpecs{
+			Cpu:      s.Specs.Cpu,
+			Memory:   s.Specs.Memory,
+			Replicas: s.Specs.Replicas,
+		}
+	}
+
+	storage := make([]*grpc_application_go.Storage, 0, len(s.Storage))
+	for _, st := range s.Storage {
+		storageType, err := parseEnum("storage type", st.Type, grpc_application_go.StorageType_value)
+		if err != nil {
+			return nil, err
+		}
+		storage = append(storage, &grpc_application_go.Storage{
+			Size:      st.Size,
+			MountPath: st.MountPath,
+			Type:      grpc_application_go.StorageType(storageType),
+		})
+	}
+
+	ports := make([]*grpc_application_go.Port, 0, len(s.ExposedPorts))
+	for _, p := range s.ExposedPorts {
+		endpoints := make([]*grpc_application_go.Endpoint, 0, len(p.Endpoints))
+		for _, e := range p.Endpoints {
+			endpointType, err := parseEnum("endpoint type", e.Type, grpc_application_go.EndpointType_value)
+			if err != nil {
+				return nil, err
+			}
+			endpoints = append(endpoints, &grpc_application_go.Endpoint{
+				Type:    grpc_application_go.EndpointType(endpointType),
+				Path:    e.Path,
+				Options: e.Options,
+			})
+		}
+		ports = append(ports, &grpc_application_go.Port{
+			Name:         p.Name,
+			InternalPort: p.InternalPort,
+			ExposedPort:  p.ExposedPort,
+			Endpoints:    endpoints,
+		})
+	}
+
+	configs := make([]*grpc_application_go.ConfigFile, 0, len(s.Configs))
+	for _, c := range s.Configs {
+		configs = append(configs, &grpc_application_go.ConfigFile{
+			Name:      c.Name,
+			Content:   []byte(c.Content),
+			MountPath: c.MountPath,
+		})
+	}
+
+	return &grpc_application_go.Service{
+		Name:                 s.Name,
+		Type:                 grpc_application_go.ServiceType(serviceType),
+		Image:                s.Image,
+		Credentials:          credentials,
+		Specs:                specs,
+		Storage:              storage,
+		ExposedPorts:         ports,
+		EnvironmentVariables: s.EnvironmentVariables,
+		Configs:              configs,
+		Labels:               s.Labels,
+		DeployAfter:          s.DeployAfter,
+		RunArguments:         s.RunArguments,
+	}, nil
+}
+
+// SetCredentials sets the credentials of the services of the descriptor. The fields set in the credentials replace the
+// ones of the descriptor. Credentials of services that are not part of the descriptor are rejected.
+func (f *File) SetCredentials(credentials Credentials) derrors.Error {
+	used := make(map[string]bool, len(credentials))
+	for i := range f.Groups {
+		for j := range f.Groups[i].Services {
+			service := &f.Groups[i].Services[j]
+			toSet, exists := credentials[service.Name]
+			if !exists {
+				continue
+			}
+			used[service.Name] = true
+			if service.Credentials == nil {
+				service.Credentials = &CredentialsFile{}
+			}
+			if toSet.Username != "" {
+				service.Credentials.Username = toSet.Username
+			}
+			if toSet.Password != "" {
+				service.Credentials.Password = toSet.Password
+			}
+			if toSet.Email != "" {
+				service.Credentials.Email = toSet.Email
+			}
+			if toSet.DockerRepository != "" {
+				service.Credentials.DockerRepository = toSet.DockerRepository
+			}
+		}
+	}
+	for name := range credentials {
+		if !used[name] {
+			return derrors.NewInvalidArgumentError("credentials of unknown service").WithParams(name)
+		}
+	}
+	return nil
+}
+
+// NewFileFromGRPC builds the file representation of a descriptor retrieved from the system model. The passwords of the
+// image repositories are not exported.
+func NewFileFromGRPC(descriptor *grpc_application_go.AppDescriptor) *File {
+	rules := make([]SecurityRuleFile, 0, len(descriptor.Rules))
+	for _, r := range descriptor.Rules {
+		rules = append(rules, SecurityRuleFile{
+			Name:                   r.Name,
+			TargetServiceGroupName: r.TargetServiceGroupName,
+			TargetServiceName:      r.TargetServiceName,
+			TargetPort:             r.TargetPort,
+			Access:                 r.Access.String(),
+			AuthServiceGroupName:   r.AuthServiceGroupName,
+			AuthServices:           r.AuthServices,
+			DeviceGroupNames:       r.DeviceGroupNames,
+			InboundNetInterface:    r.InboundNetInterface,
+			OutboundNetInterface:   r.OutboundNetInterface,
+		})
+	}
+
+	groups := make([]ServiceGroupFile, 0, len(descriptor.Groups))
+	for _, g := range descriptor.Groups {
+		groups = append(groups, *newServiceGroupFileFromGRPC(g))
+	}
+
+	parameters := make([]ParameterFile, 0, len(descriptor.Parameters))
+	for _, p := range descriptor.Parameters {
+		parameters = append(parameters, ParameterFile{
+			Name:         p.Name,
+			Description:  p.Description,
+			Path:         p.Path,
+			Type:         p.Type.String(),
+			DefaultValue: p.DefaultValue,
+			Category:     p.Category.String(),
+			EnumValues:   p.EnumValues,
+			Required:     p.Required,
+		})
+	}
+
+	inbounds := make([]InboundNetInterfaceFile, 0, len(descriptor.InboundNetInterfaces))
+	for _, inbound := range descriptor.InboundNetInterfaces {
+		inbounds = append(inbounds, InboundNetInterfaceFile{Name: inbound.Name})
+	}
+	outbounds := make([]OutboundNetInterfaceFile, 0, len(descriptor.OutboundNetInterfaces))
+	for _, outbound := range descriptor.OutboundNetInterfaces {
+		outbounds = append(outbounds, OutboundNetInterfaceFile{Name: outbound.Name, Required: outbound.Required})
+	}
+
+	return &File{
+		Name:                  descriptor.Name,
+		Labels:                descriptor.Labels,
+		ConfigurationOptions:  descriptor.ConfigurationOptions,
+		EnvironmentVariables:  descriptor.EnvironmentVariables,
+		Rules:                 rules,
+		Groups:                groups,
+		Parameters:            parameters,
+		InboundNetInterfaces:  inbounds,
+		OutboundNetInterfaces: outbounds,
+	}
+}
+
+func newServiceGroupFileFromGRPC(group *grpc_application_go.ServiceGroup) *ServiceGroupFile {
+	services := make([]ServiceFile, 0, len(group.Services))
+	for _, s := range group.Services {
+		services = append(services, *newServiceFileFromGRPC(s))
+	}
+	var specs *ServiceGroupSpecsFile
+	if group.Specs != nil {
+		specs = &ServiceGroupSpecsFile{
+			Replicas:            group.Specs.Replicas,
+			MultiClusterReplica: group.Specs.MultiClusterReplica,
+			DeploymentSelectors: group.Specs.DeploymentSelectors,
+		}
+	}
+	return &ServiceGroupFile{
+		Name:     group.Name,
+		Policy:   group.Policy.String(),
+		Specs:    specs,
+		Labels:   group.Labels,
+		Services: services,
+	}
+}
+
+func newServiceFileFromGRPC(service *grpc_application_go.Service) *ServiceFile {
+	var credentials *CredentialsFile
+	if service.Credentials != nil {
+		credentials = &CredentialsFile{
+			Username:         service.Credentials.Username,
+			Email:            service.Credentials.Email,
+			DockerRepository: service.Credentials.DockerRepository,
+		}
+	}
+	var specs *DeploySpecsFile
+	if service.Specs != nil {
+		specs = &DeploySpecsFile{
+			Cpu:      service.Specs.Cpu,
+			Memory:   service.Specs.Memory,
+			Replicas: service.Specs.Replicas,
+		}
+	}
+	storage := make([]StorageFile, 0, len(service.Storage))
+	for _, s := range service.Storage {
+		storage = append(storage, StorageFile{Size: s.Size, MountPath: s.MountPath, Type: s.Type.String()})
+	}
+	ports := make([]PortFile, 0, len(service.ExposedPorts))
+	for _, p := range service.ExposedPorts {
+		endpoints := make([]EndpointFile, 0, len(p.Endpoints))
+		for _, e := range p.Endpoints {
+			endpoints = append(endpoints, EndpointFile{Type: e.Type.String(), Path: e.Path, Options: e.Options})
+		}
+		ports = append(ports, PortFile{
+			Name:         p.Name,
+			InternalPort: p.InternalPort,
+			ExposedPort:  p.ExposedPort,
+			Endpoints:    endpoints,
+		})
+	}
+	configs := make([]ConfigFileFile, 0, len(service.Configs))
+	for _, c := range service.Configs {
+		configs = append(configs, ConfigFileFile{Name: c.Name, Content: string(c.Content), MountPath: c.MountPath})
+	}
+	return &ServiceFile{
+		Name:                 service.Name,
+		Type:                 service.Type.String(),
+		Image:                service.Image,
+		Credentials:          credentials,
+		Specs:                specs,
+		Storage:              storage,
+		ExposedPorts:         ports,
+		EnvironmentVariables: service.EnvironmentVariables,
+		Configs:              configs,
+		Labels:               service.Labels,
+		DeployAfter:          service.DeployAfter,
+		RunArguments:         service.RunArguments,
+	}
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package descriptor
+
+import (
+	"github.com/nalej/grpc-application-go"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+const sampleDescriptor = `
+name: sample-app
+labels:
+  app: sample
+rules:
+- name: allow-wordpress
+  target_service_group_name: g1
+  target_service_name: mysql
+  target_port: 3306
+  access: APP_SERVICES
+  auth_service_group_name: g1
+  auth_services:
+  - wordpress
+groups:
+- name: g1
+  policy: same_cluster
+  specs:
+    replicas: 1
+  services:
+  - name: mysql
+    type: DOCKER
+    image: mysql:5.6
+    specs:
+      replicas: 1
+    exposed_ports:
+    - name: mysqlport
+      internal_port: 3306
+      exposed_port: 3306
+    configs:
+    - name: my.cnf
+      mount_path: /etc/mysql
+      content: |
+        [mysqld]
+        bind-address = 0.0.0.0
+  - name: wordpress
+    type: DOCKER
+    image: wordpress:5.0.0
+    deploy_after:
+    - mysql
+    exposed_ports:
+    - name: wordpressport
+      internal_port: 80
+      exposed_port: 80
+      endpoints:
+      - type: WEB
+        path: /
+`
+
+var _ = ginkgo.Describe("Descriptor files", func() {
+
+	ginkgo.It("should parse a YAML descriptor into a valid request", func() {
+		file, err := Unmarshal([]byte(sampleDescriptor), YAMLFormat)
+		gomega.Expect(err).To(gomega.Succeed())
+		request, err := file.ToAddRequest("org", "request")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(request.OrganizationId).Should(gomega.Equal("org"))
+		gomega.Expect(request.Groups[0].Policy).Should(gomega.Equal(grpc_application_go.CollocationPolicy_SAME_CLUSTER))
+		gomega.Expect(request.Rules[0].Access).Should(gomega.Equal(grpc_application_go.PortAccess_APP_SERVICES))
+		gomega.Expect(string(request.Groups[0].Services[0].Configs[0].Content)).Should(gomega.ContainSubstring("bind-address"))
+		gomega.Expect(ValidateAddRequest(request)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should reject unknown enum values", func() {
+		file, err := Unmarshal([]byte(sampleDescriptor), YAMLFormat)
+		gomega.Expect(err).To(gomega.Succeed())
+		file.Groups[0].Policy = "SOMEWHERE"
+		_, err = file.ToAddRequest("org", "request")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should reject unknown fields", func() {
+		_, err := Unmarshal([]byte("name: app\nunknown: field\n"), YAMLFormat)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		_, err = Unmarshal([]byte(`{"name": "app", "unknown": "field"}`), JSONFormat)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should not export the passwords of the image repositories", func() {
+		exported := NewFileFromGRPC(&grpc_application_go.AppDescriptor{
+			Name: "app",
+			Groups: []*grpc_application_go.ServiceGroup{{Name: "g1", Services: []*grpc_application_go.Service{{
+				Name:        "private",
+				Image:       "registry/private:1.0",
+				Credentials: &grpc_application_go.ImageCredentials{Username: "user", Password: "secret"},
+			}}}},
+		})
+		content, err := Marshal(exported, YAMLFormat)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(string(content)).ShouldNot(gomega.ContainSubstring("secret"))
+		gomega.Expect(exported.Groups[0].Services[0].Credentials.Username).Should(gomega.Equal("user"))
+	})
+
+	ginkgo.It("should set the credentials of the services", func() {
+		file, err := Unmarshal([]byte(sampleDescriptor), YAMLFormat)
+		gomega.Expect(err).To(gomega.Succeed())
+		err = file.SetCredentials(Credentials{"mysql": {Username: "user", Password: "secret"}})
+		gomega.Expect(err).To(gomega.Succeed())
+		request, err := file.ToAddRequest("org", "request")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(request.Groups[0].Services[0].Credentials.Password).Should(gomega.Equal("secret"))
+		gomega.Expect(request.Groups[0].Services[1].Credentials).Should(gomega.BeNil())
+
+		err = file.SetCredentials(Credentials{"unknown": {Password: "secret"}})
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should fail validation on descriptors without groups", func() {
+		file := &File{Name: "empty"}
+		request, err := file.ToAddRequest("org", "request")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(ValidateAddRequest(request)).NotTo(gomega.Succeed())
+	})
+
+	roundTrip := func(format Format) {
+		file, err := Unmarshal([]byte(sampleDescriptor), YAMLFormat)
+		gomega.Expect(err).To(gomega.Succeed())
+		request, err := file.ToAddRequest("org", "request")
+		gomega.Expect(err).To(gomega.Succeed())
+		retrieved := &grpc_application_go.AppDescriptor{
+			OrganizationId:  request.OrganizationId,
+			AppDescriptorId: "descriptor",
+			Name:            request.Name,
+			Labels:          request.Labels,
+			Rules:           request.Rules,
+			Groups:          request.Groups,
+		}
+
+		exported := NewFileFromGRPC(retrieved)
+		content, err := Marshal(exported, format)
+		gomega.Expect(err).To(gomega.Succeed())
+		imported, err := Unmarshal(content, format)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(imported.Name).Should(gomega.Equal(file.Name))
+		gomega.Expect(imported.Groups[0].Policy).Should(gomega.Equal(grpc_application_go.CollocationPolicy_SAME_CLUSTER.String()))
+		gomega.Expect(imported.Groups[0].Services[0].Configs[0].Content).Should(gomega.Equal(file.Groups[0].Services[0].Configs[0].Content))
+		gomega.Expect(imported.Groups[0].Services[1].DeployAfter).Should(gomega.Equal([]string{"mysql"}))
+	}
+
+	ginkgo.It("should export and import YAML descriptors", func() {
+		roundTrip(YAMLFormat)
+	})
+
+	ginkgo.It("should export and import JSON descriptors", func() {
+		roundTrip(JSONFormat)
+	})
+
+	ginkgo.It("should infer the format from the file extension", func() {
+		gomega.Expect(FormatFromPath("app.json")).Should(gomega.Equal(JSONFormat))
+		gomega.Expect(FormatFromPath("app.yml")).Should(gomega.Equal(YAMLFormat))
+		gomega.Expect(FormatFromPath("app.yaml")).Should(gomega.Equal(YAMLFormat))
+	})
+})
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package descriptor
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/nalej/derrors"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Format of a descriptor file.
+type Format string
+
+const (
+	// YAMLFormat for .yaml and .yml files.
+	YAMLFormat Format = "yaml"
+	// JSONFormat for .json files.
+	JSONFormat Format = "json"
+)
+
+// ParseFormat validates the name of a format.
+func ParseFormat(format string) (Format, derrors.Error) {
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		return YAMLFormat, nil
+	case "json":
+		return JSONFormat, nil
+	}
+	return "", derrors.NewInvalidArgumentError("unsupported descriptor format").WithParams(format)
+}
+
+// FormatFromPath infers the format of a file from its extension. Files without a known extension are
+// considered YAML.
+func FormatFromPath(path string) Format {
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return JSONFormat
+	}
+	return YAMLFormat
+}
+
+// unmarshalStrict parses the content of a file rejecting the fields that are not known, so typos are not dropped.
+func unmarshalStrict(content []byte, format Format, target interface{}) derrors.Error {
+	var err error
+	switch format {
+	case JSONFormat:
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(target)
+	case YAMLFormat:
+		err = yaml.UnmarshalStrict(content, target)
+	default:
+		return derrors.NewInvalidArgumentError("unsupported descriptor format").WithParams(format)
+	}
+	if err != nil {
+		return derrors.NewInvalidArgumentError("cannot parse descriptor", err)
+	}
+	return nil
+}
+
+// Unmarshal parses the content of a descriptor file.
+func Unmarshal(content []byte, format Format) (*File, derrors.Error) {
+	file := &File{}
+	if err := unmarshalStrict(content, format, file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Marshal serializes a descriptor file.
+func Marshal(file *File, format Format) ([]byte, derrors.Error) {
+	var content []byte
+	var err error
+	switch format {
+	case JSONFormat:
+		content, err = json.MarshalIndent(file, "", "  ")
+	case YAMLFormat:
+		content, err = yaml.Marshal(file)
+	default:
+		return nil, derrors.NewInvalidArgumentError("unsupported descriptor format").WithParams(format)
+	}
+	if err != nil {
+		return nil, derrors.NewInternalError("cannot serialize descriptor", err)
+	}
+	return content, nil
+}
+
+// ReadFile reads a descriptor from disk. If no format is specified, it is inferred from the file extension.
+func ReadFile(path string, format Format) (*File, derrors.Error) {
+	if format == "" {
+		format = FormatFromPath(path)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, derrors.NewInvalidArgumentError("cannot read descriptor file", err).WithParams(path)
+	}
+	return Unmarshal(content, format)
+}
+
+// ReadCredentialsFile reads the credentials of the image repositories from disk. If no format is specified, it is
+// inferred from the file extension.
+func ReadCredentialsFile(path string, format Format) (Credentials, derrors.Error) {
+	if format == "" {
+		format = FormatFromPath(path)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, derrors.NewInvalidArgumentError("cannot read credentials file", err).WithParams(path)
+	}
+	credentials := make(Credentials, 0)
+	if err := unmarshalStrict(content, format, &credentials); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// WriteFile writes a descriptor to disk. If no format is specified, it is inferred from the file extension.
+func WriteFile(path string, file *File, format Format) derrors.Error {
+	if format == "" {
+		format = FormatFromPath(path)
+	}
+	content, dErr := Marshal(file, format)
+	if dErr != nil {
+		return dErr
+	}
+	err := ioutil.WriteFile(path, content, 0644)
+	if err != nil {
+		return derrors.NewInternalError("cannot write descriptor file", err).WithParams(path)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package descriptor
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-application-go"
+	"github.com/nalej/system-model/internal/pkg/entities"
+)
+
+// ValidateAddRequest checks that a request built from a descriptor file would be accepted by the system model.
+// The same checks applied by the application handler and manager are performed so that errors are reported
+// before contacting the server.
+func ValidateAddRequest(addRequest *grpc_application_go.AddAppDescriptorRequest) derrors.Error {
+	err := entities.ValidAddAppDescriptorRequest(addRequest)
+	if err != nil {
+		return err
+	}
+	descriptor, err := entities.NewAppDescriptorFromGRPC(addRequest)
+	if err != nil {
+		return err
+	}
+	return entities.ValidateDescriptor(*descriptor)
+}
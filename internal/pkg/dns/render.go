@@ -0,0 +1,162 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/nalej/derrors"
+	"strings"
+)
+
+// Format of an exported zone.
+type Format string
+
+const (
+	// ZoneFileFormat renders the zone as an RFC 1035 master file.
+	ZoneFileFormat Format = "zone"
+	// CoreDNSFormat renders the zone as the JSON records used by the CoreDNS etcd plugin.
+	CoreDNSFormat Format = "coredns"
+)
+
+// ParseFormat validates the name of a format.
+func ParseFormat(format string) (Format, derrors.Error) {
+	switch strings.ToLower(format) {
+	case "zone", "rfc1035", "":
+		return ZoneFileFormat, nil
+	case "coredns", "json":
+		return CoreDNSFormat, nil
+	}
+	return "", derrors.NewInvalidArgumentError("unsupported zone format").WithParams(format)
+}
+
+// SOA timers in seconds.
+const (
+	soaRefresh = 3600
+	soaRetry   = 600
+	soaExpire  = 604800
+)
+
+// line renders a record in master file format.
+func (r *Record) line() string {
+	if r.Type == SRV {
+		return fmt.Sprintf("%s\t%d\tIN\t%s\t10 10 %d %s", r.Name, r.TTL, r.Type, r.Port, r.Target)
+	}
+	return fmt.Sprintf("%s\t%d\tIN\t%s\t%s", r.Name, r.TTL, r.Type, r.Target)
+}
+
+// ZoneFile renders the zone as an RFC 1035 master file.
+func (z *Zone) ZoneFile() []byte {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "$ORIGIN %s\n", z.Origin)
+	fmt.Fprintf(&buffer, "$TTL %d\n", z.TTL)
+	fmt.Fprintf(&buffer, "@\tIN\tSOA\tns.%s hostmaster.%s %d %d %d %d %d\n",
+		z.Origin, z.Origin, z.Serial, soaRefresh, soaRetry, soaExpire, z.TTL)
+	fmt.Fprintf(&buffer, "@\tIN\tNS\tns.%s\n", z.Origin)
+	for _, record := range z.Records {
+		buffer.WriteString(record.line())
+		buffer.WriteString("\n")
+	}
+	return buffer.Bytes()
+}
+
+// CoreDNSRecord with a record stored under a SkyDNS path, as read by the CoreDNS etcd plugin.
+type CoreDNSRecord struct {
+	// Key with the path of the record.
+	Key string `json:"key"`
+	// Host with the IP address or the host name.
+	Host string `json:"host"`
+	// Port of the endpoint.
+	Port int32 `json:"port,omitempty"`
+	// TTL in seconds.
+	TTL int `json:"ttl"`
+}
+
+// CoreDNSZone with the records of a zone and its serial.
+type CoreDNSZone struct {
+	Origin  string          `json:"origin"`
+	Serial  int64           `json:"serial"`
+	Records []CoreDNSRecord `json:"records"`
+}
+
+// skyDNSPath returns the path of an absolute name (a.b.c. -> /skydns/c/b/a).
+func skyDNSPath(name string) string {
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return "/skydns/" + strings.Join(labels, "/")
+}
+
+// srvTarget returns the name described by a SRV record name, removing its service and protocol labels
+// (_service._proto.name -> name).
+func srvTarget(name string) string {
+	labels := strings.SplitN(name, ".", 3)
+	if len(labels) < 3 {
+		return name
+	}
+	return labels[2]
+}
+
+// CoreDNS converts the zone into the records used by the CoreDNS etcd plugin. Each target of a name is stored under
+// its own key so that all of them are returned. SRV information is carried by the port of the records.
+func (z *Zone) CoreDNS() *CoreDNSZone {
+	records := make([]CoreDNSRecord, 0)
+	ports := make(map[string]int32, 0)
+	for _, record := range z.Records {
+		if record.Type == SRV {
+			name := srvTarget(record.Name)
+			if _, exists := ports[name]; !exists {
+				ports[name] = record.Port
+			}
+		}
+	}
+	count := make(map[string]int, 0)
+	for _, record := range z.Records {
+		if record.Type == SRV {
+			continue
+		}
+		count[record.Name]++
+		records = append(records, CoreDNSRecord{
+			Key:  fmt.Sprintf("%s/x%d", skyDNSPath(record.Name+"."+z.Origin), count[record.Name]),
+			Host: strings.TrimSuffix(record.Target, "."),
+			Port: ports[record.Name],
+			TTL:  record.TTL,
+		})
+	}
+	return &CoreDNSZone{
+		Origin:  z.Origin,
+		Serial:  z.Serial,
+		Records: records,
+	}
+}
+
+// Render the zone in a given format.
+func (z *Zone) Render(format Format) ([]byte, derrors.Error) {
+	switch format {
+	case ZoneFileFormat:
+		return z.ZoneFile(), nil
+	case CoreDNSFormat:
+		content, err := json.MarshalIndent(z.CoreDNS(), "", "  ")
+		if err != nil {
+			return nil, derrors.NewInternalError("cannot serialize zone", err)
+		}
+		return content, nil
+	}
+	return nil, derrors.NewInvalidArgumentError("unsupported zone format").WithParams(format)
+}
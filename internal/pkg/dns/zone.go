@@ -0,0 +1,194 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dns
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"net"
+	"sort"
+	"strings"
+)
+
+// DefaultTTL is the time to live in seconds of the records of an exported zone.
+const DefaultTTL = 300
+
+// RecordType with the DNS record types produced by the exporter.
+type RecordType string
+
+const (
+	// A record for endpoints exposed through an IP address.
+	A RecordType = "A"
+	// CNAME record for endpoints exposed through a single host name.
+	CNAME RecordType = "CNAME"
+	// SRV record with the port of an endpoint.
+	SRV RecordType = "SRV"
+)
+
+// Record with a DNS resource record. Names are relative to the origin of the zone.
+type Record struct {
+	// Name of the record relative to the origin.
+	Name string `json:"name"`
+	// Type of the record.
+	Type RecordType `json:"type"`
+	// TTL in seconds.
+	TTL int `json:"ttl"`
+	// Target with the IP address (A) or the absolute host name (CNAME, SRV) of the record.
+	Target string `json:"target"`
+	// Port of SRV records.
+	Port int32 `json:"port,omitempty"`
+}
+
+// Zone with the DNS records of the application endpoints.
+type Zone struct {
+	// Origin of the zone (absolute name).
+	Origin string `json:"origin"`
+	// Serial of the zone.
+	Serial int64 `json:"serial"`
+	// TTL with the default time to live of the zone.
+	TTL int `json:"ttl"`
+	// Records sorted by name, type and target.
+	Records []Record `json:"records"`
+}
+
+// Origin returns the absolute name of the zone holding the public endpoints of a public host domain.
+func Origin(publicHostDomain string) string {
+	return fmt.Sprintf("%s.%s.", entities.PublicEndpointLabel, strings.Trim(publicHostDomain, "."))
+}
+
+// absolute returns the absolute form of a host name.
+func absolute(host string) string {
+	if strings.HasSuffix(host, ".") {
+		return host
+	}
+	return host + "."
+}
+
+// endpointIP returns the IP address of an endpoint whose FQDN is an IP or an IP:port.
+func endpointIP(fqdn string) net.IP {
+	host := fqdn
+	if h, _, err := net.SplitHostPort(fqdn); err == nil {
+		host = h
+	}
+	return net.ParseIP(host)
+}
+
+// srvServices contains the service labels of the SRV records of each protocol. Raw TCP and UDP endpoints use a
+// generic label.
+var srvServices = map[entities.AppEndpointProtocol]string{
+	entities.HTTP:      "http",
+	entities.HTTPS:     "https",
+	entities.TCP:       "endpoint",
+	entities.UDP:       "endpoint",
+	entities.GRPC:      "grpc",
+	entities.WebSocket: "ws",
+}
+
+// srvName returns the name of the SRV record of an endpoint.
+func srvName(name string, protocol entities.AppEndpointProtocol) string {
+	service, exists := srvServices[protocol]
+	if !exists {
+		service = srvServices[entities.HTTP]
+	}
+	return fmt.Sprintf("_%s._%s.%s", service, protocol.Transport(), name)
+}
+
+// NewZone builds the zone of a set of endpoints. Endpoints sharing a global FQDN are aggregated: IP targets produce
+// A records, a single host name target produces a CNAME record and every endpoint with a port produces a SRV record.
+// The serial is not set.
+func NewZone(publicHostDomain string, endpoints []entities.AppEndpoint) *Zone {
+	byName := make(map[string][]entities.AppEndpoint, 0)
+	for _, endpoint := range endpoints {
+		if endpoint.GlobalFqdn == "" || endpoint.Fqdn == "" {
+			continue
+		}
+		byName[endpoint.GlobalFqdn] = append(byName[endpoint.GlobalFqdn], endpoint)
+	}
+
+	origin := Origin(publicHostDomain)
+	records := make([]Record, 0)
+	seen := make(map[Record]bool, 0)
+	add := func(record Record) {
+		if !seen[record] {
+			seen[record] = true
+			records = append(records, record)
+		}
+	}
+
+	for name, named := range byName {
+		hosts := make(map[string]bool, 0)
+		ips := make(map[string]bool, 0)
+		for _, endpoint := range named {
+			if ip := endpointIP(endpoint.Fqdn); ip != nil {
+				ips[ip.String()] = true
+			} else {
+				hosts[absolute(endpoint.Fqdn)] = true
+			}
+		}
+		for ip := range ips {
+			add(Record{Name: name, Type: A, TTL: DefaultTTL, Target: ip})
+		}
+		// A CNAME cannot coexist with other records of the same name
+		if len(ips) == 0 && len(hosts) == 1 {
+			for host := range hosts {
+				add(Record{Name: name, Type: CNAME, TTL: DefaultTTL, Target: host})
+			}
+		}
+		for _, endpoint := range named {
+			if endpoint.Port <= 0 {
+				continue
+			}
+			target := absolute(endpoint.Fqdn)
+			if endpointIP(endpoint.Fqdn) != nil {
+				target = fmt.Sprintf("%s.%s", name, origin)
+			}
+			add(Record{Name: srvName(name, endpoint.Protocol), Type: SRV, TTL: DefaultTTL, Target: target, Port: endpoint.Port})
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Port < b.Port
+	})
+
+	return &Zone{
+		Origin:  origin,
+		TTL:     DefaultTTL,
+		Records: records,
+	}
+}
+
+// Checksum returns a hash of the records of the zone. It is used to detect changes between exports.
+func (z *Zone) Checksum() string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %d\n", z.Origin, z.TTL)
+	for _, record := range z.Records {
+		fmt.Fprintln(hash, record.line())
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dns
+
+import (
+	"encoding/json"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"strings"
+)
+
+var _ = ginkgo.Describe("DNS zone", func() {
+
+	const publicHostDomain = "nalej.cluster.local"
+
+	endpoints := []entities.AppEndpoint{
+		{
+			GlobalFqdn: "web-443.sg0001.app001.org00001",
+			Fqdn:       "web.sg0001.app001.cluster1.nalej.tech",
+			Port:       443,
+			Protocol:   entities.HTTPS,
+		},
+		{
+			GlobalFqdn: "db-5432.sg0001.app001.org00001",
+			Fqdn:       "10.0.0.2:5432",
+			Port:       5432,
+			Protocol:   entities.HTTP,
+		},
+		{
+			GlobalFqdn: "db-5432.sg0001.app001.org00001",
+			Fqdn:       "10.0.0.1:5432",
+			Port:       5432,
+			Protocol:   entities.HTTP,
+		},
+	}
+
+	ginkgo.It("should build the records of the endpoints", func() {
+		zone := NewZone(publicHostDomain, endpoints)
+		gomega.Expect(zone.Origin).Should(gomega.Equal("ep.nalej.cluster.local."))
+		gomega.Expect(zone.Records).Should(gomega.Equal([]Record{
+			{Name: "_http._tcp.db-5432.sg0001.app001.org00001", Type: SRV, TTL: DefaultTTL,
+				Target: "db-5432.sg0001.app001.org00001.ep.nalej.cluster.local.", Port: 5432},
+			{Name: "_https._tcp.web-443.sg0001.app001.org00001", Type: SRV, TTL: DefaultTTL,
+				Target: "web.sg0001.app001.cluster1.nalej.tech.", Port: 443},
+			{Name: "db-5432.sg0001.app001.org00001", Type: A, TTL: DefaultTTL, Target: "10.0.0.1"},
+			{Name: "db-5432.sg0001.app001.org00001", Type: A, TTL: DefaultTTL, Target: "10.0.0.2"},
+			{Name: "web-443.sg0001.app001.org00001", Type: CNAME, TTL: DefaultTTL,
+				Target: "web.sg0001.app001.cluster1.nalej.tech."},
+		}))
+	})
+
+	ginkgo.It("should use the protocol of the endpoints in the SRV records", func() {
+		zone := NewZone(publicHostDomain, []entities.AppEndpoint{
+			{GlobalFqdn: "dns-53.sg0001.app001.org00001", Fqdn: "10.0.0.3:53", Port: 53, Protocol: entities.UDP},
+			{GlobalFqdn: "api-9000.sg0001.app001.org00001", Fqdn: "10.0.0.4:9000", Port: 9000, Protocol: entities.GRPC},
+			{GlobalFqdn: "ws-8080.sg0001.app001.org00001", Fqdn: "10.0.0.5:8080", Port: 8080, Protocol: entities.WebSocket},
+		})
+		names := make([]string, 0)
+		for _, record := range zone.Records {
+			if record.Type == SRV {
+				names = append(names, record.Name)
+			}
+		}
+		gomega.Expect(names).Should(gomega.ConsistOf(
+			"_endpoint._udp.dns-53.sg0001.app001.org00001",
+			"_grpc._tcp.api-9000.sg0001.app001.org00001",
+			"_ws._tcp.ws-8080.sg0001.app001.org00001"))
+	})
+
+	ginkgo.It("should not depend on the order of the endpoints", func() {
+		reversed := []entities.AppEndpoint{endpoints[2], endpoints[1], endpoints[0]}
+		gomega.Expect(NewZone(publicHostDomain, reversed).Checksum()).Should(
+			gomega.Equal(NewZone(publicHostDomain, endpoints).Checksum()))
+	})
+
+	ginkgo.It("should render a zone file", func() {
+		zone := NewZone(publicHostDomain, endpoints)
+		zone.Serial = 7
+		content := string(zone.ZoneFile())
+		gomega.Expect(content).Should(gomega.HavePrefix("$ORIGIN ep.nalej.cluster.local.\n"))
+		gomega.Expect(content).Should(gomega.ContainSubstring(" 7 3600 600 604800 300\n"))
+		gomega.Expect(content).Should(gomega.ContainSubstring("db-5432.sg0001.app001.org00001\t300\tIN\tA\t10.0.0.1\n"))
+		gomega.Expect(strings.Count(content, "\n")).Should(gomega.Equal(4 + len(zone.Records)))
+	})
+
+	ginkgo.It("should render the CoreDNS records", func() {
+		zone := NewZone(publicHostDomain, endpoints)
+		content, err := zone.Render(CoreDNSFormat)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		coreDNS := CoreDNSZone{}
+		gomega.Expect(json.Unmarshal(content, &coreDNS)).To(gomega.Succeed())
+		gomega.Expect(len(coreDNS.Records)).Should(gomega.Equal(3))
+		gomega.Expect(coreDNS.Records[0].Key).Should(gomega.Equal(
+			"/skydns/local/cluster/nalej/ep/org00001/app001/sg0001/db-5432/x1"))
+		gomega.Expect(coreDNS.Records[0].Host).Should(gomega.Equal("10.0.0.1"))
+		gomega.Expect(coreDNS.Records[0].Port).Should(gomega.Equal(int32(5432)))
+		gomega.Expect(coreDNS.Records[1].Key).Should(gomega.HaveSuffix("/x2"))
+	})
+
+	ginkgo.It("should render the ports of the CoreDNS records of any protocol", func() {
+		zone := NewZone(publicHostDomain, []entities.AppEndpoint{
+			{GlobalFqdn: "dns-53.sg0001.app001.org00001", Fqdn: "10.0.0.3:53", Port: 53, Protocol: entities.UDP},
+		})
+		content, err := zone.Render(CoreDNSFormat)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		coreDNS := CoreDNSZone{}
+		gomega.Expect(json.Unmarshal(content, &coreDNS)).To(gomega.Succeed())
+		gomega.Expect(len(coreDNS.Records)).Should(gomega.Equal(1))
+		gomega.Expect(coreDNS.Records[0].Port).Should(gomega.Equal(int32(53)))
+	})
+})
@@ -27,17 +27,56 @@ type AccountState int32
 const (
 	AccountState_Active AccountState = iota + 1
 	AccountState_Deactivated
+	// AccountState_Suspended with an account temporarily stopped, e.g. due to a pending payment.
+	AccountState_Suspended
+	// AccountState_PendingDeletion with an account waiting to be removed.
+	AccountState_PendingDeletion
 )
 
+// AccountStateToGRPC converts the states of an account. The states without a gRPC counterpart are sent as deactivated,
+// with the reason in the state info.
 var AccountStateToGRPC = map[AccountState]grpc_account_go.AccountState{
-	AccountState_Active:      grpc_account_go.AccountState_ACTIVE,
-	AccountState_Deactivated: grpc_account_go.AccountState_DEACTIVATED,
+	AccountState_Active:          grpc_account_go.AccountState_ACTIVE,
+	AccountState_Deactivated:     grpc_account_go.AccountState_DEACTIVATED,
+	AccountState_Suspended:       grpc_account_go.AccountState_DEACTIVATED,
+	AccountState_PendingDeletion: grpc_account_go.AccountState_DEACTIVATED,
 }
 var AccountStateFromGRPC = map[grpc_account_go.AccountState]AccountState{
 	grpc_account_go.AccountState_ACTIVE:      AccountState_Active,
 	grpc_account_go.AccountState_DEACTIVATED: AccountState_Deactivated,
 }
 
+// AccountStateFromGRPCUpdate returns the state an account changes to when a gRPC state is received. As the suspended and
+// pending deletion states are sent as deactivated, receiving deactivated keeps those states.
+func AccountStateFromGRPCUpdate(current AccountState, state grpc_account_go.AccountState) AccountState {
+	if state == grpc_account_go.AccountState_DEACTIVATED &&
+		(current == AccountState_Suspended || current == AccountState_PendingDeletion) {
+		return current
+	}
+	return AccountStateFromGRPC[state]
+}
+
+var AccountStateToString = map[AccountState]string{
+	AccountState_Active:          "active",
+	AccountState_Deactivated:     "deactivated",
+	AccountState_Suspended:       "suspended",
+	AccountState_PendingDeletion: "pending_deletion",
+}
+var AccountStateFromString = map[string]AccountState{
+	"active":           AccountState_Active,
+	"deactivated":      AccountState_Deactivated,
+	"suspended":        AccountState_Suspended,
+	"pending_deletion": AccountState_PendingDeletion,
+}
+
+// accountStateTransitions with the states an account can change to from each state.
+var accountStateTransitions = map[AccountState][]AccountState{
+	AccountState_Active:          {AccountState_Suspended, AccountState_Deactivated, AccountState_PendingDeletion},
+	AccountState_Suspended:       {AccountState_Active, AccountState_Deactivated, AccountState_PendingDeletion},
+	AccountState_Deactivated:     {AccountState_Active, AccountState_PendingDeletion},
+	AccountState_PendingDeletion: {AccountState_Active},
+}
+
 // AccountBillingInfo with the billing information of an account
 type AccountBillingInfo struct {
 	// AccountId with the account identifier
@@ -90,6 +129,16 @@ type Account struct {
 	StateInfo string `json:"state_info,omitempty"`
 }
 
+// AccountOrganization links an organization to the account that owns it.
+type AccountOrganization struct {
+	AccountId      string `json:"account_id"`
+	OrganizationId string `json:"organization_id"`
+}
+
+func NewAccountOrganization(account string, organization string) *AccountOrganization {
+	return &AccountOrganization{account, organization}
+}
+
 func NewAccountFromGRPC(account *grpc_account_go.AddAccountRequest) *Account {
 	if account == nil {
 		return nil
@@ -129,7 +178,7 @@ func (a *Account) ApplyUpdate(update *grpc_account_go.UpdateAccountRequest) {
 		a.Name = update.Name
 	}
 	if update.UpdateState {
-		a.State = AccountStateFromGRPC[update.State]
+		a.State = AccountStateFromGRPCUpdate(a.State, update.State)
 	}
 	if update.UpdateStateInfo {
 		a.StateInfo = update.StateInfo
@@ -167,6 +216,27 @@ func ValidateAddAccountRequest(request *grpc_account_go.AddAccountRequest) derro
 	return nil
 }
 
+// ValidateAccountStateTransition checks that an account can change from a state to another one. The suspended and pending
+// deletion states require a reason.
+func ValidateAccountStateTransition(from AccountState, to AccountState, reason string) derrors.Error {
+	if _, exists := AccountStateToString[to]; !exists {
+		return derrors.NewInvalidArgumentError("invalid account state").WithParams(to)
+	}
+	if (to == AccountState_Suspended || to == AccountState_PendingDeletion) && reason == "" {
+		return derrors.NewInvalidArgumentError(emptyStateInfo).WithParams(AccountStateToString[to])
+	}
+	if from == to {
+		return nil
+	}
+	for _, allowed := range accountStateTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return derrors.NewFailedPreconditionError("invalid account state transition").
+		WithParams(AccountStateToString[from], AccountStateToString[to])
+}
+
 func ValidateAccountId(request *grpc_account_go.AccountId) derrors.Error {
 	if request.AccountId == "" {
 		return derrors.NewInvalidArgumentError(emptyAccountId)
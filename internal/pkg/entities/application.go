@@ -23,6 +23,7 @@ import (
 	"github.com/nalej/grpc-application-go"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"strings"
+	"time"
 )
 
 // DefaultEndPointInstance is used when the endpoint recived from GRPC has no endpoint
@@ -688,6 +689,29 @@ type Endpoint struct {
 	Options map[string]string `json:"options,omitempty" cql:"options"`
 }
 
+// IsIngress checks if the endpoints of this type are exposed through an ingress.
+func (t EndpointType) IsIngress() bool {
+	return t == Rest || t == Web || t == Ingestion
+}
+
+// Protocol returns the protocol declared in the options of the endpoint.
+func (e *Endpoint) Protocol() (AppEndpointProtocol, derrors.Error) {
+	value, exists := e.Options[EndpointProtocolOption]
+	if !exists || value == "" {
+		return HTTP, nil
+	}
+	protocol, exists := AppEndpointProtocolFromString[strings.ToLower(value)]
+	if !exists {
+		return 0, derrors.NewInvalidArgumentError("unsupported endpoint protocol").WithParams(value)
+	}
+	return protocol, nil
+}
+
+// HTTP2 checks if the options of the endpoint declare HTTP/2 support.
+func (e *Endpoint) HTTP2() bool {
+	return strings.ToLower(e.Options[EndpointHTTP2Option]) == "true"
+}
+
 func NewEndpointFromGRPC(endpoint *grpc_application_go.Endpoint) *Endpoint {
 	if endpoint == nil {
 		return nil
@@ -1373,6 +1397,10 @@ type AppDescriptor struct {
 	InboundNetInterfaces []InboundNetworkInterface `json:"inbound_net_interfaces,omitempty" cql:"inbound_net_interfaces"`
 	// OutboundNetInterfaces with a list of outbounds
 	OutboundNetInterfaces []OutboundNetworkInterface `json:"outbound_net_interfaces,omitempty" cql:"outbound_net_interfaces"`
+	// Revision with the current revision number of the descriptor.
+	Revision int64 `json:"revision,omitempty" cql:"revision"`
+	// ProjectId with the project of the account of the organization the descriptor belongs to, if any.
+	ProjectId string `json:"project_id,omitempty" cql:"project_id"`
 }
 
 func NewAppDescriptor(organizationID string, appDescriptorID string, name string,
@@ -1401,7 +1429,16 @@ func NewAppDescriptorFromGRPC(addRequest *grpc_application_go.AddAppDescriptorRe
 		return nil, nil
 	}
 
-	uuid := GenerateUUID()
+	descriptor, err := newAppDescriptorWithIDFromGRPC(GenerateUUID(), addRequest)
+	if err != nil {
+		return nil, err
+	}
+	descriptor.Revision = InitialDescriptorRevision
+	return descriptor, nil
+}
+
+// newAppDescriptorWithIDFromGRPC builds a descriptor with a given identifier from an add request.
+func newAppDescriptorWithIDFromGRPC(uuid string, addRequest *grpc_application_go.AddAppDescriptorRequest) (*AppDescriptor, derrors.Error) {
 
 	rules := make([]SecurityRule, 0)
 	if addRequest.Rules != nil {
@@ -1595,11 +1632,20 @@ type AppEndpointProtocol int
 const (
 	HTTP AppEndpointProtocol = iota + 1
 	HTTPS
+	TCP
+	UDP
+	GRPC
+	WebSocket
 )
 
+// AppEndpointProtocolToGRPC contains the protocols that can be represented in the gRPC API, which only supports HTTP
+// and HTTPS. gRPC and WebSocket endpoints are served by the HTTP ingress, so they are returned as HTTP. Raw TCP and
+// UDP endpoints cannot be represented. The protocols are declared with the EndpointProtocolOption of the endpoint.
 var AppEndpointProtocolToGRPC = map[AppEndpointProtocol]grpc_application_go.AppEndpointProtocol{
-	HTTP:  grpc_application_go.AppEndpointProtocol_HTTP,
-	HTTPS: grpc_application_go.AppEndpointProtocol_HTTPS,
+	HTTP:      grpc_application_go.AppEndpointProtocol_HTTP,
+	HTTPS:     grpc_application_go.AppEndpointProtocol_HTTPS,
+	GRPC:      grpc_application_go.AppEndpointProtocol_HTTP,
+	WebSocket: grpc_application_go.AppEndpointProtocol_HTTP,
 }
 
 var AppEndpointProtocolFromGRPC = map[grpc_application_go.AppEndpointProtocol]AppEndpointProtocol{
@@ -1607,6 +1653,61 @@ var AppEndpointProtocolFromGRPC = map[grpc_application_go.AppEndpointProtocol]Ap
 	grpc_application_go.AppEndpointProtocol_HTTPS: HTTPS,
 }
 
+var AppEndpointProtocolToString = map[AppEndpointProtocol]string{
+	HTTP:      "http",
+	HTTPS:     "https",
+	TCP:       "tcp",
+	UDP:       "udp",
+	GRPC:      "grpc",
+	WebSocket: "websocket",
+}
+
+var AppEndpointProtocolFromString = map[string]AppEndpointProtocol{
+	"http":      HTTP,
+	"https":     HTTPS,
+	"tcp":       TCP,
+	"udp":       UDP,
+	"grpc":      GRPC,
+	"websocket": WebSocket,
+}
+
+// EndpointProtocolOption is the endpoint option that declares the protocol of the endpoint (http, https, tcp, udp,
+// grpc or websocket). Endpoints without it use HTTP.
+const EndpointProtocolOption = "protocol"
+
+// EndpointHTTP2Option is the endpoint option that declares that the endpoint supports HTTP/2.
+const EndpointHTTP2Option = "http2"
+
+// IsHTTP checks if the protocol runs over HTTP.
+func (p AppEndpointProtocol) IsHTTP() bool {
+	return p == HTTP || p == HTTPS || p == GRPC || p == WebSocket
+}
+
+// Transport returns the transport protocol (tcp or udp).
+func (p AppEndpointProtocol) Transport() string {
+	if p == UDP {
+		return "udp"
+	}
+	return "tcp"
+}
+
+// ValidAppEndpointProtocol checks the restrictions of a protocol on an endpoint.
+func ValidAppEndpointProtocol(protocol AppEndpointProtocol, endpointType EndpointType, port int32, http2 bool) derrors.Error {
+	if _, exists := AppEndpointProtocolToString[protocol]; !exists {
+		return derrors.NewInvalidArgumentError("unsupported endpoint protocol").WithParams(protocol)
+	}
+	if !protocol.IsHTTP() && port == 0 {
+		return derrors.NewInvalidArgumentError("expecting port for non HTTP endpoints").WithParams(AppEndpointProtocolToString[protocol])
+	}
+	if protocol == UDP && endpointType.IsIngress() {
+		return derrors.NewInvalidArgumentError("UDP endpoints cannot be exposed through an ingress").WithParams(endpointType)
+	}
+	if protocol == GRPC && !http2 {
+		return derrors.NewInvalidArgumentError("gRPC endpoints must support HTTP/2")
+	}
+	return nil
+}
+
 type AppEndpoint struct {
 	// OrganizationId with the organization identifier.
 	OrganizationId string `json:"organization_id,omitempty" cql:"organization_id"`
@@ -1618,8 +1719,10 @@ type AppEndpoint struct {
 	ServiceInstanceId string `json:"service_instance_id,omitempty" cql:"service_instance_id"`
 	// Port port in the endpoint
 	Port int32 `json:"port,omitempty" cql:"port"`
-	// protocol (http, https)
+	// protocol (http, https, tcp, udp, grpc, websocket)
 	Protocol AppEndpointProtocol `json:"protocol,omitempty" cql:"protocol"`
+	// HTTP2 indicates that the endpoint supports HTTP/2
+	HTTP2 bool `json:"http2,omitempty" cql:"http2"`
 	// EndpointInstanceId unique id for this endpoint
 	EndpointInstanceId string `json:"endpoint_instance_id,omitempty" cql:"endpoint_instance_id"`
 	// Type of endpoint
@@ -1630,9 +1733,15 @@ type AppEndpoint struct {
 	GlobalFqdn string `json:"global_fqdn,omitempty" cql:"global_fqdn"`
 }
 
-func (ep *AppEndpoint) ToGRPC() *grpc_application_go.AppEndpoint {
+// ToGRPC converts the endpoint to its gRPC representation. It fails if the protocol of the endpoint cannot be
+// represented in the gRPC API.
+func (ep *AppEndpoint) ToGRPC() (*grpc_application_go.AppEndpoint, derrors.Error) {
 	convertedType, _ := EndpointTypeToGRPC[ep.Type]
-	convertedProtocol, _ := AppEndpointProtocolToGRPC[ep.Protocol]
+	convertedProtocol, exists := AppEndpointProtocolToGRPC[ep.Protocol]
+	if !exists {
+		return nil, derrors.NewFailedPreconditionError("endpoint protocol not supported by the gRPC API").WithParams(
+			ep.EndpointInstanceId, AppEndpointProtocolToString[ep.Protocol])
+	}
 	return &grpc_application_go.AppEndpoint{
 		OrganizationId:         ep.OrganizationId,
 		AppInstanceId:          ep.AppInstanceId,
@@ -1645,37 +1754,46 @@ func (ep *AppEndpoint) ToGRPC() *grpc_application_go.AppEndpoint {
 			Fqdn:               ep.Fqdn,
 			Port:               ep.Port,
 		},
-	}
+	}, nil
 }
 
 // getNamePrefixes returns prefix to fill the globalFQDN
-// 1) "service-name"-"port"
-// 2) service_group_instanceID (6 characters)
-// 3) appInstance (6 characters)
-// 4) organizationID (8 characters)
-func getNamePrefixes(ep *grpc_application_go.AddAppEndpointRequest) (string, string, string, string) {
+// 1) "service-name"-"port" (the port is omitted for HTTP based protocols on port 80)
+// 2) service_group_instanceID (instPrefixLength characters)
+// 3) appInstance (instPrefixLength characters)
+// 4) organizationID (orgPrefixLength characters)
+func getNamePrefixes(ep *grpc_application_go.AddAppEndpointRequest, protocol AppEndpointProtocol, instPrefixLength int, orgPrefixLength int) (string, string, string, string) {
 	serviceName := ep.ServiceName
 
-	if ep.EndpointInstance != nil && ep.EndpointInstance.Port != 80 && ep.EndpointInstance.Port != 0 {
+	if ep.EndpointInstance != nil && ep.EndpointInstance.Port != 0 && (ep.EndpointInstance.Port != 80 || !protocol.IsHTTP()) {
 		serviceName = fmt.Sprintf("%s-%d", ep.ServiceName, ep.EndpointInstance.Port)
 	}
-	serviceGroupInstPrefix := ep.ServiceGroupInstanceId
-	if len(serviceGroupInstPrefix) > InstPrefixLength {
-		serviceGroupInstPrefix = serviceGroupInstPrefix[0:InstPrefixLength]
-	}
-	appInstPrefix := ep.AppInstanceId
-	if len(appInstPrefix) > InstPrefixLength {
-		appInstPrefix = appInstPrefix[0:InstPrefixLength]
+	serviceGroupInstPrefix := truncateFqdnLabel(ep.ServiceGroupInstanceId, instPrefixLength)
+	appInstPrefix := truncateFqdnLabel(ep.AppInstanceId, instPrefixLength)
+	orgPrefix := truncateFqdnLabel(ep.OrganizationId, orgPrefixLength)
+	return serviceName, serviceGroupInstPrefix, appInstPrefix, orgPrefix
+}
+
+// truncateFqdnLabel returns the first length characters of an identifier. The prefix is extended if it ends with a
+// hyphen, as DNS labels cannot end with one.
+func truncateFqdnLabel(id string, length int) string {
+	if len(id) <= length {
+		return id
 	}
-	orgPrefix := ep.OrganizationId
-	if len(orgPrefix) > OrgPrefixLength {
-		orgPrefix = orgPrefix[0:OrgPrefixLength]
+	for length < len(id) && id[length-1] == '-' {
+		length++
 	}
-	return serviceName, serviceGroupInstPrefix, appInstPrefix, orgPrefix
+	return id[0:length]
 }
 
 // createGlobalFqdn returns the globalFqdn for a endpoinFqnd given
 func createGlobalFqdn(endpoint *grpc_application_go.AddAppEndpointRequest) string {
+	return CreateGlobalFqdnWithPrefixes(endpoint, AppEndpointProtocolFromGRPC[endpoint.Protocol], InstPrefixLength, OrgPrefixLength)
+}
+
+// CreateGlobalFqdnWithPrefixes returns the globalFqdn for a endpoinFqnd given using the protocol of the endpoint and
+// the selected prefix lengths
+func CreateGlobalFqdnWithPrefixes(endpoint *grpc_application_go.AddAppEndpointRequest, protocol AppEndpointProtocol, instPrefixLength int, orgPrefixLength int) string {
 
 	// Option1 - Fqdn: serv.A.B.domain
 	// where:
@@ -1687,12 +1805,12 @@ func createGlobalFqdn(endpoint *grpc_application_go.AddAppEndpointRequest) strin
 	// We need to store:
 	// Global Fqdn: serv.A.B.C.domain
 	// where
-	// A: service_group_id (6 characters)
-	// B: app_instance_id (6 characters)
-	// C: organization_id (8 characters)
+	// A: service_group_id (6 characters by default)
+	// B: app_instance_id (6 characters by default)
+	// C: organization_id (8 characters by default)
 	// the domain is not stored
 
-	serviceName, serviceGroupId, appInstanceId, organizationId := getNamePrefixes(endpoint)
+	serviceName, serviceGroupId, appInstanceId, organizationId := getNamePrefixes(endpoint, protocol, instPrefixLength, orgPrefixLength)
 
 	return fmt.Sprintf("%s.%s.%s.%s", serviceName, serviceGroupId, appInstanceId, organizationId)
 
@@ -1743,9 +1861,29 @@ func ValidGroup(group *grpc_application_go.ServiceGroup) derrors.Error {
 	if len(group.Services) == 0 {
 		return derrors.NewInvalidArgumentError("expecting at least one service")
 	}
+	for _, service := range group.Services {
+		for _, port := range service.ExposedPorts {
+			for _, endpoint := range port.Endpoints {
+				err := ValidPortEndpoint(port, endpoint)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
 	return nil
 }
 
+// ValidPortEndpoint checks the protocol declared in the options of an endpoint of an exposed port.
+func ValidPortEndpoint(port *grpc_application_go.Port, endpoint *grpc_application_go.Endpoint) derrors.Error {
+	converted := NewEndpointFromGRPC(endpoint)
+	protocol, err := converted.Protocol()
+	if err != nil {
+		return err
+	}
+	return ValidAppEndpointProtocol(protocol, converted.Type, port.ExposedPort, converted.HTTP2())
+}
+
 func ValidAddService(service *grpc_application_go.Service) derrors.Error {
 	if service.OrganizationId == "" || service.ServiceId == "" {
 		return derrors.NewInvalidArgumentError("expecting organization_id, service_id")
@@ -1851,6 +1989,12 @@ type AppInstance struct {
 	InboundNetInterfaces []InboundNetworkInterface `json:"inbound_net_interfaces,omitempty" cql:"inbound_net_interfaces"`
 	// OutboundNetInterfaces with a list of outbounds
 	OutboundNetInterfaces []OutboundNetworkInterface `json:"outbound_net_interfaces,omitempty" cql:"outbound_net_interfaces"`
+	// DescriptorRevision with the revision of the descriptor the instance was created from.
+	DescriptorRevision int64 `json:"descriptor_revision,omitempty" cql:"descriptor_revision"`
+	// Version of the instance, increased on every update.
+	Version int64 `json:"version,omitempty" cql:"version"`
+	// ProjectId with the project of the account of the organization the instance belongs to, if any.
+	ProjectId string `json:"project_id,omitempty" cql:"project_id"`
 }
 
 func (sg *ServiceGroup) ToServiceGroupInstance(appInstanceID string) *ServiceGroupInstance {
@@ -1944,6 +2088,8 @@ func NewAppInstanceFromAddInstanceRequestGRPC(addRequest *grpc_application_go.Ad
 		Info:                  "",
 		InboundNetInterfaces:  descriptor.InboundNetInterfaces,
 		OutboundNetInterfaces: descriptor.OutboundNetInterfaces,
+		DescriptorRevision:    descriptor.Revision,
+		ProjectId:             descriptor.ProjectId,
 	}
 }
 
@@ -2055,6 +2201,8 @@ type ServiceProxy struct {
 	IP string `json:"ip,omitempty" cql:"ip"`
 	//FQDN
 	FQDN string `json:"fqdn,omitempty" cql:"fqdn"`
+	// CreatedAt with the time the proxy was added
+	CreatedAt int64 `json:"created_at,omitempty" cql:"created_at"`
 }
 
 func NewServiceProxyFromGRPC(proxy *grpc_application_go.ServiceProxy) *ServiceProxy {
@@ -2184,7 +2332,7 @@ func NewAppZtNetworkMemberFromGRPC(req *grpc_application_go.AddAuthorizedZtNetwo
 		ZtNetworkId:                  req.NetworkId,
 		ServiceGroupInstanceId:       req.ServiceGroupInstanceId,
 		ServiceApplicationInstanceId: req.ServiceApplicationInstanceId,
-		Members:                      map[string]AppNetworkMember{req.MemberId: {MemberId: req.MemberId, Ip: req.NetworkId, IsProxy: req.IsProxy, CreatedAt: 0}},
+		Members:                      map[string]AppNetworkMember{req.MemberId: {MemberId: req.MemberId, IsProxy: req.IsProxy, CreatedAt: time.Now().Unix()}},
 	}
 }
 
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import "fmt"
+
+// groupDriftFields contains the fields of a service group compared to detect drift.
+var groupDriftFields = []string{"policy", "specs", "labels"}
+
+// serviceDriftFields contains the fields of a service compared to detect drift.
+var serviceDriftFields = []string{"type", "image", "credentials", "specs", "storage", "exposed_ports",
+	"environment_variables", "configs", "labels", "deploy_after", "run_arguments"}
+
+// instanceDriftFields contains the fields of an instance compared to detect drift.
+var instanceDriftFields = []string{"configuration_options", "environment_variables", "labels"}
+
+// -- AppInstanceDrift -- //
+// AppInstanceDrift contains the differences between an application instance and the parametrized descriptor it was
+// created from. Elements only found in the descriptor are reported as removed, and elements only found in the
+// instance are reported as added. OldValue contains the descriptor value and NewValue the instance value.
+type AppInstanceDrift struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string `json:"organization_id,omitempty"`
+	// AppDescriptorId with the application descriptor identifier.
+	AppDescriptorId string `json:"app_descriptor_id,omitempty"`
+	// AppInstanceId with the application instance identifier.
+	AppInstanceId string `json:"app_instance_id,omitempty"`
+	// DescriptorRevision with the revision of the descriptor the instance was created from.
+	DescriptorRevision int64 `json:"descriptor_revision,omitempty"`
+	// Changes found between the instance and its parametrized descriptor.
+	Changes []DescriptorChange `json:"changes"`
+}
+
+// HasDrift returns true if the instance differs from its parametrized descriptor.
+func (d *AppInstanceDrift) HasDrift() bool {
+	return len(d.Changes) > 0
+}
+
+// compareSelectedFields compares only the given fields of two elements.
+func (dd *descriptorDiffer) compareSelectedFields(element string, path string, oldElement interface{}, newElement interface{}, fields []string) {
+	oldFields := toComparable(oldElement)
+	newFields := toComparable(newElement)
+	selectedOld := make(map[string]interface{}, len(fields))
+	selectedNew := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if value, exists := oldFields[field]; exists {
+			selectedOld[field] = value
+		}
+		if value, exists := newFields[field]; exists {
+			selectedNew[field] = value
+		}
+	}
+	dd.compareFields(element, path, selectedOld, selectedNew)
+}
+
+// NewAppInstanceDrift compares an instance with its parametrized descriptor.
+func NewAppInstanceDrift(descriptor ParametrizedDescriptor, instance AppInstance) *AppInstanceDrift {
+	dd := &descriptorDiffer{changes: make([]DescriptorChange, 0)}
+
+	dd.compareSelectedFields("instance", "", descriptor, instance, instanceDriftFields)
+
+	// Service groups are linked with their instances by the service group identifier
+	instancesByGroup := make(map[string][]ServiceGroupInstance, 0)
+	for _, sgi := range instance.Groups {
+		instancesByGroup[sgi.ServiceGroupId] = append(instancesByGroup[sgi.ServiceGroupId], sgi)
+	}
+	for _, group := range descriptor.Groups {
+		groupPath := fmt.Sprintf("groups/%s", group.Name)
+		groupInstances, found := instancesByGroup[group.ServiceGroupId]
+		if !found {
+			dd.changes = append(dd.changes, DescriptorChange{Type: ElementRemoved, Element: "group", Path: groupPath})
+			continue
+		}
+		delete(instancesByGroup, group.ServiceGroupId)
+
+		if group.Specs != nil && group.Specs.Replicas > 0 && !group.Specs.MultiClusterReplica &&
+			int(group.Specs.Replicas) != len(groupInstances) {
+			dd.changes = append(dd.changes, DescriptorChange{
+				Type:     ElementModified,
+				Element:  "group",
+				Path:     groupPath,
+				Field:    "replicas",
+				OldValue: fmt.Sprintf("%d", group.Specs.Replicas),
+				NewValue: fmt.Sprintf("%d", len(groupInstances)),
+			})
+		}
+
+		for _, sgi := range groupInstances {
+			instancePath := fmt.Sprintf("%s/%s", groupPath, sgi.ServiceGroupInstanceId)
+			dd.compareSelectedFields("group", instancePath, group, sgi, groupDriftFields)
+
+			servicesByID := make(map[string]ServiceInstance, len(sgi.ServiceInstances))
+			for _, si := range sgi.ServiceInstances {
+				servicesByID[si.ServiceId] = si
+			}
+			for _, service := range group.Services {
+				servicePath := fmt.Sprintf("%s/services/%s", instancePath, service.Name)
+				serviceInstance, found := servicesByID[service.ServiceId]
+				if !found {
+					dd.changes = append(dd.changes, DescriptorChange{Type: ElementRemoved, Element: "service", Path: servicePath})
+					continue
+				}
+				delete(servicesByID, service.ServiceId)
+				dd.compareSelectedFields("service", servicePath, service, serviceInstance, serviceDriftFields)
+			}
+			for _, si := range servicesByID {
+				dd.changes = append(dd.changes, DescriptorChange{Type: ElementAdded, Element: "service",
+					Path: fmt.Sprintf("%s/services/%s", instancePath, si.Name)})
+			}
+		}
+	}
+	for _, groupInstances := range instancesByGroup {
+		for _, sgi := range groupInstances {
+			dd.changes = append(dd.changes, DescriptorChange{Type: ElementAdded, Element: "group",
+				Path: fmt.Sprintf("groups/%s/%s", sgi.Name, sgi.ServiceGroupInstanceId)})
+		}
+	}
+
+	// Rules
+	oldRules := make(map[string]SecurityRule, len(descriptor.Rules))
+	oldRuleNames := make([]string, 0, len(descriptor.Rules))
+	for _, r := range descriptor.Rules {
+		oldRules[r.Name] = r
+		oldRuleNames = append(oldRuleNames, r.Name)
+	}
+	newRules := make(map[string]SecurityRule, len(instance.Rules))
+	newRuleNames := make([]string, 0, len(instance.Rules))
+	for _, r := range instance.Rules {
+		newRules[r.Name] = r
+		newRuleNames = append(newRuleNames, r.Name)
+	}
+	dd.compareNamed("rule", "rules", oldRuleNames, newRuleNames, func(name string, path string) {
+		dd.compareFields("rule", path, oldRules[name], newRules[name])
+	})
+
+	return &AppInstanceDrift{
+		OrganizationId:     instance.OrganizationId,
+		AppDescriptorId:    instance.AppDescriptorId,
+		AppInstanceId:      instance.AppInstanceId,
+		DescriptorRevision: instance.DescriptorRevision,
+		Changes:            dd.changes,
+	}
+}
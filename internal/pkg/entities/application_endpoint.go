@@ -0,0 +1,141 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-application-go"
+	"strings"
+)
+
+// PublicEndpointLabel is the label between the global FQDN and the public host domain in public FQDNs.
+const PublicEndpointLabel = "ep"
+
+// globalFqdnPrefixStep is the number of characters added to the identifier prefixes when a global FQDN collides.
+const globalFqdnPrefixStep = 2
+
+// AppEndpointFqdn is the reverse index entry that assigns a global FQDN to a service of a service group instance.
+type AppEndpointFqdn struct {
+	// GlobalFqdn assigned to the service.
+	GlobalFqdn string `json:"global_fqdn,omitempty" cql:"global_fqdn"`
+	// OrganizationId with the organization identifier.
+	OrganizationId string `json:"organization_id,omitempty" cql:"organization_id"`
+	// AppInstanceId with the application instance identifier.
+	AppInstanceId string `json:"app_instance_id,omitempty" cql:"app_instance_id"`
+	// ServiceGroupInstanceId the identifier of the group instance.
+	ServiceGroupInstanceId string `json:"service_group_instance_id,omitempty" cql:"service_group_instance_id"`
+	// ServiceName with the first label of the FQDN (service name and port).
+	ServiceName string `json:"service_name,omitempty" cql:"service_name"`
+}
+
+// NewAppEndpointFqdn creates the reverse index entry of a global FQDN.
+func NewAppEndpointFqdn(globalFqdn string, endpoint *grpc_application_go.AddAppEndpointRequest) *AppEndpointFqdn {
+	return &AppEndpointFqdn{
+		GlobalFqdn:             globalFqdn,
+		OrganizationId:         endpoint.OrganizationId,
+		AppInstanceId:          endpoint.AppInstanceId,
+		ServiceGroupInstanceId: endpoint.ServiceGroupInstanceId,
+		ServiceName:            strings.Split(globalFqdn, ".")[0],
+	}
+}
+
+// SameOwner checks if two entries belong to the same service of the same service group instance.
+func (f *AppEndpointFqdn) SameOwner(other AppEndpointFqdn) bool {
+	return f.OrganizationId == other.OrganizationId && f.AppInstanceId == other.AppInstanceId &&
+		f.ServiceGroupInstanceId == other.ServiceGroupInstanceId && f.ServiceName == other.ServiceName
+}
+
+// Owns checks if an endpoint belongs to the owner of the entry.
+func (f *AppEndpointFqdn) Owns(endpoint AppEndpoint) bool {
+	return f.OrganizationId == endpoint.OrganizationId && f.AppInstanceId == endpoint.AppInstanceId &&
+		f.ServiceGroupInstanceId == endpoint.ServiceGroupInstanceId
+}
+
+// GlobalFqdnCandidates returns the global FQDNs that can be assigned to an endpoint, from the shortest to the longest
+// one. The first candidate uses the default prefix lengths and each subsequent one extends the prefixes until the full
+// identifiers are used. The protocol is the one declared for the endpoint, which may not be supported by the gRPC API.
+func GlobalFqdnCandidates(endpoint *grpc_application_go.AddAppEndpointRequest, protocol AppEndpointProtocol) []string {
+	maxLength := len(endpoint.ServiceGroupInstanceId)
+	if len(endpoint.AppInstanceId) > maxLength {
+		maxLength = len(endpoint.AppInstanceId)
+	}
+	if len(endpoint.OrganizationId) > maxLength {
+		maxLength = len(endpoint.OrganizationId)
+	}
+
+	candidates := make([]string, 0)
+	instLength := InstPrefixLength
+	orgLength := OrgPrefixLength
+	for {
+		candidate := CreateGlobalFqdnWithPrefixes(endpoint, protocol, instLength, orgLength)
+		if len(candidates) == 0 || candidates[len(candidates)-1] != candidate {
+			candidates = append(candidates, candidate)
+		}
+		if instLength >= maxLength && orgLength >= maxLength {
+			return candidates
+		}
+		instLength += globalFqdnPrefixStep
+		orgLength += globalFqdnPrefixStep
+	}
+}
+
+// GlobalFqdnFromPublicFqdn extracts the global FQDN from a public FQDN (serv.A.B.C.ep.domain) under the public host
+// domain.
+func GlobalFqdnFromPublicFqdn(publicFqdn string, publicHostDomain string) (string, derrors.Error) {
+	fqdn := strings.ToLower(strings.TrimSuffix(publicFqdn, "."))
+	suffix := "." + PublicEndpointLabel + "." + strings.ToLower(strings.Trim(publicHostDomain, "."))
+	if publicHostDomain == "" || !strings.HasSuffix(fqdn, suffix) {
+		return "", derrors.NewInvalidArgumentError("fqdn is not under the public host domain").WithParams(publicFqdn, publicHostDomain)
+	}
+	labels := strings.Split(strings.TrimSuffix(fqdn, suffix), ".")
+	if len(labels) != 4 {
+		return "", derrors.NewInvalidArgumentError("fqdn has incorrect format").WithParams(publicFqdn)
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// PublicFqdn returns the public FQDN of a global FQDN under the public host domain.
+func PublicFqdn(globalFqdn string, publicHostDomain string) string {
+	return fmt.Sprintf("%s.%s.%s", globalFqdn, PublicEndpointLabel, strings.Trim(publicHostDomain, "."))
+}
+
+// DeclaredEndpoint returns the endpoint of the exposed ports of a service instance that matches a port and an
+// endpoint type. Nil is returned if the service does not declare it.
+func (i *AppInstance) DeclaredEndpoint(serviceGroupInstanceID string, serviceInstanceID string, port int32, endpointType EndpointType) *Endpoint {
+	for _, group := range i.Groups {
+		if group.ServiceGroupInstanceId != serviceGroupInstanceID {
+			continue
+		}
+		for _, service := range group.ServiceInstances {
+			if service.ServiceInstanceId != serviceInstanceID {
+				continue
+			}
+			for _, exposed := range service.ExposedPorts {
+				if exposed.ExposedPort != port && exposed.InternalPort != port {
+					continue
+				}
+				for index := range exposed.Endpoints {
+					if exposed.Endpoints[index].Type == endpointType {
+						return &exposed.Endpoints[index]
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-application-go"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// InitialDescriptorRevision is the revision assigned to a descriptor when it is added to the system.
+const InitialDescriptorRevision int64 = 1
+
+// -- AppDescriptorRevision -- //
+// AppDescriptorRevision is an immutable snapshot of the contents of an application descriptor. A new revision is
+// created each time the groups, services, rules or parameters of the descriptor change.
+type AppDescriptorRevision struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string `json:"organization_id,omitempty" cql:"organization_id"`
+	// AppDescriptorId with the application descriptor identifier.
+	AppDescriptorId string `json:"app_descriptor_id,omitempty" cql:"app_descriptor_id"`
+	// Revision number.
+	Revision int64 `json:"revision,omitempty" cql:"revision"`
+	// Created with the creation timestamp of the revision.
+	Created int64 `json:"created,omitempty" cql:"created"`
+	// Name of the application.
+	Name string `json:"name,omitempty" cql:"name"`
+	// ConfigurationOptions defines a key-value map of configuration options.
+	ConfigurationOptions map[string]string `json:"configuration_options,omitempty" cql:"configuration_options"`
+	// EnvironmentVariables defines a key-value map of environment variables and values that will be passed to all
+	// running services.
+	EnvironmentVariables map[string]string `json:"environment_variables,omitempty" cql:"environment_variables"`
+	// Rules that define the connectivity between the elements of an application.
+	Rules []SecurityRule `json:"rules,omitempty" cql:"rules"`
+	// Groups with the Service collocation strategies.
+	Groups []ServiceGroup `json:"groups,omitempty" cql:"groups"`
+	// Parameters with the parameters of an application
+	Parameters []Parameter `json:"parameters,omitempty" cql:"parameters"`
+	// InboundNetInterfaces with a list of inbounds
+	InboundNetInterfaces []InboundNetworkInterface `json:"inbound_net_interfaces,omitempty" cql:"inbound_net_interfaces"`
+	// OutboundNetInterfaces with a list of outbounds
+	OutboundNetInterfaces []OutboundNetworkInterface `json:"outbound_net_interfaces,omitempty" cql:"outbound_net_interfaces"`
+}
+
+// NewAppDescriptorRevision creates a snapshot of the current contents of a descriptor.
+func NewAppDescriptorRevision(descriptor AppDescriptor) *AppDescriptorRevision {
+	return &AppDescriptorRevision{
+		OrganizationId:        descriptor.OrganizationId,
+		AppDescriptorId:       descriptor.AppDescriptorId,
+		Revision:              descriptor.Revision,
+		Created:               time.Now().Unix(),
+		Name:                  descriptor.Name,
+		ConfigurationOptions:  descriptor.ConfigurationOptions,
+		EnvironmentVariables:  descriptor.EnvironmentVariables,
+		Rules:                 descriptor.Rules,
+		Groups:                descriptor.Groups,
+		Parameters:            descriptor.Parameters,
+		InboundNetInterfaces:  descriptor.InboundNetInterfaces,
+		OutboundNetInterfaces: descriptor.OutboundNetInterfaces,
+	}
+}
+
+// Revise creates the next revision of a descriptor using the contents of an add request. The descriptor identifier
+// and its labels are preserved; labels are managed through UpdateAppDescriptor.
+func (d *AppDescriptor) Revise(request *grpc_application_go.AddAppDescriptorRequest) (*AppDescriptor, derrors.Error) {
+	if request.OrganizationId != d.OrganizationId {
+		return nil, derrors.NewInvalidArgumentError("organization_id does not match the descriptor").WithParams(request.OrganizationId, d.AppDescriptorId)
+	}
+	revised, err := newAppDescriptorWithIDFromGRPC(d.AppDescriptorId, request)
+	if err != nil {
+		return nil, err
+	}
+	revised.Labels = d.Labels
+	revised.ProjectId = d.ProjectId
+	revised.Revision = d.Revision + 1
+	return revised, nil
+}
+
+// -- AppDescriptorDiff -- //
+
+// DescriptorChangeType defines how an element changed between two revisions.
+type DescriptorChangeType string
+
+const (
+	// ElementAdded is used when an element is only present in the newest revision.
+	ElementAdded DescriptorChangeType = "added"
+	// ElementRemoved is used when an element is only present in the oldest revision.
+	ElementRemoved DescriptorChangeType = "removed"
+	// ElementModified is used when a field of an element has a different value.
+	ElementModified DescriptorChangeType = "modified"
+)
+
+// DescriptorChange with a single difference between two revisions.
+type DescriptorChange struct {
+	// Type of change.
+	Type DescriptorChangeType `json:"type"`
+	// Element with the kind of element that changed (descriptor, group, service, rule, parameter,
+	// inbound_net_interface, outbound_net_interface).
+	Element string `json:"element"`
+	// Path identifying the element by name (e.g., groups/g1/services/mysql).
+	Path string `json:"path"`
+	// Field that changed. Only set for modifications.
+	Field string `json:"field,omitempty"`
+	// OldValue with the JSON representation of the previous value.
+	OldValue string `json:"old_value,omitempty"`
+	// NewValue with the JSON representation of the new value.
+	NewValue string `json:"new_value,omitempty"`
+}
+
+// AppDescriptorDiff with the structured differences between two revisions of a descriptor.
+type AppDescriptorDiff struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string `json:"organization_id,omitempty"`
+	// AppDescriptorId with the application descriptor identifier.
+	AppDescriptorId string `json:"app_descriptor_id,omitempty"`
+	// FromRevision with the base revision.
+	FromRevision int64 `json:"from_revision"`
+	// ToRevision with the compared revision.
+	ToRevision int64 `json:"to_revision"`
+	// Changes found between both revisions.
+	Changes []DescriptorChange `json:"changes"`
+}
+
+// identifierFields contains the fields that are generated by the system and must be ignored when comparing revisions.
+var identifierFields = map[string]bool{
+	"organization_id":   true,
+	"app_descriptor_id": true,
+	"service_group_id":  true,
+	"service_id":        true,
+	"rule_id":           true,
+	"config_file_id":    true,
+	"device_groups":     true,
+}
+
+// toComparable transforms an element into a generic representation without system generated identifiers.
+func toComparable(element interface{}) map[string]interface{} {
+	raw, err := json.Marshal(element)
+	if err != nil {
+		return nil
+	}
+	result := make(map[string]interface{}, 0)
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil
+	}
+	removeIdentifiers(result)
+	return result
+}
+
+func removeIdentifiers(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range v {
+			if identifierFields[key] {
+				delete(v, key)
+			} else {
+				removeIdentifiers(inner)
+			}
+		}
+	case []interface{}:
+		for _, inner := range v {
+			removeIdentifiers(inner)
+		}
+	}
+}
+
+func toJSONString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(raw)
+}
+
+// descriptorDiffer accumulates the changes found between two revisions.
+type descriptorDiffer struct {
+	changes []DescriptorChange
+}
+
+// compareFields compares two elements field by field ignoring the identifiers and the skipped fields.
+func (dd *descriptorDiffer) compareFields(element string, path string, oldElement interface{}, newElement interface{}, skip ...string) {
+	oldFields := toComparable(oldElement)
+	newFields := toComparable(newElement)
+	for _, field := range skip {
+		delete(oldFields, field)
+		delete(newFields, field)
+	}
+	keys := make(map[string]bool, 0)
+	for k := range oldFields {
+		keys[k] = true
+	}
+	for k := range newFields {
+		keys[k] = true
+	}
+	sortedKeys := make([]string, 0, len(keys))
+	for k := range keys {
+		sortedKeys = append(sortedKeys, k)
+	}
+	sort.Strings(sortedKeys)
+	for _, field := range sortedKeys {
+		if !reflect.DeepEqual(oldFields[field], newFields[field]) {
+			dd.changes = append(dd.changes, DescriptorChange{
+				Type:     ElementModified,
+				Element:  element,
+				Path:     path,
+				Field:    field,
+				OldValue: toJSONString(oldFields[field]),
+				NewValue: toJSONString(newFields[field]),
+			})
+		}
+	}
+}
+
+// compareNamed compares two lists of elements identified by name. The compare function is called for
+// elements present in both lists.
+func (dd *descriptorDiffer) compareNamed(element string, basePath string, oldNames []string, newNames []string,
+	compare func(name string, path string)) {
+	oldSet := make(map[string]bool, len(oldNames))
+	for _, name := range oldNames {
+		oldSet[name] = true
+	}
+	newSet := make(map[string]bool, len(newNames))
+	for _, name := range newNames {
+		newSet[name] = true
+	}
+	for _, name := range oldNames {
+		path := fmt.Sprintf("%s/%s", basePath, name)
+		if !newSet[name] {
+			dd.changes = append(dd.changes, DescriptorChange{Type: ElementRemoved, Element: element, Path: path})
+		} else {
+			compare(name, path)
+		}
+	}
+	for _, name := range newNames {
+		if !oldSet[name] {
+			dd.changes = append(dd.changes, DescriptorChange{Type: ElementAdded, Element: element, Path: fmt.Sprintf("%s/%s", basePath, name)})
+		}
+	}
+}
+
+// NewAppDescriptorDiff compares two revisions of a descriptor.
+func NewAppDescriptorDiff(from AppDescriptorRevision, to AppDescriptorRevision) *AppDescriptorDiff {
+	dd := &descriptorDiffer{changes: make([]DescriptorChange, 0)}
+
+	dd.compareFields("descriptor", "", from, to, "revision", "created", "rules", "groups", "parameters",
+		"inbound_net_interfaces", "outbound_net_interfaces")
+
+	// Groups and services
+	oldGroups := make(map[string]ServiceGroup, len(from.Groups))
+	oldGroupNames := make([]string, 0, len(from.Groups))
+	for _, g := range from.Groups {
+		oldGroups[g.Name] = g
+		oldGroupNames = append(oldGroupNames, g.Name)
+	}
+	newGroups := make(map[string]ServiceGroup, len(to.Groups))
+	newGroupNames := make([]string, 0, len(to.Groups))
+	for _, g := range to.Groups {
+		newGroups[g.Name] = g
+		newGroupNames = append(newGroupNames, g.Name)
+	}
+	dd.compareNamed("group", "groups", oldGroupNames, newGroupNames, func(name string, path string) {
+		oldGroup := oldGroups[name]
+		newGroup := newGroups[name]
+		dd.compareFields("group", path, oldGroup, newGroup, "services")
+
+		oldServices := make(map[string]Service, len(oldGroup.Services))
+		oldServiceNames := make([]string, 0, len(oldGroup.Services))
+		for _, s := range oldGroup.Services {
+			oldServices[s.Name] = s
+			oldServiceNames = append(oldServiceNames, s.Name)
+		}
+		newServices := make(map[string]Service, len(newGroup.Services))
+		newServiceNames := make([]string, 0, len(newGroup.Services))
+		for _, s := range newGroup.Services {
+			newServices[s.Name] = s
+			newServiceNames = append(newServiceNames, s.Name)
+		}
+		dd.compareNamed("service", path+"/services", oldServiceNames, newServiceNames, func(name string, path string) {
+			dd.compareFields("service", path, oldServices[name], newServices[name])
+		})
+	})
+
+	// Rules
+	oldRules := make(map[string]SecurityRule, len(from.Rules))
+	oldRuleNames := make([]string, 0, len(from.Rules))
+	for _, r := range from.Rules {
+		oldRules[r.Name] = r
+		oldRuleNames = append(oldRuleNames, r.Name)
+	}
+	newRules := make(map[string]SecurityRule, len(to.Rules))
+	newRuleNames := make([]string, 0, len(to.Rules))
+	for _, r := range to.Rules {
+		newRules[r.Name] = r
+		newRuleNames = append(newRuleNames, r.Name)
+	}
+	dd.compareNamed("rule", "rules", oldRuleNames, newRuleNames, func(name string, path string) {
+		dd.compareFields("rule", path, oldRules[name], newRules[name])
+	})
+
+	// Parameters
+	oldParams := make(map[string]Parameter, len(from.Parameters))
+	oldParamNames := make([]string, 0, len(from.Parameters))
+	for _, p := range from.Parameters {
+		oldParams[p.Name] = p
+		oldParamNames = append(oldParamNames, p.Name)
+	}
+	newParams := make(map[string]Parameter, len(to.Parameters))
+	newParamNames := make([]string, 0, len(to.Parameters))
+	for _, p := range to.Parameters {
+		newParams[p.Name] = p
+		newParamNames = append(newParamNames, p.Name)
+	}
+	dd.compareNamed("parameter", "parameters", oldParamNames, newParamNames, func(name string, path string) {
+		dd.compareFields("parameter", path, oldParams[name], newParams[name])
+	})
+
+	// Network interfaces
+	oldInbounds := make([]string, 0, len(from.InboundNetInterfaces))
+	for _, i := range from.InboundNetInterfaces {
+		oldInbounds = append(oldInbounds, i.Name)
+	}
+	newInbounds := make([]string, 0, len(to.InboundNetInterfaces))
+	for _, i := range to.InboundNetInterfaces {
+		newInbounds = append(newInbounds, i.Name)
+	}
+	dd.compareNamed("inbound_net_interface", "inbound_net_interfaces", oldInbounds, newInbounds, func(name string, path string) {})
+
+	oldOutbounds := make(map[string]OutboundNetworkInterface, len(from.OutboundNetInterfaces))
+	oldOutboundNames := make([]string, 0, len(from.OutboundNetInterfaces))
+	for _, o := range from.OutboundNetInterfaces {
+		oldOutbounds[o.Name] = o
+		oldOutboundNames = append(oldOutboundNames, o.Name)
+	}
+	newOutbounds := make(map[string]OutboundNetworkInterface, len(to.OutboundNetInterfaces))
+	newOutboundNames := make([]string, 0, len(to.OutboundNetInterfaces))
+	for _, o := range to.OutboundNetInterfaces {
+		newOutbounds[o.Name] = o
+		newOutboundNames = append(newOutboundNames, o.Name)
+	}
+	dd.compareNamed("outbound_net_interface", "outbound_net_interfaces", oldOutboundNames, newOutboundNames, func(name string, path string) {
+		dd.compareFields("outbound_net_interface", path, oldOutbounds[name], newOutbounds[name])
+	})
+
+	return &AppDescriptorDiff{
+		OrganizationId:  to.OrganizationId,
+		AppDescriptorId: to.AppDescriptorId,
+		FromRevision:    from.Revision,
+		ToRevision:      to.Revision,
+		Changes:         dd.changes,
+	}
+}
+
+// ValidRevision checks that a revision number is valid.
+func ValidRevision(revision int64) derrors.Error {
+	if revision < InitialDescriptorRevision {
+		return derrors.NewInvalidArgumentError(invalidRevision).WithParams(revision)
+	}
+	return nil
+}
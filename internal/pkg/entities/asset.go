@@ -88,6 +88,8 @@ type Asset struct {
 	LastAliveTimestamp int64 `json:"last_alive_timestamp,omitempty" cql:"last_alive_timestamp"`
 	// Location contains the location of the asset
 	Location *InventoryLocation `json:"location,omitempty"`
+	// Version of the asset, increased on every update.
+	Version int64 `json:"version,omitempty"`
 }
 
 func NewAssetFromGRPC(addRequest *grpc_inventory_go.AddAssetRequest) *Asset {
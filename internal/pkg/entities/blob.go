@@ -0,0 +1,76 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"github.com/nalej/derrors"
+	"net/http"
+	"time"
+)
+
+// MaxPhotoSize with the maximum size in bytes of the photos of the organizations and users.
+const MaxPhotoSize = 1024 * 1024
+
+// ThumbnailMaxSide with the maximum width and height in pixels of the thumbnails of the photos.
+const ThumbnailMaxSide = 64
+
+// PhotoContentTypes with the content types accepted for the photos.
+var PhotoContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+}
+
+// Blob with binary content, such as an image, kept in a blob store.
+type Blob struct {
+	// BlobId with the blob identifier.
+	BlobId string `json:"blob_id,omitempty" cql:"blob_id"`
+	// ContentType of the data.
+	ContentType string `json:"content_type,omitempty" cql:"content_type"`
+	// Size of the data in bytes.
+	Size int64 `json:"size,omitempty" cql:"size"`
+	// Created with the creation timestamp.
+	Created int64 `json:"created,omitempty" cql:"created"`
+	// Data with the content, stored apart from the metadata.
+	Data []byte `json:"-" cql:"-"`
+}
+
+// NewBlob creates a blob with some content.
+func NewBlob(contentType string, data []byte) *Blob {
+	return &Blob{
+		BlobId:      GenerateUUID(),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Created:     time.Now().Unix(),
+		Data:        data,
+	}
+}
+
+// ValidPhoto checks the size and the content type of a photo, detected from its content. It returns the content type.
+func ValidPhoto(data []byte) (string, derrors.Error) {
+	if len(data) == 0 {
+		return "", derrors.NewInvalidArgumentError("photo cannot be empty")
+	}
+	if len(data) > MaxPhotoSize {
+		return "", derrors.NewInvalidArgumentError("photo is too large").WithParams(len(data), MaxPhotoSize)
+	}
+	contentType := http.DetectContentType(data)
+	if !PhotoContentTypes[contentType] {
+		return "", derrors.NewInvalidArgumentError("unsupported photo content type").WithParams(contentType)
+	}
+	return contentType, nil
+}
@@ -0,0 +1,312 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-application-go"
+	"strings"
+	"time"
+)
+
+// CatalogEntryLabel is added to the descriptors instantiated from the catalog with the identifier of the entry.
+const CatalogEntryLabel = "nalej-catalog-entry"
+
+// CatalogVersionLabel is added to the descriptors instantiated from the catalog with the version of the entry.
+const CatalogVersionLabel = "nalej-catalog-version"
+
+// CatalogVisibility defines which organizations can see a catalog entry.
+type CatalogVisibility int32
+
+const (
+	// CatalogVisibility_All makes the entry visible to every organization.
+	CatalogVisibility_All CatalogVisibility = iota + 1
+	// CatalogVisibility_Selected makes the entry visible to the publisher and the selected organizations.
+	CatalogVisibility_Selected
+)
+
+var CatalogVisibilityToString = map[CatalogVisibility]string{
+	CatalogVisibility_All:      "ALL",
+	CatalogVisibility_Selected: "SELECTED",
+}
+
+var CatalogVisibilityFromString = map[string]CatalogVisibility{
+	"ALL":      CatalogVisibility_All,
+	"SELECTED": CatalogVisibility_Selected,
+}
+
+// CatalogEntry is a published version of an application descriptor that other organizations can instantiate.
+type CatalogEntry struct {
+	// CatalogEntryId with the identifier shared by all the versions of the entry.
+	CatalogEntryId string `json:"catalog_entry_id,omitempty" cql:"catalog_entry_id"`
+	// Version of the entry.
+	Version string `json:"version,omitempty" cql:"version"`
+	// PublisherOrganizationId with the organization that published the entry.
+	PublisherOrganizationId string `json:"publisher_organization_id,omitempty" cql:"publisher_organization_id"`
+	// SourceAppDescriptorId with the descriptor used to create the entry.
+	SourceAppDescriptorId string `json:"source_app_descriptor_id,omitempty" cql:"source_app_descriptor_id"`
+	// SourceRevision with the revision of the descriptor used to create the entry.
+	SourceRevision int64 `json:"source_revision,omitempty" cql:"source_revision"`
+	// Published with the publication timestamp.
+	Published int64 `json:"published,omitempty" cql:"published"`
+	// Name of the application.
+	Name string `json:"name,omitempty" cql:"name"`
+	// Description of the application.
+	Description string `json:"description,omitempty" cql:"description"`
+	// Logo with the URL of the logo of the application.
+	Logo string `json:"logo,omitempty" cql:"logo"`
+	// Category of the application.
+	Category string `json:"category,omitempty" cql:"category"`
+	// Visibility of the entry.
+	Visibility CatalogVisibility `json:"visibility,omitempty" cql:"visibility"`
+	// VisibleOrganizations with the organizations that can see the entry when the visibility is CatalogVisibility_Selected.
+	VisibleOrganizations []string `json:"visible_organizations,omitempty" cql:"visible_organizations"`
+	// ConfigurationOptions defines a key-value map of configuration options.
+	ConfigurationOptions map[string]string `json:"configuration_options,omitempty" cql:"configuration_options"`
+	// EnvironmentVariables defines a key-value map of environment variables and values that will be passed to all
+	// running services.
+	EnvironmentVariables map[string]string `json:"environment_variables,omitempty" cql:"environment_variables"`
+	// Labels of the application.
+	Labels map[string]string `json:"labels,omitempty" cql:"labels"`
+	// Rules that define the connectivity between the elements of an application.
+	Rules []SecurityRule `json:"rules,omitempty" cql:"rules"`
+	// Groups with the Service collocation strategies.
+	Groups []ServiceGroup `json:"groups,omitempty" cql:"groups"`
+	// Parameters with the parameters of an application
+	Parameters []Parameter `json:"parameters,omitempty" cql:"parameters"`
+	// InboundNetInterfaces with a list of inbounds
+	InboundNetInterfaces []InboundNetworkInterface `json:"inbound_net_interfaces,omitempty" cql:"inbound_net_interfaces"`
+	// OutboundNetInterfaces with a list of outbounds
+	OutboundNetInterfaces []OutboundNetworkInterface `json:"outbound_net_interfaces,omitempty" cql:"outbound_net_interfaces"`
+}
+
+// PublishCatalogEntryRequest with the information required to publish a descriptor in the catalog.
+type PublishCatalogEntryRequest struct {
+	// OrganizationId with the publisher organization.
+	OrganizationId string
+	// AppDescriptorId with the descriptor to be published.
+	AppDescriptorId string
+	// CatalogEntryId with the entry receiving a new version. A new entry is created if empty.
+	CatalogEntryId string
+	// Version of the entry.
+	Version string
+	// Description of the application.
+	Description string
+	// Logo with the URL of the logo of the application.
+	Logo string
+	// Category of the application.
+	Category string
+	// Visibility of the entry.
+	Visibility CatalogVisibility
+	// VisibleOrganizations with the organizations that can see the entry when the visibility is CatalogVisibility_Selected.
+	VisibleOrganizations []string
+}
+
+// CatalogSearchRequest with the filters used to search the catalog. Empty filters match all the entries.
+type CatalogSearchRequest struct {
+	// OrganizationId with the organization performing the search.
+	OrganizationId string
+	// Query with a text to be found in the name, description or labels of the entry.
+	Query string
+	// Category of the entry.
+	Category string
+}
+
+// InstantiateCatalogEntryRequest with the information required to copy a catalog entry into an organization.
+type InstantiateCatalogEntryRequest struct {
+	// OrganizationId with the organization receiving the descriptor.
+	OrganizationId string
+	// CatalogEntryId with the entry to be instantiated.
+	CatalogEntryId string
+	// Version of the entry. The latest version is used if empty.
+	Version string
+	// Name of the new descriptor. The name of the entry is used if empty.
+	Name string
+}
+
+// NewCatalogEntry creates a catalog entry with a snapshot of the contents of a descriptor. Image credentials are
+// not published as they belong to the publisher organization.
+func NewCatalogEntry(request PublishCatalogEntryRequest, descriptor AppDescriptor) *CatalogEntry {
+	entryID := request.CatalogEntryId
+	if entryID == "" {
+		entryID = GenerateUUID()
+	}
+
+	groups := make([]ServiceGroup, 0, len(descriptor.Groups))
+	for _, g := range descriptor.Groups {
+		services := make([]Service, 0, len(g.Services))
+		for _, s := range g.Services {
+			s.Credentials = nil
+			services = append(services, s)
+		}
+		g.Services = services
+		groups = append(groups, g)
+	}
+
+	return &CatalogEntry{
+		CatalogEntryId:          entryID,
+		Version:                 request.Version,
+		PublisherOrganizationId: descriptor.OrganizationId,
+		SourceAppDescriptorId:   descriptor.AppDescriptorId,
+		SourceRevision:          descriptor.Revision,
+		Published:               time.Now().Unix(),
+		Name:                    descriptor.Name,
+		Description:             request.Description,
+		Logo:                    request.Logo,
+		Category:                request.Category,
+		Visibility:              request.Visibility,
+		VisibleOrganizations:    request.VisibleOrganizations,
+		ConfigurationOptions:    descriptor.ConfigurationOptions,
+		EnvironmentVariables:    descriptor.EnvironmentVariables,
+		Labels:                  descriptor.Labels,
+		Rules:                   descriptor.Rules,
+		Groups:                  groups,
+		Parameters:              descriptor.Parameters,
+		InboundNetInterfaces:    descriptor.InboundNetInterfaces,
+		OutboundNetInterfaces:   descriptor.OutboundNetInterfaces,
+	}
+}
+
+// VisibleTo checks if an organization can see the entry.
+func (e *CatalogEntry) VisibleTo(organizationID string) bool {
+	if e.Visibility == CatalogVisibility_All || e.PublisherOrganizationId == organizationID {
+		return true
+	}
+	for _, org := range e.VisibleOrganizations {
+		if org == organizationID {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches checks if the entry satisfies the filters of a search. The comparison is case insensitive.
+func (e *CatalogEntry) Matches(search CatalogSearchRequest) bool {
+	if search.Category != "" && !strings.EqualFold(search.Category, e.Category) {
+		return false
+	}
+	if search.Query == "" {
+		return true
+	}
+	query := strings.ToLower(search.Query)
+	if strings.Contains(strings.ToLower(e.Name), query) || strings.Contains(strings.ToLower(e.Description), query) {
+		return true
+	}
+	for key, value := range e.Labels {
+		if strings.Contains(strings.ToLower(key), query) || strings.Contains(strings.ToLower(value), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNewerThan checks if the entry was published after another version of the same entry.
+func (e *CatalogEntry) IsNewerThan(other CatalogEntry) bool {
+	if e.Published == other.Published {
+		return e.Version > other.Version
+	}
+	return e.Published > other.Published
+}
+
+// ToAddAppDescriptorRequest creates the request to add the contents of the entry as a new descriptor of an
+// organization. The descriptor is labeled with the entry and version it comes from.
+func (e *CatalogEntry) ToAddAppDescriptorRequest(requestID string, organizationID string, name string) *grpc_application_go.AddAppDescriptorRequest {
+	if name == "" {
+		name = e.Name
+	}
+
+	labels := make(map[string]string, len(e.Labels)+2)
+	for key, value := range e.Labels {
+		labels[key] = value
+	}
+	labels[CatalogEntryLabel] = e.CatalogEntryId
+	labels[CatalogVersionLabel] = e.Version
+
+	rules := make([]*grpc_application_go.SecurityRule, 0)
+	for _, r := range e.Rules {
+		rules = append(rules, r.ToGRPC())
+	}
+	groups := make([]*grpc_application_go.ServiceGroup, 0)
+	for _, g := range e.Groups {
+		groups = append(groups, g.ToGRPC())
+	}
+	parameters := make([]*grpc_application_go.AppParameter, 0)
+	for _, param := range e.Parameters {
+		parameters = append(parameters, param.ToGRPC())
+	}
+	inbounds := make([]*grpc_application_go.InboundNetworkInterface, 0)
+	for _, inbound := range e.InboundNetInterfaces {
+		inbounds = append(inbounds, inbound.ToGRPC())
+	}
+	outbounds := make([]*grpc_application_go.OutboundNetworkInterface, 0)
+	for _, outbound := range e.OutboundNetInterfaces {
+		outbounds = append(outbounds, outbound.ToGRPC())
+	}
+
+	return &grpc_application_go.AddAppDescriptorRequest{
+		RequestId:             requestID,
+		OrganizationId:        organizationID,
+		Name:                  name,
+		ConfigurationOptions:  e.ConfigurationOptions,
+		EnvironmentVariables:  e.EnvironmentVariables,
+		Labels:                labels,
+		Rules:                 rules,
+		Groups:                groups,
+		Parameters:            parameters,
+		InboundNetInterfaces:  inbounds,
+		OutboundNetInterfaces: outbounds,
+	}
+}
+
+func ValidPublishCatalogEntryRequest(request PublishCatalogEntryRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.AppDescriptorId == "" {
+		return derrors.NewInvalidArgumentError(emptyAppDescriptorId)
+	}
+	if request.Version == "" {
+		return derrors.NewInvalidArgumentError(emptyVersion)
+	}
+	switch request.Visibility {
+	case CatalogVisibility_All:
+	case CatalogVisibility_Selected:
+		if len(request.VisibleOrganizations) == 0 {
+			return derrors.NewInvalidArgumentError("visible_organizations cannot be empty when the visibility is SELECTED")
+		}
+	default:
+		return derrors.NewInvalidArgumentError("invalid visibility").WithParams(request.Visibility)
+	}
+	return nil
+}
+
+func ValidCatalogSearchRequest(request CatalogSearchRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	return nil
+}
+
+func ValidInstantiateCatalogEntryRequest(request InstantiateCatalogEntryRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.CatalogEntryId == "" {
+		return derrors.NewInvalidArgumentError(emptyCatalogEntryId)
+	}
+	return nil
+}
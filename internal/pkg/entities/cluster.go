@@ -214,6 +214,10 @@ type Cluster struct {
 	MillicoresConversionFactor float64 `json:"millicores_conversion_factor,omitempty"`
 	// State of the cluster with respect to provisioning and installation.
 	State ClusterState `json:"cluster_state,omitempty"`
+	// Version of the cluster, increased on every update.
+	Version int64 `json:"version,omitempty"`
+	// ProjectId with the project of the account of the organization the cluster belongs to, if any.
+	ProjectId string `json:"project_id,omitempty"`
 }
 
 // The cluster watcher contains information to ensure the connectivity between clusters. This data
@@ -0,0 +1,178 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"github.com/nalej/derrors"
+)
+
+// ConnectionStatusPolicy defines how the status of a connection is derived from the status of its links.
+type ConnectionStatusPolicy string
+
+const (
+	// ConnectionStatusPolicyAll considers a connection established when all its links are established.
+	ConnectionStatusPolicyAll ConnectionStatusPolicy = "all"
+	// ConnectionStatusPolicyMajority considers a connection established when most of its links are established.
+	ConnectionStatusPolicyMajority ConnectionStatusPolicy = "majority"
+	// ConnectionStatusPolicyAny considers a connection established when any of its links is established.
+	ConnectionStatusPolicyAny ConnectionStatusPolicy = "any"
+)
+
+// DefaultConnectionStatusPolicy with the policy used when none is configured.
+const DefaultConnectionStatusPolicy = ConnectionStatusPolicyAll
+
+var ConnectionStatusToString = map[ConnectionStatus]string{
+	ConnectionStatusWaiting:     "WAITING",
+	ConnectionStatusEstablished: "ESTABLISHED",
+	ConnectionStatusTerminated:  "TERMINATED",
+	ConnectionStatusFailed:      "FAILED",
+}
+
+// ValidConnectionStatusPolicy checks that a policy is supported.
+func ValidConnectionStatusPolicy(policy ConnectionStatusPolicy) derrors.Error {
+	switch policy {
+	case ConnectionStatusPolicyAll, ConnectionStatusPolicyMajority, ConnectionStatusPolicyAny:
+		return nil
+	}
+	return derrors.NewInvalidArgumentError("unsupported connection status policy").WithParams(policy)
+}
+
+// required returns the number of established links required to consider a connection established.
+func (p ConnectionStatusPolicy) required(activeLinks int) int {
+	switch p {
+	case ConnectionStatusPolicyAny:
+		return 1
+	case ConnectionStatusPolicyMajority:
+		return activeLinks/2 + 1
+	}
+	return activeLinks
+}
+
+// AggregateConnectionStatus derives the status of a connection from the status of its links. Terminated links are
+// not taken into account unless all the links are terminated. A connection is established when the policy is
+// satisfied, failed when the policy can no longer be satisfied by the links that are still waiting, and waiting
+// otherwise. The second value is false if there are no links to derive the status from.
+func AggregateConnectionStatus(policy ConnectionStatusPolicy, links []ConnectionInstanceLink) (ConnectionStatus, bool) {
+	if len(links) == 0 {
+		return ConnectionStatusWaiting, false
+	}
+	established, waiting, active := 0, 0, 0
+	for _, link := range links {
+		switch link.Status {
+		case ConnectionStatusTerminated:
+			continue
+		case ConnectionStatusEstablished:
+			established++
+		case ConnectionStatusFailed:
+		default:
+			waiting++
+		}
+		active++
+	}
+	if active == 0 {
+		return ConnectionStatusTerminated, true
+	}
+	required := policy.required(active)
+	if established >= required {
+		return ConnectionStatusEstablished, true
+	}
+	if established+waiting < required {
+		return ConnectionStatusFailed, true
+	}
+	return ConnectionStatusWaiting, true
+}
+
+// ConnectionHealth with the summary of the status of a connection and its links.
+type ConnectionHealth struct {
+	// OrganizationId with the organization identifier
+	OrganizationId string `json:"organization_id,omitempty"`
+	// ConnectionId with the connection identifier
+	ConnectionId string `json:"connection_id,omitempty"`
+	// SourceInstanceId with the instance identifier of the connection source
+	SourceInstanceId string `json:"source_instance_id,omitempty"`
+	// SourceInstanceName with the instance name of the connection source
+	SourceInstanceName string `json:"source_instance_name,omitempty"`
+	// TargetInstanceId with the instance identifier of the connection target
+	TargetInstanceId string `json:"target_instance_id,omitempty"`
+	// TargetInstanceName with the instance name of the connection target
+	TargetInstanceName string `json:"target_instance_name,omitempty"`
+	// InboundName with the name of the inbound network interface
+	InboundName string `json:"inbound_name,omitempty"`
+	// OutboundName with the name of the outbound network interface
+	OutboundName string `json:"outbound_name,omitempty"`
+	// Status with the stored status of the connection
+	Status ConnectionStatus `json:"status,omitempty"`
+	// AggregatedStatus with the status derived from the links
+	AggregatedStatus ConnectionStatus `json:"aggregated_status,omitempty"`
+	// Policy used to derive the status
+	Policy ConnectionStatusPolicy `json:"policy,omitempty"`
+	// Links with the number of links of the connection
+	Links int `json:"links"`
+	// Established with the number of established links
+	Established int `json:"established"`
+	// Waiting with the number of waiting links
+	Waiting int `json:"waiting"`
+	// Failed with the number of failed links
+	Failed int `json:"failed"`
+	// Terminated with the number of terminated links
+	Terminated int `json:"terminated"`
+	// UnhealthyLinks with the links that are not established
+	UnhealthyLinks []ConnectionInstanceLink `json:"unhealthy_links,omitempty"`
+}
+
+// NewConnectionHealth builds the health summary of a connection. The aggregated status of a connection without
+// links is its stored status.
+func NewConnectionHealth(connection ConnectionInstance, links []ConnectionInstanceLink, policy ConnectionStatusPolicy) *ConnectionHealth {
+	health := &ConnectionHealth{
+		OrganizationId:     connection.OrganizationId,
+		ConnectionId:       connection.ConnectionId,
+		SourceInstanceId:   connection.SourceInstanceId,
+		SourceInstanceName: connection.SourceInstanceName,
+		TargetInstanceId:   connection.TargetInstanceId,
+		TargetInstanceName: connection.TargetInstanceName,
+		InboundName:        connection.InboundName,
+		OutboundName:       connection.OutboundName,
+		Status:             connection.Status,
+		AggregatedStatus:   connection.Status,
+		Policy:             policy,
+		Links:              len(links),
+		UnhealthyLinks:     make([]ConnectionInstanceLink, 0),
+	}
+	for _, link := range links {
+		switch link.Status {
+		case ConnectionStatusEstablished:
+			health.Established++
+			continue
+		case ConnectionStatusFailed:
+			health.Failed++
+		case ConnectionStatusTerminated:
+			health.Terminated++
+		default:
+			health.Waiting++
+		}
+		health.UnhealthyLinks = append(health.UnhealthyLinks, link)
+	}
+	if status, derived := AggregateConnectionStatus(policy, links); derived {
+		health.AggregatedStatus = status
+	}
+	return health
+}
+
+// Consistent checks if the stored status of the connection matches the status derived from its links.
+func (h *ConnectionHealth) Consistent() bool {
+	return h.Status == h.AggregatedStatus
+}
@@ -37,6 +37,8 @@ type Device struct {
 	Hardware       *entities.HardwareInfo          `json:"hardware,omitempty" cql:"hardware"`
 	Storage        []*entities.StorageHardwareInfo `json:"storage,omitempty" cql:"storage"`
 	Location       *entities.InventoryLocation     `json:"location,omitempty" cql:"location"`
+	// Version of the device, increased on every update.
+	Version int64 `json:"version,omitempty" cql:"version"`
 }
 
 type DeviceGroup struct {
@@ -45,6 +47,8 @@ type DeviceGroup struct {
 	Name           string
 	Created        int64
 	Labels         map[string]string
+	// ProjectId with the project of the account of the organization the group belongs to, if any.
+	ProjectId string
 }
 
 func NewDeviceGroup(organizationID string, deviceGroupID string, name string, labels map[string]string) *DeviceGroup {
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import "time"
+
+// SystemZoneName is the name used to store the serial of the zone with the endpoints of all the organizations.
+const SystemZoneName = "system"
+
+// DNSZoneSerial with the serial of the last exported version of a DNS zone.
+type DNSZoneSerial struct {
+	// ZoneName with the organization identifier or SystemZoneName.
+	ZoneName string `json:"zone_name,omitempty" cql:"zone_name"`
+	// Serial of the zone.
+	Serial int64 `json:"serial,omitempty" cql:"serial"`
+	// Checksum of the records of the zone.
+	Checksum string `json:"checksum,omitempty" cql:"checksum"`
+	// Updated with the timestamp of the last serial change.
+	Updated int64 `json:"updated,omitempty" cql:"updated"`
+}
+
+// NewDNSZoneSerial creates the first serial of a zone.
+func NewDNSZoneSerial(zoneName string, checksum string) *DNSZoneSerial {
+	return &DNSZoneSerial{
+		ZoneName: zoneName,
+		Serial:   1,
+		Checksum: checksum,
+		Updated:  time.Now().Unix(),
+	}
+}
+
+// Next returns the serial of a zone with the given checksum. The serial is increased only if the records changed.
+func (s *DNSZoneSerial) Next(checksum string) *DNSZoneSerial {
+	if s.Checksum == checksum {
+		return s
+	}
+	return &DNSZoneSerial{
+		ZoneName: s.ZoneName,
+		Serial:   s.Serial + 1,
+		Checksum: checksum,
+		Updated:  time.Now().Unix(),
+	}
+}
@@ -109,6 +109,8 @@ type EdgeController struct {
 	Os           *OperatingSystemInfo   `json:"os,omitempty" cql:"os"`
 	Hardware     *HardwareInfo          `json:"hardware,omitempty" cql:"hardware"`
 	Storage      []*StorageHardwareInfo `json:"storage,omitempty" cql:"storage"`
+	// Version of the edge controller, increased on every update.
+	Version int64 `json:"version,omitempty" cql:"version"`
 }
 
 func NewEdgeControllerFromGRPC(eic *grpc_inventory_go.AddEdgeControllerRequest) *EdgeController {
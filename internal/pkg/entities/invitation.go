@@ -0,0 +1,163 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/nalej/derrors"
+	"strings"
+	"time"
+)
+
+// InvitationTokenPrefix with the prefix of the invitation tokens issued by the system model.
+const InvitationTokenPrefix = "nsi"
+
+// DefaultInvitationTTL with the time an invitation can be accepted if no expiration is set.
+const DefaultInvitationTTL = 7 * 24 * time.Hour
+
+// InvitationTTLSettingKey with the key of the organization setting that replaces DefaultInvitationTTL.
+const InvitationTTLSettingKey = "invitation.ttl"
+
+func init() {
+	mustRegisterSettingSchema(SettingSchema{
+		Key:         InvitationTTLSettingKey,
+		Type:        DurationSetting,
+		Default:     DefaultInvitationTTL.String(),
+		Description: "Time an invitation can be accepted if no expiration is set",
+		Editable:    true,
+	})
+}
+
+// ParseInvitationTTL parses the value of the invitation TTL setting.
+func ParseInvitationTTL(value string) (time.Duration, derrors.Error) {
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return 0, derrors.NewInvalidArgumentError("invalid invitation ttl").WithParams(value)
+	}
+	return ttl, nil
+}
+
+// invitationSecretLength with the number of random bytes of the secret of an invitation token.
+const invitationSecretLength = 32
+
+// InvitationStatus with the status of an invitation.
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationRevoked  InvitationStatus = "revoked"
+	InvitationExpired  InvitationStatus = "expired"
+)
+
+// Invitation for an email to become a member of an organization with a given role. Only the hash of the secret is
+// stored, the plain token is returned once when the invitation is created and can be used a single time.
+type Invitation struct {
+	// OrganizationId with the organization the email is invited to.
+	OrganizationId string `json:"organization_id,omitempty" cql:"organization_id"`
+	// InvitationId with the invitation identifier, part of the plain token.
+	InvitationId string `json:"invitation_id,omitempty" cql:"invitation_id"`
+	// Email of the invited user.
+	Email string `json:"email,omitempty" cql:"email"`
+	// RoleId with the role assigned to the user when the invitation is accepted.
+	RoleId string `json:"role_id,omitempty" cql:"role_id"`
+	// InvitedBy with the email of the user that created the invitation.
+	InvitedBy string `json:"invited_by,omitempty" cql:"invited_by"`
+	// Hash with the SHA-256 of the secret.
+	Hash string `json:"-" cql:"hash"`
+	// Created with the creation timestamp.
+	Created int64 `json:"created,omitempty" cql:"created"`
+	// ExpiresAt with the expiration timestamp.
+	ExpiresAt int64 `json:"expires_at,omitempty" cql:"expires_at"`
+	// AcceptedAt with the acceptance timestamp, 0 if the invitation has not been accepted.
+	AcceptedAt int64 `json:"accepted_at,omitempty" cql:"accepted_at"`
+	// RevokedAt with the revocation timestamp, 0 if the invitation is not revoked.
+	RevokedAt int64 `json:"revoked_at,omitempty" cql:"revoked_at"`
+}
+
+// NewInvitation creates an invitation. If no expiration is set, the invitation expires after DefaultInvitationTTL. It
+// returns the invitation and the plain token to be sent to the invited email.
+func NewInvitation(organizationID string, email string, roleID string, invitedBy string, expiresAt int64) (*Invitation, string, derrors.Error) {
+	secret := make([]byte, invitationSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", derrors.AsError(err, "cannot generate invitation secret")
+	}
+	encoded := hex.EncodeToString(secret)
+	now := time.Now()
+	if expiresAt == 0 {
+		expiresAt = now.Add(DefaultInvitationTTL).Unix()
+	}
+	invitation := &Invitation{
+		OrganizationId: organizationID,
+		InvitationId:   GenerateUUID(),
+		Email:          email,
+		RoleId:         roleID,
+		InvitedBy:      invitedBy,
+		Hash:           HashAPIKeySecret(encoded),
+		Created:        now.Unix(),
+		ExpiresAt:      expiresAt,
+	}
+	return invitation, fmt.Sprintf("%s_%s_%s", InvitationTokenPrefix, invitation.InvitationId, encoded), nil
+}
+
+// ParseInvitationToken splits a plain token into the invitation identifier and the secret.
+func ParseInvitationToken(token string) (string, string, derrors.Error) {
+	parts := strings.Split(token, "_")
+	if len(parts) != 3 || parts[0] != InvitationTokenPrefix || parts[1] == "" || parts[2] == "" {
+		return "", "", derrors.NewInvalidArgumentError("malformed invitation token")
+	}
+	return parts[1], parts[2], nil
+}
+
+// MatchesSecret checks in constant time if a secret corresponds to the invitation.
+func (i *Invitation) MatchesSecret(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(i.Hash), []byte(HashAPIKeySecret(secret))) == 1
+}
+
+// Status returns the status of the invitation at a given time.
+func (i *Invitation) Status(now int64) InvitationStatus {
+	if i.AcceptedAt != 0 {
+		return InvitationAccepted
+	}
+	if i.RevokedAt != 0 {
+		return InvitationRevoked
+	}
+	if now >= i.ExpiresAt {
+		return InvitationExpired
+	}
+	return InvitationPending
+}
+
+// ValidAddInvitation checks the information required to create an invitation.
+func ValidAddInvitation(organizationID string, email string, roleID string, expiresAt int64) derrors.Error {
+	if organizationID == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if email == "" {
+		return derrors.NewInvalidArgumentError(emptyEmail)
+	}
+	if roleID == "" {
+		return derrors.NewInvalidArgumentError(emptyRoleId)
+	}
+	if expiresAt != 0 && expiresAt <= time.Now().Unix() {
+		return derrors.NewInvalidArgumentError("expiration must be in the future").WithParams(expiresAt)
+	}
+	return nil
+}
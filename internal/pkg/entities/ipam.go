@@ -0,0 +1,92 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import "time"
+
+// IPRange with a block of addresses assigned to a network of an organization.
+type IPRange struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string `json:"organization_id,omitempty" cql:"organization_id"`
+	// Cidr with the network of the range.
+	Cidr string `json:"cidr,omitempty" cql:"cidr"`
+	// OwnerId with the identifier of the entity the range is assigned to (e.g., connection or ZT network).
+	OwnerId string `json:"owner_id,omitempty" cql:"owner_id"`
+	// Allocated with the allocation timestamp.
+	Allocated int64 `json:"allocated,omitempty" cql:"allocated"`
+}
+
+// NewIPRange creates a new range.
+func NewIPRange(organizationID string, cidr string, ownerID string) *IPRange {
+	return &IPRange{
+		OrganizationId: organizationID,
+		Cidr:           cidr,
+		OwnerId:        ownerID,
+		Allocated:      time.Now().Unix(),
+	}
+}
+
+// IPAddress with an address of a range assigned to a member of a network.
+type IPAddress struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string `json:"organization_id,omitempty" cql:"organization_id"`
+	// Cidr with the network of the range.
+	Cidr string `json:"cidr,omitempty" cql:"cidr"`
+	// Ip with the address.
+	Ip string `json:"ip,omitempty" cql:"ip"`
+	// OwnerId with the identifier of the member the address is assigned to.
+	OwnerId string `json:"owner_id,omitempty" cql:"owner_id"`
+	// Allocated with the allocation timestamp.
+	Allocated int64 `json:"allocated,omitempty" cql:"allocated"`
+}
+
+// NewIPAddress creates a new address.
+func NewIPAddress(organizationID string, cidr string, ip string, ownerID string) *IPAddress {
+	return &IPAddress{
+		OrganizationId: organizationID,
+		Cidr:           cidr,
+		Ip:             ip,
+		OwnerId:        ownerID,
+		Allocated:      time.Now().Unix(),
+	}
+}
+
+// IPRangeUsage with the utilization of a range.
+type IPRangeUsage struct {
+	// Cidr with the network of the range.
+	Cidr string `json:"cidr"`
+	// OwnerId with the identifier of the entity the range is assigned to.
+	OwnerId string `json:"owner_id"`
+	// Capacity with the number of host addresses of the range.
+	Capacity int64 `json:"capacity"`
+	// Allocated with the number of addresses in use.
+	Allocated int64 `json:"allocated"`
+}
+
+// IPAMUsage with the utilization of the address pool by an organization.
+type IPAMUsage struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string `json:"organization_id"`
+	// Pool with the network the ranges are allocated from.
+	Pool string `json:"pool"`
+	// BlockPrefixLength with the prefix length of the allocated ranges.
+	BlockPrefixLength int `json:"block_prefix_length"`
+	// TotalBlocks with the number of blocks of the pool.
+	TotalBlocks int64 `json:"total_blocks"`
+	// Ranges with the utilization of each range of the organization.
+	Ranges []IPRangeUsage `json:"ranges"`
+}
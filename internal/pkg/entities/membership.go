@@ -0,0 +1,95 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"github.com/nalej/derrors"
+	"time"
+)
+
+// MembershipState with the state of the membership of a user in an organization.
+type MembershipState int32
+
+const (
+	MembershipState_Active MembershipState = iota + 1
+	MembershipState_Suspended
+)
+
+var MembershipStateToString = map[MembershipState]string{
+	MembershipState_Active:    "ACTIVE",
+	MembershipState_Suspended: "SUSPENDED",
+}
+
+// Membership links a user with an organization. A user may be a member of several organizations, with different
+// roles in each one.
+type Membership struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string `json:"organization_id,omitempty"`
+	// Email of the user.
+	Email string `json:"email,omitempty"`
+	// RoleIds with the roles assigned to the user in the organization.
+	RoleIds []string `json:"role_ids,omitempty"`
+	// MemberSince with the time the user joined the organization.
+	MemberSince int64 `json:"member_since,omitempty"`
+	// State of the membership.
+	State MembershipState `json:"state,omitempty"`
+}
+
+// NewMembership creates an active membership of a user in an organization.
+func NewMembership(organizationID string, email string) *Membership {
+	return &Membership{
+		OrganizationId: organizationID,
+		Email:          email,
+		RoleIds:        make([]string, 0),
+		MemberSince:    time.Now().UnixNano(),
+		State:          MembershipState_Active,
+	}
+}
+
+// Active checks if the membership grants access to the organization.
+func (m *Membership) Active() bool {
+	return m.State == MembershipState_Active
+}
+
+// HasRole checks if a role is assigned to the user in the organization.
+func (m *Membership) HasRole(roleID string) bool {
+	for _, assigned := range m.RoleIds {
+		if assigned == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveRole unassigns a role from the user in the organization.
+func (m *Membership) RemoveRole(roleID string) {
+	remaining := make([]string, 0, len(m.RoleIds))
+	for _, assigned := range m.RoleIds {
+		if assigned != roleID {
+			remaining = append(remaining, assigned)
+		}
+	}
+	m.RoleIds = remaining
+}
+
+// ValidMembershipState checks that a membership state is supported.
+func ValidMembershipState(state MembershipState) derrors.Error {
+	if _, found := MembershipStateToString[state]; !found {
+		return derrors.NewInvalidArgumentError("unsupported membership state").WithParams(state)
+	}
+	return nil
+}
@@ -23,13 +23,18 @@ const emptyNodeId = "node_id cannot be empty"
 const emptyEmail = "email cannot be empty"
 const emptyName = "name cannot be empty"
 const emptyRoleId = "role_id cannot be empty"
+const emptyServiceAccountId = "service_account_id cannot be empty"
 const emptyAppDescriptorId = "app_descriptor_id cannot be empty"
 const emptyAppInstanceId = "app_instance_id cannot be empty"
 const emptyAssetId = "asset_id cannot be empty"
 const emptyEdgeControllerId = "edge_controller_id cannot be empty"
 const emptyAccountId = "account_id cannot be empty"
 const emptyProjectId = "project_id cannot be empty"
+const emptyStateInfo = "state_info cannot be empty for a non active state"
 const emptyServiceGroupId = "service_group_id cannot be empty"
 const emptyServiceGroupInstanceId = "service_group_instance_id cannot be empty"
 const emptyServiceInstanceId = "service_instance_id cannot be empty"
 const emptyKey = "key cannot be empty"
+const invalidRevision = "revision must be greater than zero"
+const emptyCatalogEntryId = "catalog_entry_id cannot be empty"
+const emptyVersion = "version cannot be empty"
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"fmt"
+	"time"
+)
+
+// Scopes where names must be unique.
+const (
+	OrganizationNameScope = "organization"
+	AccountNameScope      = "account"
+	// AccountOrganizationScope reserves the organization identifiers linked to an account, so an organization
+	// belongs to one account at most.
+	AccountOrganizationScope = "account_organization"
+)
+
+// ProjectNameScope returns the scope of the project names of an account.
+func ProjectNameScope(accountID string) string {
+	return fmt.Sprintf("project/%s", accountID)
+}
+
+// DeviceGroupNameScope returns the scope of the device group names of an organization.
+func DeviceGroupNameScope(organizationID string) string {
+	return fmt.Sprintf("device_group/%s", organizationID)
+}
+
+// RoleNameScope returns the scope of the role names of an organization.
+func RoleNameScope(organizationID string) string {
+	return fmt.Sprintf("role/%s", organizationID)
+}
+
+// NameReservation reserves a name in a scope for an entity, so two entities cannot be created with the same name
+// concurrently.
+type NameReservation struct {
+	// Scope where the name is unique.
+	Scope string `json:"scope,omitempty" cql:"scope"`
+	// Name reserved.
+	Name string `json:"name,omitempty" cql:"name"`
+	// OwnerId with the identifier of the entity holding the name.
+	OwnerId string `json:"owner_id,omitempty" cql:"owner_id"`
+	// Reserved with the reservation timestamp.
+	Reserved int64 `json:"reserved,omitempty" cql:"reserved"`
+}
+
+// NewNameReservation creates a reservation of a name.
+func NewNameReservation(scope string, name string, ownerID string) *NameReservation {
+	return &NameReservation{
+		Scope:    scope,
+		Name:     name,
+		OwnerId:  ownerID,
+		Reserved: time.Now().Unix(),
+	}
+}
+
+// NameConflict with a name used by several entities, found when reserving the names of the existing entities.
+type NameConflict struct {
+	// Scope where the name is unique.
+	Scope string `json:"scope,omitempty"`
+	// Name used by several entities.
+	Name string `json:"name,omitempty"`
+	// OwnerId with the entity that could not reserve the name.
+	OwnerId string `json:"owner_id,omitempty"`
+	// ReservedBy with the entity holding the name.
+	ReservedBy string `json:"reserved_by,omitempty"`
+}
@@ -60,6 +60,8 @@ type Node struct {
 	Status InfraStatus `json:"status,omitempty"`
 	// State of assignation of the node.
 	State NodeState `json:"state,omitempty"`
+	// Version of the node, increased on every update.
+	Version int64 `json:"version,omitempty"`
 }
 
 func NewNodeFromGRPC(addNodeRequest *grpc_infrastructure_go.AddNodeRequest) *Node {
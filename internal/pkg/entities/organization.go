@@ -34,12 +34,16 @@ type Organization struct {
 	ZipCode     string `json:"zip_code"`
 	PhotoBase64 string `json:"photo_base64"`
 	Created     int64  `json:"created"`
+	// PhotoId with the blob of the photo, empty if the photo is kept in PhotoBase64.
+	PhotoId string `json:"photo_id,omitempty"`
+	// ThumbnailId with the blob of the thumbnail of the photo.
+	ThumbnailId string `json:"thumbnail_id,omitempty"`
 }
 
 func NewOrganization(name string, email string, fullAddress string, city string, state string, country string, zipCode string, photo string) *Organization {
 	uuid := GenerateUUID()
 	return &Organization{uuid, name, email, fullAddress, city, state, country,
-		zipCode, photo, time.Now().Unix()}
+		zipCode, photo, time.Now().Unix(), "", ""}
 }
 
 func (o *Organization) String() string {
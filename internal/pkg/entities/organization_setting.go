@@ -27,6 +27,8 @@ type OrganizationSetting struct {
 	Key            string `json:"key"`
 	Value          string `json:"value"`
 	Description    string `json:"description"`
+	// Version of the setting, increased on every update.
+	Version int64 `json:"version,omitempty"`
 }
 
 func NewOrganizationSetting(organizationId string, key string, value string, description string) *OrganizationSetting {
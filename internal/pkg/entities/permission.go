@@ -0,0 +1,137 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"github.com/nalej/derrors"
+)
+
+// ResourceKind with the kind of resource a permission applies to.
+type ResourceKind string
+
+const (
+	ResourceOrganization        ResourceKind = "organization"
+	ResourceCluster             ResourceKind = "cluster"
+	ResourceNode                ResourceKind = "node"
+	ResourceAppDescriptor       ResourceKind = "app_descriptor"
+	ResourceAppInstance         ResourceKind = "app_instance"
+	ResourceConnection          ResourceKind = "connection"
+	ResourceDeviceGroup         ResourceKind = "device_group"
+	ResourceDevice              ResourceKind = "device"
+	ResourceAsset               ResourceKind = "asset"
+	ResourceUser                ResourceKind = "user"
+	ResourceRole                ResourceKind = "role"
+	ResourceOrganizationSetting ResourceKind = "organization_setting"
+	// AnyResource matches all the kinds of resources.
+	AnyResource ResourceKind = "*"
+)
+
+// Action that can be performed on a resource.
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+	// AnyAction matches all the actions.
+	AnyAction Action = "*"
+)
+
+var validResourceKinds = map[ResourceKind]bool{
+	ResourceOrganization: true, ResourceCluster: true, ResourceNode: true, ResourceAppDescriptor: true,
+	ResourceAppInstance: true, ResourceConnection: true, ResourceDeviceGroup: true, ResourceDevice: true,
+	ResourceAsset: true, ResourceUser: true, ResourceRole: true, ResourceOrganizationSetting: true,
+}
+
+var validActions = map[Action]bool{
+	ActionRead: true, ActionCreate: true, ActionUpdate: true, ActionDelete: true,
+}
+
+// Permission grants an action on a kind of resource. If labels are set, the permission only applies to the resources
+// that have all of them.
+type Permission struct {
+	// Resource with the kind of resource, or AnyResource.
+	Resource ResourceKind `json:"resource,omitempty" cql:"resource"`
+	// Action allowed on the resource, or AnyAction.
+	Action Action `json:"action,omitempty" cql:"action"`
+	// Labels the resource must have for the permission to apply.
+	Labels map[string]string `json:"labels,omitempty" cql:"labels"`
+}
+
+// Matches checks if the permission grants an action on a resource.
+func (p *Permission) Matches(action Action, resource *Resource) bool {
+	if p.Resource != AnyResource && p.Resource != resource.Kind {
+		return false
+	}
+	if p.Action != AnyAction && p.Action != action {
+		return false
+	}
+	for key, value := range p.Labels {
+		if current, found := resource.Labels[key]; !found || current != value {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidPermission checks that a permission refers to a supported resource kind and action.
+func ValidPermission(permission Permission) derrors.Error {
+	if permission.Resource != AnyResource && !validResourceKinds[permission.Resource] {
+		return derrors.NewInvalidArgumentError("unsupported resource kind").WithParams(permission.Resource)
+	}
+	if permission.Action != AnyAction && !validActions[permission.Action] {
+		return derrors.NewInvalidArgumentError("unsupported action").WithParams(permission.Action)
+	}
+	return nil
+}
+
+// Resource with the information of a resource required to authorize an action on it.
+type Resource struct {
+	// OrganizationId the resource belongs to.
+	OrganizationId string `json:"organization_id,omitempty"`
+	// Kind of resource.
+	Kind ResourceKind `json:"kind,omitempty"`
+	// Id with the identifier of the resource, empty for actions on all the resources of a kind.
+	Id string `json:"id,omitempty"`
+	// Labels of the resource.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// ValidAuthorizeRequest checks that an action and a resource can be authorized.
+func ValidAuthorizeRequest(action Action, resource *Resource) derrors.Error {
+	if resource == nil || resource.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if !validResourceKinds[resource.Kind] {
+		return derrors.NewInvalidArgumentError("unsupported resource kind").WithParams(resource.Kind)
+	}
+	if !validActions[action] {
+		return derrors.NewInvalidArgumentError("unsupported action").WithParams(action)
+	}
+	return nil
+}
+
+// AuthorizationDecision with the result of an authorization check.
+type AuthorizationDecision struct {
+	// Allowed is set if the action is authorized.
+	Allowed bool `json:"allowed"`
+	// RoleId with the role granting the action.
+	RoleId string `json:"role_id,omitempty"`
+	// Permission granting the action.
+	Permission *Permission `json:"permission,omitempty"`
+}
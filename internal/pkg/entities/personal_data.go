@@ -0,0 +1,127 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"fmt"
+	"github.com/nalej/derrors"
+)
+
+// ErasedPersonalData replaces the personal data that cannot be removed because it is part of another entity.
+const ErasedPersonalData = "erased"
+
+// PersonalDataExport with all the information held about an email address.
+type PersonalDataExport struct {
+	// Email the data belongs to.
+	Email string `json:"email"`
+	// Exported with the export timestamp.
+	Exported int64 `json:"exported"`
+	// User with the profile of the user, including the photo, or nil if there is no user with the email.
+	User *User `json:"user,omitempty"`
+	// Memberships of the user in the organizations.
+	Memberships []Membership `json:"memberships"`
+	// OrganizationUsers with the organizations that list the email as a user.
+	OrganizationUsers []string `json:"organization_users"`
+	// InvitationsReceived with the invitations sent to the email.
+	InvitationsReceived []Invitation `json:"invitations_received"`
+	// InvitationsSent with the invitations created by the email.
+	InvitationsSent []Invitation `json:"invitations_sent"`
+	// OrganizationContacts with the organizations that use the email as contact.
+	OrganizationContacts []string `json:"organization_contacts"`
+}
+
+// NewPersonalDataExport creates an empty export for an email.
+func NewPersonalDataExport(email string, exported int64) *PersonalDataExport {
+	return &PersonalDataExport{
+		Email:                email,
+		Exported:             exported,
+		Memberships:          make([]Membership, 0),
+		OrganizationUsers:    make([]string, 0),
+		InvitationsReceived:  make([]Invitation, 0),
+		InvitationsSent:      make([]Invitation, 0),
+		OrganizationContacts: make([]string, 0),
+	}
+}
+
+// References returns a description of each place that holds data about the email.
+func (e *PersonalDataExport) References() []string {
+	result := make([]string, 0)
+	if e.User != nil {
+		result = append(result, "user")
+	}
+	for _, membership := range e.Memberships {
+		result = append(result, fmt.Sprintf("membership in organization %s", membership.OrganizationId))
+	}
+	for _, organizationID := range e.OrganizationUsers {
+		result = append(result, fmt.Sprintf("user of organization %s", organizationID))
+	}
+	for _, invitation := range e.InvitationsReceived {
+		result = append(result, fmt.Sprintf("invitation %s received", invitation.InvitationId))
+	}
+	for _, invitation := range e.InvitationsSent {
+		result = append(result, fmt.Sprintf("invitation %s sent", invitation.InvitationId))
+	}
+	for _, organizationID := range e.OrganizationContacts {
+		result = append(result, fmt.Sprintf("contact of organization %s", organizationID))
+	}
+	return result
+}
+
+// PersonalDataErasure with the report of the erasure of the data held about an email address.
+type PersonalDataErasure struct {
+	// Email the data belonged to.
+	Email string `json:"email"`
+	// Erased with the erasure timestamp.
+	Erased int64 `json:"erased"`
+	// UserRemoved is set if the user was removed.
+	UserRemoved bool `json:"user_removed"`
+	// MembershipsRemoved with the organizations the user was removed from.
+	MembershipsRemoved []string `json:"memberships_removed"`
+	// OrganizationUsersRemoved with the organizations that listed the email as a user without a membership.
+	OrganizationUsersRemoved []string `json:"organization_users_removed"`
+	// InvitationsRemoved with the invitations sent to the email.
+	InvitationsRemoved []string `json:"invitations_removed"`
+	// InvitationsAnonymized with the invitations created by the email.
+	InvitationsAnonymized []string `json:"invitations_anonymized"`
+	// OrganizationContactsAnonymized with the organizations that used the email as contact.
+	OrganizationContactsAnonymized []string `json:"organization_contacts_anonymized"`
+	// Verified is set if no data about the email is found after the erasure.
+	Verified bool `json:"verified"`
+	// Remaining with the places that still hold data about the email after the erasure.
+	Remaining []string `json:"remaining,omitempty"`
+}
+
+// NewPersonalDataErasure creates an empty erasure report for an email.
+func NewPersonalDataErasure(email string, erased int64) *PersonalDataErasure {
+	return &PersonalDataErasure{
+		Email:                          email,
+		Erased:                         erased,
+		MembershipsRemoved:             make([]string, 0),
+		OrganizationUsersRemoved:       make([]string, 0),
+		InvitationsRemoved:             make([]string, 0),
+		InvitationsAnonymized:          make([]string, 0),
+		OrganizationContactsAnonymized: make([]string, 0),
+	}
+}
+
+// ValidPersonalDataEmail checks the email of a personal data request.
+func ValidPersonalDataEmail(email string) derrors.Error {
+	if email == "" {
+		return derrors.NewInvalidArgumentError(emptyEmail)
+	}
+	return nil
+}
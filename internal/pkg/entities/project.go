@@ -26,17 +26,70 @@ type ProjectState int32
 const (
 	ProjectState_Active ProjectState = iota + 1
 	ProjectState_Deactivated
+	// ProjectState_Suspended with a project temporarily stopped, e.g. because its account is suspended.
+	ProjectState_Suspended
+	// ProjectState_PendingDeletion with a project waiting to be removed.
+	ProjectState_PendingDeletion
 )
 
+// ProjectStateToGRPC converts the states of a project. The states without a gRPC counterpart are sent as deactivated,
+// with the reason in the state info.
 var ProjectStateToGRPC = map[ProjectState]grpc_project_go.ProjectState{
-	ProjectState_Active:      grpc_project_go.ProjectState_ACTIVE,
-	ProjectState_Deactivated: grpc_project_go.ProjectState_DEACTIVATED,
+	ProjectState_Active:          grpc_project_go.ProjectState_ACTIVE,
+	ProjectState_Deactivated:     grpc_project_go.ProjectState_DEACTIVATED,
+	ProjectState_Suspended:       grpc_project_go.ProjectState_DEACTIVATED,
+	ProjectState_PendingDeletion: grpc_project_go.ProjectState_DEACTIVATED,
 }
 var ProjectStateFromGRPC = map[grpc_project_go.ProjectState]ProjectState{
 	grpc_project_go.ProjectState_ACTIVE:      ProjectState_Active,
 	grpc_project_go.ProjectState_DEACTIVATED: ProjectState_Deactivated,
 }
 
+// ProjectStateFromGRPCUpdate returns the state a project changes to when a gRPC state is received. As the suspended and
+// pending deletion states are sent as deactivated, receiving deactivated keeps those states.
+func ProjectStateFromGRPCUpdate(current ProjectState, state grpc_project_go.ProjectState) ProjectState {
+	if state == grpc_project_go.ProjectState_DEACTIVATED &&
+		(current == ProjectState_Suspended || current == ProjectState_PendingDeletion) {
+		return current
+	}
+	return ProjectStateFromGRPC[state]
+}
+
+var ProjectStateToString = map[ProjectState]string{
+	ProjectState_Active:          "active",
+	ProjectState_Deactivated:     "deactivated",
+	ProjectState_Suspended:       "suspended",
+	ProjectState_PendingDeletion: "pending_deletion",
+}
+var ProjectStateFromString = map[string]ProjectState{
+	"active":           ProjectState_Active,
+	"deactivated":      ProjectState_Deactivated,
+	"suspended":        ProjectState_Suspended,
+	"pending_deletion": ProjectState_PendingDeletion,
+}
+
+// projectStateTransitions with the states a project can change to from each state.
+var projectStateTransitions = map[ProjectState][]ProjectState{
+	ProjectState_Active:          {ProjectState_Suspended, ProjectState_Deactivated, ProjectState_PendingDeletion},
+	ProjectState_Suspended:       {ProjectState_Active, ProjectState_Deactivated, ProjectState_PendingDeletion},
+	ProjectState_Deactivated:     {ProjectState_Active, ProjectState_PendingDeletion},
+	ProjectState_PendingDeletion: {ProjectState_Active},
+}
+
+// InactiveAccountStateInfo with the state info of the projects suspended because their account is not active. Only
+// these projects are activated again when the account is activated.
+const InactiveAccountStateInfo = "account is not active"
+
+// ProjectResourceType with the types of the resources of an organization that can be assigned to a project.
+type ProjectResourceType string
+
+const (
+	ProjectResourceCluster       ProjectResourceType = "cluster"
+	ProjectResourceAppDescriptor ProjectResourceType = "app_descriptor"
+	ProjectResourceAppInstance   ProjectResourceType = "app_instance"
+	ProjectResourceDeviceGroup   ProjectResourceType = "device_group"
+)
+
 type Project struct {
 	// ProjectId with the project identifier
 	ProjectId string `json:"project_id,omitempty"`
@@ -90,7 +143,7 @@ func (p *Project) ApplyUpdate(update *grpc_project_go.UpdateProjectRequest) {
 		p.Name = update.Name
 	}
 	if update.UpdateState {
-		p.State = ProjectStateFromGRPC[update.State]
+		p.State = ProjectStateFromGRPCUpdate(p.State, update.State)
 	}
 	if update.UpdateStateInfo {
 		p.StateInfo = update.StateInfo
@@ -108,6 +161,27 @@ func ValidateAddProjectRequest(request *grpc_project_go.AddProjectRequest) derro
 	return nil
 }
 
+// ValidateProjectStateTransition checks that a project can change from a state to another one. The suspended and pending
+// deletion states require a reason.
+func ValidateProjectStateTransition(from ProjectState, to ProjectState, reason string) derrors.Error {
+	if _, exists := ProjectStateToString[to]; !exists {
+		return derrors.NewInvalidArgumentError("invalid project state").WithParams(to)
+	}
+	if (to == ProjectState_Suspended || to == ProjectState_PendingDeletion) && reason == "" {
+		return derrors.NewInvalidArgumentError(emptyStateInfo).WithParams(ProjectStateToString[to])
+	}
+	if from == to {
+		return nil
+	}
+	for _, allowed := range projectStateTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return derrors.NewFailedPreconditionError("invalid project state transition").
+		WithParams(ProjectStateToString[from], ProjectStateToString[to])
+}
+
 func ValidateProjectId(request *grpc_project_go.ProjectId) derrors.Error {
 	if request.AccountId == "" {
 		return derrors.NewInvalidArgumentError(emptyAccountId)
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"fmt"
+	"github.com/nalej/derrors"
+	"strconv"
+	"strings"
+)
+
+// QuotaResource with the type of resource limited by the quotas of an organization.
+type QuotaResource string
+
+const (
+	ClusterQuota     QuotaResource = "clusters"
+	NodeQuota        QuotaResource = "nodes"
+	DescriptorQuota  QuotaResource = "descriptors"
+	InstanceQuota    QuotaResource = "instances"
+	DeviceQuota      QuotaResource = "devices"
+	DeviceGroupQuota QuotaResource = "device_groups"
+	UserQuota        QuotaResource = "users"
+	AppNetworkQuota  QuotaResource = "app_networks"
+)
+
+// QuotaResources with the resources limited by quotas.
+var QuotaResources = []QuotaResource{ClusterQuota, NodeQuota, DescriptorQuota, InstanceQuota, DeviceQuota,
+	DeviceGroupQuota, UserQuota, AppNetworkQuota}
+
+// DefaultQuotaLimits with the system limits used when an organization does not define its own.
+var DefaultQuotaLimits = map[QuotaResource]int64{
+	ClusterQuota:     50,
+	NodeQuota:        1000,
+	DescriptorQuota:  500,
+	InstanceQuota:    500,
+	DeviceQuota:      10000,
+	DeviceGroupQuota: 100,
+	UserQuota:        500,
+	AppNetworkQuota:  1000,
+}
+
+// quotaLimitMin with the minimum limit of a resource.
+var quotaLimitMin int64 = 0
+
+func init() {
+	// the limits are set by the administrators with the quotas command
+	for _, resource := range QuotaResources {
+		mustRegisterSettingSchema(SettingSchema{
+			Key:         QuotaSettingKey(resource),
+			Type:        IntSetting,
+			Default:     strconv.FormatInt(DefaultQuotaLimits[resource], 10),
+			Description: fmt.Sprintf("Maximum number of %s", resource),
+			Min:         &quotaLimitMin,
+			Editable:    false,
+		})
+	}
+}
+
+// QuotaSettingPrefix with the prefix of the keys of the organization settings that store the quota limits.
+const QuotaSettingPrefix = "quota."
+
+// quotaExceeded is the message of the error returned when an organization reaches the limit of a resource.
+const quotaExceeded = "quota exceeded"
+
+// QuotaSettingKey returns the key of the organization setting that stores the limit of a resource.
+func QuotaSettingKey(resource QuotaResource) string {
+	return fmt.Sprintf("%s%s", QuotaSettingPrefix, resource)
+}
+
+// IsQuotaSettingKey checks if the key of an organization setting belongs to a quota limit.
+func IsQuotaSettingKey(key string) bool {
+	return strings.HasPrefix(key, QuotaSettingPrefix)
+}
+
+// ValidQuotaResource checks that a resource is limited by quotas.
+func ValidQuotaResource(resource QuotaResource) derrors.Error {
+	if _, found := DefaultQuotaLimits[resource]; !found {
+		return derrors.NewInvalidArgumentError("unknown quota resource").WithParams(resource)
+	}
+	return nil
+}
+
+// ParseQuotaLimit parses the value of the setting that stores the limit of a resource.
+func ParseQuotaLimit(value string) (int64, derrors.Error) {
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, derrors.NewInvalidArgumentError("invalid quota limit", err).WithParams(value)
+	}
+	if limit < 0 {
+		return 0, derrors.NewInvalidArgumentError("quota limit cannot be negative").WithParams(value)
+	}
+	return limit, nil
+}
+
+// QuotaUsage with the number of resources of a type used by an organization.
+type QuotaUsage struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string `json:"organization_id,omitempty" cql:"organization_id"`
+	// Resource limited by the quota.
+	Resource QuotaResource `json:"resource,omitempty" cql:"resource"`
+	// Used with the number of resources in use.
+	Used int64 `json:"used" cql:"used"`
+}
+
+// NewQuotaUsage creates the usage of a resource.
+func NewQuotaUsage(organizationID string, resource QuotaResource, used int64) *QuotaUsage {
+	return &QuotaUsage{
+		OrganizationId: organizationID,
+		Resource:       resource,
+		Used:           used,
+	}
+}
+
+// QuotaReport compares the usage of a resource with its limit.
+type QuotaReport struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string `json:"organization_id"`
+	// Resource limited by the quota.
+	Resource QuotaResource `json:"resource"`
+	// Used with the number of resources in use.
+	Used int64 `json:"used"`
+	// Limit with the maximum number of resources.
+	Limit int64 `json:"limit"`
+	// Default is set if the limit is the system default.
+	Default bool `json:"default"`
+}
+
+// NewQuotaExceededError creates the error returned when an organization reaches the limit of a resource.
+func NewQuotaExceededError(organizationID string, resource QuotaResource, limit int64) derrors.Error {
+	return derrors.NewFailedPreconditionError(quotaExceeded).WithParams(organizationID, resource, limit)
+}
+
+// IsQuotaExceeded checks if an error was caused by an organization reaching the limit of a resource.
+func IsQuotaExceeded(err derrors.Error) bool {
+	return err != nil && err.Type() == derrors.FailedPrecondition && strings.Contains(err.Error(), quotaExceeded)
+}
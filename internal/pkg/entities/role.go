@@ -29,8 +29,12 @@ type Role struct {
 	Description    string `json:"description,omitempty"`
 	Internal       bool   `json:"internal"`
 	Created        int64  `json:"created,omitempty"`
+	// Permissions granted by the role.
+	Permissions []Permission `json:"permissions,omitempty"`
 }
 
+// NewRoleFromGRPC creates the role requested by an organization. The requested roles are never internal, only the
+// role templates create internal roles.
 func NewRoleFromGRPC(addRoleRequest *grpc_role_go.AddRoleRequest) *Role {
 	uuid := GenerateUUID()
 	return &Role{
@@ -38,7 +42,7 @@ func NewRoleFromGRPC(addRoleRequest *grpc_role_go.AddRoleRequest) *Role {
 		RoleId:         uuid,
 		Name:           addRoleRequest.Name,
 		Description:    addRoleRequest.Description,
-		Internal:       addRoleRequest.Internal,
+		Internal:       false,
 		Created:        time.Now().Unix(),
 	}
 }
@@ -54,6 +58,49 @@ func (r *Role) ToGRPC() *grpc_role_go.Role {
 	}
 }
 
+// UpdateRoleRequest with the fields of a role to be updated.
+type UpdateRoleRequest struct {
+	OrganizationId    string
+	RoleId            string
+	UpdateName        bool
+	Name              string
+	UpdateDescription bool
+	Description       string
+}
+
+// ApplyUpdate updates the fields of a role set in a request.
+func (r *Role) ApplyUpdate(request UpdateRoleRequest) {
+	if request.UpdateName {
+		r.Name = request.Name
+	}
+	if request.UpdateDescription {
+		r.Description = request.Description
+	}
+}
+
+// Allows returns the first permission of the role granting an action on a resource, or nil if none does.
+func (r *Role) Allows(action Action, resource *Resource) *Permission {
+	if r.OrganizationId != resource.OrganizationId {
+		return nil
+	}
+	for i := range r.Permissions {
+		if r.Permissions[i].Matches(action, resource) {
+			return &r.Permissions[i]
+		}
+	}
+	return nil
+}
+
+// ValidPermissions checks a list of permissions.
+func ValidPermissions(permissions []Permission) derrors.Error {
+	for _, permission := range permissions {
+		if err := ValidPermission(permission); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func ValidAddRoleRequest(addRoleRequest *grpc_role_go.AddRoleRequest) derrors.Error {
 	if addRoleRequest.OrganizationId == "" {
 		return derrors.NewInvalidArgumentError(emptyOrganizationId)
@@ -74,6 +121,20 @@ func ValidRemoveRoleRequest(removeRoleRequest *grpc_role_go.RemoveRoleRequest) d
 	return nil
 }
 
+// ValidUpdateRoleRequest checks the identifiers of a role to be updated and the new name, if set.
+func ValidUpdateRoleRequest(request UpdateRoleRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.RoleId == "" {
+		return derrors.NewInvalidArgumentError(emptyRoleId)
+	}
+	if request.UpdateName && request.Name == "" {
+		return derrors.NewInvalidArgumentError(emptyName)
+	}
+	return nil
+}
+
 func ValidRoleID(roleID *grpc_role_go.RoleId) derrors.Error {
 	if roleID.OrganizationId == "" {
 		return derrors.NewInvalidArgumentError(emptyOrganizationId)
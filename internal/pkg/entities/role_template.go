@@ -0,0 +1,109 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"encoding/json"
+	"github.com/nalej/derrors"
+	"time"
+)
+
+// RoleTemplate defines a role that is created when an organization is added.
+type RoleTemplate struct {
+	// Name of the role.
+	Name string `json:"name"`
+	// Description of the role.
+	Description string `json:"description,omitempty"`
+	// Internal roles cannot be updated or removed by the organizations.
+	Internal bool `json:"internal,omitempty"`
+	// Permissions granted by the role.
+	Permissions []Permission `json:"permissions,omitempty"`
+}
+
+// NewRole creates the role of an organization defined by the template.
+func (t *RoleTemplate) NewRole(organizationID string) *Role {
+	permissions := make([]Permission, len(t.Permissions))
+	copy(permissions, t.Permissions)
+	return &Role{
+		OrganizationId: organizationID,
+		RoleId:         GenerateUUID(),
+		Name:           t.Name,
+		Description:    t.Description,
+		Internal:       t.Internal,
+		Created:        time.Now().Unix(),
+		Permissions:    permissions,
+	}
+}
+
+// DefaultRoleTemplates returns the roles created for the new organizations if no other templates are configured.
+func DefaultRoleTemplates() []RoleTemplate {
+	return []RoleTemplate{
+		{
+			Name:        "Owner",
+			Description: "Full access to the organization",
+			Internal:    true,
+			Permissions: []Permission{{Resource: AnyResource, Action: AnyAction}},
+		},
+		{
+			Name:        "Operator",
+			Description: "Manage the applications and the devices of the organization",
+			Permissions: []Permission{
+				{Resource: AnyResource, Action: ActionRead},
+				{Resource: ResourceAppDescriptor, Action: AnyAction},
+				{Resource: ResourceAppInstance, Action: AnyAction},
+				{Resource: ResourceConnection, Action: AnyAction},
+				{Resource: ResourceDeviceGroup, Action: AnyAction},
+				{Resource: ResourceDevice, Action: AnyAction},
+			},
+		},
+		{
+			Name:        "Viewer",
+			Description: "Read-only access to the organization",
+			Permissions: []Permission{{Resource: AnyResource, Action: ActionRead}},
+		},
+	}
+}
+
+// ValidRoleTemplates checks that the templates have unique names and valid permissions.
+func ValidRoleTemplates(templates []RoleTemplate) derrors.Error {
+	names := make(map[string]bool, len(templates))
+	for _, template := range templates {
+		if template.Name == "" {
+			return derrors.NewInvalidArgumentError(emptyName)
+		}
+		if names[template.Name] {
+			return derrors.NewAlreadyExistsError("role template").WithParams(template.Name)
+		}
+		names[template.Name] = true
+		if err := ValidPermissions(template.Permissions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseRoleTemplates reads a JSON list of role templates and validates it.
+func ParseRoleTemplates(data []byte) ([]RoleTemplate, derrors.Error) {
+	templates := make([]RoleTemplate, 0)
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, derrors.NewInvalidArgumentError("cannot parse the role templates", err)
+	}
+	if err := ValidRoleTemplates(templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
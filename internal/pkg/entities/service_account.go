@@ -0,0 +1,216 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/nalej/derrors"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix with the prefix of the API keys issued by the system model.
+const APIKeyPrefix = "nsk"
+
+// apiKeySecretLength with the number of random bytes of the secret of an API key.
+const apiKeySecretLength = 32
+
+// ServiceAccount is a non-human identity of an organization used by automation such as CI pipelines or device agents.
+type ServiceAccount struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string `json:"organization_id,omitempty" cql:"organization_id"`
+	// ServiceAccountId with the service account identifier.
+	ServiceAccountId string `json:"service_account_id,omitempty" cql:"service_account_id"`
+	// Name of the service account.
+	Name string `json:"name,omitempty" cql:"name"`
+	// Description of the service account.
+	Description string `json:"description,omitempty" cql:"description"`
+	// Created with the creation timestamp.
+	Created int64 `json:"created,omitempty" cql:"created"`
+	// Enabled is unset to reject all the keys of the service account.
+	Enabled bool `json:"enabled" cql:"enabled"`
+}
+
+// NewServiceAccount creates an enabled service account.
+func NewServiceAccount(organizationID string, name string, description string) *ServiceAccount {
+	return &ServiceAccount{
+		OrganizationId:   organizationID,
+		ServiceAccountId: GenerateUUID(),
+		Name:             name,
+		Description:      description,
+		Created:          time.Now().Unix(),
+		Enabled:          true,
+	}
+}
+
+// ValidServiceAccount checks the information required to add a service account.
+func ValidServiceAccount(organizationID string, name string) derrors.Error {
+	if organizationID == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if name == "" {
+		return derrors.NewInvalidArgumentError(emptyName)
+	}
+	return nil
+}
+
+// UpdateServiceAccountRequest with the fields of a service account to be updated.
+type UpdateServiceAccountRequest struct {
+	OrganizationId    string
+	ServiceAccountId  string
+	UpdateName        bool
+	Name              string
+	UpdateDescription bool
+	Description       string
+	UpdateEnabled     bool
+	Enabled           bool
+}
+
+// ApplyUpdate updates the fields of a service account set in a request.
+func (sa *ServiceAccount) ApplyUpdate(request UpdateServiceAccountRequest) {
+	if request.UpdateName {
+		sa.Name = request.Name
+	}
+	if request.UpdateDescription {
+		sa.Description = request.Description
+	}
+	if request.UpdateEnabled {
+		sa.Enabled = request.Enabled
+	}
+}
+
+// APIKey grants a service account a set of permissions. Only the hash of the secret is stored, the plain key is
+// returned once when the key is created.
+type APIKey struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string `json:"organization_id,omitempty" cql:"organization_id"`
+	// ServiceAccountId with the service account the key belongs to.
+	ServiceAccountId string `json:"service_account_id,omitempty" cql:"service_account_id"`
+	// KeyId with the key identifier, part of the plain key.
+	KeyId string `json:"key_id,omitempty" cql:"key_id"`
+	// Hash with the SHA-256 of the secret.
+	Hash string `json:"-" cql:"hash"`
+	// Permissions granted by the key.
+	Permissions []Permission `json:"permissions,omitempty" cql:"permissions"`
+	// Created with the creation timestamp.
+	Created int64 `json:"created,omitempty" cql:"created"`
+	// ExpiresAt with the expiration timestamp, 0 if the key does not expire.
+	ExpiresAt int64 `json:"expires_at,omitempty" cql:"expires_at"`
+	// RevokedAt with the revocation timestamp, 0 if the key is not revoked.
+	RevokedAt int64 `json:"revoked_at,omitempty" cql:"revoked_at"`
+	// LastUsed with the timestamp of the last successful verification of the key.
+	LastUsed int64 `json:"last_used,omitempty" cql:"last_used"`
+}
+
+// NewAPIKey creates a key for a service account. It returns the key and the plain key to be handed to the client.
+func NewAPIKey(organizationID string, serviceAccountID string, permissions []Permission, expiresAt int64) (*APIKey, string, derrors.Error) {
+	secret := make([]byte, apiKeySecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", derrors.AsError(err, "cannot generate api key secret")
+	}
+	encoded := hex.EncodeToString(secret)
+	key := &APIKey{
+		OrganizationId:   organizationID,
+		ServiceAccountId: serviceAccountID,
+		KeyId:            GenerateUUID(),
+		Hash:             HashAPIKeySecret(encoded),
+		Permissions:      permissions,
+		Created:          time.Now().Unix(),
+		ExpiresAt:        expiresAt,
+	}
+	return key, fmt.Sprintf("%s_%s_%s", APIKeyPrefix, key.KeyId, encoded), nil
+}
+
+// ParseAPIKey splits a plain key into the key identifier and the secret.
+func ParseAPIKey(plainKey string) (string, string, derrors.Error) {
+	parts := strings.Split(plainKey, "_")
+	if len(parts) != 3 || parts[0] != APIKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", "", derrors.NewInvalidArgumentError("malformed api key")
+	}
+	return parts[1], parts[2], nil
+}
+
+// HashAPIKeySecret returns the hash of the secret of a key as stored in the system.
+func HashAPIKeySecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// MatchesSecret checks in constant time if a secret corresponds to the key.
+func (k *APIKey) MatchesSecret(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(k.Hash), []byte(HashAPIKeySecret(secret))) == 1
+}
+
+// Revoked checks if the key has been revoked.
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != 0
+}
+
+// Expired checks if the key is expired at a given time.
+func (k *APIKey) Expired(now int64) bool {
+	return k.ExpiresAt != 0 && now >= k.ExpiresAt
+}
+
+// Allows returns the permission of the key that grants an action on a resource, or nil if the action is not allowed.
+func (k *APIKey) Allows(action Action, resource *Resource) *Permission {
+	if k.OrganizationId != resource.OrganizationId {
+		return nil
+	}
+	for i := range k.Permissions {
+		if k.Permissions[i].Matches(action, resource) {
+			return &k.Permissions[i]
+		}
+	}
+	return nil
+}
+
+// ValidAddAPIKey checks the information required to create a key.
+func ValidAddAPIKey(organizationID string, serviceAccountID string, permissions []Permission, expiresAt int64) derrors.Error {
+	if organizationID == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if serviceAccountID == "" {
+		return derrors.NewInvalidArgumentError(emptyServiceAccountId)
+	}
+	if len(permissions) == 0 {
+		return derrors.NewInvalidArgumentError("permissions cannot be empty")
+	}
+	if expiresAt != 0 && expiresAt <= time.Now().Unix() {
+		return derrors.NewInvalidArgumentError("expiration must be in the future").WithParams(expiresAt)
+	}
+	return ValidPermissions(permissions)
+}
+
+// APIKeyVerification with the result of verifying a key presented to a gateway.
+type APIKeyVerification struct {
+	// Valid is set if the key can be used.
+	Valid bool `json:"valid"`
+	// Reason why the key is not valid.
+	Reason string `json:"reason,omitempty"`
+	// OrganizationId the key belongs to.
+	OrganizationId string `json:"organization_id,omitempty"`
+	// ServiceAccountId the key belongs to.
+	ServiceAccountId string `json:"service_account_id,omitempty"`
+	// KeyId with the key identifier.
+	KeyId string `json:"key_id,omitempty"`
+	// Permissions granted by the key.
+	Permissions []Permission `json:"permissions,omitempty"`
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"encoding/json"
+	"github.com/nalej/derrors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SettingType defines how the value of an organization setting is parsed.
+type SettingType string
+
+const (
+	// BoolSetting accepts true or false.
+	BoolSetting SettingType = "bool"
+	// IntSetting accepts a base 10 integer.
+	IntSetting SettingType = "int"
+	// DurationSetting accepts a duration such as 90s or 1h30m.
+	DurationSetting SettingType = "duration"
+	// EnumSetting accepts one of a set of values.
+	EnumSetting SettingType = "enum"
+	// JSONSetting accepts a JSON document.
+	JSONSetting SettingType = "json"
+)
+
+// SettingSchema defines a known organization setting.
+type SettingSchema struct {
+	// Key of the setting.
+	Key string `json:"key"`
+	// Type of the value.
+	Type SettingType `json:"type"`
+	// Default value returned when the setting is not set.
+	Default string `json:"default"`
+	// Description of the setting.
+	Description string `json:"description"`
+	// Values accepted by an enum setting.
+	Values []string `json:"values,omitempty"`
+	// Min with the minimum value of an int setting, if set.
+	Min *int64 `json:"min,omitempty"`
+	// Max with the maximum value of an int setting, if set.
+	Max *int64 `json:"max,omitempty"`
+	// Editable is set if the organizations can change the setting through the settings API.
+	Editable bool `json:"editable"`
+}
+
+// Validate checks that a value is valid for the setting.
+func (s *SettingSchema) Validate(value string) derrors.Error {
+	switch s.Type {
+	case BoolSetting:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return derrors.NewInvalidArgumentError("setting value must be a boolean").WithParams(s.Key, value)
+		}
+	case IntSetting:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return derrors.NewInvalidArgumentError("setting value must be an integer").WithParams(s.Key, value)
+		}
+		if (s.Min != nil && parsed < *s.Min) || (s.Max != nil && parsed > *s.Max) {
+			return derrors.NewInvalidArgumentError("setting value out of range").WithParams(s.Key, value)
+		}
+	case DurationSetting:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return derrors.NewInvalidArgumentError("setting value must be a duration").WithParams(s.Key, value)
+		}
+		if parsed < 0 {
+			return derrors.NewInvalidArgumentError("setting value cannot be negative").WithParams(s.Key, value)
+		}
+	case EnumSetting:
+		for _, accepted := range s.Values {
+			if value == accepted {
+				return nil
+			}
+		}
+		return derrors.NewInvalidArgumentError("setting value is not one of the accepted values").WithParams(s.Key, value, s.Values)
+	case JSONSetting:
+		if !json.Valid([]byte(value)) {
+			return derrors.NewInvalidArgumentError("setting value must be a JSON document").WithParams(s.Key)
+		}
+	default:
+		return derrors.NewInvalidArgumentError("unsupported setting type").WithParams(s.Key, s.Type)
+	}
+	return nil
+}
+
+// DefaultSetting returns the setting of an organization with the default value.
+func (s *SettingSchema) DefaultSetting(organizationID string) *OrganizationSetting {
+	return NewOrganizationSetting(organizationID, s.Key, s.Default, s.Description)
+}
+
+// SettingSchemaRegistry with the known organization settings, indexed by key.
+type SettingSchemaRegistry struct {
+	sync.RWMutex
+	schemas map[string]SettingSchema
+}
+
+// NewSettingSchemaRegistry creates an empty registry.
+func NewSettingSchemaRegistry() *SettingSchemaRegistry {
+	return &SettingSchemaRegistry{schemas: make(map[string]SettingSchema, 0)}
+}
+
+// Register adds a known setting to the registry. The default value must be valid.
+func (r *SettingSchemaRegistry) Register(schema SettingSchema) derrors.Error {
+	if schema.Key == "" {
+		return derrors.NewInvalidArgumentError(emptyKey)
+	}
+	if err := schema.Validate(schema.Default); err != nil {
+		return err
+	}
+	r.Lock()
+	defer r.Unlock()
+	if _, exists := r.schemas[schema.Key]; exists {
+		return derrors.NewAlreadyExistsError("setting schema").WithParams(schema.Key)
+	}
+	r.schemas[schema.Key] = schema
+	return nil
+}
+
+// Get retrieves the schema of a setting, and whether the setting is known.
+func (r *SettingSchemaRegistry) Get(key string) (*SettingSchema, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	schema, exists := r.schemas[key]
+	if !exists {
+		return nil, false
+	}
+	return &schema, true
+}
+
+// List retrieves the known settings sorted by key.
+func (r *SettingSchemaRegistry) List() []SettingSchema {
+	r.RLock()
+	defer r.RUnlock()
+	result := make([]SettingSchema, 0, len(r.schemas))
+	for _, schema := range r.schemas {
+		result = append(result, schema)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// SettingSchemas is the registry of the settings known by the system, used unless another registry is set.
+var SettingSchemas = NewSettingSchemaRegistry()
+
+// RegisterSettingSchema adds a known setting to the system registry. The default value must be valid.
+func RegisterSettingSchema(schema SettingSchema) derrors.Error {
+	return SettingSchemas.Register(schema)
+}
+
+// mustRegisterSettingSchema registers the settings defined by this package, which are known to be valid.
+func mustRegisterSettingSchema(schema SettingSchema) {
+	if err := RegisterSettingSchema(schema); err != nil {
+		panic(err.Error())
+	}
+}
+
+// GetSettingSchema retrieves the schema of a setting of the system registry, and whether the setting is known.
+func GetSettingSchema(key string) (*SettingSchema, bool) {
+	return SettingSchemas.Get(key)
+}
+
+// ListSettingSchemas retrieves the settings of the system registry sorted by key.
+func ListSettingSchemas() []SettingSchema {
+	return SettingSchemas.List()
+}
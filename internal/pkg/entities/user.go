@@ -33,6 +33,15 @@ type User struct {
 	Phone          string `json:"phone,omitempty"`
 	Location       string `json:"location,omitempty"`
 	PhotoBase64    string `json:"photo_base64,omitempty"`
+	// PhotoId with the blob of the photo, empty if the photo is kept in PhotoBase64.
+	PhotoId string `json:"photo_id,omitempty"`
+	// ThumbnailId with the blob of the thumbnail of the photo.
+	ThumbnailId string `json:"thumbnail_id,omitempty"`
+	// Version of the user, increased on every update.
+	Version int64 `json:"version,omitempty"`
+	// RoleIds with the roles assigned to the user before the memberships were introduced. They are only read to
+	// migrate the memberships, the roles of a user are kept in its memberships.
+	RoleIds []string `json:"role_ids,omitempty"`
 }
 
 func NewUserFromGRPC(addUserRequest *grpc_user_go.AddUserRequest) *User {
@@ -61,6 +70,14 @@ func (u *User) ToGRPC() *grpc_user_go.User {
 	}
 }
 
+// ForMembership returns the user as seen from the organization of a membership.
+func (u *User) ForMembership(membership *Membership) *User {
+	result := *u
+	result.OrganizationId = membership.OrganizationId
+	result.MemberSince = membership.MemberSince
+	return &result
+}
+
 func (u *User) ApplyUpdate(request *grpc_user_go.UpdateUserRequest) {
 	if request.UpdateName {
 		u.Name = request.Name
@@ -0,0 +1,36 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"github.com/nalej/derrors"
+	"strings"
+)
+
+// versionConflict is the message of the error returned when an update is made on a stale version of an entity.
+const versionConflict = "version conflict"
+
+// NewVersionConflictError creates the error returned when the version of an updated entity does not match the
+// stored one.
+func NewVersionConflictError(entity string, id string, version int64) derrors.Error {
+	return derrors.NewFailedPreconditionError(versionConflict).WithParams(entity, id, version)
+}
+
+// IsVersionConflict checks if an error was caused by an update on a stale version of an entity.
+func IsVersionConflict(err derrors.Error) bool {
+	return err != nil && err.Type() == derrors.FailedPrecondition && strings.Contains(err.Error(), versionConflict)
+}
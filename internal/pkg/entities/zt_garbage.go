@@ -0,0 +1,106 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+// ZtOrphanReason describes why a zt network member or proxy is considered an orphan.
+type ZtOrphanReason string
+
+const (
+	ZtOrphanInstanceNotFound             ZtOrphanReason = "instance not found"
+	ZtOrphanServiceGroupInstanceNotFound ZtOrphanReason = "service group instance not found"
+	ZtOrphanServiceInstanceNotFound      ZtOrphanReason = "service instance not found"
+	ZtOrphanClusterNotFound              ZtOrphanReason = "cluster not found"
+)
+
+// CollectedZtMember with a zt network member removed by the garbage collector.
+type CollectedZtMember struct {
+	// AppInstanceId with the application instance identifier.
+	AppInstanceId string `json:"app_instance_id,omitempty"`
+	// ServiceGroupInstanceId with the service group instance identifier.
+	ServiceGroupInstanceId string `json:"service_group_instance_id,omitempty"`
+	// ServiceApplicationInstanceId with the service instance identifier.
+	ServiceApplicationInstanceId string `json:"service_application_instance_id,omitempty"`
+	// ZtNetworkId zero-tier network identifier.
+	ZtNetworkId string `json:"zt_network_id,omitempty"`
+	// Member with the removed member.
+	Member AppNetworkMember `json:"member"`
+	// Reason why the member was removed.
+	Reason ZtOrphanReason `json:"reason"`
+}
+
+// CollectedZtProxy with a zt service proxy removed by the garbage collector.
+type CollectedZtProxy struct {
+	// Proxy with the removed proxy.
+	Proxy ServiceProxy `json:"proxy"`
+	// Reason why the proxy was removed.
+	Reason ZtOrphanReason `json:"reason"`
+}
+
+// ZtGarbageReport with the result of a garbage collection of the zt networks of an organization.
+type ZtGarbageReport struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string `json:"organization_id"`
+	// Timestamp of the collection.
+	Timestamp int64 `json:"timestamp"`
+	// GracePeriod in seconds an orphan is kept before being removed.
+	GracePeriod int64 `json:"grace_period"`
+	// DryRun is true if the orphans were reported but not removed.
+	DryRun bool `json:"dry_run"`
+	// Members removed.
+	Members []CollectedZtMember `json:"members"`
+	// Proxies removed.
+	Proxies []CollectedZtProxy `json:"proxies"`
+	// Pending with the number of orphans still within the grace period.
+	Pending int `json:"pending"`
+	// CollectLegacy is true if the orphans without creation time are removed.
+	CollectLegacy bool `json:"collect_legacy"`
+	// Legacy with the number of orphans without creation time that were not removed.
+	Legacy int `json:"legacy"`
+}
+
+// NewZtGarbageReport creates an empty report.
+func NewZtGarbageReport(organizationID string, timestamp int64, gracePeriod int64, dryRun bool, collectLegacy bool) *ZtGarbageReport {
+	return &ZtGarbageReport{
+		OrganizationId: organizationID,
+		Timestamp:      timestamp,
+		GracePeriod:    gracePeriod,
+		DryRun:         dryRun,
+		Members:        make([]CollectedZtMember, 0),
+		Proxies:        make([]CollectedZtProxy, 0),
+		CollectLegacy:  collectLegacy,
+	}
+}
+
+// Expired checks if an entry created at a given time is older than the grace period. Entries without creation time
+// were added before it was recorded, and their age is unknown; they are only expired if the legacy entries are
+// collected.
+func (r *ZtGarbageReport) Expired(createdAt int64) bool {
+	if createdAt == 0 {
+		return r.CollectLegacy
+	}
+	return r.Timestamp-createdAt >= r.GracePeriod
+}
+
+// AddKept counts an orphan that is not removed, either because it is within the grace period or because it has no
+// creation time.
+func (r *ZtGarbageReport) AddKept(createdAt int64, count int) {
+	if createdAt == 0 {
+		r.Legacy += count
+	} else {
+		r.Pending += count
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package images
+
+import (
+	"bytes"
+	"github.com/nalej/derrors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+)
+
+// ThumbnailContentType with the content type of the generated thumbnails.
+const ThumbnailContentType = "image/png"
+
+// MaxPixels with the largest number of pixels of the images that are decoded. A small compressed image can declare a
+// very large size, and decoding it would allocate memory for all its pixels.
+const MaxPixels = 40 * 1000 * 1000
+
+// Thumbnail decodes a PNG, JPEG or GIF image and scales it down so neither side is larger than maxSide, keeping the
+// aspect ratio. Smaller images are not enlarged. Images larger than MaxPixels are rejected before they are decoded.
+// The thumbnail is encoded as PNG.
+func Thumbnail(data []byte, maxSide int) ([]byte, derrors.Error) {
+	if maxSide <= 0 {
+		return nil, derrors.NewInvalidArgumentError("thumbnail side must be positive").WithParams(maxSide)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, derrors.NewInvalidArgumentError("cannot decode image", err)
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, derrors.NewInvalidArgumentError("image too large").WithParams(config.Width, config.Height)
+	}
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, derrors.NewInvalidArgumentError("cannot decode image", err)
+	}
+	bounds := source.Bounds()
+	width, height := scaledSize(bounds.Dx(), bounds.Dy(), maxSide)
+
+	// nearest neighbour sampling is enough for small thumbnails
+	result := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sourceY := bounds.Min.Y + y*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			sourceX := bounds.Min.X + x*bounds.Dx()/width
+			result.Set(x, y, source.At(sourceX, sourceY))
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, result); err != nil {
+		return nil, derrors.AsError(err, "cannot encode thumbnail")
+	}
+	return buffer.Bytes(), nil
+}
+
+// scaledSize returns the size of an image scaled down to fit in a square of a given side.
+func scaledSize(width int, height int, maxSide int) (int, int) {
+	if width <= maxSide && height <= maxSide {
+		return width, height
+	}
+	if width >= height {
+		return maxSide, max(1, height*maxSide/width)
+	}
+	return max(1, width*maxSide/height), maxSide
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
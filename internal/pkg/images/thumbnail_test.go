@@ -0,0 +1,85 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package images
+
+import (
+	"bytes"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+var _ = ginkgo.Describe("Thumbnails", func() {
+
+	createImage := func(width int, height int) image.Image {
+		result := image.NewRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				result.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+			}
+		}
+		return result
+	}
+
+	decodeSize := func(data []byte) (int, int) {
+		decoded, err := png.Decode(bytes.NewReader(data))
+		gomega.Expect(err).To(gomega.Succeed())
+		return decoded.Bounds().Dx(), decoded.Bounds().Dy()
+	}
+
+	ginkgo.It("should scale down an image keeping the aspect ratio", func() {
+		var buffer bytes.Buffer
+		gomega.Expect(png.Encode(&buffer, createImage(200, 100))).To(gomega.Succeed())
+
+		thumbnail, err := Thumbnail(buffer.Bytes(), 64)
+		gomega.Expect(err).To(gomega.Succeed())
+		width, height := decodeSize(thumbnail)
+		gomega.Expect(width).Should(gomega.Equal(64))
+		gomega.Expect(height).Should(gomega.Equal(32))
+	})
+	ginkgo.It("should not enlarge small images", func() {
+		var buffer bytes.Buffer
+		gomega.Expect(jpeg.Encode(&buffer, createImage(20, 40), nil)).To(gomega.Succeed())
+
+		thumbnail, err := Thumbnail(buffer.Bytes(), 64)
+		gomega.Expect(err).To(gomega.Succeed())
+		width, height := decodeSize(thumbnail)
+		gomega.Expect(width).Should(gomega.Equal(20))
+		gomega.Expect(height).Should(gomega.Equal(40))
+	})
+	ginkgo.It("should reject images with too many pixels before decoding them", func() {
+		var buffer bytes.Buffer
+		gomega.Expect(gif.Encode(&buffer, createImage(1, 1), nil)).To(gomega.Succeed())
+		data := buffer.Bytes()
+		// the logical screen size follows the 6 bytes of the signature, as little endian 16 bit values
+		for i := 6; i < 10; i++ {
+			data[i] = 0xff
+		}
+
+		_, err := Thumbnail(data, 64)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Error()).Should(gomega.ContainSubstring("image too large"))
+	})
+	ginkgo.It("should reject content that is not an image", func() {
+		_, err := Thumbnail([]byte("not an image"), 64)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
@@ -28,12 +28,15 @@ type MockupAccountProvider struct {
 	// accounts with a map of assets indexed by accountID.
 	accounts     map[string]entities.Account
 	accountNames map[string]bool
+	// organizations linked to each account indexed by accountID.
+	organizations map[string]map[string]bool
 }
 
 func NewMockupAccountProvider() *MockupAccountProvider {
 	return &MockupAccountProvider{
-		accounts:     make(map[string]entities.Account, 0),
-		accountNames: make(map[string]bool, 0),
+		accounts:      make(map[string]entities.Account, 0),
+		accountNames:  make(map[string]bool, 0),
+		organizations: make(map[string]map[string]bool, 0),
 	}
 }
 
@@ -119,6 +122,62 @@ func (m *MockupAccountProvider) Remove(accountID string) derrors.Error {
 	}
 	delete(m.accountNames, m.accounts[accountID].Name)
 	delete(m.accounts, accountID)
+	delete(m.organizations, accountID)
+	return nil
+}
+
+// AddOrganization links an organization to an account.
+func (m *MockupAccountProvider) AddOrganization(accountID string, organizationID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.unsafeExists(accountID) {
+		return derrors.NewNotFoundError("account").WithParams(accountID)
+	}
+	organizations, exists := m.organizations[accountID]
+	if !exists {
+		organizations = make(map[string]bool, 0)
+		m.organizations[accountID] = organizations
+	}
+	if organizations[organizationID] {
+		return derrors.NewAlreadyExistsError("organization").WithParams(accountID, organizationID)
+	}
+	organizations[organizationID] = true
+	return nil
+}
+
+// OrganizationExists checks if an organization is linked to an account.
+func (m *MockupAccountProvider) OrganizationExists(accountID string, organizationID string) (bool, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.organizations[accountID][organizationID], nil
+}
+
+// ListOrganizations returns the identifiers of the organizations linked to an account.
+func (m *MockupAccountProvider) ListOrganizations(accountID string) ([]string, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.unsafeExists(accountID) {
+		return nil, derrors.NewNotFoundError("account").WithParams(accountID)
+	}
+	list := make([]string, 0)
+	for organizationID := range m.organizations[accountID] {
+		list = append(list, organizationID)
+	}
+	return list, nil
+}
+
+// RemoveOrganization unlinks an organization from an account.
+func (m *MockupAccountProvider) RemoveOrganization(accountID string, organizationID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.organizations[accountID][organizationID] {
+		return derrors.NewNotFoundError("organization").WithParams(accountID, organizationID)
+	}
+	delete(m.organizations[accountID], organizationID)
 	return nil
 }
 
@@ -128,5 +187,6 @@ func (m *MockupAccountProvider) Clear() derrors.Error {
 	defer m.Unlock()
 	m.accounts = make(map[string]entities.Account, 0)
 	m.accountNames = make(map[string]bool, 0)
+	m.organizations = make(map[string]map[string]bool, 0)
 	return nil
 }
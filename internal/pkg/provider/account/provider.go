@@ -37,6 +37,14 @@ type Provider interface {
 	List() ([]entities.Account, derrors.Error)
 	// Remove an account
 	Remove(accountID string) derrors.Error
+	// AddOrganization links an organization to an account.
+	AddOrganization(accountID string, organizationID string) derrors.Error
+	// OrganizationExists checks if an organization is linked to an account.
+	OrganizationExists(accountID string, organizationID string) (bool, derrors.Error)
+	// ListOrganizations returns the identifiers of the organizations linked to an account.
+	ListOrganizations(accountID string) ([]string, derrors.Error)
+	// RemoveOrganization unlinks an organization from an account.
+	RemoveOrganization(accountID string, organizationID string) derrors.Error
 	// Clear all accounts
 	Clear() derrors.Error
 }
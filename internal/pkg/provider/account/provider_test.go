@@ -178,4 +178,55 @@ func RunTest(provider Provider) {
 			gomega.Expect(len(list)).Should(gomega.Equal(0))
 		})
 	})
+	ginkgo.Context("linking organizations", func() {
+		ginkgo.It("should be able to link an organization to an account", func() {
+			toAdd := CreateAccount()
+			err := provider.Add(*toAdd)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			organizationID := entities.GenerateUUID()
+			err = provider.AddOrganization(toAdd.AccountId, organizationID)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			exists, err := provider.OrganizationExists(toAdd.AccountId, organizationID)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(exists).To(gomega.BeTrue())
+
+			list, err := provider.ListOrganizations(toAdd.AccountId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(list).To(gomega.ConsistOf(organizationID))
+		})
+		ginkgo.It("should not be able to link an organization to a non existing account", func() {
+			err := provider.AddOrganization(entities.GenerateUUID(), entities.GenerateUUID())
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should be able to unlink an organization", func() {
+			toAdd := CreateAccount()
+			err := provider.Add(*toAdd)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			organizationID := entities.GenerateUUID()
+			err = provider.AddOrganization(toAdd.AccountId, organizationID)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			err = provider.RemoveOrganization(toAdd.AccountId, organizationID)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			exists, err := provider.OrganizationExists(toAdd.AccountId, organizationID)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(exists).NotTo(gomega.BeTrue())
+
+			list, err := provider.ListOrganizations(toAdd.AccountId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(list).To(gomega.BeEmpty())
+		})
+		ginkgo.It("should not be able to unlink an organization that is not linked", func() {
+			toAdd := CreateAccount()
+			err := provider.Add(*toAdd)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			err = provider.RemoveOrganization(toAdd.AccountId, entities.GenerateUUID())
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+	})
 }
@@ -27,9 +27,11 @@ import (
 
 const AccountTable = "Account"
 const AccountTablePK = "account_id"
+const AccountOrganizationTable = "Account_Organizations"
 
 var allAccountColumns = []string{"account_id", "name", "created", "billing_info", "state", "state_info"}
 var allAccountColumnsNoPK = []string{"name", "created", "billing_info", "state", "state_info"}
+var allAccountOrganizationColumns = []string{"account_id", "organization_id"}
 
 type ScyllaAccountProvider struct {
 	scylladb.ScyllaDB
@@ -126,7 +128,98 @@ func (sp *ScyllaAccountProvider) Remove(accountID string) derrors.Error {
 	sp.Lock()
 	defer sp.Unlock()
 
-	return sp.UnsafeRemove(AccountTable, AccountTablePK, accountID)
+	err := sp.UnsafeRemove(AccountTable, AccountTablePK, accountID)
+	if err != nil {
+		return err
+	}
+
+	// remove the links with the organizations
+	stmt, names := qb.Delete(AccountOrganizationTable).Where(qb.Eq(AccountTablePK)).ToCql()
+	cqlErr := gocqlx.Query(sp.Session.Query(stmt), names).BindMap(qb.M{
+		AccountTablePK: accountID,
+	}).ExecRelease()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot remove the organizations of the account")
+	}
+	return nil
+}
+
+func (sp *ScyllaAccountProvider) createAccountOrganizationPKMap(accountID string, organizationID string) map[string]interface{} {
+
+	res := map[string]interface{}{
+		"account_id":      accountID,
+		"organization_id": organizationID,
+	}
+
+	return res
+}
+
+// AddOrganization links an organization to an account.
+func (sp *ScyllaAccountProvider) AddOrganization(accountID string, organizationID string) derrors.Error {
+	sp.Lock()
+	defer sp.Unlock()
+
+	exists, err := sp.UnsafeGenericExist(AccountTable, AccountTablePK, accountID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("account").WithParams(accountID)
+	}
+
+	pkColumn := sp.createAccountOrganizationPKMap(accountID, organizationID)
+	record := entities.NewAccountOrganization(accountID, organizationID)
+	return sp.UnsafeCompositeAdd(AccountOrganizationTable, pkColumn, allAccountOrganizationColumns, record)
+}
+
+// OrganizationExists checks if an organization is linked to an account.
+func (sp *ScyllaAccountProvider) OrganizationExists(accountID string, organizationID string) (bool, derrors.Error) {
+	sp.Lock()
+	defer sp.Unlock()
+
+	pkColumn := sp.createAccountOrganizationPKMap(accountID, organizationID)
+	return sp.UnsafeGenericCompositeExist(AccountOrganizationTable, pkColumn)
+}
+
+// ListOrganizations returns the identifiers of the organizations linked to an account.
+func (sp *ScyllaAccountProvider) ListOrganizations(accountID string) ([]string, derrors.Error) {
+	sp.Lock()
+	defer sp.Unlock()
+
+	if err := sp.CheckAndConnect(); err != nil {
+		return nil, err
+	}
+
+	exists, err := sp.UnsafeGenericExist(AccountTable, AccountTablePK, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, derrors.NewNotFoundError("account").WithParams(accountID)
+	}
+
+	stmt, names := qb.Select(AccountOrganizationTable).Columns("organization_id").Where(qb.Eq(AccountTablePK)).ToCql()
+	q := gocqlx.Query(sp.Session.Query(stmt), names).BindMap(qb.M{
+		AccountTablePK: accountID,
+	})
+
+	organizations := make([]string, 0)
+	cqlErr := q.SelectRelease(&organizations)
+
+	if cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot list the organizations of the account")
+	}
+
+	return organizations, nil
+}
+
+// RemoveOrganization unlinks an organization from an account.
+func (sp *ScyllaAccountProvider) RemoveOrganization(accountID string, organizationID string) derrors.Error {
+	sp.Lock()
+	defer sp.Unlock()
+
+	pkColumn := sp.createAccountOrganizationPKMap(accountID, organizationID)
+	return sp.UnsafeCompositeRemove(AccountOrganizationTable, pkColumn)
 }
 
 // Clear all accounts
@@ -134,5 +227,5 @@ func (sp *ScyllaAccountProvider) Clear() derrors.Error {
 	sp.Lock()
 	defer sp.Unlock()
 
-	return sp.UnsafeClear([]string{AccountTable})
+	return sp.UnsafeClear([]string{AccountTable, AccountOrganizationTable})
 }
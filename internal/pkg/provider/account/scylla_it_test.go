@@ -25,6 +25,7 @@ create KEYSPACE nalej WITH replication = {'class': 'SimpleStrategy', 'replicatio
 create type IF NOT EXISTS nalej.account_billing_info (account_id text, full_name text, company_name text, address text, additional_info text);
 create table IF NOT EXISTS nalej.Account (account_id text, name text, created bigint, billing_info FROZEN<account_billing_info>, state int, state_info text, primary key (account_id) );
 create index IF NOT EXISTS accountName on nalej.Account(name);
+create table IF NOT EXISTS nalej.Account_Organizations (account_id text, organization_id text, PRIMARY KEY (account_id, organization_id));
 
 Environment variables
 IT_SCYLLA_HOST=127.0.0.1
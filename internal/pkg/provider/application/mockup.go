@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"github.com/nalej/derrors"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"sort"
 	"sync"
 )
 
@@ -27,6 +28,8 @@ type MockupApplicationProvider struct {
 	sync.Mutex
 	appDescriptors map[string]entities.AppDescriptor
 	appInstances   map[string]entities.AppInstance
+	// descriptorRevisions indexed by AppDescriptorID and revision number
+	descriptorRevisions map[string]map[int64]entities.AppDescriptorRevision
 
 	// parametrizedDescriptor indexed by AppInstanceID
 	parametrizedDescriptor map[string]entities.ParametrizedDescriptor
@@ -35,6 +38,8 @@ type MockupApplicationProvider struct {
 
 	appEntryPoints       map[string]entities.AppEndpoint
 	appEntryPointsByName map[string][]*entities.AppEndpoint
+	// appEndpointFqdns indexed by global FQDN
+	appEndpointFqdns map[string]entities.AppEndpointFqdn
 
 	appZtNetworks      map[string]map[string]entities.AppZtNetwork
 	appZtNetworMembers map[string]map[string]map[string]map[string]map[string]map[string]entities.AppNetworkMember
@@ -44,10 +49,12 @@ func NewMockupApplicationProvider() *MockupApplicationProvider {
 	return &MockupApplicationProvider{
 		appDescriptors:         make(map[string]entities.AppDescriptor, 0),
 		appInstances:           make(map[string]entities.AppInstance, 0),
+		descriptorRevisions:    make(map[string]map[int64]entities.AppDescriptorRevision, 0),
 		appEntryPoints:         make(map[string]entities.AppEndpoint, 0),
 		instanceParameters:     make(map[string][]entities.InstanceParameter, 0),
 		parametrizedDescriptor: make(map[string]entities.ParametrizedDescriptor, 0),
 		appEntryPointsByName:   make(map[string][]*entities.AppEndpoint, 0),
+		appEndpointFqdns:       make(map[string]entities.AppEndpointFqdn, 0),
 		appZtNetworks:          make(map[string]map[string]entities.AppZtNetwork, 0),
 		appZtNetworMembers:     make(map[string]map[string]map[string]map[string]map[string]map[string]entities.AppNetworkMember, 0),
 	}
@@ -60,11 +67,14 @@ func (m *MockupApplicationProvider) Clear() derrors.Error {
 
 	m.appDescriptors = make(map[string]entities.AppDescriptor, 0)
 	m.appInstances = make(map[string]entities.AppInstance, 0)
+	m.descriptorRevisions = make(map[string]map[int64]entities.AppDescriptorRevision, 0)
 	m.appEntryPoints = make(map[string]entities.AppEndpoint, 0)
 	m.parametrizedDescriptor = make(map[string]entities.ParametrizedDescriptor, 0)
 
 	m.appEntryPointsByName = make(map[string][]*entities.AppEndpoint, 0)
+	m.appEndpointFqdns = make(map[string]entities.AppEndpointFqdn, 0)
 	m.appZtNetworks = make(map[string]map[string]entities.AppZtNetwork, 0)
+	m.appZtNetworMembers = make(map[string]map[string]map[string]map[string]map[string]map[string]entities.AppNetworkMember, 0)
 
 	m.instanceParameters = make(map[string][]entities.InstanceParameter, 0)
 
@@ -153,6 +163,66 @@ func (m *MockupApplicationProvider) DeleteDescriptor(appDescriptorID string) der
 	return nil
 }
 
+// AddDescriptorRevision stores an immutable revision of a descriptor.
+func (m *MockupApplicationProvider) AddDescriptorRevision(revision entities.AppDescriptorRevision) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	revisions, exists := m.descriptorRevisions[revision.AppDescriptorId]
+	if !exists {
+		revisions = make(map[int64]entities.AppDescriptorRevision, 0)
+		m.descriptorRevisions[revision.AppDescriptorId] = revisions
+	}
+	if _, exists := revisions[revision.Revision]; exists {
+		return derrors.NewAlreadyExistsError("descriptor revision").WithParams(revision.AppDescriptorId, revision.Revision)
+	}
+	revisions[revision.Revision] = revision
+	return nil
+}
+
+// GetDescriptorRevision retrieves a given revision of a descriptor.
+func (m *MockupApplicationProvider) GetDescriptorRevision(appDescriptorID string, revision int64) (*entities.AppDescriptorRevision, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	retrieved, exists := m.descriptorRevisions[appDescriptorID][revision]
+	if !exists {
+		return nil, derrors.NewNotFoundError("descriptor revision").WithParams(appDescriptorID, revision)
+	}
+	return &retrieved, nil
+}
+
+// ListDescriptorRevisions retrieves the revisions of a descriptor ordered by revision number.
+func (m *MockupApplicationProvider) ListDescriptorRevisions(appDescriptorID string) ([]entities.AppDescriptorRevision, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.AppDescriptorRevision, 0)
+	for _, revision := range m.descriptorRevisions[appDescriptorID] {
+		result = append(result, revision)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Revision < result[j].Revision
+	})
+	return result, nil
+}
+
+// RemoveDescriptorRevision removes a revision of a descriptor that could not be applied.
+func (m *MockupApplicationProvider) RemoveDescriptorRevision(appDescriptorID string, revision int64) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.descriptorRevisions[appDescriptorID][revision]; !exists {
+		return derrors.NewNotFoundError("descriptor revision").WithParams(appDescriptorID, revision)
+	}
+	delete(m.descriptorRevisions[appDescriptorID], revision)
+	return nil
+}
+
+// DeleteDescriptorRevisions removes all the revisions of a descriptor.
+func (m *MockupApplicationProvider) DeleteDescriptorRevisions(appDescriptorID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.descriptorRevisions, appDescriptorID)
+	return nil
+}
+
 // AddInstance adds a new application instance to the system
 func (m *MockupApplicationProvider) AddInstance(instance entities.AppInstance) derrors.Error {
 	m.Lock()
@@ -201,6 +271,13 @@ func (m *MockupApplicationProvider) UpdateInstance(instance entities.AppInstance
 	if !m.unsafeExistsAppInst(instance.AppInstanceId) {
 		return derrors.NewNotFoundError("instance").WithParams(instance.AppInstanceId)
 	}
+	current := m.appInstances[instance.AppInstanceId]
+	if current.Version != instance.Version {
+		return entities.NewVersionConflictError("instance", instance.AppInstanceId, instance.Version)
+	}
+	// the descriptor revision is set when the instance is created and cannot be updated
+	instance.DescriptorRevision = current.DescriptorRevision
+	instance.Version++
 	m.appInstances[instance.AppInstanceId] = instance
 	return nil
 }
@@ -332,7 +409,17 @@ func (m *MockupApplicationProvider) DeleteAppEndpoints(organizationID string, ap
 
 	for key, endpoint := range m.appEntryPoints {
 		if endpoint.OrganizationId == organizationID && endpoint.AppInstanceId == appInstanceID {
-			delete(m.appEntryPointsByName, endpoint.GlobalFqdn)
+			remaining := make([]*entities.AppEndpoint, 0)
+			for _, named := range m.appEntryPointsByName[endpoint.GlobalFqdn] {
+				if named.OrganizationId != organizationID || named.AppInstanceId != appInstanceID {
+					remaining = append(remaining, named)
+				}
+			}
+			if len(remaining) == 0 {
+				delete(m.appEntryPointsByName, endpoint.GlobalFqdn)
+			} else {
+				m.appEntryPointsByName[endpoint.GlobalFqdn] = remaining
+			}
 			delete(m.appEntryPoints, key)
 		}
 	}
@@ -349,12 +436,64 @@ func (m *MockupApplicationProvider) GetAppEndpointList(organizationID string, ap
 	for _, endpoint := range m.appEntryPoints {
 		if endpoint.OrganizationId == organizationID && endpoint.AppInstanceId == appInstanceId &&
 			endpoint.ServiceGroupInstanceId == serviceGroupInstanceID {
-			list = append(list, &endpoint)
+			toAppend := endpoint
+			list = append(list, &toAppend)
 		}
 	}
 	return list, nil
 }
 
+// AddAppEndpointFqdn assigns a global FQDN to a service of a service group instance.
+func (m *MockupApplicationProvider) AddAppEndpointFqdn(fqdn entities.AppEndpointFqdn) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, exists := m.appEndpointFqdns[fqdn.GlobalFqdn]; exists {
+		return derrors.NewAlreadyExistsError("global fqdn").WithParams(fqdn.GlobalFqdn)
+	}
+	m.appEndpointFqdns[fqdn.GlobalFqdn] = fqdn
+	return nil
+}
+
+// GetAppEndpointFqdn retrieves the owner of a global FQDN.
+func (m *MockupApplicationProvider) GetAppEndpointFqdn(globalFqdn string) (*entities.AppEndpointFqdn, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	fqdn, exists := m.appEndpointFqdns[globalFqdn]
+	if !exists {
+		return nil, derrors.NewNotFoundError("global fqdn").WithParams(globalFqdn)
+	}
+	return &fqdn, nil
+}
+
+// ListAppEndpointFqdns retrieves the global FQDNs assigned to an application instance.
+func (m *MockupApplicationProvider) ListAppEndpointFqdns(organizationID string, appInstanceID string) ([]entities.AppEndpointFqdn, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	result := make([]entities.AppEndpointFqdn, 0)
+	for _, fqdn := range m.appEndpointFqdns {
+		if fqdn.OrganizationId == organizationID && fqdn.AppInstanceId == appInstanceID {
+			result = append(result, fqdn)
+		}
+	}
+	return result, nil
+}
+
+// DeleteAppEndpointFqdns releases the global FQDNs assigned to an application instance.
+func (m *MockupApplicationProvider) DeleteAppEndpointFqdns(organizationID string, appInstanceID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	for key, fqdn := range m.appEndpointFqdns {
+		if fqdn.OrganizationId == organizationID && fqdn.AppInstanceId == appInstanceID {
+			delete(m.appEndpointFqdns, key)
+		}
+	}
+	return nil
+}
+
 // AppZtNetwork functions
 
 func (m *MockupApplicationProvider) AddAppZtNetwork(ztNetwork entities.AppZtNetwork) derrors.Error {
@@ -422,9 +561,13 @@ func (m *MockupApplicationProvider) AddZtNetworkProxy(proxy entities.ServiceProx
 	}
 
 	// add the proxy
+	if theInstance.AvailableProxies == nil {
+		theInstance.AvailableProxies = make(map[string]map[string][]entities.ServiceProxy, 0)
+	}
 	fqdn, found := theInstance.AvailableProxies[proxy.FQDN]
 	if !found {
 		fqdn = make(map[string][]entities.ServiceProxy, 0)
+		theInstance.AvailableProxies[proxy.FQDN] = fqdn
 	}
 
 	cluster, found := fqdn[proxy.ClusterId]
@@ -432,14 +575,53 @@ func (m *MockupApplicationProvider) AddZtNetworkProxy(proxy entities.ServiceProx
 		cluster = []entities.ServiceProxy{}
 	}
 
-	cluster = append(cluster, proxy)
+	fqdn[proxy.ClusterId] = append(cluster, proxy)
+	appInstance[proxy.AppInstanceId] = theInstance
 
 	return nil
 }
 
 // RemoveZtNetworkProxy remove an existing zt service proxy
 func (m *MockupApplicationProvider) RemoveZtNetworkProxy(organizationId string, appInstanceId string, fqdn string, clusterId string, serviceGroupInstanceId string, serviceInstanceId string) derrors.Error {
-	return derrors.NewUnimplementedError("RemoveZtNetworkProxy not implemented yet")
+	m.Lock()
+	defer m.Unlock()
+
+	theInstance, found := m.appZtNetworks[organizationId][appInstanceId]
+	if !found {
+		return derrors.NewNotFoundError("appZtNetworks").WithParams(organizationId, appInstanceId)
+	}
+	clusterEntries, found := theInstance.AvailableProxies[fqdn][clusterId]
+	if !found {
+		return derrors.NewNotFoundError(fmt.Sprintf("impossible to find proxy for fqdn %s in cluster %s", fqdn, clusterId))
+	}
+	for i, proxy := range clusterEntries {
+		if proxy.ServiceInstanceId == serviceInstanceId && proxy.ServiceGroupInstanceId == serviceGroupInstanceId {
+			remaining := append(clusterEntries[:i:i], clusterEntries[i+1:]...)
+			if len(remaining) == 0 {
+				delete(theInstance.AvailableProxies[fqdn], clusterId)
+			} else {
+				theInstance.AvailableProxies[fqdn][clusterId] = remaining
+			}
+			if len(theInstance.AvailableProxies[fqdn]) == 0 {
+				delete(theInstance.AvailableProxies, fqdn)
+			}
+			return nil
+		}
+	}
+	return derrors.NewNotFoundError(fmt.Sprintf("impossible to find proxy for fqdn %s in cluster %s with serviceInstanceId %s",
+		fqdn, clusterId, serviceInstanceId))
+}
+
+// ListAppZtNetworks retrieves the zt networks of the application instances of an organization
+func (m *MockupApplicationProvider) ListAppZtNetworks(organizationId string) ([]entities.AppZtNetwork, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	list := make([]entities.AppZtNetwork, 0)
+	for _, network := range m.appZtNetworks[organizationId] {
+		list = append(list, network)
+	}
+	return list, nil
 }
 
 func (m *MockupApplicationProvider) AddAppZtNetworkMember(member entities.AppZtNetworkMembers) (*entities.AppZtNetworkMembers, derrors.Error) {
@@ -506,7 +688,12 @@ func (m *MockupApplicationProvider) RemoveAppZtNetworkMember(organizationId stri
 		return derrors.NewNotFoundError("not found application service instance")
 	}
 
-	delete(service_app_instance, ztNetworkId)
+	zt_network, found := service_app_instance[serviceInstance]
+	if !found {
+		return derrors.NewNotFoundError("not found service instance")
+	}
+
+	delete(zt_network, ztNetworkId)
 
 	return nil
 }
@@ -582,3 +769,28 @@ func (m *MockupApplicationProvider) ListAppZtNetworkMembers(organizationId strin
 
 	return list, nil
 }
+
+// ListOrganizationZtNetworkMembers retrieves the members of all the zt networks of an organization
+func (m *MockupApplicationProvider) ListOrganizationZtNetworkMembers(organizationId string) ([]*entities.AppZtNetworkMembers, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	list := make([]*entities.AppZtNetworkMembers, 0)
+	for appInstanceId, instanceMap := range m.appZtNetworMembers[organizationId] {
+		for group, serviceGroupMap := range instanceMap {
+			for instance, networks := range serviceGroupMap {
+				for networkId, members := range networks {
+					list = append(list, &entities.AppZtNetworkMembers{
+						OrganizationId:               organizationId,
+						AppInstanceId:                appInstanceId,
+						ServiceGroupInstanceId:       group,
+						ServiceApplicationInstanceId: instance,
+						ZtNetworkId:                  networkId,
+						Members:                      members,
+					})
+				}
+			}
+		}
+	}
+	return list, nil
+}
@@ -38,6 +38,21 @@ type Provider interface {
 	// DeleteDescriptor removes a given descriptor from the system.
 	DeleteDescriptor(appDescriptorID string) derrors.Error
 
+	// AddDescriptorRevision stores an immutable revision of a descriptor.
+	AddDescriptorRevision(revision entities.AppDescriptorRevision) derrors.Error
+
+	// GetDescriptorRevision retrieves a given revision of a descriptor.
+	GetDescriptorRevision(appDescriptorID string, revision int64) (*entities.AppDescriptorRevision, derrors.Error)
+
+	// ListDescriptorRevisions retrieves the revisions of a descriptor ordered by revision number.
+	ListDescriptorRevisions(appDescriptorID string) ([]entities.AppDescriptorRevision, derrors.Error)
+
+	// RemoveDescriptorRevision removes a revision of a descriptor that could not be applied.
+	RemoveDescriptorRevision(appDescriptorID string, revision int64) derrors.Error
+
+	// DeleteDescriptorRevisions removes all the revisions of a descriptor.
+	DeleteDescriptorRevisions(appDescriptorID string) derrors.Error
+
 	// GetDescriptorParameters retrieves the params of a descriptor
 	GetDescriptorParameters(appDescriptorID string) ([]entities.Parameter, derrors.Error)
 
@@ -53,7 +68,8 @@ type Provider interface {
 	// DeleteInstance removes a given instance from the system.
 	DeleteInstance(appInstanceID string) derrors.Error
 
-	// UpdateInstance updates the information of an instance
+	// UpdateInstance updates the information of an instance. The update fails with a version conflict if the
+	// version of the instance does not match the stored one, and increases the stored version otherwise.
 	UpdateInstance(instance entities.AppInstance) derrors.Error
 
 	// AddInstanceParameters adds deploy parameters of an instance in the system
@@ -91,6 +107,19 @@ type Provider interface {
 
 	GetAppEndpointList(organizationID string, appInstanceId string, serviceGroupInstanceID string) ([]*entities.AppEndpoint, derrors.Error)
 
+	// AddAppEndpointFqdn assigns a global FQDN to a service of a service group instance. It fails if the FQDN is
+	// already assigned.
+	AddAppEndpointFqdn(fqdn entities.AppEndpointFqdn) derrors.Error
+
+	// GetAppEndpointFqdn retrieves the owner of a global FQDN.
+	GetAppEndpointFqdn(globalFqdn string) (*entities.AppEndpointFqdn, derrors.Error)
+
+	// ListAppEndpointFqdns retrieves the global FQDNs assigned to an application instance.
+	ListAppEndpointFqdns(organizationID string, appInstanceID string) ([]entities.AppEndpointFqdn, derrors.Error)
+
+	// DeleteAppEndpointFqdns releases the global FQDNs assigned to an application instance.
+	DeleteAppEndpointFqdns(organizationID string, appInstanceID string) derrors.Error
+
 	// AddAppZtNetwork adds a new zerotier network to an existing application instance
 	AddAppZtNetwork(network entities.AppZtNetwork) derrors.Error
 
@@ -100,6 +129,9 @@ type Provider interface {
 	// GetAppZtNetwork get the zt network
 	GetAppZtNetwork(organizationId string, appInstanceId string) (*entities.AppZtNetwork, derrors.Error)
 
+	// ListAppZtNetworks retrieves the zt networks of the application instances of an organization
+	ListAppZtNetworks(organizationId string) ([]entities.AppZtNetwork, derrors.Error)
+
 	// AddZtNetworkProxy add a zt service proxy
 	AddZtNetworkProxy(proxy entities.ServiceProxy) derrors.Error
 
@@ -117,4 +149,7 @@ type Provider interface {
 
 	// ListAppZtNetworkMembers retrieves a list of members in a zero tier network
 	ListAppZtNetworkMembers(organizationId string, appInstanceId string, ztNetworkId string) ([]*entities.AppZtNetworkMembers, derrors.Error)
+
+	// ListOrganizationZtNetworkMembers retrieves the members of all the zt networks of an organization
+	ListOrganizationZtNetworkMembers(organizationId string) ([]*entities.AppZtNetworkMembers, derrors.Error)
 }
@@ -17,6 +17,7 @@
 package application
 
 import (
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/onsi/ginkgo"
@@ -119,6 +120,63 @@ func RunTest(provider Provider) {
 
 	})
 
+	ginkgo.Context("Descriptor Revisions", func() {
+		ginkgo.It("Should be able to add and get a descriptor revision", func() {
+			descriptor := CreateTestApplicationDescriptor(uuid.New().String())
+			descriptor.Revision = entities.InitialDescriptorRevision
+			revision := entities.NewAppDescriptorRevision(*descriptor)
+
+			err := provider.AddDescriptorRevision(*revision)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			retrieved, err := provider.GetDescriptorRevision(descriptor.AppDescriptorId, entities.InitialDescriptorRevision)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(retrieved.Name).Should(gomega.Equal(descriptor.Name))
+			gomega.Expect(retrieved.Groups).Should(gomega.Equal(descriptor.Groups))
+		})
+		ginkgo.It("Should not be able to add the same revision twice", func() {
+			descriptor := CreateTestApplicationDescriptor(uuid.New().String())
+			descriptor.Revision = entities.InitialDescriptorRevision
+			revision := entities.NewAppDescriptorRevision(*descriptor)
+
+			err := provider.AddDescriptorRevision(*revision)
+			gomega.Expect(err).To(gomega.Succeed())
+			err = provider.AddDescriptorRevision(*revision)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("Should not be able to get a non existing revision", func() {
+			_, err := provider.GetDescriptorRevision(uuid.New().String(), entities.InitialDescriptorRevision)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("Should be able to list the revisions of a descriptor in order", func() {
+			descriptor := CreateTestApplicationDescriptor(uuid.New().String())
+			numRevisions := 3
+			for i := numRevisions; i > 0; i-- {
+				descriptor.Revision = int64(i)
+				err := provider.AddDescriptorRevision(*entities.NewAppDescriptorRevision(*descriptor))
+				gomega.Expect(err).To(gomega.Succeed())
+			}
+			revisions, err := provider.ListDescriptorRevisions(descriptor.AppDescriptorId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(revisions)).Should(gomega.Equal(numRevisions))
+			for i, revision := range revisions {
+				gomega.Expect(revision.Revision).Should(gomega.Equal(int64(i + 1)))
+			}
+		})
+		ginkgo.It("Should be able to remove the revisions of a descriptor", func() {
+			descriptor := CreateTestApplicationDescriptor(uuid.New().String())
+			descriptor.Revision = entities.InitialDescriptorRevision
+			err := provider.AddDescriptorRevision(*entities.NewAppDescriptorRevision(*descriptor))
+			gomega.Expect(err).To(gomega.Succeed())
+
+			err = provider.DeleteDescriptorRevisions(descriptor.AppDescriptorId)
+			gomega.Expect(err).To(gomega.Succeed())
+			revisions, err := provider.ListDescriptorRevisions(descriptor.AppDescriptorId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(revisions).To(gomega.BeEmpty())
+		})
+	})
+
 	ginkgo.Context("Instance", func() {
 		// Add Application Instance
 		ginkgo.It("Should be able to add an application", func() {
@@ -147,6 +205,23 @@ func RunTest(provider Provider) {
 			gomega.Expect(recovered.Status).Should(gomega.Equal(entities.Deploying))
 
 		})
+		ginkgo.It("Should not be able to update a stale application", func() {
+			app := CreateTestApplication(uuid.New().String(), uuid.New().String())
+
+			err := provider.AddInstance(*app)
+			gomega.Expect(err).To(gomega.Succeed())
+			err = provider.UpdateInstance(*app)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			app.Status = entities.Error
+			err = provider.UpdateInstance(*app)
+			gomega.Expect(entities.IsVersionConflict(err)).To(gomega.BeTrue())
+
+			recovered, err := provider.GetInstance(app.AppInstanceId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(recovered.Version).Should(gomega.Equal(int64(1)))
+			gomega.Expect(recovered.Status).ShouldNot(gomega.Equal(entities.Error))
+		})
 		ginkgo.It("Should not be able to update an application", func() {
 			app := CreateTestApplication(uuid.New().String(), uuid.New().String())
 			err := provider.UpdateInstance(*app)
@@ -279,6 +354,42 @@ func RunTest(provider Provider) {
 
 	})
 
+	ginkgo.Context("App endpoint FQDNs", func() {
+		ginkgo.It("should be able to assign a global fqdn only once", func() {
+			fqdn := CreateAppEndpointFqdn()
+			err := provider.AddAppEndpointFqdn(*fqdn)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			other := CreateAppEndpointFqdn()
+			other.GlobalFqdn = fqdn.GlobalFqdn
+			err = provider.AddAppEndpointFqdn(*other)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+
+			retrieved, err := provider.GetAppEndpointFqdn(fqdn.GlobalFqdn)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(retrieved).Should(gomega.Equal(fqdn))
+		})
+		ginkgo.It("should be able to list and release the fqdns of an instance", func() {
+			fqdn := CreateAppEndpointFqdn()
+			err := provider.AddAppEndpointFqdn(*fqdn)
+			gomega.Expect(err).To(gomega.Succeed())
+			second := *fqdn
+			second.GlobalFqdn = fmt.Sprintf("other-%s", fqdn.GlobalFqdn)
+			err = provider.AddAppEndpointFqdn(second)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			list, err := provider.ListAppEndpointFqdns(fqdn.OrganizationId, fqdn.AppInstanceId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(list)).Should(gomega.Equal(2))
+
+			err = provider.DeleteAppEndpointFqdns(fqdn.OrganizationId, fqdn.AppInstanceId)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			_, err = provider.GetAppEndpointFqdn(fqdn.GlobalFqdn)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+	})
+
 	ginkgo.Context("Instance Parameters", func() {
 		ginkgo.It("Should be able to add instance parameters", func() {
 
@@ -439,4 +550,46 @@ func RunTest(provider Provider) {
 
 		})
 	})
+
+	ginkgo.Context("ZT networks", func() {
+		ginkgo.It("Should be able to list the zt networks of an organization", func() {
+			organizationID := uuid.New().String()
+			network := entities.AppZtNetwork{OrganizationId: organizationID, AppInstanceId: uuid.New().String(), ZtNetworkId: uuid.New().String()}
+			err := provider.AddAppZtNetwork(network)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			networks, err := provider.ListAppZtNetworks(organizationID)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(networks)).Should(gomega.Equal(1))
+			gomega.Expect(networks[0].ZtNetworkId).Should(gomega.Equal(network.ZtNetworkId))
+		})
+		ginkgo.It("Should be able to list the zt network members of an organization", func() {
+			organizationID := uuid.New().String()
+			for i := 0; i < 2; i++ {
+				memberID := uuid.New().String()
+				_, err := provider.AddAppZtNetworkMember(entities.AppZtNetworkMembers{
+					OrganizationId:               organizationID,
+					AppInstanceId:                uuid.New().String(),
+					ServiceGroupInstanceId:       uuid.New().String(),
+					ServiceApplicationInstanceId: uuid.New().String(),
+					ZtNetworkId:                  uuid.New().String(),
+					Members:                      map[string]entities.AppNetworkMember{memberID: {MemberId: memberID}},
+				})
+				gomega.Expect(err).To(gomega.Succeed())
+			}
+
+			members, err := provider.ListOrganizationZtNetworkMembers(organizationID)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(members)).Should(gomega.Equal(2))
+		})
+		ginkgo.It("Should return an empty list for an organization without zt networks", func() {
+			networks, err := provider.ListAppZtNetworks(uuid.New().String())
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(networks).To(gomega.BeEmpty())
+
+			members, err := provider.ListOrganizationZtNetworkMembers(uuid.New().String())
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(members).To(gomega.BeEmpty())
+		})
+	})
 }
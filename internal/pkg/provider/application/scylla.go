@@ -22,6 +22,7 @@ import (
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/scylladb-utils/pkg/scylladb"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/versioning"
 	"github.com/rs/zerolog/log"
 	"github.com/scylladb/gocqlx"
 	"github.com/scylladb/gocqlx/qb"
@@ -36,11 +37,19 @@ const ApplicationDescriptorTable = "ApplicationDescriptors"
 const ApplicationDescriptorTablePK = "app_descriptor_id"
 
 var allApplicationDecriptorColumns = []string{"organization_id", "app_descriptor_id", "name", "configuration_options",
-	"environment_variables", "labels", "rules", "groups", "parameters", "inbound_net_interfaces", "outbound_net_interfaces"}
+	"environment_variables", "labels", "rules", "groups", "parameters", "inbound_net_interfaces", "outbound_net_interfaces",
+	"revision", "project_id"}
 
-// 'parameter' column is not included in allApplicationDecriptorColumnsNoPK because the value can not be updated
 var allApplicationDecriptorColumnsNoPK = []string{"organization_id", "name", "configuration_options", "environment_variables",
-	"labels", "rules", "groups", "inbound_net_interfaces", "outbound_net_interfaces"}
+	"labels", "rules", "groups", "parameters", "inbound_net_interfaces", "outbound_net_interfaces", "revision",
+	"project_id"}
+
+// Application descriptor revision const
+const ApplicationDescriptorRevisionTable = "ApplicationDescriptorRevisions"
+
+var allApplicationDescriptorRevisionColumns = []string{"organization_id", "app_descriptor_id", "revision", "created",
+	"name", "configuration_options", "environment_variables", "rules", "groups", "parameters", "inbound_net_interfaces",
+	"outbound_net_interfaces"}
 
 // Application Instance const
 const ApplicationInstanceTable = "ApplicationInstances"
@@ -48,10 +57,14 @@ const ApplicationInstanceTablePK = "app_instance_id"
 
 var allApplicationInstanceColumns = []string{"organization_id", "app_descriptor_id", "app_instance_id",
 	"name", "configuration_options", "environment_variables", "labels", "metadata", "rules", "groups", "status",
-	"inbound_net_interfaces", "outbound_net_interfaces", "info"}
+	"inbound_net_interfaces", "outbound_net_interfaces", "info", "descriptor_revision", "version",
+	"project_id"}
+
+// 'descriptor_revision' column is not included in allApplicationInstanceColumnsNoPK because the value is set when
+// the instance is created and can not be updated
 var allApplicationInstanceColumnsNoPK = []string{"organization_id", "app_descriptor_id",
 	"name", "configuration_options", "environment_variables", "labels", "metadata", "rules", "groups", "status",
-	"inbound_net_interfaces", "outbound_net_interfaces", "info"}
+	"inbound_net_interfaces", "outbound_net_interfaces", "info", "version", "project_id"}
 
 // Parametrized Descriptor const
 const ParametrizedDescriptorTable = "ParametrizedDescriptors"
@@ -70,7 +83,14 @@ var allInstanceParamColumns = []string{"app_instance_id", "parameters"}
 const AppEndpointsTable = "AppEntrypoints"
 
 var allAppEndPointsColumns = []string{"organization_id", "app_instance_id", "service_group_instance_id",
-	"service_instance_id", "port", "endpoint_instance_id", "fqdn", "global_fqdn", "protocol", "type"}
+	"service_instance_id", "port", "endpoint_instance_id", "fqdn", "global_fqdn", "protocol", "type", "http2"}
+
+// AppEndpointFqdnTable is the reverse index from global FQDNs to service group instances.
+const AppEndpointFqdnTable = "AppEndpointFqdns"
+const AppEndpointFqdnTablePK = "global_fqdn"
+
+var allAppEndpointFqdnColumns = []string{"global_fqdn", "organization_id", "app_instance_id",
+	"service_group_instance_id", "service_name"}
 
 const AppZtNetworkTable = "appztnetworks"
 
@@ -175,7 +195,6 @@ func (sp *ScyllaApplicationProvider) UpdateDescriptor(descriptor entities.AppDes
 	sp.Lock()
 	defer sp.Unlock()
 
-	// TODO: parameters can not be updated, review if that is true
 	return sp.UnsafeUpdate(ApplicationDescriptorTable, ApplicationDescriptorTablePK, descriptor.AppDescriptorId, allApplicationDecriptorColumnsNoPK, descriptor)
 }
 
@@ -188,6 +207,110 @@ func (sp *ScyllaApplicationProvider) DeleteDescriptor(appDescriptorID string) de
 	return sp.UnsafeRemove(ApplicationDescriptorTable, ApplicationDescriptorTablePK, appDescriptorID)
 }
 
+// ------------------------------------------------------- //
+// -- Application Descriptor Revisions -------------------- //
+// ------------------------------------------------------- //
+
+func (sp *ScyllaApplicationProvider) createDescriptorRevisionPKMap(appDescriptorID string, revision int64) map[string]interface{} {
+	return map[string]interface{}{
+		"app_descriptor_id": appDescriptorID,
+		"revision":          revision,
+	}
+}
+
+// AddDescriptorRevision stores an immutable revision of a descriptor.
+func (sp *ScyllaApplicationProvider) AddDescriptorRevision(revision entities.AppDescriptorRevision) derrors.Error {
+
+	sp.Lock()
+	defer sp.Unlock()
+
+	if err := sp.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	// Revisions are immutable, a conditional insert guarantees that concurrent writers do not overwrite each other
+	stmt, names := qb.Insert(ApplicationDescriptorRevisionTable).Columns(allApplicationDescriptorRevisionColumns...).Unique().ToCql()
+	q := gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(revision)
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot add descriptor revision")
+	}
+	if !applied {
+		return derrors.NewAlreadyExistsError("descriptor revision").WithParams(revision.AppDescriptorId, revision.Revision)
+	}
+	return nil
+}
+
+// GetDescriptorRevision retrieves a given revision of a descriptor.
+func (sp *ScyllaApplicationProvider) GetDescriptorRevision(appDescriptorID string, revision int64) (*entities.AppDescriptorRevision, derrors.Error) {
+
+	sp.Lock()
+	defer sp.Unlock()
+
+	pk := sp.createDescriptorRevisionPKMap(appDescriptorID, revision)
+	var descriptorRevision interface{} = &entities.AppDescriptorRevision{}
+
+	err := sp.UnsafeCompositeGet(ApplicationDescriptorRevisionTable, pk, allApplicationDescriptorRevisionColumns, &descriptorRevision)
+	if err != nil {
+		return nil, err
+	}
+	return descriptorRevision.(*entities.AppDescriptorRevision), nil
+}
+
+// ListDescriptorRevisions retrieves the revisions of a descriptor ordered by revision number.
+func (sp *ScyllaApplicationProvider) ListDescriptorRevisions(appDescriptorID string) ([]entities.AppDescriptorRevision, derrors.Error) {
+
+	sp.Lock()
+	defer sp.Unlock()
+
+	if err := sp.CheckAndConnect(); err != nil {
+		return nil, err
+	}
+
+	stmt, names := qb.Select(ApplicationDescriptorRevisionTable).Columns(allApplicationDescriptorRevisionColumns...).
+		Where(qb.Eq(ApplicationDescriptorTablePK)).OrderBy("revision", qb.ASC).ToCql()
+	q := gocqlx.Query(sp.Session.Query(stmt), names).BindMap(qb.M{
+		ApplicationDescriptorTablePK: appDescriptorID,
+	})
+
+	revisions := make([]entities.AppDescriptorRevision, 0)
+	cqlErr := q.SelectRelease(&revisions)
+	if cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot list descriptor revisions")
+	}
+	return revisions, nil
+}
+
+// RemoveDescriptorRevision removes a revision of a descriptor that could not be applied.
+func (sp *ScyllaApplicationProvider) RemoveDescriptorRevision(appDescriptorID string, revision int64) derrors.Error {
+
+	sp.Lock()
+	defer sp.Unlock()
+
+	pk := sp.createDescriptorRevisionPKMap(appDescriptorID, revision)
+	return sp.UnsafeCompositeRemove(ApplicationDescriptorRevisionTable, pk)
+}
+
+// DeleteDescriptorRevisions removes all the revisions of a descriptor.
+func (sp *ScyllaApplicationProvider) DeleteDescriptorRevisions(appDescriptorID string) derrors.Error {
+
+	sp.Lock()
+	defer sp.Unlock()
+
+	if err := sp.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, _ := qb.Delete(ApplicationDescriptorRevisionTable).Where(qb.Eq(ApplicationDescriptorTablePK)).ToCql()
+	cqlErr := sp.Session.Query(stmt, appDescriptorID).Exec()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot delete descriptor revisions")
+	}
+	return nil
+}
+
 // -------------------------------------------- //
 // -- Application Instance -------------------- //
 // -------------------------------------------- //
@@ -241,7 +364,22 @@ func (sp *ScyllaApplicationProvider) UpdateInstance(instance entities.AppInstanc
 	sp.Lock()
 	defer sp.Unlock()
 
-	return sp.UnsafeUpdate(ApplicationInstanceTable, ApplicationInstanceTablePK, instance.AppInstanceId, allApplicationInstanceColumnsNoPK, instance)
+	if err := sp.CheckAndConnect(); err != nil {
+		return err
+	}
+	exists, err := sp.UnsafeGenericExist(ApplicationInstanceTable, ApplicationInstanceTablePK, instance.AppInstanceId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("instance").WithParams(instance.AppInstanceId)
+	}
+
+	expected := instance.Version
+	instance.Version++
+	pk := map[string]interface{}{ApplicationInstanceTablePK: instance.AppInstanceId}
+	return versioning.ScyllaUpdate(sp.Session, ApplicationInstanceTable, pk, allApplicationInstanceColumnsNoPK, instance,
+		"instance", instance.AppInstanceId, expected)
 }
 
 // ------------------------------------------- //
@@ -359,8 +497,8 @@ func (sp *ScyllaApplicationProvider) Clear() derrors.Error {
 	sp.Lock()
 	defer sp.Unlock()
 
-	return sp.UnsafeClear([]string{ApplicationDescriptorTable, ApplicationInstanceTable, ParametrizedDescriptorTable, InstanceParamTable,
-		AppEndpointsTable, AppZtNetworkTable})
+	return sp.UnsafeClear([]string{ApplicationDescriptorTable, ApplicationDescriptorRevisionTable, ApplicationInstanceTable,
+		ParametrizedDescriptorTable, InstanceParamTable, AppEndpointsTable, AppEndpointFqdnTable, AppZtNetworkTable})
 
 	err := sp.Session.Query("TRUNCATE TABLE appztnetworkmembers").Exec()
 	if err != nil {
@@ -476,6 +614,95 @@ func (sp *ScyllaApplicationProvider) GetAppEndpointList(organizationID string, a
 	return list, nil
 }
 
+// AddAppEndpointFqdn assigns a global FQDN to a service of a service group instance.
+func (sp *ScyllaApplicationProvider) AddAppEndpointFqdn(fqdn entities.AppEndpointFqdn) derrors.Error {
+	sp.Lock()
+	defer sp.Unlock()
+
+	if err := sp.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	// The FQDN is assigned with a conditional insert, so only one of the concurrent owners gets it
+	stmt, names := qb.Insert(AppEndpointFqdnTable).Columns(allAppEndpointFqdnColumns...).Unique().ToCql()
+	q := gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(fqdn)
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot add global fqdn")
+	}
+	if !applied {
+		return derrors.NewAlreadyExistsError("global fqdn").WithParams(fqdn.GlobalFqdn)
+	}
+	return nil
+}
+
+// GetAppEndpointFqdn retrieves the owner of a global FQDN.
+func (sp *ScyllaApplicationProvider) GetAppEndpointFqdn(globalFqdn string) (*entities.AppEndpointFqdn, derrors.Error) {
+	sp.Lock()
+	defer sp.Unlock()
+
+	var fqdn interface{} = &entities.AppEndpointFqdn{}
+	err := sp.UnsafeGet(AppEndpointFqdnTable, AppEndpointFqdnTablePK, globalFqdn, allAppEndpointFqdnColumns, &fqdn)
+	if err != nil {
+		return nil, err
+	}
+	return fqdn.(*entities.AppEndpointFqdn), nil
+}
+
+// ListAppEndpointFqdns retrieves the global FQDNs assigned to an application instance.
+func (sp *ScyllaApplicationProvider) ListAppEndpointFqdns(organizationID string, appInstanceID string) ([]entities.AppEndpointFqdn, derrors.Error) {
+	sp.Lock()
+	defer sp.Unlock()
+
+	return sp.unsafeListAppEndpointFqdns(organizationID, appInstanceID)
+}
+
+func (sp *ScyllaApplicationProvider) unsafeListAppEndpointFqdns(organizationID string, appInstanceID string) ([]entities.AppEndpointFqdn, derrors.Error) {
+	if err := sp.CheckAndConnect(); err != nil {
+		return nil, err
+	}
+
+	stmt, names := qb.Select(AppEndpointFqdnTable).Columns(allAppEndpointFqdnColumns...).
+		Where(qb.Eq("app_instance_id")).ToCql()
+	q := gocqlx.Query(sp.Session.Query(stmt), names).BindMap(qb.M{
+		"app_instance_id": appInstanceID,
+	})
+
+	fqdns := make([]entities.AppEndpointFqdn, 0)
+	cqlErr := q.SelectRelease(&fqdns)
+	if cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot list app endpoint fqdns")
+	}
+
+	result := make([]entities.AppEndpointFqdn, 0, len(fqdns))
+	for _, fqdn := range fqdns {
+		if fqdn.OrganizationId == organizationID {
+			result = append(result, fqdn)
+		}
+	}
+	return result, nil
+}
+
+// DeleteAppEndpointFqdns releases the global FQDNs assigned to an application instance.
+func (sp *ScyllaApplicationProvider) DeleteAppEndpointFqdns(organizationID string, appInstanceID string) derrors.Error {
+	sp.Lock()
+	defer sp.Unlock()
+
+	fqdns, err := sp.unsafeListAppEndpointFqdns(organizationID, appInstanceID)
+	if err != nil {
+		return err
+	}
+	for _, fqdn := range fqdns {
+		err = sp.UnsafeRemove(AppEndpointFqdnTable, AppEndpointFqdnTablePK, fqdn.GlobalFqdn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // TODO: no changes apply in these methods because the ZT is going to disappear
 // ---------------------------------------------------------------------------------------------------------------------
 // AppZtNetwork related methods
@@ -613,6 +840,28 @@ func (sp *ScyllaApplicationProvider) AddZtNetworkProxy(proxy entities.ServicePro
 	return nil
 }
 
+// ListAppZtNetworks retrieves the zt networks of the application instances of an organization
+func (sp *ScyllaApplicationProvider) ListAppZtNetworks(organizationId string) ([]entities.AppZtNetwork, derrors.Error) {
+	sp.Lock()
+	defer sp.Unlock()
+
+	if err := sp.CheckAndConnect(); err != nil {
+		return nil, err
+	}
+
+	stmt, names := qb.Select("appztnetworks").Columns("organization_id", "app_instance_id", "zt_network_id", "vsa_list", "available_proxies").
+		Where(qb.Eq("organization_id")).AllowFiltering().ToCql()
+	q := gocqlx.Query(sp.Session.Query(stmt), names).BindMap(qb.M{
+		"organization_id": organizationId,
+	})
+
+	list := make([]entities.AppZtNetwork, 0)
+	if cqlErr := q.SelectRelease(&list); cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot list app zt networks")
+	}
+	return list, nil
+}
+
 // RemoveZtNetworkProxy remove an existing zt service proxy
 func (sp *ScyllaApplicationProvider) RemoveZtNetworkProxy(organizationId string, appInstanceId string, fqdn string, clusterId string, serviceGroupInstanceId string, serviceInstanceId string) derrors.Error {
 	sp.Lock()
@@ -656,7 +905,7 @@ func (sp *ScyllaApplicationProvider) RemoveZtNetworkProxy(organizationId string,
 			// look for it and remove it
 			indexToDelete := -1
 			for i, proxy := range clusterEntries {
-				if proxy.ServiceInstanceId == serviceInstanceId && proxy.ServiceGroupInstanceId == serviceInstanceId {
+				if proxy.ServiceInstanceId == serviceInstanceId && proxy.ServiceGroupInstanceId == serviceGroupInstanceId {
 					indexToDelete = i
 					break
 				}
@@ -857,3 +1106,26 @@ func (sp *ScyllaApplicationProvider) ListAppZtNetworkMembers(organizationId stri
 
 	return list, nil
 }
+
+// ListOrganizationZtNetworkMembers retrieves the members of all the zt networks of an organization
+func (sp *ScyllaApplicationProvider) ListOrganizationZtNetworkMembers(organizationId string) ([]*entities.AppZtNetworkMembers, derrors.Error) {
+	sp.Lock()
+	defer sp.Unlock()
+
+	if err := sp.CheckAndConnect(); err != nil {
+		return nil, err
+	}
+
+	stmt, names := qb.Select("appztnetworkmembers").Columns("organization_id", "app_instance_id",
+		"service_group_instance_id", "service_application_instance_id", "zt_network_id", "members").
+		Where(qb.Eq("organization_id")).AllowFiltering().ToCql()
+	q := gocqlx.Query(sp.Session.Query(stmt), names).BindMap(qb.M{
+		"organization_id": organizationId,
+	})
+
+	list := make([]*entities.AppZtNetworkMembers, 0)
+	if cqlErr := gocqlx.Select(&list, q.Query); cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot list app ztNetwork members")
+	}
+	return list, nil
+}
@@ -420,3 +420,13 @@ func CreateAppEndPoint() *entities.AppEndpoint {
 		GlobalFqdn:             fmt.Sprintf("%d.globaldomain.es", rand.Int()),
 	}
 }
+
+func CreateAppEndpointFqdn() *entities.AppEndpointFqdn {
+	return &entities.AppEndpointFqdn{
+		GlobalFqdn:             fmt.Sprintf("service-%d.sg.app.org", rand.Int()),
+		OrganizationId:         uuid.New().String(),
+		AppInstanceId:          uuid.New().String(),
+		ServiceGroupInstanceId: uuid.New().String(),
+		ServiceName:            "service",
+	}
+}
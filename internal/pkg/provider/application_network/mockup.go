@@ -267,6 +267,20 @@ func (m *MockupApplicationNetworkProvider) GetConnectionInstanceLink(organizatio
 	return nil, derrors.NewNotFoundError("ConnectionInstance").WithParams(organizationId)
 }
 
+// UpdateConnectionInstanceLink Updates a connection instance link
+func (m *MockupApplicationNetworkProvider) UpdateConnectionInstanceLink(link entities.ConnectionInstanceLink) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	compositePK := getCompositePK(link.OrganizationId, link.SourceInstanceId, link.TargetInstanceId, link.InboundName, link.OutboundName)
+	for index, existing := range m.connectionInstanceLinks[compositePK] {
+		if existing.SourceClusterId == link.SourceClusterId && existing.TargetClusterId == link.TargetClusterId {
+			m.connectionInstanceLinks[compositePK][index] = link
+			return nil
+		}
+	}
+	return derrors.NewNotFoundError("ConnectionInstanceLink").WithParams(link.OrganizationId, link.SourceClusterId, link.TargetClusterId)
+}
+
 // ListConnectionInstanceLinks Retrieves a list with all the links from a connection instance
 func (m *MockupApplicationNetworkProvider) ListConnectionInstanceLinks(organizationId string, sourceInstanceId string, targetInstanceId string, inboundName string, outboundName string) ([]entities.ConnectionInstanceLink, derrors.Error) {
 	m.Lock()
@@ -48,6 +48,8 @@ type Provider interface {
 	ExistsConnectionInstanceLink(organizationId string, sourceInstanceId string, targetInstanceId string, sourceClusterId string, targetClusterId string, inboundName string, outboundName string) (bool, derrors.Error)
 	// GetConnectionInstanceLink Retrieve the connection instance.
 	GetConnectionInstanceLink(organizationId string, sourceInstanceId string, targetInstanceId string, sourceClusterId string, targetClusterId string, inboundName string, outboundName string) (*entities.ConnectionInstanceLink, derrors.Error)
+	// UpdateConnectionInstanceLink Updates the status of a connection instance link.
+	UpdateConnectionInstanceLink(connectionInstanceLink entities.ConnectionInstanceLink) derrors.Error
 	// ListConnectionInstanceLinks Lists all the connection instance links of one connection instance.
 	ListConnectionInstanceLinks(organizationId string, sourceInstanceId string, targetInstanceId string, inboundName string, outboundName string) ([]entities.ConnectionInstanceLink, derrors.Error)
 	// RemoveConnectionInstanceLinks Removes all connection links from a connection instance.
@@ -395,6 +395,43 @@ func RunTest(provider Provider) {
 			gomega.Expect(*link).To(gomega.Equal(toAdd))
 		})
 
+		ginkgo.It("should be able to update the status of a ConnectionInstanceLink", func() {
+			instance := entities.ConnectionInstance{
+				OrganizationId:   entities.GenerateUUID(),
+				ConnectionId:     entities.GenerateUUID(),
+				SourceInstanceId: entities.GenerateUUID(),
+				TargetInstanceId: entities.GenerateUUID(),
+				InboundName:      entities.GenerateUUID(),
+				OutboundName:     entities.GenerateUUID(),
+				Status:           entities.ConnectionStatusWaiting,
+			}
+			err := provider.AddConnectionInstance(instance)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			toAdd := entities.ConnectionInstanceLink{
+				OrganizationId:   instance.OrganizationId,
+				ConnectionId:     instance.ConnectionId,
+				SourceInstanceId: instance.SourceInstanceId,
+				SourceClusterId:  entities.GenerateUUID(),
+				TargetInstanceId: instance.TargetInstanceId,
+				TargetClusterId:  entities.GenerateUUID(),
+				InboundName:      instance.InboundName,
+				OutboundName:     instance.OutboundName,
+				Status:           entities.ConnectionStatusWaiting,
+			}
+			err = provider.AddConnectionInstanceLink(toAdd)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			toAdd.Status = entities.ConnectionStatusEstablished
+			err = provider.UpdateConnectionInstanceLink(toAdd)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			link, err := provider.GetConnectionInstanceLink(toAdd.OrganizationId, toAdd.SourceInstanceId, toAdd.TargetInstanceId,
+				toAdd.SourceClusterId, toAdd.TargetClusterId, toAdd.InboundName, toAdd.OutboundName)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(link.Status).Should(gomega.Equal(entities.ConnectionStatusEstablished))
+		})
+
 		ginkgo.It("should be able to list all the ConnectionInstanceLinks associated to a ConnectionInstance", func() {
 			instance := entities.ConnectionInstance{
 				OrganizationId:     entities.GenerateUUID(),
@@ -68,6 +68,10 @@ var (
 		"outbound_name",
 		"status",
 	}
+	ConnectionInstanceLinkColumnsNoPK = []string{
+		"connection_id",
+		"status",
+	}
 
 	ZTConnectionColumns = []string{
 		"organization_id",
@@ -307,6 +311,21 @@ func (sap *ScyllaApplicationNetworkProvider) GetConnectionInstanceLink(organizat
 	return result.(*entities.ConnectionInstanceLink), nil
 }
 
+func (sap *ScyllaApplicationNetworkProvider) UpdateConnectionInstanceLink(connectionInstanceLink entities.ConnectionInstanceLink) derrors.Error {
+	sap.Lock()
+	defer sap.Unlock()
+	pkComposite := sap.createConnectionInstanceLinkPkMap(
+		connectionInstanceLink.OrganizationId,
+		connectionInstanceLink.SourceInstanceId,
+		connectionInstanceLink.TargetInstanceId,
+		connectionInstanceLink.SourceClusterId,
+		connectionInstanceLink.TargetClusterId,
+		connectionInstanceLink.InboundName,
+		connectionInstanceLink.OutboundName,
+	)
+	return sap.UnsafeCompositeUpdate(ConnectionInsanceLinkTable, pkComposite, ConnectionInstanceLinkColumnsNoPK, connectionInstanceLink)
+}
+
 func (sap *ScyllaApplicationNetworkProvider) ListConnectionInstanceLinks(organizationId string, sourceInstanceId string, targetInstanceId string, inboundName string, outboundName string) ([]entities.ConnectionInstanceLink, derrors.Error) {
 	sap.Lock()
 	defer sap.Unlock()
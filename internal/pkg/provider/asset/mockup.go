@@ -56,6 +56,10 @@ func (m *MockupAssetProvider) Update(asset entities.Asset) derrors.Error {
 	if !m.unsafeExists(asset.AssetId) {
 		return derrors.NewNotFoundError(asset.AssetId)
 	}
+	if m.assets[asset.AssetId].Version != asset.Version {
+		return entities.NewVersionConflictError("asset", asset.AssetId, asset.Version)
+	}
+	asset.Version++
 	m.assets[asset.AssetId] = asset
 	return nil
 }
@@ -25,7 +25,8 @@ import (
 type Provider interface {
 	// Add a new asset to the system.
 	Add(asset entities.Asset) derrors.Error
-	// Update the information of an asset.
+	// Update the information of an asset. The update fails with a version conflict if the version of the asset
+	// does not match the stored one, and increases the stored version otherwise.
 	Update(asset entities.Asset) derrors.Error
 	// Exists checks if an asset exists on the system.
 	Exists(assetID string) (bool, derrors.Error)
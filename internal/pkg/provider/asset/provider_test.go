@@ -59,6 +59,17 @@ func RunTest(provider Provider) {
 		gomega.Expect(err).To(gomega.Succeed())
 	})
 
+	ginkgo.It("should not be able to update a stale asset", func() {
+		toAdd := CreateTestAsset()
+		err := provider.Add(*toAdd)
+		gomega.Expect(err).To(gomega.Succeed())
+		err = provider.Update(*toAdd)
+		gomega.Expect(err).To(gomega.Succeed())
+		toAdd.EicNetIp = "2.2.2.2"
+		err = provider.Update(*toAdd)
+		gomega.Expect(entities.IsVersionConflict(err)).To(gomega.BeTrue())
+	})
+
 	ginkgo.It("should be able to retrieve an asset", func() {
 		toAdd := CreateTestAsset()
 		err := provider.Add(*toAdd)
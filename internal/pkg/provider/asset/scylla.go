@@ -20,6 +20,7 @@ import (
 	"github.com/nalej/derrors"
 	"github.com/nalej/scylladb-utils/pkg/scylladb"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/versioning"
 	"github.com/rs/zerolog/log"
 	"github.com/scylladb/gocqlx"
 	"github.com/scylladb/gocqlx/qb"
@@ -34,11 +35,13 @@ const AssetTablePK = "asset_id"
 
 // AllAssetColumns contains the name of all the columns in the asset table.
 var allAssetColumns = []string{"organization_id", "edge_controller_id", "asset_id", "agent_id", "show",
-	"created", "labels", "os", "hardware", "storage", "eic_net_ip", "last_alive_timestamp", "last_op_result", "location"}
+	"created", "labels", "os", "hardware", "storage", "eic_net_ip", "last_alive_timestamp", "last_op_result", "location",
+	"version"}
 
 // AllAssetColumnsNoPK contains the name of all the columns in the asset table except the PK.
 var allAssetColumnsNoPK = []string{"organization_id", "edge_controller_id", "agent_id", "show",
-	"created", "labels", "os", "hardware", "storage", "eic_net_ip", "last_alive_timestamp", "last_op_result", "location"}
+	"created", "labels", "os", "hardware", "storage", "eic_net_ip", "last_alive_timestamp", "last_op_result", "location",
+	"version"}
 
 type ScyllaAssetProvider struct {
 	scylladb.ScyllaDB
@@ -75,7 +78,21 @@ func (sp *ScyllaAssetProvider) Add(asset entities.Asset) derrors.Error {
 func (sp *ScyllaAssetProvider) Update(asset entities.Asset) derrors.Error {
 	sp.Lock()
 	defer sp.Unlock()
-	return sp.UnsafeUpdate(AssetTable, AssetTablePK, asset.AssetId, allAssetColumnsNoPK, asset)
+	if err := sp.CheckAndConnect(); err != nil {
+		return err
+	}
+	exists, err := sp.UnsafeGenericExist(AssetTable, AssetTablePK, asset.AssetId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("asset").WithParams(asset.AssetId)
+	}
+
+	expected := asset.Version
+	asset.Version++
+	pk := map[string]interface{}{AssetTablePK: asset.AssetId}
+	return versioning.ScyllaUpdate(sp.Session, AssetTable, pk, allAssetColumnsNoPK, asset, "asset", asset.AssetId, expected)
 }
 
 func (sp *ScyllaAssetProvider) Exists(assetID string) (bool, derrors.Error) {
@@ -0,0 +1,176 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package blob
+
+import (
+	"encoding/json"
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// blobDataExtension with the extension of the files with the content of the blobs.
+const blobDataExtension = ".data"
+
+// blobMetadataExtension with the extension of the files with the metadata of the blobs.
+const blobMetadataExtension = ".json"
+
+// FileSystemBlobProvider keeps each blob in a local directory as a file with the content and a file with the metadata.
+type FileSystemBlobProvider struct {
+	sync.Mutex
+	// Directory where the blobs are stored.
+	Directory string
+}
+
+func NewFileSystemBlobProvider(directory string) *FileSystemBlobProvider {
+	return &FileSystemBlobProvider{
+		Directory: directory,
+	}
+}
+
+// paths returns the path of the content and the metadata of a blob, rejecting the identifiers that are not a file name.
+func (fs *FileSystemBlobProvider) paths(blobID string) (string, string, derrors.Error) {
+	if blobID == "" || blobID == "." || blobID == ".." || strings.ContainsAny(blobID, `/\`) {
+		return "", "", derrors.NewInvalidArgumentError("invalid blob identifier").WithParams(blobID)
+	}
+	base := filepath.Join(fs.Directory, blobID)
+	return base + blobDataExtension, base + blobMetadataExtension, nil
+}
+
+// Add a new blob. The metadata is written after the content, so a blob is not found until it is complete.
+func (fs *FileSystemBlobProvider) Add(blob entities.Blob) derrors.Error {
+	fs.Lock()
+	defer fs.Unlock()
+
+	dataPath, metadataPath, err := fs.paths(blob.BlobId)
+	if err != nil {
+		return err
+	}
+	if _, sErr := os.Stat(metadataPath); sErr == nil {
+		return derrors.NewAlreadyExistsError("blob").WithParams(blob.BlobId)
+	}
+	metadata, mErr := json.Marshal(blob)
+	if mErr != nil {
+		return derrors.AsError(mErr, "cannot marshal blob metadata")
+	}
+	if dErr := os.MkdirAll(fs.Directory, 0700); dErr != nil {
+		return derrors.AsError(dErr, "cannot create blob directory")
+	}
+	if wErr := ioutil.WriteFile(dataPath, blob.Data, 0600); wErr != nil {
+		return derrors.AsError(wErr, "cannot write blob")
+	}
+	if wErr := ioutil.WriteFile(metadataPath, metadata, 0600); wErr != nil {
+		os.Remove(dataPath)
+		return derrors.AsError(wErr, "cannot write blob metadata")
+	}
+	return nil
+}
+
+// Exists checks if a blob exists.
+func (fs *FileSystemBlobProvider) Exists(blobID string) (bool, derrors.Error) {
+	fs.Lock()
+	defer fs.Unlock()
+
+	_, metadataPath, err := fs.paths(blobID)
+	if err != nil {
+		return false, err
+	}
+	_, sErr := os.Stat(metadataPath)
+	if sErr != nil {
+		if os.IsNotExist(sErr) {
+			return false, nil
+		}
+		return false, derrors.AsError(sErr, "cannot check blob")
+	}
+	return true, nil
+}
+
+// Get a blob with its content.
+func (fs *FileSystemBlobProvider) Get(blobID string) (*entities.Blob, derrors.Error) {
+	fs.Lock()
+	defer fs.Unlock()
+
+	dataPath, metadataPath, err := fs.paths(blobID)
+	if err != nil {
+		return nil, err
+	}
+	metadata, rErr := ioutil.ReadFile(metadataPath)
+	if rErr != nil {
+		if os.IsNotExist(rErr) {
+			return nil, derrors.NewNotFoundError("blob").WithParams(blobID)
+		}
+		return nil, derrors.AsError(rErr, "cannot read blob metadata")
+	}
+	blob := &entities.Blob{}
+	if uErr := json.Unmarshal(metadata, blob); uErr != nil {
+		return nil, derrors.AsError(uErr, "cannot unmarshal blob metadata")
+	}
+	data, rErr := ioutil.ReadFile(dataPath)
+	if rErr != nil {
+		return nil, derrors.AsError(rErr, "cannot read blob")
+	}
+	blob.Data = data
+	return blob, nil
+}
+
+// Remove a blob.
+func (fs *FileSystemBlobProvider) Remove(blobID string) derrors.Error {
+	fs.Lock()
+	defer fs.Unlock()
+
+	dataPath, metadataPath, err := fs.paths(blobID)
+	if err != nil {
+		return err
+	}
+	if rErr := os.Remove(metadataPath); rErr != nil {
+		if os.IsNotExist(rErr) {
+			return derrors.NewNotFoundError("blob").WithParams(blobID)
+		}
+		return derrors.AsError(rErr, "cannot remove blob metadata")
+	}
+	if rErr := os.Remove(dataPath); rErr != nil && !os.IsNotExist(rErr) {
+		return derrors.AsError(rErr, "cannot remove blob")
+	}
+	return nil
+}
+
+// Clear removes the files of the blobs in the directory.
+func (fs *FileSystemBlobProvider) Clear() derrors.Error {
+	fs.Lock()
+	defer fs.Unlock()
+
+	files, rErr := ioutil.ReadDir(fs.Directory)
+	if rErr != nil {
+		if os.IsNotExist(rErr) {
+			return nil
+		}
+		return derrors.AsError(rErr, "cannot read blob directory")
+	}
+	for _, file := range files {
+		name := file.Name()
+		if strings.HasSuffix(name, blobDataExtension) || strings.HasSuffix(name, blobMetadataExtension) {
+			if rErr := os.Remove(filepath.Join(fs.Directory, name)); rErr != nil {
+				return derrors.AsError(rErr, "cannot remove blob file")
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package blob
+
+import (
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+)
+
+var _ = ginkgo.Describe("File system blob provider", func() {
+
+	directory, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		ginkgo.Fail("cannot create blob directory")
+	}
+	sp := NewFileSystemBlobProvider(directory)
+
+	// the directory is created again when a blob is added
+	ginkgo.AfterEach(func() {
+		os.RemoveAll(directory)
+	})
+
+	RunTest(sp)
+
+	ginkgo.It("should reject identifiers that are not a file name", func() {
+		blob := entities.NewBlob("image/png", []byte("content"))
+		blob.BlobId = "../outside"
+		gomega.Expect(sp.Add(*blob)).NotTo(gomega.Succeed())
+		_, err := sp.Get("../outside")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package blob
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"sync"
+)
+
+type MockupBlobProvider struct {
+	sync.Mutex
+	// blobs indexed by blob
+	blobs map[string]entities.Blob
+}
+
+func NewMockupBlobProvider() *MockupBlobProvider {
+	return &MockupBlobProvider{
+		blobs: make(map[string]entities.Blob, 0),
+	}
+}
+
+// Add a new blob.
+func (m *MockupBlobProvider) Add(blob entities.Blob) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, exists := m.blobs[blob.BlobId]; exists {
+		return derrors.NewAlreadyExistsError("blob").WithParams(blob.BlobId)
+	}
+	blob.Data = append([]byte{}, blob.Data...)
+	m.blobs[blob.BlobId] = blob
+	return nil
+}
+
+// Exists checks if a blob exists.
+func (m *MockupBlobProvider) Exists(blobID string) (bool, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	_, exists := m.blobs[blobID]
+	return exists, nil
+}
+
+// Get a blob with its content.
+func (m *MockupBlobProvider) Get(blobID string) (*entities.Blob, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	blob, exists := m.blobs[blobID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("blob").WithParams(blobID)
+	}
+	blob.Data = append([]byte{}, blob.Data...)
+	return &blob, nil
+}
+
+// Remove a blob.
+func (m *MockupBlobProvider) Remove(blobID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, exists := m.blobs[blobID]; !exists {
+		return derrors.NewNotFoundError("blob").WithParams(blobID)
+	}
+	delete(m.blobs, blobID)
+	return nil
+}
+
+func (m *MockupBlobProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.blobs = make(map[string]entities.Blob, 0)
+	return nil
+}
@@ -0,0 +1,37 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package blob
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+)
+
+// Provider for the blob store that keeps binary content, such as the photos of the organizations and users, apart
+// from the entities that reference it. Blobs are immutable, a new blob is added to change the content.
+type Provider interface {
+	// Add a new blob.
+	Add(blob entities.Blob) derrors.Error
+	// Exists checks if a blob exists.
+	Exists(blobID string) (bool, derrors.Error)
+	// Get a blob with its content.
+	Get(blobID string) (*entities.Blob, derrors.Error)
+	// Remove a blob.
+	Remove(blobID string) derrors.Error
+
+	Clear() derrors.Error
+}
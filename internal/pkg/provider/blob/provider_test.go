@@ -0,0 +1,72 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package blob
+
+import (
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+func RunTest(provider Provider) {
+
+	ginkgo.AfterEach(func() {
+		provider.Clear()
+	})
+
+	ginkgo.It("should be able to add a blob", func() {
+		blob := entities.NewBlob("image/png", []byte("content"))
+		err := provider.Add(*blob)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		err = provider.Add(*blob)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+
+		exists, err := provider.Exists(blob.BlobId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should be able to get a blob with its content", func() {
+		// larger than a chunk of the scylla provider
+		data := make([]byte, 200*1024)
+		for i := range data {
+			data[i] = byte(i % 251)
+		}
+		blob := entities.NewBlob("image/jpeg", data)
+		gomega.Expect(provider.Add(*blob)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get(blob.BlobId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved).To(gomega.Equal(blob))
+
+		_, err = provider.Get(entities.GenerateUUID())
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to remove a blob", func() {
+		blob := entities.NewBlob("image/png", []byte("content"))
+		gomega.Expect(provider.Add(*blob)).To(gomega.Succeed())
+
+		gomega.Expect(provider.Remove(blob.BlobId)).To(gomega.Succeed())
+		exists, err := provider.Exists(blob.BlobId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).To(gomega.BeFalse())
+
+		gomega.Expect(provider.Remove(blob.BlobId)).NotTo(gomega.Succeed())
+	})
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package blob
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/scylladb-utils/pkg/scylladb"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/scylladb/gocqlx"
+	"github.com/scylladb/gocqlx/qb"
+	"sync"
+)
+
+const blobTable = "Blobs"
+const blobTablePK = "blob_id"
+const blobChunkTable = "BlobChunks"
+
+// blobChunkSize with the maximum size in bytes of each chunk of the content of a blob.
+const blobChunkSize = 64 * 1024
+
+var blobTableColumns = []string{"blob_id", "content_type", "size", "created"}
+var blobChunkTableColumns = []string{"blob_id", "chunk", "data"}
+
+// blobChunk with a part of the content of a blob.
+type blobChunk struct {
+	BlobId string `cql:"blob_id"`
+	Chunk  int    `cql:"chunk"`
+	Data   []byte `cql:"data"`
+}
+
+// ScyllaBlobProvider keeps the metadata of the blobs in a table and their content split in chunks in another.
+type ScyllaBlobProvider struct {
+	scylladb.ScyllaDB
+	sync.Mutex
+}
+
+func NewScyllaBlobProvider(address string, port int, keyspace string) *ScyllaBlobProvider {
+	provider := ScyllaBlobProvider{
+		ScyllaDB: scylladb.ScyllaDB{
+			Address:  address,
+			Port:     port,
+			Keyspace: keyspace,
+		},
+	}
+	provider.Connect()
+	return &provider
+}
+
+// Add a new blob. The metadata is written after the chunks, so a blob is not found until it is complete.
+func (s *ScyllaBlobProvider) Add(blob entities.Blob) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	exists, err := s.UnsafeGenericExist(blobTable, blobTablePK, blob.BlobId)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return derrors.NewAlreadyExistsError("blob").WithParams(blob.BlobId)
+	}
+
+	stmt, names := qb.Insert(blobChunkTable).Columns(blobChunkTableColumns...).ToCql()
+	for chunk, start := 0, 0; start < len(blob.Data); chunk, start = chunk+1, start+blobChunkSize {
+		end := start + blobChunkSize
+		if end > len(blob.Data) {
+			end = len(blob.Data)
+		}
+		q := gocqlx.Query(s.Session.Query(stmt), names).BindStruct(blobChunk{blob.BlobId, chunk, blob.Data[start:end]})
+		if cqlErr := q.ExecRelease(); cqlErr != nil {
+			s.unsafeRemoveChunks(blob.BlobId)
+			return derrors.AsError(cqlErr, "cannot add blob chunk")
+		}
+	}
+	err = s.UnsafeAdd(blobTable, blobTablePK, blob.BlobId, blobTableColumns, blob)
+	if err != nil {
+		s.unsafeRemoveChunks(blob.BlobId)
+		return err
+	}
+	return nil
+}
+
+// Exists checks if a blob exists.
+func (s *ScyllaBlobProvider) Exists(blobID string) (bool, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.UnsafeGenericExist(blobTable, blobTablePK, blobID)
+}
+
+// Get a blob with its content.
+func (s *ScyllaBlobProvider) Get(blobID string) (*entities.Blob, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var blob interface{} = &entities.Blob{}
+	err := s.UnsafeGet(blobTable, blobTablePK, blobID, blobTableColumns, &blob)
+	if err != nil {
+		return nil, err
+	}
+	result := blob.(*entities.Blob)
+
+	stmt, names := qb.Select(blobChunkTable).Columns(blobChunkTableColumns...).Where(qb.Eq(blobTablePK)).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		blobTablePK: blobID,
+	})
+	chunks := make([]blobChunk, 0)
+	cqlErr := q.SelectRelease(&chunks)
+	if cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot get blob chunks")
+	}
+	// the chunks are sorted by the clustering key
+	result.Data = make([]byte, 0, result.Size)
+	for _, chunk := range chunks {
+		result.Data = append(result.Data, chunk.Data...)
+	}
+	if int64(len(result.Data)) != result.Size {
+		return nil, derrors.NewInternalError("incomplete blob").WithParams(blobID, len(result.Data), result.Size)
+	}
+	return result, nil
+}
+
+// Remove a blob.
+func (s *ScyllaBlobProvider) Remove(blobID string) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	err := s.UnsafeRemove(blobTable, blobTablePK, blobID)
+	if err != nil {
+		return err
+	}
+	return s.unsafeRemoveChunks(blobID)
+}
+
+// unsafeRemoveChunks removes the content of a blob.
+func (s *ScyllaBlobProvider) unsafeRemoveChunks(blobID string) derrors.Error {
+	stmt, names := qb.Delete(blobChunkTable).Where(qb.Eq(blobTablePK)).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		blobTablePK: blobID,
+	})
+	if cqlErr := q.ExecRelease(); cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot remove blob chunks")
+	}
+	return nil
+}
+
+func (s *ScyllaBlobProvider) Clear() derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.UnsafeClear([]string{blobTable, blobChunkTable})
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"sync"
+)
+
+type MockupCatalogProvider struct {
+	sync.Mutex
+	// entries indexed by catalog entry identifier and version.
+	entries map[string]map[string]entities.CatalogEntry
+}
+
+func NewMockupCatalogProvider() *MockupCatalogProvider {
+	return &MockupCatalogProvider{
+		entries: make(map[string]map[string]entities.CatalogEntry, 0),
+	}
+}
+
+func (m *MockupCatalogProvider) unsafeExists(catalogEntryID string, version string) bool {
+	versions, exists := m.entries[catalogEntryID]
+	if !exists {
+		return false
+	}
+	_, exists = versions[version]
+	return exists
+}
+
+// Add a new version of a catalog entry.
+func (m *MockupCatalogProvider) Add(entry entities.CatalogEntry) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.unsafeExists(entry.CatalogEntryId, entry.Version) {
+		return derrors.NewAlreadyExistsError("catalog entry").WithParams(entry.CatalogEntryId, entry.Version)
+	}
+	versions, exists := m.entries[entry.CatalogEntryId]
+	if !exists {
+		versions = make(map[string]entities.CatalogEntry, 0)
+		m.entries[entry.CatalogEntryId] = versions
+	}
+	versions[entry.Version] = entry
+	return nil
+}
+
+// Exists checks if a version of a catalog entry exists.
+func (m *MockupCatalogProvider) Exists(catalogEntryID string, version string) (bool, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.unsafeExists(catalogEntryID, version), nil
+}
+
+// Get a version of a catalog entry.
+func (m *MockupCatalogProvider) Get(catalogEntryID string, version string) (*entities.CatalogEntry, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	entry, exists := m.entries[catalogEntryID][version]
+	if !exists {
+		return nil, derrors.NewNotFoundError("catalog entry").WithParams(catalogEntryID, version)
+	}
+	return &entry, nil
+}
+
+// ListVersions retrieves all the versions of a catalog entry.
+func (m *MockupCatalogProvider) ListVersions(catalogEntryID string) ([]entities.CatalogEntry, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	result := make([]entities.CatalogEntry, 0)
+	for _, entry := range m.entries[catalogEntryID] {
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// List retrieves all the versions of all the catalog entries.
+func (m *MockupCatalogProvider) List() ([]entities.CatalogEntry, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	result := make([]entities.CatalogEntry, 0)
+	for _, versions := range m.entries {
+		for _, entry := range versions {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// Remove a version of a catalog entry.
+func (m *MockupCatalogProvider) Remove(catalogEntryID string, version string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.unsafeExists(catalogEntryID, version) {
+		return derrors.NewNotFoundError("catalog entry").WithParams(catalogEntryID, version)
+	}
+	delete(m.entries[catalogEntryID], version)
+	if len(m.entries[catalogEntryID]) == 0 {
+		delete(m.entries, catalogEntryID)
+	}
+	return nil
+}
+
+func (m *MockupCatalogProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.entries = make(map[string]map[string]entities.CatalogEntry, 0)
+	return nil
+}
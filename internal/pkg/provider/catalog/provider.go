@@ -0,0 +1,40 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+)
+
+// Provider for the application catalog.
+type Provider interface {
+	// Add a new version of a catalog entry.
+	Add(entry entities.CatalogEntry) derrors.Error
+	// Exists checks if a version of a catalog entry exists.
+	Exists(catalogEntryID string, version string) (bool, derrors.Error)
+	// Get a version of a catalog entry.
+	Get(catalogEntryID string, version string) (*entities.CatalogEntry, derrors.Error)
+	// ListVersions retrieves all the versions of a catalog entry.
+	ListVersions(catalogEntryID string) ([]entities.CatalogEntry, derrors.Error)
+	// List retrieves all the versions of all the catalog entries.
+	List() ([]entities.CatalogEntry, derrors.Error)
+	// Remove a version of a catalog entry.
+	Remove(catalogEntryID string, version string) derrors.Error
+
+	Clear() derrors.Error
+}
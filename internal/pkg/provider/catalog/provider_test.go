@@ -0,0 +1,147 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"sync"
+)
+
+func RunTest(provider Provider) {
+
+	ginkgo.AfterEach(func() {
+		provider.Clear()
+	})
+
+	ginkgo.Context("adding a catalog entry", func() {
+		ginkgo.It("should be able to add an entry", func() {
+			entry := CreateCatalogEntry("", "1.0.0")
+			err := provider.Add(*entry)
+			gomega.Expect(err).To(gomega.Succeed())
+		})
+		ginkgo.It("should be able to add several versions of an entry", func() {
+			entry := CreateCatalogEntry("", "1.0.0")
+			err := provider.Add(*entry)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			err = provider.Add(*CreateCatalogEntry(entry.CatalogEntryId, "1.1.0"))
+			gomega.Expect(err).To(gomega.Succeed())
+		})
+		ginkgo.It("should not be able to add the same version twice", func() {
+			entry := CreateCatalogEntry("", "1.0.0")
+			err := provider.Add(*entry)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			err = provider.Add(*entry)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should add a single copy of a version added concurrently", func() {
+			entry := CreateCatalogEntry("", "1.0.0")
+			numPublishers := 5
+			errs := make([]derrors.Error, numPublishers)
+			var wg sync.WaitGroup
+			for i := 0; i < numPublishers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					errs[i] = provider.Add(*entry)
+				}(i)
+			}
+			wg.Wait()
+			added := 0
+			for _, err := range errs {
+				if err == nil {
+					added++
+				} else {
+					gomega.Expect(err.Type()).Should(gomega.Equal(derrors.AlreadyExists))
+				}
+			}
+			gomega.Expect(added).Should(gomega.Equal(1))
+		})
+	})
+
+	ginkgo.Context("getting a catalog entry", func() {
+		ginkgo.It("should be able to get an entry", func() {
+			entry := CreateCatalogEntry("", "1.0.0")
+			err := provider.Add(*entry)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			retrieved, err := provider.Get(entry.CatalogEntryId, entry.Version)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(retrieved).To(gomega.Equal(entry))
+
+			exists, err := provider.Exists(entry.CatalogEntryId, entry.Version)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(exists).To(gomega.BeTrue())
+		})
+		ginkgo.It("should not be able to get a non existing version", func() {
+			entry := CreateCatalogEntry("", "1.0.0")
+			err := provider.Add(*entry)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			_, err = provider.Get(entry.CatalogEntryId, "2.0.0")
+			gomega.Expect(err).NotTo(gomega.Succeed())
+
+			exists, err := provider.Exists(entry.CatalogEntryId, "2.0.0")
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(exists).NotTo(gomega.BeTrue())
+		})
+	})
+
+	ginkgo.Context("listing catalog entries", func() {
+		ginkgo.It("should be able to list the versions of an entry", func() {
+			entry := CreateCatalogEntry("", "1.0.0")
+			err := provider.Add(*entry)
+			gomega.Expect(err).To(gomega.Succeed())
+			err = provider.Add(*CreateCatalogEntry(entry.CatalogEntryId, "1.1.0"))
+			gomega.Expect(err).To(gomega.Succeed())
+			err = provider.Add(*CreateCatalogEntry("", "1.0.0"))
+			gomega.Expect(err).To(gomega.Succeed())
+
+			versions, err := provider.ListVersions(entry.CatalogEntryId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(versions)).To(gomega.Equal(2))
+
+			all, err := provider.List()
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(all)).To(gomega.Equal(3))
+		})
+		ginkgo.It("should return an empty list for an unknown entry", func() {
+			versions, err := provider.ListVersions(entities.GenerateUUID())
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(versions).To(gomega.BeEmpty())
+		})
+	})
+
+	ginkgo.Context("removing a catalog entry", func() {
+		ginkgo.It("should be able to remove a version", func() {
+			entry := CreateCatalogEntry("", "1.0.0")
+			err := provider.Add(*entry)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			err = provider.Remove(entry.CatalogEntryId, entry.Version)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			exists, err := provider.Exists(entry.CatalogEntryId, entry.Version)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(exists).NotTo(gomega.BeTrue())
+		})
+	})
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/scylladb-utils/pkg/scylladb"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/scylladb/gocqlx"
+	"github.com/scylladb/gocqlx/qb"
+	"sync"
+)
+
+const catalogEntryTable = "CatalogEntries"
+
+var catalogEntryTableColumns = []string{"catalog_entry_id", "version", "publisher_organization_id",
+	"source_app_descriptor_id", "source_revision", "published", "name", "description", "logo", "category",
+	"visibility", "visible_organizations", "configuration_options", "environment_variables", "labels", "rules",
+	"groups", "parameters", "inbound_net_interfaces", "outbound_net_interfaces"}
+
+type ScyllaCatalogProvider struct {
+	scylladb.ScyllaDB
+	sync.Mutex
+}
+
+func NewScyllaCatalogProvider(address string, port int, keyspace string) *ScyllaCatalogProvider {
+	provider := ScyllaCatalogProvider{
+		ScyllaDB: scylladb.ScyllaDB{
+			Address:  address,
+			Port:     port,
+			Keyspace: keyspace,
+		},
+	}
+	provider.Connect()
+	return &provider
+}
+
+func (s *ScyllaCatalogProvider) createPKMap(catalogEntryID string, version string) map[string]interface{} {
+	return map[string]interface{}{
+		"catalog_entry_id": catalogEntryID,
+		"version":          version,
+	}
+}
+
+// Add a new version of a catalog entry. The versions are immutable, a conditional insert guarantees that concurrent
+// publications of the same version do not overwrite each other.
+func (s *ScyllaCatalogProvider) Add(entry entities.CatalogEntry) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, names := qb.Insert(catalogEntryTable).Columns(catalogEntryTableColumns...).Unique().ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindStruct(entry)
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot add catalog entry")
+	}
+	if !applied {
+		return derrors.NewAlreadyExistsError("catalog entry").WithParams(entry.CatalogEntryId, entry.Version)
+	}
+	return nil
+}
+
+// Exists checks if a version of a catalog entry exists.
+func (s *ScyllaCatalogProvider) Exists(catalogEntryID string, version string) (bool, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	pk := s.createPKMap(catalogEntryID, version)
+	return s.UnsafeGenericCompositeExist(catalogEntryTable, pk)
+}
+
+// Get a version of a catalog entry.
+func (s *ScyllaCatalogProvider) Get(catalogEntryID string, version string) (*entities.CatalogEntry, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	pk := s.createPKMap(catalogEntryID, version)
+	var entry interface{} = &entities.CatalogEntry{}
+
+	err := s.UnsafeCompositeGet(catalogEntryTable, pk, catalogEntryTableColumns, &entry)
+	if err != nil {
+		return nil, err
+	}
+	return entry.(*entities.CatalogEntry), nil
+}
+
+// ListVersions retrieves all the versions of a catalog entry.
+func (s *ScyllaCatalogProvider) ListVersions(catalogEntryID string) ([]entities.CatalogEntry, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return nil, err
+	}
+
+	stmt, names := qb.Select(catalogEntryTable).Columns(catalogEntryTableColumns...).Where(qb.Eq("catalog_entry_id")).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		"catalog_entry_id": catalogEntryID,
+	})
+
+	entries := make([]entities.CatalogEntry, 0)
+	cqlErr := q.SelectRelease(&entries)
+	if cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot list catalog entry versions")
+	}
+	return entries, nil
+}
+
+// List retrieves all the versions of all the catalog entries.
+func (s *ScyllaCatalogProvider) List() ([]entities.CatalogEntry, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return nil, err
+	}
+
+	stmt, names := qb.Select(catalogEntryTable).Columns(catalogEntryTableColumns...).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names)
+
+	entries := make([]entities.CatalogEntry, 0)
+	cqlErr := q.SelectRelease(&entries)
+	if cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot list catalog entries")
+	}
+	return entries, nil
+}
+
+// Remove a version of a catalog entry.
+func (s *ScyllaCatalogProvider) Remove(catalogEntryID string, version string) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	pk := s.createPKMap(catalogEntryID, version)
+	return s.UnsafeCompositeRemove(catalogEntryTable, pk)
+}
+
+func (s *ScyllaCatalogProvider) Clear() derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.UnsafeClear([]string{catalogEntryTable})
+}
@@ -0,0 +1,36 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/application"
+)
+
+func CreateCatalogEntry(catalogEntryID string, version string) *entities.CatalogEntry {
+	descriptor := application.CreateTestApplicationDescriptor(entities.GenerateUUID())
+	return entities.NewCatalogEntry(entities.PublishCatalogEntryRequest{
+		OrganizationId:  descriptor.OrganizationId,
+		AppDescriptorId: descriptor.AppDescriptorId,
+		CatalogEntryId:  catalogEntryID,
+		Version:         version,
+		Description:     "Test application",
+		Logo:            "https://nalej.com/logo.png",
+		Category:        "database",
+		Visibility:      entities.CatalogVisibility_All,
+	}, *descriptor)
+}
@@ -73,6 +73,10 @@ func (m *MockupClusterProvider) Update(cluster entities.Cluster) derrors.Error {
 	if !m.unsafeExists(cluster.ClusterId) {
 		return derrors.NewNotFoundError(cluster.ClusterId)
 	}
+	if m.clusters[cluster.ClusterId].Version != cluster.Version {
+		return entities.NewVersionConflictError("cluster", cluster.ClusterId, cluster.Version)
+	}
+	cluster.Version++
 	m.clusters[cluster.ClusterId] = cluster
 	return nil
 }
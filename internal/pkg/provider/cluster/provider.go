@@ -25,7 +25,8 @@ import (
 type Provider interface {
 	// Add a new cluster to the system.
 	Add(cluster entities.Cluster) derrors.Error
-	// Update an existing cluster in the system
+	// Update an existing cluster in the system. The update fails with a version conflict if the version of the
+	// cluster does not match the stored one, and increases the stored version otherwise.
 	Update(cluster entities.Cluster) derrors.Error
 	// Exists checks if a cluster exists on the system.
 	Exists(clusterID string) (bool, derrors.Error)
@@ -81,9 +81,25 @@ func RunTest(provider Provider) {
 			retrieved, err := provider.Get(cluster.ClusterId)
 			gomega.Expect(err).To(gomega.Succeed())
 			gomega.Expect(retrieved.State).Should(gomega.Equal(newState))
+			cluster = retrieved
 		}
 	})
 
+	ginkgo.It("should not be able to update a stale version of the cluster", func() {
+		cluster := CreateTestCluster("UUUId-0")
+		gomega.Expect(provider.Add(*cluster)).To(gomega.Succeed())
+		gomega.Expect(provider.Update(*cluster)).To(gomega.Succeed())
+
+		cluster.Name = "stale"
+		err := provider.Update(*cluster)
+		gomega.Expect(entities.IsVersionConflict(err)).To(gomega.BeTrue())
+
+		retrieved, err := provider.Get(cluster.ClusterId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Version).Should(gomega.Equal(int64(1)))
+		gomega.Expect(retrieved.Name).ShouldNot(gomega.Equal("stale"))
+	})
+
 	ginkgo.It("Should not be able to update the cluster", func() {
 
 		cluster := CreateTestCluster("UUUId-0")
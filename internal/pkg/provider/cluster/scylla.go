@@ -21,6 +21,7 @@ import (
 	"github.com/nalej/derrors"
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/versioning"
 	"github.com/rs/zerolog/log"
 	"github.com/scylladb/gocqlx"
 	"github.com/scylladb/gocqlx/qb"
@@ -56,6 +57,8 @@ var (
 		"last_alive_timestamp",
 		"millicores_conversion_factor",
 		"state",
+		"version",
+		"project_id",
 	}
 	clusterColumns = []string{
 		"organization_id",
@@ -72,6 +75,8 @@ var (
 		"last_alive_timestamp",
 		"millicores_conversion_factor",
 		"state",
+		"version",
+		"project_id",
 	}
 )
 
@@ -231,17 +236,11 @@ func (sp *ScyllaClusterProvider) Update(cluster entities.Cluster) derrors.Error
 		return derrors.NewNotFoundError(cluster.ClusterId)
 	}
 
-	// insert the cluster instance
-	stmt, names := qb.Update(clusterTable).Set(clusterColumnsNoPK...).
-		Where(qb.Eq(clusterTablePK)).ToCql()
-	q := gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(cluster)
-	cqlErr := q.ExecRelease()
-
-	if cqlErr != nil {
-		return derrors.AsError(err, "cannot update cluster")
-	}
-
-	return nil
+	// update the cluster if nobody else updated it
+	expected := cluster.Version
+	cluster.Version++
+	pk := map[string]interface{}{clusterTablePK: cluster.ClusterId}
+	return versioning.ScyllaUpdate(sp.Session, clusterTable, pk, clusterColumnsNoPK, cluster, "cluster", cluster.ClusterId, expected)
 }
 
 // Exists checks if a cluster exists on the system.
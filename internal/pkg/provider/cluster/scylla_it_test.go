@@ -39,7 +39,7 @@ create type nalej.cluster_cilium_creds(cilium_id text, cilium_etcd_ca_crt text,
 create type nalej.cluster_istio_creds(cluster_name text, server_name text, ca_cert text, cluster_token text);
 create type nalej.cluster_watch_info(name text, organization_id text, cluster_id text, ip text, network_type int, cilium_certs FROZEN<cluster_cilium_creds>, istio_certs FROZEN<cluster_istio_creds>);
 
-create table IF NOT EXISTS nalej.Clusters (organization_id text, cluster_id text, name text, cluster_type int, hostname text, control_plane_hostname text, multitenant int, status int, labels map<text, text>, cordon boolean, cluster_watch FROZEN <cluster_watch_info>, last_alive_timestamp int, state int, PRIMARY KEY (cluster_id));
+create table IF NOT EXISTS nalej.Clusters (organization_id text, cluster_id text, name text, cluster_type int, hostname text, control_plane_hostname text, multitenant int, status int, labels map<text, text>, cordon boolean, cluster_watch FROZEN <cluster_watch_info>, last_alive_timestamp int, state int, version bigint, project_id text, PRIMARY KEY (cluster_id));
 create table IF NOT EXISTS nalej.Cluster_Nodes (cluster_id text, node_id text, PRIMARY KEY (cluster_id, node_id));
 */
 
@@ -19,6 +19,7 @@ package device
 import (
 	"fmt"
 	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/entities/devices"
 	"sync"
 )
@@ -175,6 +176,23 @@ func (m *MockupDeviceProvider) RemoveDeviceGroup(organizationID string, deviceGr
 
 }
 
+func (m *MockupDeviceProvider) UpdateDeviceGroup(deviceGroup devices.DeviceGroup) derrors.Error {
+
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.unsafeExistsGroup(deviceGroup.OrganizationId, deviceGroup.DeviceGroupId) {
+		return derrors.NewNotFoundError("device group").WithParams(deviceGroup.OrganizationId, deviceGroup.DeviceGroupId)
+	}
+	current := m.deviceGroups[deviceGroup.OrganizationId][deviceGroup.DeviceGroupId]
+	current.Labels = deviceGroup.Labels
+	current.ProjectId = deviceGroup.ProjectId
+	m.deviceGroups[deviceGroup.OrganizationId][deviceGroup.DeviceGroupId] = current
+	m.deviceGroupsByName[fmt.Sprintf("%s#%s", current.OrganizationId, current.Name)] = current
+
+	return nil
+}
+
 // ----------------------------------------------------------------------------------------------------
 
 func CreateDeviceIndex(organizationID string, deviceGroupID string) string {
@@ -301,6 +319,10 @@ func (m *MockupDeviceProvider) UpdateDevice(device devices.Device) derrors.Error
 	}
 	key := CreateDeviceIndex(device.OrganizationId, device.DeviceGroupId)
 	devices := m.devices[key]
+	if devices[device.DeviceId].Version != device.Version {
+		return entities.NewVersionConflictError("device", device.DeviceId, device.Version)
+	}
+	device.Version++
 	devices[device.DeviceId] = device
 
 	return nil
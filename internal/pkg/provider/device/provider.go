@@ -37,6 +37,8 @@ type Provider interface {
 	GetDeviceGroupsByName(organizationID string, groupNames []string) ([]devices.DeviceGroup, derrors.Error)
 	// Remove a device group
 	RemoveDeviceGroup(organizationID string, deviceGroup string) derrors.Error
+	// UpdateDeviceGroup updates the labels and the project of a device group.
+	UpdateDeviceGroup(deviceGroup devices.DeviceGroup) derrors.Error
 
 	// AddDevice adds a new device group
 	AddDevice(device devices.Device) derrors.Error
@@ -48,7 +50,8 @@ type Provider interface {
 	ListDevices(organizationID string, deviceGroupID string) ([]devices.Device, derrors.Error)
 	// Remove a device
 	RemoveDevice(organizationID string, deviceGroupID string, deviceID string) derrors.Error
-	//UpdateDevice updates the device information
+	//UpdateDevice updates the device information. The update fails with a version conflict if the version of the
+	// device does not match the stored one, and increases the stored version otherwise.
 	UpdateDevice(device devices.Device) derrors.Error
 
 	Clear() derrors.Error
@@ -181,6 +181,32 @@ func RunTest(provider Provider) {
 			gomega.Expect(deviceGroups).To(gomega.BeEmpty())
 
 		})
+		ginkgo.It("should be able to update the project of a device group", func() {
+			helper := NewDeviceTestHepler()
+
+			toAdd := helper.CreateDeviceGroup()
+			err := provider.AddDeviceGroup(*toAdd)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			toAdd.ProjectId = uuid.New().String()
+			err = provider.UpdateDeviceGroup(*toAdd)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			received, err := provider.GetDeviceGroup(toAdd.OrganizationId, toAdd.DeviceGroupId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(received.ProjectId).To(gomega.Equal(toAdd.ProjectId))
+
+			list, err := provider.ListDeviceGroups(toAdd.OrganizationId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(list[0].ProjectId).To(gomega.Equal(toAdd.ProjectId))
+		})
+		ginkgo.It("should not be able to update a non existing device group", func() {
+			helper := NewDeviceTestHepler()
+
+			toAdd := helper.CreateDeviceGroup()
+			err := provider.UpdateDeviceGroup(*toAdd)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
 
 	})
 	ginkgo.Context("Device tests", func() {
@@ -342,6 +368,24 @@ func RunTest(provider Provider) {
 			gomega.Expect(retrieve.Location.Geohash).Should(gomega.Equal(toAdd.Location.Geohash))
 		})
 
+		ginkgo.It("Should not be able to update a stale device", func() {
+			toAdd := NewDeviceTestHepler().CreateDevice()
+
+			err := provider.AddDevice(*toAdd)
+			gomega.Expect(err).To(gomega.Succeed())
+			err = provider.UpdateDevice(*toAdd)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			toAdd.Labels = nil
+			err = provider.UpdateDevice(*toAdd)
+			gomega.Expect(entities.IsVersionConflict(err)).To(gomega.BeTrue())
+
+			retrieve, err := provider.GetDevice(toAdd.OrganizationId, toAdd.DeviceGroupId, toAdd.DeviceId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(retrieve.Version).Should(gomega.Equal(int64(1)))
+			gomega.Expect(retrieve.Labels).NotTo(gomega.BeEmpty())
+		})
+
 		ginkgo.It("Should not be able to update a non existing device", func() {
 			toAdd := NewDeviceTestHepler().CreateDevice()
 
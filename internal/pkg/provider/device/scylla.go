@@ -22,6 +22,7 @@ import (
 	"github.com/nalej/derrors"
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/system-model/internal/pkg/entities/devices"
+	"github.com/nalej/system-model/internal/pkg/provider/versioning"
 	"github.com/rs/zerolog/log"
 	"github.com/scylladb/gocqlx"
 	"github.com/scylladb/gocqlx/qb"
@@ -42,6 +43,7 @@ const (
 	hardwareField       = "hardware"
 	storageField        = "storage"
 	locationField       = "location"
+	versionField        = versioning.VersionColumn
 
 	rowNotFound = "not found"
 )
@@ -155,7 +157,7 @@ func (sp *ScyllaDeviceProvider) AddDeviceGroup(deviceGroup devices.DeviceGroup)
 	}
 	// add it into database
 	stmt, names := qb.Insert(deviceGroupTable).Columns("organization_id",
-		"device_group_id", "name", "created", "labels").ToCql()
+		"device_group_id", "name", "created", "labels", "project_id").ToCql()
 	q := gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(deviceGroup)
 	cqlErr := q.ExecRelease()
 
@@ -271,7 +273,7 @@ func (sp *ScyllaDeviceProvider) ListDeviceGroups(organizationID string) ([]devic
 	}
 
 	stmt, names := qb.Select(deviceGroupTable).Columns("organization_id", "device_group_id",
-		"created", "labels", "name").Where(qb.Eq("organization_id")).ToCql()
+		"created", "labels", "name", "project_id").Where(qb.Eq("organization_id")).ToCql()
 	q := gocqlx.Query(sp.Session.Query(stmt), names).BindMap(qb.M{
 		"organization_id": organizationID,
 	})
@@ -345,6 +347,35 @@ func (sp *ScyllaDeviceProvider) RemoveDeviceGroup(organizationID string, deviceG
 
 }
 
+// UpdateDeviceGroup updates the labels and the project of a device group.
+func (sp *ScyllaDeviceProvider) UpdateDeviceGroup(deviceGroup devices.DeviceGroup) derrors.Error {
+	sp.Lock()
+	defer sp.Unlock()
+
+	if err := sp.checkAndConnect(); err != nil {
+		return err
+	}
+
+	// check if the group exists
+	exists, err := sp.unsafeExistsGroup(deviceGroup.OrganizationId, deviceGroup.DeviceGroupId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("device group").WithParams(deviceGroup.OrganizationId, deviceGroup.DeviceGroupId)
+	}
+
+	stmt, names := qb.Update(deviceGroupTable).Set(labelsField, "project_id").
+		Where(qb.Eq(organizationIdField)).Where(qb.Eq(deviceGroupIdField)).ToCql()
+	cqlErr := gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(deviceGroup).ExecRelease()
+
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot update device group")
+	}
+
+	return nil
+}
+
 // -------------------------------------------------------------------------------------------------------------------
 
 func (sp *ScyllaDeviceProvider) unsafeExistsDevice(organizationID string, deviceGroupID string, deviceID string) (bool, derrors.Error) {
@@ -398,7 +429,7 @@ func (sp *ScyllaDeviceProvider) AddDevice(device devices.Device) derrors.Error {
 	}
 	// add it into database
 	stmt, names := qb.Insert(deviceTable).Columns(organizationIdField, deviceGroupIdField, deviceIdField,
-		labelsField, registerSinceField, osField, hardwareField, storageField).ToCql()
+		labelsField, registerSinceField, osField, hardwareField, storageField, versionField).ToCql()
 	q := gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(device)
 	cqlErr := q.ExecRelease()
 
@@ -487,7 +518,8 @@ func (sp *ScyllaDeviceProvider) ListDevices(organizationID string, deviceGroupID
 	}
 
 	stmt, names := qb.Select(deviceTable).Columns(organizationIdField, deviceGroupIdField, deviceIdField,
-		labelsField, registerSinceField, locationField, osField, hardwareField, storageField).Where(qb.Eq(organizationIdField)).
+		labelsField, registerSinceField, locationField, osField, hardwareField, storageField, versionField).
+		Where(qb.Eq(organizationIdField)).
 		Where(qb.Eq(deviceGroupIdField)).ToCql()
 
 	q := gocqlx.Query(sp.Session.Query(stmt), names).BindMap(qb.M{
@@ -557,17 +589,19 @@ func (sp *ScyllaDeviceProvider) UpdateDevice(device devices.Device) derrors.Erro
 		return derrors.NewNotFoundError("device").WithParams(device.OrganizationId, device.DeviceGroupId, device.DeviceId)
 	}
 
-	// insert the cluster instance
-	stmt, names := qb.Update(deviceTable).Set(labelsField, locationField).
-		Where(qb.Eq(organizationIdField)).
-		Where(qb.Eq(deviceGroupIdField)).
-		Where(qb.Eq(deviceIdField)).ToCql()
-	q := gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(device)
-	cqlErr := q.ExecRelease()
-
-	if cqlErr != nil {
-		log.Error().Err(cqlErr).Msg("Cannot update device")
-		return derrors.AsError(err, "cannot update device")
+	// update the device if nobody else updated it
+	expected := device.Version
+	device.Version++
+	pk := map[string]interface{}{
+		organizationIdField: device.OrganizationId,
+		deviceGroupIdField:  device.DeviceGroupId,
+		deviceIdField:       device.DeviceId,
+	}
+	err = versioning.ScyllaUpdate(sp.Session, deviceTable, pk, []string{labelsField, locationField, versionField},
+		device, "device", device.DeviceId, expected)
+	if err != nil {
+		log.Error().Str("trace", err.DebugReport()).Msg("cannot update device")
+		return err
 	}
 
 	return nil
@@ -33,8 +33,8 @@ docker exec -it scylla cqlsh
 
 create KEYSPACE nalej WITH replication = {'class': 'SimpleStrategy', 'replication_factor': 1};
 
-create table IF NOT EXISTS nalej.Devices (organization_id text, device_group_id text, device_id text, register_since int, labels map<text, text>, PRIMARY KEY ( (organization_id, device_group_id), device_id));
-create table IF NOT EXISTS nalej.DeviceGroups (organization_id text, device_group_id text, name text, created int, labels map<text, text>, primary KEY (organization_id, device_group_id));
+create table IF NOT EXISTS nalej.Devices (organization_id text, device_group_id text, device_id text, register_since int, labels map<text, text>, version bigint, PRIMARY KEY ( (organization_id, device_group_id), device_id));
+create table IF NOT EXISTS nalej.DeviceGroups (organization_id text, device_group_id text, name text, created int, labels map<text, text>, project_id text, primary KEY (organization_id, device_group_id));
 
 // -- Environment variables
 
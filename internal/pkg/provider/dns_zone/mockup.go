@@ -0,0 +1,92 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dns_zone
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"sync"
+)
+
+type MockupDNSZoneProvider struct {
+	sync.Mutex
+	// serials indexed by zone name
+	serials map[string]entities.DNSZoneSerial
+}
+
+func NewMockupDNSZoneProvider() *MockupDNSZoneProvider {
+	return &MockupDNSZoneProvider{
+		serials: make(map[string]entities.DNSZoneSerial, 0),
+	}
+}
+
+// Add the serial of a zone.
+func (m *MockupDNSZoneProvider) Add(serial entities.DNSZoneSerial) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, exists := m.serials[serial.ZoneName]; exists {
+		return derrors.NewAlreadyExistsError("zone serial").WithParams(serial.ZoneName)
+	}
+	m.serials[serial.ZoneName] = serial
+	return nil
+}
+
+// Exists checks if a zone has a serial.
+func (m *MockupDNSZoneProvider) Exists(zoneName string) (bool, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	_, exists := m.serials[zoneName]
+	return exists, nil
+}
+
+// Get the serial of a zone.
+func (m *MockupDNSZoneProvider) Get(zoneName string) (*entities.DNSZoneSerial, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	serial, exists := m.serials[zoneName]
+	if !exists {
+		return nil, derrors.NewNotFoundError("zone serial").WithParams(zoneName)
+	}
+	return &serial, nil
+}
+
+// Update the serial of a zone if the stored one is the previous serial.
+func (m *MockupDNSZoneProvider) Update(serial entities.DNSZoneSerial) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	current, exists := m.serials[serial.ZoneName]
+	if !exists {
+		return derrors.NewNotFoundError("zone serial").WithParams(serial.ZoneName)
+	}
+	if current.Serial != serial.Serial-1 {
+		return entities.NewVersionConflictError("zone serial", serial.ZoneName, serial.Serial-1)
+	}
+	m.serials[serial.ZoneName] = serial
+	return nil
+}
+
+func (m *MockupDNSZoneProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.serials = make(map[string]entities.DNSZoneSerial, 0)
+	return nil
+}
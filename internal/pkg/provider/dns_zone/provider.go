@@ -0,0 +1,37 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dns_zone
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+)
+
+// Provider for the serials of the exported DNS zones.
+type Provider interface {
+	// Add the serial of a zone.
+	Add(serial entities.DNSZoneSerial) derrors.Error
+	// Exists checks if a zone has a serial.
+	Exists(zoneName string) (bool, derrors.Error)
+	// Get the serial of a zone.
+	Get(zoneName string) (*entities.DNSZoneSerial, derrors.Error)
+	// Update the serial of a zone. The serial is only updated if the stored one is the previous serial, failing with
+	// a version conflict otherwise.
+	Update(serial entities.DNSZoneSerial) derrors.Error
+
+	Clear() derrors.Error
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dns_zone
+
+import (
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+func RunTest(provider Provider) {
+
+	ginkgo.AfterEach(func() {
+		provider.Clear()
+	})
+
+	ginkgo.It("should be able to add a zone serial", func() {
+		serial := entities.NewDNSZoneSerial(entities.GenerateUUID(), "checksum")
+		err := provider.Add(*serial)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		exists, err := provider.Exists(serial.ZoneName)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).To(gomega.BeTrue())
+
+		err = provider.Add(*serial)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to get a zone serial", func() {
+		serial := entities.NewDNSZoneSerial(entities.GenerateUUID(), "checksum")
+		err := provider.Add(*serial)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		retrieved, err := provider.Get(serial.ZoneName)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved).To(gomega.Equal(serial))
+
+		_, err = provider.Get(entities.GenerateUUID())
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to update a zone serial", func() {
+		serial := entities.NewDNSZoneSerial(entities.GenerateUUID(), "checksum")
+		err := provider.Add(*serial)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		next := serial.Next("new checksum")
+		err = provider.Update(*next)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		retrieved, err := provider.Get(serial.ZoneName)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Serial).To(gomega.Equal(serial.Serial + 1))
+	})
+
+	ginkgo.It("should not update a zone serial that changed concurrently", func() {
+		serial := entities.NewDNSZoneSerial(entities.GenerateUUID(), "checksum")
+		err := provider.Add(*serial)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		err = provider.Update(*serial.Next("first checksum"))
+		gomega.Expect(err).To(gomega.Succeed())
+		err = provider.Update(*serial.Next("second checksum"))
+		gomega.Expect(entities.IsVersionConflict(err)).To(gomega.BeTrue())
+
+		retrieved, err := provider.Get(serial.ZoneName)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Checksum).To(gomega.Equal("first checksum"))
+	})
+}
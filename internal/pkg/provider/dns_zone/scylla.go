@@ -0,0 +1,133 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dns_zone
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/scylladb-utils/pkg/scylladb"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/scylladb/gocqlx"
+	"github.com/scylladb/gocqlx/qb"
+	"sync"
+)
+
+const dnsZoneTable = "DNSZoneSerials"
+const dnsZoneTablePK = "zone_name"
+
+var dnsZoneTableColumns = []string{"zone_name", "serial", "checksum", "updated"}
+var dnsZoneTableColumnsNoPK = []string{"serial", "checksum", "updated"}
+
+type ScyllaDNSZoneProvider struct {
+	scylladb.ScyllaDB
+	sync.Mutex
+}
+
+func NewScyllaDNSZoneProvider(address string, port int, keyspace string) *ScyllaDNSZoneProvider {
+	provider := ScyllaDNSZoneProvider{
+		ScyllaDB: scylladb.ScyllaDB{
+			Address:  address,
+			Port:     port,
+			Keyspace: keyspace,
+		},
+	}
+	provider.Connect()
+	return &provider
+}
+
+// Add the serial of a zone.
+func (s *ScyllaDNSZoneProvider) Add(serial entities.DNSZoneSerial) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, names := qb.Insert(dnsZoneTable).Columns(dnsZoneTableColumns...).Unique().ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindStruct(serial)
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot add zone serial")
+	}
+	if !applied {
+		return derrors.NewAlreadyExistsError("zone serial").WithParams(serial.ZoneName)
+	}
+	return nil
+}
+
+// Exists checks if a zone has a serial.
+func (s *ScyllaDNSZoneProvider) Exists(zoneName string) (bool, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.UnsafeGenericExist(dnsZoneTable, dnsZoneTablePK, zoneName)
+}
+
+// Get the serial of a zone.
+func (s *ScyllaDNSZoneProvider) Get(zoneName string) (*entities.DNSZoneSerial, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var serial interface{} = &entities.DNSZoneSerial{}
+	err := s.UnsafeGet(dnsZoneTable, dnsZoneTablePK, zoneName, dnsZoneTableColumns, &serial)
+	if err != nil {
+		return nil, err
+	}
+	return serial.(*entities.DNSZoneSerial), nil
+}
+
+// Update the serial of a zone if the stored one is the previous serial.
+func (s *ScyllaDNSZoneProvider) Update(serial entities.DNSZoneSerial) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, names := qb.Update(dnsZoneTable).Set(dnsZoneTableColumnsNoPK...).Where(qb.Eq(dnsZoneTablePK)).
+		If(qb.EqNamed("serial", "previous_serial")).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		"zone_name":       serial.ZoneName,
+		"serial":          serial.Serial,
+		"checksum":        serial.Checksum,
+		"updated":         serial.Updated,
+		"previous_serial": serial.Serial - 1,
+	})
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot update zone serial")
+	}
+	if !applied {
+		if _, exists := current["serial"]; !exists {
+			return derrors.NewNotFoundError("zone serial").WithParams(serial.ZoneName)
+		}
+		return entities.NewVersionConflictError("zone serial", serial.ZoneName, serial.Serial-1)
+	}
+	return nil
+}
+
+func (s *ScyllaDNSZoneProvider) Clear() derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.UnsafeClear([]string{dnsZoneTable})
+}
@@ -56,6 +56,10 @@ func (m *MockupEICProvider) Update(eic entities.EdgeController) derrors.Error {
 	if !m.unsafeExists(eic.EdgeControllerId) {
 		return derrors.NewNotFoundError(eic.EdgeControllerId)
 	}
+	if m.controllers[eic.EdgeControllerId].Version != eic.Version {
+		return entities.NewVersionConflictError("edge controller", eic.EdgeControllerId, eic.Version)
+	}
+	eic.Version++
 	m.controllers[eic.EdgeControllerId] = eic
 	return nil
 }
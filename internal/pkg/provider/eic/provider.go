@@ -25,7 +25,8 @@ import (
 type Provider interface {
 	// Add a new edge controller to the system.
 	Add(eic entities.EdgeController) derrors.Error
-	// Update the information of an edge controller.
+	// Update the information of an edge controller. The update fails with a version conflict if the version of
+	// the edge controller does not match the stored one, and increases the stored version otherwise.
 	Update(eic entities.EdgeController) derrors.Error
 	// Exists checks if an EIC exists on the system.
 	Exists(edgeControllerID string) (bool, derrors.Error)
@@ -46,6 +46,17 @@ func RunTest(provider Provider) {
 		gomega.Expect(err).To(gomega.Succeed())
 	})
 
+	ginkgo.It("should not be able to update a stale EIC", func() {
+		toAdd := CreateTestEdgeController()
+		err := provider.Add(*toAdd)
+		gomega.Expect(err).To(gomega.Succeed())
+		err = provider.Update(*toAdd)
+		gomega.Expect(err).To(gomega.Succeed())
+		toAdd.Name = "staleName"
+		err = provider.Update(*toAdd)
+		gomega.Expect(entities.IsVersionConflict(err)).To(gomega.BeTrue())
+	})
+
 	ginkgo.It("should be able to retrieve an EIC", func() {
 		toAdd := CreateTestEdgeController()
 		err := provider.Add(*toAdd)
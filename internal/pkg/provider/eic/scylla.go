@@ -20,6 +20,7 @@ import (
 	"github.com/nalej/derrors"
 	"github.com/nalej/scylladb-utils/pkg/scylladb"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/versioning"
 	"github.com/scylladb/gocqlx"
 	"github.com/scylladb/gocqlx/qb"
 	"sync"
@@ -33,11 +34,13 @@ const ControllerTablePK = "edge_controller_id"
 
 // AllControllerColumns contains the name of all the columns in the controller table.
 var allControllerColumns = []string{"organization_id", "edge_controller_id", "show",
-	"created", "name", "labels", "last_alive_timestamp", "location", "os", "hardware", "storage", "last_op_result"}
+	"created", "name", "labels", "last_alive_timestamp", "location", "os", "hardware", "storage", "last_op_result",
+	"version"}
 
 // AllControllerColumnsNoPK contains the name of all the columns in the controller table except the PK.
 var allControllerColumnsNoPK = []string{"organization_id", "show",
-	"created", "name", "labels", "last_alive_timestamp", "location", "os", "hardware", "storage", "last_op_result"}
+	"created", "name", "labels", "last_alive_timestamp", "location", "os", "hardware", "storage", "last_op_result",
+	"version"}
 
 type ScyllaControllerProvider struct {
 	scylladb.ScyllaDB
@@ -72,7 +75,22 @@ func (sp *ScyllaControllerProvider) Add(eic entities.EdgeController) derrors.Err
 func (sp *ScyllaControllerProvider) Update(eic entities.EdgeController) derrors.Error {
 	sp.Lock()
 	defer sp.Unlock()
-	return sp.UnsafeUpdate(ControllerTable, ControllerTablePK, eic.EdgeControllerId, allControllerColumnsNoPK, eic)
+	if err := sp.CheckAndConnect(); err != nil {
+		return err
+	}
+	exists, err := sp.UnsafeGenericExist(ControllerTable, ControllerTablePK, eic.EdgeControllerId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("edge controller").WithParams(eic.EdgeControllerId)
+	}
+
+	expected := eic.Version
+	eic.Version++
+	pk := map[string]interface{}{ControllerTablePK: eic.EdgeControllerId}
+	return versioning.ScyllaUpdate(sp.Session, ControllerTable, pk, allControllerColumnsNoPK, eic, "edge controller",
+		eic.EdgeControllerId, expected)
 }
 
 func (sp *ScyllaControllerProvider) Exists(edgeControllerID string) (bool, derrors.Error) {
@@ -32,7 +32,7 @@ docker exec -it scylla cqlsh
 create KEYSPACE nalej WITH replication = {'class': 'SimpleStrategy', 'replication_factor': 1};
 use nalej;
 
-create table IF NOT EXISTS nalej.Controller (organization_id text, edge_controller_id text, show boolean, created int, name text, labels map<text, text>, PRIMARY KEY(edge_controller_id));
+create table IF NOT EXISTS nalej.Controller (organization_id text, edge_controller_id text, show boolean, created int, name text, labels map<text, text>, version bigint, PRIMARY KEY(edge_controller_id));
 create index IF NOT EXISTS controllerOrg ON nalej.Controller (organization_id);
 */
 
@@ -0,0 +1,197 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invitation
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"sync"
+	"time"
+)
+
+type MockupInvitationProvider struct {
+	sync.Mutex
+	// invitations indexed by invitation
+	invitations map[string]entities.Invitation
+	// emails with the invitation that reserves each email, indexed by organization and email
+	emails map[string]map[string]string
+}
+
+func NewMockupInvitationProvider() *MockupInvitationProvider {
+	return &MockupInvitationProvider{
+		invitations: make(map[string]entities.Invitation, 0),
+		emails:      make(map[string]map[string]string, 0),
+	}
+}
+
+// Add a new invitation.
+func (m *MockupInvitationProvider) Add(invitation entities.Invitation) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, exists := m.invitations[invitation.InvitationId]; exists {
+		return derrors.NewAlreadyExistsError("invitation").WithParams(invitation.InvitationId)
+	}
+	emails, exists := m.emails[invitation.OrganizationId]
+	if !exists {
+		emails = make(map[string]string, 0)
+		m.emails[invitation.OrganizationId] = emails
+	}
+	if reservedBy, exists := emails[invitation.Email]; exists {
+		previous, found := m.invitations[reservedBy]
+		if found && previous.Status(time.Now().Unix()) == entities.InvitationPending {
+			return derrors.NewAlreadyExistsError("pending invitation").WithParams(invitation.Email, reservedBy)
+		}
+	}
+	emails[invitation.Email] = invitation.InvitationId
+	m.invitations[invitation.InvitationId] = invitation
+	return nil
+}
+
+// Update an existing invitation.
+func (m *MockupInvitationProvider) Update(invitation entities.Invitation) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, exists := m.invitations[invitation.InvitationId]; !exists {
+		return derrors.NewNotFoundError("invitation").WithParams(invitation.InvitationId)
+	}
+	m.invitations[invitation.InvitationId] = invitation
+	return nil
+}
+
+// Get an invitation.
+func (m *MockupInvitationProvider) Get(invitationID string) (*entities.Invitation, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	invitation, exists := m.invitations[invitationID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("invitation").WithParams(invitationID)
+	}
+	return &invitation, nil
+}
+
+// List the invitations of an organization.
+func (m *MockupInvitationProvider) List(organizationID string) ([]entities.Invitation, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	result := make([]entities.Invitation, 0)
+	for _, invitation := range m.invitations {
+		if invitation.OrganizationId == organizationID {
+			result = append(result, invitation)
+		}
+	}
+	return result, nil
+}
+
+// ListByEmail retrieves the invitations sent to an email or created by it in any organization.
+func (m *MockupInvitationProvider) ListByEmail(email string) ([]entities.Invitation, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	result := make([]entities.Invitation, 0)
+	for _, invitation := range m.invitations {
+		if invitation.Email == email || invitation.InvitedBy == email {
+			result = append(result, invitation)
+		}
+	}
+	return result, nil
+}
+
+// Accept marks an invitation as accepted.
+func (m *MockupInvitationProvider) Accept(invitationID string, acceptedAt int64) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	invitation, err := m.unsafeGetUnused(invitationID)
+	if err != nil {
+		return err
+	}
+	invitation.AcceptedAt = acceptedAt
+	m.invitations[invitationID] = *invitation
+	return nil
+}
+
+// Revoke marks an invitation as revoked.
+func (m *MockupInvitationProvider) Revoke(invitationID string, revokedAt int64) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	invitation, err := m.unsafeGetUnused(invitationID)
+	if err != nil {
+		return err
+	}
+	invitation.RevokedAt = revokedAt
+	m.invitations[invitationID] = *invitation
+	return nil
+}
+
+// CancelAccept restores an accepted invitation to pending, only if it is still accepted at the given time.
+func (m *MockupInvitationProvider) CancelAccept(invitationID string, acceptedAt int64) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	invitation, exists := m.invitations[invitationID]
+	if !exists {
+		return derrors.NewNotFoundError("invitation").WithParams(invitationID)
+	}
+	if invitation.AcceptedAt != acceptedAt {
+		return derrors.NewFailedPreconditionError("invitation not accepted at the given time").WithParams(invitationID, acceptedAt)
+	}
+	invitation.AcceptedAt = 0
+	m.invitations[invitationID] = invitation
+	return nil
+}
+
+// unsafeGetUnused retrieves an invitation that has not been accepted nor revoked.
+func (m *MockupInvitationProvider) unsafeGetUnused(invitationID string) (*entities.Invitation, derrors.Error) {
+	invitation, exists := m.invitations[invitationID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("invitation").WithParams(invitationID)
+	}
+	if invitation.AcceptedAt != 0 || invitation.RevokedAt != 0 {
+		return nil, derrors.NewFailedPreconditionError("invitation already used").WithParams(invitationID)
+	}
+	return &invitation, nil
+}
+
+// Remove an invitation.
+func (m *MockupInvitationProvider) Remove(invitationID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	invitation, exists := m.invitations[invitationID]
+	if !exists {
+		return derrors.NewNotFoundError("invitation").WithParams(invitationID)
+	}
+	if m.emails[invitation.OrganizationId][invitation.Email] == invitationID {
+		delete(m.emails[invitation.OrganizationId], invitation.Email)
+	}
+	delete(m.invitations, invitationID)
+	return nil
+}
+
+func (m *MockupInvitationProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.invitations = make(map[string]entities.Invitation, 0)
+	m.emails = make(map[string]map[string]string, 0)
+	return nil
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invitation
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+)
+
+// Provider for the invitations to become a member of an organization. Accepting and revoking an invitation are
+// atomic, so an invitation can only be used once, and an email can only have one pending invitation in an
+// organization.
+type Provider interface {
+	// Add a new invitation. It fails with an AlreadyExists error if the email has a pending invitation in the
+	// organization.
+	Add(invitation entities.Invitation) derrors.Error
+	// Update an existing invitation.
+	Update(invitation entities.Invitation) derrors.Error
+	// Get an invitation.
+	Get(invitationID string) (*entities.Invitation, derrors.Error)
+	// List the invitations of an organization.
+	List(organizationID string) ([]entities.Invitation, derrors.Error)
+	// ListByEmail retrieves the invitations sent to an email or created by it in any organization.
+	ListByEmail(email string) ([]entities.Invitation, derrors.Error)
+	// Accept marks an invitation as accepted. It fails with a FailedPrecondition error if the invitation has
+	// already been accepted or revoked.
+	Accept(invitationID string, acceptedAt int64) derrors.Error
+	// CancelAccept restores an accepted invitation to pending. It fails with a FailedPrecondition error if the
+	// invitation is not accepted at the given time.
+	CancelAccept(invitationID string, acceptedAt int64) derrors.Error
+	// Revoke marks an invitation as revoked. It fails with a FailedPrecondition error if the invitation has
+	// already been accepted or revoked.
+	Revoke(invitationID string, revokedAt int64) derrors.Error
+	// Remove an invitation and the reservation of its email.
+	Remove(invitationID string) derrors.Error
+
+	Clear() derrors.Error
+}
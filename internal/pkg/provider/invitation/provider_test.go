@@ -0,0 +1,179 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invitation
+
+import (
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"time"
+)
+
+func createInvitation(organizationID string) *entities.Invitation {
+	email := fmt.Sprintf("%s@nalej.com", entities.GenerateUUID())
+	invitation, _, err := entities.NewInvitation(organizationID, email, entities.GenerateUUID(), "admin@nalej.com", 0)
+	gomega.Expect(err).To(gomega.Succeed())
+	return invitation
+}
+
+func RunTest(provider Provider) {
+
+	ginkgo.AfterEach(func() {
+		provider.Clear()
+	})
+
+	ginkgo.It("should be able to add an invitation", func() {
+		invitation := createInvitation(entities.GenerateUUID())
+		err := provider.Add(*invitation)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		err = provider.Add(*invitation)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should only add one pending invitation for an email in an organization", func() {
+		invitation := createInvitation(entities.GenerateUUID())
+		gomega.Expect(provider.Add(*invitation)).To(gomega.Succeed())
+
+		duplicated := createInvitation(invitation.OrganizationId)
+		duplicated.Email = invitation.Email
+		err := provider.Add(*duplicated)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.AlreadyExists))
+
+		other := createInvitation(entities.GenerateUUID())
+		other.Email = invitation.Email
+		gomega.Expect(provider.Add(*other)).To(gomega.Succeed())
+
+		gomega.Expect(provider.Revoke(invitation.InvitationId, time.Now().Unix())).To(gomega.Succeed())
+		gomega.Expect(provider.Add(*duplicated)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to get an invitation", func() {
+		invitation := createInvitation(entities.GenerateUUID())
+		gomega.Expect(provider.Add(*invitation)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get(invitation.InvitationId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved).To(gomega.Equal(invitation))
+
+		_, err = provider.Get(entities.GenerateUUID())
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to list the invitations of an organization", func() {
+		organizationID := entities.GenerateUUID()
+		for i := 0; i < 3; i++ {
+			gomega.Expect(provider.Add(*createInvitation(organizationID))).To(gomega.Succeed())
+		}
+		gomega.Expect(provider.Add(*createInvitation(entities.GenerateUUID()))).To(gomega.Succeed())
+
+		invitations, err := provider.List(organizationID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(invitations)).Should(gomega.Equal(3))
+	})
+
+	ginkgo.It("should be able to list the invitations of an email", func() {
+		received := createInvitation(entities.GenerateUUID())
+		received.Email = "subject@nalej.com"
+		gomega.Expect(provider.Add(*received)).To(gomega.Succeed())
+		sent := createInvitation(entities.GenerateUUID())
+		sent.InvitedBy = "subject@nalej.com"
+		gomega.Expect(provider.Add(*sent)).To(gomega.Succeed())
+		gomega.Expect(provider.Add(*createInvitation(entities.GenerateUUID()))).To(gomega.Succeed())
+
+		invitations, err := provider.ListByEmail("subject@nalej.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(invitations)).Should(gomega.Equal(2))
+	})
+
+	ginkgo.It("should be able to update an invitation", func() {
+		invitation := createInvitation(entities.GenerateUUID())
+		gomega.Expect(provider.Add(*invitation)).To(gomega.Succeed())
+
+		invitation.RoleId = entities.GenerateUUID()
+		gomega.Expect(provider.Update(*invitation)).To(gomega.Succeed())
+		retrieved, err := provider.Get(invitation.InvitationId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.RoleId).Should(gomega.Equal(invitation.RoleId))
+	})
+
+	ginkgo.It("should accept an invitation only once", func() {
+		invitation := createInvitation(entities.GenerateUUID())
+		gomega.Expect(provider.Add(*invitation)).To(gomega.Succeed())
+
+		now := time.Now().Unix()
+		gomega.Expect(provider.Accept(invitation.InvitationId, now)).To(gomega.Succeed())
+		err := provider.Accept(invitation.InvitationId, now)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.FailedPrecondition))
+		err = provider.Revoke(invitation.InvitationId, now)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		retrieved, err := provider.Get(invitation.InvitationId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.AcceptedAt).Should(gomega.Equal(now))
+		gomega.Expect(retrieved.RevokedAt).Should(gomega.BeZero())
+	})
+
+	ginkgo.It("should cancel an acceptance only if the invitation is still accepted at the same time", func() {
+		invitation := createInvitation(entities.GenerateUUID())
+		gomega.Expect(provider.Add(*invitation)).To(gomega.Succeed())
+
+		now := time.Now().Unix()
+		gomega.Expect(provider.Accept(invitation.InvitationId, now)).To(gomega.Succeed())
+		err := provider.CancelAccept(invitation.InvitationId, now+1)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.FailedPrecondition))
+		gomega.Expect(provider.CancelAccept(invitation.InvitationId, now)).To(gomega.Succeed())
+
+		gomega.Expect(provider.Revoke(invitation.InvitationId, now)).To(gomega.Succeed())
+		gomega.Expect(provider.CancelAccept(invitation.InvitationId, now)).NotTo(gomega.Succeed())
+		retrieved, err := provider.Get(invitation.InvitationId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.RevokedAt).Should(gomega.Equal(now))
+		gomega.Expect(retrieved.AcceptedAt).Should(gomega.BeZero())
+	})
+
+	ginkgo.It("should not accept a revoked invitation", func() {
+		invitation := createInvitation(entities.GenerateUUID())
+		gomega.Expect(provider.Add(*invitation)).To(gomega.Succeed())
+
+		now := time.Now().Unix()
+		gomega.Expect(provider.Revoke(invitation.InvitationId, now)).To(gomega.Succeed())
+		err := provider.Accept(invitation.InvitationId, now)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.FailedPrecondition))
+	})
+
+	ginkgo.It("should fail to accept a missing invitation", func() {
+		err := provider.Accept(entities.GenerateUUID(), time.Now().Unix())
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should be able to remove an invitation", func() {
+		invitation := createInvitation(entities.GenerateUUID())
+		gomega.Expect(provider.Add(*invitation)).To(gomega.Succeed())
+
+		gomega.Expect(provider.Remove(invitation.InvitationId)).To(gomega.Succeed())
+		_, err := provider.Get(invitation.InvitationId)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+}
@@ -0,0 +1,315 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invitation
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/scylladb-utils/pkg/scylladb"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+	"github.com/scylladb/gocqlx"
+	"github.com/scylladb/gocqlx/qb"
+	"sync"
+	"time"
+)
+
+const invitationTable = "Invitations"
+const invitationTablePK = "invitation_id"
+const invitationEmailTable = "Invitation_Emails"
+
+var invitationTableColumns = []string{"organization_id", "invitation_id", "email", "role_id", "invited_by", "hash",
+	"created", "expires_at", "accepted_at", "revoked_at"}
+var invitationTableColumnsNoPK = []string{"organization_id", "email", "role_id", "invited_by", "hash",
+	"created", "expires_at", "accepted_at", "revoked_at"}
+
+type ScyllaInvitationProvider struct {
+	scylladb.ScyllaDB
+	sync.Mutex
+}
+
+func NewScyllaInvitationProvider(address string, port int, keyspace string) *ScyllaInvitationProvider {
+	provider := ScyllaInvitationProvider{
+		ScyllaDB: scylladb.ScyllaDB{
+			Address:  address,
+			Port:     port,
+			Keyspace: keyspace,
+		},
+	}
+	provider.Connect()
+	return &provider
+}
+
+// Add a new invitation. The email is reserved in the organization with a conditional insert, replacing the
+// reservation of a previous invitation that is no longer pending, so an email cannot have two pending invitations.
+func (s *ScyllaInvitationProvider) Add(invitation entities.Invitation) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.unsafeReserveEmail(invitation); err != nil {
+		return err
+	}
+	err := s.UnsafeAdd(invitationTable, invitationTablePK, invitation.InvitationId, invitationTableColumns, invitation)
+	if err != nil {
+		if rErr := s.unsafeReleaseEmail(invitation); rErr != nil {
+			log.Error().Str("invitationId", invitation.InvitationId).Str("trace", rErr.DebugReport()).Msg("cannot release the invited email")
+		}
+		return err
+	}
+	return nil
+}
+
+// unsafeReserveEmail reserves the email of an invitation in its organization.
+func (s *ScyllaInvitationProvider) unsafeReserveEmail(invitation entities.Invitation) derrors.Error {
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, names := qb.Insert(invitationEmailTable).Columns("organization_id", "email", "invitation_id").Unique().ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindStruct(invitation)
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot reserve the invited email")
+	}
+	if applied {
+		return nil
+	}
+
+	reservedBy, _ := current["invitation_id"].(string)
+	var previous interface{} = &entities.Invitation{}
+	err := s.UnsafeGet(invitationTable, invitationTablePK, reservedBy, invitationTableColumns, &previous)
+	if err == nil && previous.(*entities.Invitation).Status(time.Now().Unix()) == entities.InvitationPending {
+		return derrors.NewAlreadyExistsError("pending invitation").WithParams(invitation.Email, reservedBy)
+	}
+	if err != nil && err.Type() != derrors.NotFound {
+		return err
+	}
+
+	stmt, names = qb.Update(invitationEmailTable).Set("invitation_id").Where(qb.Eq("organization_id"), qb.Eq("email")).
+		If(qb.EqNamed("invitation_id", "reserved_by")).ToCql()
+	q = gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		"organization_id": invitation.OrganizationId,
+		"email":           invitation.Email,
+		"invitation_id":   invitation.InvitationId,
+		"reserved_by":     reservedBy,
+	})
+	current = make(map[string]interface{}, 0)
+	applied, cqlErr = q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot reserve the invited email")
+	}
+	if !applied {
+		return derrors.NewAlreadyExistsError("pending invitation").WithParams(invitation.Email)
+	}
+	return nil
+}
+
+// unsafeReleaseEmail removes the reservation of the email of an invitation, if it belongs to the invitation.
+func (s *ScyllaInvitationProvider) unsafeReleaseEmail(invitation entities.Invitation) derrors.Error {
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, names := qb.Delete(invitationEmailTable).Where(qb.Eq("organization_id"), qb.Eq("email")).
+		If(qb.Eq("invitation_id")).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindStruct(invitation)
+	current := make(map[string]interface{}, 0)
+	_, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot release the invited email")
+	}
+	return nil
+}
+
+// Update an existing invitation.
+func (s *ScyllaInvitationProvider) Update(invitation entities.Invitation) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.UnsafeUpdate(invitationTable, invitationTablePK, invitation.InvitationId, invitationTableColumnsNoPK, invitation)
+}
+
+// Get an invitation.
+func (s *ScyllaInvitationProvider) Get(invitationID string) (*entities.Invitation, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var invitation interface{} = &entities.Invitation{}
+	err := s.UnsafeGet(invitationTable, invitationTablePK, invitationID, invitationTableColumns, &invitation)
+	if err != nil {
+		return nil, err
+	}
+	return invitation.(*entities.Invitation), nil
+}
+
+// List the invitations of an organization.
+func (s *ScyllaInvitationProvider) List(organizationID string) ([]entities.Invitation, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return nil, err
+	}
+
+	stmt, names := qb.Select(invitationTable).Columns(invitationTableColumns...).
+		Where(qb.Eq("organization_id")).AllowFiltering().ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		"organization_id": organizationID,
+	})
+
+	invitations := make([]entities.Invitation, 0)
+	cqlErr := q.SelectRelease(&invitations)
+	if cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot list invitations")
+	}
+	return invitations, nil
+}
+
+// ListByEmail retrieves the invitations sent to an email or created by it in any organization.
+func (s *ScyllaInvitationProvider) ListByEmail(email string) ([]entities.Invitation, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return nil, err
+	}
+
+	result := make([]entities.Invitation, 0)
+	found := make(map[string]bool, 0)
+	for _, column := range []string{"email", "invited_by"} {
+		stmt, names := qb.Select(invitationTable).Columns(invitationTableColumns...).
+			Where(qb.Eq(column)).AllowFiltering().ToCql()
+		q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+			column: email,
+		})
+		invitations := make([]entities.Invitation, 0)
+		cqlErr := q.SelectRelease(&invitations)
+		if cqlErr != nil {
+			return nil, derrors.AsError(cqlErr, "cannot list invitations")
+		}
+		// an email can invite itself, so the invitation is returned by both queries
+		for _, invitation := range invitations {
+			if !found[invitation.InvitationId] {
+				found[invitation.InvitationId] = true
+				result = append(result, invitation)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Accept marks an invitation as accepted.
+func (s *ScyllaInvitationProvider) Accept(invitationID string, acceptedAt int64) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.unsafeMarkUsed(invitationID, "accepted_at", acceptedAt)
+}
+
+// Revoke marks an invitation as revoked.
+func (s *ScyllaInvitationProvider) Revoke(invitationID string, revokedAt int64) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.unsafeMarkUsed(invitationID, "revoked_at", revokedAt)
+}
+
+// CancelAccept restores an accepted invitation to pending, only if it is still accepted at the given time.
+func (s *ScyllaInvitationProvider) CancelAccept(invitationID string, acceptedAt int64) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, names := qb.Update(invitationTable).SetNamed("accepted_at", "unused").Where(qb.Eq(invitationTablePK)).
+		If(qb.Eq("accepted_at")).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		invitationTablePK: invitationID,
+		"accepted_at":     acceptedAt,
+		"unused":          int64(0),
+	})
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot update invitation")
+	}
+	if !applied {
+		if _, exists := current["accepted_at"]; !exists {
+			return derrors.NewNotFoundError("invitation").WithParams(invitationID)
+		}
+		return derrors.NewFailedPreconditionError("invitation not accepted at the given time").WithParams(invitationID, acceptedAt)
+	}
+	return nil
+}
+
+// unsafeMarkUsed sets the timestamp of a column if the invitation has not been accepted nor revoked.
+func (s *ScyllaInvitationProvider) unsafeMarkUsed(invitationID string, column string, timestamp int64) derrors.Error {
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, names := qb.Update(invitationTable).SetNamed(column, "used_at").Where(qb.Eq(invitationTablePK)).
+		If(qb.EqNamed("accepted_at", "unused"), qb.EqNamed("revoked_at", "unused")).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		invitationTablePK: invitationID,
+		"used_at":         timestamp,
+		"unused":          int64(0),
+	})
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot update invitation")
+	}
+	if !applied {
+		// a conditional update on a missing row does not return the current values
+		if _, exists := current["accepted_at"]; !exists {
+			return derrors.NewNotFoundError("invitation").WithParams(invitationID)
+		}
+		return derrors.NewFailedPreconditionError("invitation already used").WithParams(invitationID)
+	}
+	return nil
+}
+
+// Remove an invitation and the reservation of its email.
+func (s *ScyllaInvitationProvider) Remove(invitationID string) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	var invitation interface{} = &entities.Invitation{}
+	err := s.UnsafeGet(invitationTable, invitationTablePK, invitationID, invitationTableColumns, &invitation)
+	if err != nil {
+		return err
+	}
+	if err := s.unsafeReleaseEmail(*invitation.(*entities.Invitation)); err != nil {
+		return err
+	}
+	return s.UnsafeRemove(invitationTable, invitationTablePK, invitationID)
+}
+
+func (s *ScyllaInvitationProvider) Clear() derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.UnsafeClear([]string{invitationTable, invitationEmailTable})
+}
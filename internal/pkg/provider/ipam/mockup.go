@@ -0,0 +1,157 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ipam
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"sync"
+)
+
+type MockupIPAMProvider struct {
+	sync.Mutex
+	// ranges indexed by organization and CIDR
+	ranges map[string]map[string]entities.IPRange
+	// addresses indexed by organization, CIDR and IP
+	addresses map[string]map[string]map[string]entities.IPAddress
+}
+
+func NewMockupIPAMProvider() *MockupIPAMProvider {
+	return &MockupIPAMProvider{
+		ranges:    make(map[string]map[string]entities.IPRange, 0),
+		addresses: make(map[string]map[string]map[string]entities.IPAddress, 0),
+	}
+}
+
+// AddRange adds a new range.
+func (m *MockupIPAMProvider) AddRange(ipRange entities.IPRange) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	ranges, exists := m.ranges[ipRange.OrganizationId]
+	if !exists {
+		ranges = make(map[string]entities.IPRange, 0)
+		m.ranges[ipRange.OrganizationId] = ranges
+	}
+	if _, exists := ranges[ipRange.Cidr]; exists {
+		return derrors.NewAlreadyExistsError("ip range").WithParams(ipRange.OrganizationId, ipRange.Cidr)
+	}
+	ranges[ipRange.Cidr] = ipRange
+	return nil
+}
+
+// GetRange retrieves a range.
+func (m *MockupIPAMProvider) GetRange(organizationID string, cidr string) (*entities.IPRange, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	ipRange, exists := m.ranges[organizationID][cidr]
+	if !exists {
+		return nil, derrors.NewNotFoundError("ip range").WithParams(organizationID, cidr)
+	}
+	return &ipRange, nil
+}
+
+// ListRanges retrieves the ranges of an organization.
+func (m *MockupIPAMProvider) ListRanges(organizationID string) ([]entities.IPRange, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	result := make([]entities.IPRange, 0)
+	for _, ipRange := range m.ranges[organizationID] {
+		result = append(result, ipRange)
+	}
+	return result, nil
+}
+
+// RemoveRange removes a range.
+func (m *MockupIPAMProvider) RemoveRange(organizationID string, cidr string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, exists := m.ranges[organizationID][cidr]; !exists {
+		return derrors.NewNotFoundError("ip range").WithParams(organizationID, cidr)
+	}
+	delete(m.ranges[organizationID], cidr)
+	return nil
+}
+
+// AddAddress adds a new address to a range.
+func (m *MockupIPAMProvider) AddAddress(address entities.IPAddress) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	byRange, exists := m.addresses[address.OrganizationId]
+	if !exists {
+		byRange = make(map[string]map[string]entities.IPAddress, 0)
+		m.addresses[address.OrganizationId] = byRange
+	}
+	addresses, exists := byRange[address.Cidr]
+	if !exists {
+		addresses = make(map[string]entities.IPAddress, 0)
+		byRange[address.Cidr] = addresses
+	}
+	if _, exists := addresses[address.Ip]; exists {
+		return derrors.NewAlreadyExistsError("ip address").WithParams(address.OrganizationId, address.Cidr, address.Ip)
+	}
+	addresses[address.Ip] = address
+	return nil
+}
+
+// ListAddresses retrieves the addresses of a range.
+func (m *MockupIPAMProvider) ListAddresses(organizationID string, cidr string) ([]entities.IPAddress, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	result := make([]entities.IPAddress, 0)
+	for _, address := range m.addresses[organizationID][cidr] {
+		result = append(result, address)
+	}
+	return result, nil
+}
+
+// RemoveAddress removes an address of a range.
+func (m *MockupIPAMProvider) RemoveAddress(organizationID string, cidr string, ip string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, exists := m.addresses[organizationID][cidr][ip]; !exists {
+		return derrors.NewNotFoundError("ip address").WithParams(organizationID, cidr, ip)
+	}
+	delete(m.addresses[organizationID][cidr], ip)
+	return nil
+}
+
+// RemoveAddresses removes all the addresses of a range.
+func (m *MockupIPAMProvider) RemoveAddresses(organizationID string, cidr string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if byRange, exists := m.addresses[organizationID]; exists {
+		delete(byRange, cidr)
+	}
+	return nil
+}
+
+func (m *MockupIPAMProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.ranges = make(map[string]map[string]entities.IPRange, 0)
+	m.addresses = make(map[string]map[string]map[string]entities.IPAddress, 0)
+	return nil
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ipam
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+)
+
+// Provider for the IP ranges and addresses allocated to the organizations.
+type Provider interface {
+	// AddRange adds a new range.
+	AddRange(ipRange entities.IPRange) derrors.Error
+	// GetRange retrieves a range.
+	GetRange(organizationID string, cidr string) (*entities.IPRange, derrors.Error)
+	// ListRanges retrieves the ranges of an organization.
+	ListRanges(organizationID string) ([]entities.IPRange, derrors.Error)
+	// RemoveRange removes a range.
+	RemoveRange(organizationID string, cidr string) derrors.Error
+
+	// AddAddress adds a new address to a range.
+	AddAddress(address entities.IPAddress) derrors.Error
+	// ListAddresses retrieves the addresses of a range.
+	ListAddresses(organizationID string, cidr string) ([]entities.IPAddress, derrors.Error)
+	// RemoveAddress removes an address of a range.
+	RemoveAddress(organizationID string, cidr string, ip string) derrors.Error
+	// RemoveAddresses removes all the addresses of a range.
+	RemoveAddresses(organizationID string, cidr string) derrors.Error
+
+	Clear() derrors.Error
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ipam
+
+import (
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+func RunTest(provider Provider) {
+
+	ginkgo.AfterEach(func() {
+		provider.Clear()
+	})
+
+	ginkgo.Context("ranges", func() {
+		ginkgo.It("should be able to add a range", func() {
+			ipRange := entities.NewIPRange(entities.GenerateUUID(), "10.0.1.0/24", entities.GenerateUUID())
+			err := provider.AddRange(*ipRange)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			err = provider.AddRange(*ipRange)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should be able to get a range", func() {
+			ipRange := entities.NewIPRange(entities.GenerateUUID(), "10.0.1.0/24", entities.GenerateUUID())
+			gomega.Expect(provider.AddRange(*ipRange)).To(gomega.Succeed())
+
+			retrieved, err := provider.GetRange(ipRange.OrganizationId, ipRange.Cidr)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(retrieved).To(gomega.Equal(ipRange))
+
+			_, err = provider.GetRange(ipRange.OrganizationId, "10.0.2.0/24")
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should be able to list the ranges of an organization", func() {
+			organizationID := entities.GenerateUUID()
+			gomega.Expect(provider.AddRange(*entities.NewIPRange(organizationID, "10.0.1.0/24", "a"))).To(gomega.Succeed())
+			gomega.Expect(provider.AddRange(*entities.NewIPRange(organizationID, "10.0.2.0/24", "b"))).To(gomega.Succeed())
+			gomega.Expect(provider.AddRange(*entities.NewIPRange(entities.GenerateUUID(), "10.0.1.0/24", "c"))).To(gomega.Succeed())
+
+			ranges, err := provider.ListRanges(organizationID)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(ranges)).Should(gomega.Equal(2))
+		})
+		ginkgo.It("should be able to remove a range", func() {
+			ipRange := entities.NewIPRange(entities.GenerateUUID(), "10.0.1.0/24", entities.GenerateUUID())
+			gomega.Expect(provider.AddRange(*ipRange)).To(gomega.Succeed())
+
+			err := provider.RemoveRange(ipRange.OrganizationId, ipRange.Cidr)
+			gomega.Expect(err).To(gomega.Succeed())
+			_, err = provider.GetRange(ipRange.OrganizationId, ipRange.Cidr)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+	})
+
+	ginkgo.Context("addresses", func() {
+		ginkgo.It("should be able to add and list addresses", func() {
+			organizationID := entities.GenerateUUID()
+			address := entities.NewIPAddress(organizationID, "10.0.1.0/24", "10.0.1.1", entities.GenerateUUID())
+			gomega.Expect(provider.AddAddress(*address)).To(gomega.Succeed())
+			gomega.Expect(provider.AddAddress(*address)).NotTo(gomega.Succeed())
+			gomega.Expect(provider.AddAddress(*entities.NewIPAddress(organizationID, "10.0.1.0/24", "10.0.1.2", "b"))).To(gomega.Succeed())
+
+			addresses, err := provider.ListAddresses(organizationID, "10.0.1.0/24")
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(addresses)).Should(gomega.Equal(2))
+
+			addresses, err = provider.ListAddresses(organizationID, "10.0.2.0/24")
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(addresses).Should(gomega.BeEmpty())
+		})
+		ginkgo.It("should be able to remove addresses", func() {
+			organizationID := entities.GenerateUUID()
+			gomega.Expect(provider.AddAddress(*entities.NewIPAddress(organizationID, "10.0.1.0/24", "10.0.1.1", "a"))).To(gomega.Succeed())
+			gomega.Expect(provider.AddAddress(*entities.NewIPAddress(organizationID, "10.0.1.0/24", "10.0.1.2", "b"))).To(gomega.Succeed())
+
+			gomega.Expect(provider.RemoveAddress(organizationID, "10.0.1.0/24", "10.0.1.1")).To(gomega.Succeed())
+			addresses, err := provider.ListAddresses(organizationID, "10.0.1.0/24")
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(addresses)).Should(gomega.Equal(1))
+
+			gomega.Expect(provider.RemoveAddresses(organizationID, "10.0.1.0/24")).To(gomega.Succeed())
+			addresses, err = provider.ListAddresses(organizationID, "10.0.1.0/24")
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(addresses).Should(gomega.BeEmpty())
+		})
+	})
+}
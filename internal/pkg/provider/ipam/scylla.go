@@ -0,0 +1,203 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ipam
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/scylladb-utils/pkg/scylladb"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/scylladb/gocqlx"
+	"github.com/scylladb/gocqlx/qb"
+	"sync"
+)
+
+const ipRangeTable = "IPRanges"
+const ipAddressTable = "IPAddresses"
+
+var ipRangeTableColumns = []string{"organization_id", "cidr", "owner_id", "allocated"}
+var ipAddressTableColumns = []string{"organization_id", "cidr", "ip", "owner_id", "allocated"}
+
+type ScyllaIPAMProvider struct {
+	scylladb.ScyllaDB
+	sync.Mutex
+}
+
+func NewScyllaIPAMProvider(address string, port int, keyspace string) *ScyllaIPAMProvider {
+	provider := ScyllaIPAMProvider{
+		ScyllaDB: scylladb.ScyllaDB{
+			Address:  address,
+			Port:     port,
+			Keyspace: keyspace,
+		},
+	}
+	provider.Connect()
+	return &provider
+}
+
+func (s *ScyllaIPAMProvider) createRangePKMap(organizationID string, cidr string) map[string]interface{} {
+	return map[string]interface{}{
+		"organization_id": organizationID,
+		"cidr":            cidr,
+	}
+}
+
+func (s *ScyllaIPAMProvider) createAddressPKMap(organizationID string, cidr string, ip string) map[string]interface{} {
+	return map[string]interface{}{
+		"organization_id": organizationID,
+		"cidr":            cidr,
+		"ip":              ip,
+	}
+}
+
+// unsafeInsertUnique inserts a row with a conditional insert, so only one of the concurrent writers of the same
+// primary key succeeds. It returns AlreadyExists if the row exists.
+func (s *ScyllaIPAMProvider) unsafeInsertUnique(table string, columns []string, value interface{}, entity string, params ...interface{}) derrors.Error {
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, names := qb.Insert(table).Columns(columns...).Unique().ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindStruct(value)
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot add "+entity)
+	}
+	if !applied {
+		return derrors.NewAlreadyExistsError(entity).WithParams(params...)
+	}
+	return nil
+}
+
+// AddRange adds a new range.
+func (s *ScyllaIPAMProvider) AddRange(ipRange entities.IPRange) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.unsafeInsertUnique(ipRangeTable, ipRangeTableColumns, ipRange, "ip range", ipRange.OrganizationId, ipRange.Cidr)
+}
+
+// GetRange retrieves a range.
+func (s *ScyllaIPAMProvider) GetRange(organizationID string, cidr string) (*entities.IPRange, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	pk := s.createRangePKMap(organizationID, cidr)
+	var ipRange interface{} = &entities.IPRange{}
+	err := s.UnsafeCompositeGet(ipRangeTable, pk, ipRangeTableColumns, &ipRange)
+	if err != nil {
+		return nil, err
+	}
+	return ipRange.(*entities.IPRange), nil
+}
+
+// ListRanges retrieves the ranges of an organization.
+func (s *ScyllaIPAMProvider) ListRanges(organizationID string) ([]entities.IPRange, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return nil, err
+	}
+
+	stmt, names := qb.Select(ipRangeTable).Columns(ipRangeTableColumns...).Where(qb.Eq("organization_id")).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		"organization_id": organizationID,
+	})
+
+	ranges := make([]entities.IPRange, 0)
+	cqlErr := q.SelectRelease(&ranges)
+	if cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot list ip ranges")
+	}
+	return ranges, nil
+}
+
+// RemoveRange removes a range.
+func (s *ScyllaIPAMProvider) RemoveRange(organizationID string, cidr string) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	pk := s.createRangePKMap(organizationID, cidr)
+	return s.UnsafeCompositeRemove(ipRangeTable, pk)
+}
+
+// AddAddress adds a new address to a range.
+func (s *ScyllaIPAMProvider) AddAddress(address entities.IPAddress) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.unsafeInsertUnique(ipAddressTable, ipAddressTableColumns, address, "ip address", address.OrganizationId, address.Cidr, address.Ip)
+}
+
+// ListAddresses retrieves the addresses of a range.
+func (s *ScyllaIPAMProvider) ListAddresses(organizationID string, cidr string) ([]entities.IPAddress, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return nil, err
+	}
+
+	stmt, names := qb.Select(ipAddressTable).Columns(ipAddressTableColumns...).
+		Where(qb.Eq("organization_id"), qb.Eq("cidr")).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		"organization_id": organizationID,
+		"cidr":            cidr,
+	})
+
+	addresses := make([]entities.IPAddress, 0)
+	cqlErr := q.SelectRelease(&addresses)
+	if cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot list ip addresses")
+	}
+	return addresses, nil
+}
+
+// RemoveAddress removes an address of a range.
+func (s *ScyllaIPAMProvider) RemoveAddress(organizationID string, cidr string, ip string) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	pk := s.createAddressPKMap(organizationID, cidr, ip)
+	return s.UnsafeCompositeRemove(ipAddressTable, pk)
+}
+
+// RemoveAddresses removes all the addresses of a range.
+func (s *ScyllaIPAMProvider) RemoveAddresses(organizationID string, cidr string) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, _ := qb.Delete(ipAddressTable).Where(qb.Eq("organization_id"), qb.Eq("cidr")).ToCql()
+	cqlErr := s.Session.Query(stmt, organizationID, cidr).Exec()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot remove ip addresses")
+	}
+	return nil
+}
+
+func (s *ScyllaIPAMProvider) Clear() derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.UnsafeClear([]string{ipRangeTable, ipAddressTable})
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package name_reservation
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"sync"
+)
+
+type MockupNameReservationProvider struct {
+	sync.Mutex
+	// reservations indexed by scope and name
+	reservations map[string]map[string]entities.NameReservation
+}
+
+func NewMockupNameReservationProvider() *MockupNameReservationProvider {
+	return &MockupNameReservationProvider{
+		reservations: make(map[string]map[string]entities.NameReservation, 0),
+	}
+}
+
+func (m *MockupNameReservationProvider) unsafeReserve(reservation entities.NameReservation) derrors.Error {
+	names, exists := m.reservations[reservation.Scope]
+	if !exists {
+		names = make(map[string]entities.NameReservation, 0)
+		m.reservations[reservation.Scope] = names
+	}
+	if current, exists := names[reservation.Name]; exists {
+		if current.OwnerId == reservation.OwnerId {
+			return nil
+		}
+		return derrors.NewAlreadyExistsError("name").WithParams(reservation.Scope, reservation.Name)
+	}
+	names[reservation.Name] = reservation
+	return nil
+}
+
+// Reserve a name for an owner.
+func (m *MockupNameReservationProvider) Reserve(reservation entities.NameReservation) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.unsafeReserve(reservation)
+}
+
+// Rename moves the reservation of an owner to a new name.
+func (m *MockupNameReservationProvider) Rename(scope string, oldName string, newName string, ownerID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	err := m.unsafeReserve(*entities.NewNameReservation(scope, newName, ownerID))
+	if err != nil {
+		return err
+	}
+	if current, exists := m.reservations[scope][oldName]; exists && current.OwnerId == ownerID && oldName != newName {
+		delete(m.reservations[scope], oldName)
+	}
+	return nil
+}
+
+// Get retrieves the reservation of a name.
+func (m *MockupNameReservationProvider) Get(scope string, name string) (*entities.NameReservation, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	reservation, exists := m.reservations[scope][name]
+	if !exists {
+		return nil, derrors.NewNotFoundError("name").WithParams(scope, name)
+	}
+	return &reservation, nil
+}
+
+// Release a name held by an owner.
+func (m *MockupNameReservationProvider) Release(scope string, name string, ownerID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	current, exists := m.reservations[scope][name]
+	if !exists {
+		return nil
+	}
+	if current.OwnerId != ownerID {
+		return derrors.NewFailedPreconditionError("name reserved by another owner").WithParams(scope, name, current.OwnerId)
+	}
+	delete(m.reservations[scope], name)
+	return nil
+}
+
+func (m *MockupNameReservationProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.reservations = make(map[string]map[string]entities.NameReservation, 0)
+	return nil
+}
@@ -0,0 +1,39 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package name_reservation
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+)
+
+// Provider for the reservations that keep names unique in a scope. All the operations are atomic, so concurrent
+// requests cannot reserve the same name.
+type Provider interface {
+	// Reserve a name for an owner. It fails with an AlreadyExists error if the name is reserved by another owner, and
+	// succeeds if the owner already holds it.
+	Reserve(reservation entities.NameReservation) derrors.Error
+	// Rename moves the reservation of an owner to a new name. The new name is reserved and the old one released in
+	// a single operation. If the owner does not hold the old name, only the new one is reserved.
+	Rename(scope string, oldName string, newName string, ownerID string) derrors.Error
+	// Get retrieves the reservation of a name.
+	Get(scope string, name string) (*entities.NameReservation, derrors.Error)
+	// Release a name held by an owner. Releasing a name that is not reserved succeeds.
+	Release(scope string, name string, ownerID string) derrors.Error
+
+	Clear() derrors.Error
+}
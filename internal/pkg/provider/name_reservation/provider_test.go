@@ -0,0 +1,108 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package name_reservation
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"sync"
+)
+
+func RunTest(provider Provider) {
+
+	ginkgo.AfterEach(func() {
+		provider.Clear()
+	})
+
+	ginkgo.It("should reserve a name", func() {
+		reservation := entities.NewNameReservation(entities.OrganizationNameScope, "nalej", entities.GenerateUUID())
+		gomega.Expect(provider.Reserve(*reservation)).To(gomega.Succeed())
+		// the owner may reserve it again
+		gomega.Expect(provider.Reserve(*reservation)).To(gomega.Succeed())
+
+		err := provider.Reserve(*entities.NewNameReservation(entities.OrganizationNameScope, "nalej", entities.GenerateUUID()))
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.AlreadyExists))
+
+		// names are unique per scope
+		gomega.Expect(provider.Reserve(*entities.NewNameReservation(entities.AccountNameScope, "nalej", entities.GenerateUUID()))).To(gomega.Succeed())
+
+		retrieved, err := provider.Get(entities.OrganizationNameScope, "nalej")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved).To(gomega.Equal(reservation))
+	})
+
+	ginkgo.It("should let a single owner reserve a name concurrently", func() {
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		reserved := 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := provider.Reserve(*entities.NewNameReservation(entities.OrganizationNameScope, "nalej", entities.GenerateUUID()))
+				if err == nil {
+					mutex.Lock()
+					reserved++
+					mutex.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		gomega.Expect(reserved).Should(gomega.Equal(1))
+	})
+
+	ginkgo.It("should rename a reservation", func() {
+		ownerID := entities.GenerateUUID()
+		gomega.Expect(provider.Reserve(*entities.NewNameReservation(entities.OrganizationNameScope, "old", ownerID))).To(gomega.Succeed())
+		gomega.Expect(provider.Reserve(*entities.NewNameReservation(entities.OrganizationNameScope, "taken", entities.GenerateUUID()))).To(gomega.Succeed())
+
+		err := provider.Rename(entities.OrganizationNameScope, "old", "taken", ownerID)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		retrieved, err := provider.Get(entities.OrganizationNameScope, "old")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.OwnerId).Should(gomega.Equal(ownerID))
+
+		gomega.Expect(provider.Rename(entities.OrganizationNameScope, "old", "new", ownerID)).To(gomega.Succeed())
+		_, err = provider.Get(entities.OrganizationNameScope, "old")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		retrieved, err = provider.Get(entities.OrganizationNameScope, "new")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.OwnerId).Should(gomega.Equal(ownerID))
+	})
+
+	ginkgo.It("should rename a name that was not reserved", func() {
+		ownerID := entities.GenerateUUID()
+		gomega.Expect(provider.Rename(entities.OrganizationNameScope, "legacy", "new", ownerID)).To(gomega.Succeed())
+		retrieved, err := provider.Get(entities.OrganizationNameScope, "new")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.OwnerId).Should(gomega.Equal(ownerID))
+	})
+
+	ginkgo.It("should release a name", func() {
+		ownerID := entities.GenerateUUID()
+		gomega.Expect(provider.Reserve(*entities.NewNameReservation(entities.OrganizationNameScope, "nalej", ownerID))).To(gomega.Succeed())
+
+		gomega.Expect(provider.Release(entities.OrganizationNameScope, "nalej", entities.GenerateUUID())).NotTo(gomega.Succeed())
+		gomega.Expect(provider.Release(entities.OrganizationNameScope, "nalej", ownerID)).To(gomega.Succeed())
+		_, err := provider.Get(entities.OrganizationNameScope, "nalej")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(provider.Release(entities.OrganizationNameScope, "nalej", ownerID)).To(gomega.Succeed())
+	})
+}
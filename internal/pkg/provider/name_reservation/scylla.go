@@ -0,0 +1,172 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package name_reservation
+
+import (
+	"github.com/gocql/gocql"
+	"github.com/nalej/derrors"
+	"github.com/nalej/scylladb-utils/pkg/scylladb"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/scylladb/gocqlx"
+	"github.com/scylladb/gocqlx/qb"
+	"sync"
+)
+
+const nameReservationTable = "NameReservations"
+
+var nameReservationTableColumns = []string{"scope", "name", "owner_id", "reserved"}
+
+// ScyllaNameReservationProvider uses lightweight transactions to reserve the names. The names of a scope share a
+// partition, so a rename is a single conditional batch.
+type ScyllaNameReservationProvider struct {
+	scylladb.ScyllaDB
+	sync.Mutex
+}
+
+func NewScyllaNameReservationProvider(address string, port int, keyspace string) *ScyllaNameReservationProvider {
+	provider := ScyllaNameReservationProvider{
+		ScyllaDB: scylladb.ScyllaDB{
+			Address:  address,
+			Port:     port,
+			Keyspace: keyspace,
+		},
+	}
+	provider.Connect()
+	return &provider
+}
+
+func (s *ScyllaNameReservationProvider) createPKMap(scope string, name string) map[string]interface{} {
+	return map[string]interface{}{
+		"scope": scope,
+		"name":  name,
+	}
+}
+
+func (s *ScyllaNameReservationProvider) unsafeReserve(reservation entities.NameReservation) derrors.Error {
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, names := qb.Insert(nameReservationTable).Columns(nameReservationTableColumns...).Unique().ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindStruct(reservation)
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot reserve name")
+	}
+	if !applied && current["owner_id"] != reservation.OwnerId {
+		return derrors.NewAlreadyExistsError("name").WithParams(reservation.Scope, reservation.Name)
+	}
+	return nil
+}
+
+func (s *ScyllaNameReservationProvider) unsafeRelease(scope string, name string, ownerID string) derrors.Error {
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, names := qb.Delete(nameReservationTable).Where(qb.Eq("scope"), qb.Eq("name")).If(qb.Eq("owner_id")).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		"scope":    scope,
+		"name":     name,
+		"owner_id": ownerID,
+	})
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot release name")
+	}
+	// a delete that is not applied only returns the current owner if the name is reserved
+	if owner, reserved := current["owner_id"]; !applied && reserved {
+		return derrors.NewFailedPreconditionError("name reserved by another owner").WithParams(scope, name, owner)
+	}
+	return nil
+}
+
+// Reserve a name for an owner.
+func (s *ScyllaNameReservationProvider) Reserve(reservation entities.NameReservation) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.unsafeReserve(reservation)
+}
+
+// Rename moves the reservation of an owner to a new name.
+func (s *ScyllaNameReservationProvider) Rename(scope string, oldName string, newName string, ownerID string) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	reservation := entities.NewNameReservation(scope, newName, ownerID)
+	if oldName == newName {
+		return s.unsafeReserve(*reservation)
+	}
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	insertStmt, _ := qb.Insert(nameReservationTable).Columns(nameReservationTableColumns...).Unique().ToCql()
+	deleteStmt, _ := qb.Delete(nameReservationTable).Where(qb.Eq("scope"), qb.Eq("name")).If(qb.Eq("owner_id")).ToCql()
+	batch := s.Session.NewBatch(gocql.LoggedBatch)
+	batch.Query(insertStmt, reservation.Scope, reservation.Name, reservation.OwnerId, reservation.Reserved)
+	batch.Query(deleteStmt, scope, oldName, ownerID)
+	applied, iter, cqlErr := s.Session.MapExecuteBatchCAS(batch, make(map[string]interface{}, 0))
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot rename reservation")
+	}
+	iter.Close()
+	if applied {
+		return nil
+	}
+
+	// the new name is taken, or the owner does not hold the old name
+	err := s.unsafeReserve(*reservation)
+	if err != nil {
+		return err
+	}
+	return s.unsafeRelease(scope, oldName, ownerID)
+}
+
+// Get retrieves the reservation of a name.
+func (s *ScyllaNameReservationProvider) Get(scope string, name string) (*entities.NameReservation, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	pk := s.createPKMap(scope, name)
+	var reservation interface{} = &entities.NameReservation{}
+	err := s.UnsafeCompositeGet(nameReservationTable, pk, nameReservationTableColumns, &reservation)
+	if err != nil {
+		return nil, err
+	}
+	return reservation.(*entities.NameReservation), nil
+}
+
+// Release a name held by an owner.
+func (s *ScyllaNameReservationProvider) Release(scope string, name string, ownerID string) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.unsafeRelease(scope, name, ownerID)
+}
+
+func (s *ScyllaNameReservationProvider) Clear() derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.UnsafeClear([]string{nameReservationTable})
+}
@@ -57,6 +57,10 @@ func (m *MockupNodeProvider) Update(node entities.Node) derrors.Error {
 	if !m.unsafeExists(node.NodeId) {
 		return derrors.NewNotFoundError(node.NodeId)
 	}
+	if m.nodes[node.NodeId].Version != node.Version {
+		return entities.NewVersionConflictError("node", node.NodeId, node.Version)
+	}
+	node.Version++
 	m.nodes[node.NodeId] = node
 	return nil
 }
@@ -25,7 +25,8 @@ import (
 type Provider interface {
 	// Add a new node to the system.
 	Add(node entities.Node) derrors.Error
-	// Update an existing node in the system
+	// Update an existing node in the system. The update fails with a version conflict if the version of the
+	// node does not match the stored one, and increases the stored version otherwise.
 	Update(node entities.Node) derrors.Error
 	// Exists checks if a node exists on the system.
 	Exists(nodeID string) (bool, derrors.Error)
@@ -60,6 +60,21 @@ func RunTest(provider Provider) {
 		err = provider.Update(*node)
 		gomega.Expect(err).To(gomega.Succeed())
 
+	})
+	ginkgo.It("Should not be able to update a stale node", func() {
+
+		node := &entities.Node{OrganizationId: "org", ClusterId: "cluster_id", NodeId: "node",
+			Ip: "0.0.0.0", Labels: labels, Status: entities.InfraStatusRunning, State: 0}
+
+		err := provider.Add(*node)
+		gomega.Expect(err).To(gomega.Succeed())
+		err = provider.Update(*node)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		node.Ip = "127.0.0.1"
+		err = provider.Update(*node)
+		gomega.Expect(entities.IsVersionConflict(err)).To(gomega.BeTrue())
+
 	})
 	ginkgo.It("Should not be able to update role", func() {
 
@@ -21,6 +21,7 @@ import (
 	"github.com/nalej/derrors"
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/versioning"
 	"github.com/rs/zerolog/log"
 	"github.com/scylladb/gocqlx"
 	"github.com/scylladb/gocqlx/qb"
@@ -149,7 +150,7 @@ func (sp *ScyllaNodeProvider) Add(node entities.Node) derrors.Error {
 
 	// insert a user
 
-	stmt, names := qb.Insert(nodeTable).Columns("organization_id", "cluster_id", "node_id", "ip", "labels", "status", "state").ToCql()
+	stmt, names := qb.Insert(nodeTable).Columns("organization_id", "cluster_id", "node_id", "ip", "labels", "status", "state", "version").ToCql()
 	q := gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(node)
 	cqlErr := q.ExecRelease()
 
@@ -181,16 +182,12 @@ func (sp *ScyllaNodeProvider) Update(node entities.Node) derrors.Error {
 		return derrors.NewNotFoundError(node.NodeId)
 	}
 
-	// update a user
-	stmt, names := qb.Update(nodeTable).Set("organization_id", "cluster_id", "ip", "labels", "status", "state").Where(qb.Eq(nodeTablePK)).ToCql()
-	q := gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(node)
-	cqlErr := q.ExecRelease()
-
-	if cqlErr != nil {
-		return derrors.AsError(cqlErr, "cannot update node")
-	}
-
-	return nil
+	// update the node if nobody else updated it
+	expected := node.Version
+	node.Version++
+	pk := map[string]interface{}{nodeTablePK: node.NodeId}
+	columns := []string{"organization_id", "cluster_id", "ip", "labels", "status", "state", "version"}
+	return versioning.ScyllaUpdate(sp.Session, nodeTable, pk, columns, node, "node", node.NodeId, expected)
 }
 
 // Exists checks if a node exists on the system.
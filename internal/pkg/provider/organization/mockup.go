@@ -227,6 +227,19 @@ func (m *MockupOrganizationProvider) Update(org entities.Organization) derrors.E
 	return nil
 }
 
+// Remove an organization with its photo. The elements linked to the organization are not removed.
+func (m *MockupOrganizationProvider) Remove(organizationID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	org, exists := m.organizations[organizationID]
+	if !exists {
+		return derrors.NewNotFoundError(organizationID)
+	}
+	delete(m.organizationNames, org.Name)
+	delete(m.organizations, organizationID)
+	return nil
+}
+
 // AddCluster adds a new cluster ID to the organization.
 func (m *MockupOrganizationProvider) AddCluster(organizationID string, clusterID string) derrors.Error {
 	m.Lock()
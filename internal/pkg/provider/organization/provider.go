@@ -34,6 +34,8 @@ type Provider interface {
 	List() ([]entities.Organization, derrors.Error)
 	// Update the information of an organization
 	Update(org entities.Organization) derrors.Error
+	// Remove an organization with its photo. The elements linked to the organization are not removed.
+	Remove(organizationID string) derrors.Error
 
 	// AddCluster adds a new cluster ID to the organization.
 	AddCluster(organizationID string, clusterID string) derrors.Error
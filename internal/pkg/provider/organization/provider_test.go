@@ -95,6 +95,26 @@ func RunTest(provider Provider) {
 
 	})
 
+	ginkgo.It("Should be able to remove an organization", func() {
+
+		org := CreateOrganization()
+		err := provider.Add(*org)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		err = provider.Remove(org.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		exists, err := provider.Exists(org.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).Should(gomega.BeFalse())
+		exists, err = provider.ExistsByName(org.Name)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).Should(gomega.BeFalse())
+
+		err = provider.Remove(org.ID)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
 	ginkgo.It("Should not be able to get a organization", func() {
 
 		_, err := provider.Get("Org_0001")
@@ -44,8 +44,10 @@ const organizationPhotoTablePK = "organization_id"
 const organizationTableIndex = "name"
 
 // Columns
-var organizationTableColumns = []string{"id", "name", "email", "full_address", "city", "state", "country", "zip_code", "created"}
-var organizationTableColumnsNoPK = []string{"name", "email", "full_address", "city", "state", "country", "zip_code", "created"}
+var organizationTableColumns = []string{"id", "name", "email", "full_address", "city", "state", "country", "zip_code", "created",
+	"photo_id", "thumbnail_id"}
+var organizationTableColumnsNoPK = []string{"name", "email", "full_address", "city", "state", "country", "zip_code", "created",
+	"photo_id", "thumbnail_id"}
 var organizationPhotoTableColumns = []string{"organization_id", "photo_base64"}
 var organizationPhotoTableColumnsNoPK = []string{"photo_base64"}
 var organizationClusterTableColumns = []string{"organization_id", "cluster_id"}
@@ -381,6 +383,23 @@ func (sp *ScyllaOrganizationProvider) Update(org entities.Organization) derrors.
 	return nil
 }
 
+// Remove an organization with its photo. The elements linked to the organization are not removed.
+func (sp *ScyllaOrganizationProvider) Remove(organizationID string) derrors.Error {
+	sp.Lock()
+	defer sp.Unlock()
+
+	err := sp.UnsafeRemove(organizationTable, organizationTablePK, organizationID)
+	if err != nil {
+		return err
+	}
+	// the organizations added by older versions may not have a photo record
+	err = sp.UnsafeRemove(organizationPhotoTable, organizationPhotoTablePK, organizationID)
+	if err != nil && err.Type() != derrors.NotFound {
+		return err
+	}
+	return nil
+}
+
 // --------------------------------------------------------------------------------------------------------------------
 
 // AddCluster adds a new cluster ID to the organization.
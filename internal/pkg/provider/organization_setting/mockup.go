@@ -115,6 +115,10 @@ func (m *MockupOrganizationSettingProvider) Update(setting entities.Organization
 	}
 	for i := 0; i < len(settings); i++ {
 		if settings[i].Key == setting.Key {
+			if settings[i].Version != setting.Version {
+				return entities.NewVersionConflictError("setting", setting.Key, setting.Version)
+			}
+			setting.Version++
 			settings[i] = setting
 			return nil
 		}
@@ -31,7 +31,8 @@ type Provider interface {
 	Get(organizationID string, key string) (*entities.OrganizationSetting, derrors.Error)
 	// List all the settings of an organization.
 	List(organizationID string) ([]entities.OrganizationSetting, derrors.Error)
-	// Update a setting of an organization
+	// Update a setting of an organization. The update fails with a version conflict if the version of the
+	// setting does not match the stored one, and increases the stored version otherwise.
 	Update(setting entities.OrganizationSetting) derrors.Error
 	// Remove deletes a given setting.
 	Remove(organizationID string, key string) derrors.Error
@@ -132,9 +132,22 @@ func RunTest(provider Provider) {
 
 			retrieved, err := provider.Get(orgId, setting.Key)
 			gomega.Expect(err).Should(gomega.Succeed())
+			setting.Version++
 			gomega.Expect(retrieved).Should(gomega.Equal(setting))
 
 		})
+		ginkgo.It("should not be able to update a stale version of a setting", func() {
+			orgId := uuid.New().String()
+			setting := CreateOrganizationSetting(orgId)
+			err := provider.Add(*setting)
+			gomega.Expect(err).Should(gomega.Succeed())
+			err = provider.Update(*setting)
+			gomega.Expect(err).Should(gomega.Succeed())
+
+			setting.Value = "Stale value"
+			err = provider.Update(*setting)
+			gomega.Expect(entities.IsVersionConflict(err)).Should(gomega.BeTrue())
+		})
 		ginkgo.It("should not be able to update a non existing setting", func() {
 			orgId := uuid.New().String()
 			setting := CreateOrganizationSetting(orgId)
@@ -21,6 +21,7 @@ import (
 	"github.com/nalej/derrors"
 	"github.com/nalej/scylladb-utils/pkg/scylladb"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/versioning"
 	"github.com/scylladb/gocqlx"
 	"github.com/scylladb/gocqlx/qb"
 	"sync"
@@ -28,8 +29,8 @@ import (
 
 const organizationSettingTable = "OrganizationSetting"
 
-var organizationSettingTableColumns = []string{"organization_id", "key", "value", "description"}
-var organizationSettingTableColumnsNoPK = []string{"value", "description"}
+var organizationSettingTableColumns = []string{"organization_id", "key", "value", "description", "version"}
+var organizationSettingTableColumnsNoPK = []string{"value", "description", "version"}
 
 type ScyllaOrganizationSettingProvider struct {
 	scylladb.ScyllaDB
@@ -127,9 +128,22 @@ func (s *ScyllaOrganizationSettingProvider) Update(setting entities.Organization
 	s.Lock()
 	defer s.Unlock()
 
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
 	pk := s.createPKMap(setting.OrganizationId, setting.Key)
+	exists, err := s.UnsafeGenericCompositeExist(organizationSettingTable, pk)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("setting").WithParams(setting.OrganizationId, setting.Key)
+	}
 
-	return s.UnsafeCompositeUpdate(organizationSettingTable, pk, organizationSettingTableColumnsNoPK, setting)
+	expected := setting.Version
+	setting.Version++
+	return versioning.ScyllaUpdate(s.Session, organizationSettingTable, pk, organizationSettingTableColumnsNoPK, setting,
+		"setting", setting.Key, expected)
 
 }
 
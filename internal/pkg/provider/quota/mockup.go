@@ -0,0 +1,103 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quota
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"sync"
+)
+
+type MockupQuotaProvider struct {
+	sync.Mutex
+	// usage indexed by organization and resource
+	usage map[string]map[entities.QuotaResource]int64
+}
+
+func NewMockupQuotaProvider() *MockupQuotaProvider {
+	return &MockupQuotaProvider{
+		usage: make(map[string]map[entities.QuotaResource]int64, 0),
+	}
+}
+
+func (m *MockupQuotaProvider) unsafeSet(organizationID string, resource entities.QuotaResource, used int64) {
+	resources, exists := m.usage[organizationID]
+	if !exists {
+		resources = make(map[entities.QuotaResource]int64, 0)
+		m.usage[organizationID] = resources
+	}
+	resources[resource] = used
+}
+
+// Acquire increases the usage of a resource of an organization.
+func (m *MockupQuotaProvider) Acquire(organizationID string, resource entities.QuotaResource, amount int64, limit int64) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	used := m.usage[organizationID][resource]
+	if used+amount > limit {
+		return entities.NewQuotaExceededError(organizationID, resource, limit)
+	}
+	m.unsafeSet(organizationID, resource, used+amount)
+	return nil
+}
+
+// Release decreases the usage of a resource of an organization.
+func (m *MockupQuotaProvider) Release(organizationID string, resource entities.QuotaResource, amount int64) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	used, exists := m.usage[organizationID][resource]
+	if !exists {
+		return nil
+	}
+	used = used - amount
+	if used < 0 {
+		used = 0
+	}
+	m.unsafeSet(organizationID, resource, used)
+	return nil
+}
+
+// SetUsage replaces the usage of a resource.
+func (m *MockupQuotaProvider) SetUsage(usage entities.QuotaUsage) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.unsafeSet(usage.OrganizationId, usage.Resource, usage.Used)
+	return nil
+}
+
+// ListUsage retrieves the usage of the resources of an organization.
+func (m *MockupQuotaProvider) ListUsage(organizationID string) ([]entities.QuotaUsage, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	result := make([]entities.QuotaUsage, 0)
+	for resource, used := range m.usage[organizationID] {
+		result = append(result, *entities.NewQuotaUsage(organizationID, resource, used))
+	}
+	return result, nil
+}
+
+func (m *MockupQuotaProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.usage = make(map[string]map[entities.QuotaResource]int64, 0)
+	return nil
+}
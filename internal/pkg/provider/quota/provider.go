@@ -0,0 +1,37 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quota
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+)
+
+// Provider for the usage of the resources limited by the quotas of the organizations.
+type Provider interface {
+	// Acquire increases the usage of a resource of an organization. It fails with a quota exceeded error if the
+	// usage would be over the limit.
+	Acquire(organizationID string, resource entities.QuotaResource, amount int64, limit int64) derrors.Error
+	// Release decreases the usage of a resource of an organization. The usage is never negative.
+	Release(organizationID string, resource entities.QuotaResource, amount int64) derrors.Error
+	// SetUsage replaces the usage of a resource, used to count the existing resources.
+	SetUsage(usage entities.QuotaUsage) derrors.Error
+	// ListUsage retrieves the usage of the resources of an organization.
+	ListUsage(organizationID string) ([]entities.QuotaUsage, derrors.Error)
+
+	Clear() derrors.Error
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quota
+
+import (
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"sync"
+)
+
+func RunTest(provider Provider) {
+
+	ginkgo.AfterEach(func() {
+		provider.Clear()
+	})
+
+	ginkgo.It("should acquire resources up to the limit", func() {
+		organizationID := entities.GenerateUUID()
+		gomega.Expect(provider.Acquire(organizationID, entities.DeviceQuota, 2, 3)).To(gomega.Succeed())
+		gomega.Expect(provider.Acquire(organizationID, entities.DeviceQuota, 1, 3)).To(gomega.Succeed())
+
+		err := provider.Acquire(organizationID, entities.DeviceQuota, 1, 3)
+		gomega.Expect(entities.IsQuotaExceeded(err)).To(gomega.BeTrue())
+
+		// the usage of other resources is independent
+		gomega.Expect(provider.Acquire(organizationID, entities.ClusterQuota, 1, 3)).To(gomega.Succeed())
+
+		usage, err := provider.ListUsage(organizationID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(usage).To(gomega.ConsistOf(
+			*entities.NewQuotaUsage(organizationID, entities.DeviceQuota, 3),
+			*entities.NewQuotaUsage(organizationID, entities.ClusterQuota, 1)))
+	})
+
+	ginkgo.It("should release resources", func() {
+		organizationID := entities.GenerateUUID()
+		gomega.Expect(provider.Acquire(organizationID, entities.NodeQuota, 1, 1)).To(gomega.Succeed())
+		gomega.Expect(provider.Release(organizationID, entities.NodeQuota, 1)).To(gomega.Succeed())
+		gomega.Expect(provider.Acquire(organizationID, entities.NodeQuota, 1, 1)).To(gomega.Succeed())
+
+		// the usage is never negative
+		gomega.Expect(provider.Release(organizationID, entities.NodeQuota, 5)).To(gomega.Succeed())
+		gomega.Expect(provider.Release(organizationID, entities.UserQuota, 1)).To(gomega.Succeed())
+		usage, err := provider.ListUsage(organizationID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(usage).To(gomega.ConsistOf(*entities.NewQuotaUsage(organizationID, entities.NodeQuota, 0)))
+	})
+
+	ginkgo.It("should replace the usage of a resource", func() {
+		organizationID := entities.GenerateUUID()
+		gomega.Expect(provider.SetUsage(*entities.NewQuotaUsage(organizationID, entities.InstanceQuota, 10))).To(gomega.Succeed())
+
+		err := provider.Acquire(organizationID, entities.InstanceQuota, 1, 10)
+		gomega.Expect(entities.IsQuotaExceeded(err)).To(gomega.BeTrue())
+		gomega.Expect(provider.Acquire(organizationID, entities.InstanceQuota, 1, 11)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should not go over the limit with concurrent requests", func() {
+		organizationID := entities.GenerateUUID()
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		acquired := 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := provider.Acquire(organizationID, entities.DeviceGroupQuota, 1, 4); err == nil {
+					mutex.Lock()
+					acquired++
+					mutex.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		gomega.Expect(acquired).Should(gomega.Equal(4))
+	})
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quota
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/scylladb-utils/pkg/scylladb"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/scylladb/gocqlx"
+	"github.com/scylladb/gocqlx/qb"
+	"sync"
+)
+
+const quotaUsageTable = "QuotaUsage"
+
+var quotaUsageTableColumns = []string{"organization_id", "resource", "used"}
+
+// maxUsageUpdates with the number of times the usage is read again when it is changed concurrently.
+const maxUsageUpdates = 20
+
+// ScyllaQuotaProvider stores the usage of the resources in a row per organization and resource, updated with
+// lightweight transactions so concurrent requests cannot go over the limit.
+type ScyllaQuotaProvider struct {
+	scylladb.ScyllaDB
+	sync.Mutex
+}
+
+func NewScyllaQuotaProvider(address string, port int, keyspace string) *ScyllaQuotaProvider {
+	provider := ScyllaQuotaProvider{
+		ScyllaDB: scylladb.ScyllaDB{
+			Address:  address,
+			Port:     port,
+			Keyspace: keyspace,
+		},
+	}
+	provider.Connect()
+	return &provider
+}
+
+func (s *ScyllaQuotaProvider) createPKMap(organizationID string, resource entities.QuotaResource) map[string]interface{} {
+	return map[string]interface{}{
+		"organization_id": organizationID,
+		"resource":        resource,
+	}
+}
+
+// unsafeGetUsed retrieves the usage of a resource, and whether it is stored.
+func (s *ScyllaQuotaProvider) unsafeGetUsed(organizationID string, resource entities.QuotaResource) (int64, bool, derrors.Error) {
+	pk := s.createPKMap(organizationID, resource)
+	var usage interface{} = &entities.QuotaUsage{}
+	err := s.UnsafeCompositeGet(quotaUsageTable, pk, quotaUsageTableColumns, &usage)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return usage.(*entities.QuotaUsage).Used, true, nil
+}
+
+// unsafeSetUsed replaces the usage of a resource if it has not changed since it was read.
+func (s *ScyllaQuotaProvider) unsafeSetUsed(organizationID string, resource entities.QuotaResource, used int64, stored bool, expected int64) (bool, derrors.Error) {
+	usage := entities.NewQuotaUsage(organizationID, resource, used)
+	var q *gocqlx.Queryx
+	if stored {
+		stmt, names := qb.Update(quotaUsageTable).Set("used").Where(qb.Eq("organization_id"), qb.Eq("resource")).
+			If(qb.EqNamed("used", "expected_used")).ToCql()
+		q = gocqlx.Query(s.Session.Query(stmt), names).BindStructMap(usage, qb.M{"expected_used": expected})
+	} else {
+		stmt, names := qb.Insert(quotaUsageTable).Columns(quotaUsageTableColumns...).Unique().ToCql()
+		q = gocqlx.Query(s.Session.Query(stmt), names).BindStruct(usage)
+	}
+	applied, cqlErr := q.MapScanCAS(make(map[string]interface{}, 0))
+	q.Release()
+	if cqlErr != nil {
+		return false, derrors.AsError(cqlErr, "cannot update quota usage")
+	}
+	return applied, nil
+}
+
+// unsafeAdd adds an amount to the usage of a resource, checking the limit if it is increased.
+func (s *ScyllaQuotaProvider) unsafeAdd(organizationID string, resource entities.QuotaResource, amount int64, limit int64) derrors.Error {
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+	for attempt := 0; attempt < maxUsageUpdates; attempt++ {
+		used, stored, err := s.unsafeGetUsed(organizationID, resource)
+		if err != nil {
+			return err
+		}
+		if amount < 0 && !stored {
+			return nil
+		}
+		next := used + amount
+		if amount > 0 && next > limit {
+			return entities.NewQuotaExceededError(organizationID, resource, limit)
+		}
+		if next < 0 {
+			next = 0
+		}
+		applied, err := s.unsafeSetUsed(organizationID, resource, next, stored, used)
+		if err != nil {
+			return err
+		}
+		if applied {
+			return nil
+		}
+	}
+	return derrors.NewInternalError("cannot update quota usage, too many concurrent updates").WithParams(organizationID, resource)
+}
+
+// Acquire increases the usage of a resource of an organization.
+func (s *ScyllaQuotaProvider) Acquire(organizationID string, resource entities.QuotaResource, amount int64, limit int64) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.unsafeAdd(organizationID, resource, amount, limit)
+}
+
+// Release decreases the usage of a resource of an organization.
+func (s *ScyllaQuotaProvider) Release(organizationID string, resource entities.QuotaResource, amount int64) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.unsafeAdd(organizationID, resource, -amount, 0)
+}
+
+// SetUsage replaces the usage of a resource.
+func (s *ScyllaQuotaProvider) SetUsage(usage entities.QuotaUsage) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+	stmt, names := qb.Insert(quotaUsageTable).Columns(quotaUsageTableColumns...).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindStruct(usage)
+	cqlErr := q.ExecRelease()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot set quota usage")
+	}
+	return nil
+}
+
+// ListUsage retrieves the usage of the resources of an organization.
+func (s *ScyllaQuotaProvider) ListUsage(organizationID string) ([]entities.QuotaUsage, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return nil, err
+	}
+	stmt, names := qb.Select(quotaUsageTable).Columns(quotaUsageTableColumns...).Where(qb.Eq("organization_id")).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		"organization_id": organizationID,
+	})
+
+	usage := make([]entities.QuotaUsage, 0)
+	cqlErr := q.SelectRelease(&usage)
+	if cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot list quota usage")
+	}
+	return usage, nil
+}
+
+func (s *ScyllaQuotaProvider) Clear() derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.UnsafeClear([]string{quotaUsageTable})
+}
@@ -73,6 +73,25 @@ func RunTest(provider Provider) {
 
 	})
 
+	ginkgo.It("Should be able to store the permissions of a role", func() {
+
+		role := &entities.Role{OrganizationId: "org",
+			RoleId:  roleOK,
+			Name:    "name",
+			Created: 1,
+			Permissions: []entities.Permission{
+				{Resource: entities.ResourceCluster, Action: entities.ActionRead, Labels: map[string]string{"env": "dev"}},
+			}}
+
+		err := provider.Add(*role)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		returnedRole, err := provider.Get(roleOK)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(returnedRole.Permissions).Should(gomega.Equal(role.Permissions))
+
+	})
+
 	//	Exists
 	ginkgo.It("Should be able to find role", func() {
 
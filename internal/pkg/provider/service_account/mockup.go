@@ -0,0 +1,217 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service_account
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"sync"
+)
+
+type MockupServiceAccountProvider struct {
+	sync.Mutex
+	// accounts indexed by organization and service account
+	accounts map[string]map[string]entities.ServiceAccount
+	// keys indexed by key
+	keys map[string]entities.APIKey
+}
+
+func NewMockupServiceAccountProvider() *MockupServiceAccountProvider {
+	return &MockupServiceAccountProvider{
+		accounts: make(map[string]map[string]entities.ServiceAccount, 0),
+		keys:     make(map[string]entities.APIKey, 0),
+	}
+}
+
+// Add a new service account.
+func (m *MockupServiceAccountProvider) Add(account entities.ServiceAccount) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	accounts, exists := m.accounts[account.OrganizationId]
+	if !exists {
+		accounts = make(map[string]entities.ServiceAccount, 0)
+		m.accounts[account.OrganizationId] = accounts
+	}
+	if _, exists := accounts[account.ServiceAccountId]; exists {
+		return derrors.NewAlreadyExistsError("service account").WithParams(account.OrganizationId, account.ServiceAccountId)
+	}
+	accounts[account.ServiceAccountId] = account
+	return nil
+}
+
+// Update an existing service account.
+func (m *MockupServiceAccountProvider) Update(account entities.ServiceAccount) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, exists := m.accounts[account.OrganizationId][account.ServiceAccountId]; !exists {
+		return derrors.NewNotFoundError("service account").WithParams(account.OrganizationId, account.ServiceAccountId)
+	}
+	m.accounts[account.OrganizationId][account.ServiceAccountId] = account
+	return nil
+}
+
+// Exists checks if a service account exists.
+func (m *MockupServiceAccountProvider) Exists(organizationID string, serviceAccountID string) (bool, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	_, exists := m.accounts[organizationID][serviceAccountID]
+	return exists, nil
+}
+
+// Get a service account.
+func (m *MockupServiceAccountProvider) Get(organizationID string, serviceAccountID string) (*entities.ServiceAccount, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	account, exists := m.accounts[organizationID][serviceAccountID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("service account").WithParams(organizationID, serviceAccountID)
+	}
+	return &account, nil
+}
+
+// List the service accounts of an organization.
+func (m *MockupServiceAccountProvider) List(organizationID string) ([]entities.ServiceAccount, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	result := make([]entities.ServiceAccount, 0)
+	for _, account := range m.accounts[organizationID] {
+		result = append(result, account)
+	}
+	return result, nil
+}
+
+// Remove a service account.
+func (m *MockupServiceAccountProvider) Remove(organizationID string, serviceAccountID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, exists := m.accounts[organizationID][serviceAccountID]; !exists {
+		return derrors.NewNotFoundError("service account").WithParams(organizationID, serviceAccountID)
+	}
+	delete(m.accounts[organizationID], serviceAccountID)
+	return nil
+}
+
+// AddAPIKey adds a new key.
+func (m *MockupServiceAccountProvider) AddAPIKey(key entities.APIKey) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, exists := m.keys[key.KeyId]; exists {
+		return derrors.NewAlreadyExistsError("api key").WithParams(key.KeyId)
+	}
+	m.keys[key.KeyId] = key
+	return nil
+}
+
+// UpdateAPIKey updates an existing key.
+func (m *MockupServiceAccountProvider) UpdateAPIKey(key entities.APIKey) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, exists := m.keys[key.KeyId]; !exists {
+		return derrors.NewNotFoundError("api key").WithParams(key.KeyId)
+	}
+	m.keys[key.KeyId] = key
+	return nil
+}
+
+// UpdateAPIKeyLastUsed records the last use of a key if it is not revoked.
+func (m *MockupServiceAccountProvider) UpdateAPIKeyLastUsed(key entities.APIKey) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	current, exists := m.keys[key.KeyId]
+	if !exists {
+		return derrors.NewNotFoundError("api key").WithParams(key.KeyId)
+	}
+	if current.Revoked() {
+		return derrors.NewFailedPreconditionError("api key revoked").WithParams(key.KeyId)
+	}
+	current.LastUsed = key.LastUsed
+	m.keys[key.KeyId] = current
+	return nil
+}
+
+// RevokeAPIKey records the revocation time of a key if it is not already revoked.
+func (m *MockupServiceAccountProvider) RevokeAPIKey(key entities.APIKey) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	current, exists := m.keys[key.KeyId]
+	if !exists {
+		return derrors.NewNotFoundError("api key").WithParams(key.KeyId)
+	}
+	if current.Revoked() {
+		return derrors.NewFailedPreconditionError("api key revoked").WithParams(key.KeyId)
+	}
+	current.RevokedAt = key.RevokedAt
+	m.keys[key.KeyId] = current
+	return nil
+}
+
+// GetAPIKey retrieves a key.
+func (m *MockupServiceAccountProvider) GetAPIKey(keyID string) (*entities.APIKey, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	key, exists := m.keys[keyID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("api key").WithParams(keyID)
+	}
+	return &key, nil
+}
+
+// ListAPIKeys retrieves the keys of a service account.
+func (m *MockupServiceAccountProvider) ListAPIKeys(organizationID string, serviceAccountID string) ([]entities.APIKey, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+
+	result := make([]entities.APIKey, 0)
+	for _, key := range m.keys {
+		if key.OrganizationId == organizationID && key.ServiceAccountId == serviceAccountID {
+			result = append(result, key)
+		}
+	}
+	return result, nil
+}
+
+// RemoveAPIKey removes a key.
+func (m *MockupServiceAccountProvider) RemoveAPIKey(keyID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, exists := m.keys[keyID]; !exists {
+		return derrors.NewNotFoundError("api key").WithParams(keyID)
+	}
+	delete(m.keys, keyID)
+	return nil
+}
+
+func (m *MockupServiceAccountProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.accounts = make(map[string]map[string]entities.ServiceAccount, 0)
+	m.keys = make(map[string]entities.APIKey, 0)
+	return nil
+}
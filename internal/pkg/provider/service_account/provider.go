@@ -0,0 +1,55 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service_account
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+)
+
+// Provider for the service accounts of the organizations and their API keys.
+type Provider interface {
+	// Add a new service account.
+	Add(account entities.ServiceAccount) derrors.Error
+	// Update an existing service account.
+	Update(account entities.ServiceAccount) derrors.Error
+	// Exists checks if a service account exists.
+	Exists(organizationID string, serviceAccountID string) (bool, derrors.Error)
+	// Get a service account.
+	Get(organizationID string, serviceAccountID string) (*entities.ServiceAccount, derrors.Error)
+	// List the service accounts of an organization.
+	List(organizationID string) ([]entities.ServiceAccount, derrors.Error)
+	// Remove a service account.
+	Remove(organizationID string, serviceAccountID string) derrors.Error
+
+	// AddAPIKey adds a new key.
+	AddAPIKey(key entities.APIKey) derrors.Error
+	// UpdateAPIKey updates an existing key.
+	UpdateAPIKey(key entities.APIKey) derrors.Error
+	// UpdateAPIKeyLastUsed records the last use of a key if it is not revoked.
+	UpdateAPIKeyLastUsed(key entities.APIKey) derrors.Error
+	// RevokeAPIKey records the revocation time of a key if it is not already revoked.
+	RevokeAPIKey(key entities.APIKey) derrors.Error
+	// GetAPIKey retrieves a key.
+	GetAPIKey(keyID string) (*entities.APIKey, derrors.Error)
+	// ListAPIKeys retrieves the keys of a service account.
+	ListAPIKeys(organizationID string, serviceAccountID string) ([]entities.APIKey, derrors.Error)
+	// RemoveAPIKey removes a key.
+	RemoveAPIKey(keyID string) derrors.Error
+
+	Clear() derrors.Error
+}
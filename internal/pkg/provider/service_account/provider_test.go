@@ -0,0 +1,198 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service_account
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"time"
+)
+
+func RunTest(provider Provider) {
+
+	ginkgo.AfterEach(func() {
+		provider.Clear()
+	})
+
+	ginkgo.Context("service accounts", func() {
+		ginkgo.It("should be able to add a service account", func() {
+			account := entities.NewServiceAccount(entities.GenerateUUID(), "ci", "CI pipeline")
+			err := provider.Add(*account)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			err = provider.Add(*account)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should be able to get a service account", func() {
+			account := entities.NewServiceAccount(entities.GenerateUUID(), "ci", "CI pipeline")
+			gomega.Expect(provider.Add(*account)).To(gomega.Succeed())
+
+			exists, err := provider.Exists(account.OrganizationId, account.ServiceAccountId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(exists).To(gomega.BeTrue())
+
+			retrieved, err := provider.Get(account.OrganizationId, account.ServiceAccountId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(retrieved).To(gomega.Equal(account))
+
+			_, err = provider.Get(account.OrganizationId, entities.GenerateUUID())
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should be able to update a service account", func() {
+			account := entities.NewServiceAccount(entities.GenerateUUID(), "ci", "CI pipeline")
+			gomega.Expect(provider.Add(*account)).To(gomega.Succeed())
+
+			account.Enabled = false
+			account.Description = "disabled"
+			gomega.Expect(provider.Update(*account)).To(gomega.Succeed())
+
+			retrieved, err := provider.Get(account.OrganizationId, account.ServiceAccountId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(retrieved.Enabled).To(gomega.BeFalse())
+			gomega.Expect(retrieved.Description).To(gomega.Equal("disabled"))
+		})
+		ginkgo.It("should be able to list the service accounts of an organization", func() {
+			organizationID := entities.GenerateUUID()
+			gomega.Expect(provider.Add(*entities.NewServiceAccount(organizationID, "a", ""))).To(gomega.Succeed())
+			gomega.Expect(provider.Add(*entities.NewServiceAccount(organizationID, "b", ""))).To(gomega.Succeed())
+			gomega.Expect(provider.Add(*entities.NewServiceAccount(entities.GenerateUUID(), "c", ""))).To(gomega.Succeed())
+
+			accounts, err := provider.List(organizationID)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(accounts)).Should(gomega.Equal(2))
+		})
+		ginkgo.It("should be able to remove a service account", func() {
+			account := entities.NewServiceAccount(entities.GenerateUUID(), "ci", "CI pipeline")
+			gomega.Expect(provider.Add(*account)).To(gomega.Succeed())
+
+			err := provider.Remove(account.OrganizationId, account.ServiceAccountId)
+			gomega.Expect(err).To(gomega.Succeed())
+			exists, err := provider.Exists(account.OrganizationId, account.ServiceAccountId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(exists).To(gomega.BeFalse())
+		})
+	})
+
+	ginkgo.Context("api keys", func() {
+		permissions := []entities.Permission{{Resource: entities.ResourceAppInstance, Action: entities.ActionRead}}
+
+		ginkgo.It("should be able to add and get a key", func() {
+			key, _, err := entities.NewAPIKey(entities.GenerateUUID(), entities.GenerateUUID(), permissions, time.Now().Add(time.Hour).Unix())
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(provider.AddAPIKey(*key)).To(gomega.Succeed())
+			gomega.Expect(provider.AddAPIKey(*key)).NotTo(gomega.Succeed())
+
+			retrieved, err := provider.GetAPIKey(key.KeyId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(retrieved).To(gomega.Equal(key))
+
+			_, err = provider.GetAPIKey(entities.GenerateUUID())
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should be able to update a key", func() {
+			key, _, err := entities.NewAPIKey(entities.GenerateUUID(), entities.GenerateUUID(), permissions, 0)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(provider.AddAPIKey(*key)).To(gomega.Succeed())
+
+			key.RevokedAt = time.Now().Unix()
+			key.LastUsed = time.Now().Unix()
+			gomega.Expect(provider.UpdateAPIKey(*key)).To(gomega.Succeed())
+
+			retrieved, err := provider.GetAPIKey(key.KeyId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(retrieved.RevokedAt).To(gomega.Equal(key.RevokedAt))
+			gomega.Expect(retrieved.LastUsed).To(gomega.Equal(key.LastUsed))
+		})
+		ginkgo.It("should record the last use without overwriting a revocation", func() {
+			key, _, err := entities.NewAPIKey(entities.GenerateUUID(), entities.GenerateUUID(), permissions, 0)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(provider.AddAPIKey(*key)).To(gomega.Succeed())
+
+			used := *key
+			used.LastUsed = time.Now().Unix()
+			gomega.Expect(provider.UpdateAPIKeyLastUsed(used)).To(gomega.Succeed())
+
+			revoked := *key
+			revoked.RevokedAt = time.Now().Unix()
+			revoked.LastUsed = used.LastUsed
+			gomega.Expect(provider.UpdateAPIKey(revoked)).To(gomega.Succeed())
+
+			stale := *key
+			stale.LastUsed = used.LastUsed + 1
+			gomega.Expect(provider.UpdateAPIKeyLastUsed(stale)).NotTo(gomega.Succeed())
+
+			retrieved, err := provider.GetAPIKey(key.KeyId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(retrieved.RevokedAt).To(gomega.Equal(revoked.RevokedAt))
+			gomega.Expect(retrieved.LastUsed).To(gomega.Equal(used.LastUsed))
+		})
+		ginkgo.It("should revoke a key only once", func() {
+			key, _, err := entities.NewAPIKey(entities.GenerateUUID(), entities.GenerateUUID(), permissions, 0)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(provider.AddAPIKey(*key)).To(gomega.Succeed())
+
+			revoked := *key
+			revoked.RevokedAt = time.Now().Unix()
+			gomega.Expect(provider.RevokeAPIKey(revoked)).To(gomega.Succeed())
+
+			again := *key
+			again.RevokedAt = revoked.RevokedAt + 1
+			err = provider.RevokeAPIKey(again)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+			gomega.Expect(err.Type()).To(gomega.Equal(derrors.FailedPrecondition))
+
+			retrieved, err := provider.GetAPIKey(key.KeyId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(retrieved.RevokedAt).To(gomega.Equal(revoked.RevokedAt))
+
+			unknown, _, err := entities.NewAPIKey(entities.GenerateUUID(), entities.GenerateUUID(), permissions, 0)
+			gomega.Expect(err).To(gomega.Succeed())
+			unknown.RevokedAt = time.Now().Unix()
+			err = provider.RevokeAPIKey(*unknown)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+			gomega.Expect(err.Type()).To(gomega.Equal(derrors.NotFound))
+		})
+		ginkgo.It("should be able to list the keys of a service account", func() {
+			organizationID := entities.GenerateUUID()
+			serviceAccountID := entities.GenerateUUID()
+			for i := 0; i < 2; i++ {
+				key, _, err := entities.NewAPIKey(organizationID, serviceAccountID, permissions, 0)
+				gomega.Expect(err).To(gomega.Succeed())
+				gomega.Expect(provider.AddAPIKey(*key)).To(gomega.Succeed())
+			}
+			other, _, err := entities.NewAPIKey(organizationID, entities.GenerateUUID(), permissions, 0)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(provider.AddAPIKey(*other)).To(gomega.Succeed())
+
+			keys, err := provider.ListAPIKeys(organizationID, serviceAccountID)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(keys)).Should(gomega.Equal(2))
+		})
+		ginkgo.It("should be able to remove a key", func() {
+			key, _, err := entities.NewAPIKey(entities.GenerateUUID(), entities.GenerateUUID(), permissions, 0)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(provider.AddAPIKey(*key)).To(gomega.Succeed())
+
+			gomega.Expect(provider.RemoveAPIKey(key.KeyId)).To(gomega.Succeed())
+			_, err = provider.GetAPIKey(key.KeyId)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+			gomega.Expect(provider.RemoveAPIKey(key.KeyId)).NotTo(gomega.Succeed())
+		})
+	})
+}
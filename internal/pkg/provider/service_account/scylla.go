@@ -0,0 +1,331 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service_account
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/scylladb-utils/pkg/scylladb"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+	"github.com/scylladb/gocqlx"
+	"github.com/scylladb/gocqlx/qb"
+	"sync"
+)
+
+const serviceAccountTable = "ServiceAccounts"
+const apiKeyTable = "APIKeys"
+const apiKeyOwnerTable = "APIKey_Owners"
+const apiKeyOwnerTablePK = "key_id"
+
+var serviceAccountTableColumns = []string{"organization_id", "service_account_id", "name", "description", "created", "enabled"}
+var serviceAccountTableColumnsNoPK = []string{"name", "description", "created", "enabled"}
+var apiKeyTableColumns = []string{"organization_id", "service_account_id", "key_id", "hash", "permissions", "created",
+	"expires_at", "revoked_at", "last_used"}
+var apiKeyTableColumnsNoPK = []string{"hash", "permissions", "created", "expires_at", "revoked_at", "last_used"}
+var apiKeyOwnerTableColumns = []string{"key_id", "organization_id", "service_account_id"}
+
+type ScyllaServiceAccountProvider struct {
+	scylladb.ScyllaDB
+	sync.Mutex
+}
+
+func NewScyllaServiceAccountProvider(address string, port int, keyspace string) *ScyllaServiceAccountProvider {
+	provider := ScyllaServiceAccountProvider{
+		ScyllaDB: scylladb.ScyllaDB{
+			Address:  address,
+			Port:     port,
+			Keyspace: keyspace,
+		},
+	}
+	provider.Connect()
+	return &provider
+}
+
+func (s *ScyllaServiceAccountProvider) createPKMap(organizationID string, serviceAccountID string) map[string]interface{} {
+	return map[string]interface{}{
+		"organization_id":    organizationID,
+		"service_account_id": serviceAccountID,
+	}
+}
+
+// Add a new service account.
+func (s *ScyllaServiceAccountProvider) Add(account entities.ServiceAccount) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	pk := s.createPKMap(account.OrganizationId, account.ServiceAccountId)
+	return s.UnsafeCompositeAdd(serviceAccountTable, pk, serviceAccountTableColumns, account)
+}
+
+// Update an existing service account.
+func (s *ScyllaServiceAccountProvider) Update(account entities.ServiceAccount) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	pk := s.createPKMap(account.OrganizationId, account.ServiceAccountId)
+	return s.UnsafeCompositeUpdate(serviceAccountTable, pk, serviceAccountTableColumnsNoPK, account)
+}
+
+// Exists checks if a service account exists.
+func (s *ScyllaServiceAccountProvider) Exists(organizationID string, serviceAccountID string) (bool, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	pk := s.createPKMap(organizationID, serviceAccountID)
+	return s.UnsafeGenericCompositeExist(serviceAccountTable, pk)
+}
+
+// Get a service account.
+func (s *ScyllaServiceAccountProvider) Get(organizationID string, serviceAccountID string) (*entities.ServiceAccount, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	pk := s.createPKMap(organizationID, serviceAccountID)
+	var account interface{} = &entities.ServiceAccount{}
+	err := s.UnsafeCompositeGet(serviceAccountTable, pk, serviceAccountTableColumns, &account)
+	if err != nil {
+		return nil, err
+	}
+	return account.(*entities.ServiceAccount), nil
+}
+
+// List the service accounts of an organization.
+func (s *ScyllaServiceAccountProvider) List(organizationID string) ([]entities.ServiceAccount, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return nil, err
+	}
+
+	stmt, names := qb.Select(serviceAccountTable).Columns(serviceAccountTableColumns...).Where(qb.Eq("organization_id")).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		"organization_id": organizationID,
+	})
+
+	accounts := make([]entities.ServiceAccount, 0)
+	cqlErr := q.SelectRelease(&accounts)
+	if cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot list service accounts")
+	}
+	return accounts, nil
+}
+
+// Remove a service account.
+func (s *ScyllaServiceAccountProvider) Remove(organizationID string, serviceAccountID string) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	pk := s.createPKMap(organizationID, serviceAccountID)
+	return s.UnsafeCompositeRemove(serviceAccountTable, pk)
+}
+
+func (s *ScyllaServiceAccountProvider) createKeyPKMap(organizationID string, serviceAccountID string, keyID string) map[string]interface{} {
+	return map[string]interface{}{
+		"organization_id":    organizationID,
+		"service_account_id": serviceAccountID,
+		"key_id":             keyID,
+	}
+}
+
+// unsafeGetOwner retrieves the organization and service account a key belongs to.
+func (s *ScyllaServiceAccountProvider) unsafeGetOwner(keyID string) (*entities.APIKey, derrors.Error) {
+	var owner interface{} = &entities.APIKey{}
+	err := s.UnsafeGet(apiKeyOwnerTable, apiKeyOwnerTablePK, keyID, apiKeyOwnerTableColumns, &owner)
+	if err != nil {
+		return nil, err
+	}
+	return owner.(*entities.APIKey), nil
+}
+
+// AddAPIKey adds a new key. The key identifier is reserved in the owners table, so a key can be retrieved from the
+// identifier included in the plain key.
+func (s *ScyllaServiceAccountProvider) AddAPIKey(key entities.APIKey) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, names := qb.Insert(apiKeyOwnerTable).Columns(apiKeyOwnerTableColumns...).Unique().ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindStruct(key)
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot add api key")
+	}
+	if !applied {
+		return derrors.NewAlreadyExistsError("api key").WithParams(key.KeyId)
+	}
+
+	pk := s.createKeyPKMap(key.OrganizationId, key.ServiceAccountId, key.KeyId)
+	err := s.UnsafeCompositeAdd(apiKeyTable, pk, apiKeyTableColumns, key)
+	if err != nil {
+		if rErr := s.UnsafeRemove(apiKeyOwnerTable, apiKeyOwnerTablePK, key.KeyId); rErr != nil {
+			log.Error().Str("keyID", key.KeyId).Str("trace", rErr.DebugReport()).Msg("cannot release the api key identifier")
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateAPIKey updates an existing key.
+func (s *ScyllaServiceAccountProvider) UpdateAPIKey(key entities.APIKey) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	pk := s.createKeyPKMap(key.OrganizationId, key.ServiceAccountId, key.KeyId)
+	return s.UnsafeCompositeUpdate(apiKeyTable, pk, apiKeyTableColumnsNoPK, key)
+}
+
+// UpdateAPIKeyLastUsed records the last use of a key. Only the last use is written, and only if the key is not
+// revoked, so a concurrent revocation is never overwritten.
+func (s *ScyllaServiceAccountProvider) UpdateAPIKeyLastUsed(key entities.APIKey) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, names := qb.Update(apiKeyTable).Set("last_used").
+		Where(qb.Eq("organization_id"), qb.Eq("service_account_id"), qb.Eq("key_id")).
+		If(qb.EqNamed("revoked_at", "not_revoked")).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		"organization_id":    key.OrganizationId,
+		"service_account_id": key.ServiceAccountId,
+		"key_id":             key.KeyId,
+		"last_used":          key.LastUsed,
+		"not_revoked":        int64(0),
+	})
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot update the last use of the api key")
+	}
+	if !applied {
+		if _, exists := current["revoked_at"]; !exists {
+			return derrors.NewNotFoundError("api key").WithParams(key.KeyId)
+		}
+		return derrors.NewFailedPreconditionError("api key revoked").WithParams(key.KeyId)
+	}
+	return nil
+}
+
+// RevokeAPIKey records the revocation time of a key. Only the revocation time is written, and only if the key is
+// not already revoked, so concurrent revocations are detected.
+func (s *ScyllaServiceAccountProvider) RevokeAPIKey(key entities.APIKey) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return err
+	}
+
+	stmt, names := qb.Update(apiKeyTable).Set("revoked_at").
+		Where(qb.Eq("organization_id"), qb.Eq("service_account_id"), qb.Eq("key_id")).
+		If(qb.EqNamed("revoked_at", "not_revoked")).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		"organization_id":    key.OrganizationId,
+		"service_account_id": key.ServiceAccountId,
+		"key_id":             key.KeyId,
+		"revoked_at":         key.RevokedAt,
+		"not_revoked":        int64(0),
+	})
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot revoke the api key")
+	}
+	if !applied {
+		if _, exists := current["revoked_at"]; !exists {
+			return derrors.NewNotFoundError("api key").WithParams(key.KeyId)
+		}
+		return derrors.NewFailedPreconditionError("api key revoked").WithParams(key.KeyId)
+	}
+	return nil
+}
+
+// GetAPIKey retrieves a key.
+func (s *ScyllaServiceAccountProvider) GetAPIKey(keyID string) (*entities.APIKey, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	owner, err := s.unsafeGetOwner(keyID)
+	if err != nil {
+		return nil, err
+	}
+	pk := s.createKeyPKMap(owner.OrganizationId, owner.ServiceAccountId, keyID)
+	var key interface{} = &entities.APIKey{}
+	err = s.UnsafeCompositeGet(apiKeyTable, pk, apiKeyTableColumns, &key)
+	if err != nil {
+		return nil, err
+	}
+	return key.(*entities.APIKey), nil
+}
+
+// ListAPIKeys retrieves the keys of a service account.
+func (s *ScyllaServiceAccountProvider) ListAPIKeys(organizationID string, serviceAccountID string) ([]entities.APIKey, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.CheckAndConnect(); err != nil {
+		return nil, err
+	}
+
+	stmt, names := qb.Select(apiKeyTable).Columns(apiKeyTableColumns...).
+		Where(qb.Eq("organization_id"), qb.Eq("service_account_id")).ToCql()
+	q := gocqlx.Query(s.Session.Query(stmt), names).BindMap(qb.M{
+		"organization_id":    organizationID,
+		"service_account_id": serviceAccountID,
+	})
+
+	keys := make([]entities.APIKey, 0)
+	cqlErr := q.SelectRelease(&keys)
+	if cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot list api keys")
+	}
+	return keys, nil
+}
+
+// RemoveAPIKey removes a key.
+func (s *ScyllaServiceAccountProvider) RemoveAPIKey(keyID string) derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	owner, err := s.unsafeGetOwner(keyID)
+	if err != nil {
+		return err
+	}
+	pk := s.createKeyPKMap(owner.OrganizationId, owner.ServiceAccountId, keyID)
+	err = s.UnsafeCompositeRemove(apiKeyTable, pk)
+	if err != nil && err.Type() != derrors.NotFound {
+		return err
+	}
+	return s.UnsafeRemove(apiKeyOwnerTable, apiKeyOwnerTablePK, keyID)
+}
+
+func (s *ScyllaServiceAccountProvider) Clear() derrors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.UnsafeClear([]string{serviceAccountTable, apiKeyTable, apiKeyOwnerTable})
+}
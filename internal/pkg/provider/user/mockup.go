@@ -26,11 +26,14 @@ type MockupUserProvider struct {
 	sync.Mutex
 	// Users indexed by user email.
 	users map[string]entities.User
+	// Memberships indexed by user email and organization identifier.
+	memberships map[string]map[string]entities.Membership
 }
 
 func NewMockupUserProvider() *MockupUserProvider {
 	return &MockupUserProvider{
-		users: make(map[string]entities.User, 0),
+		users:       make(map[string]entities.User, 0),
+		memberships: make(map[string]map[string]entities.Membership, 0),
 	}
 }
 
@@ -57,6 +60,10 @@ func (m *MockupUserProvider) Update(user entities.User) derrors.Error {
 	if !m.unsafeExists(user.Email) {
 		return derrors.NewNotFoundError(user.Email)
 	}
+	if m.users[user.Email].Version != user.Version {
+		return entities.NewVersionConflictError("user", user.Email, user.Version)
+	}
+	user.Version++
 	m.users[user.Email] = user
 	return nil
 }
@@ -94,6 +101,80 @@ func (m *MockupUserProvider) Remove(email string) derrors.Error {
 func (m *MockupUserProvider) Clear() derrors.Error {
 	m.Lock()
 	m.users = make(map[string]entities.User, 0)
+	m.memberships = make(map[string]map[string]entities.Membership, 0)
 	m.Unlock()
 	return nil
 }
+
+func (m *MockupUserProvider) unsafeMembershipExists(email string, organizationID string) bool {
+	_, exists := m.memberships[email][organizationID]
+	return exists
+}
+
+// AddMembership adds the membership of a user in an organization.
+func (m *MockupUserProvider) AddMembership(membership entities.Membership) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if m.unsafeMembershipExists(membership.Email, membership.OrganizationId) {
+		return derrors.NewAlreadyExistsError("membership").WithParams(membership.Email, membership.OrganizationId)
+	}
+	if _, found := m.memberships[membership.Email]; !found {
+		m.memberships[membership.Email] = make(map[string]entities.Membership, 0)
+	}
+	m.memberships[membership.Email][membership.OrganizationId] = membership
+	return nil
+}
+
+// UpdateMembership updates the membership of a user in an organization.
+func (m *MockupUserProvider) UpdateMembership(membership entities.Membership) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if !m.unsafeMembershipExists(membership.Email, membership.OrganizationId) {
+		return derrors.NewNotFoundError("membership").WithParams(membership.Email, membership.OrganizationId)
+	}
+	m.memberships[membership.Email][membership.OrganizationId] = membership
+	return nil
+}
+
+// MembershipExists checks if a user is a member of an organization.
+func (m *MockupUserProvider) MembershipExists(email string, organizationID string) (bool, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.unsafeMembershipExists(email, organizationID), nil
+}
+
+// GetMembership retrieves the membership of a user in an organization.
+func (m *MockupUserProvider) GetMembership(email string, organizationID string) (*entities.Membership, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	membership, exists := m.memberships[email][organizationID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("membership").WithParams(email, organizationID)
+	}
+	return &membership, nil
+}
+
+// ListMemberships retrieves the memberships of a user.
+func (m *MockupUserProvider) ListMemberships(email string) ([]entities.Membership, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.Membership, 0, len(m.memberships[email]))
+	for _, membership := range m.memberships[email] {
+		result = append(result, membership)
+	}
+	return result, nil
+}
+
+// RemoveMembership removes the membership of a user in an organization.
+func (m *MockupUserProvider) RemoveMembership(email string, organizationID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if !m.unsafeMembershipExists(email, organizationID) {
+		return derrors.NewNotFoundError("membership").WithParams(email, organizationID)
+	}
+	delete(m.memberships[email], organizationID)
+	if len(m.memberships[email]) == 0 {
+		delete(m.memberships, email)
+	}
+	return nil
+}
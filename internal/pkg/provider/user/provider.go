@@ -25,7 +25,8 @@ import (
 type Provider interface {
 	// Add a new user to the system.
 	Add(user entities.User) derrors.Error
-	// Update an existing user in the system
+	// Update an existing user in the system. The update fails with a version conflict if the version of the user
+	// does not match the stored one, and increases the stored version otherwise.
 	Update(user entities.User) derrors.Error
 	// Exists checks if a user exists on the system.
 	Exists(email string) (bool, derrors.Error)
@@ -33,6 +34,18 @@ type Provider interface {
 	Get(email string) (*entities.User, derrors.Error)
 	// Remove a user.
 	Remove(email string) derrors.Error
+	// AddMembership adds the membership of a user in an organization.
+	AddMembership(membership entities.Membership) derrors.Error
+	// UpdateMembership updates the membership of a user in an organization.
+	UpdateMembership(membership entities.Membership) derrors.Error
+	// MembershipExists checks if a user is a member of an organization.
+	MembershipExists(email string, organizationID string) (bool, derrors.Error)
+	// GetMembership retrieves the membership of a user in an organization.
+	GetMembership(email string, organizationID string) (*entities.Membership, derrors.Error)
+	// ListMemberships retrieves the memberships of a user.
+	ListMemberships(email string) ([]entities.Membership, derrors.Error)
+	// RemoveMembership removes the membership of a user in an organization.
+	RemoveMembership(email string, organizationID string) derrors.Error
 	// Clear
 	Clear() derrors.Error
 }
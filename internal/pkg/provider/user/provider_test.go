@@ -62,6 +62,26 @@ func RunTest(provider Provider) {
 		err = provider.Update(*user)
 		gomega.Expect(err).To(gomega.Succeed())
 	})
+	ginkgo.It("Should not be able to update a stale user", func() {
+
+		user := &entities.User{OrganizationId: "organization",
+			Email:       email,
+			Name:        "Name",
+			MemberSince: 1}
+
+		err := provider.Add(*user)
+		gomega.Expect(err).To(gomega.Succeed())
+		err = provider.Update(*user)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		user.Name = "Stale"
+		err = provider.Update(*user)
+		gomega.Expect(entities.IsVersionConflict(err)).To(gomega.BeTrue())
+
+		retrieved, err := provider.Get(email)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Version).Should(gomega.Equal(int64(1)))
+	})
 	ginkgo.It("Should not be able to update user", func() {
 
 		user := &entities.User{OrganizationId: "org",
@@ -138,4 +158,60 @@ func RunTest(provider Provider) {
 		gomega.Expect(err).NotTo(gomega.Succeed())
 	})
 
+	// Memberships
+	ginkgo.It("Should be able to add memberships in several organizations", func() {
+
+		for _, organizationID := range []string{"org1", "org2"} {
+			err := provider.AddMembership(*entities.NewMembership(organizationID, email))
+			gomega.Expect(err).To(gomega.Succeed())
+		}
+
+		memberships, err := provider.ListMemberships(email)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(memberships)).Should(gomega.Equal(2))
+
+		exists, err := provider.MembershipExists(email, "org1")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).To(gomega.BeTrue())
+	})
+	ginkgo.It("Should not be able to add a membership twice", func() {
+
+		membership := entities.NewMembership("org1", email)
+		err := provider.AddMembership(*membership)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		err = provider.AddMembership(*membership)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+	ginkgo.It("Should be able to update a membership", func() {
+
+		membership := entities.NewMembership("org1", email)
+		err := provider.AddMembership(*membership)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		membership.RoleIds = []string{"role1"}
+		membership.State = entities.MembershipState_Suspended
+		err = provider.UpdateMembership(*membership)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		retrieved, err := provider.GetMembership(email, "org1")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.RoleIds).Should(gomega.Equal(membership.RoleIds))
+		gomega.Expect(retrieved.State).Should(gomega.Equal(entities.MembershipState_Suspended))
+	})
+	ginkgo.It("Should be able to remove a membership", func() {
+
+		err := provider.AddMembership(*entities.NewMembership("org1", email))
+		gomega.Expect(err).To(gomega.Succeed())
+
+		err = provider.RemoveMembership(email, "org1")
+		gomega.Expect(err).To(gomega.Succeed())
+
+		_, err = provider.GetMembership(email, "org1")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+
+		err = provider.RemoveMembership(email, "org1")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
 }
@@ -21,6 +21,7 @@ import (
 	"github.com/nalej/derrors"
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/versioning"
 	"github.com/rs/zerolog/log"
 	"github.com/scylladb/gocqlx"
 	"github.com/scylladb/gocqlx/qb"
@@ -30,6 +31,7 @@ import (
 // Tables
 const userTable = "users"
 const userPhotoTable = "UserPhotos"
+const membershipTable = "Memberships"
 
 // PKs
 const userTablePK = "email"
@@ -162,7 +164,8 @@ func (sp *ScyllaUserProvider) Add(user entities.User) derrors.Error {
 	}
 
 	// insert a user
-	stmt, names := qb.Insert(userTable).Columns("organization_id", "email", "name", "member_since", "last_name", "title", "phone", "location").ToCql()
+	stmt, names := qb.Insert(userTable).Columns("organization_id", "email", "name", "member_since", "last_name", "title", "phone", "location",
+		"version", "photo_id", "thumbnail_id").ToCql()
 	q := gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(user)
 	cqlErr := q.ExecRelease()
 
@@ -204,19 +207,21 @@ func (sp *ScyllaUserProvider) Update(user entities.User) derrors.Error {
 		return derrors.NewNotFoundError(user.Email)
 	}
 
-	// update a user
-	stmt, names := qb.Update(userTable).Set("organization_id", "name", "member_since", "last_name", "title", "phone", "location").Where(qb.Eq(userTablePK)).ToCql()
-	q := gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(user)
-	cqlErr := q.ExecRelease()
-
-	if cqlErr != nil {
-		return derrors.AsError(cqlErr, "cannot update user")
+	// update a user if nobody else updated it
+	expected := user.Version
+	user.Version++
+	pk := map[string]interface{}{userTablePK: user.Email}
+	columns := []string{"organization_id", "name", "member_since", "last_name", "title", "phone", "location", "version",
+		"photo_id", "thumbnail_id"}
+	err = versioning.ScyllaUpdate(sp.Session, userTable, pk, columns, user, "user", user.Email, expected)
+	if err != nil {
+		return err
 	}
 
 	userPhoto := NewUserPhotoInfo(user.Email, user.PhotoBase64)
-	stmt, names = qb.Update(userPhotoTable).Set("photo_base64").Where(qb.Eq(userPhotoTablePK)).ToCql()
-	q = gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(userPhoto)
-	cqlErr = q.ExecRelease()
+	stmt, names := qb.Update(userPhotoTable).Set("photo_base64").Where(qb.Eq(userPhotoTablePK)).ToCql()
+	q := gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(userPhoto)
+	cqlErr := q.ExecRelease()
 
 	if cqlErr != nil {
 		return derrors.AsError(cqlErr, "cannot update user photo")
@@ -367,5 +372,193 @@ func (sp *ScyllaUserProvider) Clear() derrors.Error {
 		return derrors.AsError(err, "cannot truncate users table")
 	}
 
+	err = sp.Session.Query("TRUNCATE TABLE MEMBERSHIPS").Exec()
+	if err != nil {
+		log.Error().Str("trace", conversions.ToDerror(err).DebugReport()).Msg("failed to truncate the memberships table")
+		return derrors.AsError(err, "cannot truncate memberships table")
+	}
+
+	return nil
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (sp *ScyllaUserProvider) unsafeMembershipExists(email string, organizationID string) (bool, derrors.Error) {
+
+	var returnedEmail string
+	stmt, names := qb.Select(membershipTable).Columns("email").Where(qb.Eq("email"), qb.Eq("organization_id")).ToCql()
+	q := gocqlx.Query(sp.Session.Query(stmt), names).BindMap(qb.M{
+		"email":           email,
+		"organization_id": organizationID,
+	})
+
+	err := q.GetRelease(&returnedEmail)
+	if err != nil {
+		if err.Error() == rowNotFound {
+			return false, nil
+		} else {
+			return false, derrors.AsError(err, "cannot determinate if membership exists")
+		}
+	}
+	return true, nil
+}
+
+// AddMembership adds the membership of a user in an organization.
+func (sp *ScyllaUserProvider) AddMembership(membership entities.Membership) derrors.Error {
+
+	sp.Lock()
+	defer sp.Unlock()
+
+	// check connection
+	if err := sp.checkAndConnect(); err != nil {
+		return err
+	}
+
+	exists, err := sp.unsafeMembershipExists(membership.Email, membership.OrganizationId)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return derrors.NewAlreadyExistsError("membership").WithParams(membership.Email, membership.OrganizationId)
+	}
+
+	stmt, names := qb.Insert(membershipTable).Columns("email", "organization_id", "role_ids", "member_since", "state").ToCql()
+	q := gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(membership)
+	cqlErr := q.ExecRelease()
+
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot add membership")
+	}
+
+	return nil
+}
+
+// UpdateMembership updates the membership of a user in an organization.
+func (sp *ScyllaUserProvider) UpdateMembership(membership entities.Membership) derrors.Error {
+
+	sp.Lock()
+	defer sp.Unlock()
+
+	// check connection
+	if err := sp.checkAndConnect(); err != nil {
+		return err
+	}
+
+	exists, err := sp.unsafeMembershipExists(membership.Email, membership.OrganizationId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("membership").WithParams(membership.Email, membership.OrganizationId)
+	}
+
+	stmt, names := qb.Update(membershipTable).Set("role_ids", "member_since", "state").
+		Where(qb.Eq("email"), qb.Eq("organization_id")).ToCql()
+	q := gocqlx.Query(sp.Session.Query(stmt), names).BindStruct(membership)
+	cqlErr := q.ExecRelease()
+
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot update membership")
+	}
+
+	return nil
+}
+
+// MembershipExists checks if a user is a member of an organization.
+func (sp *ScyllaUserProvider) MembershipExists(email string, organizationID string) (bool, derrors.Error) {
+
+	sp.Lock()
+	defer sp.Unlock()
+
+	// check connection
+	if err := sp.checkAndConnect(); err != nil {
+		return false, err
+	}
+
+	return sp.unsafeMembershipExists(email, organizationID)
+}
+
+// GetMembership retrieves the membership of a user in an organization.
+func (sp *ScyllaUserProvider) GetMembership(email string, organizationID string) (*entities.Membership, derrors.Error) {
+
+	sp.Lock()
+	defer sp.Unlock()
+
+	// check connection
+	if err := sp.checkAndConnect(); err != nil {
+		return nil, err
+	}
+
+	var membership entities.Membership
+	stmt, names := qb.Select(membershipTable).Where(qb.Eq("email"), qb.Eq("organization_id")).ToCql()
+	q := gocqlx.Query(sp.Session.Query(stmt), names).BindMap(qb.M{
+		"email":           email,
+		"organization_id": organizationID,
+	})
+
+	err := q.GetRelease(&membership)
+	if err != nil {
+		if err.Error() == rowNotFound {
+			return nil, derrors.NewNotFoundError("membership").WithParams(email, organizationID)
+		} else {
+			return nil, derrors.AsError(err, "cannot get membership")
+		}
+	}
+
+	return &membership, nil
+}
+
+// ListMemberships retrieves the memberships of a user.
+func (sp *ScyllaUserProvider) ListMemberships(email string) ([]entities.Membership, derrors.Error) {
+
+	sp.Lock()
+	defer sp.Unlock()
+
+	// check connection
+	if err := sp.checkAndConnect(); err != nil {
+		return nil, err
+	}
+
+	stmt, names := qb.Select(membershipTable).Where(qb.Eq("email")).ToCql()
+	q := gocqlx.Query(sp.Session.Query(stmt), names).BindMap(qb.M{
+		"email": email,
+	})
+
+	memberships := make([]entities.Membership, 0)
+	cqlErr := q.SelectRelease(&memberships)
+
+	if cqlErr != nil {
+		return nil, derrors.AsError(cqlErr, "cannot list memberships")
+	}
+
+	return memberships, nil
+}
+
+// RemoveMembership removes the membership of a user in an organization.
+func (sp *ScyllaUserProvider) RemoveMembership(email string, organizationID string) derrors.Error {
+
+	sp.Lock()
+	defer sp.Unlock()
+
+	// check connection
+	if err := sp.checkAndConnect(); err != nil {
+		return err
+	}
+
+	exists, err := sp.unsafeMembershipExists(email, organizationID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("membership").WithParams(email, organizationID)
+	}
+
+	stmt, _ := qb.Delete(membershipTable).Where(qb.Eq("email"), qb.Eq("organization_id")).ToCql()
+	cqlErr := sp.Session.Query(stmt, email, organizationID).Exec()
+
+	if cqlErr != nil {
+		return derrors.AsError(cqlErr, "cannot remove membership")
+	}
+
 	return nil
 }
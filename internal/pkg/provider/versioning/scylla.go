@@ -0,0 +1,80 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package versioning contains the conditional updates used by the providers to implement optimistic concurrency.
+package versioning
+
+import (
+	"github.com/gocql/gocql"
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/scylladb/gocqlx"
+	"github.com/scylladb/gocqlx/qb"
+)
+
+// VersionColumn with the name of the column storing the version of an entity.
+const VersionColumn = "version"
+
+const expectedVersion = "expected_version"
+
+// ScyllaUpdate updates the columns of a row with a lightweight transaction that is only applied if the stored version
+// matches the expected one. The entity must contain the new version. Rows stored before the version column was
+// added have no version and are considered to be on version 0.
+func ScyllaUpdate(session *gocql.Session, table string, pk map[string]interface{}, columns []string,
+	entity interface{}, entityName string, entityID string, expected int64) derrors.Error {
+
+	where := make([]qb.Cmp, 0, len(pk))
+	for column := range pk {
+		where = append(where, qb.Eq(column))
+	}
+	stmt, names := qb.Update(table).Set(columns...).Where(where...).
+		If(qb.EqNamed(VersionColumn, expectedVersion)).ToCql()
+
+	applied, current, err := scyllaCASUpdate(session, stmt, names, pk, entity, expected)
+	if err != nil {
+		return err
+	}
+	if !applied && expected == 0 {
+		// legacy rows without a version are compared against null, scanned as nil or 0 depending on the driver
+		if stored, found := current[VersionColumn]; found && (stored == nil || stored == int64(0)) {
+			applied, _, err = scyllaCASUpdate(session, stmt, names, pk, entity, nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if !applied {
+		return entities.NewVersionConflictError(entityName, entityID, expected)
+	}
+	return nil
+}
+
+func scyllaCASUpdate(session *gocql.Session, stmt string, names []string, pk map[string]interface{},
+	entity interface{}, expected interface{}) (bool, map[string]interface{}, derrors.Error) {
+
+	values := qb.M{expectedVersion: expected}
+	for column, value := range pk {
+		values[column] = value
+	}
+	q := gocqlx.Query(session.Query(stmt), names).BindStructMap(entity, values)
+	current := make(map[string]interface{}, 0)
+	applied, cqlErr := q.MapScanCAS(current)
+	q.Release()
+	if cqlErr != nil {
+		return false, nil, derrors.AsError(cqlErr, "cannot update versioned entity")
+	}
+	return applied, current, nil
+}
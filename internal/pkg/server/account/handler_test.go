@@ -24,6 +24,10 @@ import (
 	"github.com/nalej/grpc-utils/pkg/test"
 	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/provider/account"
+	"github.com/nalej/system-model/internal/pkg/provider/name_reservation"
+	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/project"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
 	"google.golang.org/grpc"
@@ -51,6 +55,9 @@ var _ = ginkgo.Describe("Account service", func() {
 
 	// Providers
 	var accountProvider account.Provider
+	var organizationProvider organization.Provider
+	var projectProvider project.Provider
+	var manager Manager
 
 	ginkgo.BeforeSuite(func() {
 		listener = test.GetDefaultListener()
@@ -58,7 +65,9 @@ var _ = ginkgo.Describe("Account service", func() {
 
 		// Register the service
 		accountProvider = account.NewMockupAccountProvider()
-		manager := NewManager(accountProvider)
+		organizationProvider = organization.NewMockupOrganizationProvider()
+		projectProvider = project.NewMockupProjectProvider()
+		manager = NewManager(accountProvider, name_reservation.NewMockupNameReservationProvider(), organizationProvider, projectProvider)
 		handler := NewHandler(manager)
 		grpc_account_go.RegisterAccountsServer(server, handler)
 
@@ -226,4 +235,173 @@ var _ = ginkgo.Describe("Account service", func() {
 
 		})
 	})
+
+	ginkgo.Context("linking organizations", func() {
+		ginkgo.It("should be able to link an organization to an account", func() {
+			account, err := client.AddAccount(context.Background(), CreateAddAccountRequest())
+			gomega.Expect(err).To(gomega.Succeed())
+			org := testhelpers.AddOrganization(organizationProvider)
+
+			dErr := manager.LinkOrganization(account.AccountId, org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+
+			list, dErr := manager.ListOrganizations(account.AccountId)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			gomega.Expect(list).To(gomega.ConsistOf(org.ID))
+
+			owner, dErr := manager.GetOrganizationAccount(org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			gomega.Expect(owner).To(gomega.Equal(account.AccountId))
+		})
+		ginkgo.It("should not be able to link a non existing organization", func() {
+			account, err := client.AddAccount(context.Background(), CreateAddAccountRequest())
+			gomega.Expect(err).To(gomega.Succeed())
+
+			dErr := manager.LinkOrganization(account.AccountId, entities.GenerateUUID())
+			gomega.Expect(dErr).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should not be able to link an organization to two accounts", func() {
+			first, err := client.AddAccount(context.Background(), CreateAddAccountRequest())
+			gomega.Expect(err).To(gomega.Succeed())
+			second, err := client.AddAccount(context.Background(), CreateAddAccountRequest())
+			gomega.Expect(err).To(gomega.Succeed())
+			org := testhelpers.AddOrganization(organizationProvider)
+
+			dErr := manager.LinkOrganization(first.AccountId, org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			dErr = manager.LinkOrganization(second.AccountId, org.ID)
+			gomega.Expect(dErr).NotTo(gomega.Succeed())
+
+			owner, dErr := manager.GetOrganizationAccount(org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			gomega.Expect(owner).To(gomega.Equal(first.AccountId))
+		})
+		ginkgo.It("should be able to link an organization to another account after unlinking it", func() {
+			first, err := client.AddAccount(context.Background(), CreateAddAccountRequest())
+			gomega.Expect(err).To(gomega.Succeed())
+			second, err := client.AddAccount(context.Background(), CreateAddAccountRequest())
+			gomega.Expect(err).To(gomega.Succeed())
+			org := testhelpers.AddOrganization(organizationProvider)
+
+			dErr := manager.LinkOrganization(first.AccountId, org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			dErr = manager.UnlinkOrganization(first.AccountId, org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+
+			_, dErr = manager.GetOrganizationAccount(org.ID)
+			gomega.Expect(dErr).NotTo(gomega.Succeed())
+
+			dErr = manager.LinkOrganization(second.AccountId, org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+		})
+	})
+
+	ginkgo.Context("account lifecycle", func() {
+		addProject := func(accountID string, state entities.ProjectState) *entities.Project {
+			toAdd := &entities.Project{ProjectId: entities.GenerateUUID(), OwnerAccountId: accountID,
+				Name: fmt.Sprintf("Project-%s", entities.GenerateUUID()), State: state}
+			if state != entities.ProjectState_Active {
+				toAdd.StateInfo = "test"
+			}
+			err := projectProvider.Add(*toAdd)
+			gomega.Expect(err).To(gomega.Succeed())
+			return toAdd
+		}
+
+		ginkgo.It("should suspend the active projects with the account and activate them again", func() {
+			account, err := client.AddAccount(context.Background(), CreateAddAccountRequest())
+			gomega.Expect(err).To(gomega.Succeed())
+			active := addProject(account.AccountId, entities.ProjectState_Active)
+			deactivated := addProject(account.AccountId, entities.ProjectState_Deactivated)
+
+			dErr := manager.ChangeAccountState(account.AccountId, entities.AccountState_Suspended, "pending payment")
+			gomega.Expect(dErr).To(gomega.Succeed())
+			retrieved, dErr := accountProvider.Get(account.AccountId)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			gomega.Expect(retrieved.State).Should(gomega.Equal(entities.AccountState_Suspended))
+			gomega.Expect(retrieved.StateInfo).Should(gomega.Equal("pending payment"))
+			suspended, dErr := projectProvider.Get(account.AccountId, active.ProjectId)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			gomega.Expect(suspended.State).Should(gomega.Equal(entities.ProjectState_Suspended))
+
+			dErr = manager.ChangeAccountState(account.AccountId, entities.AccountState_Active, "")
+			gomega.Expect(dErr).To(gomega.Succeed())
+			activated, dErr := projectProvider.Get(account.AccountId, active.ProjectId)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			gomega.Expect(activated.State).Should(gomega.Equal(entities.ProjectState_Active))
+			notActivated, dErr := projectProvider.Get(account.AccountId, deactivated.ProjectId)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			gomega.Expect(notActivated.State).Should(gomega.Equal(entities.ProjectState_Deactivated))
+		})
+		ginkgo.It("should not be able to suspend an account without a reason", func() {
+			account, err := client.AddAccount(context.Background(), CreateAddAccountRequest())
+			gomega.Expect(err).To(gomega.Succeed())
+
+			dErr := manager.ChangeAccountState(account.AccountId, entities.AccountState_Suspended, "")
+			gomega.Expect(dErr).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should keep the state of a suspended account updated as deactivated", func() {
+			account, err := client.AddAccount(context.Background(), CreateAddAccountRequest())
+			gomega.Expect(err).To(gomega.Succeed())
+			dErr := manager.ChangeAccountState(account.AccountId, entities.AccountState_Suspended, "pending payment")
+			gomega.Expect(dErr).To(gomega.Succeed())
+
+			_, err = client.UpdateAccount(context.Background(), &grpc_account_go.UpdateAccountRequest{
+				AccountId:   account.AccountId,
+				UpdateState: true,
+				State:       grpc_account_go.AccountState_DEACTIVATED,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+			retrieved, dErr := accountProvider.Get(account.AccountId)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			gomega.Expect(retrieved.State).Should(gomega.Equal(entities.AccountState_Suspended))
+			gomega.Expect(retrieved.StateInfo).Should(gomega.Equal("pending payment"))
+		})
+		ginkgo.It("should not be able to suspend an account pending deletion", func() {
+			account, err := client.AddAccount(context.Background(), CreateAddAccountRequest())
+			gomega.Expect(err).To(gomega.Succeed())
+
+			dErr := manager.ChangeAccountState(account.AccountId, entities.AccountState_PendingDeletion, "closed")
+			gomega.Expect(dErr).To(gomega.Succeed())
+			dErr = manager.ChangeAccountState(account.AccountId, entities.AccountState_Suspended, "pending payment")
+			gomega.Expect(dErr).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should be able to list the accounts in a state", func() {
+			account, err := client.AddAccount(context.Background(), CreateAddAccountRequest())
+			gomega.Expect(err).To(gomega.Succeed())
+			dErr := manager.ChangeAccountState(account.AccountId, entities.AccountState_Suspended, "pending payment")
+			gomega.Expect(dErr).To(gomega.Succeed())
+
+			suspended, dErr := manager.ListAccounts(entities.AccountState_Suspended)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			gomega.Expect(suspended).NotTo(gomega.BeEmpty())
+			for _, acc := range suspended {
+				gomega.Expect(acc.State).Should(gomega.Equal(entities.AccountState_Suspended))
+			}
+		})
+		ginkgo.It("should not be able to remove an account with projects", func() {
+			account, err := client.AddAccount(context.Background(), CreateAddAccountRequest())
+			gomega.Expect(err).To(gomega.Succeed())
+			addProject(account.AccountId, entities.ProjectState_Active)
+
+			dErr := manager.RemoveAccount(account.AccountId)
+			gomega.Expect(dErr).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should be able to remove an account without projects", func() {
+			toAdd := CreateAddAccountRequest()
+			account, err := client.AddAccount(context.Background(), toAdd)
+			gomega.Expect(err).To(gomega.Succeed())
+			org := testhelpers.AddOrganization(organizationProvider)
+			dErr := manager.LinkOrganization(account.AccountId, org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+
+			dErr = manager.RemoveAccount(account.AccountId)
+			gomega.Expect(dErr).To(gomega.Succeed())
+
+			_, dErr = manager.GetOrganizationAccount(org.ID)
+			gomega.Expect(dErr).NotTo(gomega.Succeed())
+			_, err = client.AddAccount(context.Background(), toAdd)
+			gomega.Expect(err).To(gomega.Succeed())
+		})
+	})
 })
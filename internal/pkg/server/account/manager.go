@@ -21,15 +21,26 @@ import (
 	"github.com/nalej/grpc-account-go"
 	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/provider/account"
+	"github.com/nalej/system-model/internal/pkg/provider/name_reservation"
+	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/project"
+	"github.com/rs/zerolog/log"
 )
 
 type Manager struct {
 	AccountProvider account.Provider
+	NameProvider    name_reservation.Provider
+	OrgProvider     organization.Provider
+	ProjectProvider project.Provider
 }
 
-func NewManager(accProvider account.Provider) Manager {
+func NewManager(accProvider account.Provider, nameProvider name_reservation.Provider, orgProvider organization.Provider,
+	proProvider project.Provider) Manager {
 	return Manager{
 		AccountProvider: accProvider,
+		NameProvider:    nameProvider,
+		OrgProvider:     orgProvider,
+		ProjectProvider: proProvider,
 	}
 }
 
@@ -46,8 +57,18 @@ func (m *Manager) AddAccount(request *grpc_account_go.AddAccountRequest) (*entit
 
 	// add the account
 	toAdd := entities.NewAccountFromGRPC(request)
+	err = m.NameProvider.Reserve(*entities.NewNameReservation(entities.AccountNameScope, toAdd.Name, toAdd.AccountId))
+	if err != nil {
+		if err.Type() == derrors.AlreadyExists {
+			return nil, derrors.NewInvalidArgumentError("Account Name already exists").WithParams(request.Name)
+		}
+		return nil, err
+	}
 	err = m.AccountProvider.Add(*toAdd)
 	if err != nil {
+		if rErr := m.NameProvider.Release(entities.AccountNameScope, toAdd.Name, toAdd.AccountId); rErr != nil {
+			log.Warn().Str("name", toAdd.Name).Str("trace", rErr.DebugReport()).Msg("cannot release account name")
+		}
 		return nil, err
 	}
 
@@ -64,10 +85,27 @@ func (m *Manager) GetAccount(request *grpc_account_go.AccountId) (*entities.Acco
 	return account, nil
 }
 
-func (m *Manager) ListAccounts() ([]entities.Account, derrors.Error) {
-
-	return m.AccountProvider.List()
+// ListAccounts retrieves the accounts of the system. If states are received, only the accounts in those states are
+// returned.
+func (m *Manager) ListAccounts(states ...entities.AccountState) ([]entities.Account, derrors.Error) {
 
+	accounts, err := m.AccountProvider.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return accounts, nil
+	}
+	filtered := make([]entities.Account, 0)
+	for _, acc := range accounts {
+		for _, state := range states {
+			if acc.State == state {
+				filtered = append(filtered, acc)
+				break
+			}
+		}
+	}
+	return filtered, nil
 }
 
 func (m *Manager) UpdateAccount(request *grpc_account_go.UpdateAccountRequest) derrors.Error {
@@ -76,8 +114,21 @@ func (m *Manager) UpdateAccount(request *grpc_account_go.UpdateAccountRequest) d
 	if err != nil {
 		return err
 	}
+	oldName := oldAccount.Name
+	oldState := oldAccount.State
+	if request.UpdateState {
+		reason := oldAccount.StateInfo
+		if request.UpdateStateInfo {
+			reason = request.StateInfo
+		}
+		err = entities.ValidateAccountStateTransition(oldState, entities.AccountStateFromGRPCUpdate(oldState, request.State), reason)
+		if err != nil {
+			return err
+		}
+	}
+	renamed := request.UpdateName && request.Name != oldName
 	// if the name is being udpated, we need to confirm there is no other account with this name
-	if request.UpdateName {
+	if renamed {
 		exists, err := m.AccountProvider.ExistsByName(request.Name)
 		if err != nil {
 			return err
@@ -85,13 +136,142 @@ func (m *Manager) UpdateAccount(request *grpc_account_go.UpdateAccountRequest) d
 		if exists {
 			return derrors.NewInvalidArgumentError("Account Name already exists").WithParams(request.Name)
 		}
+		err = m.NameProvider.Rename(entities.AccountNameScope, oldName, request.Name, oldAccount.AccountId)
+		if err != nil {
+			if err.Type() == derrors.AlreadyExists {
+				return derrors.NewInvalidArgumentError("Account Name already exists").WithParams(request.Name)
+			}
+			return err
+		}
 	}
 	oldAccount.ApplyUpdate(request)
 
-	return m.AccountProvider.Update(*oldAccount)
+	err = m.AccountProvider.Update(*oldAccount)
+	if err != nil {
+		if renamed {
+			if rErr := m.NameProvider.Rename(entities.AccountNameScope, request.Name, oldName, oldAccount.AccountId); rErr != nil {
+				log.Warn().Str("name", request.Name).Str("trace", rErr.DebugReport()).Msg("cannot restore account name")
+			}
+		}
+		return err
+	}
+	return m.cascadeAccountState(oldAccount.AccountId, oldState, oldAccount.State)
 
 }
 
+// ChangeAccountState changes the state of an account, recording the reason in its state info. The projects of the
+// account are suspended when the account stops being active, and activated again when the account is activated.
+func (m *Manager) ChangeAccountState(accountID string, state entities.AccountState, reason string) derrors.Error {
+	retrieved, err := m.AccountProvider.Get(accountID)
+	if err != nil {
+		return err
+	}
+	oldState := retrieved.State
+	err = entities.ValidateAccountStateTransition(oldState, state, reason)
+	if err != nil {
+		return err
+	}
+	retrieved.State = state
+	retrieved.StateInfo = reason
+	err = m.AccountProvider.Update(*retrieved)
+	if err != nil {
+		return err
+	}
+	return m.cascadeAccountState(accountID, oldState, state)
+}
+
+// cascadeAccountState propagates a change of the state of an account to its projects.
+func (m *Manager) cascadeAccountState(accountID string, oldState entities.AccountState, newState entities.AccountState) derrors.Error {
+	wasActive := oldState == entities.AccountState_Active
+	isActive := newState == entities.AccountState_Active
+	if wasActive == isActive {
+		return nil
+	}
+	projects, err := m.ProjectProvider.ListAccountProjects(accountID)
+	if err != nil {
+		return err
+	}
+	for _, toUpdate := range projects {
+		if isActive {
+			// only the projects suspended with the account are activated again
+			if toUpdate.State != entities.ProjectState_Suspended || toUpdate.StateInfo != entities.InactiveAccountStateInfo {
+				continue
+			}
+			toUpdate.State = entities.ProjectState_Active
+			toUpdate.StateInfo = ""
+		} else {
+			if toUpdate.State != entities.ProjectState_Active {
+				continue
+			}
+			toUpdate.State = entities.ProjectState_Suspended
+			toUpdate.StateInfo = entities.InactiveAccountStateInfo
+		}
+		err = m.ProjectProvider.Update(toUpdate)
+		if err != nil {
+			return err
+		}
+		log.Debug().Str("accountId", accountID).Str("projectId", toUpdate.ProjectId).
+			Str("state", entities.ProjectStateToString[toUpdate.State]).Msg("project state changed with its account")
+	}
+	return nil
+}
+
+// RemoveAccount removes an account without projects, unlinking its organizations.
+func (m *Manager) RemoveAccount(accountID string) derrors.Error {
+	toRemove, err := m.AccountProvider.Get(accountID)
+	if err != nil {
+		return err
+	}
+	projects, err := m.ProjectProvider.ListAccountProjects(accountID)
+	if err != nil {
+		return err
+	}
+	if len(projects) > 0 {
+		return derrors.NewFailedPreconditionError("account has projects").WithParams(accountID, len(projects))
+	}
+	organizationIDs, err := m.AccountProvider.ListOrganizations(accountID)
+	if err != nil {
+		return err
+	}
+	for _, organizationID := range organizationIDs {
+		err = m.UnlinkOrganization(accountID, organizationID)
+		if err != nil {
+			return err
+		}
+	}
+	err = m.AccountProvider.Remove(accountID)
+	if err != nil {
+		return err
+	}
+	return m.NameProvider.Release(entities.AccountNameScope, toRemove.Name, toRemove.AccountId)
+}
+
+// ReserveAccountNames reserves the names of the existing accounts, returning the names used by more than one
+// account. It can be run several times.
+func (m *Manager) ReserveAccountNames() ([]entities.NameConflict, derrors.Error) {
+	accounts, err := m.AccountProvider.List()
+	if err != nil {
+		return nil, err
+	}
+	conflicts := make([]entities.NameConflict, 0)
+	for _, account := range accounts {
+		err := m.NameProvider.Reserve(*entities.NewNameReservation(entities.AccountNameScope, account.Name, account.AccountId))
+		if err == nil {
+			continue
+		}
+		if err.Type() != derrors.AlreadyExists {
+			return nil, err
+		}
+		reservation, err := m.NameProvider.Get(entities.AccountNameScope, account.Name)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, entities.NameConflict{Scope: entities.AccountNameScope, Name: account.Name,
+			OwnerId: account.AccountId, ReservedBy: reservation.OwnerId})
+	}
+	return conflicts, nil
+}
+
 func (m *Manager) UpdateAccountBillingInfo(request *grpc_account_go.UpdateAccountBillingInfoRequest) derrors.Error {
 
 	oldAccount, err := m.AccountProvider.Get(request.AccountId)
@@ -102,3 +282,71 @@ func (m *Manager) UpdateAccountBillingInfo(request *grpc_account_go.UpdateAccoun
 
 	return m.AccountProvider.Update(*oldAccount)
 }
+
+// LinkOrganization links an organization to an account. An organization can only be linked to one account.
+func (m *Manager) LinkOrganization(accountID string, organizationID string) derrors.Error {
+	exists, err := m.AccountProvider.Exists(accountID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("account").WithParams(accountID)
+	}
+	exists, err = m.OrgProvider.Exists(organizationID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("organization").WithParams(organizationID)
+	}
+
+	// the reservation of the organization identifier guarantees that it is linked to one account at most
+	err = m.NameProvider.Reserve(*entities.NewNameReservation(entities.AccountOrganizationScope, organizationID, accountID))
+	if err != nil {
+		if err.Type() != derrors.AlreadyExists {
+			return err
+		}
+		owner, gErr := m.GetOrganizationAccount(organizationID)
+		if gErr != nil {
+			return gErr
+		}
+		if owner != accountID {
+			return derrors.NewFailedPreconditionError("organization is linked to another account").WithParams(organizationID, owner)
+		}
+		return derrors.NewAlreadyExistsError("organization is already linked to the account").WithParams(accountID, organizationID)
+	}
+	err = m.AccountProvider.AddOrganization(accountID, organizationID)
+	if err != nil {
+		if rErr := m.NameProvider.Release(entities.AccountOrganizationScope, organizationID, accountID); rErr != nil {
+			log.Warn().Str("organizationID", organizationID).Str("trace", rErr.DebugReport()).Msg("cannot release account organization")
+		}
+		return err
+	}
+	return nil
+}
+
+// UnlinkOrganization removes the link between an account and an organization.
+func (m *Manager) UnlinkOrganization(accountID string, organizationID string) derrors.Error {
+	err := m.AccountProvider.RemoveOrganization(accountID, organizationID)
+	if err != nil {
+		return err
+	}
+	return m.NameProvider.Release(entities.AccountOrganizationScope, organizationID, accountID)
+}
+
+// ListOrganizations returns the identifiers of the organizations linked to an account.
+func (m *Manager) ListOrganizations(accountID string) ([]string, derrors.Error) {
+	return m.AccountProvider.ListOrganizations(accountID)
+}
+
+// GetOrganizationAccount returns the identifier of the account an organization is linked to.
+func (m *Manager) GetOrganizationAccount(organizationID string) (string, derrors.Error) {
+	reservation, err := m.NameProvider.Get(entities.AccountOrganizationScope, organizationID)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return "", derrors.NewNotFoundError("account of organization").WithParams(organizationID)
+		}
+		return "", err
+	}
+	return reservation.OwnerId, nil
+}
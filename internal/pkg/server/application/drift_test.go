@@ -0,0 +1,126 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package application
+
+import (
+	"github.com/nalej/grpc-application-go"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	devProvider "github.com/nalej/system-model/internal/pkg/provider/device"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Application instance drift", func() {
+
+	const numServices = 2
+
+	var manager Manager
+	var targetOrganization *entities.Organization
+	var targetDescriptor *entities.AppDescriptor
+	var targetInstance *entities.AppInstance
+	var instanceID *grpc_application_go.AppInstanceId
+
+	addGroupInstances := func(numInstances int32) {
+		_, err := manager.AddServiceGroupInstances(&grpc_application_go.AddServiceGroupInstancesRequest{
+			OrganizationId:  targetOrganization.ID,
+			AppDescriptorId: targetDescriptor.AppDescriptorId,
+			AppInstanceId:   targetInstance.AppInstanceId,
+			ServiceGroupId:  targetDescriptor.Groups[0].ServiceGroupId,
+			NumInstances:    numInstances,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	}
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider := orgProvider.NewMockupOrganizationProvider()
+		deviceProvider := devProvider.NewMockupDeviceProvider()
+		manager = NewManager(organizationProvider, appProvider.NewMockupApplicationProvider(),
+			deviceProvider, "nalej.cluster.local", newIPAMManager(organizationProvider),
+			testhelpers.NewQuotaManager(organizationProvider))
+		targetOrganization = testhelpers.AddOrganization(organizationProvider)
+		testhelpers.CreateDeviceGroup(deviceProvider, targetOrganization.ID, "dg1")
+		testhelpers.CreateDeviceGroup(deviceProvider, targetOrganization.ID, "dg2")
+		testhelpers.CreateDeviceGroup(deviceProvider, targetOrganization.ID, "dg3")
+
+		descriptor, err := manager.AddAppDescriptor(generateAddAppDescriptor(targetOrganization.ID, numServices))
+		gomega.Expect(err).To(gomega.Succeed())
+		targetDescriptor = descriptor
+
+		instance, err := manager.AddAppInstance(generateAddAppInstance(targetOrganization.ID, descriptor.AppDescriptorId))
+		gomega.Expect(err).To(gomega.Succeed())
+		targetInstance = instance
+		instanceID = &grpc_application_go.AppInstanceId{
+			OrganizationId: targetOrganization.ID,
+			AppInstanceId:  instance.AppInstanceId,
+		}
+
+		_, err = manager.AddParametrizedDescriptor(generateParametrizedDescriptor(descriptor.ToGRPC(), instance.AppInstanceId))
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should not report drift on an instance matching its descriptor", func() {
+		addGroupInstances(targetDescriptor.Groups[0].Specs.Replicas)
+		drift, err := manager.GetAppInstanceDrift(instanceID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(drift.Changes).To(gomega.BeEmpty())
+		gomega.Expect(drift.HasDrift()).To(gomega.BeFalse())
+		gomega.Expect(drift.DescriptorRevision).Should(gomega.Equal(entities.InitialDescriptorRevision))
+	})
+
+	ginkgo.It("should report missing service groups", func() {
+		drift, err := manager.GetAppInstanceDrift(instanceID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(drift.Changes)).Should(gomega.Equal(1))
+		gomega.Expect(drift.Changes[0].Type).Should(gomega.Equal(entities.ElementRemoved))
+		gomega.Expect(drift.Changes[0].Element).Should(gomega.Equal("group"))
+	})
+
+	ginkgo.It("should report replica and image changes", func() {
+		addGroupInstances(1)
+		instance, err := manager.AppProvider.GetInstance(targetInstance.AppInstanceId)
+		gomega.Expect(err).To(gomega.Succeed())
+		instance.Groups[0].ServiceInstances[0].Image = "image:modified"
+		err = manager.AppProvider.UpdateInstance(*instance)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		drift, err := manager.GetAppInstanceDrift(instanceID)
+		gomega.Expect(err).To(gomega.Succeed())
+		var replicas, image bool
+		for _, change := range drift.Changes {
+			if change.Element == "group" && change.Field == "replicas" {
+				replicas = true
+			}
+			if change.Element == "service" && change.Field == "image" {
+				image = true
+				gomega.Expect(change.NewValue).Should(gomega.Equal("\"image:modified\""))
+			}
+		}
+		gomega.Expect(replicas).To(gomega.BeTrue())
+		gomega.Expect(image).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should fail if the instance does not belong to the organization", func() {
+		_, err := manager.GetAppInstanceDrift(&grpc_application_go.AppInstanceId{
+			OrganizationId: targetOrganization.ID,
+			AppInstanceId:  "unknown",
+		})
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+})
@@ -0,0 +1,248 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package application
+
+import (
+	"github.com/nalej/grpc-application-go"
+	"github.com/nalej/grpc-infrastructure-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	devProvider "github.com/nalej/system-model/internal/pkg/provider/device"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Application endpoint FQDNs", func() {
+
+	const publicHostDomain = "nalej.cluster.local"
+
+	var manager Manager
+	var organizationProvider orgProvider.Provider
+	var applicationProvider appProvider.Provider
+
+	createEndpointRequest := func(organizationID string, appInstanceID string, serviceGroupInstanceID string, serviceInstanceID string) *grpc_application_go.AddAppEndpointRequest {
+		return &grpc_application_go.AddAppEndpointRequest{
+			OrganizationId:         organizationID,
+			AppInstanceId:          appInstanceID,
+			ServiceGroupInstanceId: serviceGroupInstanceID,
+			ServiceInstanceId:      serviceInstanceID,
+			ServiceName:            "web",
+			Protocol:               grpc_application_go.AppEndpointProtocol_HTTPS,
+			EndpointInstance: &grpc_application_go.EndpointInstance{
+				EndpointInstanceId: entities.GenerateUUID(),
+				Type:               grpc_application_go.EndpointType_WEB,
+				Fqdn:               "web.sg.app.org.local",
+				Port:               443,
+			},
+		}
+	}
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider = orgProvider.NewMockupOrganizationProvider()
+		applicationProvider = appProvider.NewMockupApplicationProvider()
+		manager = NewManager(organizationProvider, applicationProvider, devProvider.NewMockupDeviceProvider(), publicHostDomain, newIPAMManager(organizationProvider),
+			testhelpers.NewQuotaManager(organizationProvider))
+	})
+
+	ginkgo.Context("assigning global fqdns", func() {
+		ginkgo.It("should extend the prefixes when two instances collide", func() {
+			first := createEndpointRequest("aaaaaaaa-0000-0000-0000-000000000001", "bbbbbb-1", "cccccc-1", "s1")
+			second := createEndpointRequest("aaaaaaaa-0000-0000-0000-000000000002", "bbbbbb-2", "cccccc-2", "s2")
+
+			err := manager.AddAppEndpoint(first)
+			gomega.Expect(err).To(gomega.Succeed())
+			err = manager.AddAppEndpoint(second)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			firstFqdns, err := applicationProvider.ListAppEndpointFqdns(first.OrganizationId, first.AppInstanceId)
+			gomega.Expect(err).To(gomega.Succeed())
+			secondFqdns, err := applicationProvider.ListAppEndpointFqdns(second.OrganizationId, second.AppInstanceId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(firstFqdns)).Should(gomega.Equal(1))
+			gomega.Expect(len(secondFqdns)).Should(gomega.Equal(1))
+			gomega.Expect(firstFqdns[0].GlobalFqdn).Should(gomega.Equal("web-443.cccccc.bbbbbb.aaaaaaaa"))
+			gomega.Expect(secondFqdns[0].GlobalFqdn).Should(gomega.Equal("web-443.cccccc-2.bbbbbb-2.aaaaaaaa-0"))
+
+			resolved, err := manager.ResolvePublicFqdn(entities.PublicFqdn(firstFqdns[0].GlobalFqdn, publicHostDomain))
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(resolved)).Should(gomega.Equal(1))
+			gomega.Expect(resolved[0].OrganizationId).Should(gomega.Equal(first.OrganizationId))
+		})
+		ginkgo.It("should reuse the fqdn of a service for new replicas", func() {
+			first := createEndpointRequest("aaaaaaaa-0000-0000-0000-000000000001", "bbbbbb-1", "cccccc-1", "s1")
+			collision := createEndpointRequest("aaaaaaaa-0000-0000-0000-000000000002", "bbbbbb-2", "cccccc-2", "s2")
+			replica := createEndpointRequest(collision.OrganizationId, collision.AppInstanceId, collision.ServiceGroupInstanceId, "s3")
+
+			gomega.Expect(manager.AddAppEndpoint(first)).To(gomega.Succeed())
+			gomega.Expect(manager.AddAppEndpoint(collision)).To(gomega.Succeed())
+			gomega.Expect(manager.AddAppEndpoint(replica)).To(gomega.Succeed())
+
+			resolved, err := manager.ResolvePublicFqdn("web-443.cccccc-2.bbbbbb-2.aaaaaaaa-0.ep." + publicHostDomain)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(resolved)).Should(gomega.Equal(2))
+			gomega.Expect(resolved[0].ServiceInstanceId).Should(gomega.Equal("s2"))
+			gomega.Expect(resolved[1].ServiceInstanceId).Should(gomega.Equal("s3"))
+		})
+		ginkgo.It("should release the fqdns when the endpoints are removed", func() {
+			first := createEndpointRequest("aaaaaaaa-0000-0000-0000-000000000001", "bbbbbb-1", "cccccc-1", "s1")
+			gomega.Expect(manager.AddAppEndpoint(first)).To(gomega.Succeed())
+
+			err := manager.RemoveAppEndpoints(&grpc_application_go.RemoveAppEndpointRequest{
+				OrganizationId: first.OrganizationId,
+				AppInstanceId:  first.AppInstanceId,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+
+			fqdns, err := applicationProvider.ListAppEndpointFqdns(first.OrganizationId, first.AppInstanceId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(fqdns).Should(gomega.BeEmpty())
+		})
+		ginkgo.It("should reject fqdns outside the public host domain", func() {
+			_, err := manager.ResolvePublicFqdn("web.cccccc.bbbbbb.aaaaaaaa.ep.other.domain")
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+	})
+
+	ginkgo.Context("listing endpoints", func() {
+		ginkgo.It("should list the endpoints of an organization and a cluster", func() {
+			organization := testhelpers.AddOrganization(organizationProvider)
+			instance := appProvider.CreateTestApplication(organization.ID, entities.GenerateUUID())
+			gomega.Expect(applicationProvider.AddInstance(*instance)).To(gomega.Succeed())
+			gomega.Expect(organizationProvider.AddInstance(organization.ID, instance.AppInstanceId)).To(gomega.Succeed())
+
+			group := instance.Groups[0]
+			service := group.ServiceInstances[0]
+			err := manager.AddAppEndpoint(createEndpointRequest(organization.ID, instance.AppInstanceId,
+				group.ServiceGroupInstanceId, service.ServiceInstanceId))
+			gomega.Expect(err).To(gomega.Succeed())
+
+			endpoints, err := manager.ListOrganizationEndpoints(&grpc_organization_go.OrganizationId{OrganizationId: organization.ID})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(endpoints)).Should(gomega.Equal(1))
+
+			endpoints, err = manager.ListClusterEndpoints(&grpc_infrastructure_go.ClusterId{
+				OrganizationId: organization.ID,
+				ClusterId:      service.DeployedOnClusterId,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(endpoints)).Should(gomega.Equal(1))
+
+			endpoints, err = manager.ListClusterEndpoints(&grpc_infrastructure_go.ClusterId{
+				OrganizationId: organization.ID,
+				ClusterId:      entities.GenerateUUID(),
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(endpoints).Should(gomega.BeEmpty())
+		})
+	})
+
+	ginkgo.Context("resolving protocols", func() {
+		// addDeclaredEndpoint adds an instance whose first service declares an endpoint and returns the request to
+		// register it.
+		addDeclaredEndpoint := func(port int32, endpointType entities.EndpointType, options map[string]string) *grpc_application_go.AddAppEndpointRequest {
+			instance := appProvider.CreateTestApplication(entities.GenerateUUID(), entities.GenerateUUID())
+			instance.Groups[0].ServiceInstances[0].ExposedPorts = []entities.Port{{
+				Name:         "port",
+				InternalPort: port,
+				ExposedPort:  port,
+				Endpoints:    []entities.Endpoint{{Type: endpointType, Options: options}},
+			}}
+			gomega.Expect(applicationProvider.AddInstance(*instance)).To(gomega.Succeed())
+
+			group := instance.Groups[0]
+			request := createEndpointRequest(instance.OrganizationId, instance.AppInstanceId,
+				group.ServiceGroupInstanceId, group.ServiceInstances[0].ServiceInstanceId)
+			request.Protocol = grpc_application_go.AppEndpointProtocol_HTTP
+			request.EndpointInstance.Port = port
+			request.EndpointInstance.Type = entities.EndpointTypeToGRPC[endpointType]
+			return request
+		}
+		getEndpoint := func(request *grpc_application_go.AddAppEndpointRequest) *entities.AppEndpoint {
+			endpoints, err := applicationProvider.GetAppEndpointList(request.OrganizationId, request.AppInstanceId, request.ServiceGroupInstanceId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(endpoints)).Should(gomega.Equal(1))
+			return endpoints[0]
+		}
+
+		ginkgo.It("should use the protocol declared by the service", func() {
+			request := addDeclaredEndpoint(80, entities.IsAlive, map[string]string{entities.EndpointProtocolOption: "udp"})
+			gomega.Expect(manager.AddAppEndpoint(request)).To(gomega.Succeed())
+
+			endpoint := getEndpoint(request)
+			gomega.Expect(endpoint.Protocol).Should(gomega.Equal(entities.UDP))
+			gomega.Expect(endpoint.GlobalFqdn).Should(gomega.HavePrefix("web-80."))
+		})
+		ginkgo.It("should keep the gRPC protocol if the service does not declare it", func() {
+			request := createEndpointRequest(entities.GenerateUUID(), entities.GenerateUUID(), entities.GenerateUUID(), entities.GenerateUUID())
+			gomega.Expect(manager.AddAppEndpoint(request)).To(gomega.Succeed())
+			gomega.Expect(getEndpoint(request).Protocol).Should(gomega.Equal(entities.HTTPS))
+		})
+		ginkgo.It("should reject UDP endpoints exposed through an ingress", func() {
+			request := addDeclaredEndpoint(53, entities.Web, map[string]string{entities.EndpointProtocolOption: "udp"})
+			gomega.Expect(manager.AddAppEndpoint(request)).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should require HTTP/2 for gRPC endpoints", func() {
+			request := addDeclaredEndpoint(80, entities.Rest, map[string]string{entities.EndpointProtocolOption: "grpc"})
+			gomega.Expect(manager.AddAppEndpoint(request)).NotTo(gomega.Succeed())
+
+			request = addDeclaredEndpoint(80, entities.Rest, map[string]string{
+				entities.EndpointProtocolOption: "grpc", entities.EndpointHTTP2Option: "true"})
+			gomega.Expect(manager.AddAppEndpoint(request)).To(gomega.Succeed())
+			endpoint := getEndpoint(request)
+			gomega.Expect(endpoint.Protocol).Should(gomega.Equal(entities.GRPC))
+			gomega.Expect(endpoint.HTTP2).Should(gomega.BeTrue())
+			gomega.Expect(endpoint.GlobalFqdn).Should(gomega.HavePrefix("web."))
+		})
+		ginkgo.It("should return the protocol of the endpoints in the gRPC API", func() {
+			request := createEndpointRequest(entities.GenerateUUID(), entities.GenerateUUID(), entities.GenerateUUID(), entities.GenerateUUID())
+			gomega.Expect(manager.AddAppEndpoint(request)).To(gomega.Succeed())
+
+			list, err := manager.GetAppEndpoint(&grpc_application_go.GetAppEndPointRequest{
+				Fqdn: entities.PublicFqdn(getEndpoint(request).GlobalFqdn, publicHostDomain),
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(list.AppEndpoints)).Should(gomega.Equal(1))
+			gomega.Expect(list.AppEndpoints[0].Protocol).Should(gomega.Equal(grpc_application_go.AppEndpointProtocol_HTTPS))
+			gomega.Expect(entities.AppEndpointProtocolFromGRPC[list.AppEndpoints[0].Protocol]).Should(gomega.Equal(entities.HTTPS))
+
+			request = addDeclaredEndpoint(80, entities.Rest, map[string]string{
+				entities.EndpointProtocolOption: "grpc", entities.EndpointHTTP2Option: "true"})
+			gomega.Expect(manager.AddAppEndpoint(request)).To(gomega.Succeed())
+			converted, err := getEndpoint(request).ToGRPC()
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(converted.Protocol).Should(gomega.Equal(grpc_application_go.AppEndpointProtocol_HTTP))
+		})
+		ginkgo.It("should not return the endpoints that cannot be represented in the gRPC API", func() {
+			request := addDeclaredEndpoint(80, entities.IsAlive, map[string]string{entities.EndpointProtocolOption: "udp"})
+			gomega.Expect(manager.AddAppEndpoint(request)).To(gomega.Succeed())
+
+			_, err := getEndpoint(request).ToGRPC()
+			gomega.Expect(err).NotTo(gomega.Succeed())
+			_, err = manager.GetAppEndpoint(&grpc_application_go.GetAppEndPointRequest{
+				Fqdn: entities.PublicFqdn(getEndpoint(request).GlobalFqdn, publicHostDomain),
+			})
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should reject unknown protocols", func() {
+			request := addDeclaredEndpoint(8080, entities.Rest, map[string]string{entities.EndpointProtocolOption: "sctp"})
+			gomega.Expect(manager.AddAppEndpoint(request)).NotTo(gomega.Succeed())
+		})
+	})
+})
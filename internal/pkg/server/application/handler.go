@@ -23,6 +23,7 @@ import (
 	"github.com/nalej/grpc-organization-go"
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
 	"github.com/rs/zerolog/log"
 )
 
@@ -47,7 +48,7 @@ func (h *Handler) AddAppDescriptor(ctx context.Context, addRequest *grpc_applica
 	added, err := h.Manager.AddAppDescriptor(addRequest)
 	if err != nil {
 		log.Error().Str("trace", err.DebugReport()).Msg("cannot add application descriptor")
-		return nil, conversions.ToGRPCError(err)
+		return nil, quota.ToGRPCError(err)
 	}
 	return added.ToGRPC(), nil
 }
@@ -166,7 +167,7 @@ func (h *Handler) AddAppInstance(ctx context.Context, addInstanceRequest *grpc_a
 	added, err := h.Manager.AddAppInstance(addInstanceRequest)
 	if err != nil {
 		log.Error().Str("trace", err.DebugReport()).Msg("cannot add application instance")
-		return nil, conversions.ToGRPCError(err)
+		return nil, quota.ToGRPCError(err)
 	}
 	return added.ToGRPC(), nil
 }
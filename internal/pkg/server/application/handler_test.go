@@ -293,7 +293,8 @@ var _ = ginkgo.Describe("Applications", func() {
 		applicationProvider = appProvider.NewMockupApplicationProvider()
 		deviceProvider = devProvider.NewMockupDeviceProvider()
 
-		manager := NewManager(organizationProvider, applicationProvider, deviceProvider, "nalej.cluster.local")
+		manager := NewManager(organizationProvider, applicationProvider, deviceProvider, "nalej.cluster.local", newIPAMManager(organizationProvider),
+			testhelpers.NewQuotaManager(organizationProvider))
 		handler := NewHandler(manager)
 		grpc_application_go.RegisterApplicationsServer(server, handler)
 
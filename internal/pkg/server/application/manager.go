@@ -20,14 +20,20 @@ import (
 	"fmt"
 	"github.com/nalej/derrors"
 	"github.com/nalej/grpc-application-go"
+	"github.com/nalej/grpc-infrastructure-go"
 	"github.com/nalej/grpc-organization-go"
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/provider/application"
 	"github.com/nalej/system-model/internal/pkg/provider/device"
 	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/ipam"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
+	"github.com/nalej/system-model/internal/pkg/utils"
 	"github.com/rs/zerolog/log"
+	"sort"
 	"strings"
+	"time"
 )
 
 const unknownField = "Unknown"
@@ -38,11 +44,13 @@ type Manager struct {
 	AppProvider      application.Provider
 	DevProvider      device.Provider
 	PublicHostDomain string
+	IPAM             ipam.Manager
+	Quota            quota.Manager
 }
 
 // NewManager creates a Manager using a set of providers.
-func NewManager(orgProvider organization.Provider, appProvider application.Provider, devProvider device.Provider, publicHostDomain string) Manager {
-	return Manager{orgProvider, appProvider, devProvider, publicHostDomain}
+func NewManager(orgProvider organization.Provider, appProvider application.Provider, devProvider device.Provider, publicHostDomain string, ipamManager ipam.Manager, quotaManager quota.Manager) Manager {
+	return Manager{orgProvider, appProvider, devProvider, publicHostDomain, ipamManager, quotaManager}
 }
 
 func (m *Manager) extractGroupIds(organizationID string, rules []*grpc_application_go.SecurityRule) (map[string]string, derrors.Error) {
@@ -108,18 +116,42 @@ func (m *Manager) AddAppDescriptor(addRequest *grpc_application_go.AddAppDescrip
 		return nil, err
 	}
 
+	err = m.Quota.Acquire(descriptor.OrganizationId, entities.DescriptorQuota, 1)
+	if err != nil {
+		return nil, err
+	}
 	err = m.AppProvider.AddDescriptor(*descriptor)
 	if err != nil {
+		m.Quota.Release(descriptor.OrganizationId, entities.DescriptorQuota, 1)
+		return nil, err
+	}
+	err = m.AppProvider.AddDescriptorRevision(*entities.NewAppDescriptorRevision(*descriptor))
+	if err != nil {
+		m.rollbackAddAppDescriptor(*descriptor, false)
 		return nil, err
 	}
 	err = m.OrgProvider.AddDescriptor(descriptor.OrganizationId, descriptor.AppDescriptorId)
 	if err != nil {
+		m.rollbackAddAppDescriptor(*descriptor, true)
 		return nil, err
 	}
 
 	return descriptor, nil
 }
 
+// rollbackAddAppDescriptor removes a descriptor that could not be completely added and releases its quota.
+func (m *Manager) rollbackAddAppDescriptor(descriptor entities.AppDescriptor, withRevisions bool) {
+	if withRevisions {
+		if err := m.AppProvider.DeleteDescriptorRevisions(descriptor.AppDescriptorId); err != nil {
+			log.Error().Str("trace", err.DebugReport()).Str("appDescriptorID", descriptor.AppDescriptorId).Msg("cannot remove the revisions of a descriptor on rollback")
+		}
+	}
+	if err := m.AppProvider.DeleteDescriptor(descriptor.AppDescriptorId); err != nil {
+		log.Error().Str("trace", err.DebugReport()).Str("appDescriptorID", descriptor.AppDescriptorId).Msg("cannot remove a descriptor on rollback")
+	}
+	m.Quota.Release(descriptor.OrganizationId, entities.DescriptorQuota, 1)
+}
+
 // ListDescriptors obtains a list of descriptors associated with an organization.
 func (m *Manager) ListDescriptors(orgID *grpc_organization_go.OrganizationId) ([]entities.AppDescriptor, derrors.Error) {
 	exists, err := m.OrgProvider.Exists(orgID.OrganizationId)
@@ -212,8 +244,137 @@ func (m *Manager) RemoveAppDescriptor(appDescID *grpc_application_go.AppDescript
 		if rollbackError != nil {
 			log.Error().Str("trace", conversions.ToDerror(rollbackError).DebugReport()).Msg("error in Rollback")
 		}
+		return err
 	}
-	return err
+	m.Quota.Release(appDescID.OrganizationId, entities.DescriptorQuota, 1)
+	err = m.AppProvider.DeleteDescriptorRevisions(appDescID.AppDescriptorId)
+	if err != nil {
+		log.Warn().Str("appDescriptorID", appDescID.AppDescriptorId).Str("trace", err.DebugReport()).Msg("error removing descriptor revisions")
+	}
+	return nil
+}
+
+// checkDescriptor checks that an organization exists and that the descriptor belongs to it.
+func (m *Manager) checkDescriptor(organizationID string, appDescriptorID string) derrors.Error {
+	exists, err := m.OrgProvider.Exists(organizationID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("organizationID").WithParams(organizationID)
+	}
+	exists, err = m.OrgProvider.DescriptorExists(organizationID, appDescriptorID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("appDescriptorID").WithParams(organizationID, appDescriptorID)
+	}
+	return nil
+}
+
+// ReviseAppDescriptor replaces the contents of a descriptor creating a new revision. The descriptor keeps its
+// identifier and labels. If the contents do not change, no revision is created.
+func (m *Manager) ReviseAppDescriptor(appDescriptorID string, request *grpc_application_go.AddAppDescriptorRequest) (*entities.AppDescriptor, derrors.Error) {
+	err := entities.ValidAddAppDescriptorRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	err = m.checkDescriptor(request.OrganizationId, appDescriptorID)
+	if err != nil {
+		return nil, err
+	}
+	// The revision is added first, so a concurrent revision of the same descriptor fails with AlreadyExists
+	// and the operation is executed again over the latest contents.
+	for attempt := 0; attempt < utils.MaxConflictRetries; attempt++ {
+		revised, err := m.reviseAppDescriptor(appDescriptorID, request)
+		if err == nil || err.Type() != derrors.AlreadyExists {
+			return revised, err
+		}
+		log.Debug().Int("attempt", attempt).Str("appDescriptorID", appDescriptorID).Msg("revision conflict, retrying")
+	}
+	return nil, derrors.NewAlreadyExistsError("descriptor revision").WithParams(appDescriptorID)
+}
+
+// reviseAppDescriptor creates the next revision of a descriptor.
+func (m *Manager) reviseAppDescriptor(appDescriptorID string, request *grpc_application_go.AddAppDescriptorRequest) (*entities.AppDescriptor, derrors.Error) {
+	current, err := m.AppProvider.GetDescriptor(appDescriptorID)
+	if err != nil {
+		return nil, err
+	}
+	// Descriptors created before revisions were available are stored as their first revision
+	if current.Revision < entities.InitialDescriptorRevision {
+		current.Revision = entities.InitialDescriptorRevision
+		err = m.AppProvider.AddDescriptorRevision(*entities.NewAppDescriptorRevision(*current))
+		if err != nil && err.Type() != derrors.AlreadyExists {
+			return nil, err
+		}
+	}
+
+	revised, err := current.Revise(request)
+	if err != nil {
+		return nil, err
+	}
+	err = entities.ValidateDescriptor(*revised)
+	if err != nil {
+		return nil, err
+	}
+
+	revision := entities.NewAppDescriptorRevision(*revised)
+	diff := entities.NewAppDescriptorDiff(*entities.NewAppDescriptorRevision(*current), *revision)
+	if len(diff.Changes) == 0 {
+		return current, nil
+	}
+
+	err = m.AppProvider.AddDescriptorRevision(*revision)
+	if err != nil {
+		return nil, err
+	}
+	err = m.AppProvider.UpdateDescriptor(*revised)
+	if err != nil {
+		// the revision is removed so the next attempt can use the same revision number
+		if rErr := m.AppProvider.RemoveDescriptorRevision(revision.AppDescriptorId, revision.Revision); rErr != nil {
+			log.Warn().Str("appDescriptorID", revision.AppDescriptorId).Int64("revision", revision.Revision).
+				Str("trace", rErr.DebugReport()).Msg("cannot remove descriptor revision")
+		}
+		return nil, err
+	}
+	return revised, nil
+}
+
+// ListAppDescriptorRevisions retrieves the revisions of a descriptor.
+func (m *Manager) ListAppDescriptorRevisions(appDescID *grpc_application_go.AppDescriptorId) ([]entities.AppDescriptorRevision, derrors.Error) {
+	err := m.checkDescriptor(appDescID.OrganizationId, appDescID.AppDescriptorId)
+	if err != nil {
+		return nil, err
+	}
+	return m.AppProvider.ListDescriptorRevisions(appDescID.AppDescriptorId)
+}
+
+// GetAppDescriptorRevision retrieves a given revision of a descriptor.
+func (m *Manager) GetAppDescriptorRevision(appDescID *grpc_application_go.AppDescriptorId, revision int64) (*entities.AppDescriptorRevision, derrors.Error) {
+	err := entities.ValidRevision(revision)
+	if err != nil {
+		return nil, err
+	}
+	err = m.checkDescriptor(appDescID.OrganizationId, appDescID.AppDescriptorId)
+	if err != nil {
+		return nil, err
+	}
+	return m.AppProvider.GetDescriptorRevision(appDescID.AppDescriptorId, revision)
+}
+
+// GetAppDescriptorDiff returns the structured differences between two revisions of a descriptor.
+func (m *Manager) GetAppDescriptorDiff(appDescID *grpc_application_go.AppDescriptorId, fromRevision int64, toRevision int64) (*entities.AppDescriptorDiff, derrors.Error) {
+	from, err := m.GetAppDescriptorRevision(appDescID, fromRevision)
+	if err != nil {
+		return nil, err
+	}
+	to, err := m.AppProvider.GetDescriptorRevision(appDescID.AppDescriptorId, toRevision)
+	if err != nil {
+		return nil, err
+	}
+	return entities.NewAppDescriptorDiff(*from, *to), nil
 }
 
 func (m *Manager) GetDescriptorAppParameters(request *grpc_application_go.AppDescriptorId) ([]entities.Parameter, derrors.Error) {
@@ -257,13 +418,19 @@ func (m *Manager) AddAppInstance(addRequest *grpc_application_go.AddAppInstanceR
 		return nil, err
 	}
 
+	err = m.Quota.Acquire(addRequest.OrganizationId, entities.InstanceQuota, 1)
+	if err != nil {
+		return nil, err
+	}
 	instance := entities.NewAppInstanceFromAddInstanceRequestGRPC(addRequest, descriptor)
 	err = m.AppProvider.AddInstance(*instance)
 	if err != nil {
+		m.Quota.Release(instance.OrganizationId, entities.InstanceQuota, 1)
 		return nil, err
 	}
 	err = m.OrgProvider.AddInstance(instance.OrganizationId, instance.AppInstanceId)
 	if err != nil {
+		m.Quota.Release(instance.OrganizationId, entities.InstanceQuota, 1)
 		return nil, err
 	}
 
@@ -280,6 +447,8 @@ func (m *Manager) AddAppInstance(addRequest *grpc_application_go.AddAppInstanceR
 			rollBackErr := m.AppProvider.DeleteInstance(instance.AppInstanceId)
 			if rollBackErr != nil {
 				log.Error().Str("instance_id", instance.AppInstanceId).Str("trace", rollBackErr.DebugReport()).Msg("Error removing instance")
+			} else {
+				m.Quota.Release(instance.OrganizationId, entities.InstanceQuota, 1)
 			}
 			return nil, err
 		}
@@ -339,7 +508,7 @@ func (m *Manager) fillGlobalFqdn(instance *entities.AppInstance) derrors.Error {
 			// map to list
 			instance.Groups[i].GlobalFqdn = make([]string, 0)
 			for key, _ := range fqdns {
-				instance.Groups[i].GlobalFqdn = append(instance.Groups[i].GlobalFqdn, fmt.Sprintf("%s.ep.%s", key, m.PublicHostDomain))
+				instance.Groups[i].GlobalFqdn = append(instance.Groups[i].GlobalFqdn, entities.PublicFqdn(key, m.PublicHostDomain))
 			}
 		}
 
@@ -377,6 +546,34 @@ func (m *Manager) GetInstance(appInstID *grpc_application_go.AppInstanceId) (*en
 	return instance, nil
 }
 
+// GetAppInstanceDrift compares an instance with the parametrized descriptor it was created from and reports
+// the differences.
+func (m *Manager) GetAppInstanceDrift(appInstID *grpc_application_go.AppInstanceId) (*entities.AppInstanceDrift, derrors.Error) {
+	exists, err := m.OrgProvider.Exists(appInstID.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, derrors.NewNotFoundError("organizationID").WithParams(appInstID.OrganizationId)
+	}
+	exists, err = m.OrgProvider.InstanceExists(appInstID.OrganizationId, appInstID.AppInstanceId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, derrors.NewNotFoundError("appInstanceID").WithParams(appInstID.OrganizationId, appInstID.AppInstanceId)
+	}
+	instance, err := m.AppProvider.GetInstance(appInstID.AppInstanceId)
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := m.AppProvider.GetParametrizedDescriptor(appInstID.AppInstanceId)
+	if err != nil {
+		return nil, err
+	}
+	return entities.NewAppInstanceDrift(*descriptor, *instance), nil
+}
+
 // UpdateInstance updates the information of a given instance.
 func (m *Manager) UpdateInstance(updateRequest *grpc_application_go.UpdateAppStatusRequest) derrors.Error {
 	exists, err := m.OrgProvider.InstanceExists(updateRequest.OrganizationId, updateRequest.AppInstanceId)
@@ -387,22 +584,45 @@ func (m *Manager) UpdateInstance(updateRequest *grpc_application_go.UpdateAppSta
 		return derrors.NewNotFoundError("appInstanceID").WithParams(updateRequest.OrganizationId, updateRequest.AppInstanceId)
 	}
 
-	toUpdate, err := m.AppProvider.GetInstance(updateRequest.AppInstanceId)
+	_, err = m.updateInstance(updateRequest.AppInstanceId, func(toUpdate *entities.AppInstance) derrors.Error {
+		toUpdate.Status = entities.AppStatusFromGRPC[updateRequest.Status]
+		if updateRequest.Info != "" {
+			toUpdate.Info = updateRequest.Info
+		}
+		return nil
+	})
 	if err != nil {
-		return derrors.NewInternalError("impossible to get old instance", err)
+		return derrors.NewInternalError("impossible to update instance").CausedBy(err)
 	}
 
-	toUpdate.Status = entities.AppStatusFromGRPC[updateRequest.Status]
-	if updateRequest.Info != "" {
-		toUpdate.Info = updateRequest.Info
-	}
+	return nil
+}
 
-	err = m.AppProvider.UpdateInstance(*toUpdate)
+// updateInstance retrieves an instance, applies a change and stores it. The operation is executed again if the
+// instance is updated concurrently.
+func (m *Manager) updateInstance(appInstanceID string, apply func(instance *entities.AppInstance) derrors.Error) (*entities.AppInstance, derrors.Error) {
+	var updated *entities.AppInstance
+	err := utils.RetryOnConflict(func() derrors.Error {
+		retrieved, err := m.AppProvider.GetInstance(appInstanceID)
+		if err != nil {
+			return err
+		}
+		err = apply(retrieved)
+		if err != nil {
+			return err
+		}
+		err = m.AppProvider.UpdateInstance(*retrieved)
+		if err != nil {
+			return err
+		}
+		retrieved.Version++
+		updated = retrieved
+		return nil
+	})
 	if err != nil {
-		return derrors.NewInternalError("impossible to update instance").CausedBy(err)
+		return nil, err
 	}
-
-	return nil
+	return updated, nil
 }
 
 // UpdateService updates an application service.
@@ -417,40 +637,34 @@ func (m *Manager) UpdateService(updateRequest *grpc_application_go.UpdateService
 	if !exists {
 		return derrors.NewNotFoundError("appInstanceID").WithParams(updateRequest.OrganizationId, updateRequest.AppInstanceId)
 	}
-	toUpdate, err := m.AppProvider.GetInstance(updateRequest.AppInstanceId)
-	if err != nil {
-		return derrors.NewInternalError("impossible to get parent instance", err)
-	}
-
-	aux := toUpdate
-
-	// find the service instance
-	for indexGroup, g := range toUpdate.Groups {
-		// find the group
-		if g.ServiceGroupInstanceId == updateRequest.ServiceGroupInstanceId {
-			// find the service
-			changed := false
-			for indexService, serviceInstance := range g.ServiceInstances {
-				if serviceInstance.ServiceInstanceId == updateRequest.ServiceInstanceId {
-					// found and updated
-					// build the endpoint instances
-					endpoints := make([]entities.EndpointInstance, len(updateRequest.Endpoints))
-					for i, ep := range updateRequest.Endpoints {
-						endpoints[i] = entities.EndpointInstanceFromGRPC(ep)
+	_, err = m.updateInstance(updateRequest.AppInstanceId, func(toUpdate *entities.AppInstance) derrors.Error {
+		// find the service instance
+		for indexGroup, g := range toUpdate.Groups {
+			// find the group
+			if g.ServiceGroupInstanceId == updateRequest.ServiceGroupInstanceId {
+				// find the service
+				changed := false
+				for indexService, serviceInstance := range g.ServiceInstances {
+					if serviceInstance.ServiceInstanceId == updateRequest.ServiceInstanceId {
+						// found and updated
+						// build the endpoint instances
+						endpoints := make([]entities.EndpointInstance, len(updateRequest.Endpoints))
+						for i, ep := range updateRequest.Endpoints {
+							endpoints[i] = entities.EndpointInstanceFromGRPC(ep)
+						}
+						toUpdate.Groups[indexGroup].ServiceInstances[indexService].Status = entities.ServiceStatusFromGRPC[updateRequest.Status]
+						toUpdate.Groups[indexGroup].ServiceInstances[indexService].Endpoints = endpoints
+						toUpdate.Groups[indexGroup].ServiceInstances[indexService].DeployedOnClusterId = updateRequest.DeployedOnClusterId
+						changed = true
 					}
-					aux.Groups[indexGroup].ServiceInstances[indexService].Status = entities.ServiceStatusFromGRPC[updateRequest.Status]
-					aux.Groups[indexGroup].ServiceInstances[indexService].Endpoints = endpoints
-					aux.Groups[indexGroup].ServiceInstances[indexService].DeployedOnClusterId = updateRequest.DeployedOnClusterId
-					changed = true
 				}
-			}
-			if !changed {
-				return derrors.NewInternalError("update service failed. Not all the entries were found.")
+				if !changed {
+					return derrors.NewInternalError("update service failed. Not all the entries were found.")
+				}
 			}
 		}
-	}
-
-	err = m.AppProvider.UpdateInstance(*aux)
+		return nil
+	})
 	if err != nil {
 		return derrors.NewInternalError("impossible to update instance").CausedBy(err)
 	}
@@ -462,7 +676,17 @@ func (m *Manager) UpdateService(updateRequest *grpc_application_go.UpdateService
 func (m *Manager) UpdateAppInstance(appInstance *grpc_application_go.AppInstance) derrors.Error {
 	localEntity := entities.NewAppInstanceFromGRPC(appInstance)
 
-	err := m.AppProvider.UpdateInstance(*localEntity)
+	// the instance received replaces the stored one, whatever its version
+	err := utils.RetryOnConflict(func() derrors.Error {
+		current, err := m.AppProvider.GetInstance(localEntity.AppInstanceId)
+		if err != nil {
+			return err
+		}
+		localEntity.Version = current.Version
+		// the project is not part of the received instance
+		localEntity.ProjectId = current.ProjectId
+		return m.AppProvider.UpdateInstance(*localEntity)
+	})
 	if err != nil {
 		return derrors.NewInternalError("impossible to update application instance").CausedBy(err)
 	}
@@ -499,10 +723,15 @@ func (m *Manager) RemoveAppInstance(appInstID *grpc_application_go.AppInstanceId
 				Str("appInstID.AppInstanceId", appInstID.AppInstanceId).Msg("error in Rollback")
 		}
 	} else { // delete parameters (if exist)
+		m.Quota.Release(appInstID.OrganizationId, entities.InstanceQuota, 1)
 		instErr := m.AppProvider.DeleteInstanceParameters(appInstID.AppInstanceId)
 		if instErr != nil {
 			log.Error().Str("instanceID", appInstID.AppInstanceId).Str("trace", instErr.DebugReport()).Msg("Error removing parameters")
 		}
+		fqdnErr := m.AppProvider.DeleteAppEndpointFqdns(appInstID.OrganizationId, appInstID.AppInstanceId)
+		if fqdnErr != nil {
+			log.Error().Str("instanceID", appInstID.AppInstanceId).Str("trace", fqdnErr.DebugReport()).Msg("Error releasing global fqdns")
+		}
 	}
 	return err
 }
@@ -654,17 +883,11 @@ func (m *Manager) AddServiceGroupInstances(request *grpc_application_go.AddServi
 		result[numReplica] = *sgi
 	}
 
-	// get the app instance
-	retrieved, err := m.AppProvider.GetInstance(request.AppInstanceId)
-	if err != nil {
-		return nil, err
-	}
-
 	// set the new values for these service group instances
-	retrieved.Groups = append(retrieved.Groups, result...)
-
-	// update
-	err = m.AppProvider.UpdateInstance(*retrieved)
+	_, err = m.updateInstance(request.AppInstanceId, func(retrieved *entities.AppInstance) derrors.Error {
+		retrieved.Groups = append(retrieved.Groups, result...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -673,21 +896,11 @@ func (m *Manager) AddServiceGroupInstances(request *grpc_application_go.AddServi
 }
 
 func (m *Manager) RemoveServiceGroupInstances(removeRequest *grpc_application_go.RemoveServiceGroupInstancesRequest) derrors.Error {
-	// Get the corresponding instance
-	appInst, err := m.AppProvider.GetInstance(removeRequest.AppInstanceId)
-	if err != nil {
-		return err
-	}
-
-	appInst.Groups = nil
-
-	// update
-	err = m.AppProvider.UpdateInstance(*appInst)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	_, err := m.updateInstance(removeRequest.AppInstanceId, func(appInst *entities.AppInstance) derrors.Error {
+		appInst.Groups = nil
+		return nil
+	})
+	return err
 }
 
 func (m *Manager) GetServiceGroupInstanceMetadata(request *grpc_application_go.GetServiceGroupInstanceMetadataRequest) (*entities.InstanceMetadata, derrors.Error) {
@@ -709,34 +922,27 @@ func (m *Manager) GetServiceGroupInstanceMetadata(request *grpc_application_go.G
 }
 
 func (m *Manager) UpdateServiceGroupInstanceMetadata(request *grpc_application_go.InstanceMetadata) derrors.Error {
-	// Get the corresponding instance
-	appInst, err := m.AppProvider.GetInstance(request.AppInstanceId)
-	if err != nil {
-		return err
-	}
-
-	// Find the service group instance and update it
-	targetGroupIndex := 0
-	found := false
-	for i, groupInst := range appInst.Groups {
-		if groupInst.ServiceGroupInstanceId == request.MonitoredInstanceId {
-			targetGroupIndex = i
-			found = true
-			break
+	_, err := m.updateInstance(request.AppInstanceId, func(appInst *entities.AppInstance) derrors.Error {
+		// Find the service group instance and update it
+		targetGroupIndex := 0
+		found := false
+		for i, groupInst := range appInst.Groups {
+			if groupInst.ServiceGroupInstanceId == request.MonitoredInstanceId {
+				targetGroupIndex = i
+				found = true
+				break
+			}
 		}
-	}
 
-	if !found {
-		return derrors.NewNotFoundError(fmt.Sprintf("service group instance %s not found", request.MonitoredInstanceId))
-	}
+		if !found {
+			return derrors.NewNotFoundError(fmt.Sprintf("service group instance %s not found", request.MonitoredInstanceId))
+		}
 
-	//update the corresponding application instance
-	appInst.Groups[targetGroupIndex].Metadata = entities.NewMetadataFromGRPC(request)
-	err = m.AppProvider.UpdateInstance(*appInst)
-	if err != nil {
-		return err
-	}
-	return nil
+		//update the corresponding application instance
+		appInst.Groups[targetGroupIndex].Metadata = entities.NewMetadataFromGRPC(request)
+		return nil
+	})
+	return err
 }
 
 func (m *Manager) AddServiceInstance(request *grpc_application_go.AddServiceInstanceRequest) (*entities.ServiceInstance, derrors.Error) {
@@ -782,28 +988,17 @@ func (m *Manager) AddServiceInstance(request *grpc_application_go.AddServiceInst
 	// Instance creation
 	serviceInstance := service.ToServiceInstance(request.AppInstanceId, request.ServiceGroupInstanceId)
 
-	// get the instance
-	retrieved, err := m.AppProvider.GetInstance(request.AppInstanceId)
-	if err != nil {
-		return nil, err
-	}
-
 	// look for the service_group_instance and add the new service into service group
-	found := false // boolean to control if the service group has been found
-	for i := 0; i < len(retrieved.Groups); i++ {
-		if retrieved.Groups[i].ServiceGroupId == request.ServiceGroupId &&
-			retrieved.Groups[i].ServiceGroupInstanceId == request.ServiceGroupInstanceId {
-			retrieved.Groups[i].ServiceInstances = append(retrieved.Groups[i].ServiceInstances, *serviceInstance)
-			found = true
-			break
+	_, err = m.updateInstance(request.AppInstanceId, func(retrieved *entities.AppInstance) derrors.Error {
+		for i := 0; i < len(retrieved.Groups); i++ {
+			if retrieved.Groups[i].ServiceGroupId == request.ServiceGroupId &&
+				retrieved.Groups[i].ServiceGroupInstanceId == request.ServiceGroupInstanceId {
+				retrieved.Groups[i].ServiceInstances = append(retrieved.Groups[i].ServiceInstances, *serviceInstance)
+				return nil
+			}
 		}
-	}
-	if !found {
-		return nil, derrors.NewNotFoundError("ServiceGroupInstanceId").WithParams(request.ServiceGroupInstanceId)
-	}
-
-	// update the instance
-	err = m.AppProvider.UpdateInstance(*retrieved)
+		return derrors.NewNotFoundError("ServiceGroupInstanceId").WithParams(request.ServiceGroupInstanceId)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -818,6 +1013,15 @@ func (m *Manager) AddAppEndpoint(appEndpoint *grpc_application_go.AddAppEndpoint
 	if err != nil {
 		return err
 	}
+	err = m.resolveEndpointProtocol(endpoint)
+	if err != nil {
+		return err
+	}
+	globalFqdn, err := m.assignGlobalFqdn(appEndpoint, endpoint.Protocol)
+	if err != nil {
+		return err
+	}
+	endpoint.GlobalFqdn = globalFqdn
 
 	err = m.AppProvider.AddAppEndpoint(*endpoint)
 	if err != nil {
@@ -827,13 +1031,127 @@ func (m *Manager) AddAppEndpoint(appEndpoint *grpc_application_go.AddAppEndpoint
 	return nil
 }
 
+// resolveEndpointProtocol completes the protocol of an endpoint with the options declared in the exposed ports of its
+// service, as the gRPC API only supports HTTP and HTTPS, and checks the restrictions of the protocol.
+func (m *Manager) resolveEndpointProtocol(endpoint *entities.AppEndpoint) derrors.Error {
+	instance, err := m.AppProvider.GetInstance(endpoint.AppInstanceId)
+	if err != nil && err.Type() != derrors.NotFound {
+		return err
+	}
+	if instance != nil {
+		declared := instance.DeclaredEndpoint(endpoint.ServiceGroupInstanceId, endpoint.ServiceInstanceId, endpoint.Port, endpoint.Type)
+		if declared != nil {
+			if _, exists := declared.Options[entities.EndpointProtocolOption]; exists {
+				protocol, err := declared.Protocol()
+				if err != nil {
+					return err
+				}
+				endpoint.Protocol = protocol
+			}
+			endpoint.HTTP2 = declared.HTTP2()
+		}
+	}
+	return entities.ValidAppEndpointProtocol(endpoint.Protocol, endpoint.Type, endpoint.Port, endpoint.HTTP2)
+}
+
+// assignGlobalFqdn returns the global FQDN of the service of an endpoint. The FQDN already assigned to the service is
+// reused; otherwise, the prefixes of the identifiers are extended until a free FQDN is found.
+func (m *Manager) assignGlobalFqdn(appEndpoint *grpc_application_go.AddAppEndpointRequest, protocol entities.AppEndpointProtocol) (string, derrors.Error) {
+	candidates := entities.GlobalFqdnCandidates(appEndpoint, protocol)
+	owner := entities.NewAppEndpointFqdn(candidates[0], appEndpoint)
+
+	assigned, err := m.AppProvider.ListAppEndpointFqdns(appEndpoint.OrganizationId, appEndpoint.AppInstanceId)
+	if err != nil {
+		return "", err
+	}
+	for _, fqdn := range assigned {
+		if fqdn.ServiceGroupInstanceId == owner.ServiceGroupInstanceId && fqdn.ServiceName == owner.ServiceName {
+			return fqdn.GlobalFqdn, nil
+		}
+	}
+
+	for _, candidate := range candidates {
+		current, err := m.AppProvider.GetAppEndpointFqdn(candidate)
+		if err == nil {
+			if current.SameOwner(*owner) {
+				return candidate, nil
+			}
+			log.Debug().Str("globalFqdn", candidate).Str("owner", current.AppInstanceId).Msg("global fqdn collision")
+			continue
+		}
+		if err.Type() != derrors.NotFound {
+			return "", err
+		}
+		if m.usedByLegacyEndpoints(candidate, *owner) {
+			log.Debug().Str("globalFqdn", candidate).Msg("global fqdn collision with an existing endpoint")
+			continue
+		}
+		toAdd := entities.NewAppEndpointFqdn(candidate, appEndpoint)
+		err = m.AppProvider.AddAppEndpointFqdn(*toAdd)
+		if err == nil {
+			return candidate, nil
+		}
+		if err.Type() != derrors.AlreadyExists {
+			return "", err
+		}
+		// The FQDN was assigned concurrently, it is only reused if it was assigned to the same service
+		current, err = m.AppProvider.GetAppEndpointFqdn(candidate)
+		if err != nil {
+			return "", err
+		}
+		if current.SameOwner(*owner) {
+			return candidate, nil
+		}
+		log.Debug().Str("globalFqdn", candidate).Str("owner", current.AppInstanceId).Msg("global fqdn collision")
+	}
+	return "", derrors.NewAlreadyExistsError("global fqdn").WithParams(candidates[len(candidates)-1])
+}
+
+// usedByLegacyEndpoints checks if a global FQDN is used by endpoints of another owner that were added before the
+// global FQDNs were indexed.
+func (m *Manager) usedByLegacyEndpoints(globalFqdn string, owner entities.AppEndpointFqdn) bool {
+	list, err := m.AppProvider.GetAppEndpointByFQDN(globalFqdn)
+	if err != nil {
+		return false
+	}
+	for _, endpoint := range list {
+		if !owner.Owns(*endpoint) {
+			return true
+		}
+	}
+	return false
+}
+
+// getOwnedEndpoints retrieves the endpoints of a global FQDN. If the FQDN is indexed, only the endpoints of its owner
+// are returned.
+func (m *Manager) getOwnedEndpoints(globalFqdn string) ([]*entities.AppEndpoint, *entities.AppEndpointFqdn, derrors.Error) {
+	list, err := m.AppProvider.GetAppEndpointByFQDN(globalFqdn)
+	if err != nil {
+		return nil, nil, err
+	}
+	owner, err := m.AppProvider.GetAppEndpointFqdn(globalFqdn)
+	if err != nil {
+		if err.Type() != derrors.NotFound {
+			return nil, nil, err
+		}
+		return list, nil, nil
+	}
+	owned := make([]*entities.AppEndpoint, 0, len(list))
+	for _, endpoint := range list {
+		if owner.Owns(*endpoint) {
+			owned = append(owned, endpoint)
+		}
+	}
+	return owned, owner, nil
+}
+
 // GetAppEndPoint retrieves an appEndpoint
 func (m *Manager) GetAppEndpoint(request *grpc_application_go.GetAppEndPointRequest) (*grpc_application_go.AppEndpointList, derrors.Error) {
 
 	split := strings.Split(request.Fqdn, ".")
 	globalFqdn := fmt.Sprintf("%s.%s.%s.%s", split[0], split[1], split[2], split[3])
 
-	list, err := m.AppProvider.GetAppEndpointByFQDN(globalFqdn)
+	list, _, err := m.getOwnedEndpoints(globalFqdn)
 	if err != nil {
 		return nil, err
 	}
@@ -847,7 +1165,11 @@ func (m *Manager) GetAppEndpoint(request *grpc_application_go.GetAppEndPointRequ
 			if endpoint.OrganizationId != organizationID {
 				return nil, derrors.NewInternalError("Unable to return app end points, several organizations have the same endpoint")
 			}
-			endpointList = append(endpointList, endpoint.ToGRPC())
+			converted, err := endpoint.ToGRPC()
+			if err != nil {
+				return nil, err
+			}
+			endpointList = append(endpointList, converted)
 		}
 	}
 
@@ -857,7 +1179,115 @@ func (m *Manager) GetAppEndpoint(request *grpc_application_go.GetAppEndPointRequ
 }
 
 func (m *Manager) RemoveAppEndpoints(removeRequest *grpc_application_go.RemoveAppEndpointRequest) derrors.Error {
-	return m.AppProvider.DeleteAppEndpoints(removeRequest.OrganizationId, removeRequest.AppInstanceId)
+	err := m.AppProvider.DeleteAppEndpoints(removeRequest.OrganizationId, removeRequest.AppInstanceId)
+	if err != nil {
+		return err
+	}
+	return m.AppProvider.DeleteAppEndpointFqdns(removeRequest.OrganizationId, removeRequest.AppInstanceId)
+}
+
+// ResolvePublicFqdn retrieves the endpoints of a public FQDN under the public host domain. The endpoints are sorted
+// to provide a deterministic answer.
+func (m *Manager) ResolvePublicFqdn(fqdn string) ([]entities.AppEndpoint, derrors.Error) {
+	globalFqdn, err := entities.GlobalFqdnFromPublicFqdn(fqdn, m.PublicHostDomain)
+	if err != nil {
+		return nil, err
+	}
+	list, _, err := m.getOwnedEndpoints(globalFqdn)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, derrors.NewNotFoundError("appEndPoint").WithParams(fqdn)
+	}
+	organizationID := list[0].OrganizationId
+	result := make([]entities.AppEndpoint, 0, len(list))
+	for _, endpoint := range list {
+		if endpoint.OrganizationId != organizationID {
+			return nil, derrors.NewInternalError("Unable to return app end points, several organizations have the same endpoint")
+		}
+		result = append(result, *endpoint)
+	}
+	sortAppEndpoints(result)
+	return result, nil
+}
+
+// ListOrganizationEndpoints retrieves all the endpoints of the instances of an organization.
+func (m *Manager) ListOrganizationEndpoints(orgID *grpc_organization_go.OrganizationId) ([]entities.AppEndpoint, derrors.Error) {
+	return m.listEndpoints(orgID.OrganizationId, func(instance entities.AppInstance, endpoint entities.AppEndpoint) bool {
+		return true
+	})
+}
+
+// ListClusterEndpoints retrieves the endpoints of the service instances deployed on a cluster.
+func (m *Manager) ListClusterEndpoints(clusterID *grpc_infrastructure_go.ClusterId) ([]entities.AppEndpoint, derrors.Error) {
+	return m.listEndpoints(clusterID.OrganizationId, func(instance entities.AppInstance, endpoint entities.AppEndpoint) bool {
+		for _, group := range instance.Groups {
+			if group.ServiceGroupInstanceId != endpoint.ServiceGroupInstanceId {
+				continue
+			}
+			for _, service := range group.ServiceInstances {
+				if service.ServiceInstanceId == endpoint.ServiceInstanceId {
+					return service.DeployedOnClusterId == clusterID.ClusterId
+				}
+			}
+		}
+		return false
+	})
+}
+
+// listEndpoints retrieves the endpoints of the instances of an organization that satisfy a filter.
+func (m *Manager) listEndpoints(organizationID string, filter func(entities.AppInstance, entities.AppEndpoint) bool) ([]entities.AppEndpoint, derrors.Error) {
+	exists, err := m.OrgProvider.Exists(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, derrors.NewNotFoundError("organizationID").WithParams(organizationID)
+	}
+	instances, err := m.OrgProvider.ListInstances(organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]entities.AppEndpoint, 0)
+	for _, instID := range instances {
+		instance, err := m.AppProvider.GetInstance(instID)
+		if err != nil {
+			log.Warn().Str("instance", instID).Msg("instance not found while listing endpoints")
+			continue
+		}
+		for _, group := range instance.Groups {
+			endpoints, err := m.AppProvider.GetAppEndpointList(organizationID, instID, group.ServiceGroupInstanceId)
+			if err != nil {
+				return nil, err
+			}
+			for _, endpoint := range endpoints {
+				if filter(*instance, *endpoint) {
+					result = append(result, *endpoint)
+				}
+			}
+		}
+	}
+	sortAppEndpoints(result)
+	return result, nil
+}
+
+// sortAppEndpoints sorts a list of endpoints by global FQDN, service instance, port and protocol.
+func sortAppEndpoints(endpoints []entities.AppEndpoint) {
+	sort.Slice(endpoints, func(i, j int) bool {
+		a, b := endpoints[i], endpoints[j]
+		if a.GlobalFqdn != b.GlobalFqdn {
+			return a.GlobalFqdn < b.GlobalFqdn
+		}
+		if a.ServiceInstanceId != b.ServiceInstanceId {
+			return a.ServiceInstanceId < b.ServiceInstanceId
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return a.Protocol < b.Protocol
+	})
 }
 
 func (m *Manager) AddZtNetwork(request *grpc_application_go.AddAppZtNetworkRequest) derrors.Error {
@@ -865,11 +1295,27 @@ func (m *Manager) AddZtNetwork(request *grpc_application_go.AddAppZtNetworkReque
 		AppInstanceId: request.AppInstanceId, ZtNetworkId: request.NetworkId, VSAList: request.VsaList})
 }
 
+// RemoveZtNetwork removes the zt network of an application instance and releases its IP range.
 func (m *Manager) RemoveZtNetwork(request *grpc_application_go.RemoveAppZtNetworkRequest) derrors.Error {
-	return m.AppProvider.RemoveAppZtNetwork(request.OrganizationId, request.AppInstanceId)
+	network, err := m.AppProvider.GetAppZtNetwork(request.OrganizationId, request.AppInstanceId)
+	if err != nil && err.Type() != derrors.NotFound {
+		return err
+	}
+	err = m.AppProvider.RemoveAppZtNetwork(request.OrganizationId, request.AppInstanceId)
+	if err != nil {
+		return err
+	}
+	if network != nil {
+		return m.IPAM.ReleaseRanges(request.OrganizationId, network.ZtNetworkId)
+	}
+	return nil
 }
 
+// AddZtNetworkProxy adds a zt service proxy, recording when it was added so it can be garbage collected.
 func (m *Manager) AddZtNetworkProxy(request *entities.ServiceProxy) derrors.Error {
+	if request.CreatedAt == 0 {
+		request.CreatedAt = time.Now().Unix()
+	}
 	return m.AppProvider.AddZtNetworkProxy(*request)
 }
 
@@ -882,12 +1328,75 @@ func (m *Manager) GetAppZtNetwork(request *grpc_application_go.GetAppZtNetworkRe
 	return m.AppProvider.GetAppZtNetwork(request.OrganizationId, request.AppInstanceId)
 }
 
+// AddAppZtNetworkMember adds a member to the zt network of an application instance. Members of a registered zt
+// network get an IP address of the range allocated for the network.
 func (m *Manager) AddAppZtNetworkMember(request *grpc_application_go.AddAuthorizedZtNetworkMemberRequest) (*entities.AppZtNetworkMembers, derrors.Error) {
-	return m.AppProvider.AddAppZtNetworkMember(*entities.NewAppZtNetworkMemberFromGRPC(request))
+	toAdd := entities.NewAppZtNetworkMemberFromGRPC(request)
+
+	network, err := m.AppProvider.GetAppZtNetwork(request.OrganizationId, request.AppInstanceId)
+	if err != nil && err.Type() != derrors.NotFound {
+		return nil, err
+	}
+	if network != nil && network.ZtNetworkId == request.NetworkId {
+		ipRange, err := m.IPAM.AllocateRange(request.OrganizationId, request.NetworkId)
+		if err != nil {
+			return nil, err
+		}
+		address, err := m.IPAM.AllocateAddress(request.OrganizationId, ipRange.Cidr, request.MemberId)
+		if err != nil {
+			return nil, err
+		}
+		member := toAdd.Members[request.MemberId]
+		member.Ip = address.Ip
+		toAdd.Members[request.MemberId] = member
+	}
+
+	added, err := m.AppProvider.AddAppZtNetworkMember(*toAdd)
+	if err != nil {
+		if network != nil && network.ZtNetworkId == request.NetworkId {
+			m.releaseMemberAddresses(toAdd)
+		}
+		return nil, err
+	}
+	return added, nil
+}
+
+// releaseMemberAddresses releases the IP addresses assigned to a set of zt network members.
+func (m *Manager) releaseMemberAddresses(members *entities.AppZtNetworkMembers) {
+	for _, member := range members.Members {
+		if member.Ip == "" {
+			continue
+		}
+		ipRange, err := m.IPAM.GetOwnerRange(members.OrganizationId, members.ZtNetworkId)
+		if err != nil {
+			if err.Type() != derrors.NotFound {
+				log.Warn().Str("trace", err.DebugReport()).Str("ztNetworkId", members.ZtNetworkId).Msg("cannot retrieve the range of the zt network")
+			}
+			return
+		}
+		err = m.IPAM.ReleaseAddress(members.OrganizationId, ipRange.Cidr, member.Ip)
+		if err != nil {
+			log.Warn().Str("trace", err.DebugReport()).Str("ip", member.Ip).Msg("cannot release the address of the zt member")
+		}
+	}
 }
 
+// RemoveAppZtNetworkMember removes the members of a service in a zt network and releases their IP addresses.
 func (m *Manager) RemoveAppZtNetworkMember(organizationId string, appInstanceId string, serviceGroupInstanceId string, serviceApplicationInstanceId string, ztNetworkId string) derrors.Error {
-	return m.AppProvider.RemoveAppZtNetworkMember(organizationId, appInstanceId, serviceGroupInstanceId, serviceApplicationInstanceId, ztNetworkId)
+	retrieved, err := m.AppProvider.ListAppZtNetworkMembers(organizationId, appInstanceId, ztNetworkId)
+	if err != nil && err.Type() != derrors.NotFound {
+		return err
+	}
+	err = m.AppProvider.RemoveAppZtNetworkMember(organizationId, appInstanceId, serviceGroupInstanceId, serviceApplicationInstanceId, ztNetworkId)
+	if err != nil {
+		return err
+	}
+	for _, members := range retrieved {
+		if members.ServiceGroupInstanceId == serviceGroupInstanceId && members.ServiceApplicationInstanceId == serviceApplicationInstanceId {
+			m.releaseMemberAddresses(members)
+		}
+	}
+	return nil
 }
 
 func (m *Manager) GetAppZtNetworkMember(organizationId string, appInstanceId string, serviceGroupInstanceId string, serviceApplicationInstanceId string) (*entities.AppZtNetworkMembers, derrors.Error) {
@@ -0,0 +1,178 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package application
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-application-go"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	devProvider "github.com/nalej/system-model/internal/pkg/provider/device"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// failingUpdateProvider fails the next descriptor update.
+type failingUpdateProvider struct {
+	appProvider.Provider
+	fail bool
+}
+
+func (p *failingUpdateProvider) UpdateDescriptor(descriptor entities.AppDescriptor) derrors.Error {
+	if p.fail {
+		p.fail = false
+		return derrors.NewInternalError("update failed")
+	}
+	return p.Provider.UpdateDescriptor(descriptor)
+}
+
+var _ = ginkgo.Describe("Application descriptor revisions", func() {
+
+	const numServices = 2
+
+	var manager Manager
+	var targetOrganization *entities.Organization
+	var targetDescriptor *entities.AppDescriptor
+	var descriptorID *grpc_application_go.AppDescriptorId
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider := orgProvider.NewMockupOrganizationProvider()
+		manager = NewManager(organizationProvider, appProvider.NewMockupApplicationProvider(),
+			devProvider.NewMockupDeviceProvider(), "nalej.cluster.local", newIPAMManager(organizationProvider),
+			testhelpers.NewQuotaManager(organizationProvider))
+		targetOrganization = testhelpers.AddOrganization(organizationProvider)
+
+		added, err := manager.AddAppDescriptor(generateAddAppDescriptor(targetOrganization.ID, numServices))
+		gomega.Expect(err).To(gomega.Succeed())
+		targetDescriptor = added
+		descriptorID = &grpc_application_go.AppDescriptorId{
+			OrganizationId:  targetOrganization.ID,
+			AppDescriptorId: added.AppDescriptorId,
+		}
+	})
+
+	ginkgo.It("should create the initial revision when a descriptor is added", func() {
+		gomega.Expect(targetDescriptor.Revision).Should(gomega.Equal(entities.InitialDescriptorRevision))
+		revisions, err := manager.ListAppDescriptorRevisions(descriptorID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(revisions)).Should(gomega.Equal(1))
+		gomega.Expect(revisions[0].Revision).Should(gomega.Equal(entities.InitialDescriptorRevision))
+	})
+
+	ginkgo.It("should create a new revision when the services change", func() {
+		request := generateAddAppDescriptor(targetOrganization.ID, numServices)
+		request.Groups[0].Services[0].Image = "image:revised"
+		revised, err := manager.ReviseAppDescriptor(targetDescriptor.AppDescriptorId, request)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(revised.AppDescriptorId).Should(gomega.Equal(targetDescriptor.AppDescriptorId))
+		gomega.Expect(revised.Revision).Should(gomega.Equal(entities.InitialDescriptorRevision + 1))
+
+		retrieved, err := manager.GetDescriptor(descriptorID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Revision).Should(gomega.Equal(revised.Revision))
+
+		old, err := manager.GetAppDescriptorRevision(descriptorID, entities.InitialDescriptorRevision)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(old.Groups[0].Services[0].Image).Should(gomega.Equal(targetDescriptor.Groups[0].Services[0].Image))
+	})
+
+	ginkgo.It("should not create a new revision if the contents do not change", func() {
+		request := generateAddAppDescriptor(targetOrganization.ID, numServices)
+		for i, s := range targetDescriptor.Groups[0].Services {
+			request.Groups[0].Services[i].Image = s.Image
+			request.Groups[0].Services[i].Specs = s.Specs.ToGRPC()
+		}
+		revised, err := manager.ReviseAppDescriptor(targetDescriptor.AppDescriptorId, request)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(revised.Revision).Should(gomega.Equal(entities.InitialDescriptorRevision))
+	})
+
+	ginkgo.It("should not revise a descriptor of another organization", func() {
+		request := generateAddAppDescriptor(testhelpers.CreateOrganization().ID, numServices)
+		_, err := manager.ReviseAppDescriptor(targetDescriptor.AppDescriptorId, request)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should return the differences between two revisions", func() {
+		request := generateAddAppDescriptor(targetOrganization.ID, numServices+1)
+		for i, s := range targetDescriptor.Groups[0].Services {
+			request.Groups[0].Services[i].Image = s.Image
+			request.Groups[0].Services[i].Specs = s.Specs.ToGRPC()
+		}
+		request.Groups[0].Services[0].Image = "image:revised"
+		request.Parameters = []*grpc_application_go.AppParameter{{Name: "param", Path: "groups.0.services.0.image"}}
+		revised, err := manager.ReviseAppDescriptor(targetDescriptor.AppDescriptorId, request)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		diff, err := manager.GetAppDescriptorDiff(descriptorID, entities.InitialDescriptorRevision, revised.Revision)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(diff.FromRevision).Should(gomega.Equal(entities.InitialDescriptorRevision))
+		gomega.Expect(diff.ToRevision).Should(gomega.Equal(revised.Revision))
+
+		var imageChanged, serviceAdded, paramAdded bool
+		for _, change := range diff.Changes {
+			switch {
+			case change.Element == "service" && change.Type == entities.ElementModified && change.Field == "image":
+				imageChanged = true
+				gomega.Expect(change.NewValue).Should(gomega.Equal("\"image:revised\""))
+			case change.Element == "service" && change.Type == entities.ElementAdded:
+				serviceAdded = true
+			case change.Element == "parameter" && change.Type == entities.ElementAdded:
+				paramAdded = true
+			}
+		}
+		gomega.Expect(imageChanged).To(gomega.BeTrue())
+		gomega.Expect(serviceAdded).To(gomega.BeTrue())
+		gomega.Expect(paramAdded).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should record the descriptor revision in new instances", func() {
+		request := generateAddAppDescriptor(targetOrganization.ID, numServices)
+		request.Groups[0].Services[0].Image = "image:revised"
+		revised, err := manager.ReviseAppDescriptor(targetDescriptor.AppDescriptorId, request)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		instance, err := manager.AddAppInstance(generateAddAppInstance(targetOrganization.ID, targetDescriptor.AppDescriptorId))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(instance.DescriptorRevision).Should(gomega.Equal(revised.Revision))
+	})
+
+	ginkgo.It("should remove the revision if the descriptor cannot be updated", func() {
+		manager.AppProvider = &failingUpdateProvider{Provider: manager.AppProvider, fail: true}
+		request := generateAddAppDescriptor(targetOrganization.ID, numServices)
+		request.Groups[0].Services[0].Image = "image:revised"
+		_, err := manager.ReviseAppDescriptor(targetDescriptor.AppDescriptorId, request)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		revisions, err := manager.ListAppDescriptorRevisions(descriptorID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(revisions).Should(gomega.HaveLen(1))
+
+		revised, err := manager.ReviseAppDescriptor(targetDescriptor.AppDescriptorId, request)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(revised.Revision).Should(gomega.Equal(entities.InitialDescriptorRevision + 1))
+	})
+
+	ginkgo.It("should remove the revisions with the descriptor", func() {
+		err := manager.RemoveAppDescriptor(descriptorID)
+		gomega.Expect(err).To(gomega.Succeed())
+		revisions, err := manager.AppProvider.ListDescriptorRevisions(descriptorID.AppDescriptorId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(revisions).To(gomega.BeEmpty())
+	})
+})
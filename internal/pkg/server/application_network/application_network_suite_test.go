@@ -17,6 +17,10 @@
 package application_network
 
 import (
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	ipamProvider "github.com/nalej/system-model/internal/pkg/provider/ipam"
+	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/ipam"
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
 	"testing"
@@ -26,3 +30,10 @@ func TestApplicationPackage(t *testing.T) {
 	gomega.RegisterFailHandler(ginkgo.Fail)
 	ginkgo.RunSpecs(t, "Application Network package suite")
 }
+
+// newIPAMManager creates an IPAM manager with in-memory providers and the default pool.
+func newIPAMManager(organizationProvider organization.Provider) ipam.Manager {
+	pool, err := cidr.ParsePool(cidr.DefaultPool, cidr.DefaultBlockPrefixLength)
+	gomega.Expect(err).To(gomega.Succeed())
+	return ipam.NewManager(organizationProvider, ipamProvider.NewMockupIPAMProvider(), pool)
+}
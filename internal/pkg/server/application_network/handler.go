@@ -24,6 +24,7 @@ import (
 	"github.com/nalej/grpc-organization-go"
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
 	"github.com/rs/zerolog/log"
 )
 
@@ -44,7 +45,7 @@ func (h *Handler) AddConnection(ctx context.Context, addConnectionRequest *grpc_
 	added, err := h.Manager.AddConnectionInstance(addConnectionRequest)
 	if err != nil {
 		log.Error().Str("trace", err.DebugReport()).Msg("cannot add connection instance")
-		return nil, conversions.ToGRPCError(err)
+		return nil, quota.ToGRPCError(err)
 	}
 	return added.ToGRPC(), nil
 }
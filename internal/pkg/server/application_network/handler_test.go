@@ -26,6 +26,7 @@ import (
 	"github.com/nalej/system-model/internal/pkg/provider/application"
 	"github.com/nalej/system-model/internal/pkg/provider/application_network"
 	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
 	"google.golang.org/grpc"
@@ -107,7 +108,8 @@ var _ = ginkgo.Describe("Application Network service", func() {
 		organizationProvider = organization.NewMockupOrganizationProvider()
 		applicationProvider = application.NewMockupApplicationProvider()
 		appNetProvider = application_network.NewMockupApplicationNetworkProvider()
-		manager := NewManager(organizationProvider, applicationProvider, appNetProvider)
+		manager := NewManager(organizationProvider, applicationProvider, appNetProvider, newIPAMManager(organizationProvider), entities.DefaultConnectionStatusPolicy,
+			testhelpers.NewQuotaManager(organizationProvider))
 		handler := NewHandler(manager)
 		grpc_application_network_go.RegisterApplicationNetworkServer(server, handler)
 
@@ -143,7 +145,7 @@ var _ = ginkgo.Describe("Application Network service", func() {
 				TargetInstanceId: targetInstance.AppInstanceId,
 				InboundName:      targetInstance.InboundNetInterfaces[0].Name,
 				OutboundName:     sourceInstance.OutboundNetInterfaces[0].Name,
-				IpRange:          entities.GenerateUUID(),
+				IpRange:          "",
 				ZtNetworkId:      entities.GenerateUUID(),
 			}
 			connectionInstance, err := client.AddConnection(context.Background(), addConnectionRequest)
@@ -167,7 +169,7 @@ var _ = ginkgo.Describe("Application Network service", func() {
 				TargetInstanceId: targetInstance.AppInstanceId,
 				InboundName:      targetInstance.InboundNetInterfaces[0].Name,
 				OutboundName:     sourceInstance.OutboundNetInterfaces[0].Name,
-				IpRange:          entities.GenerateUUID(),
+				IpRange:          "",
 				ZtNetworkId:      entities.GenerateUUID(),
 			}
 			_, err := client.AddConnection(context.Background(), addConnectionRequest)
@@ -191,7 +193,7 @@ var _ = ginkgo.Describe("Application Network service", func() {
 				TargetInstanceId: targetInstance.AppInstanceId,
 				InboundName:      targetInstance.InboundNetInterfaces[0].Name,
 				OutboundName:     sourceInstance.OutboundNetInterfaces[0].Name,
-				IpRange:          entities.GenerateUUID(),
+				IpRange:          "",
 				ZtNetworkId:      entities.GenerateUUID(),
 			}
 			connectionAdded, err := client.AddConnection(context.Background(), addConnectionRequest)
@@ -233,7 +235,7 @@ var _ = ginkgo.Describe("Application Network service", func() {
 				TargetInstanceId: targetInstance.AppInstanceId,
 				InboundName:      targetInstance.InboundNetInterfaces[0].Name,
 				OutboundName:     sourceInstance.OutboundNetInterfaces[0].Name,
-				IpRange:          entities.GenerateUUID(),
+				IpRange:          "",
 				ZtNetworkId:      entities.GenerateUUID(),
 			}
 			connectionAdded, err := client.AddConnection(context.Background(), addConnectionRequest)
@@ -271,7 +273,7 @@ var _ = ginkgo.Describe("Application Network service", func() {
 				TargetInstanceId: targetInstance.AppInstanceId,
 				InboundName:      targetInstance.InboundNetInterfaces[0].Name,
 				OutboundName:     sourceInstance.OutboundNetInterfaces[0].Name,
-				IpRange:          entities.GenerateUUID(),
+				IpRange:          "",
 				ZtNetworkId:      entities.GenerateUUID(),
 			}
 			_, err := client.AddConnection(context.Background(), addConnectionRequest)
@@ -310,12 +312,12 @@ var _ = ginkgo.Describe("Application Network service", func() {
 				TargetInstanceId: targetInstance.AppInstanceId,
 				InboundName:      targetInstance.InboundNetInterfaces[0].Name,
 				OutboundName:     sourceInstance.OutboundNetInterfaces[0].Name,
-				IpRange:          entities.GenerateUUID(),
+				IpRange:          "",
 				ZtNetworkId:      entities.GenerateUUID(),
 			}
 			_, err := client.AddConnection(context.Background(), addConnectionRequest)
 			gomega.Expect(err).To(gomega.Succeed())
-			newRange := "172.16.0.1-172.16.0.255"
+			newRange := "172.16.0.0/24"
 			updateConnectionRequest := &grpc_application_network_go.UpdateConnectionRequest{
 				OrganizationId:    addConnectionRequest.OrganizationId,
 				SourceInstanceId:  addConnectionRequest.SourceInstanceId,
@@ -350,7 +352,7 @@ var _ = ginkgo.Describe("Application Network service", func() {
 				TargetInstanceId: targetInstance.AppInstanceId,
 				InboundName:      targetInstance.InboundNetInterfaces[0].Name,
 				OutboundName:     sourceInstance.OutboundNetInterfaces[0].Name,
-				IpRange:          entities.GenerateUUID(),
+				IpRange:          "",
 				ZtNetworkId:      entities.GenerateUUID(),
 			}
 			_, err := client.AddConnection(context.Background(), addConnectionRequest)
@@ -389,12 +391,12 @@ var _ = ginkgo.Describe("Application Network service", func() {
 				TargetInstanceId: targetInstance.AppInstanceId,
 				InboundName:      targetInstance.InboundNetInterfaces[0].Name,
 				OutboundName:     sourceInstance.OutboundNetInterfaces[0].Name,
-				IpRange:          entities.GenerateUUID(),
+				IpRange:          "",
 				ZtNetworkId:      entities.GenerateUUID(),
 			}
 			_, err := client.AddConnection(context.Background(), addConnectionRequest)
 			gomega.Expect(err).To(gomega.Succeed())
-			newRange := "172.16.0.1-172.16.0.255"
+			newRange := "172.16.0.0/24"
 			updateConnectionRequest := &grpc_application_network_go.UpdateConnectionRequest{
 				OrganizationId:    addConnectionRequest.OrganizationId,
 				SourceInstanceId:  addConnectionRequest.SourceInstanceId,
@@ -17,6 +17,7 @@
 package application_network
 
 import (
+	"fmt"
 	"github.com/nalej/derrors"
 	"github.com/nalej/grpc-application-go"
 	"github.com/nalej/grpc-application-network-go"
@@ -26,19 +27,29 @@ import (
 	"github.com/nalej/system-model/internal/pkg/provider/application"
 	"github.com/nalej/system-model/internal/pkg/provider/application_network"
 	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/ipam"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
+	"github.com/nalej/system-model/internal/pkg/topology"
+	"github.com/rs/zerolog/log"
 )
 
 type Manager struct {
 	OrganizationProvider organization.Provider
 	ApplicationProvider  application.Provider
 	AppNetProvider       application_network.Provider
+	IPAM                 ipam.Manager
+	StatusPolicy         entities.ConnectionStatusPolicy
+	Quota                quota.Manager
 }
 
-func NewManager(organizationProvider organization.Provider, applicationProvider application.Provider, appNetProvider application_network.Provider) Manager {
+func NewManager(organizationProvider organization.Provider, applicationProvider application.Provider, appNetProvider application_network.Provider, ipamManager ipam.Manager, statusPolicy entities.ConnectionStatusPolicy, quotaManager quota.Manager) Manager {
 	return Manager{
 		OrganizationProvider: organizationProvider,
 		ApplicationProvider:  applicationProvider,
 		AppNetProvider:       appNetProvider,
+		IPAM:                 ipamManager,
+		StatusPolicy:         statusPolicy,
+		Quota:                quotaManager,
 	}
 }
 
@@ -86,7 +97,29 @@ func (manager *Manager) AddConnectionInstance(addConnectionRequest *grpc_applica
 		outboundRequired,
 	)
 
+	err = manager.Quota.Acquire(instance.OrganizationId, entities.AppNetworkQuota, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// the IP range is allocated from the pool unless the caller chooses one
+	var ipRange *entities.IPRange
+	if instance.IpRange == "" {
+		ipRange, err = manager.IPAM.AllocateRange(instance.OrganizationId, instance.ConnectionId)
+	} else {
+		ipRange, err = manager.IPAM.RegisterRange(instance.OrganizationId, instance.ConnectionId, instance.IpRange)
+	}
+	if err != nil {
+		manager.Quota.Release(instance.OrganizationId, entities.AppNetworkQuota, 1)
+		return nil, err
+	}
+	instance.IpRange = ipRange.Cidr
+
 	if err = manager.AppNetProvider.AddConnectionInstance(*instance); err != nil {
+		manager.Quota.Release(instance.OrganizationId, entities.AppNetworkQuota, 1)
+		if rErr := manager.IPAM.ReleaseRanges(instance.OrganizationId, instance.ConnectionId); rErr != nil {
+			log.Warn().Str("trace", rErr.DebugReport()).Str("connectionId", instance.ConnectionId).Msg("cannot release the ip range of the connection")
+		}
 		return nil, err
 	}
 	return instance, nil
@@ -111,14 +144,170 @@ func (manager *Manager) UpdateConnectionInstance(updateConnectionRequest *grpc_a
 		return derrors.NewNotFoundError("connectionInstance").WithParams(updateConnectionRequest)
 	}
 
+	if updateConnectionRequest.UpdateIpRange {
+		ipRange, err := manager.updateIpRange(connectionInstance, updateConnectionRequest.IpRange)
+		if err != nil {
+			return err
+		}
+		updateConnectionRequest.IpRange = ipRange
+	}
+
 	connectionInstance.ApplyUpdate(updateConnectionRequest)
 
+	// the status of a connection with links is derived from them, except when the connection is being terminated
+	if updateConnectionRequest.UpdateStatus && connectionInstance.Status != entities.ConnectionStatusTerminated {
+		links, err := manager.AppNetProvider.ListConnectionInstanceLinks(connectionInstance.OrganizationId, connectionInstance.SourceInstanceId,
+			connectionInstance.TargetInstanceId, connectionInstance.InboundName, connectionInstance.OutboundName)
+		if err != nil {
+			return err
+		}
+		if status, derived := entities.AggregateConnectionStatus(manager.StatusPolicy, links); derived && status != connectionInstance.Status {
+			log.Debug().Str("connectionId", connectionInstance.ConnectionId).
+				Str("requested", entities.ConnectionStatusToString[connectionInstance.Status]).
+				Str("aggregated", entities.ConnectionStatusToString[status]).Msg("connection status derived from its links")
+			connectionInstance.Status = status
+		}
+	}
+
 	if err = manager.AppNetProvider.UpdateConnectionInstance(*connectionInstance); err != nil {
 		return err
 	}
 	return nil
 }
 
+// AddConnectionInstanceLink adds the link of a connection between a source and a target cluster, and updates the
+// status of the connection.
+func (manager *Manager) AddConnectionInstanceLink(link entities.ConnectionInstanceLink) derrors.Error {
+	err := manager.validOrganization(link.OrganizationId)
+	if err != nil {
+		return err
+	}
+	connection, err := manager.AppNetProvider.GetConnectionInstance(link.OrganizationId, link.SourceInstanceId,
+		link.TargetInstanceId, link.InboundName, link.OutboundName)
+	if err != nil {
+		return err
+	}
+	link.ConnectionId = connection.ConnectionId
+	if link.Status == 0 {
+		link.Status = entities.ConnectionStatusWaiting
+	}
+	if err = manager.AppNetProvider.AddConnectionInstanceLink(link); err != nil {
+		return err
+	}
+	return manager.refreshConnectionStatus(connection)
+}
+
+// UpdateConnectionInstanceLink updates the status of the link of a connection, and updates the status of the
+// connection.
+func (manager *Manager) UpdateConnectionInstanceLink(link entities.ConnectionInstanceLink) derrors.Error {
+	err := manager.validOrganization(link.OrganizationId)
+	if err != nil {
+		return err
+	}
+	connection, err := manager.AppNetProvider.GetConnectionInstance(link.OrganizationId, link.SourceInstanceId,
+		link.TargetInstanceId, link.InboundName, link.OutboundName)
+	if err != nil {
+		return err
+	}
+	retrieved, err := manager.AppNetProvider.GetConnectionInstanceLink(link.OrganizationId, link.SourceInstanceId,
+		link.TargetInstanceId, link.SourceClusterId, link.TargetClusterId, link.InboundName, link.OutboundName)
+	if err != nil {
+		return err
+	}
+	retrieved.Status = link.Status
+	if err = manager.AppNetProvider.UpdateConnectionInstanceLink(*retrieved); err != nil {
+		return err
+	}
+	return manager.refreshConnectionStatus(connection)
+}
+
+// refreshConnectionStatus stores the status of a connection derived from its links. Terminated connections are not
+// modified.
+func (manager *Manager) refreshConnectionStatus(connection *entities.ConnectionInstance) derrors.Error {
+	if connection.Status == entities.ConnectionStatusTerminated {
+		return nil
+	}
+	links, err := manager.AppNetProvider.ListConnectionInstanceLinks(connection.OrganizationId, connection.SourceInstanceId,
+		connection.TargetInstanceId, connection.InboundName, connection.OutboundName)
+	if err != nil {
+		return err
+	}
+	status, derived := entities.AggregateConnectionStatus(manager.StatusPolicy, links)
+	if !derived || status == connection.Status {
+		return nil
+	}
+	connection.Status = status
+	return manager.AppNetProvider.UpdateConnectionInstance(*connection)
+}
+
+// GetConnectionHealth retrieves the health summary of a connection.
+func (manager *Manager) GetConnectionHealth(connectionId *grpc_application_network_go.ConnectionInstanceId) (*entities.ConnectionHealth, derrors.Error) {
+	err := manager.validOrganization(connectionId.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	connection, err := manager.AppNetProvider.GetConnectionInstance(connectionId.OrganizationId, connectionId.SourceInstanceId,
+		connectionId.TargetInstanceId, connectionId.InboundName, connectionId.OutboundName)
+	if err != nil {
+		return nil, err
+	}
+	return manager.connectionHealth(*connection)
+}
+
+// ListConnectionHealth retrieves the health summary of all the connections of an organization.
+func (manager *Manager) ListConnectionHealth(organizationId *grpc_organization_go.OrganizationId) ([]entities.ConnectionHealth, derrors.Error) {
+	if err := manager.validOrganization(organizationId.OrganizationId); err != nil {
+		return nil, err
+	}
+	connections, err := manager.AppNetProvider.ListConnectionInstances(organizationId.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]entities.ConnectionHealth, 0, len(connections))
+	for _, connection := range connections {
+		health, err := manager.connectionHealth(connection)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *health)
+	}
+	return result, nil
+}
+
+// connectionHealth builds the health summary of a connection.
+func (manager *Manager) connectionHealth(connection entities.ConnectionInstance) (*entities.ConnectionHealth, derrors.Error) {
+	links, err := manager.AppNetProvider.ListConnectionInstanceLinks(connection.OrganizationId, connection.SourceInstanceId,
+		connection.TargetInstanceId, connection.InboundName, connection.OutboundName)
+	if err != nil {
+		return nil, err
+	}
+	return entities.NewConnectionHealth(connection, links, manager.StatusPolicy), nil
+}
+
+// updateIpRange replaces the IP range assigned to a connection. An empty range allocates a new one from the pool.
+func (manager *Manager) updateIpRange(connection *entities.ConnectionInstance, ipRange string) (string, derrors.Error) {
+	current, err := manager.IPAM.GetOwnerRange(connection.OrganizationId, connection.ConnectionId)
+	if err != nil && err.Type() != derrors.NotFound {
+		return "", err
+	}
+	if current != nil && (ipRange == "" || ipRange == current.Cidr) {
+		return current.Cidr, nil
+	}
+	if err = manager.IPAM.ReleaseRanges(connection.OrganizationId, connection.ConnectionId); err != nil {
+		return "", err
+	}
+	var assigned *entities.IPRange
+	if ipRange == "" {
+		assigned, err = manager.IPAM.AllocateRange(connection.OrganizationId, connection.ConnectionId)
+	} else {
+		assigned, err = manager.IPAM.RegisterRange(connection.OrganizationId, connection.ConnectionId, ipRange)
+	}
+	if err != nil {
+		return "", err
+	}
+	return assigned.Cidr, nil
+}
+
 func (manager *Manager) ExistsConnectionInstance(connId *grpc_application_network_go.ConnectionInstanceId) (bool, derrors.Error) {
 	return manager.AppNetProvider.ExistsConnectionInstance(connId.OrganizationId, connId.SourceInstanceId, connId.TargetInstanceId, connId.InboundName, connId.OutboundName)
 }
@@ -158,14 +347,18 @@ func (manager *Manager) RemoveConnectionInstance(removeConnectionRequest *grpc_a
 		return derrors.NewGenericError("outbound connection is required but user did not grant confirmation")
 	}
 
-	return manager.AppNetProvider.RemoveConnectionInstance(
+	err = manager.AppNetProvider.RemoveConnectionInstance(
 		removeConnectionRequest.OrganizationId,
 		removeConnectionRequest.SourceInstanceId,
 		removeConnectionRequest.TargetInstanceId,
 		removeConnectionRequest.InboundName,
 		removeConnectionRequest.OutboundName,
 	)
-
+	if err != nil {
+		return err
+	}
+	manager.Quota.Release(conn.OrganizationId, entities.AppNetworkQuota, 1)
+	return manager.IPAM.ReleaseRanges(conn.OrganizationId, conn.ConnectionId)
 }
 
 func (manager *Manager) GetConnectionByZtNetworkId(request *grpc_application_network_go.ZTNetworkId) (*entities.ConnectionInstance, derrors.Error) {
@@ -200,6 +393,37 @@ func (manager *Manager) ListConnectionInstances(organizationId *grpc_organizatio
 	return listConnectionInstances, nil
 }
 
+// GetConnectionGraph builds the graph of the connections between the instances of an organization, reporting required
+// outbounds without connections, connections to interfaces or instances that no longer exist, and cycles.
+func (manager *Manager) GetConnectionGraph(organizationId *grpc_organization_go.OrganizationId) (*topology.Graph, derrors.Error) {
+	if err := manager.validOrganization(organizationId.OrganizationId); err != nil {
+		return nil, err
+	}
+
+	instanceIds, err := manager.OrganizationProvider.ListInstances(organizationId.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]entities.AppInstance, 0, len(instanceIds))
+	for _, instanceId := range instanceIds {
+		instance, err := manager.ApplicationProvider.GetInstance(instanceId)
+		if err != nil {
+			if err.Type() == derrors.NotFound {
+				log.Warn().Str("instance", instanceId).Msg("instance not found while building the connection graph")
+				continue
+			}
+			return nil, err
+		}
+		instances = append(instances, *instance)
+	}
+
+	connections, err := manager.AppNetProvider.ListConnectionInstances(organizationId.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	return topology.NewGraph(organizationId.OrganizationId, instances, connections), nil
+}
+
 // validOrganization check if the organization ID corresponds to an existing organization
 func (manager *Manager) validOrganization(orgID string) derrors.Error {
 	exists, err := manager.OrganizationProvider.Exists(orgID)
@@ -299,13 +523,79 @@ func (manager *Manager) AddZTNetworkConnection(addRequest *grpc_application_netw
 	}
 
 	toAdd := entities.NewZTNetworkConnectionFromGRPC(addRequest)
+
+	ipRange, err := manager.ztNetworkRange(toAdd.OrganizationId, toAdd.ZtNetworkId)
+	if err != nil {
+		return nil, err
+	}
+	if ipRange != "" {
+		var address *entities.IPAddress
+		if toAdd.ZtIp == "" {
+			address, err = manager.IPAM.AllocateAddress(toAdd.OrganizationId, ipRange, ztAddressOwner(toAdd))
+		} else {
+			address, err = manager.IPAM.RegisterAddress(toAdd.OrganizationId, ipRange, toAdd.ZtIp, ztAddressOwner(toAdd))
+		}
+		if err != nil {
+			return nil, err
+		}
+		toAdd.ZtIp = address.Ip
+	}
+
 	err = manager.AppNetProvider.AddZTConnection(*toAdd)
 	if err != nil {
+		if ipRange != "" {
+			if rErr := manager.IPAM.ReleaseAddress(toAdd.OrganizationId, ipRange, toAdd.ZtIp); rErr != nil {
+				log.Warn().Str("trace", rErr.DebugReport()).Str("ztIp", toAdd.ZtIp).Msg("cannot release the address of the zt connection")
+			}
+		}
 		return nil, err
 	}
 	return toAdd, nil
 }
 
+// ztNetworkRange returns the IP range of the connection that owns a zt network, or an empty string if the network
+// is not linked to a connection.
+func (manager *Manager) ztNetworkRange(organizationId string, ztNetworkId string) (string, derrors.Error) {
+	list, err := manager.AppNetProvider.GetConnectionByZtNetworkId(ztNetworkId)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	for _, conn := range list {
+		if conn.OrganizationId == organizationId {
+			return conn.IpRange, nil
+		}
+	}
+	return "", nil
+}
+
+// ztAddressOwner returns the identifier of the owner of the address of a zt connection.
+func ztAddressOwner(conn *entities.ZTNetworkConnection) string {
+	return fmt.Sprintf("%s/%s/%s", conn.AppInstanceId, conn.ServiceId, conn.ClusterId)
+}
+
+// releaseZTAddresses releases the addresses of a set of zt connections.
+func (manager *Manager) releaseZTAddresses(connections []entities.ZTNetworkConnection) derrors.Error {
+	for _, conn := range connections {
+		if conn.ZtIp == "" {
+			continue
+		}
+		ipRange, err := manager.ztNetworkRange(conn.OrganizationId, conn.ZtNetworkId)
+		if err != nil {
+			return err
+		}
+		if ipRange == "" {
+			continue
+		}
+		if err = manager.IPAM.ReleaseAddress(conn.OrganizationId, ipRange, conn.ZtIp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ListZTNetworkConnection lists the connections in one zt network (one inbound and one outbound)
 func (manager *Manager) ListZTNetworkConnection(ztNetworkId *grpc_application_network_go.ZTNetworkId) ([]entities.ZTNetworkConnection, derrors.Error) {
 	// check if the organization exists
@@ -338,6 +628,25 @@ func (manager *Manager) UpdateZTNetworkConnection(updateRequest *grpc_applicatio
 	if err != nil {
 		return err
 	}
+	if updateRequest.UpdateZtIp && updateRequest.ZtIp != conn.ZtIp {
+		ipRange, err := manager.ztNetworkRange(conn.OrganizationId, conn.ZtNetworkId)
+		if err != nil {
+			return err
+		}
+		if ipRange != "" {
+			if updateRequest.ZtIp != "" {
+				_, err = manager.IPAM.RegisterAddress(conn.OrganizationId, ipRange, updateRequest.ZtIp, ztAddressOwner(conn))
+				if err != nil {
+					return err
+				}
+			}
+			if conn.ZtIp != "" {
+				if err = manager.IPAM.ReleaseAddress(conn.OrganizationId, ipRange, conn.ZtIp); err != nil {
+					return err
+				}
+			}
+		}
+	}
 	conn.ApplyUpdate(updateRequest)
 
 	return manager.AppNetProvider.UpdateZTConnection(*conn)
@@ -352,7 +661,15 @@ func (manager *Manager) RemoveZTNetworkConnection(connection *grpc_application_n
 		return err
 	}
 
-	return manager.AppNetProvider.RemoveZTConnection(connection.OrganizationId, connection.ZtNetworkId, connection.AppInstanceId, connection.ServiceId, connection.ClusterId)
+	conn, err := manager.AppNetProvider.GetZTConnection(connection.OrganizationId, connection.ZtNetworkId, connection.AppInstanceId, connection.ServiceId, connection.ClusterId)
+	if err != nil {
+		return err
+	}
+	err = manager.AppNetProvider.RemoveZTConnection(connection.OrganizationId, connection.ZtNetworkId, connection.AppInstanceId, connection.ServiceId, connection.ClusterId)
+	if err != nil {
+		return err
+	}
+	return manager.releaseZTAddresses([]entities.ZTNetworkConnection{*conn})
 
 }
 
@@ -363,5 +680,13 @@ func (manager *Manager) RemoveZTNetworkConnectionByNetworkId(networkId *grpc_app
 		return err
 	}
 
-	return manager.AppNetProvider.RemoveZTConnectionByNetworkId(networkId.OrganizationId, networkId.ZtNetworkId)
+	list, err := manager.AppNetProvider.ListZTConnections(networkId.OrganizationId, networkId.ZtNetworkId)
+	if err != nil {
+		return err
+	}
+	err = manager.AppNetProvider.RemoveZTConnectionByNetworkId(networkId.OrganizationId, networkId.ZtNetworkId)
+	if err != nil {
+		return err
+	}
+	return manager.releaseZTAddresses(list)
 }
@@ -0,0 +1,286 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package application_network
+
+import (
+	"github.com/nalej/grpc-application-network-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/application"
+	"github.com/nalej/system-model/internal/pkg/provider/application_network"
+	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
+	"github.com/nalej/system-model/internal/pkg/topology"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Application network manager", func() {
+
+	var manager Manager
+	var organizationProvider organization.Provider
+	var applicationProvider application.Provider
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider = organization.NewMockupOrganizationProvider()
+		applicationProvider = application.NewMockupApplicationProvider()
+		manager = NewManager(organizationProvider, applicationProvider, application_network.NewMockupApplicationNetworkProvider(),
+			newIPAMManager(organizationProvider), entities.DefaultConnectionStatusPolicy,
+			testhelpers.NewQuotaManager(organizationProvider))
+	})
+
+	ginkgo.Context("building the connection graph", func() {
+		ginkgo.It("should report the required outbounds without connections", func() {
+			org := addOrganization(organizationProvider)
+			source := addSourceInstance(org.ID, true, applicationProvider)
+			target := addTargetInstance(org.ID, applicationProvider)
+			gomega.Expect(organizationProvider.AddInstance(org.ID, source.AppInstanceId)).To(gomega.Succeed())
+			gomega.Expect(organizationProvider.AddInstance(org.ID, target.AppInstanceId)).To(gomega.Succeed())
+
+			graph, err := manager.GetConnectionGraph(&grpc_organization_go.OrganizationId{OrganizationId: org.ID})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(graph.Nodes)).Should(gomega.Equal(2))
+			gomega.Expect(graph.Issues).Should(gomega.Equal([]topology.Issue{{
+				Type:          topology.MissingRequiredOutbound,
+				AppInstanceId: source.AppInstanceId,
+				InterfaceName: "source-outbound",
+			}}))
+
+			_, err = manager.AddConnectionInstance(&grpc_application_network_go.AddConnectionRequest{
+				OrganizationId:   org.ID,
+				SourceInstanceId: source.AppInstanceId,
+				TargetInstanceId: target.AppInstanceId,
+				InboundName:      "target-inbound",
+				OutboundName:     "source-outbound",
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+
+			graph, err = manager.GetConnectionGraph(&grpc_organization_go.OrganizationId{OrganizationId: org.ID})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(graph.Edges)).Should(gomega.Equal(1))
+			gomega.Expect(graph.Valid()).Should(gomega.BeTrue())
+		})
+		ginkgo.It("should fail on an unknown organization", func() {
+			_, err := manager.GetConnectionGraph(&grpc_organization_go.OrganizationId{OrganizationId: entities.GenerateUUID()})
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+	})
+	ginkgo.Context("managing ip ranges", func() {
+		addConnection := func(organizationID string, ipRange string) (*entities.ConnectionInstance, error) {
+			source := addSourceInstance(organizationID, false, applicationProvider)
+			target := addTargetInstance(organizationID, applicationProvider)
+			conn, err := manager.AddConnectionInstance(&grpc_application_network_go.AddConnectionRequest{
+				OrganizationId:   organizationID,
+				SourceInstanceId: source.AppInstanceId,
+				TargetInstanceId: target.AppInstanceId,
+				InboundName:      "target-inbound",
+				OutboundName:     "source-outbound",
+				IpRange:          ipRange,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return conn, nil
+		}
+
+		ginkgo.It("should allocate non overlapping ranges to the connections", func() {
+			org := addOrganization(organizationProvider)
+			first, err := addConnection(org.ID, "")
+			gomega.Expect(err).To(gomega.Succeed())
+			second, err := addConnection(org.ID, "")
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(first.IpRange).Should(gomega.Equal("10.192.0.0/24"))
+			gomega.Expect(second.IpRange).Should(gomega.Equal("10.192.1.0/24"))
+
+			_, err = addConnection(org.ID, "10.192.0.128/25")
+			gomega.Expect(err).NotTo(gomega.Succeed())
+
+			usage, err := manager.IPAM.GetUsage(&grpc_organization_go.OrganizationId{OrganizationId: org.ID})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(usage.Ranges)).Should(gomega.Equal(2))
+		})
+		ginkgo.It("should release the range when the connection is removed", func() {
+			org := addOrganization(organizationProvider)
+			conn, err := addConnection(org.ID, "")
+			gomega.Expect(err).To(gomega.Succeed())
+
+			err = manager.RemoveConnectionInstance(&grpc_application_network_go.RemoveConnectionRequest{
+				OrganizationId:   org.ID,
+				SourceInstanceId: conn.SourceInstanceId,
+				TargetInstanceId: conn.TargetInstanceId,
+				InboundName:      conn.InboundName,
+				OutboundName:     conn.OutboundName,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+
+			usage, err := manager.IPAM.GetUsage(&grpc_organization_go.OrganizationId{OrganizationId: org.ID})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(usage.Ranges).Should(gomega.BeEmpty())
+
+			_, err = addConnection(org.ID, conn.IpRange)
+			gomega.Expect(err).To(gomega.Succeed())
+		})
+		ginkgo.It("should assign the addresses of the zt members from the range of the connection", func() {
+			org := addOrganization(organizationProvider)
+			conn, err := addConnection(org.ID, "")
+			gomega.Expect(err).To(gomega.Succeed())
+			err = manager.UpdateConnectionInstance(&grpc_application_network_go.UpdateConnectionRequest{
+				OrganizationId:    org.ID,
+				SourceInstanceId:  conn.SourceInstanceId,
+				TargetInstanceId:  conn.TargetInstanceId,
+				InboundName:       conn.InboundName,
+				OutboundName:      conn.OutboundName,
+				UpdateZtNetworkId: true,
+				ZtNetworkId:       entities.GenerateUUID(),
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+			conn, err = manager.GetConnectionInstance(&grpc_application_network_go.ConnectionInstanceId{
+				OrganizationId:   org.ID,
+				SourceInstanceId: conn.SourceInstanceId,
+				TargetInstanceId: conn.TargetInstanceId,
+				InboundName:      conn.InboundName,
+				OutboundName:     conn.OutboundName,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+
+			instance := addInstance(org.ID, applicationProvider)
+			member := &grpc_application_network_go.ZTNetworkConnection{
+				OrganizationId: org.ID,
+				ZtNetworkId:    conn.ZtNetworkId,
+				AppInstanceId:  instance.AppInstanceId,
+				ServiceId:      instance.Groups[0].ServiceInstances[0].ServiceId,
+				ZtMember:       entities.GenerateUUID(),
+				ClusterId:      entities.GenerateUUID(),
+				Side:           grpc_application_network_go.ConnectionSide_SIDE_OUTBOUND,
+			}
+			added, err := manager.AddZTNetworkConnection(member)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(added.ZtIp).Should(gomega.Equal("10.192.0.1"))
+
+			member.ClusterId = entities.GenerateUUID()
+			member.ZtIp = added.ZtIp
+			_, err = manager.AddZTNetworkConnection(member)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+
+			err = manager.RemoveZTNetworkConnectionByNetworkId(&grpc_application_network_go.ZTNetworkId{
+				OrganizationId: org.ID,
+				ZtNetworkId:    conn.ZtNetworkId,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+			usage, err := manager.IPAM.GetUsage(&grpc_organization_go.OrganizationId{OrganizationId: org.ID})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(usage.Ranges[0].Allocated).Should(gomega.Equal(int64(0)))
+		})
+	})
+	ginkgo.Context("aggregating the connection status", func() {
+		var org entities.Organization
+		var conn *entities.ConnectionInstance
+		var links []entities.ConnectionInstanceLink
+
+		connectionId := func() *grpc_application_network_go.ConnectionInstanceId {
+			return &grpc_application_network_go.ConnectionInstanceId{
+				OrganizationId:   conn.OrganizationId,
+				SourceInstanceId: conn.SourceInstanceId,
+				TargetInstanceId: conn.TargetInstanceId,
+				InboundName:      conn.InboundName,
+				OutboundName:     conn.OutboundName,
+			}
+		}
+		setLinkStatus := func(index int, status entities.ConnectionStatus) {
+			links[index].Status = status
+			gomega.Expect(manager.UpdateConnectionInstanceLink(links[index])).To(gomega.Succeed())
+		}
+		connectionStatus := func() entities.ConnectionStatus {
+			retrieved, err := manager.GetConnectionInstance(connectionId())
+			gomega.Expect(err).To(gomega.Succeed())
+			return retrieved.Status
+		}
+
+		ginkgo.BeforeEach(func() {
+			org = addOrganization(organizationProvider)
+			source := addSourceInstance(org.ID, false, applicationProvider)
+			target := addTargetInstance(org.ID, applicationProvider)
+			var err error
+			conn, err = manager.AddConnectionInstance(&grpc_application_network_go.AddConnectionRequest{
+				OrganizationId:   org.ID,
+				SourceInstanceId: source.AppInstanceId,
+				TargetInstanceId: target.AppInstanceId,
+				InboundName:      "target-inbound",
+				OutboundName:     "source-outbound",
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			links = make([]entities.ConnectionInstanceLink, 0)
+			for i := 0; i < 2; i++ {
+				link := entities.ConnectionInstanceLink{
+					OrganizationId:   org.ID,
+					SourceInstanceId: conn.SourceInstanceId,
+					SourceClusterId:  entities.GenerateUUID(),
+					TargetInstanceId: conn.TargetInstanceId,
+					TargetClusterId:  entities.GenerateUUID(),
+					InboundName:      conn.InboundName,
+					OutboundName:     conn.OutboundName,
+				}
+				gomega.Expect(manager.AddConnectionInstanceLink(link)).To(gomega.Succeed())
+				links = append(links, link)
+			}
+		})
+
+		ginkgo.It("should be established only when all the links are established", func() {
+			gomega.Expect(connectionStatus()).Should(gomega.Equal(entities.ConnectionStatusWaiting))
+			setLinkStatus(0, entities.ConnectionStatusEstablished)
+			gomega.Expect(connectionStatus()).Should(gomega.Equal(entities.ConnectionStatusWaiting))
+			setLinkStatus(1, entities.ConnectionStatusEstablished)
+			gomega.Expect(connectionStatus()).Should(gomega.Equal(entities.ConnectionStatusEstablished))
+			setLinkStatus(1, entities.ConnectionStatusFailed)
+			gomega.Expect(connectionStatus()).Should(gomega.Equal(entities.ConnectionStatusFailed))
+		})
+		ginkgo.It("should apply the configured policy", func() {
+			manager.StatusPolicy = entities.ConnectionStatusPolicyAny
+			setLinkStatus(0, entities.ConnectionStatusFailed)
+			gomega.Expect(connectionStatus()).Should(gomega.Equal(entities.ConnectionStatusWaiting))
+			setLinkStatus(1, entities.ConnectionStatusEstablished)
+			gomega.Expect(connectionStatus()).Should(gomega.Equal(entities.ConnectionStatusEstablished))
+		})
+		ginkgo.It("should not report an established connection with broken links", func() {
+			setLinkStatus(0, entities.ConnectionStatusEstablished)
+			setLinkStatus(1, entities.ConnectionStatusFailed)
+			err := manager.UpdateConnectionInstance(&grpc_application_network_go.UpdateConnectionRequest{
+				OrganizationId:   conn.OrganizationId,
+				SourceInstanceId: conn.SourceInstanceId,
+				TargetInstanceId: conn.TargetInstanceId,
+				InboundName:      conn.InboundName,
+				OutboundName:     conn.OutboundName,
+				UpdateStatus:     true,
+				Status:           grpc_application_network_go.ConnectionStatus_ESTABLISHED,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(connectionStatus()).Should(gomega.Equal(entities.ConnectionStatusFailed))
+
+			health, err := manager.GetConnectionHealth(connectionId())
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(health.Links).Should(gomega.Equal(2))
+			gomega.Expect(health.Established).Should(gomega.Equal(1))
+			gomega.Expect(health.Failed).Should(gomega.Equal(1))
+			gomega.Expect(health.UnhealthyLinks).Should(gomega.HaveLen(1))
+			gomega.Expect(health.Consistent()).Should(gomega.BeTrue())
+
+			list, err := manager.ListConnectionHealth(&grpc_organization_go.OrganizationId{OrganizationId: org.ID})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(list).Should(gomega.HaveLen(1))
+		})
+	})
+})
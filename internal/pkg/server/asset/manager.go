@@ -23,6 +23,7 @@ import (
 	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/provider/asset"
 	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/utils"
 )
 
 type Manager struct {
@@ -107,19 +108,28 @@ func (m *Manager) Remove(assetID *grpc_inventory_go.AssetId) derrors.Error {
 
 // Update the information of an asset.
 func (m *Manager) Update(updateRequest *grpc_inventory_go.UpdateAssetRequest) (*entities.Asset, derrors.Error) {
-	asset, err := m.AssetProvider.Get(updateRequest.AssetId)
+	var updated *entities.Asset
+	err := utils.RetryOnConflict(func() derrors.Error {
+		asset, err := m.AssetProvider.Get(updateRequest.AssetId)
+		if err != nil {
+			return err
+		}
+		if asset.OrganizationId != updateRequest.OrganizationId {
+			return derrors.NewNotFoundError("organization_id & asset_id").WithParams(updateRequest.OrganizationId, updateRequest.AssetId)
+		}
+		asset.ApplyUpdate(updateRequest)
+		err = m.AssetProvider.Update(*asset)
+		if err != nil {
+			return err
+		}
+		asset.Version++
+		updated = asset
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	if asset.OrganizationId != updateRequest.OrganizationId {
-		return nil, derrors.NewNotFoundError("organization_id & asset_id").WithParams(updateRequest.OrganizationId, updateRequest.AssetId)
-	}
-	asset.ApplyUpdate(updateRequest)
-	err = m.AssetProvider.Update(*asset)
-	if err != nil {
-		return nil, err
-	}
-	return asset, nil
+	return updated, nil
 }
 
 func (m *Manager) ListControllerAssets(edgeControllerId *grpc_inventory_go.EdgeControllerId) ([]entities.Asset, derrors.Error) {
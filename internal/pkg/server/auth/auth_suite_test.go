@@ -0,0 +1,59 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"testing"
+)
+
+func TestAuthPackage(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Auth package suite")
+}
+
+// encodeSegment encodes a JSON segment of a token.
+func encodeSegment(content interface{}) string {
+	raw, err := json.Marshal(content)
+	gomega.Expect(err).To(gomega.Succeed())
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// createHS256Token creates a token signed with a shared secret.
+func createHS256Token(secret []byte, claims map[string]interface{}) string {
+	signed := encodeSegment(map[string]string{"alg": string(HS256), "typ": "JWT"}) + "." + encodeSegment(claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// createRS256Token creates a token signed with an RSA private key.
+func createRS256Token(key *rsa.PrivateKey, claims map[string]interface{}) string {
+	signed := encodeSegment(map[string]string{"alg": string(RS256), "typ": "JWT"}) + "." + encodeSegment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	gomega.Expect(err).To(gomega.Succeed())
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
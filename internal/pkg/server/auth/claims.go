@@ -0,0 +1,63 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package auth contains the authentication and tenant isolation of the gRPC server.
+package auth
+
+import (
+	"context"
+)
+
+// SystemScope grants access to the resources of all the organizations.
+const SystemScope = "system"
+
+// Claims with the verified identity of the caller of a request.
+type Claims struct {
+	// Subject identifying the caller.
+	Subject string `json:"subject,omitempty"`
+	// OrganizationId the caller belongs to.
+	OrganizationId string `json:"organization_id,omitempty"`
+	// Scopes granted to the caller.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// HasScope checks if a scope has been granted to the caller.
+func (c *Claims) HasScope(scope string) bool {
+	for _, granted := range c.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSystem checks if the caller can access the resources of all the organizations.
+func (c *Claims) IsSystem() bool {
+	return c.HasScope(SystemScope)
+}
+
+type claimsKey struct{}
+
+// NewContext returns a context with the claims of the caller.
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext retrieves the claims of the caller, if the request was authenticated.
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// AuthorizationHeader with the metadata key containing the bearer token.
+const AuthorizationHeader = "authorization"
+
+const bearerPrefix = "bearer "
+
+// reflectionPrefix with the prefix of the methods of the gRPC reflection service.
+const reflectionPrefix = "/grpc.reflection."
+
+// Authenticator verifies the identity of the callers and the organization of their requests.
+type Authenticator struct {
+	// Verifier of the bearer tokens, if tokens are required.
+	Verifier *JWTVerifier
+	// ClientCertificates is set if the callers are identified by their TLS client certificates.
+	ClientCertificates bool
+	// UnauthenticatedReflection is set if the gRPC reflection service can be used without authentication.
+	UnauthenticatedReflection bool
+}
+
+// NewAuthenticator creates an Authenticator. If a verifier is set, callers must present a valid bearer token;
+// otherwise they are identified by their client certificate. The reflection service is authenticated as any other
+// service unless unauthenticatedReflection is set.
+func NewAuthenticator(verifier *JWTVerifier, clientCertificates bool, unauthenticatedReflection bool) *Authenticator {
+	return &Authenticator{Verifier: verifier, ClientCertificates: clientCertificates, UnauthenticatedReflection: unauthenticatedReflection}
+}
+
+// authenticate retrieves the claims of the caller of a request.
+func (a *Authenticator) authenticate(ctx context.Context) (*Claims, error) {
+	if a.Verifier != nil {
+		token, err := bearerToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		claims, vErr := a.Verifier.Verify(token)
+		if vErr != nil {
+			log.Debug().Str("trace", vErr.DebugReport()).Msg("invalid token")
+			return nil, status.Error(codes.Unauthenticated, vErr.Error())
+		}
+		return claims, nil
+	}
+	if a.ClientCertificates {
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "peer not found")
+		}
+		tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+		if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "client certificate required")
+		}
+		return ClaimsFromCertificate(tlsInfo.State.VerifiedChains[0][0]), nil
+	}
+	return nil, status.Error(codes.Unauthenticated, "no authentication method configured")
+}
+
+// bearerToken retrieves the bearer token of the request metadata.
+func bearerToken(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "metadata not found")
+	}
+	values := md.Get(AuthorizationHeader)
+	if len(values) == 0 {
+		return "", status.Error(codes.Unauthenticated, "bearer token required")
+	}
+	if !strings.HasPrefix(strings.ToLower(values[0]), bearerPrefix) {
+		return "", status.Error(codes.Unauthenticated, "authorization is not a bearer token")
+	}
+	return strings.TrimSpace(values[0][len(bearerPrefix):]), nil
+}
+
+// checkTenant applies the tenant guard to a request.
+func checkTenant(claims *Claims, method string, request interface{}) error {
+	if err := CheckTenant(claims, request); err != nil {
+		log.Warn().Str("subject", claims.Subject).Str("organizationID", claims.OrganizationId).
+			Str("method", method).Msg(err.Error())
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
+
+// requiresAuthentication checks if the callers of a method must be authenticated.
+func (a *Authenticator) requiresAuthentication(method string) bool {
+	return !a.UnauthenticatedReflection || !strings.HasPrefix(method, reflectionPrefix)
+}
+
+// UnaryInterceptor authenticates the unary calls, checks the organization of the request and places the claims of
+// the caller in the context.
+func (a *Authenticator) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !a.requiresAuthentication(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		claims, err := a.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkTenant(claims, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(NewContext(ctx, claims), req)
+	}
+}
+
+// guardedStream checks the organization of each message received in a stream.
+type guardedStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	claims *Claims
+	method string
+}
+
+func (s *guardedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *guardedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return checkTenant(s.claims, s.method, m)
+}
+
+// StreamInterceptor authenticates the streaming calls, checks the organization of the received messages and
+// places the claims of the caller in the context.
+func (a *Authenticator) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !a.requiresAuthentication(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		claims, err := a.authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &guardedStream{ServerStream: ss, ctx: NewContext(ss.Context(), claims), claims: claims, method: info.FullMethod})
+	}
+}
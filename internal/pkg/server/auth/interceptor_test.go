@@ -0,0 +1,123 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"context"
+	"github.com/nalej/grpc-common-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+var _ = ginkgo.Describe("Authentication interceptor", func() {
+
+	var secret = []byte("secret")
+	var interceptor grpc.UnaryServerInterceptor
+	var info = &grpc.UnaryServerInfo{FullMethod: "/organization.Organizations/GetOrganization"}
+
+	token := func(organizationID string, scopes ...string) string {
+		return createHS256Token(secret, map[string]interface{}{
+			"sub":             "user@nalej.com",
+			"exp":             time.Now().Add(time.Hour).Unix(),
+			"organization_id": organizationID,
+			"scopes":          scopes,
+		})
+	}
+
+	call := func(authorization string, request interface{}) (*Claims, error) {
+		ctx := context.Background()
+		if authorization != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(AuthorizationHeader, authorization))
+		}
+		var received *Claims
+		_, err := interceptor(ctx, request, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			received, _ = FromContext(ctx)
+			return nil, nil
+		})
+		return received, err
+	}
+
+	ginkgo.BeforeEach(func() {
+		interceptor = NewAuthenticator(NewHMACVerifier(secret, "", ""), false, false).UnaryInterceptor()
+	})
+
+	ginkgo.It("should place the claims of the caller in the context", func() {
+		claims, err := call("Bearer "+token("org1"), &grpc_organization_go.OrganizationId{OrganizationId: "org1"})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(claims).ShouldNot(gomega.BeNil())
+		gomega.Expect(claims.OrganizationId).Should(gomega.Equal("org1"))
+	})
+
+	ginkgo.It("should reject requests without a valid token", func() {
+		_, err := call("", &grpc_organization_go.OrganizationId{OrganizationId: "org1"})
+		gomega.Expect(status.Code(err)).Should(gomega.Equal(codes.Unauthenticated))
+
+		_, err = call("Bearer invalid", &grpc_organization_go.OrganizationId{OrganizationId: "org1"})
+		gomega.Expect(status.Code(err)).Should(gomega.Equal(codes.Unauthenticated))
+
+		_, err = call("Basic dXNlcjpwYXNz", &grpc_organization_go.OrganizationId{OrganizationId: "org1"})
+		gomega.Expect(status.Code(err)).Should(gomega.Equal(codes.Unauthenticated))
+	})
+
+	ginkgo.It("should reject requests of other organizations", func() {
+		_, err := call("Bearer "+token("org1"), &grpc_organization_go.OrganizationId{OrganizationId: "org2"})
+		gomega.Expect(status.Code(err)).Should(gomega.Equal(codes.PermissionDenied))
+	})
+
+	ginkgo.It("should reserve the requests without organization to the system scope", func() {
+		_, err := call("Bearer "+token("org1"), &grpc_common_go.Empty{})
+		gomega.Expect(status.Code(err)).Should(gomega.Equal(codes.PermissionDenied))
+
+		_, err = call("Bearer "+token("", SystemScope), &grpc_common_go.Empty{})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should allow the system scope to access any organization", func() {
+		_, err := call("Bearer "+token("org1", SystemScope), &grpc_organization_go.OrganizationId{OrganizationId: "org2"})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should authenticate the reflection service by default", func() {
+		reflectionInfo := &grpc.UnaryServerInfo{FullMethod: "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"}
+		_, err := interceptor(context.Background(), &grpc_common_go.Empty{}, reflectionInfo,
+			func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+		gomega.Expect(status.Code(err)).Should(gomega.Equal(codes.Unauthenticated))
+	})
+
+	ginkgo.It("should not authenticate the reflection service if it is allowed", func() {
+		interceptor = NewAuthenticator(NewHMACVerifier(secret, "", ""), false, true).UnaryInterceptor()
+		reflectionInfo := &grpc.UnaryServerInfo{FullMethod: "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"}
+		_, err := interceptor(context.Background(), &grpc_common_go.Empty{}, reflectionInfo,
+			func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+		gomega.Expect(err).To(gomega.Succeed())
+
+		_, err = call("", &grpc_organization_go.OrganizationId{OrganizationId: "org1"})
+		gomega.Expect(status.Code(err)).Should(gomega.Equal(codes.Unauthenticated))
+	})
+
+	ginkgo.It("should require a client certificate when tokens are not used", func() {
+		interceptor = NewAuthenticator(nil, true, false).UnaryInterceptor()
+		_, err := call("", &grpc_organization_go.OrganizationId{OrganizationId: "org1"})
+		gomega.Expect(status.Code(err)).Should(gomega.Equal(codes.Unauthenticated))
+	})
+})
@@ -0,0 +1,218 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/nalej/derrors"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// Algorithm used to sign the tokens.
+type Algorithm string
+
+const (
+	// HS256 with HMAC using SHA-256.
+	HS256 Algorithm = "HS256"
+	// RS256 with RSASSA-PKCS1-v1_5 using SHA-256.
+	RS256 Algorithm = "RS256"
+)
+
+// jwtHeader with the fields of the JOSE header that are checked.
+type jwtHeader struct {
+	Algorithm Algorithm `json:"alg"`
+}
+
+// jwtClaims with the registered and private claims supported in the tokens.
+type jwtClaims struct {
+	Subject        string          `json:"sub"`
+	Issuer         string          `json:"iss"`
+	Audience       json.RawMessage `json:"aud"`
+	ExpiresAt      int64           `json:"exp"`
+	NotBefore      int64           `json:"nbf"`
+	OrganizationId string          `json:"organization_id"`
+	Scopes         []string        `json:"scopes"`
+	// Scope with space separated scopes, as issued by OAuth 2.0 servers.
+	Scope string `json:"scope"`
+}
+
+// audiences returns the audiences of the token, that may be a single string or a list of them.
+func (c *jwtClaims) audiences() []string {
+	if len(c.Audience) == 0 {
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(c.Audience, &single); err == nil {
+		return []string{single}
+	}
+	var list []string
+	if err := json.Unmarshal(c.Audience, &list); err == nil {
+		return list
+	}
+	return nil
+}
+
+// JWTVerifier verifies the signature and the claims of JSON Web Tokens.
+type JWTVerifier struct {
+	algorithm Algorithm
+	secret    []byte
+	publicKey *rsa.PublicKey
+	// Issuer expected in the tokens, not checked if empty.
+	issuer string
+	// Audience expected in the tokens, not checked if empty.
+	audience string
+	now      func() time.Time
+}
+
+// NewHMACVerifier creates a verifier of tokens signed with a shared secret.
+func NewHMACVerifier(secret []byte, issuer string, audience string) *JWTVerifier {
+	return &JWTVerifier{algorithm: HS256, secret: secret, issuer: issuer, audience: audience, now: time.Now}
+}
+
+// NewRSAVerifier creates a verifier of tokens signed with an RSA private key.
+func NewRSAVerifier(publicKey *rsa.PublicKey, issuer string, audience string) *JWTVerifier {
+	return &JWTVerifier{algorithm: RS256, publicKey: publicKey, issuer: issuer, audience: audience, now: time.Now}
+}
+
+// LoadRSAPublicKey reads a PEM encoded RSA public key or certificate.
+func LoadRSAPublicKey(path string) (*rsa.PublicKey, derrors.Error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot read public key")
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, derrors.NewInvalidArgumentError("public key is not PEM encoded").WithParams(path)
+	}
+	var key interface{}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, derrors.AsError(err, "cannot parse certificate")
+		}
+		key = cert.PublicKey
+	} else {
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, derrors.AsError(err, "cannot parse public key")
+		}
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, derrors.NewInvalidArgumentError("public key is not an RSA key").WithParams(path)
+	}
+	return rsaKey, nil
+}
+
+// Verify checks the signature, expiration, issuer and audience of a token, returning its claims.
+func (v *JWTVerifier) Verify(token string) (*Claims, derrors.Error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, derrors.NewInvalidArgumentError("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Algorithm != v.algorithm {
+		return nil, derrors.NewInvalidArgumentError("unexpected token algorithm").WithParams(header.Algorithm)
+	}
+	signature, sErr := base64.RawURLEncoding.DecodeString(parts[2])
+	if sErr != nil {
+		return nil, derrors.NewInvalidArgumentError("malformed token signature")
+	}
+	if err := v.verifySignature(parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	now := v.now().Unix()
+	if claims.ExpiresAt == 0 || now >= claims.ExpiresAt {
+		return nil, derrors.NewInvalidArgumentError("token expired")
+	}
+	if claims.NotBefore != 0 && now < claims.NotBefore {
+		return nil, derrors.NewInvalidArgumentError("token not valid yet")
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return nil, derrors.NewInvalidArgumentError("unexpected token issuer").WithParams(claims.Issuer)
+	}
+	if v.audience != "" && !contains(claims.audiences(), v.audience) {
+		return nil, derrors.NewInvalidArgumentError("token not issued for this audience")
+	}
+	if claims.Subject == "" {
+		return nil, derrors.NewInvalidArgumentError("token without subject")
+	}
+
+	scopes := claims.Scopes
+	if claims.Scope != "" {
+		scopes = append(scopes, strings.Fields(claims.Scope)...)
+	}
+	return &Claims{Subject: claims.Subject, OrganizationId: claims.OrganizationId, Scopes: scopes}, nil
+}
+
+// verifySignature checks the signature of the signed part of a token.
+func (v *JWTVerifier) verifySignature(signed string, signature []byte) derrors.Error {
+	switch v.algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return derrors.NewInvalidArgumentError("invalid token signature")
+		}
+	case RS256:
+		digest := sha256.Sum256([]byte(signed))
+		if err := rsa.VerifyPKCS1v15(v.publicKey, crypto.SHA256, digest[:], signature); err != nil {
+			return derrors.NewInvalidArgumentError("invalid token signature")
+		}
+	default:
+		return derrors.NewInvalidArgumentError("unsupported token algorithm").WithParams(v.algorithm)
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token.
+func decodeSegment(segment string, target interface{}) derrors.Error {
+	content, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return derrors.NewInvalidArgumentError("malformed token segment")
+	}
+	if err := json.Unmarshal(content, target); err != nil {
+		return derrors.NewInvalidArgumentError("malformed token segment")
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, current := range values {
+		if current == value {
+			return true
+		}
+	}
+	return false
+}
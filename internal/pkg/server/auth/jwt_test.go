@@ -0,0 +1,120 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"time"
+)
+
+var _ = ginkgo.Describe("JWT verification", func() {
+
+	var secret = []byte("secret")
+	var now time.Time
+
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"sub":             "user@nalej.com",
+			"iss":             "nalej",
+			"aud":             "system-model",
+			"exp":             now.Add(time.Hour).Unix(),
+			"organization_id": "org1",
+			"scopes":          []string{"read"},
+			"scope":           "write profile",
+		}
+	}
+
+	newVerifier := func() *JWTVerifier {
+		verifier := NewHMACVerifier(secret, "nalej", "system-model")
+		verifier.now = func() time.Time { return now }
+		return verifier
+	}
+
+	ginkgo.BeforeEach(func() {
+		now = time.Unix(1600000000, 0)
+	})
+
+	ginkgo.It("should verify a valid HS256 token", func() {
+		claims, err := newVerifier().Verify(createHS256Token(secret, validClaims()))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(claims.Subject).Should(gomega.Equal("user@nalej.com"))
+		gomega.Expect(claims.OrganizationId).Should(gomega.Equal("org1"))
+		gomega.Expect(claims.Scopes).Should(gomega.ConsistOf("read", "write", "profile"))
+	})
+
+	ginkgo.It("should verify a valid RS256 token", func() {
+		key, rErr := rsa.GenerateKey(rand.Reader, 1024)
+		gomega.Expect(rErr).To(gomega.Succeed())
+		verifier := NewRSAVerifier(&key.PublicKey, "", "")
+		verifier.now = func() time.Time { return now }
+		claims, err := verifier.Verify(createRS256Token(key, validClaims()))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(claims.Subject).Should(gomega.Equal("user@nalej.com"))
+
+		_, err = newVerifier().Verify(createRS256Token(key, validClaims()))
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should reject tokens with an invalid signature", func() {
+		_, err := newVerifier().Verify(createHS256Token([]byte("other"), validClaims()))
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should reject expired tokens", func() {
+		claims := validClaims()
+		claims["exp"] = now.Add(-time.Minute).Unix()
+		_, err := newVerifier().Verify(createHS256Token(secret, claims))
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		delete(claims, "exp")
+		_, err = newVerifier().Verify(createHS256Token(secret, claims))
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should reject tokens not valid yet", func() {
+		claims := validClaims()
+		claims["nbf"] = now.Add(time.Minute).Unix()
+		_, err := newVerifier().Verify(createHS256Token(secret, claims))
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should check the issuer and the audience", func() {
+		claims := validClaims()
+		claims["iss"] = "other"
+		_, err := newVerifier().Verify(createHS256Token(secret, claims))
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		claims = validClaims()
+		claims["aud"] = []string{"other", "system-model"}
+		_, err = newVerifier().Verify(createHS256Token(secret, claims))
+		gomega.Expect(err).To(gomega.Succeed())
+
+		claims["aud"] = "other"
+		_, err = newVerifier().Verify(createHS256Token(secret, claims))
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should reject malformed tokens", func() {
+		_, err := newVerifier().Verify("not.a-token")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		_, err = newVerifier().Verify("a.b.c")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
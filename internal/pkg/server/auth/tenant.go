@@ -0,0 +1,46 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"github.com/nalej/derrors"
+)
+
+// organizationRequest is implemented by the requests that refer to an organization.
+type organizationRequest interface {
+	GetOrganizationId() string
+}
+
+// CheckTenant checks that a caller can access the organization of a request. Callers with the system scope can
+// access all the organizations, while the rest can only access the organization of their claims. Requests that do
+// not refer to an organization are reserved to the system scope.
+func CheckTenant(claims *Claims, request interface{}) derrors.Error {
+	if claims.IsSystem() {
+		return nil
+	}
+	organizationID := ""
+	if withOrganization, ok := request.(organizationRequest); ok {
+		organizationID = withOrganization.GetOrganizationId()
+	}
+	if organizationID == "" {
+		return derrors.NewInvalidArgumentError("request requires the system scope")
+	}
+	if claims.OrganizationId == "" || organizationID != claims.OrganizationId {
+		return derrors.NewInvalidArgumentError("organization not accessible").WithParams(organizationID)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/nalej/derrors"
+	"io/ioutil"
+)
+
+// ServerTLSConfig creates the TLS configuration of the server. If a client CA is set, clients must present a
+// certificate signed by it.
+func ServerTLSConfig(certFile string, keyFile string, clientCAFile string) (*tls.Config, derrors.Error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot load server certificate")
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCAFile == "" {
+		return config, nil
+	}
+	content, err := ioutil.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot read client CA")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, derrors.NewInvalidArgumentError("client CA does not contain PEM certificates").WithParams(clientCAFile)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
+// ClaimsFromCertificate extracts the claims of a verified client certificate. The subject is the common name, the
+// organization is the first organization of the certificate subject, and the scopes are its organizational units.
+func ClaimsFromCertificate(cert *x509.Certificate) *Claims {
+	claims := &Claims{Subject: cert.Subject.CommonName, Scopes: cert.Subject.OrganizationalUnit}
+	if len(cert.Subject.Organization) > 0 {
+		claims.OrganizationId = cert.Subject.Organization[0]
+	}
+	return claims
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	"github.com/nalej/system-model/internal/pkg/provider/catalog"
+	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/application"
+	"sort"
+)
+
+// Manager structure with the required providers for catalog operations.
+type Manager struct {
+	OrgProvider     organization.Provider
+	AppProvider     appProvider.Provider
+	CatalogProvider catalog.Provider
+	// Applications is used to add the instantiated descriptors so the quotas of the organization are enforced.
+	Applications application.Manager
+}
+
+// NewManager creates a Manager using a set of providers.
+func NewManager(orgProvider organization.Provider, applicationProvider appProvider.Provider, catalogProvider catalog.Provider,
+	applications application.Manager) Manager {
+	return Manager{orgProvider, applicationProvider, catalogProvider, applications}
+}
+
+func (m *Manager) checkOrganization(organizationID string) derrors.Error {
+	exists, err := m.OrgProvider.Exists(organizationID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("organizationID").WithParams(organizationID)
+	}
+	return nil
+}
+
+// latestVersion returns the most recently published version of a list of versions of the same entry.
+func latestVersion(versions []entities.CatalogEntry) *entities.CatalogEntry {
+	var latest *entities.CatalogEntry
+	for i := range versions {
+		if latest == nil || versions[i].IsNewerThan(*latest) {
+			latest = &versions[i]
+		}
+	}
+	return latest
+}
+
+// PublishDescriptor publishes the current contents of a descriptor as a new version of a catalog entry. Only the
+// organization that published the first version of an entry can publish new versions.
+func (m *Manager) PublishDescriptor(request entities.PublishCatalogEntryRequest) (*entities.CatalogEntry, derrors.Error) {
+	vErr := entities.ValidPublishCatalogEntryRequest(request)
+	if vErr != nil {
+		return nil, vErr
+	}
+	err := m.checkOrganization(request.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	for _, orgID := range request.VisibleOrganizations {
+		err = m.checkOrganization(orgID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	descriptor, err := m.AppProvider.GetDescriptor(request.AppDescriptorId)
+	if err != nil {
+		return nil, err
+	}
+	if descriptor.OrganizationId != request.OrganizationId {
+		return nil, derrors.NewNotFoundError("appDescriptorID").WithParams(request.OrganizationId, request.AppDescriptorId)
+	}
+
+	if request.CatalogEntryId != "" {
+		versions, err := m.CatalogProvider.ListVersions(request.CatalogEntryId)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			return nil, derrors.NewNotFoundError("catalog entry").WithParams(request.CatalogEntryId)
+		}
+		if versions[0].PublisherOrganizationId != request.OrganizationId {
+			return nil, derrors.NewPermissionDeniedError("only the publisher organization can add versions to a catalog entry").WithParams(request.OrganizationId, request.CatalogEntryId)
+		}
+	}
+
+	// the provider rejects the versions that already exist atomically, so concurrent publications of the same version
+	// do not overwrite each other
+	entry := entities.NewCatalogEntry(request, *descriptor)
+	err = m.CatalogProvider.Add(*entry)
+	if err != nil {
+		if err.Type() == derrors.AlreadyExists {
+			return nil, derrors.NewAlreadyExistsError("catalog entry version").WithParams(request.CatalogEntryId, request.Version)
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ListEntries retrieves the latest version of the catalog entries visible to an organization that match a search.
+// The result is sorted by name.
+func (m *Manager) ListEntries(search entities.CatalogSearchRequest) ([]entities.CatalogEntry, derrors.Error) {
+	vErr := entities.ValidCatalogSearchRequest(search)
+	if vErr != nil {
+		return nil, vErr
+	}
+	err := m.checkOrganization(search.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := m.CatalogProvider.List()
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[string][]entities.CatalogEntry, 0)
+	for _, entry := range all {
+		if entry.VisibleTo(search.OrganizationId) {
+			versions[entry.CatalogEntryId] = append(versions[entry.CatalogEntryId], entry)
+		}
+	}
+
+	result := make([]entities.CatalogEntry, 0)
+	for _, entryVersions := range versions {
+		latest := latestVersion(entryVersions)
+		if latest.Matches(search) {
+			result = append(result, *latest)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name == result[j].Name {
+			return result[i].CatalogEntryId < result[j].CatalogEntryId
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// ListEntryVersions retrieves the versions of a catalog entry visible to an organization, newest first.
+func (m *Manager) ListEntryVersions(organizationID string, catalogEntryID string) ([]entities.CatalogEntry, derrors.Error) {
+	err := m.checkOrganization(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := m.CatalogProvider.ListVersions(catalogEntryID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]entities.CatalogEntry, 0, len(versions))
+	for _, entry := range versions {
+		if entry.VisibleTo(organizationID) {
+			result = append(result, entry)
+		}
+	}
+	if len(result) == 0 {
+		return nil, derrors.NewNotFoundError("catalog entry").WithParams(catalogEntryID)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].IsNewerThan(result[j])
+	})
+	return result, nil
+}
+
+// GetEntry retrieves a version of a catalog entry visible to an organization. If no version is specified, the
+// latest one is returned. Entries that are not visible to the organization are reported as not found.
+func (m *Manager) GetEntry(organizationID string, catalogEntryID string, version string) (*entities.CatalogEntry, derrors.Error) {
+	if version == "" {
+		versions, err := m.ListEntryVersions(organizationID, catalogEntryID)
+		if err != nil {
+			return nil, err
+		}
+		return &versions[0], nil
+	}
+
+	err := m.checkOrganization(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := m.CatalogProvider.Get(catalogEntryID, version)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.VisibleTo(organizationID) {
+		return nil, derrors.NewNotFoundError("catalog entry").WithParams(catalogEntryID, version)
+	}
+	return entry, nil
+}
+
+// InstantiateEntry copies a catalog entry into the descriptors of an organization. The new descriptor receives new
+// identifiers for the descriptor, groups, services and rules.
+func (m *Manager) InstantiateEntry(request entities.InstantiateCatalogEntryRequest) (*entities.AppDescriptor, derrors.Error) {
+	vErr := entities.ValidInstantiateCatalogEntryRequest(request)
+	if vErr != nil {
+		return nil, vErr
+	}
+	entry, err := m.GetEntry(request.OrganizationId, request.CatalogEntryId, request.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	addRequest := entry.ToAddAppDescriptorRequest(entities.GenerateUUID(), request.OrganizationId, request.Name)
+	err = entities.ValidAddAppDescriptorRequest(addRequest)
+	if err != nil {
+		return nil, err
+	}
+	return m.Applications.AddAppDescriptor(addRequest)
+}
+
+// UnpublishEntry removes a version of a catalog entry. Descriptors already instantiated from it are not affected.
+func (m *Manager) UnpublishEntry(organizationID string, catalogEntryID string, version string) derrors.Error {
+	err := m.checkOrganization(organizationID)
+	if err != nil {
+		return err
+	}
+	entry, err := m.CatalogProvider.Get(catalogEntryID, version)
+	if err != nil {
+		return err
+	}
+	if entry.PublisherOrganizationId != organizationID {
+		return derrors.NewPermissionDeniedError("only the publisher organization can unpublish a catalog entry").WithParams(organizationID, catalogEntryID)
+	}
+	return m.CatalogProvider.Remove(catalogEntryID, version)
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	catProvider "github.com/nalej/system-model/internal/pkg/provider/catalog"
+	devProvider "github.com/nalej/system-model/internal/pkg/provider/device"
+	ipamProvider "github.com/nalej/system-model/internal/pkg/provider/ipam"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/application"
+	"github.com/nalej/system-model/internal/pkg/server/ipam"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Catalog manager", func() {
+
+	var organizationProvider orgProvider.Provider
+	var applicationProvider appProvider.Provider
+	var quotaManager quota.Manager
+	var manager Manager
+
+	var publisher *entities.Organization
+	var consumer *entities.Organization
+	var other *entities.Organization
+	var descriptor *entities.AppDescriptor
+
+	createPublishRequest := func(visibility entities.CatalogVisibility, visibleOrgs ...string) entities.PublishCatalogEntryRequest {
+		return entities.PublishCatalogEntryRequest{
+			OrganizationId:       publisher.ID,
+			AppDescriptorId:      descriptor.AppDescriptorId,
+			Version:              "1.0.0",
+			Description:          "Relational database",
+			Logo:                 "https://nalej.com/logo.png",
+			Category:             "database",
+			Visibility:           visibility,
+			VisibleOrganizations: visibleOrgs,
+		}
+	}
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider = orgProvider.NewMockupOrganizationProvider()
+		applicationProvider = appProvider.NewMockupApplicationProvider()
+		pool, err := cidr.ParsePool(cidr.DefaultPool, cidr.DefaultBlockPrefixLength)
+		gomega.Expect(err).To(gomega.Succeed())
+		quotaManager = testhelpers.NewQuotaManager(organizationProvider)
+		applications := application.NewManager(organizationProvider, applicationProvider, devProvider.NewMockupDeviceProvider(),
+			"nalej.cluster.local", ipam.NewManager(organizationProvider, ipamProvider.NewMockupIPAMProvider(), pool), quotaManager)
+		manager = NewManager(organizationProvider, applicationProvider, catProvider.NewMockupCatalogProvider(), applications)
+
+		publisher = testhelpers.AddOrganization(organizationProvider)
+		consumer = testhelpers.AddOrganization(organizationProvider)
+		other = testhelpers.AddOrganization(organizationProvider)
+
+		descriptor = appProvider.CreateTestApplicationDescriptor(publisher.ID)
+		descriptor.Groups[0].Services[0].Name = "mysql"
+		descriptor.Groups[0].Services[0].Credentials = &entities.ImageCredentials{Username: "user", Password: "secret"}
+		err = applicationProvider.AddDescriptor(*descriptor)
+		gomega.Expect(err).To(gomega.Succeed())
+		err = organizationProvider.AddDescriptor(publisher.ID, descriptor.AppDescriptorId)
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.Context("publishing", func() {
+		ginkgo.It("should publish a descriptor without its credentials", func() {
+			entry, err := manager.PublishDescriptor(createPublishRequest(entities.CatalogVisibility_All))
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(entry.CatalogEntryId).ShouldNot(gomega.BeEmpty())
+			gomega.Expect(entry.PublisherOrganizationId).Should(gomega.Equal(publisher.ID))
+			gomega.Expect(entry.Groups[0].Services[0].Credentials).Should(gomega.BeNil())
+
+			source, err := applicationProvider.GetDescriptor(descriptor.AppDescriptorId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(source.Groups[0].Services[0].Credentials).ShouldNot(gomega.BeNil())
+		})
+		ginkgo.It("should publish new versions of an entry", func() {
+			entry, err := manager.PublishDescriptor(createPublishRequest(entities.CatalogVisibility_All))
+			gomega.Expect(err).To(gomega.Succeed())
+
+			request := createPublishRequest(entities.CatalogVisibility_All)
+			request.CatalogEntryId = entry.CatalogEntryId
+			_, err = manager.PublishDescriptor(request)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+
+			request.Version = "1.1.0"
+			_, err = manager.PublishDescriptor(request)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			versions, err := manager.ListEntryVersions(consumer.ID, entry.CatalogEntryId)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(versions)).Should(gomega.Equal(2))
+		})
+		ginkgo.It("should not publish the descriptor of another organization", func() {
+			request := createPublishRequest(entities.CatalogVisibility_All)
+			request.OrganizationId = consumer.ID
+			_, err := manager.PublishDescriptor(request)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should require the visible organizations on selected visibility", func() {
+			_, err := manager.PublishDescriptor(createPublishRequest(entities.CatalogVisibility_Selected))
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+	})
+
+	ginkgo.Context("listing and searching", func() {
+		ginkgo.It("should only list the entries visible to an organization", func() {
+			_, err := manager.PublishDescriptor(createPublishRequest(entities.CatalogVisibility_Selected, consumer.ID))
+			gomega.Expect(err).To(gomega.Succeed())
+
+			entries, err := manager.ListEntries(entities.CatalogSearchRequest{OrganizationId: consumer.ID})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(entries)).Should(gomega.Equal(1))
+
+			entries, err = manager.ListEntries(entities.CatalogSearchRequest{OrganizationId: other.ID})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(entries).Should(gomega.BeEmpty())
+		})
+		ginkgo.It("should search by text and category", func() {
+			_, err := manager.PublishDescriptor(createPublishRequest(entities.CatalogVisibility_All))
+			gomega.Expect(err).To(gomega.Succeed())
+
+			entries, err := manager.ListEntries(entities.CatalogSearchRequest{OrganizationId: consumer.ID, Query: "RELATIONAL"})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(len(entries)).Should(gomega.Equal(1))
+
+			entries, err = manager.ListEntries(entities.CatalogSearchRequest{OrganizationId: consumer.ID, Category: "monitoring"})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(entries).Should(gomega.BeEmpty())
+		})
+	})
+
+	ginkgo.Context("instantiating", func() {
+		ginkgo.It("should copy an entry into the descriptors of an organization", func() {
+			entry, err := manager.PublishDescriptor(createPublishRequest(entities.CatalogVisibility_All))
+			gomega.Expect(err).To(gomega.Succeed())
+
+			added, err := manager.InstantiateEntry(entities.InstantiateCatalogEntryRequest{
+				OrganizationId: consumer.ID,
+				CatalogEntryId: entry.CatalogEntryId,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(added.OrganizationId).Should(gomega.Equal(consumer.ID))
+			gomega.Expect(added.AppDescriptorId).ShouldNot(gomega.Equal(descriptor.AppDescriptorId))
+			gomega.Expect(added.Groups[0].ServiceGroupId).ShouldNot(gomega.Equal(descriptor.Groups[0].ServiceGroupId))
+			gomega.Expect(added.Groups[0].Services[0].ServiceId).ShouldNot(gomega.Equal(descriptor.Groups[0].Services[0].ServiceId))
+			gomega.Expect(added.Groups[0].Services[0].Name).Should(gomega.Equal("mysql"))
+			gomega.Expect(added.Labels[entities.CatalogEntryLabel]).Should(gomega.Equal(entry.CatalogEntryId))
+
+			descriptors, err := organizationProvider.ListDescriptors(consumer.ID)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(descriptors).Should(gomega.ContainElement(added.AppDescriptorId))
+		})
+		ginkgo.It("should enforce the descriptor quota of the organization", func() {
+			entry, err := manager.PublishDescriptor(createPublishRequest(entities.CatalogVisibility_All))
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(quotaManager.SetLimit(consumer.ID, entities.DescriptorQuota, 0)).To(gomega.Succeed())
+
+			_, err = manager.InstantiateEntry(entities.InstantiateCatalogEntryRequest{
+				OrganizationId: consumer.ID,
+				CatalogEntryId: entry.CatalogEntryId,
+			})
+			gomega.Expect(err).NotTo(gomega.Succeed())
+
+			descriptors, err := organizationProvider.ListDescriptors(consumer.ID)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(descriptors).Should(gomega.BeEmpty())
+		})
+		ginkgo.It("should not instantiate an entry that is not visible", func() {
+			entry, err := manager.PublishDescriptor(createPublishRequest(entities.CatalogVisibility_Selected, consumer.ID))
+			gomega.Expect(err).To(gomega.Succeed())
+
+			_, err = manager.InstantiateEntry(entities.InstantiateCatalogEntryRequest{
+				OrganizationId: other.ID,
+				CatalogEntryId: entry.CatalogEntryId,
+				Version:        entry.Version,
+			})
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+	})
+
+	ginkgo.Context("unpublishing", func() {
+		ginkgo.It("should only allow the publisher to unpublish an entry", func() {
+			entry, err := manager.PublishDescriptor(createPublishRequest(entities.CatalogVisibility_All))
+			gomega.Expect(err).To(gomega.Succeed())
+
+			err = manager.UnpublishEntry(consumer.ID, entry.CatalogEntryId, entry.Version)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+
+			err = manager.UnpublishEntry(publisher.ID, entry.CatalogEntryId, entry.Version)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			_, err = manager.GetEntry(publisher.ID, entry.CatalogEntryId, "")
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+	})
+})
@@ -23,6 +23,7 @@ import (
 	"github.com/nalej/grpc-organization-go"
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
 	"github.com/rs/zerolog/log"
 )
 
@@ -49,7 +50,7 @@ func (h *Handler) AddCluster(ctx context.Context, addClusterRequest *grpc_infras
 	cluster, err := h.Manager.AddCluster(addClusterRequest)
 	if err != nil {
 		log.Error().Str("trace", err.DebugReport()).Msg("cannot add cluster")
-		return nil, conversions.ToGRPCError(err)
+		return nil, quota.ToGRPCError(err)
 	}
 	log.Debug().Str("clusterID", cluster.ClusterId).Msg("cluster has been added")
 	return cluster.ToGRPC(), nil
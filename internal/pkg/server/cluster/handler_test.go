@@ -24,6 +24,7 @@ import (
 	"github.com/nalej/grpc-utils/pkg/test"
 	clusProvider "github.com/nalej/system-model/internal/pkg/provider/cluster"
 	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
 	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
 	"github.com/onsi/ginkgo"
 	"github.com/satori/go.uuid"
@@ -61,6 +62,7 @@ var _ = ginkgo.Describe("Cluster service", func() {
 	// Providers
 	var organizationProvider orgProvider.Provider
 	var clusterProvider clusProvider.Provider
+	var quotaManager quota.Manager
 
 	ginkgo.BeforeSuite(func() {
 		listener = test.GetDefaultListener()
@@ -69,7 +71,8 @@ var _ = ginkgo.Describe("Cluster service", func() {
 		// Register the service
 		organizationProvider = orgProvider.NewMockupOrganizationProvider()
 		clusterProvider = clusProvider.NewMockupClusterProvider()
-		manager := NewManager(organizationProvider, clusterProvider)
+		quotaManager = testhelpers.NewQuotaManager(organizationProvider)
+		manager := NewManager(organizationProvider, clusterProvider, quotaManager)
 		handler := NewHandler(manager)
 		grpc_infrastructure_go.RegisterClustersServer(server, handler)
 
@@ -319,6 +322,25 @@ var _ = ginkgo.Describe("Cluster service", func() {
 			gomega.Expect(err).Should(gomega.Succeed())
 			gomega.Expect(retrieved.ClusterStatus).Should(gomega.Equal(grpc_connectivity_manager_go.ClusterStatus_ONLINE))
 		})
+		ginkgo.It("should not be able to add a cluster over the quota of the organization", func() {
+			gomega.Expect(quotaManager.SetLimit(targetOrganization.ID, entities.ClusterQuota, 1)).To(gomega.Succeed())
+			added, err := client.AddCluster(context.Background(), createAddClusterRequest(targetOrganization.ID))
+			gomega.Expect(err).To(gomega.Succeed())
+
+			exceeded, err := client.AddCluster(context.Background(), createAddClusterRequest(targetOrganization.ID))
+			gomega.Expect(err).To(gomega.HaveOccurred())
+			gomega.Expect(exceeded).Should(gomega.BeNil())
+
+			removeRequest := &grpc_infrastructure_go.RemoveClusterRequest{
+				RequestId:      "removeId",
+				OrganizationId: targetOrganization.ID,
+				ClusterId:      added.ClusterId,
+			}
+			_, err = client.RemoveCluster(context.Background(), removeRequest)
+			gomega.Expect(err).To(gomega.Succeed())
+			_, err = client.AddCluster(context.Background(), createAddClusterRequest(targetOrganization.ID))
+			gomega.Expect(err).To(gomega.Succeed())
+		})
 
 	})
 
@@ -24,6 +24,8 @@ import (
 	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/provider/cluster"
 	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
+	"github.com/nalej/system-model/internal/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
 
@@ -31,11 +33,12 @@ import (
 type Manager struct {
 	OrgProvider     organization.Provider
 	ClusterProvider cluster.Provider
+	Quota           quota.Manager
 }
 
 // NewManager creates a Manager using a set of providers.
-func NewManager(orgProvider organization.Provider, clusterProvider cluster.Provider) Manager {
-	return Manager{orgProvider, clusterProvider}
+func NewManager(orgProvider organization.Provider, clusterProvider cluster.Provider, quotaManager quota.Manager) Manager {
+	return Manager{orgProvider, clusterProvider, quotaManager}
 }
 
 // AddCluster adds a new cluster to the system.
@@ -47,13 +50,19 @@ func (m *Manager) AddCluster(addClusterRequest *grpc_infrastructure_go.AddCluste
 	if !exists {
 		return nil, derrors.NewNotFoundError("organizationID").WithParams(addClusterRequest.OrganizationId)
 	}
+	err = m.Quota.Acquire(addClusterRequest.OrganizationId, entities.ClusterQuota, 1)
+	if err != nil {
+		return nil, err
+	}
 	toAdd := entities.NewClusterFromGRPC(addClusterRequest)
 	err = m.ClusterProvider.Add(*toAdd)
 	if err != nil {
+		m.Quota.Release(toAdd.OrganizationId, entities.ClusterQuota, 1)
 		return nil, err
 	}
 	err = m.OrgProvider.AddCluster(toAdd.OrganizationId, toAdd.ClusterId)
 	if err != nil {
+		m.Quota.Release(toAdd.OrganizationId, entities.ClusterQuota, 1)
 		return nil, err
 	}
 
@@ -76,16 +85,25 @@ func (m *Manager) UpdateCluster(updateRequest *grpc_infrastructure_go.UpdateClus
 	if !exists {
 		return nil, derrors.NewNotFoundError("clusterID").WithParams(updateRequest.OrganizationId, updateRequest.ClusterId)
 	}
-	old, err := m.ClusterProvider.Get(updateRequest.ClusterId)
-	if err != nil {
-		return nil, err
-	}
-	old.ApplyUpdate(*updateRequest)
-	err = m.ClusterProvider.Update(*old)
+	var updated *entities.Cluster
+	err = utils.RetryOnConflict(func() derrors.Error {
+		old, err := m.ClusterProvider.Get(updateRequest.ClusterId)
+		if err != nil {
+			return err
+		}
+		old.ApplyUpdate(*updateRequest)
+		err = m.ClusterProvider.Update(*old)
+		if err != nil {
+			return err
+		}
+		old.Version++
+		updated = old
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	return old, nil
+	return updated, nil
 }
 
 // GetCluster retrieves the cluster information.
@@ -165,8 +183,10 @@ func (m *Manager) RemoveCluster(removeClusterRequest *grpc_infrastructure_go.Rem
 				Str("removeClusterRequest.ClusterId", removeClusterRequest.ClusterId).
 				Msg("error in Rollback")
 		}
+		return err
 	}
-	return err
+	m.Quota.Release(removeClusterRequest.OrganizationId, entities.ClusterQuota, 1)
+	return nil
 }
 
 func (m *Manager) CordonCluster(clusterID *grpc_infrastructure_go.ClusterId) derrors.Error {
@@ -186,7 +206,14 @@ func (m *Manager) CordonCluster(clusterID *grpc_infrastructure_go.ClusterId) der
 		return derrors.NewNotFoundError("clusterID").WithParams(clusterID.OrganizationId, clusterID.ClusterId)
 	}
 
-	old, err := m.ClusterProvider.Get(clusterID.ClusterId)
+	return utils.RetryOnConflict(func() derrors.Error {
+		return m.cordonCluster(clusterID.ClusterId)
+	})
+}
+
+// cordonCluster changes the status of a cluster to cordoned.
+func (m *Manager) cordonCluster(clusterID string) derrors.Error {
+	old, err := m.ClusterProvider.Get(clusterID)
 	if err != nil {
 		return err
 	}
@@ -239,7 +266,14 @@ func (m *Manager) UncordonCluster(clusterID *grpc_infrastructure_go.ClusterId) d
 		return derrors.NewNotFoundError("clusterID").WithParams(clusterID.OrganizationId, clusterID.ClusterId)
 	}
 
-	old, err := m.ClusterProvider.Get(clusterID.ClusterId)
+	return utils.RetryOnConflict(func() derrors.Error {
+		return m.uncordonCluster(clusterID.ClusterId)
+	})
+}
+
+// uncordonCluster changes the status of a cordoned cluster back to its previous status.
+func (m *Manager) uncordonCluster(clusterID string) derrors.Error {
+	old, err := m.ClusterProvider.Get(clusterID)
 	if err != nil {
 		return err
 	}
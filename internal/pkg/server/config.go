@@ -18,8 +18,12 @@ package server
 
 import (
 	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/version"
 	"github.com/rs/zerolog/log"
+	"io/ioutil"
+	"time"
 )
 
 // Config structure with the options for the system model.
@@ -40,6 +44,49 @@ type Config struct {
 	KeySpace string
 	// PublicHostDomain
 	PublicHostDomain string
+	// IPAMPool with the network from which the IP ranges of the organizations are allocated
+	IPAMPool string
+	// IPAMBlockPrefixLength with the prefix length of the allocated IP ranges
+	IPAMBlockPrefixLength int
+	// ConnectionStatusPolicy with the policy used to derive the status of the connections from their links
+	ConnectionStatusPolicy string
+	// ZtGCInterval with the period of the garbage collection of zt members and proxies, disabled if zero
+	ZtGCInterval time.Duration
+	// ZtGCGracePeriod with the time an orphan zt member or proxy is kept before being removed
+	ZtGCGracePeriod time.Duration
+	// TLSCertFile with the certificate of the server, TLS is disabled if empty
+	TLSCertFile string
+	// TLSKeyFile with the private key of the server certificate
+	TLSKeyFile string
+	// TLSClientCAFile with the CA that signs the client certificates, mutual TLS is disabled if empty
+	TLSClientCAFile string
+	// JWTSecretFile with the secret of the HS256 signed tokens
+	JWTSecretFile string
+	// JWTPublicKeyFile with the public key of the RS256 signed tokens
+	JWTPublicKeyFile string
+	// JWTIssuer expected in the tokens, not checked if empty
+	JWTIssuer string
+	// JWTAudience expected in the tokens, not checked if empty
+	JWTAudience string
+	// UnauthenticatedReflection allows the use of the gRPC reflection service without authentication
+	UnauthenticatedReflection bool
+	// StrictSettings rejects the organization settings that are not defined in the settings schema registry
+	StrictSettings bool
+	// BlobDirectory with the directory where the photos are stored, they are stored in the database if empty
+	BlobDirectory string
+	// RoleTemplatesFile with the JSON list of roles created for the new organizations, the default roles are created
+	// if empty
+	RoleTemplatesFile string
+}
+
+// AuthenticationEnabled checks if the callers must be authenticated.
+func (conf *Config) AuthenticationEnabled() bool {
+	return conf.TLSClientCAFile != "" || conf.JWTEnabled()
+}
+
+// JWTEnabled checks if the callers must present a bearer token.
+func (conf *Config) JWTEnabled() bool {
+	return conf.JWTSecretFile != "" || conf.JWTPublicKeyFile != ""
 }
 
 // Validate the current configuration.
@@ -64,9 +111,45 @@ func (conf *Config) Validate() derrors.Error {
 	if !conf.UseDBScyllaProviders && !conf.UseInMemoryProviders {
 		return derrors.NewInvalidArgumentError("a type of provider must be selected")
 	}
+	if _, err := cidr.ParsePool(conf.IPAMPool, conf.IPAMBlockPrefixLength); err != nil {
+		return err
+	}
+	if err := entities.ValidConnectionStatusPolicy(entities.ConnectionStatusPolicy(conf.ConnectionStatusPolicy)); err != nil {
+		return err
+	}
+	if conf.ZtGCInterval < 0 || conf.ZtGCGracePeriod < 0 {
+		return derrors.NewInvalidArgumentError("zt garbage collection interval and grace period cannot be negative")
+	}
+	if (conf.TLSCertFile == "") != (conf.TLSKeyFile == "") {
+		return derrors.NewInvalidArgumentError("TLS certificate and key must be specified together")
+	}
+	if conf.TLSClientCAFile != "" && conf.TLSCertFile == "" {
+		return derrors.NewInvalidArgumentError("mutual TLS requires a server certificate")
+	}
+	if conf.JWTSecretFile != "" && conf.JWTPublicKeyFile != "" {
+		return derrors.NewInvalidArgumentError("only one of JWT secret and public key must be specified")
+	}
+	if conf.JWTEnabled() && conf.TLSCertFile == "" {
+		return derrors.NewInvalidArgumentError("JWT authentication requires TLS")
+	}
+	if _, err := conf.LoadRoleTemplates(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// LoadRoleTemplates returns the templates of the roles created for the new organizations.
+func (conf *Config) LoadRoleTemplates() ([]entities.RoleTemplate, derrors.Error) {
+	if conf.RoleTemplatesFile == "" {
+		return entities.DefaultRoleTemplates(), nil
+	}
+	data, err := ioutil.ReadFile(conf.RoleTemplatesFile)
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot read the role templates")
+	}
+	return entities.ParseRoleTemplates(data)
+}
+
 // Print the current configuration to the log system.
 func (conf *Config) Print() {
 	log.Info().Str("app", version.AppVersion).Str("commit", version.Commit).Msg("Version")
@@ -80,4 +163,25 @@ func (conf *Config) Print() {
 		log.Info().Str("URL", conf.ScyllaDBAddress).Str("KeySpace", conf.KeySpace).Int("Port", conf.ScyllaDBPort).Msg("ScyllaDB")
 	}
 	log.Info().Str("PublicHostDomain", conf.PublicHostDomain).Msg("Public Host Domain")
+	log.Info().Str("pool", conf.IPAMPool).Int("blockPrefixLength", conf.IPAMBlockPrefixLength).Msg("IPAM")
+	log.Info().Str("policy", conf.ConnectionStatusPolicy).Msg("Connection status")
+	log.Info().Str("interval", conf.ZtGCInterval.String()).Str("gracePeriod", conf.ZtGCGracePeriod.String()).Msg("ZT garbage collection")
+	log.Info().Bool("tls", conf.TLSCertFile != "").Bool("mutualTLS", conf.TLSClientCAFile != "").Msg("Transport security")
+	if conf.JWTEnabled() {
+		log.Info().Bool("hs256", conf.JWTSecretFile != "").Bool("rs256", conf.JWTPublicKeyFile != "").
+			Str("issuer", conf.JWTIssuer).Str("audience", conf.JWTAudience).Msg("JWT authentication")
+	}
+	if conf.AuthenticationEnabled() && conf.UnauthenticatedReflection {
+		log.Warn().Msg("gRPC reflection service available without authentication")
+	}
+	if conf.RoleTemplatesFile != "" {
+		log.Info().Str("file", conf.RoleTemplatesFile).Msg("Role templates")
+	}
+	if conf.BlobDirectory != "" {
+		log.Info().Str("directory", conf.BlobDirectory).Msg("Photos stored in the file system")
+	}
+	log.Info().Bool("strict", conf.StrictSettings).Int("known", len(entities.ListSettingSchemas())).Msg("Organization settings")
+	if !conf.AuthenticationEnabled() {
+		log.Warn().Msg("Authentication disabled, any caller can access the data of all the organizations")
+	}
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/entities/devices"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
 	"github.com/rs/zerolog/log"
 )
 
@@ -46,7 +47,7 @@ func (h *Handler) AddDeviceGroup(ctx context.Context, addRequest *grpc_device_go
 	added, err := h.Manager.AddDeviceGroup(addRequest)
 	if err != nil {
 		log.Error().Str("trace", err.DebugReport()).Msg("cannot add device group")
-		return nil, conversions.ToGRPCError(err)
+		return nil, quota.ToGRPCError(err)
 	}
 	return added.ToGRPC(), nil
 }
@@ -139,7 +140,7 @@ func (h *Handler) AddDevice(ctx context.Context, addRequest *grpc_device_go.AddD
 	added, err := h.Manager.AddDevice(addRequest)
 	if err != nil {
 		log.Error().Str("trace", err.DebugReport()).Msg("cannot add device")
-		return nil, conversions.ToGRPCError(err)
+		return nil, quota.ToGRPCError(err)
 	}
 	return added.ToGRPC(), nil
 }
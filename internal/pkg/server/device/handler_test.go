@@ -27,6 +27,7 @@ import (
 	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/entities/devices"
 	"github.com/nalej/system-model/internal/pkg/provider/device"
+	"github.com/nalej/system-model/internal/pkg/provider/name_reservation"
 	"github.com/nalej/system-model/internal/pkg/provider/organization"
 	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
 	"github.com/onsi/ginkgo"
@@ -127,7 +128,8 @@ var _ = ginkgo.Describe("Applications", func() {
 		organizationProvider = organization.NewMockupOrganizationProvider()
 		deviceProvider = device.NewMockupDeviceProvider()
 
-		manager := NewManager(deviceProvider, organizationProvider)
+		manager := NewManager(deviceProvider, organizationProvider, name_reservation.NewMockupNameReservationProvider(),
+			testhelpers.NewQuotaManager(organizationProvider))
 		handler := NewHandler(manager)
 		grpc_device_go.RegisterDevicesServer(server, handler)
 
@@ -20,20 +20,27 @@ import (
 	"github.com/nalej/derrors"
 	"github.com/nalej/grpc-device-go"
 	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/entities/devices"
 	"github.com/nalej/system-model/internal/pkg/provider/device"
+	"github.com/nalej/system-model/internal/pkg/provider/name_reservation"
 	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
+	"github.com/nalej/system-model/internal/pkg/utils"
+	"github.com/rs/zerolog/log"
 )
 
 // Manager structure with the required providers for application operations.
 type Manager struct {
-	DevProvider device.Provider
-	OrgProvider organization.Provider
+	DevProvider  device.Provider
+	OrgProvider  organization.Provider
+	NameProvider name_reservation.Provider
+	Quota        quota.Manager
 }
 
 // NewManager creates a Manager using a set of providers.
-func NewManager(devProvider device.Provider, orgProvider organization.Provider) Manager {
-	return Manager{devProvider, orgProvider}
+func NewManager(devProvider device.Provider, orgProvider organization.Provider, nameProvider name_reservation.Provider, quotaManager quota.Manager) Manager {
+	return Manager{devProvider, orgProvider, nameProvider, quotaManager}
 }
 
 // ---------------------------------------------------------------------------------------------------------
@@ -56,9 +63,26 @@ func (m *Manager) AddDeviceGroup(addRequest *grpc_device_go.AddDeviceGroupReques
 		return nil, derrors.NewAlreadyExistsError("device group").WithParams(addRequest.OrganizationId, addRequest.Name)
 	}
 
+	err = m.Quota.Acquire(addRequest.OrganizationId, entities.DeviceGroupQuota, 1)
+	if err != nil {
+		return nil, err
+	}
 	group := devices.NewDeviceGroupFromGRPC(addRequest)
+	scope := entities.DeviceGroupNameScope(group.OrganizationId)
+	err = m.NameProvider.Reserve(*entities.NewNameReservation(scope, group.Name, group.DeviceGroupId))
+	if err != nil {
+		m.Quota.Release(group.OrganizationId, entities.DeviceGroupQuota, 1)
+		if err.Type() == derrors.AlreadyExists {
+			return nil, derrors.NewAlreadyExistsError("device group").WithParams(addRequest.OrganizationId, addRequest.Name)
+		}
+		return nil, err
+	}
 	err = m.DevProvider.AddDeviceGroup(*group)
 	if err != nil {
+		m.Quota.Release(group.OrganizationId, entities.DeviceGroupQuota, 1)
+		if rErr := m.NameProvider.Release(scope, group.Name, group.DeviceGroupId); rErr != nil {
+			log.Warn().Str("name", group.Name).Str("trace", rErr.DebugReport()).Msg("cannot release device group name")
+		}
 		return nil, err
 	}
 
@@ -120,13 +144,51 @@ func (m *Manager) RemoveDeviceGroup(removeRequest *grpc_device_go.RemoveDeviceGr
 	if !exists {
 		return derrors.NewNotFoundError("device group").WithParams(removeRequest.OrganizationId, removeRequest.DeviceGroupId)
 	}
+	group, err := m.DevProvider.GetDeviceGroup(removeRequest.OrganizationId, removeRequest.DeviceGroupId)
+	if err != nil {
+		return err
+	}
 
 	err = m.DevProvider.RemoveDeviceGroup(removeRequest.OrganizationId, removeRequest.DeviceGroupId)
 	if err != nil {
 		return err
 	}
+	m.Quota.Release(removeRequest.OrganizationId, entities.DeviceGroupQuota, 1)
 
-	return nil
+	return m.NameProvider.Release(entities.DeviceGroupNameScope(group.OrganizationId), group.Name, group.DeviceGroupId)
+}
+
+// ReserveDeviceGroupNames reserves the names of the existing device groups, returning the names used by more than
+// one group of an organization. It can be run several times.
+func (m *Manager) ReserveDeviceGroupNames() ([]entities.NameConflict, derrors.Error) {
+	organizations, err := m.OrgProvider.List()
+	if err != nil {
+		return nil, err
+	}
+	conflicts := make([]entities.NameConflict, 0)
+	for _, org := range organizations {
+		groups, err := m.DevProvider.ListDeviceGroups(org.ID)
+		if err != nil {
+			return nil, err
+		}
+		scope := entities.DeviceGroupNameScope(org.ID)
+		for _, group := range groups {
+			err := m.NameProvider.Reserve(*entities.NewNameReservation(scope, group.Name, group.DeviceGroupId))
+			if err == nil {
+				continue
+			}
+			if err.Type() != derrors.AlreadyExists {
+				return nil, err
+			}
+			reservation, err := m.NameProvider.Get(scope, group.Name)
+			if err != nil {
+				return nil, err
+			}
+			conflicts = append(conflicts, entities.NameConflict{Scope: scope, Name: group.Name,
+				OwnerId: group.DeviceGroupId, ReservedBy: reservation.OwnerId})
+		}
+	}
+	return conflicts, nil
 }
 func (m *Manager) GetDeviceGroupsByNames(request *grpc_device_go.GetDeviceGroupsRequest) ([]devices.DeviceGroup, derrors.Error) {
 	exists, err := m.OrgProvider.Exists(request.OrganizationId)
@@ -163,9 +225,14 @@ func (m *Manager) AddDevice(addRequest *grpc_device_go.AddDeviceRequest) (*devic
 		return nil, derrors.NewNotFoundError("deviceGroup").WithParams(addRequest.OrganizationId, addRequest.DeviceGroupId)
 	}
 
+	err = m.Quota.Acquire(addRequest.OrganizationId, entities.DeviceQuota, 1)
+	if err != nil {
+		return nil, err
+	}
 	device := devices.NewDeviceFromGRPC(addRequest)
 	err = m.DevProvider.AddDevice(*device)
 	if err != nil {
+		m.Quota.Release(addRequest.OrganizationId, entities.DeviceQuota, 1)
 		return nil, err
 	}
 
@@ -259,21 +326,31 @@ func (m *Manager) RemoveDevice(removeRequest *grpc_device_go.RemoveDeviceRequest
 	if err != nil {
 		return err
 	}
+	m.Quota.Release(removeRequest.OrganizationId, entities.DeviceQuota, 1)
 
 	return nil
 }
 
 func (m *Manager) UpdateDevice(deviceRequest *grpc_device_go.UpdateDeviceRequest) (*devices.Device, derrors.Error) {
 
-	device, err := m.DevProvider.GetDevice(deviceRequest.OrganizationId, deviceRequest.DeviceGroupId, deviceRequest.DeviceId)
+	var updated *devices.Device
+	err := utils.RetryOnConflict(func() derrors.Error {
+		device, err := m.DevProvider.GetDevice(deviceRequest.OrganizationId, deviceRequest.DeviceGroupId, deviceRequest.DeviceId)
+		if err != nil {
+			return err
+		}
+		device.ApplyUpdate(*deviceRequest)
+		err = m.DevProvider.UpdateDevice(*device)
+		if err != nil {
+			return err
+		}
+		device.Version++
+		updated = device
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	device.ApplyUpdate(*deviceRequest)
-	err = m.DevProvider.UpdateDevice(*device)
-	if err != nil {
-		return nil, err
-	}
-	return device, nil
+	return updated, nil
 
 }
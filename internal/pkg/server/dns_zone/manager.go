@@ -0,0 +1,108 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dns_zone
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/dns"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/dns_zone"
+	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/application"
+	"github.com/nalej/system-model/internal/pkg/utils"
+)
+
+// Manager structure with the required providers for DNS zone operations.
+type Manager struct {
+	OrgProvider  organization.Provider
+	ZoneProvider dns_zone.Provider
+	// Applications retrieves the endpoints of the organizations under its public host domain.
+	Applications application.Manager
+}
+
+// NewManager creates a Manager using a set of providers and the application manager that lists the endpoints.
+func NewManager(orgProvider organization.Provider, zoneProvider dns_zone.Provider, applications application.Manager) Manager {
+	return Manager{orgProvider, zoneProvider, applications}
+}
+
+// ExportOrganizationZone builds the DNS zone with the endpoints of an organization.
+func (m *Manager) ExportOrganizationZone(orgID *grpc_organization_go.OrganizationId) (*dns.Zone, derrors.Error) {
+	endpoints, err := m.Applications.ListOrganizationEndpoints(orgID)
+	if err != nil {
+		return nil, err
+	}
+	return m.exportZone(orgID.OrganizationId, endpoints)
+}
+
+// ExportSystemZone builds the DNS zone with the endpoints of all the organizations.
+func (m *Manager) ExportSystemZone() (*dns.Zone, derrors.Error) {
+	organizations, err := m.OrgProvider.List()
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]entities.AppEndpoint, 0)
+	for _, org := range organizations {
+		orgEndpoints, err := m.Applications.ListOrganizationEndpoints(&grpc_organization_go.OrganizationId{OrganizationId: org.ID})
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, orgEndpoints...)
+	}
+	return m.exportZone(entities.SystemZoneName, endpoints)
+}
+
+// exportZone builds a zone and assigns its serial. The serial is increased each time the records of the zone change.
+// The serial is updated conditionally, so concurrent exports never assign the same serial to different records.
+func (m *Manager) exportZone(zoneName string, endpoints []entities.AppEndpoint) (*dns.Zone, derrors.Error) {
+	zone := dns.NewZone(m.Applications.PublicHostDomain, endpoints)
+	checksum := zone.Checksum()
+
+	err := utils.RetryOnConflict(func() derrors.Error {
+		current, err := m.ZoneProvider.Get(zoneName)
+		if err != nil {
+			if err.Type() != derrors.NotFound {
+				return err
+			}
+			serial := entities.NewDNSZoneSerial(zoneName, checksum)
+			err = m.ZoneProvider.Add(*serial)
+			if err != nil {
+				if err.Type() == derrors.AlreadyExists {
+					// Added by a concurrent export
+					return entities.NewVersionConflictError("zone serial", zoneName, 0)
+				}
+				return err
+			}
+			zone.Serial = serial.Serial
+			return nil
+		}
+
+		next := current.Next(checksum)
+		if next.Serial != current.Serial {
+			err = m.ZoneProvider.Update(*next)
+			if err != nil {
+				return err
+			}
+		}
+		zone.Serial = next.Serial
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return zone, nil
+}
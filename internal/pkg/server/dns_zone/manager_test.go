@@ -0,0 +1,109 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dns_zone
+
+import (
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	devProvider "github.com/nalej/system-model/internal/pkg/provider/device"
+	zoneProvider "github.com/nalej/system-model/internal/pkg/provider/dns_zone"
+	ipamProvider "github.com/nalej/system-model/internal/pkg/provider/ipam"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/application"
+	"github.com/nalej/system-model/internal/pkg/server/ipam"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("DNS zone manager", func() {
+
+	var manager Manager
+	var organizationProvider orgProvider.Provider
+	var applicationProvider appProvider.Provider
+	var organization *entities.Organization
+	var instance *entities.AppInstance
+
+	addEndpoint := func(port int32) {
+		group := instance.Groups[0]
+		err := applicationProvider.AddAppEndpoint(entities.AppEndpoint{
+			OrganizationId:         organization.ID,
+			AppInstanceId:          instance.AppInstanceId,
+			ServiceGroupInstanceId: group.ServiceGroupInstanceId,
+			ServiceInstanceId:      group.ServiceInstances[0].ServiceInstanceId,
+			Port:                   port,
+			Protocol:               entities.HTTPS,
+			Type:                   entities.Web,
+			Fqdn:                   "web.sg.app.cluster.nalej.tech",
+			GlobalFqdn:             "web.sg.app.org",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	}
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider = orgProvider.NewMockupOrganizationProvider()
+		applicationProvider = appProvider.NewMockupApplicationProvider()
+		pool, err := cidr.ParsePool(cidr.DefaultPool, cidr.DefaultBlockPrefixLength)
+		gomega.Expect(err).To(gomega.Succeed())
+		applications := application.NewManager(organizationProvider, applicationProvider, devProvider.NewMockupDeviceProvider(),
+			"nalej.cluster.local", ipam.NewManager(organizationProvider, ipamProvider.NewMockupIPAMProvider(), pool),
+			testhelpers.NewQuotaManager(organizationProvider))
+		manager = NewManager(organizationProvider, zoneProvider.NewMockupDNSZoneProvider(), applications)
+
+		organization = testhelpers.AddOrganization(organizationProvider)
+		instance = appProvider.CreateTestApplication(organization.ID, entities.GenerateUUID())
+		gomega.Expect(applicationProvider.AddInstance(*instance)).To(gomega.Succeed())
+		gomega.Expect(organizationProvider.AddInstance(organization.ID, instance.AppInstanceId)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should keep the serial while the zone does not change", func() {
+		addEndpoint(443)
+		orgID := &grpc_organization_go.OrganizationId{OrganizationId: organization.ID}
+
+		zone, err := manager.ExportOrganizationZone(orgID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(zone.Serial).Should(gomega.Equal(int64(1)))
+		gomega.Expect(zone.Records).ShouldNot(gomega.BeEmpty())
+
+		zone, err = manager.ExportOrganizationZone(orgID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(zone.Serial).Should(gomega.Equal(int64(1)))
+	})
+
+	ginkgo.It("should increase the serial when the zone changes", func() {
+		addEndpoint(443)
+		orgID := &grpc_organization_go.OrganizationId{OrganizationId: organization.ID}
+
+		_, err := manager.ExportOrganizationZone(orgID)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		addEndpoint(8443)
+		zone, err := manager.ExportOrganizationZone(orgID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(zone.Serial).Should(gomega.Equal(int64(2)))
+	})
+
+	ginkgo.It("should export the zone of the whole system", func() {
+		addEndpoint(443)
+		zone, err := manager.ExportSystemZone()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(zone.Serial).Should(gomega.Equal(int64(1)))
+		gomega.Expect(zone.Records).ShouldNot(gomega.BeEmpty())
+	})
+})
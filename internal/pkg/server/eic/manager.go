@@ -23,6 +23,7 @@ import (
 	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/provider/eic"
 	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/utils"
 )
 
 // Manager structure with the required providers for application operations.
@@ -87,19 +88,28 @@ func (m *Manager) Remove(edgeControllerID *grpc_inventory_go.EdgeControllerId) d
 }
 
 func (m *Manager) Update(request *grpc_inventory_go.UpdateEdgeControllerRequest) (*entities.EdgeController, derrors.Error) {
-	retrieved, err := m.ControllerProvider.Get(request.EdgeControllerId)
+	var updated *entities.EdgeController
+	err := utils.RetryOnConflict(func() derrors.Error {
+		retrieved, err := m.ControllerProvider.Get(request.EdgeControllerId)
+		if err != nil {
+			return err
+		}
+		if retrieved.OrganizationId != request.OrganizationId {
+			return derrors.NewNotFoundError("organization_id & asset_id").WithParams(request.OrganizationId, request.EdgeControllerId)
+		}
+		retrieved.ApplyUpdate(request)
+		err = m.ControllerProvider.Update(*retrieved)
+		if err != nil {
+			return err
+		}
+		retrieved.Version++
+		updated = retrieved
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	if retrieved.OrganizationId != request.OrganizationId {
-		return nil, derrors.NewNotFoundError("organization_id & asset_id").WithParams(request.OrganizationId, request.EdgeControllerId)
-	}
-	retrieved.ApplyUpdate(request)
-	err = m.ControllerProvider.Update(*retrieved)
-	if err != nil {
-		return nil, err
-	}
-	return retrieved, nil
+	return updated, nil
 }
 
 func (m *Manager) Get(edgeControllerID *grpc_inventory_go.EdgeControllerId) (*entities.EdgeController, derrors.Error) {
@@ -0,0 +1,202 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invitation
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/invitation"
+	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	"github.com/nalej/system-model/internal/pkg/server/user"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// Manager structure with the required providers for invitation operations.
+type Manager struct {
+	OrgProvider        organization.Provider
+	InvitationProvider invitation.Provider
+	SettingProvider    organization_setting.Provider
+	UserManager        user.Manager
+}
+
+// NewManager creates a Manager using a set of providers and the manager that adds the users.
+func NewManager(orgProvider organization.Provider, invitationProvider invitation.Provider,
+	settingProvider organization_setting.Provider, userManager user.Manager) Manager {
+	return Manager{
+		OrgProvider:        orgProvider,
+		InvitationProvider: invitationProvider,
+		SettingProvider:    settingProvider,
+		UserManager:        userManager,
+	}
+}
+
+// checkOrganization checks that an organization exists.
+func (m *Manager) checkOrganization(organizationID string) derrors.Error {
+	exists, err := m.OrgProvider.Exists(organizationID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("organizationID").WithParams(organizationID)
+	}
+	return nil
+}
+
+// invitationTTL returns the time the invitations of an organization can be accepted if no expiration is set, taken
+// from the invitation TTL setting of the organization.
+func (m *Manager) invitationTTL(organizationID string) (time.Duration, derrors.Error) {
+	setting, err := m.SettingProvider.Get(organizationID, entities.InvitationTTLSettingKey)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return entities.DefaultInvitationTTL, nil
+		}
+		return 0, err
+	}
+	ttl, err := entities.ParseInvitationTTL(setting.Value)
+	if err != nil {
+		log.Warn().Str("organizationId", organizationID).Str("value", setting.Value).
+			Msg("invalid invitation ttl setting, using the default ttl")
+		return entities.DefaultInvitationTTL, nil
+	}
+	return ttl, nil
+}
+
+// Invite an email to become a member of an organization with a role. Emails that are already members or that have
+// a pending invitation cannot be invited. Invitations without expiration expire after the invitation TTL setting of
+// the organization. It returns the invitation and the plain token to be sent to the email.
+func (m *Manager) Invite(organizationID string, email string, roleID string, invitedBy string, expiresAt int64) (*entities.Invitation, string, derrors.Error) {
+	if err := entities.ValidAddInvitation(organizationID, email, roleID, expiresAt); err != nil {
+		return nil, "", err
+	}
+	if err := m.checkOrganization(organizationID); err != nil {
+		return nil, "", err
+	}
+	exists, err := m.OrgProvider.RoleExists(organizationID, roleID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !exists {
+		return nil, "", derrors.NewNotFoundError("roleID").WithParams(organizationID, roleID)
+	}
+	exists, err = m.UserManager.UserProvider.MembershipExists(email, organizationID)
+	if err != nil {
+		return nil, "", err
+	}
+	if exists {
+		return nil, "", derrors.NewAlreadyExistsError("user is already a member").WithParams(email, organizationID)
+	}
+	if expiresAt == 0 {
+		ttl, err := m.invitationTTL(organizationID)
+		if err != nil {
+			return nil, "", err
+		}
+		expiresAt = time.Now().Add(ttl).Unix()
+	}
+	toAdd, token, err := entities.NewInvitation(organizationID, email, roleID, invitedBy, expiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+	err = m.InvitationProvider.Add(*toAdd)
+	if err != nil {
+		return nil, "", err
+	}
+	return toAdd, token, nil
+}
+
+// ListInvitations retrieves the invitations of an organization.
+func (m *Manager) ListInvitations(organizationID string) ([]entities.Invitation, derrors.Error) {
+	if err := m.checkOrganization(organizationID); err != nil {
+		return nil, err
+	}
+	return m.InvitationProvider.List(organizationID)
+}
+
+// getInvitation retrieves an invitation of an organization.
+func (m *Manager) getInvitation(organizationID string, invitationID string) (*entities.Invitation, derrors.Error) {
+	if err := m.checkOrganization(organizationID); err != nil {
+		return nil, err
+	}
+	retrieved, err := m.InvitationProvider.Get(invitationID)
+	if err != nil {
+		return nil, err
+	}
+	if retrieved.OrganizationId != organizationID {
+		return nil, derrors.NewNotFoundError("invitation").WithParams(organizationID, invitationID)
+	}
+	return retrieved, nil
+}
+
+// RevokeInvitation revokes an invitation that has not been used, so it can no longer be accepted.
+func (m *Manager) RevokeInvitation(organizationID string, invitationID string) (*entities.Invitation, derrors.Error) {
+	retrieved, err := m.getInvitation(organizationID, invitationID)
+	if err != nil {
+		return nil, err
+	}
+	retrieved.RevokedAt = time.Now().Unix()
+	err = m.InvitationProvider.Revoke(invitationID, retrieved.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return retrieved, nil
+}
+
+// AcceptInvitation uses a plain token to add the invited email to the organization with the role of the invitation.
+// The invitation is marked as accepted before the user is added, so concurrent requests cannot use it twice, and it
+// is restored if the user cannot be added.
+func (m *Manager) AcceptInvitation(token string, name string, lastName string, title string) (*entities.User, derrors.Error) {
+	invitationID, secret, err := entities.ParseInvitationToken(token)
+	if err != nil {
+		return nil, err
+	}
+	retrieved, err := m.InvitationProvider.Get(invitationID)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return nil, derrors.NewPermissionDeniedError("invalid invitation token")
+		}
+		return nil, err
+	}
+	if !retrieved.MatchesSecret(secret) {
+		return nil, derrors.NewPermissionDeniedError("invalid invitation token")
+	}
+	now := time.Now().Unix()
+	if status := retrieved.Status(now); status != entities.InvitationPending {
+		return nil, derrors.NewFailedPreconditionError("invitation cannot be accepted").WithParams(invitationID, status)
+	}
+	err = m.InvitationProvider.Accept(invitationID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := m.UserManager.AddUserWithRoles(&grpc_user_go.AddUserRequest{
+		OrganizationId: retrieved.OrganizationId,
+		Email:          retrieved.Email,
+		Name:           name,
+		LastName:       lastName,
+		Title:          title,
+	}, []string{retrieved.RoleId})
+	if err != nil {
+		if rollbackError := m.InvitationProvider.CancelAccept(invitationID, now); rollbackError != nil {
+			log.Error().Str("trace", conversions.ToDerror(rollbackError).DebugReport()).Msg("error in Rollback")
+		}
+		return nil, err
+	}
+	return added, nil
+}
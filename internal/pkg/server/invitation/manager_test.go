@@ -0,0 +1,182 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invitation
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	iProvider "github.com/nalej/system-model/internal/pkg/provider/invitation"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	rProvider "github.com/nalej/system-model/internal/pkg/provider/role"
+	saProvider "github.com/nalej/system-model/internal/pkg/provider/service_account"
+	uProvider "github.com/nalej/system-model/internal/pkg/provider/user"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
+	"github.com/nalej/system-model/internal/pkg/server/user"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"time"
+)
+
+var _ = ginkgo.Describe("Organization invitations", func() {
+
+	var manager Manager
+	var targetOrganization *entities.Organization
+	var roleID string
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider := orgProvider.NewMockupOrganizationProvider()
+		userManager := user.NewManager(organizationProvider, uProvider.NewMockupUserProvider(), rProvider.NewMockupRoleProvider(),
+			saProvider.NewMockupServiceAccountProvider(), testhelpers.NewQuotaManager(organizationProvider),
+			testhelpers.NewPhotoManager())
+		manager = NewManager(organizationProvider, iProvider.NewMockupInvitationProvider(),
+			organization_setting.NewMockupOrganizationSettingProvider(), userManager)
+		targetOrganization = testhelpers.AddOrganization(organizationProvider)
+		roleID = entities.GenerateUUID()
+		gomega.Expect(organizationProvider.AddRole(targetOrganization.ID, roleID)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should expire the invitations after the ttl of the organization", func() {
+		setting := entities.NewOrganizationSetting(targetOrganization.ID, entities.InvitationTTLSettingKey, "1h", "")
+		gomega.Expect(manager.SettingProvider.Add(*setting)).To(gomega.Succeed())
+
+		invitation, _, err := manager.Invite(targetOrganization.ID, "invited@nalej.com", roleID, "admin@nalej.com", 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(invitation.ExpiresAt).Should(gomega.BeNumerically("<=", time.Now().Add(time.Hour).Unix()))
+		gomega.Expect(invitation.ExpiresAt).Should(gomega.BeNumerically(">", time.Now().Add(time.Hour-time.Minute).Unix()))
+	})
+
+	ginkgo.It("should add the user with the role when an invitation is accepted", func() {
+		invitation, token, err := manager.Invite(targetOrganization.ID, "invited@nalej.com", roleID, "admin@nalej.com", 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(invitation.ExpiresAt).Should(gomega.BeNumerically(">", time.Now().Unix()))
+		gomega.Expect(invitation.Hash).ShouldNot(gomega.ContainSubstring(token))
+
+		added, err := manager.AcceptInvitation(token, "invited", "user", "engineer")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(added.Email).Should(gomega.Equal("invited@nalej.com"))
+		gomega.Expect(added.Name).Should(gomega.Equal("invited"))
+
+		membership, err := manager.UserManager.UserProvider.GetMembership("invited@nalej.com", targetOrganization.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(membership.RoleIds).Should(gomega.Equal([]string{roleID}))
+
+		_, err = manager.AcceptInvitation(token, "invited", "user", "engineer")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.FailedPrecondition))
+
+		invitations, err := manager.ListInvitations(targetOrganization.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(invitations)).Should(gomega.Equal(1))
+		gomega.Expect(invitations[0].Status(time.Now().Unix())).Should(gomega.Equal(entities.InvitationAccepted))
+	})
+
+	ginkgo.It("should not accept invitations when the user quota is reached", func() {
+		_, token, err := manager.Invite(targetOrganization.ID, "invited@nalej.com", roleID, "admin@nalej.com", 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(manager.UserManager.Quota.SetLimit(targetOrganization.ID, entities.UserQuota, 0)).To(gomega.Succeed())
+
+		_, err = manager.AcceptInvitation(token, "invited", "user", "engineer")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(entities.IsQuotaExceeded(err)).To(gomega.BeTrue())
+		_, err = manager.UserManager.UserProvider.GetMembership("invited@nalej.com", targetOrganization.ID)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		// the invitation is still pending, so it can be accepted once the limit is raised
+		invitations, err := manager.ListInvitations(targetOrganization.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(invitations)).Should(gomega.Equal(1))
+		gomega.Expect(invitations[0].Status(time.Now().Unix())).Should(gomega.Equal(entities.InvitationPending))
+
+		gomega.Expect(manager.UserManager.Quota.SetLimit(targetOrganization.ID, entities.UserQuota, 1)).To(gomega.Succeed())
+		_, err = manager.AcceptInvitation(token, "invited", "user", "engineer")
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should reject invitations for members and duplicated invitations", func() {
+		_, _, err := manager.Invite(targetOrganization.ID, "invited@nalej.com", roleID, "admin@nalej.com", 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		_, _, err = manager.Invite(targetOrganization.ID, "invited@nalej.com", roleID, "admin@nalej.com", 0)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		_, err = manager.UserManager.AddUser(&grpc_user_go.AddUserRequest{OrganizationId: targetOrganization.ID, Email: "member@nalej.com"})
+		gomega.Expect(err).To(gomega.Succeed())
+		_, _, err = manager.Invite(targetOrganization.ID, "member@nalej.com", roleID, "admin@nalej.com", 0)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		_, _, err = manager.Invite(targetOrganization.ID, "other@nalej.com", entities.GenerateUUID(), "admin@nalej.com", 0)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should not accept revoked invitations", func() {
+		invitation, token, err := manager.Invite(targetOrganization.ID, "invited@nalej.com", roleID, "admin@nalej.com", 0)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		_, err = manager.RevokeInvitation(entities.GenerateUUID(), invitation.InvitationId)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		revoked, err := manager.RevokeInvitation(targetOrganization.ID, invitation.InvitationId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(revoked.Status(time.Now().Unix())).Should(gomega.Equal(entities.InvitationRevoked))
+
+		_, err = manager.AcceptInvitation(token, "invited", "user", "engineer")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		_, err = manager.RevokeInvitation(targetOrganization.ID, invitation.InvitationId)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		// the email can be invited again once the invitation is revoked
+		_, _, err = manager.Invite(targetOrganization.ID, "invited@nalej.com", roleID, "admin@nalej.com", 0)
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should not accept invalid tokens", func() {
+		invitation, _, err := manager.Invite(targetOrganization.ID, "invited@nalej.com", roleID, "admin@nalej.com", 0)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		_, err = manager.AcceptInvitation("malformed", "invited", "user", "engineer")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		_, err = manager.AcceptInvitation(entities.InvitationTokenPrefix+"_"+invitation.InvitationId+"_secret", "invited", "user", "engineer")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.PermissionDenied))
+	})
+
+	ginkgo.It("should not accept expired invitations", func() {
+		invitation, token, err := manager.Invite(targetOrganization.ID, "invited@nalej.com", roleID, "admin@nalej.com", 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		invitation.ExpiresAt = time.Now().Unix() - 1
+		gomega.Expect(manager.InvitationProvider.Update(*invitation)).To(gomega.Succeed())
+
+		_, err = manager.AcceptInvitation(token, "invited", "user", "engineer")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.FailedPrecondition))
+	})
+
+	ginkgo.It("should restore the invitation if the user cannot be added", func() {
+		invitation, token, err := manager.Invite(targetOrganization.ID, "invited@nalej.com", roleID, "admin@nalej.com", 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(manager.OrgProvider.DeleteRole(targetOrganization.ID, roleID)).To(gomega.Succeed())
+
+		_, err = manager.AcceptInvitation(token, "invited", "user", "engineer")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		retrieved, err := manager.InvitationProvider.Get(invitation.InvitationId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Status(time.Now().Unix())).Should(gomega.Equal(entities.InvitationPending))
+		_, err = manager.UserManager.UserProvider.GetMembership("invited@nalej.com", targetOrganization.ID)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
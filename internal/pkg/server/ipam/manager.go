@@ -0,0 +1,315 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ipam
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/ipam"
+	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"net"
+	"sort"
+)
+
+// Manager structure with the required providers for IP address management operations.
+// Ranges are fixed size blocks of the pool, or ranges registered by the callers. Concurrent allocations of the same
+// block or address are resolved by the providers with conditional inserts, which reject duplicated entries; the
+// allocation is then retried with the next free one. The overlap between ranges with a different CIDR is checked
+// against the ranges stored when the range is added, so a range registered concurrently with the allocation of an
+// overlapping range of the same organization is not detected. Callers registering ranges chosen by them must not do
+// it concurrently with other range operations of the same organization.
+type Manager struct {
+	OrgProvider  organization.Provider
+	IPAMProvider ipam.Provider
+	Pool         *cidr.Pool
+}
+
+// NewManager creates a Manager using a set of providers.
+func NewManager(orgProvider organization.Provider, ipamProvider ipam.Provider, pool *cidr.Pool) Manager {
+	return Manager{
+		OrgProvider:  orgProvider,
+		IPAMProvider: ipamProvider,
+		Pool:         pool,
+	}
+}
+
+// validOrganization checks that the organization exists.
+func (m *Manager) validOrganization(organizationID string) derrors.Error {
+	exists, err := m.OrgProvider.Exists(organizationID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("organizationID").WithParams(organizationID)
+	}
+	return nil
+}
+
+// ownerRange returns the first range assigned to an owner, or nil.
+func ownerRange(ranges []entities.IPRange, ownerID string) *entities.IPRange {
+	for index := range ranges {
+		if ranges[index].OwnerId == ownerID {
+			return &ranges[index]
+		}
+	}
+	return nil
+}
+
+// overlappingRange returns the range that overlaps with a network, or nil.
+func overlappingRange(ranges []entities.IPRange, network *net.IPNet) *entities.IPRange {
+	for index := range ranges {
+		existing, err := cidr.Parse(ranges[index].Cidr)
+		if err != nil {
+			continue
+		}
+		if cidr.Overlaps(existing, network) {
+			return &ranges[index]
+		}
+	}
+	return nil
+}
+
+// AllocateRange assigns a free block of the pool to an owner. The range already assigned to the owner is returned
+// if it exists.
+func (m *Manager) AllocateRange(organizationID string, ownerID string) (*entities.IPRange, derrors.Error) {
+	if ownerID == "" {
+		return nil, derrors.NewInvalidArgumentError("expecting owner_id")
+	}
+	if err := m.validOrganization(organizationID); err != nil {
+		return nil, err
+	}
+	ranges, err := m.IPAMProvider.ListRanges(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if assigned := ownerRange(ranges, ownerID); assigned != nil {
+		return assigned, nil
+	}
+
+	for index := int64(0); index < m.Pool.Blocks(); index++ {
+		block := m.Pool.Block(index)
+		if overlappingRange(ranges, block) != nil {
+			continue
+		}
+		toAdd := entities.NewIPRange(organizationID, block.String(), ownerID)
+		err = m.IPAMProvider.AddRange(*toAdd)
+		if err == nil {
+			return toAdd, nil
+		}
+		if err.Type() != derrors.AlreadyExists {
+			return nil, err
+		}
+	}
+	return nil, derrors.NewFailedPreconditionError("ip pool exhausted").WithParams(organizationID, m.Pool.String())
+}
+
+// RegisterRange assigns a range chosen by the caller to an owner. The range must not overlap with the ranges of
+// other owners of the organization.
+func (m *Manager) RegisterRange(organizationID string, ownerID string, ipRange string) (*entities.IPRange, derrors.Error) {
+	if ownerID == "" {
+		return nil, derrors.NewInvalidArgumentError("expecting owner_id")
+	}
+	network, err := cidr.Parse(ipRange)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.validOrganization(organizationID); err != nil {
+		return nil, err
+	}
+	ranges, err := m.IPAMProvider.ListRanges(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if overlapping := overlappingRange(ranges, network); overlapping != nil {
+		if overlapping.OwnerId == ownerID && overlapping.Cidr == network.String() {
+			return overlapping, nil
+		}
+		return nil, derrors.NewAlreadyExistsError("overlapping ip range").WithParams(network.String(), overlapping.Cidr)
+	}
+	toAdd := entities.NewIPRange(organizationID, network.String(), ownerID)
+	err = m.IPAMProvider.AddRange(*toAdd)
+	if err != nil {
+		return nil, err
+	}
+	return toAdd, nil
+}
+
+// GetOwnerRange retrieves the range assigned to an owner.
+func (m *Manager) GetOwnerRange(organizationID string, ownerID string) (*entities.IPRange, derrors.Error) {
+	ranges, err := m.IPAMProvider.ListRanges(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	assigned := ownerRange(ranges, ownerID)
+	if assigned == nil {
+		return nil, derrors.NewNotFoundError("ip range").WithParams(organizationID, ownerID)
+	}
+	return assigned, nil
+}
+
+// ReleaseRanges releases the ranges assigned to an owner and their addresses.
+func (m *Manager) ReleaseRanges(organizationID string, ownerID string) derrors.Error {
+	ranges, err := m.IPAMProvider.ListRanges(organizationID)
+	if err != nil {
+		return err
+	}
+	for _, ipRange := range ranges {
+		if ipRange.OwnerId != ownerID {
+			continue
+		}
+		err = m.IPAMProvider.RemoveAddresses(organizationID, ipRange.Cidr)
+		if err != nil {
+			return err
+		}
+		err = m.IPAMProvider.RemoveRange(organizationID, ipRange.Cidr)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AllocateAddress assigns a free host address of a range to a member. The address already assigned to the member
+// is returned if it exists.
+func (m *Manager) AllocateAddress(organizationID string, ipRange string, ownerID string) (*entities.IPAddress, derrors.Error) {
+	if ownerID == "" {
+		return nil, derrors.NewInvalidArgumentError("expecting owner_id")
+	}
+	assigned, err := m.IPAMProvider.GetRange(organizationID, ipRange)
+	if err != nil {
+		return nil, err
+	}
+	network, err := cidr.Parse(assigned.Cidr)
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := m.IPAMProvider.ListAddresses(organizationID, assigned.Cidr)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool, len(addresses))
+	for index := range addresses {
+		if addresses[index].OwnerId == ownerID {
+			return &addresses[index], nil
+		}
+		used[addresses[index].Ip] = true
+	}
+
+	for index := int64(0); index < cidr.Hosts(network); index++ {
+		ip := cidr.Host(network, index).String()
+		if used[ip] {
+			continue
+		}
+		toAdd := entities.NewIPAddress(organizationID, assigned.Cidr, ip, ownerID)
+		err = m.IPAMProvider.AddAddress(*toAdd)
+		if err == nil {
+			return toAdd, nil
+		}
+		if err.Type() != derrors.AlreadyExists {
+			return nil, err
+		}
+	}
+	return nil, derrors.NewFailedPreconditionError("ip range exhausted").WithParams(organizationID, assigned.Cidr)
+}
+
+// RegisterAddress assigns an address chosen by the caller to a member. The address must be a free host address of
+// the range.
+func (m *Manager) RegisterAddress(organizationID string, ipRange string, ip string, ownerID string) (*entities.IPAddress, derrors.Error) {
+	if ownerID == "" {
+		return nil, derrors.NewInvalidArgumentError("expecting owner_id")
+	}
+	assigned, err := m.IPAMProvider.GetRange(organizationID, ipRange)
+	if err != nil {
+		return nil, err
+	}
+	network, err := cidr.Parse(assigned.Cidr)
+	if err != nil {
+		return nil, err
+	}
+	address := net.ParseIP(ip)
+	if address == nil || !cidr.IsHost(network, address) {
+		return nil, derrors.NewInvalidArgumentError("ip is not a host address of the range").WithParams(ip, assigned.Cidr)
+	}
+	addresses, err := m.IPAMProvider.ListAddresses(organizationID, assigned.Cidr)
+	if err != nil {
+		return nil, err
+	}
+	for index := range addresses {
+		if addresses[index].Ip == address.String() {
+			if addresses[index].OwnerId == ownerID {
+				return &addresses[index], nil
+			}
+			return nil, derrors.NewAlreadyExistsError("ip address").WithParams(ip, addresses[index].OwnerId)
+		}
+	}
+	toAdd := entities.NewIPAddress(organizationID, assigned.Cidr, address.String(), ownerID)
+	err = m.IPAMProvider.AddAddress(*toAdd)
+	if err != nil {
+		return nil, err
+	}
+	return toAdd, nil
+}
+
+// ReleaseAddress releases an address of a range. Addresses that are not allocated are ignored.
+func (m *Manager) ReleaseAddress(organizationID string, ipRange string, ip string) derrors.Error {
+	err := m.IPAMProvider.RemoveAddress(organizationID, ipRange, ip)
+	if err != nil && err.Type() != derrors.NotFound {
+		return err
+	}
+	return nil
+}
+
+// GetUsage retrieves the utilization of the pool by an organization.
+func (m *Manager) GetUsage(organizationID *grpc_organization_go.OrganizationId) (*entities.IPAMUsage, derrors.Error) {
+	if err := m.validOrganization(organizationID.OrganizationId); err != nil {
+		return nil, err
+	}
+	ranges, err := m.IPAMProvider.ListRanges(organizationID.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Cidr < ranges[j].Cidr
+	})
+
+	usage := &entities.IPAMUsage{
+		OrganizationId:    organizationID.OrganizationId,
+		Pool:              m.Pool.String(),
+		BlockPrefixLength: m.Pool.BlockPrefixLength,
+		TotalBlocks:       m.Pool.Blocks(),
+		Ranges:            make([]entities.IPRangeUsage, 0, len(ranges)),
+	}
+	for _, ipRange := range ranges {
+		network, err := cidr.Parse(ipRange.Cidr)
+		if err != nil {
+			return nil, err
+		}
+		addresses, err := m.IPAMProvider.ListAddresses(organizationID.OrganizationId, ipRange.Cidr)
+		if err != nil {
+			return nil, err
+		}
+		usage.Ranges = append(usage.Ranges, entities.IPRangeUsage{
+			Cidr:      ipRange.Cidr,
+			OwnerId:   ipRange.OwnerId,
+			Capacity:  cidr.Hosts(network),
+			Allocated: int64(len(addresses)),
+		})
+	}
+	return usage, nil
+}
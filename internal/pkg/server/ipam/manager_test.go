@@ -0,0 +1,139 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ipam
+
+import (
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	ipamProvider "github.com/nalej/system-model/internal/pkg/provider/ipam"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("IPAM manager", func() {
+
+	var manager Manager
+	var organization *entities.Organization
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider := orgProvider.NewMockupOrganizationProvider()
+		pool, err := cidr.ParsePool("10.0.0.0/22", 24)
+		gomega.Expect(err).To(gomega.Succeed())
+		manager = NewManager(organizationProvider, ipamProvider.NewMockupIPAMProvider(), pool)
+		organization = testhelpers.AddOrganization(organizationProvider)
+	})
+
+	ginkgo.Context("ranges", func() {
+		ginkgo.It("should allocate non overlapping ranges", func() {
+			first, err := manager.AllocateRange(organization.ID, "conn1")
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(first.Cidr).Should(gomega.Equal("10.0.0.0/24"))
+
+			_, err = manager.RegisterRange(organization.ID, "conn2", "10.0.1.0/24")
+			gomega.Expect(err).To(gomega.Succeed())
+
+			third, err := manager.AllocateRange(organization.ID, "conn3")
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(third.Cidr).Should(gomega.Equal("10.0.2.0/24"))
+
+			again, err := manager.AllocateRange(organization.ID, "conn1")
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(again.Cidr).Should(gomega.Equal(first.Cidr))
+		})
+		ginkgo.It("should reject overlapping ranges", func() {
+			_, err := manager.RegisterRange(organization.ID, "conn1", "10.0.0.0/23")
+			gomega.Expect(err).To(gomega.Succeed())
+			_, err = manager.RegisterRange(organization.ID, "conn2", "10.0.1.128/25")
+			gomega.Expect(err).NotTo(gomega.Succeed())
+			_, err = manager.RegisterRange(organization.ID, "conn1", "10.0.0.0/23")
+			gomega.Expect(err).To(gomega.Succeed())
+		})
+		ginkgo.It("should fail when the pool is exhausted", func() {
+			for _, owner := range []string{"a", "b", "c", "d"} {
+				_, err := manager.AllocateRange(organization.ID, owner)
+				gomega.Expect(err).To(gomega.Succeed())
+			}
+			_, err := manager.AllocateRange(organization.ID, "e")
+			gomega.Expect(err).NotTo(gomega.Succeed())
+
+			gomega.Expect(manager.ReleaseRanges(organization.ID, "b")).To(gomega.Succeed())
+			released, err := manager.AllocateRange(organization.ID, "e")
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(released.Cidr).Should(gomega.Equal("10.0.1.0/24"))
+		})
+		ginkgo.It("should fail on an unknown organization", func() {
+			_, err := manager.AllocateRange(entities.GenerateUUID(), "conn1")
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+	})
+
+	ginkgo.Context("addresses", func() {
+		var ipRange *entities.IPRange
+		ginkgo.BeforeEach(func() {
+			var err error
+			ipRange, err = manager.RegisterRange(organization.ID, "conn1", "10.0.0.0/30")
+			gomega.Expect(err).To(gomega.Succeed())
+		})
+		ginkgo.It("should allocate the host addresses of a range", func() {
+			first, err := manager.AllocateAddress(organization.ID, ipRange.Cidr, "member1")
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(first.Ip).Should(gomega.Equal("10.0.0.1"))
+			second, err := manager.AllocateAddress(organization.ID, ipRange.Cidr, "member2")
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(second.Ip).Should(gomega.Equal("10.0.0.2"))
+
+			_, err = manager.AllocateAddress(organization.ID, ipRange.Cidr, "member3")
+			gomega.Expect(err).NotTo(gomega.Succeed())
+
+			gomega.Expect(manager.ReleaseAddress(organization.ID, ipRange.Cidr, first.Ip)).To(gomega.Succeed())
+			third, err := manager.AllocateAddress(organization.ID, ipRange.Cidr, "member3")
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(third.Ip).Should(gomega.Equal(first.Ip))
+		})
+		ginkgo.It("should register addresses chosen by the caller", func() {
+			_, err := manager.RegisterAddress(organization.ID, ipRange.Cidr, "10.0.0.2", "member1")
+			gomega.Expect(err).To(gomega.Succeed())
+			_, err = manager.RegisterAddress(organization.ID, ipRange.Cidr, "10.0.0.2", "member2")
+			gomega.Expect(err).NotTo(gomega.Succeed())
+			_, err = manager.RegisterAddress(organization.ID, ipRange.Cidr, "10.0.0.3", "member2")
+			gomega.Expect(err).NotTo(gomega.Succeed())
+
+			allocated, err := manager.AllocateAddress(organization.ID, ipRange.Cidr, "member2")
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(allocated.Ip).Should(gomega.Equal("10.0.0.1"))
+		})
+		ginkgo.It("should report the utilization", func() {
+			_, err := manager.AllocateAddress(organization.ID, ipRange.Cidr, "member1")
+			gomega.Expect(err).To(gomega.Succeed())
+
+			usage, err := manager.GetUsage(&grpc_organization_go.OrganizationId{OrganizationId: organization.ID})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(usage.TotalBlocks).Should(gomega.Equal(int64(4)))
+			gomega.Expect(usage.Ranges).Should(gomega.Equal([]entities.IPRangeUsage{
+				{Cidr: "10.0.0.0/30", OwnerId: "conn1", Capacity: 2, Allocated: 1},
+			}))
+
+			gomega.Expect(manager.ReleaseRanges(organization.ID, "conn1")).To(gomega.Succeed())
+			usage, err = manager.GetUsage(&grpc_organization_go.OrganizationId{OrganizationId: organization.ID})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(usage.Ranges).Should(gomega.BeEmpty())
+		})
+	})
+})
@@ -22,6 +22,7 @@ import (
 	"github.com/nalej/grpc-infrastructure-go"
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
 	"github.com/rs/zerolog/log"
 )
 
@@ -46,7 +47,7 @@ func (h *Handler) AddNode(ctx context.Context, addNodeRequest *grpc_infrastructu
 	added, err := h.Manager.AddNode(addNodeRequest)
 	if err != nil {
 		log.Error().Str("trace", err.DebugReport()).Msg("cannot add node")
-		return nil, conversions.ToGRPCError(err)
+		return nil, quota.ToGRPCError(err)
 	}
 	log.Debug().Str("nodeID", added.NodeId).Msg("node has been added")
 	return added.ToGRPC(), nil
@@ -80,7 +80,7 @@ var _ = ginkgo.Describe("Node service", func() {
 		organizationProvider = orgProvider.NewMockupOrganizationProvider()
 		clusterProvider = clusProvider.NewMockupClusterProvider()
 		nProvider = nodeProvider.NewMockupNodeProvider()
-		manager := NewManager(organizationProvider, clusterProvider, nProvider)
+		manager := NewManager(organizationProvider, clusterProvider, nProvider, testhelpers.NewQuotaManager(organizationProvider))
 		handler := NewHandler(manager)
 		grpc_infrastructure_go.RegisterNodesServer(server, handler)
 
@@ -24,6 +24,8 @@ import (
 	"github.com/nalej/system-model/internal/pkg/provider/cluster"
 	"github.com/nalej/system-model/internal/pkg/provider/node"
 	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
+	"github.com/nalej/system-model/internal/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
 
@@ -32,14 +34,16 @@ type Manager struct {
 	OrgProvider     organization.Provider
 	ClusterProvider cluster.Provider
 	NodeProvider    node.Provider
+	Quota           quota.Manager
 }
 
 // NewManager creates a Manager using a set of providers.
 func NewManager(
 	orgProvider organization.Provider,
 	clusterProvider cluster.Provider,
-	nodeProvider node.Provider) Manager {
-	return Manager{orgProvider, clusterProvider, nodeProvider}
+	nodeProvider node.Provider,
+	quotaManager quota.Manager) Manager {
+	return Manager{orgProvider, clusterProvider, nodeProvider, quotaManager}
 }
 
 // AddNode adds a new node to the system.
@@ -51,13 +55,19 @@ func (m *Manager) AddNode(addNodeRequest *grpc_infrastructure_go.AddNodeRequest)
 	if !exists {
 		return nil, derrors.NewNotFoundError("organizationID").WithParams(addNodeRequest.OrganizationId)
 	}
+	err = m.Quota.Acquire(addNodeRequest.OrganizationId, entities.NodeQuota, 1)
+	if err != nil {
+		return nil, err
+	}
 	toAdd := entities.NewNodeFromGRPC(addNodeRequest)
 	err = m.NodeProvider.Add(*toAdd)
 	if err != nil {
+		m.Quota.Release(toAdd.OrganizationId, entities.NodeQuota, 1)
 		return nil, err
 	}
 	err = m.OrgProvider.AddNode(toAdd.OrganizationId, toAdd.NodeId)
 	if err != nil {
+		m.Quota.Release(toAdd.OrganizationId, entities.NodeQuota, 1)
 		return nil, err
 	}
 	return toAdd, nil
@@ -78,16 +88,25 @@ func (m *Manager) UpdateNode(updateNodeRequest *grpc_infrastructure_go.UpdateNod
 	if !exists {
 		return nil, derrors.NewNotFoundError("nodeID").WithParams(updateNodeRequest.NodeId)
 	}
-	old, err := m.NodeProvider.Get(updateNodeRequest.NodeId)
-	if err != nil {
-		return nil, err
-	}
-	old.ApplyUpdate(*updateNodeRequest)
-	err = m.NodeProvider.Update(*old)
+	var updated *entities.Node
+	err = utils.RetryOnConflict(func() derrors.Error {
+		old, err := m.NodeProvider.Get(updateNodeRequest.NodeId)
+		if err != nil {
+			return err
+		}
+		old.ApplyUpdate(*updateNodeRequest)
+		err = m.NodeProvider.Update(*old)
+		if err != nil {
+			return err
+		}
+		old.Version++
+		updated = old
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	return old, nil
+	return updated, nil
 }
 
 // AttachNode links a node with a given cluster.
@@ -113,20 +132,18 @@ func (m *Manager) AttachNode(attachNodeRequest *grpc_infrastructure_go.AttachNod
 	if !exists {
 		return derrors.NewNotFoundError("nodeID").WithParams(attachNodeRequest.NodeId)
 	}
-	retrieved, err := m.NodeProvider.Get(attachNodeRequest.NodeId)
-	if err != nil {
-		return err
-	}
 	err = m.ClusterProvider.AddNode(attachNodeRequest.ClusterId, attachNodeRequest.NodeId)
 	if err != nil {
 		return err
 	}
-	retrieved.ClusterId = attachNodeRequest.ClusterId
-	err = m.NodeProvider.Update(*retrieved)
-	if err != nil {
-		return err
-	}
-	return nil
+	return utils.RetryOnConflict(func() derrors.Error {
+		retrieved, err := m.NodeProvider.Get(attachNodeRequest.NodeId)
+		if err != nil {
+			return err
+		}
+		retrieved.ClusterId = attachNodeRequest.ClusterId
+		return m.NodeProvider.Update(*retrieved)
+	})
 }
 
 // ListNodes obtains a list of nodes in a cluster.
@@ -210,6 +227,7 @@ func (m *Manager) RemoveNodes(removeNodesRequest *grpc_infrastructure_go.RemoveN
 			}
 			return err
 		}
+		m.Quota.Release(node.OrganizationId, entities.NodeQuota, 1)
 	}
 
 	return nil
@@ -22,8 +22,12 @@ import (
 	"github.com/nalej/grpc-common-go"
 	"github.com/nalej/grpc-organization-go"
 	"github.com/nalej/grpc-utils/pkg/test"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/name_reservation"
 	"github.com/nalej/system-model/internal/pkg/provider/organization"
 	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	"github.com/nalej/system-model/internal/pkg/provider/service_account"
+	"github.com/nalej/system-model/internal/pkg/provider/user"
 	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
@@ -41,6 +45,7 @@ var _ = ginkgo.Describe("Organization service", func() {
 
 	var orgProvider organization.Provider
 	var settingProvider organization_setting.Provider
+	var nameProvider name_reservation.Provider
 
 	ginkgo.BeforeSuite(func() {
 		listener = test.GetDefaultListener()
@@ -49,7 +54,10 @@ var _ = ginkgo.Describe("Organization service", func() {
 		// Register the service
 		orgProvider = organization.NewMockupOrganizationProvider()
 		settingProvider = organization_setting.NewMockupOrganizationSettingProvider()
-		manager := NewManager(orgProvider, settingProvider)
+		nameProvider = name_reservation.NewMockupNameReservationProvider()
+		manager := NewManager(orgProvider, settingProvider, user.NewMockupUserProvider(),
+			service_account.NewMockupServiceAccountProvider(), nameProvider,
+			testhelpers.NewPhotoManager(), newRoleManager(orgProvider), false)
 		handler := NewHandler(manager)
 		grpc_organization_go.RegisterOrganizationsServer(server, handler)
 
@@ -67,6 +75,7 @@ var _ = ginkgo.Describe("Organization service", func() {
 
 	ginkgo.BeforeEach(func() {
 		orgProvider.Clear()
+		nameProvider.Clear()
 	})
 
 	ginkgo.Context("adding organization", func() {
@@ -345,7 +354,8 @@ var _ = ginkgo.Describe("Organization service", func() {
 			})
 			gomega.Expect(err).Should(gomega.Succeed())
 			gomega.Expect(list).NotTo(gomega.BeNil())
-			gomega.Expect(len(list.Settings)).Should(gomega.Equal(numSettings))
+			// the known settings that are not set are listed with their default value
+			gomega.Expect(len(list.Settings)).Should(gomega.Equal(numSettings + len(entities.ListSettingSchemas())))
 
 		})
 		ginkgo.It("Should be able to return an empty list of settings", func() {
@@ -360,7 +370,7 @@ var _ = ginkgo.Describe("Organization service", func() {
 			})
 			gomega.Expect(err).Should(gomega.Succeed())
 			gomega.Expect(list).NotTo(gomega.BeNil())
-			gomega.Expect(len(list.Settings)).Should(gomega.Equal(0))
+			gomega.Expect(len(list.Settings)).Should(gomega.Equal(len(entities.ListSettingSchemas())))
 
 		})
 		ginkgo.It("Should not be able to return a list of settings if the organization does not exist", func() {
@@ -20,26 +20,67 @@ import (
 	"github.com/nalej/derrors"
 	"github.com/nalej/grpc-organization-go"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/name_reservation"
 	"github.com/nalej/system-model/internal/pkg/provider/organization"
 	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	"github.com/nalej/system-model/internal/pkg/provider/service_account"
+	"github.com/nalej/system-model/internal/pkg/provider/user"
+	"github.com/nalej/system-model/internal/pkg/server/photo"
+	"github.com/nalej/system-model/internal/pkg/server/role"
+	"github.com/nalej/system-model/internal/pkg/utils"
+	"github.com/rs/zerolog/log"
 )
 
 // Manager structure with the required providers for organization operations.
 type Manager struct {
-	Provider        organization.Provider
-	SettingProvider organization_setting.Provider
+	Provider               organization.Provider
+	SettingProvider        organization_setting.Provider
+	UserProvider           user.Provider
+	ServiceAccountProvider service_account.Provider
+	NameProvider           name_reservation.Provider
+	Photos                 photo.Manager
+	// Roles creates the roles of the new organizations from the role templates.
+	Roles role.Manager
+	// StrictSettings rejects the settings that are not defined in the settings schema registry.
+	StrictSettings bool
+	// SettingSchemas with the registry of the known settings, the system registry unless another one is set.
+	SettingSchemas *entities.SettingSchemaRegistry
 }
 
 // NewManager creates a Manager using a set of providers.
-func NewManager(provider organization.Provider, settingProvider organization_setting.Provider) Manager {
-	return Manager{Provider: provider, SettingProvider: settingProvider}
+func NewManager(provider organization.Provider, settingProvider organization_setting.Provider, userProvider user.Provider,
+	serviceAccountProvider service_account.Provider, nameProvider name_reservation.Provider, photoManager photo.Manager,
+	roleManager role.Manager, strictSettings bool) Manager {
+	return Manager{Provider: provider, SettingProvider: settingProvider, UserProvider: userProvider,
+		ServiceAccountProvider: serviceAccountProvider, NameProvider: nameProvider, Photos: photoManager,
+		Roles: roleManager, StrictSettings: strictSettings, SettingSchemas: entities.SettingSchemas}
 }
 
-// AddOrganization adds a new organization to the system.
+// fillPhoto sets the photo of an organization kept in the blob store, or its thumbnail. The photos of the
+// organizations that have not been migrated to the blob store are kept in the organization.
+func (m *Manager) fillPhoto(org *entities.Organization, thumbnail bool) {
+	blobID := org.PhotoId
+	if thumbnail {
+		blobID = org.ThumbnailId
+	}
+	if blobID == "" {
+		return
+	}
+	photo, err := m.Photos.Load(blobID)
+	if err != nil {
+		log.Warn().Str("organizationId", org.ID).Str("blobId", blobID).Str("trace", err.DebugReport()).Msg("cannot load organization photo")
+		return
+	}
+	org.PhotoBase64 = photo
+}
+
+// AddOrganization adds a new organization to the system with the roles defined by the role templates. If the roles
+// cannot be created, the organization, its photo and the reservation of its name are removed.
 func (m *Manager) AddOrganization(toAdd grpc_organization_go.AddOrganizationRequest) (*entities.Organization, derrors.Error) {
 	newOrg := entities.NewOrganization(toAdd.Name, toAdd.Email, toAdd.FullAddress, toAdd.City,
 		toAdd.State, toAdd.Country, toAdd.ZipCode, toAdd.PhotoBase64)
 
+	// the names of the organizations created before the reservations were introduced may not be reserved
 	exists, err := m.Provider.ExistsByName(newOrg.Name)
 	if err != nil {
 		return nil, err
@@ -47,9 +88,36 @@ func (m *Manager) AddOrganization(toAdd grpc_organization_go.AddOrganizationRequ
 	if exists {
 		return nil, derrors.NewAlreadyExistsError(newOrg.Name)
 	}
+	err = m.NameProvider.Reserve(*entities.NewNameReservation(entities.OrganizationNameScope, newOrg.Name, newOrg.ID))
+	if err != nil {
+		if err.Type() == derrors.AlreadyExists {
+			return nil, derrors.NewAlreadyExistsError(newOrg.Name)
+		}
+		return nil, err
+	}
 
-	err = m.Provider.Add(*newOrg)
+	newOrg.PhotoId, newOrg.ThumbnailId, err = m.Photos.Store(newOrg.PhotoBase64)
+	if err == nil {
+		// only the reference to the photo is kept in the organization
+		stored := *newOrg
+		stored.PhotoBase64 = ""
+		err = m.Provider.Add(stored)
+		if err == nil {
+			_, err = m.Roles.AddTemplateRoles(newOrg.ID)
+			if err != nil {
+				if rErr := m.Provider.Remove(newOrg.ID); rErr != nil {
+					log.Warn().Str("organizationId", newOrg.ID).Str("trace", rErr.DebugReport()).Msg("cannot remove organization")
+				}
+			}
+		}
+		if err != nil {
+			m.Photos.Remove(newOrg.PhotoId, newOrg.ThumbnailId)
+		}
+	}
 	if err != nil {
+		if rErr := m.NameProvider.Release(entities.OrganizationNameScope, newOrg.Name, newOrg.ID); rErr != nil {
+			log.Warn().Str("name", newOrg.Name).Str("trace", rErr.DebugReport()).Msg("cannot release organization name")
+		}
 		return nil, err
 	}
 	return newOrg, nil
@@ -57,12 +125,26 @@ func (m *Manager) AddOrganization(toAdd grpc_organization_go.AddOrganizationRequ
 
 // GetOrganization retrieves the profile information of a given organization.
 func (m *Manager) GetOrganization(orgID grpc_organization_go.OrganizationId) (*entities.Organization, derrors.Error) {
-	return m.Provider.Get(orgID.OrganizationId)
+	org, err := m.Provider.Get(orgID.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	m.fillPhoto(org, false)
+	return org, nil
 }
 
-// ListOrganization retrieves the profile information of a given organization.
+// ListOrganization retrieves the profile information of the organizations, with the thumbnails of their photos. Each
+// thumbnail is read from the blob store on its own, so the cost of the list grows with the organizations that have a
+// photo.
 func (m *Manager) ListOrganization() ([]entities.Organization, derrors.Error) {
-	return m.Provider.List()
+	organizations, err := m.Provider.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range organizations {
+		m.fillPhoto(&organizations[i], true)
+	}
+	return organizations, nil
 }
 
 func (m *Manager) UpdateOrganization(newOrg *grpc_organization_go.UpdateOrganizationRequest) derrors.Error {
@@ -71,8 +153,10 @@ func (m *Manager) UpdateOrganization(newOrg *grpc_organization_go.UpdateOrganiza
 	if err != nil {
 		return err
 	}
+	oldName := org.Name
+	renamed := newOrg.UpdateName && newOrg.Name != oldName
 	// if the name is going to be updated to a different name..
-	if newOrg.UpdateName && newOrg.Name != org.Name {
+	if renamed {
 		// check if there is an organization with the new name
 		exists, err := m.Provider.ExistsByName(newOrg.Name)
 		if err != nil {
@@ -81,15 +165,139 @@ func (m *Manager) UpdateOrganization(newOrg *grpc_organization_go.UpdateOrganiza
 		if exists {
 			return derrors.NewAlreadyExistsError("name").WithParams(newOrg.Name)
 		}
+		// move the reservation to the new name
+		err = m.NameProvider.Rename(entities.OrganizationNameScope, oldName, newOrg.Name, org.ID)
+		if err != nil {
+			if err.Type() == derrors.AlreadyExists {
+				return derrors.NewAlreadyExistsError("name").WithParams(newOrg.Name)
+			}
+			return err
+		}
 	}
 
+	replaced := []string{org.PhotoId, org.ThumbnailId}
 	org.ApplyUpdate(newOrg)
-	return m.Provider.Update(*org)
+	if newOrg.UpdatePhoto {
+		org.PhotoId, org.ThumbnailId, err = m.Photos.Store(newOrg.PhotoBase64)
+		org.PhotoBase64 = ""
+	}
+	if err == nil {
+		err = m.Provider.Update(*org)
+		if err != nil && newOrg.UpdatePhoto {
+			m.Photos.Remove(org.PhotoId, org.ThumbnailId)
+		}
+	}
+	if err != nil {
+		if renamed {
+			if rErr := m.NameProvider.Rename(entities.OrganizationNameScope, newOrg.Name, oldName, org.ID); rErr != nil {
+				log.Warn().Str("name", newOrg.Name).Str("trace", rErr.DebugReport()).Msg("cannot restore organization name")
+			}
+		}
+		return err
+	}
+	if newOrg.UpdatePhoto {
+		m.Photos.Remove(replaced...)
+	}
+	return nil
+
+}
+
+// MigratePhotos moves the photos kept in the organizations to the blob store. Photos that are not valid are kept in
+// the organizations, and organizations already migrated are skipped, so the migration can be run several times. It
+// returns the number of photos migrated.
+func (m *Manager) MigratePhotos() (int, derrors.Error) {
+	organizations, err := m.Provider.List()
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for _, org := range organizations {
+		if org.PhotoId != "" || org.PhotoBase64 == "" {
+			continue
+		}
+		org.PhotoId, org.ThumbnailId, err = m.Photos.Store(org.PhotoBase64)
+		if err != nil {
+			if err.Type() == derrors.InvalidArgument {
+				log.Warn().Str("organizationId", org.ID).Str("trace", err.DebugReport()).Msg("cannot migrate organization photo, skipping")
+				continue
+			}
+			return migrated, err
+		}
+		org.PhotoBase64 = ""
+		err = m.Provider.Update(org)
+		if err != nil {
+			m.Photos.Remove(org.PhotoId, org.ThumbnailId)
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// ReserveOrganizationNames reserves the names of the existing organizations, returning the names used by more than
+// one organization. It can be run several times.
+func (m *Manager) ReserveOrganizationNames() ([]entities.NameConflict, derrors.Error) {
+	organizations, err := m.Provider.List()
+	if err != nil {
+		return nil, err
+	}
+	conflicts := make([]entities.NameConflict, 0)
+	for _, org := range organizations {
+		err := m.NameProvider.Reserve(*entities.NewNameReservation(entities.OrganizationNameScope, org.Name, org.ID))
+		if err == nil {
+			continue
+		}
+		if err.Type() != derrors.AlreadyExists {
+			return nil, err
+		}
+		reservation, err := m.NameProvider.Get(entities.OrganizationNameScope, org.Name)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, entities.NameConflict{Scope: entities.OrganizationNameScope, Name: org.Name,
+			OwnerId: org.ID, ReservedBy: reservation.OwnerId})
+	}
+	return conflicts, nil
+}
+
+// checkEditableSetting rejects the changes of the known settings that cannot be changed by the organizations, such as
+// the quota limits. It returns the schema of the setting, or nil if the setting is not known.
+func (m *Manager) checkEditableSetting(key string) (*entities.SettingSchema, derrors.Error) {
+	schema, known := m.SettingSchemas.Get(key)
+	if !known {
+		return nil, nil
+	}
+	if !schema.Editable {
+		return nil, derrors.NewInvalidArgumentError("setting cannot be changed by the organization").WithParams(key)
+	}
+	return schema, nil
+}
 
+// validateSetting checks the value of a setting against its schema. Unknown settings are only accepted if the settings
+// are not strict.
+func (m *Manager) validateSetting(key string, value string) (*entities.SettingSchema, derrors.Error) {
+	schema, err := m.checkEditableSetting(key)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		if m.StrictSettings {
+			return nil, derrors.NewInvalidArgumentError("unknown setting").WithParams(key)
+		}
+		return nil, nil
+	}
+	if err := schema.Validate(value); err != nil {
+		return nil, err
+	}
+	return schema, nil
 }
 
 // AddSetting adds a new setting for the organization
 func (m *Manager) AddSetting(addRequest *grpc_organization_go.AddSettingRequest) (*entities.OrganizationSetting, derrors.Error) {
+	schema, err := m.validateSetting(addRequest.Key, addRequest.Value)
+	if err != nil {
+		return nil, err
+	}
 
 	// check if the organization exists
 	exists, err := m.Provider.Exists(addRequest.OrganizationId)
@@ -100,6 +308,9 @@ func (m *Manager) AddSetting(addRequest *grpc_organization_go.AddSettingRequest)
 		return nil, derrors.NewNotFoundError("organization").WithParams(addRequest.OrganizationId)
 	}
 	setting := entities.NewOrganizationSettingFromGRPC(addRequest)
+	if schema != nil && setting.Description == "" {
+		setting.Description = schema.Description
+	}
 	err = m.SettingProvider.Add(*setting)
 	if err != nil {
 		return nil, err
@@ -107,7 +318,7 @@ func (m *Manager) AddSetting(addRequest *grpc_organization_go.AddSettingRequest)
 	return setting, nil
 }
 
-// GetSetting returns an OrganizationSetting
+// GetSetting returns an OrganizationSetting. The default value is returned for the known settings that are not set.
 func (m *Manager) GetSetting(in *grpc_organization_go.SettingKey) (*entities.OrganizationSetting, derrors.Error) {
 	// check if the organization exists
 	exists, err := m.Provider.Exists(in.OrganizationId)
@@ -118,11 +329,20 @@ func (m *Manager) GetSetting(in *grpc_organization_go.SettingKey) (*entities.Org
 		return nil, derrors.NewNotFoundError("organization").WithParams(in.OrganizationId)
 	}
 
-	return m.SettingProvider.Get(in.OrganizationId, in.Key)
-
+	setting, err := m.SettingProvider.Get(in.OrganizationId, in.Key)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			if schema, known := m.SettingSchemas.Get(in.Key); known {
+				return schema.DefaultSetting(in.OrganizationId), nil
+			}
+		}
+		return nil, err
+	}
+	return setting, nil
 }
 
-// ListSettings returns a list of settings of an organization
+// ListSettings returns a list of settings of an organization, including the known settings that are not set with
+// their default value.
 func (m *Manager) ListSettings(in *grpc_organization_go.OrganizationId) ([]entities.OrganizationSetting, derrors.Error) {
 	// check if the organization exists
 	exists, err := m.Provider.Exists(in.OrganizationId)
@@ -133,22 +353,49 @@ func (m *Manager) ListSettings(in *grpc_organization_go.OrganizationId) ([]entit
 		return nil, derrors.NewNotFoundError("organization").WithParams(in.OrganizationId)
 	}
 
-	return m.SettingProvider.List(in.OrganizationId)
+	settings, err := m.SettingProvider.List(in.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	schemas := m.SettingSchemas.List()
+	result := make([]entities.OrganizationSetting, 0, len(settings)+len(schemas))
+	stored := make(map[string]bool, len(settings))
+	for _, setting := range settings {
+		result = append(result, setting)
+		stored[setting.Key] = true
+	}
+	for _, schema := range schemas {
+		if !stored[schema.Key] {
+			result = append(result, *schema.DefaultSetting(in.OrganizationId))
+		}
+	}
+	return result, nil
 }
 
 // UpdateSetting update the value and/or the description of a setting
 func (m *Manager) UpdateSetting(updateRequest *grpc_organization_go.UpdateSettingRequest) derrors.Error {
-
-	setting, err := m.SettingProvider.Get(updateRequest.OrganizationId, updateRequest.Key)
-	if err != nil {
+	if updateRequest.UpdateValue {
+		if _, err := m.validateSetting(updateRequest.Key, updateRequest.Value); err != nil {
+			return err
+		}
+	} else if _, err := m.checkEditableSetting(updateRequest.Key); err != nil {
 		return err
 	}
-	setting.ApplyUpdate(updateRequest)
-	return m.SettingProvider.Update(*setting)
+	return utils.RetryOnConflict(func() derrors.Error {
+		setting, err := m.SettingProvider.Get(updateRequest.OrganizationId, updateRequest.Key)
+		if err != nil {
+			return err
+		}
+		setting.ApplyUpdate(updateRequest)
+		return m.SettingProvider.Update(*setting)
+	})
 }
 
 // RemoveSetting removes a given setting of an organization
 func (m *Manager) RemoveSetting(key *grpc_organization_go.SettingKey) derrors.Error {
+	if _, err := m.checkEditableSetting(key.Key); err != nil {
+		return err
+	}
 	// check if the organization exists
 	exists, err := m.Provider.Exists(key.OrganizationId)
 	if err != nil {
@@ -160,3 +407,98 @@ func (m *Manager) RemoveSetting(key *grpc_organization_go.SettingKey) derrors.Er
 
 	return m.SettingProvider.Remove(key.OrganizationId, key.Key)
 }
+
+// ListUserOrganizations retrieves the organizations a user is a member of, with the thumbnails of their photos.
+func (m *Manager) ListUserOrganizations(email string) ([]entities.Organization, derrors.Error) {
+	memberships, err := m.UserProvider.ListMemberships(email)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]entities.Organization, 0, len(memberships))
+	for _, membership := range memberships {
+		org, err := m.Provider.Get(membership.OrganizationId)
+		if err != nil {
+			return nil, err
+		}
+		m.fillPhoto(org, true)
+		result = append(result, *org)
+	}
+	return result, nil
+}
+
+// AddServiceAccount adds a new service account to an organization.
+func (m *Manager) AddServiceAccount(organizationID string, name string, description string) (*entities.ServiceAccount, derrors.Error) {
+	if err := entities.ValidServiceAccount(organizationID, name); err != nil {
+		return nil, err
+	}
+	exists, err := m.Provider.Exists(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, derrors.NewNotFoundError("organization").WithParams(organizationID)
+	}
+	account := entities.NewServiceAccount(organizationID, name, description)
+	err = m.ServiceAccountProvider.Add(*account)
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// GetServiceAccount retrieves a service account of an organization.
+func (m *Manager) GetServiceAccount(organizationID string, serviceAccountID string) (*entities.ServiceAccount, derrors.Error) {
+	return m.ServiceAccountProvider.Get(organizationID, serviceAccountID)
+}
+
+// ListServiceAccounts retrieves the service accounts of an organization.
+func (m *Manager) ListServiceAccounts(organizationID string) ([]entities.ServiceAccount, derrors.Error) {
+	exists, err := m.Provider.Exists(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, derrors.NewNotFoundError("organization").WithParams(organizationID)
+	}
+	return m.ServiceAccountProvider.List(organizationID)
+}
+
+// UpdateServiceAccount updates the name, description or enabled flag of a service account. Disabling a service
+// account rejects all its keys.
+func (m *Manager) UpdateServiceAccount(request entities.UpdateServiceAccountRequest) (*entities.ServiceAccount, derrors.Error) {
+	account, err := m.ServiceAccountProvider.Get(request.OrganizationId, request.ServiceAccountId)
+	if err != nil {
+		return nil, err
+	}
+	account.ApplyUpdate(request)
+	if err := entities.ValidServiceAccount(account.OrganizationId, account.Name); err != nil {
+		return nil, err
+	}
+	err = m.ServiceAccountProvider.Update(*account)
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// RemoveServiceAccount removes a service account and all its keys.
+func (m *Manager) RemoveServiceAccount(organizationID string, serviceAccountID string) derrors.Error {
+	exists, err := m.ServiceAccountProvider.Exists(organizationID, serviceAccountID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("service account").WithParams(organizationID, serviceAccountID)
+	}
+	keys, err := m.ServiceAccountProvider.ListAPIKeys(organizationID, serviceAccountID)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		err = m.ServiceAccountProvider.RemoveAPIKey(key.KeyId)
+		if err != nil {
+			return err
+		}
+	}
+	return m.ServiceAccountProvider.Remove(organizationID, serviceAccountID)
+}
@@ -0,0 +1,543 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package organization
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/blob"
+	"github.com/nalej/system-model/internal/pkg/provider/name_reservation"
+	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	rProvider "github.com/nalej/system-model/internal/pkg/provider/role"
+	"github.com/nalej/system-model/internal/pkg/provider/service_account"
+	"github.com/nalej/system-model/internal/pkg/provider/user"
+	"github.com/nalej/system-model/internal/pkg/server/photo"
+	"github.com/nalej/system-model/internal/pkg/server/role"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
+	"github.com/nalej/system-model/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"sync"
+)
+
+// newRoleManager creates a role manager that adds the default roles to the organizations.
+func newRoleManager(orgProvider organization.Provider) role.Manager {
+	return role.NewManager(orgProvider, rProvider.NewMockupRoleProvider(), name_reservation.NewMockupNameReservationProvider(),
+		entities.DefaultRoleTemplates())
+}
+
+// recordingBlobProvider keeps the identifiers of the blobs added.
+type recordingBlobProvider struct {
+	blob.Provider
+	added []string
+}
+
+func (r *recordingBlobProvider) Add(toAdd entities.Blob) derrors.Error {
+	r.added = append(r.added, toAdd.BlobId)
+	return r.Provider.Add(toAdd)
+}
+
+var _ = ginkgo.Describe("Organization service accounts", func() {
+
+	var manager Manager
+	var serviceAccountProvider service_account.Provider
+	var targetOrganization *entities.Organization
+
+	ginkgo.BeforeEach(func() {
+		orgProvider := organization.NewMockupOrganizationProvider()
+		serviceAccountProvider = service_account.NewMockupServiceAccountProvider()
+		manager = NewManager(orgProvider, organization_setting.NewMockupOrganizationSettingProvider(),
+			user.NewMockupUserProvider(), serviceAccountProvider, name_reservation.NewMockupNameReservationProvider(),
+			testhelpers.NewPhotoManager(), newRoleManager(orgProvider), false)
+		targetOrganization = testhelpers.AddOrganization(orgProvider)
+	})
+
+	ginkgo.It("should add and list service accounts", func() {
+		added, err := manager.AddServiceAccount(targetOrganization.ID, "ci", "CI pipeline")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(added.Enabled).To(gomega.BeTrue())
+
+		retrieved, err := manager.GetServiceAccount(targetOrganization.ID, added.ServiceAccountId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved).To(gomega.Equal(added))
+
+		accounts, err := manager.ListServiceAccounts(targetOrganization.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(accounts)).Should(gomega.Equal(1))
+	})
+
+	ginkgo.It("should not add service accounts to missing organizations", func() {
+		_, err := manager.AddServiceAccount(entities.GenerateUUID(), "ci", "")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		_, err = manager.AddServiceAccount(targetOrganization.ID, "", "")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should update a service account", func() {
+		added, err := manager.AddServiceAccount(targetOrganization.ID, "ci", "CI pipeline")
+		gomega.Expect(err).To(gomega.Succeed())
+
+		updated, err := manager.UpdateServiceAccount(entities.UpdateServiceAccountRequest{
+			OrganizationId:   targetOrganization.ID,
+			ServiceAccountId: added.ServiceAccountId,
+			UpdateEnabled:    true,
+			Enabled:          false,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(updated.Enabled).To(gomega.BeFalse())
+		gomega.Expect(updated.Name).Should(gomega.Equal("ci"))
+	})
+
+	ginkgo.It("should remove a service account with its keys", func() {
+		added, err := manager.AddServiceAccount(targetOrganization.ID, "ci", "CI pipeline")
+		gomega.Expect(err).To(gomega.Succeed())
+		key, _, err := entities.NewAPIKey(targetOrganization.ID, added.ServiceAccountId,
+			[]entities.Permission{{Resource: entities.AnyResource, Action: entities.ActionRead}}, 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(serviceAccountProvider.AddAPIKey(*key)).To(gomega.Succeed())
+
+		gomega.Expect(manager.RemoveServiceAccount(targetOrganization.ID, added.ServiceAccountId)).To(gomega.Succeed())
+		_, err = manager.GetServiceAccount(targetOrganization.ID, added.ServiceAccountId)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		_, err = serviceAccountProvider.GetAPIKey(key.KeyId)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
+
+var _ = ginkgo.Describe("Organization names", func() {
+
+	var manager Manager
+	var nameProvider name_reservation.Provider
+
+	ginkgo.BeforeEach(func() {
+		nameProvider = name_reservation.NewMockupNameReservationProvider()
+		orgProvider := organization.NewMockupOrganizationProvider()
+		manager = NewManager(orgProvider, organization_setting.NewMockupOrganizationSettingProvider(),
+			user.NewMockupUserProvider(), service_account.NewMockupServiceAccountProvider(), nameProvider,
+			testhelpers.NewPhotoManager(), newRoleManager(orgProvider), false)
+	})
+
+	ginkgo.It("should add a single organization with the same name concurrently", func() {
+		toAdd := testhelpers.CreateAddOrganizationRequest()
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		added := 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := manager.AddOrganization(*toAdd); err == nil {
+					mutex.Lock()
+					added++
+					mutex.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		gomega.Expect(added).Should(gomega.Equal(1))
+	})
+
+	ginkgo.It("should move the reservation when an organization is renamed", func() {
+		toAdd := testhelpers.CreateAddOrganizationRequest()
+		added, err := manager.AddOrganization(*toAdd)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		err = manager.UpdateOrganization(testhelpers.CreateUpdateOrganizationRequest(added.ID, true, "renamed"))
+		gomega.Expect(err).To(gomega.Succeed())
+		reservation, err := nameProvider.Get(entities.OrganizationNameScope, "renamed")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(reservation.OwnerId).Should(gomega.Equal(added.ID))
+
+		// the old name can be used again
+		_, err = manager.AddOrganization(*toAdd)
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should not rename an organization to a reserved name", func() {
+		added, err := manager.AddOrganization(*testhelpers.CreateAddOrganizationRequest())
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(nameProvider.Reserve(*entities.NewNameReservation(entities.OrganizationNameScope, "taken", entities.GenerateUUID()))).To(gomega.Succeed())
+
+		err = manager.UpdateOrganization(testhelpers.CreateUpdateOrganizationRequest(added.ID, true, "taken"))
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		retrieved, err := manager.Provider.Get(added.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Name).Should(gomega.Equal(added.Name))
+	})
+
+	ginkgo.It("should reserve the names of existing organizations", func() {
+		existing := testhelpers.AddOrganization(manager.Provider)
+		duplicated := testhelpers.AddOrganization(manager.Provider)
+		otherID := entities.GenerateUUID()
+		gomega.Expect(nameProvider.Reserve(*entities.NewNameReservation(entities.OrganizationNameScope, duplicated.Name, otherID))).To(gomega.Succeed())
+
+		conflicts, err := manager.ReserveOrganizationNames()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(conflicts)).Should(gomega.Equal(1))
+		gomega.Expect(conflicts[0].OwnerId).Should(gomega.Equal(duplicated.ID))
+		gomega.Expect(conflicts[0].ReservedBy).Should(gomega.Equal(otherID))
+		reservation, err := nameProvider.Get(entities.OrganizationNameScope, existing.Name)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(reservation.OwnerId).Should(gomega.Equal(existing.ID))
+
+		// running it again reports the same conflicts
+		conflicts, err = manager.ReserveOrganizationNames()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(conflicts)).Should(gomega.Equal(1))
+	})
+})
+
+// concurrentSettingProvider updates the setting before the first updates of the manager, as another writer would do
+// between the read and the write of the manager.
+type concurrentSettingProvider struct {
+	organization_setting.Provider
+	concurrentUpdates int
+}
+
+func (p *concurrentSettingProvider) Update(setting entities.OrganizationSetting) derrors.Error {
+	if p.concurrentUpdates > 0 {
+		p.concurrentUpdates--
+		current, err := p.Provider.Get(setting.OrganizationId, setting.Key)
+		if err != nil {
+			return err
+		}
+		current.Description = "concurrent"
+		err = p.Provider.Update(*current)
+		if err != nil {
+			return err
+		}
+	}
+	return p.Provider.Update(setting)
+}
+
+var _ = ginkgo.Describe("Organization setting versions", func() {
+
+	var manager Manager
+	var settingProvider *concurrentSettingProvider
+	var targetSetting *entities.OrganizationSetting
+
+	ginkgo.BeforeEach(func() {
+		orgProvider := organization.NewMockupOrganizationProvider()
+		settingProvider = &concurrentSettingProvider{Provider: organization_setting.NewMockupOrganizationSettingProvider()}
+		manager = NewManager(orgProvider, settingProvider, user.NewMockupUserProvider(),
+			service_account.NewMockupServiceAccountProvider(), name_reservation.NewMockupNameReservationProvider(),
+			testhelpers.NewPhotoManager(), newRoleManager(orgProvider), false)
+		targetOrganization := testhelpers.AddOrganization(orgProvider)
+		added, err := manager.AddSetting(&grpc_organization_go.AddSettingRequest{
+			OrganizationId: targetOrganization.ID,
+			Key:            "key",
+			Value:          "value",
+			Description:    "description",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		targetSetting = added
+	})
+
+	ginkgo.It("should retry an update after a concurrent update", func() {
+		settingProvider.concurrentUpdates = 2
+		err := manager.UpdateSetting(&grpc_organization_go.UpdateSettingRequest{
+			OrganizationId: targetSetting.OrganizationId,
+			Key:            targetSetting.Key,
+			UpdateValue:    true,
+			Value:          "new value",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+
+		retrieved, err := settingProvider.Get(targetSetting.OrganizationId, targetSetting.Key)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Value).Should(gomega.Equal("new value"))
+		gomega.Expect(retrieved.Description).Should(gomega.Equal("concurrent"))
+		gomega.Expect(retrieved.Version).Should(gomega.Equal(int64(3)))
+	})
+
+	ginkgo.It("should fail when the setting keeps being updated concurrently", func() {
+		settingProvider.concurrentUpdates = utils.MaxConflictRetries
+		err := manager.UpdateSetting(&grpc_organization_go.UpdateSettingRequest{
+			OrganizationId: targetSetting.OrganizationId,
+			Key:            targetSetting.Key,
+			UpdateValue:    true,
+			Value:          "new value",
+		})
+		gomega.Expect(entities.IsVersionConflict(err)).To(gomega.BeTrue())
+	})
+})
+
+var _ = ginkgo.Describe("Organization quota settings", func() {
+
+	var manager Manager
+	var targetOrganization *entities.Organization
+
+	ginkgo.BeforeEach(func() {
+		orgProvider := organization.NewMockupOrganizationProvider()
+		manager = NewManager(orgProvider, organization_setting.NewMockupOrganizationSettingProvider(), user.NewMockupUserProvider(),
+			service_account.NewMockupServiceAccountProvider(), name_reservation.NewMockupNameReservationProvider(),
+			testhelpers.NewPhotoManager(), newRoleManager(orgProvider), false)
+		targetOrganization = testhelpers.AddOrganization(orgProvider)
+	})
+
+	ginkgo.It("should not change quota limits as settings", func() {
+		key := entities.QuotaSettingKey(entities.DeviceQuota)
+		_, err := manager.AddSetting(&grpc_organization_go.AddSettingRequest{
+			OrganizationId: targetOrganization.ID,
+			Key:            key,
+			Value:          "100000",
+		})
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		err = manager.UpdateSetting(&grpc_organization_go.UpdateSettingRequest{
+			OrganizationId: targetOrganization.ID,
+			Key:            key,
+			UpdateValue:    true,
+			Value:          "100000",
+		})
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+})
+
+var testSettingsMax int64 = 10
+
+// newTestSettingSchemas creates a registry with the schemas used by the tests.
+func newTestSettingSchemas() *entities.SettingSchemaRegistry {
+	registry := entities.NewSettingSchemaRegistry()
+	for _, schema := range []entities.SettingSchema{
+		{Key: "test.enabled", Type: entities.BoolSetting, Default: "false", Description: "enabled", Editable: true},
+		{Key: "test.retries", Type: entities.IntSetting, Default: "3", Max: &testSettingsMax, Editable: true},
+		{Key: "test.interval", Type: entities.DurationSetting, Default: "1m", Editable: true},
+		{Key: "test.mode", Type: entities.EnumSetting, Default: "fast", Values: []string{"fast", "safe"}, Editable: true},
+		{Key: "test.labels", Type: entities.JSONSetting, Default: "{}", Editable: true},
+	} {
+		gomega.Expect(registry.Register(schema)).To(gomega.Succeed())
+	}
+	return registry
+}
+
+var _ = ginkgo.Describe("Organization setting schemas", func() {
+
+	var orgProvider organization.Provider
+	var manager Manager
+	var targetOrganization *entities.Organization
+
+	ginkgo.BeforeEach(func() {
+		orgProvider = organization.NewMockupOrganizationProvider()
+		manager = NewManager(orgProvider, organization_setting.NewMockupOrganizationSettingProvider(), user.NewMockupUserProvider(),
+			service_account.NewMockupServiceAccountProvider(), name_reservation.NewMockupNameReservationProvider(),
+			testhelpers.NewPhotoManager(), newRoleManager(orgProvider), false)
+		manager.SettingSchemas = newTestSettingSchemas()
+		targetOrganization = testhelpers.AddOrganization(orgProvider)
+	})
+
+	addSetting := func(key string, value string) (*entities.OrganizationSetting, derrors.Error) {
+		return manager.AddSetting(&grpc_organization_go.AddSettingRequest{
+			OrganizationId: targetOrganization.ID,
+			Key:            key,
+			Value:          value,
+		})
+	}
+
+	ginkgo.It("should validate the values of the known settings", func() {
+		for key, values := range map[string][]string{
+			"test.enabled":  {"maybe", "true"},
+			"test.retries":  {"11", "10"},
+			"test.interval": {"-1s", "90s"},
+			"test.mode":     {"slow", "safe"},
+			"test.labels":   {"{", `{"a": "b"}`},
+		} {
+			_, err := addSetting(key, values[0])
+			gomega.Expect(err).NotTo(gomega.Succeed())
+			added, err := addSetting(key, values[1])
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(added.Value).Should(gomega.Equal(values[1]))
+		}
+
+		err := manager.UpdateSetting(&grpc_organization_go.UpdateSettingRequest{
+			OrganizationId: targetOrganization.ID,
+			Key:            "test.retries",
+			UpdateValue:    true,
+			Value:          "many",
+		})
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should return the default value of the known settings that are not set", func() {
+		added, err := addSetting("test.enabled", "true")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(added.Description).Should(gomega.Equal("enabled"))
+
+		retrieved, err := manager.GetSetting(&grpc_organization_go.SettingKey{OrganizationId: targetOrganization.ID, Key: "test.mode"})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Value).Should(gomega.Equal("fast"))
+		_, err = manager.GetSetting(&grpc_organization_go.SettingKey{OrganizationId: targetOrganization.ID, Key: "unknown"})
+		gomega.Expect(err).NotTo(gomega.Succeed())
+
+		list, err := manager.ListSettings(&grpc_organization_go.OrganizationId{OrganizationId: targetOrganization.ID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(list).Should(gomega.HaveLen(len(manager.SettingSchemas.List())))
+		for _, setting := range list {
+			if setting.Key == "test.enabled" {
+				gomega.Expect(setting.Value).Should(gomega.Equal("true"))
+			}
+		}
+	})
+
+	ginkgo.It("should only accept unknown settings if the settings are not strict", func() {
+		_, err := addSetting("unknown", "value")
+		gomega.Expect(err).To(gomega.Succeed())
+
+		manager.StrictSettings = true
+		_, err = addSetting("other", "value")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		_, err = addSetting("test.mode", "safe")
+		gomega.Expect(err).To(gomega.Succeed())
+		err = manager.RemoveSetting(&grpc_organization_go.SettingKey{OrganizationId: targetOrganization.ID, Key: "unknown"})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+})
+
+var _ = ginkgo.Describe("Organization photos", func() {
+
+	var manager Manager
+
+	ginkgo.BeforeEach(func() {
+		orgProvider := organization.NewMockupOrganizationProvider()
+		manager = NewManager(orgProvider, organization_setting.NewMockupOrganizationSettingProvider(),
+			user.NewMockupUserProvider(), service_account.NewMockupServiceAccountProvider(), name_reservation.NewMockupNameReservationProvider(),
+			testhelpers.NewPhotoManager(), newRoleManager(orgProvider), false)
+	})
+
+	ginkgo.It("should keep the photo in the blob store and list the thumbnail", func() {
+		toAdd := testhelpers.CreateAddOrganizationRequest()
+		added, err := manager.AddOrganization(*toAdd)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(added.PhotoBase64).Should(gomega.Equal(toAdd.PhotoBase64))
+
+		stored, err := manager.Provider.Get(added.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(stored.PhotoBase64).Should(gomega.BeEmpty())
+		gomega.Expect(stored.PhotoId).ShouldNot(gomega.BeEmpty())
+
+		retrieved, err := manager.GetOrganization(grpc_organization_go.OrganizationId{OrganizationId: added.ID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.PhotoBase64).Should(gomega.Equal(toAdd.PhotoBase64))
+
+		list, err := manager.ListOrganization()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(list).Should(gomega.HaveLen(1))
+		gomega.Expect(list[0].PhotoBase64).ShouldNot(gomega.BeEmpty())
+		gomega.Expect(len(list[0].PhotoBase64)).Should(gomega.BeNumerically("<", len(toAdd.PhotoBase64)))
+
+		err = manager.UpdateOrganization(&grpc_organization_go.UpdateOrganizationRequest{OrganizationId: added.ID,
+			UpdatePhoto: true, PhotoBase64: testhelpers.CreatePhotoBase64()})
+		gomega.Expect(err).To(gomega.Succeed())
+		for _, blobID := range []string{stored.PhotoId, stored.ThumbnailId} {
+			exists, err := manager.Photos.BlobProvider.Exists(blobID)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(exists).Should(gomega.BeFalse())
+		}
+	})
+
+	ginkgo.It("should reject invalid photos", func() {
+		toAdd := testhelpers.CreateAddOrganizationRequest()
+		toAdd.PhotoBase64 = "bm90IGFuIGltYWdl"
+		_, err := manager.AddOrganization(*toAdd)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		exists, err := manager.Provider.ExistsByName(toAdd.Name)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).Should(gomega.BeFalse())
+		// the name is released
+		toAdd.PhotoBase64 = ""
+		_, err = manager.AddOrganization(*toAdd)
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should migrate the photos kept in the organizations", func() {
+		legacy := testhelpers.CreateOrganization()
+		legacy.PhotoBase64 = testhelpers.CreatePhotoBase64()
+		gomega.Expect(manager.Provider.Add(*legacy)).To(gomega.Succeed())
+		invalid := testhelpers.AddOrganization(manager.Provider)
+
+		migrated, err := manager.MigratePhotos()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(migrated).Should(gomega.Equal(1))
+
+		stored, err := manager.Provider.Get(legacy.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(stored.PhotoBase64).Should(gomega.BeEmpty())
+		retrieved, err := manager.GetOrganization(grpc_organization_go.OrganizationId{OrganizationId: legacy.ID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.PhotoBase64).Should(gomega.Equal(legacy.PhotoBase64))
+		kept, err := manager.Provider.Get(invalid.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(kept.PhotoBase64).Should(gomega.Equal(invalid.PhotoBase64))
+
+		migrated, err = manager.MigratePhotos()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(migrated).Should(gomega.Equal(0))
+	})
+})
+
+var _ = ginkgo.Describe("Organization roles", func() {
+
+	ginkgo.It("should create the roles of the templates in the new organizations", func() {
+		orgProvider := organization.NewMockupOrganizationProvider()
+		manager := NewManager(orgProvider, organization_setting.NewMockupOrganizationSettingProvider(), user.NewMockupUserProvider(),
+			service_account.NewMockupServiceAccountProvider(), name_reservation.NewMockupNameReservationProvider(),
+			testhelpers.NewPhotoManager(), newRoleManager(orgProvider), false)
+		added, err := manager.AddOrganization(*testhelpers.CreateAddOrganizationRequest())
+		gomega.Expect(err).To(gomega.Succeed())
+
+		roles, err := manager.Roles.ListRoles(&grpc_organization_go.OrganizationId{OrganizationId: added.ID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(roles).Should(gomega.HaveLen(len(entities.DefaultRoleTemplates())))
+		internal := 0
+		for _, r := range roles {
+			if r.Internal {
+				internal++
+			}
+		}
+		gomega.Expect(internal).Should(gomega.Equal(1))
+	})
+
+	ginkgo.It("should remove the organization if its roles cannot be created", func() {
+		orgProvider := organization.NewMockupOrganizationProvider()
+		// the templates share a name, so the second role cannot be created
+		roles := role.NewManager(orgProvider, rProvider.NewMockupRoleProvider(), name_reservation.NewMockupNameReservationProvider(),
+			[]entities.RoleTemplate{{Name: "Owner"}, {Name: "Owner"}})
+		blobs := &recordingBlobProvider{Provider: blob.NewMockupBlobProvider()}
+		manager := NewManager(orgProvider, organization_setting.NewMockupOrganizationSettingProvider(), user.NewMockupUserProvider(),
+			service_account.NewMockupServiceAccountProvider(), name_reservation.NewMockupNameReservationProvider(),
+			photo.NewManager(blobs), roles, false)
+		toAdd := testhelpers.CreateAddOrganizationRequest()
+		_, err := manager.AddOrganization(*toAdd)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		list, err := manager.Provider.List()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(list).Should(gomega.BeEmpty())
+		gomega.Expect(blobs.added).Should(gomega.HaveLen(2))
+		for _, blobID := range blobs.added {
+			exists, err := blobs.Exists(blobID)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(exists).Should(gomega.BeFalse())
+		}
+
+		// the name is released
+		manager.Roles = newRoleManager(orgProvider)
+		_, err = manager.AddOrganization(*toAdd)
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+})
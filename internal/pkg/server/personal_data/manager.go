@@ -0,0 +1,219 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package personal_data
+
+import (
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/invitation"
+	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/user"
+	"time"
+)
+
+// Manager structure with the required providers to export and erase the personal data held about an email.
+type Manager struct {
+	OrgProvider        organization.Provider
+	InvitationProvider invitation.Provider
+	UserManager        user.Manager
+}
+
+// NewManager creates a Manager using a set of providers and the manager that removes the users.
+func NewManager(orgProvider organization.Provider, invitationProvider invitation.Provider, userManager user.Manager) Manager {
+	return Manager{
+		OrgProvider:        orgProvider,
+		InvitationProvider: invitationProvider,
+		UserManager:        userManager,
+	}
+}
+
+// Export retrieves all the information held about an email: the user profile and photo, the memberships, the
+// organizations that list the email as a user or as contact, and the invitations sent to the email or created by it.
+func (m *Manager) Export(email string) (*entities.PersonalDataExport, derrors.Error) {
+	if err := entities.ValidPersonalDataEmail(email); err != nil {
+		return nil, err
+	}
+	result := entities.NewPersonalDataExport(email, time.Now().Unix())
+
+	usr, err := m.UserManager.UserProvider.Get(email)
+	if err != nil {
+		if err.Type() != derrors.NotFound {
+			return nil, err
+		}
+	} else {
+		if usr.PhotoId != "" {
+			usr.PhotoBase64, err = m.UserManager.Photos.Load(usr.PhotoId)
+			if err != nil {
+				return nil, err
+			}
+		}
+		result.User = usr
+	}
+	memberships, err := m.UserManager.UserProvider.ListMemberships(email)
+	if err != nil {
+		return nil, err
+	}
+	result.Memberships = append(result.Memberships, memberships...)
+
+	organizations, err := m.OrgProvider.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, org := range organizations {
+		exists, err := m.OrgProvider.UserExists(org.ID, email)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			result.OrganizationUsers = append(result.OrganizationUsers, org.ID)
+		}
+		if org.Email == email {
+			result.OrganizationContacts = append(result.OrganizationContacts, org.ID)
+		}
+	}
+
+	invitations, err := m.InvitationProvider.ListByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	for _, received := range invitations {
+		if received.Email == email {
+			result.InvitationsReceived = append(result.InvitationsReceived, received)
+		}
+		if received.InvitedBy == email {
+			result.InvitationsSent = append(result.InvitationsSent, received)
+		}
+	}
+	return result, nil
+}
+
+// Erase removes all the information held about an email. The user is removed from its organizations, releasing the
+// user quota, and the invitations sent to the email are removed. The data that belongs to other entities, such as
+// the creator of an invitation or the contact of an organization, is anonymized. The data is exported again once
+// erased to verify that nothing remains. An erasure that fails can be executed again.
+func (m *Manager) Erase(email string) (*entities.PersonalDataErasure, derrors.Error) {
+	if err := entities.ValidPersonalDataEmail(email); err != nil {
+		return nil, err
+	}
+	report := entities.NewPersonalDataErasure(email, time.Now().Unix())
+
+	// the blobs of the photo are no longer referenced once the user is removed, they are checked at the end
+	existed := true
+	photoBlobs := make([]string, 0)
+	usr, err := m.UserManager.UserProvider.Get(email)
+	if err != nil {
+		if err.Type() != derrors.NotFound {
+			return nil, err
+		}
+		existed = false
+	} else {
+		for _, blobID := range []string{usr.PhotoId, usr.ThumbnailId} {
+			if blobID != "" {
+				photoBlobs = append(photoBlobs, blobID)
+			}
+		}
+	}
+	memberships, err := m.UserManager.UserProvider.ListMemberships(email)
+	if err != nil {
+		return nil, err
+	}
+	for _, membership := range memberships {
+		err = m.UserManager.RemoveUser(&grpc_user_go.RemoveUserRequest{OrganizationId: membership.OrganizationId, Email: email})
+		if err != nil && err.Type() == derrors.NotFound {
+			// the organization or its list of users no longer has the user, only the membership is left
+			err = m.UserManager.UserProvider.RemoveMembership(email, membership.OrganizationId)
+		}
+		if err != nil {
+			return nil, err
+		}
+		report.MembershipsRemoved = append(report.MembershipsRemoved, membership.OrganizationId)
+	}
+
+	organizations, err := m.OrgProvider.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, org := range organizations {
+		exists, err := m.OrgProvider.UserExists(org.ID, email)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			if err := m.OrgProvider.DeleteUser(org.ID, email); err != nil {
+				return nil, err
+			}
+			report.OrganizationUsersRemoved = append(report.OrganizationUsersRemoved, org.ID)
+		}
+		if org.Email == email {
+			org.Email = entities.ErasedPersonalData
+			if err := m.OrgProvider.Update(org); err != nil {
+				return nil, err
+			}
+			report.OrganizationContactsAnonymized = append(report.OrganizationContactsAnonymized, org.ID)
+		}
+	}
+
+	exists, err := m.UserManager.UserProvider.Exists(email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		if err := m.UserManager.UserProvider.Remove(email); err != nil {
+			return nil, err
+		}
+	}
+	m.UserManager.Photos.Remove(photoBlobs...)
+	report.UserRemoved = existed
+
+	invitations, err := m.InvitationProvider.ListByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	for _, sent := range invitations {
+		if sent.Email == email {
+			if err := m.InvitationProvider.Remove(sent.InvitationId); err != nil {
+				return nil, err
+			}
+			report.InvitationsRemoved = append(report.InvitationsRemoved, sent.InvitationId)
+			continue
+		}
+		sent.InvitedBy = entities.ErasedPersonalData
+		if err := m.InvitationProvider.Update(sent); err != nil {
+			return nil, err
+		}
+		report.InvitationsAnonymized = append(report.InvitationsAnonymized, sent.InvitationId)
+	}
+
+	remaining, err := m.Export(email)
+	if err != nil {
+		return nil, err
+	}
+	report.Remaining = remaining.References()
+	for _, blobID := range photoBlobs {
+		exists, err := m.UserManager.Photos.BlobProvider.Exists(blobID)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			report.Remaining = append(report.Remaining, fmt.Sprintf("photo blob %s", blobID))
+		}
+	}
+	report.Verified = len(report.Remaining) == 0
+	return report, nil
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package personal_data
+
+import (
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	iProvider "github.com/nalej/system-model/internal/pkg/provider/invitation"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	rProvider "github.com/nalej/system-model/internal/pkg/provider/role"
+	saProvider "github.com/nalej/system-model/internal/pkg/provider/service_account"
+	uProvider "github.com/nalej/system-model/internal/pkg/provider/user"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
+	"github.com/nalej/system-model/internal/pkg/server/user"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+const subject = "subject@nalej.com"
+
+var _ = ginkgo.Describe("Personal data", func() {
+
+	var manager Manager
+	var firstOrganization *entities.Organization
+	var secondOrganization *entities.Organization
+	var photo string
+
+	addInvitation := func(organizationID string, email string, invitedBy string) *entities.Invitation {
+		invitation, _, err := entities.NewInvitation(organizationID, email, entities.GenerateUUID(), invitedBy, 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(manager.InvitationProvider.Add(*invitation)).To(gomega.Succeed())
+		return invitation
+	}
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider := orgProvider.NewMockupOrganizationProvider()
+		userManager := user.NewManager(organizationProvider, uProvider.NewMockupUserProvider(), rProvider.NewMockupRoleProvider(),
+			saProvider.NewMockupServiceAccountProvider(), testhelpers.NewQuotaManager(organizationProvider),
+			testhelpers.NewPhotoManager())
+		manager = NewManager(organizationProvider, iProvider.NewMockupInvitationProvider(), userManager)
+		firstOrganization = testhelpers.AddOrganization(organizationProvider)
+		secondOrganization = testhelpers.AddOrganization(organizationProvider)
+		photo = testhelpers.CreatePhotoBase64()
+
+		for _, org := range []*entities.Organization{firstOrganization, secondOrganization} {
+			_, err := userManager.AddUser(&grpc_user_go.AddUserRequest{
+				OrganizationId: org.ID,
+				Email:          subject,
+				Name:           "subject",
+				LastName:       "user",
+				PhotoBase64:    photo,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+		}
+		_, err := userManager.AddUser(&grpc_user_go.AddUserRequest{OrganizationId: firstOrganization.ID, Email: "other@nalej.com", Name: "other"})
+		gomega.Expect(err).To(gomega.Succeed())
+		secondOrganization.Email = subject
+		gomega.Expect(organizationProvider.Update(*secondOrganization)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should export the data held about an email", func() {
+		received := addInvitation(secondOrganization.ID, subject, "admin@nalej.com")
+		sent := addInvitation(firstOrganization.ID, "new@nalej.com", subject)
+		addInvitation(firstOrganization.ID, "new@nalej.com", "admin@nalej.com")
+
+		exported, err := manager.Export(subject)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exported.User).ShouldNot(gomega.BeNil())
+		gomega.Expect(exported.User.PhotoBase64).Should(gomega.Equal(photo))
+		gomega.Expect(len(exported.Memberships)).Should(gomega.Equal(2))
+		gomega.Expect(exported.OrganizationUsers).Should(gomega.ConsistOf(firstOrganization.ID, secondOrganization.ID))
+		gomega.Expect(exported.OrganizationContacts).Should(gomega.Equal([]string{secondOrganization.ID}))
+		gomega.Expect(len(exported.InvitationsReceived)).Should(gomega.Equal(1))
+		gomega.Expect(exported.InvitationsReceived[0].InvitationId).Should(gomega.Equal(received.InvitationId))
+		gomega.Expect(len(exported.InvitationsSent)).Should(gomega.Equal(1))
+		gomega.Expect(exported.InvitationsSent[0].InvitationId).Should(gomega.Equal(sent.InvitationId))
+
+		_, err = manager.Export("")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should erase the data held about an email and verify it", func() {
+		received := addInvitation(secondOrganization.ID, subject, "admin@nalej.com")
+		sent := addInvitation(firstOrganization.ID, "new@nalej.com", subject)
+		// a user listed by an organization without a membership
+		thirdOrganization := testhelpers.AddOrganization(manager.OrgProvider)
+		gomega.Expect(manager.OrgProvider.AddUser(thirdOrganization.ID, subject)).To(gomega.Succeed())
+
+		usr, err := manager.UserManager.UserProvider.Get(subject)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(usr.PhotoId).ShouldNot(gomega.BeEmpty())
+
+		report, err := manager.Erase(subject)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Verified).Should(gomega.BeTrue())
+		gomega.Expect(report.Remaining).Should(gomega.BeEmpty())
+		gomega.Expect(report.UserRemoved).Should(gomega.BeTrue())
+		gomega.Expect(report.MembershipsRemoved).Should(gomega.ConsistOf(firstOrganization.ID, secondOrganization.ID))
+		gomega.Expect(report.OrganizationUsersRemoved).Should(gomega.Equal([]string{thirdOrganization.ID}))
+		gomega.Expect(report.OrganizationContactsAnonymized).Should(gomega.Equal([]string{secondOrganization.ID}))
+		gomega.Expect(report.InvitationsRemoved).Should(gomega.Equal([]string{received.InvitationId}))
+		gomega.Expect(report.InvitationsAnonymized).Should(gomega.Equal([]string{sent.InvitationId}))
+
+		anonymized, err := manager.InvitationProvider.Get(sent.InvitationId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(anonymized.InvitedBy).Should(gomega.Equal(entities.ErasedPersonalData))
+		gomega.Expect(anonymized.Email).Should(gomega.Equal("new@nalej.com"))
+
+		// the photo of the user is removed
+		for _, blobID := range []string{usr.PhotoId, usr.ThumbnailId} {
+			exists, err := manager.UserManager.Photos.BlobProvider.Exists(blobID)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(exists).Should(gomega.BeFalse())
+		}
+
+		// other users are kept
+		exists, err := manager.UserManager.UserProvider.Exists("other@nalej.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).Should(gomega.BeTrue())
+
+		// the erasure can be executed again
+		report, err = manager.Erase(subject)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Verified).Should(gomega.BeTrue())
+		gomega.Expect(report.UserRemoved).Should(gomega.BeFalse())
+	})
+})
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package photo
+
+import (
+	"encoding/base64"
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/images"
+	"github.com/nalej/system-model/internal/pkg/provider/blob"
+	"github.com/rs/zerolog/log"
+)
+
+// Manager structure to keep the photos of the organizations and users in the blob store.
+type Manager struct {
+	BlobProvider blob.Provider
+}
+
+// NewManager creates a Manager using a blob provider.
+func NewManager(blobProvider blob.Provider) Manager {
+	return Manager{BlobProvider: blobProvider}
+}
+
+// Store validates a base64 encoded photo and adds it to the blob store together with its thumbnail. It returns the
+// identifiers of the photo and the thumbnail, both empty if there is no photo.
+func (m *Manager) Store(photoBase64 string) (string, string, derrors.Error) {
+	if photoBase64 == "" {
+		return "", "", nil
+	}
+	data, dErr := base64.StdEncoding.DecodeString(photoBase64)
+	if dErr != nil {
+		return "", "", derrors.NewInvalidArgumentError("photo is not base64 encoded", dErr)
+	}
+	contentType, err := entities.ValidPhoto(data)
+	if err != nil {
+		return "", "", err
+	}
+	thumbnail, err := images.Thumbnail(data, entities.ThumbnailMaxSide)
+	if err != nil {
+		return "", "", err
+	}
+
+	photo := entities.NewBlob(contentType, data)
+	err = m.BlobProvider.Add(*photo)
+	if err != nil {
+		return "", "", err
+	}
+	thumbnailBlob := entities.NewBlob(images.ThumbnailContentType, thumbnail)
+	err = m.BlobProvider.Add(*thumbnailBlob)
+	if err != nil {
+		m.Remove(photo.BlobId)
+		return "", "", err
+	}
+	return photo.BlobId, thumbnailBlob.BlobId, nil
+}
+
+// Load retrieves a blob of the store encoded in base64. It returns an empty string if there is no blob.
+func (m *Manager) Load(blobID string) (string, derrors.Error) {
+	if blobID == "" {
+		return "", nil
+	}
+	retrieved, err := m.BlobProvider.Get(blobID)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(retrieved.Data), nil
+}
+
+// Remove the blobs of a photo from the store. Blobs that are not found are ignored, and other errors are logged as
+// the blobs are no longer referenced.
+func (m *Manager) Remove(blobIDs ...string) {
+	for _, blobID := range blobIDs {
+		if blobID == "" {
+			continue
+		}
+		err := m.BlobProvider.Remove(blobID)
+		if err != nil && err.Type() != derrors.NotFound {
+			log.Warn().Str("blobId", blobID).Str("trace", err.DebugReport()).Msg("cannot remove photo")
+		}
+	}
+}
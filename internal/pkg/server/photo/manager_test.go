@@ -0,0 +1,98 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package photo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/images"
+	"github.com/nalej/system-model/internal/pkg/provider/blob"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+var _ = ginkgo.Describe("Photo manager", func() {
+
+	var manager Manager
+
+	createPhoto := func(width int, height int) []byte {
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+			}
+		}
+		var buffer bytes.Buffer
+		gomega.Expect(png.Encode(&buffer, img)).To(gomega.Succeed())
+		return buffer.Bytes()
+	}
+
+	ginkgo.BeforeEach(func() {
+		manager = NewManager(blob.NewMockupBlobProvider())
+	})
+
+	ginkgo.It("should store a photo and its thumbnail", func() {
+		photo := base64.StdEncoding.EncodeToString(createPhoto(200, 100))
+		photoID, thumbnailID, err := manager.Store(photo)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(photoID).ShouldNot(gomega.BeEmpty())
+		gomega.Expect(thumbnailID).ShouldNot(gomega.Equal(photoID))
+
+		loaded, err := manager.Load(photoID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(loaded).Should(gomega.Equal(photo))
+
+		thumbnail, err := manager.BlobProvider.Get(thumbnailID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(thumbnail.ContentType).Should(gomega.Equal(images.ThumbnailContentType))
+		decoded, dErr := png.Decode(bytes.NewReader(thumbnail.Data))
+		gomega.Expect(dErr).To(gomega.Succeed())
+		gomega.Expect(decoded.Bounds().Dx()).Should(gomega.Equal(entities.ThumbnailMaxSide))
+
+		manager.Remove(photoID, thumbnailID, "")
+		_, err = manager.Load(photoID)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+		// removing a photo twice is not an error
+		manager.Remove(photoID)
+	})
+
+	ginkgo.It("should not store empty photos", func() {
+		photoID, thumbnailID, err := manager.Store("")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(photoID).Should(gomega.BeEmpty())
+		gomega.Expect(thumbnailID).Should(gomega.BeEmpty())
+
+		loaded, err := manager.Load("")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(loaded).Should(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should reject invalid photos", func() {
+		for _, invalid := range []string{"Photo", base64.StdEncoding.EncodeToString([]byte("not an image")),
+			base64.StdEncoding.EncodeToString(make([]byte, entities.MaxPhotoSize+1))} {
+			_, _, err := manager.Store(invalid)
+			gomega.Expect(err).To(gomega.HaveOccurred())
+			gomega.Expect(err.Type()).Should(gomega.Equal(derrors.InvalidArgument))
+		}
+	})
+})
@@ -24,7 +24,13 @@ import (
 	"github.com/nalej/grpc-utils/pkg/test"
 	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/provider/account"
+	"github.com/nalej/system-model/internal/pkg/provider/application"
+	"github.com/nalej/system-model/internal/pkg/provider/cluster"
+	"github.com/nalej/system-model/internal/pkg/provider/device"
+	"github.com/nalej/system-model/internal/pkg/provider/name_reservation"
+	"github.com/nalej/system-model/internal/pkg/provider/organization"
 	"github.com/nalej/system-model/internal/pkg/provider/project"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
 	"google.golang.org/grpc"
@@ -52,6 +58,35 @@ func CreateAddProjectRequest(accountId string) *grpc_project_go.AddProjectReques
 	}
 }
 
+// AddLinkedOrganization adds an organization with a cluster, a descriptor and an instance, and links it to an account.
+func AddLinkedOrganization(accProvider account.Provider, orgProvider organization.Provider, clusterProvider cluster.Provider,
+	appProvider application.Provider, accountID string) *entities.Organization {
+	org := testhelpers.AddOrganization(orgProvider)
+	err := accProvider.AddOrganization(accountID, org.ID)
+	gomega.Expect(err).To(gomega.Succeed())
+
+	toAdd := entities.Cluster{OrganizationId: org.ID, ClusterId: entities.GenerateUUID(), Name: "cluster"}
+	err = clusterProvider.Add(toAdd)
+	gomega.Expect(err).To(gomega.Succeed())
+	err = orgProvider.AddCluster(org.ID, toAdd.ClusterId)
+	gomega.Expect(err).To(gomega.Succeed())
+
+	descriptor := entities.AppDescriptor{OrganizationId: org.ID, AppDescriptorId: entities.GenerateUUID(), Name: "descriptor"}
+	err = appProvider.AddDescriptor(descriptor)
+	gomega.Expect(err).To(gomega.Succeed())
+	err = orgProvider.AddDescriptor(org.ID, descriptor.AppDescriptorId)
+	gomega.Expect(err).To(gomega.Succeed())
+
+	instance := entities.AppInstance{OrganizationId: org.ID, AppDescriptorId: descriptor.AppDescriptorId,
+		AppInstanceId: entities.GenerateUUID(), Name: "instance"}
+	err = appProvider.AddInstance(instance)
+	gomega.Expect(err).To(gomega.Succeed())
+	err = orgProvider.AddInstance(org.ID, instance.AppInstanceId)
+	gomega.Expect(err).To(gomega.Succeed())
+
+	return org
+}
+
 var _ = ginkgo.Describe("Project service", func() {
 	// gRPC server
 	var server *grpc.Server
@@ -63,6 +98,11 @@ var _ = ginkgo.Describe("Project service", func() {
 	// Providers
 	var accountProvider account.Provider
 	var projectProvider project.Provider
+	var organizationProvider organization.Provider
+	var clusterProvider cluster.Provider
+	var applicationProvider application.Provider
+	var deviceProvider device.Provider
+	var manager Manager
 
 	var targetAccount *entities.Account
 
@@ -74,7 +114,12 @@ var _ = ginkgo.Describe("Project service", func() {
 		// Register the service
 		accountProvider = account.NewMockupAccountProvider()
 		projectProvider = project.NewMockupProjectProvider()
-		manager := NewManager(accountProvider, projectProvider)
+		organizationProvider = organization.NewMockupOrganizationProvider()
+		clusterProvider = cluster.NewMockupClusterProvider()
+		applicationProvider = application.NewMockupApplicationProvider()
+		deviceProvider = device.NewMockupDeviceProvider()
+		manager = NewManager(accountProvider, projectProvider, name_reservation.NewMockupNameReservationProvider(),
+			organizationProvider, clusterProvider, applicationProvider, deviceProvider)
 		handler := NewHandler(manager)
 		grpc_project_go.RegisterProjectsServer(server, handler)
 
@@ -182,6 +227,21 @@ var _ = ginkgo.Describe("Project service", func() {
 			gomega.Expect(success).NotTo(gomega.BeNil())
 
 		})
+		ginkgo.It("should be able to reuse the name of a removed project", func() {
+			toAdd := CreateAddProjectRequest(targetAccount.AccountId)
+
+			project, err := client.AddProject(context.Background(), toAdd)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			_, err = client.RemoveProject(context.Background(), &grpc_project_go.ProjectId{
+				AccountId: targetAccount.AccountId,
+				ProjectId: project.ProjectId,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+
+			_, err = client.AddProject(context.Background(), toAdd)
+			gomega.Expect(err).To(gomega.Succeed())
+		})
 		ginkgo.It("should not be able to remove a project if it does not exist", func() {
 			success, err := client.RemoveProject(context.Background(), &grpc_project_go.ProjectId{
 				AccountId: targetAccount.AccountId,
@@ -309,4 +369,161 @@ var _ = ginkgo.Describe("Project service", func() {
 		})
 	})
 
+	ginkgo.Context("assigning resources to projects", func() {
+		ginkgo.It("should be able to move the resources of a linked organization to a project", func() {
+			added, err := client.AddProject(context.Background(), CreateAddProjectRequest(targetAccount.AccountId))
+			gomega.Expect(err).To(gomega.Succeed())
+			org := AddLinkedOrganization(accountProvider, organizationProvider, clusterProvider, applicationProvider, targetAccount.AccountId)
+			group := testhelpers.CreateDeviceGroup(deviceProvider, org.ID, fmt.Sprintf("group-%s", entities.GenerateUUID()))
+			clusterIDs, dErr := organizationProvider.ListClusters(org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			descriptorIDs, dErr := organizationProvider.ListDescriptors(org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			instanceIDs, dErr := organizationProvider.ListInstances(org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+
+			for resourceType, resourceID := range map[entities.ProjectResourceType]string{
+				entities.ProjectResourceCluster:       clusterIDs[0],
+				entities.ProjectResourceAppDescriptor: descriptorIDs[0],
+				entities.ProjectResourceAppInstance:   instanceIDs[0],
+				entities.ProjectResourceDeviceGroup:   group.DeviceGroupId,
+			} {
+				dErr = manager.MoveToProject(targetAccount.AccountId, added.ProjectId, org.ID, resourceType, resourceID)
+				gomega.Expect(dErr).To(gomega.Succeed())
+			}
+
+			resources, dErr := manager.ListProjectResources(targetAccount.AccountId, added.ProjectId)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			gomega.Expect(len(resources.Clusters)).Should(gomega.Equal(1))
+			gomega.Expect(len(resources.AppDescriptors)).Should(gomega.Equal(1))
+			gomega.Expect(len(resources.AppInstances)).Should(gomega.Equal(1))
+			gomega.Expect(len(resources.DeviceGroups)).Should(gomega.Equal(1))
+
+			// the project cannot be removed while it has resources
+			_, err = client.RemoveProject(context.Background(), &grpc_project_go.ProjectId{
+				AccountId: targetAccount.AccountId,
+				ProjectId: added.ProjectId,
+			})
+			gomega.Expect(err).NotTo(gomega.Succeed())
+
+			// an empty project removes the resource from its project
+			dErr = manager.MoveToProject(targetAccount.AccountId, "", org.ID, entities.ProjectResourceCluster, clusterIDs[0])
+			gomega.Expect(dErr).To(gomega.Succeed())
+			resources, dErr = manager.ListProjectResources(targetAccount.AccountId, added.ProjectId)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			gomega.Expect(resources.Clusters).To(gomega.BeEmpty())
+		})
+		ginkgo.It("should be able to move a resource between projects", func() {
+			first, err := client.AddProject(context.Background(), CreateAddProjectRequest(targetAccount.AccountId))
+			gomega.Expect(err).To(gomega.Succeed())
+			second, err := client.AddProject(context.Background(), CreateAddProjectRequest(targetAccount.AccountId))
+			gomega.Expect(err).To(gomega.Succeed())
+			org := AddLinkedOrganization(accountProvider, organizationProvider, clusterProvider, applicationProvider, targetAccount.AccountId)
+			instanceIDs, dErr := organizationProvider.ListInstances(org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+
+			dErr = manager.MoveToProject(targetAccount.AccountId, first.ProjectId, org.ID, entities.ProjectResourceAppInstance, instanceIDs[0])
+			gomega.Expect(dErr).To(gomega.Succeed())
+			dErr = manager.MoveToProject(targetAccount.AccountId, second.ProjectId, org.ID, entities.ProjectResourceAppInstance, instanceIDs[0])
+			gomega.Expect(dErr).To(gomega.Succeed())
+
+			resources, dErr := manager.ListProjectResources(targetAccount.AccountId, first.ProjectId)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			gomega.Expect(resources.IsEmpty()).To(gomega.BeTrue())
+			resources, dErr = manager.ListProjectResources(targetAccount.AccountId, second.ProjectId)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			gomega.Expect(len(resources.AppInstances)).Should(gomega.Equal(1))
+		})
+		ginkgo.It("should not be able to move a resource of an organization that is not linked to the account", func() {
+			added, err := client.AddProject(context.Background(), CreateAddProjectRequest(targetAccount.AccountId))
+			gomega.Expect(err).To(gomega.Succeed())
+			other := AddAccount(accountProvider)
+			org := AddLinkedOrganization(accountProvider, organizationProvider, clusterProvider, applicationProvider, other.AccountId)
+			clusterIDs, dErr := organizationProvider.ListClusters(org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+
+			dErr = manager.MoveToProject(targetAccount.AccountId, added.ProjectId, org.ID, entities.ProjectResourceCluster, clusterIDs[0])
+			gomega.Expect(dErr).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should not be able to move a resource to a non existing project", func() {
+			org := AddLinkedOrganization(accountProvider, organizationProvider, clusterProvider, applicationProvider, targetAccount.AccountId)
+			clusterIDs, dErr := organizationProvider.ListClusters(org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+
+			dErr = manager.MoveToProject(targetAccount.AccountId, entities.GenerateUUID(), org.ID, entities.ProjectResourceCluster, clusterIDs[0])
+			gomega.Expect(dErr).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should not be able to move a resource of another organization", func() {
+			added, err := client.AddProject(context.Background(), CreateAddProjectRequest(targetAccount.AccountId))
+			gomega.Expect(err).To(gomega.Succeed())
+			org := AddLinkedOrganization(accountProvider, organizationProvider, clusterProvider, applicationProvider, targetAccount.AccountId)
+
+			dErr := manager.MoveToProject(targetAccount.AccountId, added.ProjectId, org.ID, entities.ProjectResourceCluster, entities.GenerateUUID())
+			gomega.Expect(dErr).NotTo(gomega.Succeed())
+		})
+	})
+
+	ginkgo.Context("project lifecycle", func() {
+		suspendAccount := func(accountID string) {
+			retrieved, err := accountProvider.Get(accountID)
+			gomega.Expect(err).To(gomega.Succeed())
+			retrieved.State = entities.AccountState_Suspended
+			retrieved.StateInfo = "pending payment"
+			err = accountProvider.Update(*retrieved)
+			gomega.Expect(err).To(gomega.Succeed())
+		}
+
+		ginkgo.It("should not be able to add a project to a suspended account", func() {
+			suspendAccount(targetAccount.AccountId)
+
+			_, err := client.AddProject(context.Background(), CreateAddProjectRequest(targetAccount.AccountId))
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should not be able to activate a project of a suspended account", func() {
+			added, err := client.AddProject(context.Background(), CreateAddProjectRequest(targetAccount.AccountId))
+			gomega.Expect(err).To(gomega.Succeed())
+			dErr := manager.ChangeProjectState(targetAccount.AccountId, added.ProjectId, entities.ProjectState_Suspended, "test")
+			gomega.Expect(dErr).To(gomega.Succeed())
+			suspendAccount(targetAccount.AccountId)
+
+			dErr = manager.ChangeProjectState(targetAccount.AccountId, added.ProjectId, entities.ProjectState_Active, "")
+			gomega.Expect(dErr).NotTo(gomega.Succeed())
+		})
+		ginkgo.It("should not be able to suspend a project without a reason", func() {
+			added, err := client.AddProject(context.Background(), CreateAddProjectRequest(targetAccount.AccountId))
+			gomega.Expect(err).To(gomega.Succeed())
+
+			dErr := manager.ChangeProjectState(targetAccount.AccountId, added.ProjectId, entities.ProjectState_Suspended, "")
+			gomega.Expect(dErr).NotTo(gomega.Succeed())
+			dErr = manager.ChangeProjectState(targetAccount.AccountId, added.ProjectId, entities.ProjectState_Deactivated, "")
+			gomega.Expect(dErr).To(gomega.Succeed())
+		})
+		ginkgo.It("should be able to list the projects in a state", func() {
+			first, err := client.AddProject(context.Background(), CreateAddProjectRequest(targetAccount.AccountId))
+			gomega.Expect(err).To(gomega.Succeed())
+			_, err = client.AddProject(context.Background(), CreateAddProjectRequest(targetAccount.AccountId))
+			gomega.Expect(err).To(gomega.Succeed())
+			dErr := manager.ChangeProjectState(targetAccount.AccountId, first.ProjectId, entities.ProjectState_PendingDeletion, "closed")
+			gomega.Expect(dErr).To(gomega.Succeed())
+
+			list, dErr := manager.ListAccountProjects(&grpc_account_go.AccountId{AccountId: targetAccount.AccountId},
+				entities.ProjectState_PendingDeletion)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			gomega.Expect(len(list)).Should(gomega.Equal(1))
+			gomega.Expect(list[0].ProjectId).Should(gomega.Equal(first.ProjectId))
+		})
+		ginkgo.It("should not be able to move resources to a project that is not active", func() {
+			added, err := client.AddProject(context.Background(), CreateAddProjectRequest(targetAccount.AccountId))
+			gomega.Expect(err).To(gomega.Succeed())
+			org := AddLinkedOrganization(accountProvider, organizationProvider, clusterProvider, applicationProvider, targetAccount.AccountId)
+			clusterIDs, dErr := organizationProvider.ListClusters(org.ID)
+			gomega.Expect(dErr).To(gomega.Succeed())
+			dErr = manager.ChangeProjectState(targetAccount.AccountId, added.ProjectId, entities.ProjectState_Deactivated, "test")
+			gomega.Expect(dErr).To(gomega.Succeed())
+
+			dErr = manager.MoveToProject(targetAccount.AccountId, added.ProjectId, org.ID, entities.ProjectResourceCluster, clusterIDs[0])
+			gomega.Expect(dErr).NotTo(gomega.Succeed())
+		})
+	})
+
 })
@@ -21,43 +21,92 @@ import (
 	"github.com/nalej/grpc-account-go"
 	"github.com/nalej/grpc-project-go"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/entities/devices"
 	"github.com/nalej/system-model/internal/pkg/provider/account"
+	"github.com/nalej/system-model/internal/pkg/provider/application"
+	"github.com/nalej/system-model/internal/pkg/provider/cluster"
+	"github.com/nalej/system-model/internal/pkg/provider/device"
+	"github.com/nalej/system-model/internal/pkg/provider/name_reservation"
+	"github.com/nalej/system-model/internal/pkg/provider/organization"
 	"github.com/nalej/system-model/internal/pkg/provider/project"
+	"github.com/nalej/system-model/internal/pkg/utils"
+	"github.com/rs/zerolog/log"
 )
 
 type Manager struct {
 	AccountProvider account.Provider
 	ProjectProvider project.Provider
+	NameProvider    name_reservation.Provider
+	OrgProvider     organization.Provider
+	ClusterProvider cluster.Provider
+	AppProvider     application.Provider
+	DevProvider     device.Provider
 }
 
-func NewManager(accProvider account.Provider, proProvider project.Provider) Manager {
+func NewManager(accProvider account.Provider, proProvider project.Provider, nameProvider name_reservation.Provider,
+	orgProvider organization.Provider, clusterProvider cluster.Provider, appProvider application.Provider,
+	devProvider device.Provider) Manager {
 	return Manager{
 		AccountProvider: accProvider,
 		ProjectProvider: proProvider,
+		NameProvider:    nameProvider,
+		OrgProvider:     orgProvider,
+		ClusterProvider: clusterProvider,
+		AppProvider:     appProvider,
+		DevProvider:     devProvider,
 	}
 }
 
+// ProjectResources with the resources of the organizations linked to an account that are assigned to a project.
+type ProjectResources struct {
+	AccountId      string                   `json:"account_id"`
+	ProjectId      string                   `json:"project_id"`
+	Clusters       []entities.Cluster       `json:"clusters"`
+	AppDescriptors []entities.AppDescriptor `json:"app_descriptors"`
+	AppInstances   []entities.AppInstance   `json:"app_instances"`
+	DeviceGroups   []devices.DeviceGroup    `json:"device_groups"`
+}
+
+// IsEmpty checks if no resource is assigned to the project.
+func (pr *ProjectResources) IsEmpty() bool {
+	return len(pr.Clusters) == 0 && len(pr.AppDescriptors) == 0 && len(pr.AppInstances) == 0 && len(pr.DeviceGroups) == 0
+}
+
 // AddProject adds a new project to a given account
 func (m *Manager) AddProject(request *grpc_project_go.AddProjectRequest) (*entities.Project, derrors.Error) {
 
-	// check if the account exists
-	exists, err := m.AccountProvider.Exists(request.AccountId)
+	// check if the account exists and is active
+	owner, err := m.AccountProvider.Get(request.AccountId)
 	if err != nil {
 		return nil, err
 	}
-	if !exists {
-		return nil, derrors.NewNotFoundError("account").WithParams(request.AccountId)
+	if owner.State != entities.AccountState_Active {
+		return nil, derrors.NewFailedPreconditionError("account is not active").WithParams(request.AccountId)
 	}
 
 	// check there is no another project with the same name
-	exists, err = m.ProjectProvider.ExistsByName(request.AccountId, request.Name)
+	exists, err := m.ProjectProvider.ExistsByName(request.AccountId, request.Name)
+	if err != nil {
+		return nil, err
+	}
 	if exists {
 		return nil, derrors.NewInvalidArgumentError("A Project with that name already exists").WithParams(request.Name)
 	}
 
 	toAdd := entities.NewProjectToGRPC(request)
+	scope := entities.ProjectNameScope(toAdd.OwnerAccountId)
+	err = m.NameProvider.Reserve(*entities.NewNameReservation(scope, toAdd.Name, toAdd.ProjectId))
+	if err != nil {
+		if err.Type() == derrors.AlreadyExists {
+			return nil, derrors.NewInvalidArgumentError("A Project with that name already exists").WithParams(request.Name)
+		}
+		return nil, err
+	}
 	err = m.ProjectProvider.Add(*toAdd)
 	if err != nil {
+		if rErr := m.NameProvider.Release(scope, toAdd.Name, toAdd.ProjectId); rErr != nil {
+			log.Warn().Str("name", toAdd.Name).Str("trace", rErr.DebugReport()).Msg("cannot release project name")
+		}
 		return nil, err
 	}
 
@@ -88,12 +137,28 @@ func (m *Manager) RemoveProject(project *grpc_project_go.ProjectId) derrors.Erro
 	if !exists {
 		return derrors.NewNotFoundError("account").WithParams(project.AccountId)
 	}
-	return m.ProjectProvider.Remove(project.AccountId, project.ProjectId)
+	toRemove, err := m.ProjectProvider.Get(project.AccountId, project.ProjectId)
+	if err != nil {
+		return err
+	}
+	resources, err := m.listProjectResources(project.AccountId, project.ProjectId)
+	if err != nil {
+		return err
+	}
+	if !resources.IsEmpty() {
+		return derrors.NewFailedPreconditionError("project has resources assigned").WithParams(project.AccountId, project.ProjectId)
+	}
+	err = m.ProjectProvider.Remove(project.AccountId, project.ProjectId)
+	if err != nil {
+		return err
+	}
+	return m.NameProvider.Release(entities.ProjectNameScope(toRemove.OwnerAccountId), toRemove.Name, toRemove.ProjectId)
 
 }
 
-// ListAccountProjects list the projects of a given account
-func (m *Manager) ListAccountProjects(project *grpc_account_go.AccountId) ([]entities.Project, derrors.Error) {
+// ListAccountProjects list the projects of a given account. If states are received, only the projects in those states
+// are returned.
+func (m *Manager) ListAccountProjects(project *grpc_account_go.AccountId, states ...entities.ProjectState) ([]entities.Project, derrors.Error) {
 	exists, err := m.AccountProvider.Exists(project.AccountId)
 	if err != nil {
 		return nil, err
@@ -102,8 +167,23 @@ func (m *Manager) ListAccountProjects(project *grpc_account_go.AccountId) ([]ent
 		return nil, derrors.NewNotFoundError("account").WithParams(project.AccountId)
 	}
 
-	return m.ProjectProvider.ListAccountProjects(project.AccountId)
-
+	projects, err := m.ProjectProvider.ListAccountProjects(project.AccountId)
+	if err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return projects, nil
+	}
+	filtered := make([]entities.Project, 0)
+	for _, pr := range projects {
+		for _, state := range states {
+			if pr.State == state {
+				filtered = append(filtered, pr)
+				break
+			}
+		}
+	}
+	return filtered, nil
 }
 
 // UpdateProject updates the project information
@@ -120,19 +200,271 @@ func (m *Manager) UpdateProject(request *grpc_project_go.UpdateProjectRequest) d
 	if err != nil {
 		return err
 	}
+	if request.UpdateState {
+		reason := oldProject.StateInfo
+		if request.UpdateStateInfo {
+			reason = request.StateInfo
+		}
+		err = m.validateProjectState(oldProject, entities.ProjectStateFromGRPCUpdate(oldProject.State, request.State), reason)
+		if err != nil {
+			return err
+		}
+	}
+	scope := entities.ProjectNameScope(oldProject.OwnerAccountId)
+	oldName := oldProject.Name
+	renamed := request.UpdateName && request.Name != oldName
 	// if the name is been changed, check if the ner one already exists
-	if request.UpdateName {
-		exists, err := m.ProjectProvider.ExistsByName(request.ProjectId, request.Name)
+	if renamed {
+		exists, err := m.ProjectProvider.ExistsByName(request.AccountId, request.Name)
 		if err != nil {
 			return err
 		}
 		if exists {
 			return derrors.NewInvalidArgumentError("A Project with that name already exists").WithParams(request.Name)
 		}
+		err = m.NameProvider.Rename(scope, oldName, request.Name, oldProject.ProjectId)
+		if err != nil {
+			if err.Type() == derrors.AlreadyExists {
+				return derrors.NewInvalidArgumentError("A Project with that name already exists").WithParams(request.Name)
+			}
+			return err
+		}
 	}
 
 	oldProject.ApplyUpdate(request)
 
-	return m.ProjectProvider.Update(*oldProject)
+	err = m.ProjectProvider.Update(*oldProject)
+	if err != nil && renamed {
+		if rErr := m.NameProvider.Rename(scope, request.Name, oldName, oldProject.ProjectId); rErr != nil {
+			log.Warn().Str("name", request.Name).Str("trace", rErr.DebugReport()).Msg("cannot restore project name")
+		}
+	}
+	return err
+
+}
+
+// validateProjectState checks that a project can change to a state. A project can only be activated if its account
+// is active.
+func (m *Manager) validateProjectState(toUpdate *entities.Project, state entities.ProjectState, reason string) derrors.Error {
+	err := entities.ValidateProjectStateTransition(toUpdate.State, state, reason)
+	if err != nil {
+		return err
+	}
+	if state == entities.ProjectState_Active && toUpdate.State != entities.ProjectState_Active {
+		owner, err := m.AccountProvider.Get(toUpdate.OwnerAccountId)
+		if err != nil {
+			return err
+		}
+		if owner.State != entities.AccountState_Active {
+			return derrors.NewFailedPreconditionError("account is not active").WithParams(toUpdate.OwnerAccountId)
+		}
+	}
+	return nil
+}
+
+// ChangeProjectState changes the state of a project, recording the reason in its state info.
+func (m *Manager) ChangeProjectState(accountID string, projectID string, state entities.ProjectState, reason string) derrors.Error {
+	toUpdate, err := m.ProjectProvider.Get(accountID, projectID)
+	if err != nil {
+		return err
+	}
+	err = m.validateProjectState(toUpdate, state, reason)
+	if err != nil {
+		return err
+	}
+	toUpdate.State = state
+	toUpdate.StateInfo = reason
+	return m.ProjectProvider.Update(*toUpdate)
+}
+
+// ReserveProjectNames reserves the names of the existing projects, returning the names used by more than one project
+// of an account. It can be run several times.
+func (m *Manager) ReserveProjectNames() ([]entities.NameConflict, derrors.Error) {
+	accounts, err := m.AccountProvider.List()
+	if err != nil {
+		return nil, err
+	}
+	conflicts := make([]entities.NameConflict, 0)
+	for _, acc := range accounts {
+		projects, err := m.ProjectProvider.ListAccountProjects(acc.AccountId)
+		if err != nil {
+			return nil, err
+		}
+		scope := entities.ProjectNameScope(acc.AccountId)
+		for _, toReserve := range projects {
+			err := m.NameProvider.Reserve(*entities.NewNameReservation(scope, toReserve.Name, toReserve.ProjectId))
+			if err == nil {
+				continue
+			}
+			if err.Type() != derrors.AlreadyExists {
+				return nil, err
+			}
+			reservation, err := m.NameProvider.Get(scope, toReserve.Name)
+			if err != nil {
+				return nil, err
+			}
+			conflicts = append(conflicts, entities.NameConflict{Scope: scope, Name: toReserve.Name,
+				OwnerId: toReserve.ProjectId, ReservedBy: reservation.OwnerId})
+		}
+	}
+	return conflicts, nil
+}
+
+// MoveToProject assigns a resource of an organization to a project of the account the organization is linked to.
+// An empty projectID removes the resource from its project.
+func (m *Manager) MoveToProject(accountID string, projectID string, organizationID string,
+	resourceType entities.ProjectResourceType, resourceID string) derrors.Error {
+
+	linked, err := m.AccountProvider.OrganizationExists(accountID, organizationID)
+	if err != nil {
+		return err
+	}
+	if !linked {
+		return derrors.NewFailedPreconditionError("organization is not linked to the account").WithParams(accountID, organizationID)
+	}
+	if projectID != "" {
+		target, err := m.ProjectProvider.Get(accountID, projectID)
+		if err != nil {
+			return err
+		}
+		if target.State != entities.ProjectState_Active {
+			return derrors.NewFailedPreconditionError("project is not active").WithParams(accountID, projectID)
+		}
+	}
 
+	switch resourceType {
+	case entities.ProjectResourceCluster:
+		if err := m.checkOrganizationResource(m.OrgProvider.ClusterExists, "cluster", organizationID, resourceID); err != nil {
+			return err
+		}
+		return utils.RetryOnConflict(func() derrors.Error {
+			toMove, err := m.ClusterProvider.Get(resourceID)
+			if err != nil {
+				return err
+			}
+			toMove.ProjectId = projectID
+			return m.ClusterProvider.Update(*toMove)
+		})
+	case entities.ProjectResourceAppDescriptor:
+		if err := m.checkOrganizationResource(m.OrgProvider.DescriptorExists, "app descriptor", organizationID, resourceID); err != nil {
+			return err
+		}
+		toMove, err := m.AppProvider.GetDescriptor(resourceID)
+		if err != nil {
+			return err
+		}
+		toMove.ProjectId = projectID
+		return m.AppProvider.UpdateDescriptor(*toMove)
+	case entities.ProjectResourceAppInstance:
+		if err := m.checkOrganizationResource(m.OrgProvider.InstanceExists, "app instance", organizationID, resourceID); err != nil {
+			return err
+		}
+		return utils.RetryOnConflict(func() derrors.Error {
+			toMove, err := m.AppProvider.GetInstance(resourceID)
+			if err != nil {
+				return err
+			}
+			toMove.ProjectId = projectID
+			return m.AppProvider.UpdateInstance(*toMove)
+		})
+	case entities.ProjectResourceDeviceGroup:
+		toMove, err := m.DevProvider.GetDeviceGroup(organizationID, resourceID)
+		if err != nil {
+			return err
+		}
+		toMove.ProjectId = projectID
+		return m.DevProvider.UpdateDeviceGroup(*toMove)
+	}
+	return derrors.NewInvalidArgumentError("invalid resource type").WithParams(resourceType)
+}
+
+// checkOrganizationResource checks that a resource belongs to an organization.
+func (m *Manager) checkOrganizationResource(exists func(string, string) (bool, derrors.Error), resource string,
+	organizationID string, resourceID string) derrors.Error {
+	found, err := exists(organizationID, resourceID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return derrors.NewNotFoundError(resource).WithParams(organizationID, resourceID)
+	}
+	return nil
+}
+
+// ListProjectResources lists the resources of the organizations linked to an account that are assigned to a project.
+func (m *Manager) ListProjectResources(accountID string, projectID string) (*ProjectResources, derrors.Error) {
+	exists, err := m.ProjectProvider.Exists(accountID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, derrors.NewNotFoundError("project").WithParams(accountID, projectID)
+	}
+	return m.listProjectResources(accountID, projectID)
+}
+
+func (m *Manager) listProjectResources(accountID string, projectID string) (*ProjectResources, derrors.Error) {
+	organizationIDs, err := m.AccountProvider.ListOrganizations(accountID)
+	if err != nil {
+		return nil, err
+	}
+	resources := &ProjectResources{
+		AccountId:      accountID,
+		ProjectId:      projectID,
+		Clusters:       make([]entities.Cluster, 0),
+		AppDescriptors: make([]entities.AppDescriptor, 0),
+		AppInstances:   make([]entities.AppInstance, 0),
+		DeviceGroups:   make([]devices.DeviceGroup, 0),
+	}
+	for _, organizationID := range organizationIDs {
+		clusterIDs, err := m.OrgProvider.ListClusters(organizationID)
+		if err != nil {
+			return nil, err
+		}
+		for _, clusterID := range clusterIDs {
+			retrieved, err := m.ClusterProvider.Get(clusterID)
+			if err != nil {
+				return nil, err
+			}
+			if retrieved.ProjectId == projectID {
+				resources.Clusters = append(resources.Clusters, *retrieved)
+			}
+		}
+		descriptorIDs, err := m.OrgProvider.ListDescriptors(organizationID)
+		if err != nil {
+			return nil, err
+		}
+		for _, descriptorID := range descriptorIDs {
+			retrieved, err := m.AppProvider.GetDescriptor(descriptorID)
+			if err != nil {
+				return nil, err
+			}
+			if retrieved.ProjectId == projectID {
+				resources.AppDescriptors = append(resources.AppDescriptors, *retrieved)
+			}
+		}
+		instanceIDs, err := m.OrgProvider.ListInstances(organizationID)
+		if err != nil {
+			return nil, err
+		}
+		for _, instanceID := range instanceIDs {
+			retrieved, err := m.AppProvider.GetInstance(instanceID)
+			if err != nil {
+				return nil, err
+			}
+			if retrieved.ProjectId == projectID {
+				resources.AppInstances = append(resources.AppInstances, *retrieved)
+			}
+		}
+		groups, err := m.DevProvider.ListDeviceGroups(organizationID)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			if group.ProjectId == projectID {
+				resources.DeviceGroups = append(resources.DeviceGroups, group)
+			}
+		}
+	}
+	return resources, nil
 }
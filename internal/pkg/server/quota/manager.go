@@ -0,0 +1,189 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quota
+
+import (
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	"github.com/nalej/system-model/internal/pkg/provider/quota"
+	"github.com/nalej/system-model/internal/pkg/utils"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Manager structure with the required providers for quota operations.
+// The limits of an organization are stored as organization settings, and the system defaults are used for the
+// resources without a setting. The usage is increased before a resource is added, and decreased when it is removed.
+type Manager struct {
+	OrgProvider     organization.Provider
+	SettingProvider organization_setting.Provider
+	QuotaProvider   quota.Provider
+}
+
+// NewManager creates a Manager using a set of providers.
+func NewManager(orgProvider organization.Provider, settingProvider organization_setting.Provider, quotaProvider quota.Provider) Manager {
+	return Manager{
+		OrgProvider:     orgProvider,
+		SettingProvider: settingProvider,
+		QuotaProvider:   quotaProvider,
+	}
+}
+
+// validOrganization checks that the organization exists.
+func (m *Manager) validOrganization(organizationID string) derrors.Error {
+	exists, err := m.OrgProvider.Exists(organizationID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("organizationID").WithParams(organizationID)
+	}
+	return nil
+}
+
+// Limit returns the limit of a resource for an organization, and whether it is the system default.
+func (m *Manager) Limit(organizationID string, resource entities.QuotaResource) (int64, bool, derrors.Error) {
+	if err := entities.ValidQuotaResource(resource); err != nil {
+		return 0, false, err
+	}
+	setting, err := m.SettingProvider.Get(organizationID, entities.QuotaSettingKey(resource))
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return entities.DefaultQuotaLimits[resource], true, nil
+		}
+		return 0, false, err
+	}
+	limit, err := entities.ParseQuotaLimit(setting.Value)
+	if err != nil {
+		log.Warn().Str("organizationId", organizationID).Str("key", setting.Key).Str("value", setting.Value).
+			Msg("invalid quota setting, using the default limit")
+		return entities.DefaultQuotaLimits[resource], true, nil
+	}
+	return limit, false, nil
+}
+
+// Acquire increases the usage of a resource of an organization. It fails with a quota exceeded error if the usage
+// would be over the limit of the organization.
+func (m *Manager) Acquire(organizationID string, resource entities.QuotaResource, amount int64) derrors.Error {
+	limit, _, err := m.Limit(organizationID, resource)
+	if err != nil {
+		return err
+	}
+	return m.QuotaProvider.Acquire(organizationID, resource, amount, limit)
+}
+
+// Release decreases the usage of a resource of an organization. Failures are logged, as the usage can be counted
+// again with the quotas recount command.
+func (m *Manager) Release(organizationID string, resource entities.QuotaResource, amount int64) {
+	err := m.QuotaProvider.Release(organizationID, resource, amount)
+	if err != nil {
+		log.Warn().Str("trace", err.DebugReport()).Str("organizationId", organizationID).
+			Str("resource", string(resource)).Msg("cannot release quota")
+	}
+}
+
+// SetLimit stores the limit of a resource for an organization.
+func (m *Manager) SetLimit(organizationID string, resource entities.QuotaResource, limit int64) derrors.Error {
+	if err := entities.ValidQuotaResource(resource); err != nil {
+		return err
+	}
+	if limit < 0 {
+		return derrors.NewInvalidArgumentError("quota limit cannot be negative").WithParams(limit)
+	}
+	if err := m.validOrganization(organizationID); err != nil {
+		return err
+	}
+	key := entities.QuotaSettingKey(resource)
+	value := fmt.Sprintf("%d", limit)
+	return utils.RetryOnConflict(func() derrors.Error {
+		setting, err := m.SettingProvider.Get(organizationID, key)
+		if err != nil {
+			if err.Type() != derrors.NotFound {
+				return err
+			}
+			schema, _ := entities.GetSettingSchema(key)
+			return m.SettingProvider.Add(*entities.NewOrganizationSetting(organizationID, key, value, schema.Description))
+		}
+		setting.Value = value
+		return m.SettingProvider.Update(*setting)
+	})
+}
+
+// ResetLimit removes the limit of a resource for an organization, so the system default is used.
+func (m *Manager) ResetLimit(organizationID string, resource entities.QuotaResource) derrors.Error {
+	if err := entities.ValidQuotaResource(resource); err != nil {
+		return err
+	}
+	err := m.SettingProvider.Remove(organizationID, entities.QuotaSettingKey(resource))
+	if err != nil && err.Type() != derrors.NotFound {
+		return err
+	}
+	return nil
+}
+
+// Usage reports the usage of the resources of an organization with respect to their limits.
+func (m *Manager) Usage(organizationID string) ([]entities.QuotaReport, derrors.Error) {
+	if err := m.validOrganization(organizationID); err != nil {
+		return nil, err
+	}
+	usage, err := m.QuotaProvider.ListUsage(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[entities.QuotaResource]int64, len(usage))
+	for _, resourceUsage := range usage {
+		used[resourceUsage.Resource] = resourceUsage.Used
+	}
+
+	result := make([]entities.QuotaReport, 0, len(entities.QuotaResources))
+	for _, resource := range entities.QuotaResources {
+		limit, isDefault, err := m.Limit(organizationID, resource)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entities.QuotaReport{
+			OrganizationId: organizationID,
+			Resource:       resource,
+			Used:           used[resource],
+			Limit:          limit,
+			Default:        isDefault,
+		})
+	}
+	return result, nil
+}
+
+// SetUsage replaces the usage of a resource of an organization with the number of existing resources.
+func (m *Manager) SetUsage(organizationID string, resource entities.QuotaResource, used int64) derrors.Error {
+	if err := entities.ValidQuotaResource(resource); err != nil {
+		return err
+	}
+	return m.QuotaProvider.SetUsage(*entities.NewQuotaUsage(organizationID, resource, used))
+}
+
+// ToGRPCError converts the errors of the operations that acquire quota. Reaching the limit of a resource is reported
+// as ResourceExhausted so callers can tell it apart from other failed preconditions.
+func ToGRPCError(err derrors.Error) error {
+	if entities.IsQuotaExceeded(err) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return conversions.ToGRPCError(err)
+}
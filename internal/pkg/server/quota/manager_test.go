@@ -0,0 +1,110 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quota
+
+import (
+	"github.com/nalej/system-model/internal/pkg/entities"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	quotaProvider "github.com/nalej/system-model/internal/pkg/provider/quota"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ = ginkgo.Describe("Quota manager", func() {
+
+	var manager Manager
+	var organization *entities.Organization
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider := orgProvider.NewMockupOrganizationProvider()
+		manager = NewManager(organizationProvider, organization_setting.NewMockupOrganizationSettingProvider(),
+			quotaProvider.NewMockupQuotaProvider())
+		// the test helpers use this package, so the organization is added here
+		organization = entities.NewOrganization("quota-org", "test@email.com", "Nalej Test Address", "City Test",
+			"State Test", "U.S.A", "XXX", "Photo")
+		gomega.Expect(organizationProvider.Add(*organization)).To(gomega.Succeed())
+	})
+
+	ginkgo.Context("limits", func() {
+		ginkgo.It("should use the system default if no limit is set", func() {
+			limit, isDefault, err := manager.Limit(organization.ID, entities.DeviceQuota)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(isDefault).To(gomega.BeTrue())
+			gomega.Expect(limit).Should(gomega.Equal(entities.DefaultQuotaLimits[entities.DeviceQuota]))
+		})
+		ginkgo.It("should set and reset the limit of an organization", func() {
+			gomega.Expect(manager.SetLimit(organization.ID, entities.DeviceQuota, 10)).To(gomega.Succeed())
+			gomega.Expect(manager.SetLimit(organization.ID, entities.DeviceQuota, 20)).To(gomega.Succeed())
+			limit, isDefault, err := manager.Limit(organization.ID, entities.DeviceQuota)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(isDefault).To(gomega.BeFalse())
+			gomega.Expect(limit).Should(gomega.Equal(int64(20)))
+
+			gomega.Expect(manager.ResetLimit(organization.ID, entities.DeviceQuota)).To(gomega.Succeed())
+			_, isDefault, err = manager.Limit(organization.ID, entities.DeviceQuota)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(isDefault).To(gomega.BeTrue())
+		})
+		ginkgo.It("should reject invalid limits", func() {
+			gomega.Expect(manager.SetLimit(organization.ID, entities.QuotaResource("satellites"), 10)).NotTo(gomega.Succeed())
+			gomega.Expect(manager.SetLimit(organization.ID, entities.DeviceQuota, -1)).NotTo(gomega.Succeed())
+			gomega.Expect(manager.SetLimit("unknown", entities.DeviceQuota, 10)).NotTo(gomega.Succeed())
+		})
+	})
+
+	ginkgo.Context("usage", func() {
+		ginkgo.It("should fail when the limit is reached", func() {
+			gomega.Expect(manager.SetLimit(organization.ID, entities.ClusterQuota, 2)).To(gomega.Succeed())
+			gomega.Expect(manager.Acquire(organization.ID, entities.ClusterQuota, 1)).To(gomega.Succeed())
+			gomega.Expect(manager.Acquire(organization.ID, entities.ClusterQuota, 1)).To(gomega.Succeed())
+			err := manager.Acquire(organization.ID, entities.ClusterQuota, 1)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+			gomega.Expect(entities.IsQuotaExceeded(err)).To(gomega.BeTrue())
+			gomega.Expect(status.Code(ToGRPCError(err))).Should(gomega.Equal(codes.ResourceExhausted))
+
+			manager.Release(organization.ID, entities.ClusterQuota, 1)
+			gomega.Expect(manager.Acquire(organization.ID, entities.ClusterQuota, 1)).To(gomega.Succeed())
+		})
+		ginkgo.It("should report the usage of every resource", func() {
+			gomega.Expect(manager.SetLimit(organization.ID, entities.NodeQuota, 5)).To(gomega.Succeed())
+			gomega.Expect(manager.Acquire(organization.ID, entities.NodeQuota, 3)).To(gomega.Succeed())
+			gomega.Expect(manager.SetUsage(organization.ID, entities.UserQuota, 7)).To(gomega.Succeed())
+
+			report, err := manager.Usage(organization.ID)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(report).Should(gomega.HaveLen(len(entities.QuotaResources)))
+			for _, usage := range report {
+				gomega.Expect(usage.OrganizationId).Should(gomega.Equal(organization.ID))
+				switch usage.Resource {
+				case entities.NodeQuota:
+					gomega.Expect(usage.Used).Should(gomega.Equal(int64(3)))
+					gomega.Expect(usage.Limit).Should(gomega.Equal(int64(5)))
+					gomega.Expect(usage.Default).To(gomega.BeFalse())
+				case entities.UserQuota:
+					gomega.Expect(usage.Used).Should(gomega.Equal(int64(7)))
+					gomega.Expect(usage.Default).To(gomega.BeTrue())
+				default:
+					gomega.Expect(usage.Used).Should(gomega.Equal(int64(0)))
+					gomega.Expect(usage.Default).To(gomega.BeTrue())
+				}
+			}
+		})
+	})
+})
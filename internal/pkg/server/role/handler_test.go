@@ -18,10 +18,12 @@ package role
 
 import (
 	"context"
+	"fmt"
 	"github.com/nalej/grpc-organization-go"
 	"github.com/nalej/grpc-role-go"
 	"github.com/nalej/grpc-utils/pkg/test"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	nrProvider "github.com/nalej/system-model/internal/pkg/provider/name_reservation"
 	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
 	rProvider "github.com/nalej/system-model/internal/pkg/provider/role"
 	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
@@ -31,12 +33,13 @@ import (
 	"google.golang.org/grpc/test/bufconn"
 )
 
+// createAddRoleRequest creates a request for an internal role, that must be added as a regular role.
 func createAddRoleRequest(organizationID string) *grpc_role_go.AddRoleRequest {
 	return &grpc_role_go.AddRoleRequest{
 		OrganizationId: organizationID,
-		Name:           "name",
+		Name:           fmt.Sprintf("role-%s", entities.GenerateUUID()),
 		Description:    "description",
-		Internal:       false,
+		Internal:       true,
 	}
 }
 
@@ -52,6 +55,7 @@ var _ = ginkgo.Describe("Role service", func() {
 	// Providers
 	var organizationProvider orgProvider.Provider
 	var roleProvider rProvider.Provider
+	var nameProvider nrProvider.Provider
 
 	// Target organization.
 	var targetOrganization *entities.Organization
@@ -63,9 +67,10 @@ var _ = ginkgo.Describe("Role service", func() {
 
 		organizationProvider = orgProvider.NewMockupOrganizationProvider()
 		roleProvider = rProvider.NewMockupRoleProvider()
+		nameProvider = nrProvider.NewMockupNameReservationProvider()
 
 		// Register the service
-		manager := NewManager(organizationProvider, roleProvider)
+		manager := NewManager(organizationProvider, roleProvider, nameProvider, entities.DefaultRoleTemplates())
 		handler := NewHandler(manager)
 		grpc_role_go.RegisterRolesServer(server, handler)
 
@@ -83,6 +88,7 @@ var _ = ginkgo.Describe("Role service", func() {
 		ginkgo.By("cleaning the mockups", func() {
 			organizationProvider.(*orgProvider.MockupOrganizationProvider).Clear()
 			roleProvider.(*rProvider.MockupRoleProvider).Clear()
+			nameProvider.(*nrProvider.MockupNameReservationProvider).Clear()
 			// Initial data
 			targetOrganization = testhelpers.AddOrganization(organizationProvider)
 		})
@@ -95,6 +101,7 @@ var _ = ginkgo.Describe("Role service", func() {
 		gomega.Expect(added).ShouldNot(gomega.BeNil())
 		gomega.Expect(added.RoleId).ShouldNot(gomega.BeEmpty())
 		gomega.Expect(added.OrganizationId).Should(gomega.Equal(toAdd.OrganizationId))
+		gomega.Expect(added.Internal).Should(gomega.BeFalse())
 	})
 
 	ginkgo.It("should be able to retrieve an existing role", func() {
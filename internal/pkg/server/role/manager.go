@@ -22,6 +22,7 @@ import (
 	"github.com/nalej/grpc-role-go"
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/provider/name_reservation"
 	"github.com/nalej/system-model/internal/pkg/provider/organization"
 	"github.com/nalej/system-model/internal/pkg/provider/role"
 	"github.com/rs/zerolog/log"
@@ -31,11 +32,15 @@ import (
 type Manager struct {
 	OrgProvider  organization.Provider
 	RoleProvider role.Provider
+	NameProvider name_reservation.Provider
+	// Templates with the roles created for the new organizations.
+	Templates []entities.RoleTemplate
 }
 
-// NewManager creates a Manager using a set of providers.
-func NewManager(orgProvider organization.Provider, roleProvider role.Provider) Manager {
-	return Manager{orgProvider, roleProvider}
+// NewManager creates a Manager using a set of providers and the templates of the roles of the new organizations.
+func NewManager(orgProvider organization.Provider, roleProvider role.Provider, nameProvider name_reservation.Provider,
+	templates []entities.RoleTemplate) Manager {
+	return Manager{orgProvider, roleProvider, nameProvider, templates}
 }
 
 // AddRole adds a new role to a given organization.
@@ -48,15 +53,59 @@ func (m *Manager) AddRole(addRoleRequest *grpc_role_go.AddRoleRequest) (*entitie
 		return nil, derrors.NewNotFoundError("organizationID").WithParams(addRoleRequest.OrganizationId)
 	}
 	toAdd := entities.NewRoleFromGRPC(addRoleRequest)
-	err = m.RoleProvider.Add(*toAdd)
+	err = m.addRole(toAdd)
 	if err != nil {
 		return nil, err
 	}
-	err = m.OrgProvider.AddRole(toAdd.OrganizationId, toAdd.RoleId)
+	return toAdd, nil
+}
+
+// addRole reserves the name of a role in its organization and adds it.
+func (m *Manager) addRole(toAdd *entities.Role) derrors.Error {
+	scope := entities.RoleNameScope(toAdd.OrganizationId)
+	err := m.NameProvider.Reserve(*entities.NewNameReservation(scope, toAdd.Name, toAdd.RoleId))
 	if err != nil {
-		return nil, err
+		if err.Type() == derrors.AlreadyExists {
+			return derrors.NewAlreadyExistsError("role").WithParams(toAdd.OrganizationId, toAdd.Name)
+		}
+		return err
 	}
-	return toAdd, nil
+	err = m.RoleProvider.Add(*toAdd)
+	if err == nil {
+		err = m.OrgProvider.AddRole(toAdd.OrganizationId, toAdd.RoleId)
+		if err != nil {
+			if rErr := m.RoleProvider.Remove(toAdd.RoleId); rErr != nil {
+				log.Warn().Str("roleId", toAdd.RoleId).Str("trace", rErr.DebugReport()).Msg("cannot remove role")
+			}
+		}
+	}
+	if err != nil {
+		if rErr := m.NameProvider.Release(scope, toAdd.Name, toAdd.RoleId); rErr != nil {
+			log.Warn().Str("name", toAdd.Name).Str("trace", rErr.DebugReport()).Msg("cannot release role name")
+		}
+		return err
+	}
+	return nil
+}
+
+// AddTemplateRoles creates the roles defined by the templates in a new organization. If a role cannot be created,
+// the roles already created are removed.
+func (m *Manager) AddTemplateRoles(organizationID string) ([]entities.Role, derrors.Error) {
+	result := make([]entities.Role, 0, len(m.Templates))
+	for _, template := range m.Templates {
+		toAdd := template.NewRole(organizationID)
+		err := m.addRole(toAdd)
+		if err != nil {
+			for _, added := range result {
+				if rErr := m.removeRole(&added); rErr != nil {
+					log.Warn().Str("roleId", added.RoleId).Str("trace", rErr.DebugReport()).Msg("cannot remove template role")
+				}
+			}
+			return nil, err
+		}
+		result = append(result, *toAdd)
+	}
+	return result, nil
 }
 
 // GetRole returns an existing role.
@@ -79,6 +128,90 @@ func (m *Manager) GetRole(roleID *grpc_role_go.RoleId) (*entities.Role, derrors.
 	return m.RoleProvider.Get(roleID.RoleId)
 }
 
+// BootstrapRoles creates the roles defined by the templates in the organizations that have no roles, such as the
+// organizations added before the role templates were introduced. It returns the number of organizations updated.
+func (m *Manager) BootstrapRoles() (int, derrors.Error) {
+	organizations, err := m.OrgProvider.List()
+	if err != nil {
+		return 0, err
+	}
+	bootstrapped := 0
+	for _, org := range organizations {
+		roleIDs, err := m.OrgProvider.ListRoles(org.ID)
+		if err != nil {
+			return bootstrapped, err
+		}
+		if len(roleIDs) > 0 {
+			continue
+		}
+		_, err = m.AddTemplateRoles(org.ID)
+		if err != nil {
+			return bootstrapped, err
+		}
+		bootstrapped++
+	}
+	return bootstrapped, nil
+}
+
+// SetRolePermissions replaces the permissions granted by a role. The permissions of the internal roles cannot be
+// changed.
+func (m *Manager) SetRolePermissions(roleID *grpc_role_go.RoleId, permissions []entities.Permission) (*entities.Role, derrors.Error) {
+	if err := entities.ValidPermissions(permissions); err != nil {
+		return nil, err
+	}
+	role, err := m.GetRole(roleID)
+	if err != nil {
+		return nil, err
+	}
+	if role.Internal {
+		return nil, derrors.NewFailedPreconditionError("internal roles cannot be modified").WithParams(roleID.OrganizationId, roleID.RoleId)
+	}
+	role.Permissions = permissions
+	err = m.RoleProvider.Update(*role)
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// UpdateRole updates the name or the description of a role. The names of the roles are unique in an organization, and
+// the internal roles cannot be modified.
+func (m *Manager) UpdateRole(request entities.UpdateRoleRequest) (*entities.Role, derrors.Error) {
+	if err := entities.ValidUpdateRoleRequest(request); err != nil {
+		return nil, err
+	}
+	role, err := m.GetRole(&grpc_role_go.RoleId{OrganizationId: request.OrganizationId, RoleId: request.RoleId})
+	if err != nil {
+		return nil, err
+	}
+	if role.Internal {
+		return nil, derrors.NewFailedPreconditionError("internal roles cannot be modified").WithParams(request.OrganizationId, request.RoleId)
+	}
+	oldName := role.Name
+	renamed := request.UpdateName && request.Name != oldName
+	scope := entities.RoleNameScope(role.OrganizationId)
+	if renamed {
+		err = m.NameProvider.Rename(scope, oldName, request.Name, role.RoleId)
+		if err != nil {
+			if err.Type() == derrors.AlreadyExists {
+				return nil, derrors.NewAlreadyExistsError("role").WithParams(request.OrganizationId, request.Name)
+			}
+			return nil, err
+		}
+	}
+	role.ApplyUpdate(request)
+	err = m.RoleProvider.Update(*role)
+	if err != nil {
+		if renamed {
+			if rErr := m.NameProvider.Rename(scope, request.Name, oldName, role.RoleId); rErr != nil {
+				log.Warn().Str("name", request.Name).Str("trace", rErr.DebugReport()).Msg("cannot restore role name")
+			}
+		}
+		return nil, err
+	}
+	return role, nil
+}
+
 // ListRoles retrieves the list of roles of a given organization.
 func (m *Manager) ListRoles(organizationID *grpc_organization_go.OrganizationId) ([]entities.Role, derrors.Error) {
 	exists, err := m.OrgProvider.Exists(organizationID.OrganizationId)
@@ -103,7 +236,7 @@ func (m *Manager) ListRoles(organizationID *grpc_organization_go.OrganizationId)
 	return result, nil
 }
 
-// RemoveRole removes a given role from an organization.
+// RemoveRole removes a given role from an organization. The internal roles cannot be removed.
 func (m *Manager) RemoveRole(removeRoleRequest *grpc_role_go.RemoveRoleRequest) derrors.Error {
 	exists, err := m.OrgProvider.Exists(removeRoleRequest.OrganizationId)
 	if err != nil {
@@ -120,21 +253,70 @@ func (m *Manager) RemoveRole(removeRoleRequest *grpc_role_go.RemoveRoleRequest)
 	if !exists {
 		return derrors.NewNotFoundError("roleID").WithParams(removeRoleRequest.OrganizationId, removeRoleRequest.RoleId)
 	}
+	role, err := m.RoleProvider.Get(removeRoleRequest.RoleId)
+	if err != nil {
+		return err
+	}
+	if role.Internal {
+		return derrors.NewFailedPreconditionError("internal roles cannot be removed").WithParams(removeRoleRequest.OrganizationId, removeRoleRequest.RoleId)
+	}
+	return m.removeRole(role)
+}
 
-	err = m.OrgProvider.DeleteRole(removeRoleRequest.OrganizationId, removeRoleRequest.RoleId)
+// removeRole removes a role from its organization and releases its name.
+func (m *Manager) removeRole(role *entities.Role) derrors.Error {
+	err := m.OrgProvider.DeleteRole(role.OrganizationId, role.RoleId)
 	if err != nil {
 		return err
 	}
-	err = m.RoleProvider.Remove(removeRoleRequest.RoleId)
+	err = m.RoleProvider.Remove(role.RoleId)
 	if err != nil {
 		log.Error().Str("trace", conversions.ToDerror(err).DebugReport()).Msg("Error removing role. Rollback!")
-		rollbackError := m.OrgProvider.AddRole(removeRoleRequest.OrganizationId, removeRoleRequest.RoleId)
+		rollbackError := m.OrgProvider.AddRole(role.OrganizationId, role.RoleId)
 		if rollbackError != nil {
 			log.Error().Str("trace", conversions.ToDerror(rollbackError).DebugReport()).
-				Str("removeRoleRequest.OrganizationId", removeRoleRequest.OrganizationId).
-				Str("removeRoleRequest.RoleId", removeRoleRequest.RoleId).
+				Str("removeRoleRequest.OrganizationId", role.OrganizationId).
+				Str("removeRoleRequest.RoleId", role.RoleId).
 				Msg("error in Rollback")
 		}
+		return err
+	}
+	return m.NameProvider.Release(entities.RoleNameScope(role.OrganizationId), role.Name, role.RoleId)
+}
+
+// ReserveRoleNames reserves the names of the existing roles, returning the names used by more than one role of an
+// organization. It can be run several times.
+func (m *Manager) ReserveRoleNames() ([]entities.NameConflict, derrors.Error) {
+	organizations, err := m.OrgProvider.List()
+	if err != nil {
+		return nil, err
+	}
+	conflicts := make([]entities.NameConflict, 0)
+	for _, org := range organizations {
+		roleIDs, err := m.OrgProvider.ListRoles(org.ID)
+		if err != nil {
+			return nil, err
+		}
+		scope := entities.RoleNameScope(org.ID)
+		for _, roleID := range roleIDs {
+			role, err := m.RoleProvider.Get(roleID)
+			if err != nil {
+				return nil, err
+			}
+			err = m.NameProvider.Reserve(*entities.NewNameReservation(scope, role.Name, role.RoleId))
+			if err == nil {
+				continue
+			}
+			if err.Type() != derrors.AlreadyExists {
+				return nil, err
+			}
+			reservation, err := m.NameProvider.Get(scope, role.Name)
+			if err != nil {
+				return nil, err
+			}
+			conflicts = append(conflicts, entities.NameConflict{Scope: scope, Name: role.Name,
+				OwnerId: role.RoleId, ReservedBy: reservation.OwnerId})
+		}
 	}
-	return err
+	return conflicts, nil
 }
@@ -0,0 +1,232 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package role
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-role-go"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	nrProvider "github.com/nalej/system-model/internal/pkg/provider/name_reservation"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	rProvider "github.com/nalej/system-model/internal/pkg/provider/role"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Role permissions", func() {
+
+	var manager Manager
+	var roleID *grpc_role_go.RoleId
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider := orgProvider.NewMockupOrganizationProvider()
+		manager = NewManager(organizationProvider, rProvider.NewMockupRoleProvider(), nrProvider.NewMockupNameReservationProvider(),
+			entities.DefaultRoleTemplates())
+		targetOrganization := testhelpers.AddOrganization(organizationProvider)
+		added, err := manager.AddRole(createAddRoleRequest(targetOrganization.ID))
+		gomega.Expect(err).To(gomega.Succeed())
+		roleID = &grpc_role_go.RoleId{OrganizationId: added.OrganizationId, RoleId: added.RoleId}
+	})
+
+	ginkgo.It("should set the permissions of a role", func() {
+		permissions := []entities.Permission{
+			{Resource: entities.ResourceCluster, Action: entities.ActionRead},
+			{Resource: entities.ResourceAppInstance, Action: entities.AnyAction, Labels: map[string]string{"team": "a"}},
+		}
+		_, err := manager.SetRolePermissions(roleID, permissions)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		retrieved, err := manager.GetRole(roleID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Permissions).Should(gomega.Equal(permissions))
+	})
+
+	ginkgo.It("should reject invalid permissions", func() {
+		_, err := manager.SetRolePermissions(roleID, []entities.Permission{{Resource: "spaceship", Action: entities.ActionRead}})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		_, err = manager.SetRolePermissions(roleID, []entities.Permission{{Resource: entities.ResourceCluster, Action: "launch"}})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should not set the permissions of a non existing role", func() {
+		roleID.RoleId = entities.GenerateUUID()
+		_, err := manager.SetRolePermissions(roleID, []entities.Permission{})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
+
+var _ = ginkgo.Describe("Role templates", func() {
+
+	var manager Manager
+	var targetOrganization *entities.Organization
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider := orgProvider.NewMockupOrganizationProvider()
+		manager = NewManager(organizationProvider, rProvider.NewMockupRoleProvider(), nrProvider.NewMockupNameReservationProvider(),
+			entities.DefaultRoleTemplates())
+		targetOrganization = testhelpers.AddOrganization(organizationProvider)
+	})
+
+	ginkgo.It("should create the roles of the templates", func() {
+		added, err := manager.AddTemplateRoles(targetOrganization.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(added).Should(gomega.HaveLen(len(manager.Templates)))
+
+		roles, err := manager.ListRoles(&grpc_organization_go.OrganizationId{OrganizationId: targetOrganization.ID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(roles).Should(gomega.ConsistOf(added))
+		for i, template := range manager.Templates {
+			gomega.Expect(added[i].Name).Should(gomega.Equal(template.Name))
+			gomega.Expect(added[i].Internal).Should(gomega.Equal(template.Internal))
+			gomega.Expect(added[i].Permissions).Should(gomega.Equal(template.Permissions))
+		}
+	})
+
+	ginkgo.It("should remove the roles created if a template fails", func() {
+		_, err := manager.AddRole(&grpc_role_go.AddRoleRequest{OrganizationId: targetOrganization.ID, Name: manager.Templates[1].Name})
+		gomega.Expect(err).To(gomega.Succeed())
+
+		_, err = manager.AddTemplateRoles(targetOrganization.ID)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.AlreadyExists))
+		roles, err := manager.ListRoles(&grpc_organization_go.OrganizationId{OrganizationId: targetOrganization.ID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(roles).Should(gomega.HaveLen(1))
+		// the names of the removed roles are released
+		_, err = manager.AddRole(&grpc_role_go.AddRoleRequest{OrganizationId: targetOrganization.ID, Name: manager.Templates[0].Name})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should bootstrap the organizations without roles", func() {
+		other := testhelpers.AddOrganization(manager.OrgProvider)
+		_, err := manager.AddRole(&grpc_role_go.AddRoleRequest{OrganizationId: other.ID, Name: "custom"})
+		gomega.Expect(err).To(gomega.Succeed())
+
+		bootstrapped, err := manager.BootstrapRoles()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(bootstrapped).Should(gomega.Equal(1))
+		roles, err := manager.ListRoles(&grpc_organization_go.OrganizationId{OrganizationId: targetOrganization.ID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(roles).Should(gomega.HaveLen(len(manager.Templates)))
+		roles, err = manager.ListRoles(&grpc_organization_go.OrganizationId{OrganizationId: other.ID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(roles).Should(gomega.HaveLen(1))
+
+		bootstrapped, err = manager.BootstrapRoles()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(bootstrapped).Should(gomega.Equal(0))
+	})
+
+	ginkgo.It("should validate the templates", func() {
+		templates, err := entities.ParseRoleTemplates([]byte(`[{"name": "Admin", "internal": true, "permissions": [{"resource": "*", "action": "*"}]}]`))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(templates).Should(gomega.HaveLen(1))
+		gomega.Expect(templates[0].Internal).Should(gomega.BeTrue())
+
+		for _, invalid := range []string{`{"name": "Admin"}`, `[{"description": "no name"}]`, `[{"name": "Admin"}, {"name": "Admin"}]`,
+			`[{"name": "Admin", "permissions": [{"resource": "spaceship", "action": "read"}]}]`} {
+			_, err = entities.ParseRoleTemplates([]byte(invalid))
+			gomega.Expect(err).To(gomega.HaveOccurred())
+		}
+	})
+})
+
+var _ = ginkgo.Describe("Role updates", func() {
+
+	var manager Manager
+	var targetOrganization *entities.Organization
+	var internal *entities.Role
+	var custom *entities.Role
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider := orgProvider.NewMockupOrganizationProvider()
+		manager = NewManager(organizationProvider, rProvider.NewMockupRoleProvider(), nrProvider.NewMockupNameReservationProvider(),
+			[]entities.RoleTemplate{{Name: "Owner", Internal: true}, {Name: "Viewer"}})
+		targetOrganization = testhelpers.AddOrganization(organizationProvider)
+		added, err := manager.AddTemplateRoles(targetOrganization.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		internal, custom = &added[0], &added[1]
+	})
+
+	ginkgo.It("should update the name and the description of a role", func() {
+		updated, err := manager.UpdateRole(entities.UpdateRoleRequest{OrganizationId: targetOrganization.ID, RoleId: custom.RoleId,
+			UpdateName: true, Name: "Reader", UpdateDescription: true, Description: "read only"})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(updated.Name).Should(gomega.Equal("Reader"))
+
+		retrieved, err := manager.GetRole(&grpc_role_go.RoleId{OrganizationId: targetOrganization.ID, RoleId: custom.RoleId})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Name).Should(gomega.Equal("Reader"))
+		gomega.Expect(retrieved.Description).Should(gomega.Equal("read only"))
+
+		// the old name can be used again
+		_, err = manager.AddRole(&grpc_role_go.AddRoleRequest{OrganizationId: targetOrganization.ID, Name: custom.Name})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should keep the names of the roles unique in an organization", func() {
+		_, err := manager.UpdateRole(entities.UpdateRoleRequest{OrganizationId: targetOrganization.ID, RoleId: custom.RoleId,
+			UpdateName: true, Name: internal.Name})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.AlreadyExists))
+		_, err = manager.AddRole(&grpc_role_go.AddRoleRequest{OrganizationId: targetOrganization.ID, Name: custom.Name})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		_, err = manager.UpdateRole(entities.UpdateRoleRequest{OrganizationId: targetOrganization.ID, RoleId: custom.RoleId,
+			UpdateName: true, Name: ""})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		// other organizations can use the same names
+		other := testhelpers.AddOrganization(manager.OrgProvider)
+		_, err = manager.AddRole(&grpc_role_go.AddRoleRequest{OrganizationId: other.ID, Name: custom.Name})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should not modify or remove the internal roles", func() {
+		roleID := &grpc_role_go.RoleId{OrganizationId: targetOrganization.ID, RoleId: internal.RoleId}
+		_, err := manager.UpdateRole(entities.UpdateRoleRequest{OrganizationId: targetOrganization.ID, RoleId: internal.RoleId,
+			UpdateDescription: true, Description: "changed"})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.FailedPrecondition))
+		_, err = manager.SetRolePermissions(roleID, []entities.Permission{})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		err = manager.RemoveRole(&grpc_role_go.RemoveRoleRequest{OrganizationId: targetOrganization.ID, RoleId: internal.RoleId})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.FailedPrecondition))
+
+		retrieved, err := manager.GetRole(roleID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(*internal))
+
+		err = manager.RemoveRole(&grpc_role_go.RemoveRoleRequest{OrganizationId: targetOrganization.ID, RoleId: custom.RoleId})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should reserve the names of the existing roles", func() {
+		legacy := entities.Role{OrganizationId: targetOrganization.ID, RoleId: entities.GenerateUUID(), Name: custom.Name}
+		gomega.Expect(manager.RoleProvider.Add(legacy)).To(gomega.Succeed())
+		gomega.Expect(manager.OrgProvider.AddRole(legacy.OrganizationId, legacy.RoleId)).To(gomega.Succeed())
+
+		conflicts, err := manager.ReserveRoleNames()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(conflicts).Should(gomega.HaveLen(1))
+		gomega.Expect(conflicts[0].OwnerId).Should(gomega.Equal(legacy.RoleId))
+		gomega.Expect(conflicts[0].ReservedBy).Should(gomega.Equal(custom.RoleId))
+	})
+})
@@ -17,7 +17,10 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"github.com/nalej/derrors"
 	"github.com/nalej/grpc-account-go"
 	"github.com/nalej/grpc-application-history-logs-go"
 	"github.com/nalej/grpc-application-network-go"
@@ -27,6 +30,8 @@ import (
 	"github.com/nalej/grpc-project-go"
 	"github.com/nalej/grpc-role-go"
 	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
 	"github.com/nalej/system-model/internal/pkg/server/application_history_logs"
 	"github.com/nalej/system-model/internal/pkg/server/application_network"
@@ -34,16 +39,23 @@ import (
 
 	"github.com/nalej/system-model/internal/pkg/server/account"
 	"github.com/nalej/system-model/internal/pkg/server/asset"
+	"github.com/nalej/system-model/internal/pkg/server/auth"
 	"github.com/nalej/system-model/internal/pkg/server/cluster"
 	"github.com/nalej/system-model/internal/pkg/server/device"
 	"github.com/nalej/system-model/internal/pkg/server/eic"
+	"github.com/nalej/system-model/internal/pkg/server/ipam"
 	"github.com/nalej/system-model/internal/pkg/server/node"
+	"github.com/nalej/system-model/internal/pkg/server/photo"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
 	"github.com/nalej/system-model/internal/pkg/server/role"
 	"github.com/nalej/system-model/internal/pkg/server/user"
+	"github.com/nalej/system-model/internal/pkg/server/zt_garbage_collector"
+	"io/ioutil"
 	"net"
 
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 
 	"github.com/nalej/grpc-application-go"
@@ -53,13 +65,19 @@ import (
 	appHistoryLogsProvider "github.com/nalej/system-model/internal/pkg/provider/application_history_logs"
 	anProvider "github.com/nalej/system-model/internal/pkg/provider/application_network"
 	aProvider "github.com/nalej/system-model/internal/pkg/provider/asset"
+	"github.com/nalej/system-model/internal/pkg/provider/blob"
+	catProvider "github.com/nalej/system-model/internal/pkg/provider/catalog"
 	clusterProvider "github.com/nalej/system-model/internal/pkg/provider/cluster"
 	devProvider "github.com/nalej/system-model/internal/pkg/provider/device"
 	eicProvider "github.com/nalej/system-model/internal/pkg/provider/eic"
+	ipProvider "github.com/nalej/system-model/internal/pkg/provider/ipam"
+	nrProvider "github.com/nalej/system-model/internal/pkg/provider/name_reservation"
 	nodeProvider "github.com/nalej/system-model/internal/pkg/provider/node"
 	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
 	pProvider "github.com/nalej/system-model/internal/pkg/provider/project"
+	qProvider "github.com/nalej/system-model/internal/pkg/provider/quota"
 	rProvider "github.com/nalej/system-model/internal/pkg/provider/role"
+	saProvider "github.com/nalej/system-model/internal/pkg/provider/service_account"
 	uProvider "github.com/nalej/system-model/internal/pkg/provider/user"
 
 	"github.com/nalej/system-model/internal/pkg/server/application"
@@ -94,6 +112,12 @@ type Providers struct {
 	projectProvider        pProvider.Provider
 	appNetProvider         anProvider.Provider
 	appHistoryLogsProvider appHistoryLogsProvider.Provider
+	catalogProvider        catProvider.Provider
+	ipamProvider           ipProvider.Provider
+	serviceAccountProvider saProvider.Provider
+	nameProvider           nrProvider.Provider
+	quotaProvider          qProvider.Provider
+	blobProvider           blob.Provider
 }
 
 // Name of the service.
@@ -123,6 +147,12 @@ func (s *Service) CreateInMemoryProviders() *Providers {
 		projectProvider:        pProvider.NewMockupProjectProvider(),
 		appNetProvider:         anProvider.NewMockupApplicationNetworkProvider(),
 		appHistoryLogsProvider: appHistoryLogsProvider.NewMockupApplicationHistoryLogsProvider(),
+		catalogProvider:        catProvider.NewMockupCatalogProvider(),
+		ipamProvider:           ipProvider.NewMockupIPAMProvider(),
+		serviceAccountProvider: saProvider.NewMockupServiceAccountProvider(),
+		nameProvider:           nrProvider.NewMockupNameReservationProvider(),
+		quotaProvider:          qProvider.NewMockupQuotaProvider(),
+		blobProvider:           blob.NewMockupBlobProvider(),
 	}
 }
 
@@ -157,9 +187,28 @@ func (s *Service) CreateDBScyllaProviders() *Providers {
 			s.Configuration.ScyllaDBAddress, s.Configuration.ScyllaDBPort, s.Configuration.KeySpace),
 		appHistoryLogsProvider: appHistoryLogsProvider.NewScyllaApplicationHistoryLogsProvider(
 			s.Configuration.ScyllaDBAddress, s.Configuration.ScyllaDBPort, s.Configuration.KeySpace),
+		catalogProvider: catProvider.NewScyllaCatalogProvider(
+			s.Configuration.ScyllaDBAddress, s.Configuration.ScyllaDBPort, s.Configuration.KeySpace),
+		ipamProvider: ipProvider.NewScyllaIPAMProvider(
+			s.Configuration.ScyllaDBAddress, s.Configuration.ScyllaDBPort, s.Configuration.KeySpace),
+		serviceAccountProvider: saProvider.NewScyllaServiceAccountProvider(
+			s.Configuration.ScyllaDBAddress, s.Configuration.ScyllaDBPort, s.Configuration.KeySpace),
+		nameProvider: nrProvider.NewScyllaNameReservationProvider(
+			s.Configuration.ScyllaDBAddress, s.Configuration.ScyllaDBPort, s.Configuration.KeySpace),
+		quotaProvider: qProvider.NewScyllaQuotaProvider(
+			s.Configuration.ScyllaDBAddress, s.Configuration.ScyllaDBPort, s.Configuration.KeySpace),
+		blobProvider: s.createBlobProvider(),
 	}
 }
 
+// createBlobProvider returns the blob provider of the photos, kept in the file system if a directory is configured.
+func (s *Service) createBlobProvider() blob.Provider {
+	if s.Configuration.BlobDirectory != "" {
+		return blob.NewFileSystemBlobProvider(s.Configuration.BlobDirectory)
+	}
+	return blob.NewScyllaBlobProvider(s.Configuration.ScyllaDBAddress, s.Configuration.ScyllaDBPort, s.Configuration.KeySpace)
+}
+
 // GetProviders builds the providers according to the selected backend.
 func (s *Service) GetProviders() *Providers {
 	if s.Configuration.UseInMemoryProviders {
@@ -171,6 +220,40 @@ func (s *Service) GetProviders() *Providers {
 	return nil
 }
 
+// serverOptions creates the transport credentials and the authentication interceptors of the gRPC server.
+func (s *Service) serverOptions() ([]grpc.ServerOption, derrors.Error) {
+	options := make([]grpc.ServerOption, 0)
+	if s.Configuration.TLSCertFile != "" {
+		tlsConfig, err := auth.ServerTLSConfig(s.Configuration.TLSCertFile, s.Configuration.TLSKeyFile, s.Configuration.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	if !s.Configuration.AuthenticationEnabled() {
+		return options, nil
+	}
+	var verifier *auth.JWTVerifier
+	if s.Configuration.JWTSecretFile != "" {
+		secret, err := ioutil.ReadFile(s.Configuration.JWTSecretFile)
+		if err != nil {
+			return nil, derrors.AsError(err, "cannot read JWT secret")
+		}
+		verifier = auth.NewHMACVerifier(bytes.TrimSpace(secret), s.Configuration.JWTIssuer, s.Configuration.JWTAudience)
+	}
+	if s.Configuration.JWTPublicKeyFile != "" {
+		publicKey, err := auth.LoadRSAPublicKey(s.Configuration.JWTPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		verifier = auth.NewRSAVerifier(publicKey, s.Configuration.JWTIssuer, s.Configuration.JWTAudience)
+	}
+	authenticator := auth.NewAuthenticator(verifier, s.Configuration.TLSClientCAFile != "", s.Configuration.UnauthenticatedReflection)
+	options = append(options, grpc.UnaryInterceptor(authenticator.UnaryInterceptor()),
+		grpc.StreamInterceptor(authenticator.StreamInterceptor()))
+	return options, nil
+}
+
 // Run the service, launch the REST service handler.
 func (s *Service) Run() error {
 	cErr := s.Configuration.Validate()
@@ -183,30 +266,52 @@ func (s *Service) Run() error {
 	if err != nil {
 		log.Fatal().Errs("failed to listen: %v", []error{err})
 	}
+	// quotas
+	quotaManager := quota.NewManager(p.organizationProvider, p.settingsProvider, p.quotaProvider)
+	// photos
+	photoManager := photo.NewManager(p.blobProvider)
+	// roles
+	roleTemplates, cErr := s.Configuration.LoadRoleTemplates()
+	if cErr != nil {
+		log.Fatal().Str("err", cErr.DebugReport()).Msg("invalid role templates")
+	}
+	roleManager := role.NewManager(p.organizationProvider, p.roleProvider, p.nameProvider, roleTemplates)
+	roleHandler := role.NewHandler(roleManager)
 	// organizations
-	orgManager := organization.NewManager(p.organizationProvider, p.settingsProvider)
+	orgManager := organization.NewManager(p.organizationProvider, p.settingsProvider, p.userProvider,
+		p.serviceAccountProvider, p.nameProvider, photoManager, roleManager, s.Configuration.StrictSettings)
 	organizationHandler := organization.NewHandler(orgManager)
 	// clusters
-	clusterManager := cluster.NewManager(p.organizationProvider, p.clusterProvider)
+	clusterManager := cluster.NewManager(p.organizationProvider, p.clusterProvider, quotaManager)
 	clusterHandler := cluster.NewHandler(clusterManager)
 	// nodes
-	nodeManager := node.NewManager(p.organizationProvider, p.clusterProvider, p.nodeProvider)
+	nodeManager := node.NewManager(p.organizationProvider, p.clusterProvider, p.nodeProvider, quotaManager)
 	nodeHandler := node.NewHandler(nodeManager)
+	// ip address management
+	pool, cErr := cidr.ParsePool(s.Configuration.IPAMPool, s.Configuration.IPAMBlockPrefixLength)
+	if cErr != nil {
+		log.Fatal().Str("err", cErr.DebugReport()).Msg("invalid ip pool")
+	}
+	ipamManager := ipam.NewManager(p.organizationProvider, p.ipamProvider, pool)
 	// applications
-	appManager := application.NewManager(p.organizationProvider, p.applicationProvider, p.deviceProvider, s.Configuration.PublicHostDomain)
+	appManager := application.NewManager(p.organizationProvider, p.applicationProvider, p.deviceProvider, s.Configuration.PublicHostDomain, ipamManager, quotaManager)
 	applicationHandler := application.NewHandler(appManager)
+	gcManager := zt_garbage_collector.NewManager(p.organizationProvider, p.applicationProvider, p.clusterProvider, appManager, s.Configuration.ZtGCGracePeriod)
+	if s.Configuration.ZtGCInterval > 0 {
+		gcContext, stopGC := context.WithCancel(context.Background())
+		defer stopGC()
+		go gcManager.Run(gcContext, s.Configuration.ZtGCInterval)
+	}
 
-	appNetManager := application_network.NewManager(p.organizationProvider, p.applicationProvider, p.appNetProvider)
+	appNetManager := application_network.NewManager(p.organizationProvider, p.applicationProvider, p.appNetProvider, ipamManager,
+		entities.ConnectionStatusPolicy(s.Configuration.ConnectionStatusPolicy), quotaManager)
 	appNetHandler := application_network.NewHandler(appNetManager)
 
-	// roles
-	roleManager := role.NewManager(p.organizationProvider, p.roleProvider)
-	roleHandler := role.NewHandler(roleManager)
 	// users
-	userManager := user.NewManager(p.organizationProvider, p.userProvider)
+	userManager := user.NewManager(p.organizationProvider, p.userProvider, p.roleProvider, p.serviceAccountProvider, quotaManager, photoManager)
 	userHandler := user.NewHandler(userManager)
 	//device
-	deviceManager := device.NewManager(p.deviceProvider, p.organizationProvider)
+	deviceManager := device.NewManager(p.deviceProvider, p.organizationProvider, p.nameProvider, quotaManager)
 	deviceHandler := device.NewHandler(deviceManager)
 
 	assetManager := asset.NewManager(p.organizationProvider, p.assetProvider)
@@ -215,16 +320,21 @@ func (s *Service) Run() error {
 	controllerManager := eic.NewManager(p.controllerProvider, p.organizationProvider)
 	controllerHandler := eic.NewHandler(controllerManager)
 	//account
-	accountManager := account.NewManager(p.accountProvider)
+	accountManager := account.NewManager(p.accountProvider, p.nameProvider, p.organizationProvider, p.projectProvider)
 	accountHandler := account.NewHandler(accountManager)
 	//project
-	projectManager := project.NewManager(p.accountProvider, p.projectProvider)
+	projectManager := project.NewManager(p.accountProvider, p.projectProvider, p.nameProvider, p.organizationProvider,
+		p.clusterProvider, p.applicationProvider, p.deviceProvider)
 	projectHandler := project.NewHandler(projectManager)
 	//app history logs
 	appHistoryLogsManager := application_history_logs.NewManager(p.appHistoryLogsProvider)
 	appHistoryLogsHandler := application_history_logs.NewHandler(appHistoryLogsManager)
 
-	grpcServer := grpc.NewServer()
+	options, cErr := s.serverOptions()
+	if cErr != nil {
+		log.Fatal().Str("err", cErr.DebugReport()).Msg("invalid authentication configuration")
+	}
+	grpcServer := grpc.NewServer(options...)
 	grpc_organization_go.RegisterOrganizationsServer(grpcServer, organizationHandler)
 	grpc_infrastructure_go.RegisterClustersServer(grpcServer, clusterHandler)
 	grpc_infrastructure_go.RegisterNodesServer(grpcServer, nodeHandler)
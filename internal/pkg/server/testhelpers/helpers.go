@@ -17,15 +17,25 @@
 package testhelpers
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/nalej/grpc-organization-go"
 	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/entities/devices"
+	"github.com/nalej/system-model/internal/pkg/provider/blob"
 	devProvider "github.com/nalej/system-model/internal/pkg/provider/device"
 	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/organization_setting"
+	quotaProvider "github.com/nalej/system-model/internal/pkg/provider/quota"
+	"github.com/nalej/system-model/internal/pkg/server/photo"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
+	"image"
+	"image/color"
+	"image/png"
 	"math/rand"
 )
 
@@ -49,10 +59,24 @@ func CreateAddOrganizationRequest() *grpc_organization_go.AddOrganizationRequest
 		State:       "State",
 		Country:     "Country",
 		ZipCode:     "Zip Code",
-		PhotoBase64: "Photo",
+		PhotoBase64: CreatePhotoBase64(),
 	}
 }
 
+// CreatePhotoBase64 creates a base64 encoded PNG photo larger than the thumbnails.
+func CreatePhotoBase64() string {
+	img := image.NewRGBA(image.Rect(0, 0, 2*entities.ThumbnailMaxSide, entities.ThumbnailMaxSide))
+	for x := 0; x < img.Bounds().Dx(); x++ {
+		for y := 0; y < img.Bounds().Dy(); y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buffer bytes.Buffer
+	err := png.Encode(&buffer, img)
+	gomega.Expect(err).To(gomega.Succeed())
+	return base64.StdEncoding.EncodeToString(buffer.Bytes())
+}
+
 func CreateUpdateOrganizationRequest(id string, updateName bool, newName string) *grpc_organization_go.UpdateOrganizationRequest {
 	return &grpc_organization_go.UpdateOrganizationRequest{
 		OrganizationId:    id,
@@ -70,6 +94,17 @@ func AddOrganization(orgProvider orgProvider.Provider) *entities.Organization {
 	return toAdd
 }
 
+// NewPhotoManager creates a photo manager that keeps the photos in memory.
+func NewPhotoManager() photo.Manager {
+	return photo.NewManager(blob.NewMockupBlobProvider())
+}
+
+// NewQuotaManager creates a quota manager with the system default limits.
+func NewQuotaManager(orgProvider orgProvider.Provider) quota.Manager {
+	return quota.NewManager(orgProvider, organization_setting.NewMockupOrganizationSettingProvider(),
+		quotaProvider.NewMockupQuotaProvider())
+}
+
 func CreateDeviceGroup(devProvider devProvider.Provider, organizationID string, deviceGroupName string) *devices.DeviceGroup {
 	labels := make(map[string]string, 0)
 	toAdd := devices.NewDeviceGroup(organizationID, entities.GenerateUUID(), deviceGroupName, labels)
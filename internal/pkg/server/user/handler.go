@@ -26,6 +26,7 @@ import (
 	"github.com/nalej/grpc-user-go"
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
 	"github.com/rs/zerolog/log"
 )
 
@@ -51,7 +52,7 @@ func (h *Handler) AddUser(ctx context.Context, addUserRequest *grpc_user_go.AddU
 	added, err := h.Manager.AddUser(addUserRequest)
 	if err != nil {
 		log.Error().Str("trace", err.DebugReport()).Msg("cannot add user")
-		return nil, conversions.ToGRPCError(err)
+		return nil, quota.ToGRPCError(err)
 	}
 	log.Debug().Str("organizationID", addUserRequest.OrganizationId).
 		Str("email", addUserRequest.Email).Msg("user has been added")
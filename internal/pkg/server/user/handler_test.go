@@ -24,6 +24,8 @@ import (
 	"github.com/nalej/grpc-utils/pkg/test"
 	"github.com/nalej/system-model/internal/pkg/entities"
 	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	rProvider "github.com/nalej/system-model/internal/pkg/provider/role"
+	saProvider "github.com/nalej/system-model/internal/pkg/provider/service_account"
 	uProvider "github.com/nalej/system-model/internal/pkg/provider/user"
 	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
 	"github.com/onsi/ginkgo"
@@ -65,7 +67,9 @@ var _ = ginkgo.Describe("User service", func() {
 		userProvider = uProvider.NewMockupUserProvider()
 
 		// Register the service
-		manager := NewManager(organizationProvider, userProvider)
+		manager := NewManager(organizationProvider, userProvider, rProvider.NewMockupRoleProvider(),
+			saProvider.NewMockupServiceAccountProvider(), testhelpers.NewQuotaManager(organizationProvider),
+			testhelpers.NewPhotoManager())
 		handler := NewHandler(manager)
 		grpc_user_go.RegisterUsersServer(server, handler)
 
@@ -23,23 +23,90 @@ import (
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/system-model/internal/pkg/entities"
 	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/provider/role"
+	"github.com/nalej/system-model/internal/pkg/provider/service_account"
 	"github.com/nalej/system-model/internal/pkg/provider/user"
+	"github.com/nalej/system-model/internal/pkg/server/photo"
+	"github.com/nalej/system-model/internal/pkg/server/quota"
+	"github.com/nalej/system-model/internal/pkg/utils"
 	"github.com/rs/zerolog/log"
+	"time"
 )
 
+// lastUsedResolution with the minimum time between updates of the last use of an API key, so verifying a key
+// does not write to the database on every request.
+const lastUsedResolution = time.Minute
+
 // Manager structure with the required providers for user operations.
 type Manager struct {
-	OrgProvider  organization.Provider
-	UserProvider user.Provider
+	OrgProvider            organization.Provider
+	UserProvider           user.Provider
+	RoleProvider           role.Provider
+	ServiceAccountProvider service_account.Provider
+	Quota                  quota.Manager
+	Photos                 photo.Manager
 }
 
 // NewManager creates a Manager using a set of providers.
-func NewManager(orgProvider organization.Provider, userProvider user.Provider) Manager {
-	return Manager{orgProvider, userProvider}
+func NewManager(orgProvider organization.Provider, userProvider user.Provider, roleProvider role.Provider,
+	serviceAccountProvider service_account.Provider, quotaManager quota.Manager, photoManager photo.Manager) Manager {
+	return Manager{orgProvider, userProvider, roleProvider, serviceAccountProvider, quotaManager, photoManager}
+}
+
+// fillPhoto sets the photo of a user kept in the blob store, or its thumbnail. The photos of the users that have not
+// been migrated to the blob store are kept in the user.
+func (m *Manager) fillPhoto(usr *entities.User, thumbnail bool) {
+	blobID := usr.PhotoId
+	if thumbnail {
+		blobID = usr.ThumbnailId
+	}
+	if blobID == "" {
+		return
+	}
+	photo, err := m.Photos.Load(blobID)
+	if err != nil {
+		log.Warn().Str("email", usr.Email).Str("blobId", blobID).Str("trace", err.DebugReport()).Msg("cannot load user photo")
+		return
+	}
+	usr.PhotoBase64 = photo
+}
+
+// checkOrganization checks that an organization exists.
+func (m *Manager) checkOrganization(organizationID string) derrors.Error {
+	exists, err := m.OrgProvider.Exists(organizationID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("organizationID").WithParams(organizationID)
+	}
+	return nil
 }
 
-// AddUser adds a new user to a given organization.
+// getMembership retrieves the membership of a user in an existing organization.
+func (m *Manager) getMembership(userID *grpc_user_go.UserId) (*entities.Membership, derrors.Error) {
+	if err := m.checkOrganization(userID.OrganizationId); err != nil {
+		return nil, err
+	}
+	membership, err := m.UserProvider.GetMembership(userID.Email, userID.OrganizationId)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return nil, derrors.NewNotFoundError("userID").WithParams(userID.OrganizationId, userID.Email)
+		}
+		return nil, err
+	}
+	return membership, nil
+}
+
+// AddUser adds a user to a given organization. If the user is already a member of another organization, only
+// the membership is added and the user information is kept.
 func (m *Manager) AddUser(addUserRequest *grpc_user_go.AddUserRequest) (*entities.User, derrors.Error) {
+	return m.AddUserWithRoles(addUserRequest, nil)
+}
+
+// AddUserWithRoles adds a user to a given organization with a set of roles of the organization. The roles are part of
+// the membership, so the user never becomes a member without them.
+func (m *Manager) AddUserWithRoles(addUserRequest *grpc_user_go.AddUserRequest, roleIDs []string) (*entities.User, derrors.Error) {
 	exists, err := m.OrgProvider.Exists(addUserRequest.OrganizationId)
 	if err != nil {
 		return nil, err
@@ -47,117 +114,519 @@ func (m *Manager) AddUser(addUserRequest *grpc_user_go.AddUserRequest) (*entitie
 	if !exists {
 		return nil, derrors.NewNotFoundError("not found organizationID").WithParams(addUserRequest.OrganizationId)
 	}
-	toAdd := entities.NewUserFromGRPC(addUserRequest)
-	err = m.UserProvider.Add(*toAdd)
+	exists, err = m.UserProvider.MembershipExists(addUserRequest.Email, addUserRequest.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, derrors.NewAlreadyExistsError(addUserRequest.Email).WithParams(addUserRequest.OrganizationId)
+	}
+	for _, roleID := range roleIDs {
+		exists, err = m.OrgProvider.RoleExists(addUserRequest.OrganizationId, roleID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, derrors.NewNotFoundError("roleID").WithParams(addUserRequest.OrganizationId, roleID)
+		}
+	}
+	err = m.Quota.Acquire(addUserRequest.OrganizationId, entities.UserQuota, 1)
 	if err != nil {
 		return nil, err
 	}
-	err = m.OrgProvider.AddUser(toAdd.OrganizationId, toAdd.Email)
+
+	usr, err := m.UserProvider.Get(addUserRequest.Email)
+	created := false
+	if err != nil {
+		if err.Type() != derrors.NotFound {
+			m.Quota.Release(addUserRequest.OrganizationId, entities.UserQuota, 1)
+			return nil, err
+		}
+		usr = entities.NewUserFromGRPC(addUserRequest)
+		usr.PhotoId, usr.ThumbnailId, err = m.Photos.Store(usr.PhotoBase64)
+		if err != nil {
+			m.Quota.Release(addUserRequest.OrganizationId, entities.UserQuota, 1)
+			return nil, err
+		}
+		// only the reference to the photo is kept in the user
+		stored := *usr
+		stored.PhotoBase64 = ""
+		err = m.UserProvider.Add(stored)
+		if err != nil {
+			m.Quota.Release(addUserRequest.OrganizationId, entities.UserQuota, 1)
+			m.Photos.Remove(usr.PhotoId, usr.ThumbnailId)
+			return nil, err
+		}
+		created = true
+	} else {
+		m.fillPhoto(usr, false)
+	}
+
+	membership := entities.NewMembership(addUserRequest.OrganizationId, addUserRequest.Email)
+	membership.RoleIds = append(membership.RoleIds, roleIDs...)
+	err = m.UserProvider.AddMembership(*membership)
+	if err == nil {
+		err = m.OrgProvider.AddUser(membership.OrganizationId, membership.Email)
+		if err != nil {
+			if rollbackError := m.UserProvider.RemoveMembership(membership.Email, membership.OrganizationId); rollbackError != nil {
+				log.Error().Str("trace", conversions.ToDerror(rollbackError).DebugReport()).Msg("error in Rollback")
+			}
+		}
+	}
 	if err != nil {
+		m.Quota.Release(addUserRequest.OrganizationId, entities.UserQuota, 1)
+		if created {
+			if rollbackError := m.UserProvider.Remove(usr.Email); rollbackError != nil {
+				log.Error().Str("trace", conversions.ToDerror(rollbackError).DebugReport()).Msg("error in Rollback")
+			}
+			m.Photos.Remove(usr.PhotoId, usr.ThumbnailId)
+		}
 		return nil, err
 	}
-	return toAdd, nil
+	return usr.ForMembership(membership), nil
 }
 
-// AddUser adds a new user to a given organization.
+// UpdateUser updates the information of a user that is a member of a given organization. The information is shared
+// by all the organizations of the user.
 func (m *Manager) UpdateUser(request *grpc_user_go.UpdateUserRequest) derrors.Error {
-	exists, err := m.OrgProvider.Exists(request.OrganizationId)
+	_, err := m.getMembership(&grpc_user_go.UserId{OrganizationId: request.OrganizationId, Email: request.Email})
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return derrors.NewNotFoundError("not found organizationID").WithParams(request.OrganizationId)
+
+	photoID, thumbnailID := "", ""
+	if request.UpdatePhotoBase64 {
+		photoID, thumbnailID, err = m.Photos.Store(request.PhotoBase64)
+		if err != nil {
+			return err
+		}
+	}
+	var replaced []string
+	err = utils.RetryOnConflict(func() derrors.Error {
+		usr, err := m.UserProvider.Get(request.Email)
+		if err != nil {
+			return err
+		}
+		usr.ApplyUpdate(request)
+		if request.UpdatePhotoBase64 {
+			replaced = []string{usr.PhotoId, usr.ThumbnailId}
+			usr.PhotoId, usr.ThumbnailId, usr.PhotoBase64 = photoID, thumbnailID, ""
+		}
+		return m.UserProvider.Update(*usr)
+	})
+	if err != nil {
+		m.Photos.Remove(photoID, thumbnailID)
+		return err
+	}
+	m.Photos.Remove(replaced...)
+	return nil
+}
+
+// getUser returns an existing user as a member of an organization, with the photo or its thumbnail.
+func (m *Manager) getUser(userID *grpc_user_go.UserId, thumbnail bool) (*entities.User, derrors.Error) {
+	membership, err := m.getMembership(userID)
+	if err != nil {
+		return nil, err
+	}
+	usr, err := m.UserProvider.Get(userID.Email)
+	if err != nil {
+		return nil, err
 	}
+	m.fillPhoto(usr, thumbnail)
+	return usr.ForMembership(membership), nil
+}
 
-	usr, err := m.UserProvider.Get(request.Email)
+// GetUser returns an existing user as a member of an organization.
+func (m *Manager) GetUser(userID *grpc_user_go.UserId) (*entities.User, derrors.Error) {
+	return m.getUser(userID, false)
+}
+
+// GetUsers retrieves the list of users of a given organization. The users include the thumbnail of their photo. Each
+// user and its thumbnail are read on their own, so the cost of the list grows with the members of the organization.
+func (m *Manager) GetUsers(organizationID *grpc_organization_go.OrganizationId) ([]entities.User, derrors.Error) {
+	if err := m.checkOrganization(organizationID.OrganizationId); err != nil {
+		return nil, err
+	}
+	users, err := m.OrgProvider.ListUsers(organizationID.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]entities.User, 0)
+	for _, email := range users {
+		toAdd, err := m.getUser(&grpc_user_go.UserId{OrganizationId: organizationID.OrganizationId, Email: email}, true)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *toAdd)
+	}
+	return result, nil
+}
+
+// RemoveUser removes a given user from an organization. The user information is removed once the user is no longer
+// a member of any organization.
+func (m *Manager) RemoveUser(removeRequest *grpc_user_go.RemoveUserRequest) derrors.Error {
+	_, err := m.getMembership(&grpc_user_go.UserId{OrganizationId: removeRequest.OrganizationId, Email: removeRequest.Email})
 	if err != nil {
 		return err
 	}
 
-	usr.ApplyUpdate(request)
+	err = m.OrgProvider.DeleteUser(removeRequest.OrganizationId, removeRequest.Email)
+	if err != nil {
+		return err
+	}
+
+	err = m.UserProvider.RemoveMembership(removeRequest.Email, removeRequest.OrganizationId)
+	if err != nil {
+		log.Error().Str("trace", conversions.ToDerror(err).DebugReport()).Msg("Error removing membership. Rollback!")
+		rollbackError := m.OrgProvider.AddUser(removeRequest.OrganizationId, removeRequest.Email)
+		if rollbackError != nil {
+			log.Error().Str("trace", conversions.ToDerror(rollbackError).DebugReport()).Msg("error in Rollback")
+		}
+		return err
+	}
+	m.Quota.Release(removeRequest.OrganizationId, entities.UserQuota, 1)
 
-	err = m.UserProvider.Update(*usr)
+	remaining, err := m.UserProvider.ListMemberships(removeRequest.Email)
 	if err != nil {
 		return err
 	}
+	if len(remaining) == 0 {
+		usr, err := m.UserProvider.Get(removeRequest.Email)
+		if err != nil {
+			return err
+		}
+		err = m.UserProvider.Remove(removeRequest.Email)
+		if err != nil {
+			return err
+		}
+		m.Photos.Remove(usr.PhotoId, usr.ThumbnailId)
+	}
 	return nil
 }
 
-// GetUser returns an existing user.
-func (m *Manager) GetUser(userID *grpc_user_go.UserId) (*entities.User, derrors.Error) {
-	exists, err := m.OrgProvider.Exists(userID.OrganizationId)
+// ListMemberships retrieves the organizations a user is a member of.
+func (m *Manager) ListMemberships(email string) ([]entities.Membership, derrors.Error) {
+	exists, err := m.UserProvider.Exists(email)
 	if err != nil {
 		return nil, err
 	}
 	if !exists {
-		return nil, derrors.NewNotFoundError("organizationID").WithParams(userID.OrganizationId)
+		return nil, derrors.NewNotFoundError("email").WithParams(email)
 	}
+	return m.UserProvider.ListMemberships(email)
+}
 
-	exists, err = m.OrgProvider.UserExists(userID.OrganizationId, userID.Email)
+// SetMembershipState changes the state of the membership of a user in an organization.
+func (m *Manager) SetMembershipState(userID *grpc_user_go.UserId, state entities.MembershipState) (*entities.Membership, derrors.Error) {
+	if err := entities.ValidMembershipState(state); err != nil {
+		return nil, err
+	}
+	membership, err := m.getMembership(userID)
 	if err != nil {
 		return nil, err
 	}
-	if !exists {
-		return nil, derrors.NewNotFoundError("userID").WithParams(userID.OrganizationId, userID.Email)
+	membership.State = state
+	err = m.UserProvider.UpdateMembership(*membership)
+	if err != nil {
+		return nil, err
 	}
-	return m.UserProvider.Get(userID.Email)
+	return membership, nil
 }
 
-// GetUsers retrieves the list of users of a given organization.
-func (m *Manager) GetUsers(organizationID *grpc_organization_go.OrganizationId) ([]entities.User, derrors.Error) {
-	exists, err := m.OrgProvider.Exists(organizationID.OrganizationId)
+// AssignRole assigns a role of the organization to a user.
+func (m *Manager) AssignRole(userID *grpc_user_go.UserId, roleID string) (*entities.Membership, derrors.Error) {
+	membership, err := m.getMembership(userID)
+	if err != nil {
+		return nil, err
+	}
+	exists, err := m.OrgProvider.RoleExists(userID.OrganizationId, roleID)
 	if err != nil {
 		return nil, err
 	}
 	if !exists {
-		return nil, derrors.NewNotFoundError("organizationID").WithParams(organizationID.OrganizationId)
+		return nil, derrors.NewNotFoundError("roleID").WithParams(userID.OrganizationId, roleID)
 	}
-	users, err := m.OrgProvider.ListUsers(organizationID.OrganizationId)
+	if membership.HasRole(roleID) {
+		return nil, derrors.NewAlreadyExistsError("role already assigned").WithParams(userID.Email, roleID)
+	}
+	membership.RoleIds = append(membership.RoleIds, roleID)
+	err = m.UserProvider.UpdateMembership(*membership)
 	if err != nil {
 		return nil, err
 	}
-	result := make([]entities.User, 0)
-	for _, email := range users {
-		toAdd, err := m.UserProvider.Get(email)
+	return membership, nil
+}
+
+// UnassignRole removes a role from a user.
+func (m *Manager) UnassignRole(userID *grpc_user_go.UserId, roleID string) (*entities.Membership, derrors.Error) {
+	membership, err := m.getMembership(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !membership.HasRole(roleID) {
+		return nil, derrors.NewNotFoundError("role not assigned").WithParams(userID.Email, roleID)
+	}
+	membership.RemoveRole(roleID)
+	err = m.UserProvider.UpdateMembership(*membership)
+	if err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// Authorize checks if any of the roles of a user in an organization grants an action on a resource. Users can only
+// be authorized on resources of the organization, and while their membership is active. Roles that no longer exist
+// are ignored.
+func (m *Manager) Authorize(userID *grpc_user_go.UserId, action entities.Action, resource *entities.Resource) (*entities.AuthorizationDecision, derrors.Error) {
+	if err := entities.ValidAuthorizeRequest(action, resource); err != nil {
+		return nil, err
+	}
+	membership, err := m.getMembership(userID)
+	if err != nil {
+		return nil, err
+	}
+	if resource.OrganizationId != userID.OrganizationId || !membership.Active() {
+		return &entities.AuthorizationDecision{Allowed: false}, nil
+	}
+	for _, roleID := range membership.RoleIds {
+		assigned, err := m.RoleProvider.Get(roleID)
 		if err != nil {
+			if err.Type() == derrors.NotFound {
+				log.Warn().Str("email", membership.Email).Str("roleID", roleID).Msg("user has a role that does not exist")
+				continue
+			}
 			return nil, err
 		}
-		result = append(result, *toAdd)
+		if permission := assigned.Allows(action, resource); permission != nil {
+			return &entities.AuthorizationDecision{Allowed: true, RoleId: roleID, Permission: permission}, nil
+		}
 	}
-	return result, nil
+	return &entities.AuthorizationDecision{Allowed: false}, nil
 }
 
-// RemoveUser removes a given user from an organization.
-func (m *Manager) RemoveUser(removeRequest *grpc_user_go.RemoveUserRequest) derrors.Error {
-	exists, err := m.OrgProvider.Exists(removeRequest.OrganizationId)
+// MigrateMemberships creates the memberships of the users added before users could belong to several
+// organizations, using the organization, member since and roles of the user. Users that already have a membership are
+// skipped, so the migration can be run several times. It returns the number of memberships created.
+func (m *Manager) MigrateMemberships() (int, derrors.Error) {
+	organizations, err := m.OrgProvider.List()
 	if err != nil {
-		return err
+		return 0, err
+	}
+	migrated := 0
+	for _, org := range organizations {
+		emails, err := m.OrgProvider.ListUsers(org.ID)
+		if err != nil {
+			return migrated, err
+		}
+		for _, email := range emails {
+			exists, err := m.UserProvider.MembershipExists(email, org.ID)
+			if err != nil {
+				return migrated, err
+			}
+			if exists {
+				continue
+			}
+			usr, err := m.UserProvider.Get(email)
+			if err != nil {
+				if err.Type() == derrors.NotFound {
+					log.Warn().Str("organizationID", org.ID).Str("email", email).Msg("organization user not found, skipping")
+					continue
+				}
+				return migrated, err
+			}
+			membership := entities.NewMembership(org.ID, email)
+			membership.MemberSince = usr.MemberSince
+			membership.RoleIds = append(membership.RoleIds, usr.RoleIds...)
+			err = m.UserProvider.AddMembership(*membership)
+			if err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+	}
+	return migrated, nil
+}
+
+// MigratePhotos moves the photos kept in the users to the blob store. Photos that are not valid are kept in the
+// users, and users already migrated are skipped, so the migration can be run several times. It returns the number of
+// photos migrated.
+func (m *Manager) MigratePhotos() (int, derrors.Error) {
+	organizations, err := m.OrgProvider.List()
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	visited := make(map[string]bool, 0)
+	for _, org := range organizations {
+		emails, err := m.OrgProvider.ListUsers(org.ID)
+		if err != nil {
+			return migrated, err
+		}
+		for _, email := range emails {
+			if visited[email] {
+				continue
+			}
+			visited[email] = true
+			err = utils.RetryOnConflict(func() derrors.Error {
+				usr, err := m.UserProvider.Get(email)
+				if err != nil {
+					return err
+				}
+				if usr.PhotoId != "" || usr.PhotoBase64 == "" {
+					return nil
+				}
+				photoID, thumbnailID, err := m.Photos.Store(usr.PhotoBase64)
+				if err != nil {
+					return err
+				}
+				usr.PhotoId, usr.ThumbnailId, usr.PhotoBase64 = photoID, thumbnailID, ""
+				err = m.UserProvider.Update(*usr)
+				if err != nil {
+					m.Photos.Remove(photoID, thumbnailID)
+					return err
+				}
+				migrated++
+				return nil
+			})
+			if err != nil {
+				if err.Type() == derrors.InvalidArgument || err.Type() == derrors.NotFound {
+					log.Warn().Str("email", email).Str("trace", err.DebugReport()).Msg("cannot migrate user photo, skipping")
+					continue
+				}
+				return migrated, err
+			}
+		}
+	}
+	return migrated, nil
+}
+
+// CreateAPIKey creates a key for a service account of an organization. It returns the key and the plain key, which
+// is not stored and cannot be retrieved later.
+func (m *Manager) CreateAPIKey(organizationID string, serviceAccountID string, permissions []entities.Permission, expiresAt int64) (*entities.APIKey, string, derrors.Error) {
+	if err := entities.ValidAddAPIKey(organizationID, serviceAccountID, permissions, expiresAt); err != nil {
+		return nil, "", err
+	}
+	if err := m.checkOrganization(organizationID); err != nil {
+		return nil, "", err
+	}
+	exists, err := m.ServiceAccountProvider.Exists(organizationID, serviceAccountID)
+	if err != nil {
+		return nil, "", err
 	}
 	if !exists {
-		return derrors.NewNotFoundError("organizationID").WithParams(removeRequest.OrganizationId)
+		return nil, "", derrors.NewNotFoundError("service account").WithParams(organizationID, serviceAccountID)
+	}
+	key, plainKey, err := entities.NewAPIKey(organizationID, serviceAccountID, permissions, expiresAt)
+	if err != nil {
+		return nil, "", err
 	}
+	err = m.ServiceAccountProvider.AddAPIKey(*key)
+	if err != nil {
+		return nil, "", err
+	}
+	return key, plainKey, nil
+}
 
-	exists, err = m.OrgProvider.UserExists(removeRequest.OrganizationId, removeRequest.Email)
+// ListAPIKeys retrieves the keys of a service account.
+func (m *Manager) ListAPIKeys(organizationID string, serviceAccountID string) ([]entities.APIKey, derrors.Error) {
+	exists, err := m.ServiceAccountProvider.Exists(organizationID, serviceAccountID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !exists {
-		return derrors.NewNotFoundError("userID").WithParams(removeRequest.OrganizationId, removeRequest.Email)
+		return nil, derrors.NewNotFoundError("service account").WithParams(organizationID, serviceAccountID)
 	}
+	return m.ServiceAccountProvider.ListAPIKeys(organizationID, serviceAccountID)
+}
 
-	err = m.OrgProvider.DeleteUser(removeRequest.OrganizationId, removeRequest.Email)
+// getAPIKey retrieves a key of an organization.
+func (m *Manager) getAPIKey(organizationID string, keyID string) (*entities.APIKey, derrors.Error) {
+	key, err := m.ServiceAccountProvider.GetAPIKey(keyID)
 	if err != nil {
+		return nil, err
+	}
+	if key.OrganizationId != organizationID {
+		return nil, derrors.NewNotFoundError("api key").WithParams(organizationID, keyID)
+	}
+	return key, nil
+}
+
+// RevokeAPIKey revokes a key. Revoked keys are kept so their use can be audited, and revoking them again fails.
+func (m *Manager) RevokeAPIKey(organizationID string, keyID string) (*entities.APIKey, derrors.Error) {
+	key, err := m.getAPIKey(organizationID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.Revoked() {
+		return nil, derrors.NewFailedPreconditionError("api key revoked").WithParams(organizationID, keyID)
+	}
+	key.RevokedAt = time.Now().Unix()
+	err = m.ServiceAccountProvider.RevokeAPIKey(*key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// RemoveAPIKey removes a key.
+func (m *Manager) RemoveAPIKey(organizationID string, keyID string) derrors.Error {
+	if _, err := m.getAPIKey(organizationID, keyID); err != nil {
 		return err
 	}
+	return m.ServiceAccountProvider.RemoveAPIKey(keyID)
+}
 
-	err = m.UserProvider.Remove(removeRequest.Email)
+// VerifyAPIKey checks a plain key presented to a gateway. Keys that are malformed, unknown, revoked, expired or that
+// belong to a disabled service account are not valid; the reason is included in the result, and errors are only
+// returned if the verification cannot be done. The last use of valid keys is recorded.
+func (m *Manager) VerifyAPIKey(plainKey string) (*entities.APIKeyVerification, derrors.Error) {
+	keyID, secret, err := entities.ParseAPIKey(plainKey)
 	if err != nil {
-		log.Error().Str("trace", conversions.ToDerror(err).DebugReport()).Msg("Error removing user. Rollback!")
-		rollbackError := m.OrgProvider.AddUser(removeRequest.OrganizationId, removeRequest.Email)
-		if rollbackError != nil {
-			log.Error().Str("trace", conversions.ToDerror(rollbackError).DebugReport()).Msg("error in Rollback")
+		return &entities.APIKeyVerification{Valid: false, Reason: "malformed key"}, nil
+	}
+	key, err := m.ServiceAccountProvider.GetAPIKey(keyID)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return &entities.APIKeyVerification{Valid: false, Reason: "unknown key"}, nil
 		}
+		return nil, err
 	}
-	return err
-
+	if !key.MatchesSecret(secret) {
+		return &entities.APIKeyVerification{Valid: false, Reason: "unknown key"}, nil
+	}
+	now := time.Now().Unix()
+	result := &entities.APIKeyVerification{
+		OrganizationId:   key.OrganizationId,
+		ServiceAccountId: key.ServiceAccountId,
+		KeyId:            key.KeyId,
+	}
+	if key.Revoked() {
+		result.Reason = "key revoked"
+		return result, nil
+	}
+	if key.Expired(now) {
+		result.Reason = "key expired"
+		return result, nil
+	}
+	account, err := m.ServiceAccountProvider.Get(key.OrganizationId, key.ServiceAccountId)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			result.Reason = "service account not found"
+			return result, nil
+		}
+		return nil, err
+	}
+	if !account.Enabled {
+		result.Reason = "service account disabled"
+		return result, nil
+	}
+	if now-key.LastUsed >= int64(lastUsedResolution.Seconds()) {
+		key.LastUsed = now
+		if err := m.ServiceAccountProvider.UpdateAPIKeyLastUsed(*key); err != nil {
+			log.Warn().Str("keyID", key.KeyId).Str("trace", err.DebugReport()).Msg("cannot record the last use of the key")
+		}
+	}
+	result.Valid = true
+	result.Permissions = key.Permissions
+	return result, nil
 }
@@ -0,0 +1,456 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	rProvider "github.com/nalej/system-model/internal/pkg/provider/role"
+	saProvider "github.com/nalej/system-model/internal/pkg/provider/service_account"
+	uProvider "github.com/nalej/system-model/internal/pkg/provider/user"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"time"
+)
+
+var _ = ginkgo.Describe("User memberships", func() {
+
+	var manager Manager
+	var organizationProvider orgProvider.Provider
+	var firstOrganization *entities.Organization
+	var secondOrganization *entities.Organization
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider = orgProvider.NewMockupOrganizationProvider()
+		manager = NewManager(organizationProvider, uProvider.NewMockupUserProvider(), rProvider.NewMockupRoleProvider(),
+			saProvider.NewMockupServiceAccountProvider(), testhelpers.NewQuotaManager(organizationProvider),
+			testhelpers.NewPhotoManager())
+		firstOrganization = testhelpers.AddOrganization(organizationProvider)
+		secondOrganization = testhelpers.AddOrganization(organizationProvider)
+	})
+
+	ginkgo.It("should add a user to several organizations", func() {
+		_, err := manager.AddUser(createAddUserRequest(firstOrganization.ID, "consultant@nalej.com"))
+		gomega.Expect(err).To(gomega.Succeed())
+		request := createAddUserRequest(secondOrganization.ID, "consultant@nalej.com")
+		request.Name = "other name"
+		added, err := manager.AddUser(request)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(added.OrganizationId).Should(gomega.Equal(secondOrganization.ID))
+		gomega.Expect(added.Name).Should(gomega.Equal("test user"))
+
+		_, err = manager.AddUser(request)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		memberships, err := manager.ListMemberships("consultant@nalej.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(memberships)).Should(gomega.Equal(2))
+
+		users, err := manager.GetUsers(&grpc_organization_go.OrganizationId{OrganizationId: secondOrganization.ID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(users)).Should(gomega.Equal(1))
+		gomega.Expect(users[0].OrganizationId).Should(gomega.Equal(secondOrganization.ID))
+	})
+
+	ginkgo.It("should keep the user while it is a member of an organization", func() {
+		for _, org := range []*entities.Organization{firstOrganization, secondOrganization} {
+			_, err := manager.AddUser(createAddUserRequest(org.ID, "consultant@nalej.com"))
+			gomega.Expect(err).To(gomega.Succeed())
+		}
+
+		err := manager.RemoveUser(&grpc_user_go.RemoveUserRequest{OrganizationId: firstOrganization.ID, Email: "consultant@nalej.com"})
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.GetUser(&grpc_user_go.UserId{OrganizationId: firstOrganization.ID, Email: "consultant@nalej.com"})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		_, err = manager.GetUser(&grpc_user_go.UserId{OrganizationId: secondOrganization.ID, Email: "consultant@nalej.com"})
+		gomega.Expect(err).To(gomega.Succeed())
+
+		err = manager.RemoveUser(&grpc_user_go.RemoveUserRequest{OrganizationId: secondOrganization.ID, Email: "consultant@nalej.com"})
+		gomega.Expect(err).To(gomega.Succeed())
+		exists, err := manager.UserProvider.Exists("consultant@nalej.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should migrate the users without membership", func() {
+		legacy := entities.User{OrganizationId: firstOrganization.ID, Email: "legacy@nalej.com", Name: "legacy", MemberSince: 42, RoleIds: []string{"legacyRole"}}
+		gomega.Expect(manager.UserProvider.Add(legacy)).To(gomega.Succeed())
+		gomega.Expect(organizationProvider.AddUser(firstOrganization.ID, legacy.Email)).To(gomega.Succeed())
+		_, err := manager.AddUser(createAddUserRequest(firstOrganization.ID, "new@nalej.com"))
+		gomega.Expect(err).To(gomega.Succeed())
+
+		migrated, err := manager.MigrateMemberships()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(migrated).Should(gomega.Equal(1))
+
+		retrieved, err := manager.GetUser(&grpc_user_go.UserId{OrganizationId: firstOrganization.ID, Email: legacy.Email})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.MemberSince).Should(gomega.Equal(legacy.MemberSince))
+		membership, err := manager.UserProvider.GetMembership(legacy.Email, firstOrganization.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(membership.RoleIds).Should(gomega.ConsistOf("legacyRole"))
+
+		migrated, err = manager.MigrateMemberships()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(migrated).Should(gomega.Equal(0))
+	})
+})
+
+var _ = ginkgo.Describe("User authorization", func() {
+
+	var manager Manager
+	var targetOrganization *entities.Organization
+	var userID *grpc_user_go.UserId
+
+	addRole := func(permissions ...entities.Permission) string {
+		role := entities.Role{
+			OrganizationId: targetOrganization.ID,
+			RoleId:         entities.GenerateUUID(),
+			Name:           "role",
+			Permissions:    permissions,
+		}
+		gomega.Expect(manager.RoleProvider.Add(role)).To(gomega.Succeed())
+		gomega.Expect(manager.OrgProvider.AddRole(role.OrganizationId, role.RoleId)).To(gomega.Succeed())
+		return role.RoleId
+	}
+
+	resource := func(kind entities.ResourceKind, labels map[string]string) *entities.Resource {
+		return &entities.Resource{OrganizationId: targetOrganization.ID, Kind: kind, Id: entities.GenerateUUID(), Labels: labels}
+	}
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider := orgProvider.NewMockupOrganizationProvider()
+		manager = NewManager(organizationProvider, uProvider.NewMockupUserProvider(), rProvider.NewMockupRoleProvider(),
+			saProvider.NewMockupServiceAccountProvider(), testhelpers.NewQuotaManager(organizationProvider),
+			testhelpers.NewPhotoManager())
+		targetOrganization = testhelpers.AddOrganization(organizationProvider)
+		added, err := manager.AddUser(createAddUserRequest(targetOrganization.ID, "user@nalej.com"))
+		gomega.Expect(err).To(gomega.Succeed())
+		userID = &grpc_user_go.UserId{OrganizationId: targetOrganization.ID, Email: added.Email}
+	})
+
+	ginkgo.It("should assign and unassign roles", func() {
+		roleID := addRole()
+		membership, err := manager.AssignRole(userID, roleID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(membership.RoleIds).To(gomega.ConsistOf(roleID))
+
+		_, err = manager.AssignRole(userID, roleID)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		membership, err = manager.UnassignRole(userID, roleID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(membership.RoleIds).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should not assign roles of other organizations", func() {
+		_, err := manager.AssignRole(userID, entities.GenerateUUID())
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should deny actions without roles", func() {
+		decision, err := manager.Authorize(userID, entities.ActionRead, resource(entities.ResourceCluster, nil))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(decision.Allowed).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should authorize the actions granted by the roles", func() {
+		roleID := addRole(entities.Permission{Resource: entities.ResourceCluster, Action: entities.ActionRead},
+			entities.Permission{Resource: entities.ResourceAppInstance, Action: entities.AnyAction})
+		_, err := manager.AssignRole(userID, roleID)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		decision, err := manager.Authorize(userID, entities.ActionRead, resource(entities.ResourceCluster, nil))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(decision.Allowed).To(gomega.BeTrue())
+		gomega.Expect(decision.RoleId).Should(gomega.Equal(roleID))
+
+		decision, err = manager.Authorize(userID, entities.ActionDelete, resource(entities.ResourceCluster, nil))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(decision.Allowed).To(gomega.BeFalse())
+
+		decision, err = manager.Authorize(userID, entities.ActionDelete, resource(entities.ResourceAppInstance, nil))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(decision.Allowed).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should scope the permissions to labels", func() {
+		roleID := addRole(entities.Permission{Resource: entities.AnyResource, Action: entities.ActionUpdate,
+			Labels: map[string]string{"env": "dev"}})
+		_, err := manager.AssignRole(userID, roleID)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		decision, err := manager.Authorize(userID, entities.ActionUpdate,
+			resource(entities.ResourceDeviceGroup, map[string]string{"env": "dev", "team": "a"}))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(decision.Allowed).To(gomega.BeTrue())
+
+		decision, err = manager.Authorize(userID, entities.ActionUpdate,
+			resource(entities.ResourceDeviceGroup, map[string]string{"env": "prod"}))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(decision.Allowed).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should deny actions on resources of other organizations", func() {
+		roleID := addRole(entities.Permission{Resource: entities.AnyResource, Action: entities.AnyAction})
+		_, err := manager.AssignRole(userID, roleID)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		target := resource(entities.ResourceCluster, nil)
+		target.OrganizationId = entities.GenerateUUID()
+		decision, err := manager.Authorize(userID, entities.ActionRead, target)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(decision.Allowed).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should deny actions while the membership is suspended", func() {
+		roleID := addRole(entities.Permission{Resource: entities.AnyResource, Action: entities.AnyAction})
+		_, err := manager.AssignRole(userID, roleID)
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.SetMembershipState(userID, entities.MembershipState_Suspended)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		decision, err := manager.Authorize(userID, entities.ActionRead, resource(entities.ResourceCluster, nil))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(decision.Allowed).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should reject unsupported actions", func() {
+		_, err := manager.Authorize(userID, entities.Action("execute"), resource(entities.ResourceCluster, nil))
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
+
+var _ = ginkgo.Describe("Service account API keys", func() {
+
+	var manager Manager
+	var serviceAccounts saProvider.Provider
+	var account *entities.ServiceAccount
+	permissions := []entities.Permission{{Resource: entities.ResourceAppInstance, Action: entities.ActionRead}}
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider := orgProvider.NewMockupOrganizationProvider()
+		serviceAccounts = saProvider.NewMockupServiceAccountProvider()
+		manager = NewManager(organizationProvider, uProvider.NewMockupUserProvider(), rProvider.NewMockupRoleProvider(),
+			serviceAccounts, testhelpers.NewQuotaManager(organizationProvider),
+			testhelpers.NewPhotoManager())
+		targetOrganization := testhelpers.AddOrganization(organizationProvider)
+		account = entities.NewServiceAccount(targetOrganization.ID, "ci", "CI pipeline")
+		gomega.Expect(serviceAccounts.Add(*account)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should create a key and verify it", func() {
+		key, plainKey, err := manager.CreateAPIKey(account.OrganizationId, account.ServiceAccountId, permissions, 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(key.Hash).ShouldNot(gomega.ContainSubstring(plainKey))
+
+		verification, err := manager.VerifyAPIKey(plainKey)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(verification.Valid).To(gomega.BeTrue())
+		gomega.Expect(verification.OrganizationId).Should(gomega.Equal(account.OrganizationId))
+		gomega.Expect(verification.Permissions).Should(gomega.Equal(permissions))
+
+		stored, err := serviceAccounts.GetAPIKey(key.KeyId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(stored.LastUsed).ShouldNot(gomega.BeZero())
+
+		keys, err := manager.ListAPIKeys(account.OrganizationId, account.ServiceAccountId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(keys)).Should(gomega.Equal(1))
+	})
+
+	ginkgo.It("should not create keys for missing service accounts or without permissions", func() {
+		_, _, err := manager.CreateAPIKey(account.OrganizationId, entities.GenerateUUID(), permissions, 0)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		_, _, err = manager.CreateAPIKey(account.OrganizationId, account.ServiceAccountId, nil, 0)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		_, _, err = manager.CreateAPIKey(account.OrganizationId, account.ServiceAccountId, permissions, time.Now().Add(-time.Hour).Unix())
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should reject malformed and unknown keys", func() {
+		key, plainKey, err := manager.CreateAPIKey(account.OrganizationId, account.ServiceAccountId, permissions, 0)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		for _, candidate := range []string{"", "not a key", plainKey + "0", fmt.Sprintf("%s_%s_%s", entities.APIKeyPrefix, key.KeyId, "secret")} {
+			verification, err := manager.VerifyAPIKey(candidate)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(verification.Valid).To(gomega.BeFalse())
+		}
+	})
+
+	ginkgo.It("should reject revoked and expired keys", func() {
+		key, plainKey, err := manager.CreateAPIKey(account.OrganizationId, account.ServiceAccountId, permissions, 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		revoked, err := manager.RevokeAPIKey(account.OrganizationId, key.KeyId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(revoked.Revoked()).To(gomega.BeTrue())
+		_, err = manager.RevokeAPIKey(account.OrganizationId, key.KeyId)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Type()).To(gomega.Equal(derrors.FailedPrecondition))
+		verification, err := manager.VerifyAPIKey(plainKey)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(verification.Valid).To(gomega.BeFalse())
+
+		key, plainKey, err = manager.CreateAPIKey(account.OrganizationId, account.ServiceAccountId, permissions, time.Now().Add(time.Hour).Unix())
+		gomega.Expect(err).To(gomega.Succeed())
+		key.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+		gomega.Expect(serviceAccounts.UpdateAPIKey(*key)).To(gomega.Succeed())
+		verification, err = manager.VerifyAPIKey(plainKey)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(verification.Valid).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should reject keys of disabled service accounts", func() {
+		_, plainKey, err := manager.CreateAPIKey(account.OrganizationId, account.ServiceAccountId, permissions, 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		account.Enabled = false
+		gomega.Expect(serviceAccounts.Update(*account)).To(gomega.Succeed())
+
+		verification, err := manager.VerifyAPIKey(plainKey)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(verification.Valid).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should only manage keys of the organization", func() {
+		key, _, err := manager.CreateAPIKey(account.OrganizationId, account.ServiceAccountId, permissions, 0)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		_, err = manager.RevokeAPIKey(entities.GenerateUUID(), key.KeyId)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(manager.RemoveAPIKey(entities.GenerateUUID(), key.KeyId)).NotTo(gomega.Succeed())
+		gomega.Expect(manager.RemoveAPIKey(account.OrganizationId, key.KeyId)).To(gomega.Succeed())
+	})
+})
+
+var _ = ginkgo.Describe("User photos", func() {
+
+	var manager Manager
+	var organizationProvider orgProvider.Provider
+	var targetOrganization *entities.Organization
+	var userID *grpc_user_go.UserId
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider = orgProvider.NewMockupOrganizationProvider()
+		manager = NewManager(organizationProvider, uProvider.NewMockupUserProvider(), rProvider.NewMockupRoleProvider(),
+			saProvider.NewMockupServiceAccountProvider(), testhelpers.NewQuotaManager(organizationProvider),
+			testhelpers.NewPhotoManager())
+		targetOrganization = testhelpers.AddOrganization(organizationProvider)
+		userID = &grpc_user_go.UserId{OrganizationId: targetOrganization.ID, Email: "photo@nalej.com"}
+	})
+
+	ginkgo.It("should keep the photo in the blob store and list the thumbnail", func() {
+		photo := testhelpers.CreatePhotoBase64()
+		request := createAddUserRequest(targetOrganization.ID, userID.Email)
+		request.PhotoBase64 = photo
+		added, err := manager.AddUser(request)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(added.PhotoBase64).Should(gomega.Equal(photo))
+
+		stored, err := manager.UserProvider.Get(userID.Email)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(stored.PhotoBase64).Should(gomega.BeEmpty())
+		gomega.Expect(stored.PhotoId).ShouldNot(gomega.BeEmpty())
+		gomega.Expect(stored.ThumbnailId).ShouldNot(gomega.BeEmpty())
+
+		retrieved, err := manager.GetUser(userID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.PhotoBase64).Should(gomega.Equal(photo))
+
+		users, err := manager.GetUsers(&grpc_organization_go.OrganizationId{OrganizationId: targetOrganization.ID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(users)).Should(gomega.Equal(1))
+		gomega.Expect(users[0].PhotoBase64).ShouldNot(gomega.BeEmpty())
+		gomega.Expect(len(users[0].PhotoBase64)).Should(gomega.BeNumerically("<", len(photo)))
+
+		err = manager.RemoveUser(&grpc_user_go.RemoveUserRequest{OrganizationId: targetOrganization.ID, Email: userID.Email})
+		gomega.Expect(err).To(gomega.Succeed())
+		for _, blobID := range []string{stored.PhotoId, stored.ThumbnailId} {
+			exists, err := manager.Photos.BlobProvider.Exists(blobID)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(exists).Should(gomega.BeFalse())
+		}
+	})
+
+	ginkgo.It("should reject invalid photos", func() {
+		request := createAddUserRequest(targetOrganization.ID, userID.Email)
+		request.PhotoBase64 = "bm90IGFuIGltYWdl"
+		_, err := manager.AddUser(request)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		exists, err := manager.UserProvider.Exists(userID.Email)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).Should(gomega.BeFalse())
+	})
+
+	ginkgo.It("should replace the photo of a user", func() {
+		request := createAddUserRequest(targetOrganization.ID, userID.Email)
+		request.PhotoBase64 = testhelpers.CreatePhotoBase64()
+		_, err := manager.AddUser(request)
+		gomega.Expect(err).To(gomega.Succeed())
+		previous, err := manager.UserProvider.Get(userID.Email)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		err = manager.UpdateUser(&grpc_user_go.UpdateUserRequest{OrganizationId: targetOrganization.ID, Email: userID.Email,
+			UpdatePhotoBase64: true, PhotoBase64: "bm90IGFuIGltYWdl"})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		err = manager.UpdateUser(&grpc_user_go.UpdateUserRequest{OrganizationId: targetOrganization.ID, Email: userID.Email,
+			UpdatePhotoBase64: true, PhotoBase64: ""})
+		gomega.Expect(err).To(gomega.Succeed())
+
+		retrieved, err := manager.GetUser(userID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.PhotoBase64).Should(gomega.BeEmpty())
+		gomega.Expect(retrieved.PhotoId).Should(gomega.BeEmpty())
+		exists, err := manager.Photos.BlobProvider.Exists(previous.PhotoId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).Should(gomega.BeFalse())
+	})
+
+	ginkgo.It("should migrate the photos kept in the users", func() {
+		photo := testhelpers.CreatePhotoBase64()
+		legacy := entities.User{OrganizationId: targetOrganization.ID, Email: userID.Email, Name: "legacy", PhotoBase64: photo}
+		gomega.Expect(manager.UserProvider.Add(legacy)).To(gomega.Succeed())
+		gomega.Expect(organizationProvider.AddUser(targetOrganization.ID, legacy.Email)).To(gomega.Succeed())
+		invalid := entities.User{OrganizationId: targetOrganization.ID, Email: "invalid@nalej.com", Name: "invalid", PhotoBase64: "Photo"}
+		gomega.Expect(manager.UserProvider.Add(invalid)).To(gomega.Succeed())
+		gomega.Expect(organizationProvider.AddUser(targetOrganization.ID, invalid.Email)).To(gomega.Succeed())
+
+		migrated, err := manager.MigratePhotos()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(migrated).Should(gomega.Equal(1))
+
+		stored, err := manager.UserProvider.Get(legacy.Email)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(stored.PhotoBase64).Should(gomega.BeEmpty())
+		gomega.Expect(stored.PhotoId).ShouldNot(gomega.BeEmpty())
+		photoBase64, err := manager.Photos.Load(stored.PhotoId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(photoBase64).Should(gomega.Equal(photo))
+		kept, err := manager.UserProvider.Get(invalid.Email)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(kept.PhotoBase64).Should(gomega.Equal("Photo"))
+
+		migrated, err = manager.MigratePhotos()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(migrated).Should(gomega.Equal(0))
+	})
+})
@@ -0,0 +1,309 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zt_garbage_collector
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	"github.com/nalej/system-model/internal/pkg/provider/cluster"
+	"github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/application"
+	"github.com/rs/zerolog/log"
+	"sort"
+	"time"
+)
+
+// Manager structure with the required providers to collect the zt network members and proxies whose service
+// instances or clusters no longer exist.
+type Manager struct {
+	OrgProvider     organization.Provider
+	AppProvider     appProvider.Provider
+	ClusterProvider cluster.Provider
+	// Applications is used to remove the orphans so their IP addresses are released.
+	Applications application.Manager
+	// GracePeriod an orphan is kept before being removed.
+	GracePeriod time.Duration
+	// CollectLegacy removes the orphans without creation time, added before it was recorded. They are kept otherwise.
+	CollectLegacy bool
+}
+
+// NewManager creates a Manager using a set of providers.
+func NewManager(orgProvider organization.Provider, applicationProvider appProvider.Provider, clusterProvider cluster.Provider,
+	applications application.Manager, gracePeriod time.Duration) Manager {
+	return Manager{
+		OrgProvider:     orgProvider,
+		AppProvider:     applicationProvider,
+		ClusterProvider: clusterProvider,
+		Applications:    applications,
+		GracePeriod:     gracePeriod,
+	}
+}
+
+// collection with the state of a collection of an organization.
+type collection struct {
+	report    *entities.ZtGarbageReport
+	instances map[string]*entities.AppInstance
+	clusters  map[string]bool
+}
+
+// instance retrieves an application instance, or nil if it does not exist.
+func (m *Manager) instance(c *collection, appInstanceID string) (*entities.AppInstance, derrors.Error) {
+	if instance, found := c.instances[appInstanceID]; found {
+		return instance, nil
+	}
+	instance, err := m.AppProvider.GetInstance(appInstanceID)
+	if err != nil {
+		if err.Type() != derrors.NotFound {
+			return nil, err
+		}
+		instance = nil
+	}
+	c.instances[appInstanceID] = instance
+	return instance, nil
+}
+
+// clusterExists checks if a cluster exists.
+func (m *Manager) clusterExists(c *collection, clusterID string) (bool, derrors.Error) {
+	if exists, found := c.clusters[clusterID]; found {
+		return exists, nil
+	}
+	exists, err := m.ClusterProvider.Exists(clusterID)
+	if err != nil {
+		return false, err
+	}
+	c.clusters[clusterID] = exists
+	return exists, nil
+}
+
+// orphanReason checks if a service instance still exists, returning why it is an orphan otherwise. An empty reason
+// is returned if the service instance exists.
+func (m *Manager) orphanReason(c *collection, appInstanceID string, serviceGroupInstanceID string, serviceInstanceID string) (entities.ZtOrphanReason, derrors.Error) {
+	instance, err := m.instance(c, appInstanceID)
+	if err != nil {
+		return "", err
+	}
+	if instance == nil {
+		return entities.ZtOrphanInstanceNotFound, nil
+	}
+	for _, group := range instance.Groups {
+		if group.ServiceGroupInstanceId != serviceGroupInstanceID {
+			continue
+		}
+		for _, service := range group.ServiceInstances {
+			if service.ServiceInstanceId == serviceInstanceID {
+				return "", nil
+			}
+		}
+		return entities.ZtOrphanServiceInstanceNotFound, nil
+	}
+	return entities.ZtOrphanServiceGroupInstanceNotFound, nil
+}
+
+// Collect removes the zt network members and proxies of an organization whose service instances or clusters no
+// longer exist and that are older than the grace period. If dryRun is set, the orphans are only reported.
+func (m *Manager) Collect(organizationID *grpc_organization_go.OrganizationId, dryRun bool) (*entities.ZtGarbageReport, derrors.Error) {
+	exists, err := m.OrgProvider.Exists(organizationID.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, derrors.NewNotFoundError("organizationID").WithParams(organizationID.OrganizationId)
+	}
+
+	c := &collection{
+		report:    entities.NewZtGarbageReport(organizationID.OrganizationId, time.Now().Unix(), int64(m.GracePeriod.Seconds()), dryRun, m.CollectLegacy),
+		instances: make(map[string]*entities.AppInstance, 0),
+		clusters:  make(map[string]bool, 0),
+	}
+	if err = m.collectMembers(c); err != nil {
+		return nil, err
+	}
+	if err = m.collectProxies(c); err != nil {
+		return nil, err
+	}
+	return c.report, nil
+}
+
+// collectMembers removes the orphan members. The members of a service instance are removed together, once all of
+// them are older than the grace period.
+func (m *Manager) collectMembers(c *collection) derrors.Error {
+	records, err := m.AppProvider.ListOrganizationZtNetworkMembers(c.report.OrganizationId)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		reason, err := m.orphanReason(c, record.AppInstanceId, record.ServiceGroupInstanceId, record.ServiceApplicationInstanceId)
+		if err != nil {
+			return err
+		}
+		if reason == "" {
+			continue
+		}
+		expired := true
+		oldest := int64(-1)
+		for _, member := range record.Members {
+			expired = expired && c.report.Expired(member.CreatedAt)
+			if oldest == -1 || member.CreatedAt < oldest {
+				oldest = member.CreatedAt
+			}
+		}
+		if !expired {
+			c.report.AddKept(oldest, len(record.Members))
+			continue
+		}
+		if !c.report.DryRun {
+			err = m.Applications.RemoveAppZtNetworkMember(record.OrganizationId, record.AppInstanceId,
+				record.ServiceGroupInstanceId, record.ServiceApplicationInstanceId, record.ZtNetworkId)
+			if err != nil {
+				return err
+			}
+		}
+		memberIDs := make([]string, 0, len(record.Members))
+		for memberID := range record.Members {
+			memberIDs = append(memberIDs, memberID)
+		}
+		sort.Strings(memberIDs)
+		for _, memberID := range memberIDs {
+			c.report.Members = append(c.report.Members, entities.CollectedZtMember{
+				AppInstanceId:                record.AppInstanceId,
+				ServiceGroupInstanceId:       record.ServiceGroupInstanceId,
+				ServiceApplicationInstanceId: record.ServiceApplicationInstanceId,
+				ZtNetworkId:                  record.ZtNetworkId,
+				Member:                       record.Members[memberID],
+				Reason:                       reason,
+			})
+		}
+	}
+	return nil
+}
+
+// collectProxies removes the orphan proxies, including those deployed in clusters that no longer exist.
+func (m *Manager) collectProxies(c *collection) derrors.Error {
+	networks, err := m.AppProvider.ListAppZtNetworks(c.report.OrganizationId)
+	if err != nil {
+		return err
+	}
+	for _, network := range networks {
+		for _, proxy := range sortedProxies(network) {
+			reason, err := m.orphanReason(c, proxy.AppInstanceId, proxy.ServiceGroupInstanceId, proxy.ServiceInstanceId)
+			if err != nil {
+				return err
+			}
+			if reason == "" {
+				exists, err := m.clusterExists(c, proxy.ClusterId)
+				if err != nil {
+					return err
+				}
+				if exists {
+					continue
+				}
+				reason = entities.ZtOrphanClusterNotFound
+			}
+			if !c.report.Expired(proxy.CreatedAt) {
+				c.report.AddKept(proxy.CreatedAt, 1)
+				continue
+			}
+			if !c.report.DryRun {
+				err = m.Applications.RemoveZtNetworkProxy(network.OrganizationId, network.AppInstanceId, proxy.FQDN,
+					proxy.ClusterId, proxy.ServiceGroupInstanceId, proxy.ServiceInstanceId)
+				if err != nil {
+					return err
+				}
+			}
+			c.report.Proxies = append(c.report.Proxies, entities.CollectedZtProxy{Proxy: proxy, Reason: reason})
+		}
+	}
+	return nil
+}
+
+// sortedProxies returns the proxies of a zt network in a deterministic order.
+func sortedProxies(network entities.AppZtNetwork) []entities.ServiceProxy {
+	proxies := make([]entities.ServiceProxy, 0)
+	for fqdn, perCluster := range network.AvailableProxies {
+		for clusterID, list := range perCluster {
+			for _, proxy := range list {
+				if proxy.FQDN == "" {
+					proxy.FQDN = fqdn
+				}
+				if proxy.ClusterId == "" {
+					proxy.ClusterId = clusterID
+				}
+				proxies = append(proxies, proxy)
+			}
+		}
+	}
+	sort.Slice(proxies, func(i, j int) bool {
+		if proxies[i].FQDN != proxies[j].FQDN {
+			return proxies[i].FQDN < proxies[j].FQDN
+		}
+		if proxies[i].ClusterId != proxies[j].ClusterId {
+			return proxies[i].ClusterId < proxies[j].ClusterId
+		}
+		return proxies[i].ServiceInstanceId < proxies[j].ServiceInstanceId
+	})
+	return proxies
+}
+
+// CollectAll collects the orphans of all the organizations.
+func (m *Manager) CollectAll(dryRun bool) ([]entities.ZtGarbageReport, derrors.Error) {
+	organizations, err := m.OrgProvider.List()
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]entities.ZtGarbageReport, 0, len(organizations))
+	for _, org := range organizations {
+		report, err := m.Collect(&grpc_organization_go.OrganizationId{OrganizationId: org.ID}, dryRun)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
+
+// Run collects the orphans of all the organizations periodically until the context is done.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	log.Info().Str("interval", interval.String()).Str("gracePeriod", m.GracePeriod.String()).Msg("launching zt garbage collector")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("zt garbage collector stopped")
+			return
+		case <-ticker.C:
+		}
+		reports, err := m.CollectAll(false)
+		if err != nil {
+			log.Error().Str("trace", err.DebugReport()).Msg("zt garbage collection failed")
+			continue
+		}
+		for _, report := range reports {
+			if len(report.Members) > 0 || len(report.Proxies) > 0 {
+				log.Info().Str("organizationID", report.OrganizationId).Int("members", len(report.Members)).
+					Int("proxies", len(report.Proxies)).Int("pending", report.Pending).Msg("zt orphans removed")
+			}
+			if report.Legacy > 0 {
+				log.Warn().Str("organizationID", report.OrganizationId).Int("legacy", report.Legacy).
+					Msg("zt orphans without creation time kept, they are removed by the gc command with --legacy")
+			}
+		}
+	}
+}
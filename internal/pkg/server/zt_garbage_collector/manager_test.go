@@ -0,0 +1,236 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zt_garbage_collector
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nalej/grpc-application-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/system-model/internal/pkg/cidr"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	appProvider "github.com/nalej/system-model/internal/pkg/provider/application"
+	clusterProvider "github.com/nalej/system-model/internal/pkg/provider/cluster"
+	devProvider "github.com/nalej/system-model/internal/pkg/provider/device"
+	ipamProvider "github.com/nalej/system-model/internal/pkg/provider/ipam"
+	orgProvider "github.com/nalej/system-model/internal/pkg/provider/organization"
+	"github.com/nalej/system-model/internal/pkg/server/application"
+	"github.com/nalej/system-model/internal/pkg/server/ipam"
+	"github.com/nalej/system-model/internal/pkg/server/testhelpers"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"time"
+)
+
+var _ = ginkgo.Describe("ZT garbage collector", func() {
+
+	var applicationProvider appProvider.Provider
+	var clusters clusterProvider.Provider
+	var applications application.Manager
+	var manager Manager
+	var targetOrganization *entities.Organization
+	var organizationID *grpc_organization_go.OrganizationId
+	var targetInstance entities.AppInstance
+	var targetCluster entities.Cluster
+
+	newManager := func(gracePeriod time.Duration) Manager {
+		return NewManager(applications.OrgProvider, applicationProvider, clusters, applications, gracePeriod)
+	}
+
+	addMember := func(appInstanceID string, serviceGroupInstanceID string, serviceInstanceID string) {
+		_, err := applications.AddAppZtNetworkMember(&grpc_application_go.AddAuthorizedZtNetworkMemberRequest{
+			OrganizationId:               targetOrganization.ID,
+			AppInstanceId:                appInstanceID,
+			ServiceGroupInstanceId:       serviceGroupInstanceID,
+			ServiceApplicationInstanceId: serviceInstanceID,
+			NetworkId:                    "ztnetwork",
+			MemberId:                     uuid.New().String(),
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	}
+
+	addProxy := func(serviceInstanceID string, clusterID string) {
+		err := applications.AddZtNetworkProxy(&entities.ServiceProxy{
+			OrganizationId:         targetOrganization.ID,
+			AppInstanceId:          targetInstance.AppInstanceId,
+			ServiceGroupInstanceId: targetInstance.Groups[0].ServiceGroupInstanceId,
+			ServiceInstanceId:      serviceInstanceID,
+			ClusterId:              clusterID,
+			FQDN:                   "service.nalej",
+			IP:                     "10.0.0.1",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	}
+
+	ginkgo.BeforeEach(func() {
+		organizationProvider := orgProvider.NewMockupOrganizationProvider()
+		applicationProvider = appProvider.NewMockupApplicationProvider()
+		clusters = clusterProvider.NewMockupClusterProvider()
+		pool, err := cidr.ParsePool(cidr.DefaultPool, cidr.DefaultBlockPrefixLength)
+		gomega.Expect(err).To(gomega.Succeed())
+		applications = application.NewManager(organizationProvider, applicationProvider, devProvider.NewMockupDeviceProvider(),
+			"nalej.cluster.local", ipam.NewManager(organizationProvider, ipamProvider.NewMockupIPAMProvider(), pool),
+			testhelpers.NewQuotaManager(organizationProvider))
+		manager = newManager(0)
+
+		targetOrganization = testhelpers.AddOrganization(organizationProvider)
+		organizationID = &grpc_organization_go.OrganizationId{OrganizationId: targetOrganization.ID}
+
+		targetInstance = entities.AppInstance{
+			OrganizationId: targetOrganization.ID,
+			AppInstanceId:  uuid.New().String(),
+			Groups: []entities.ServiceGroupInstance{{
+				OrganizationId:         targetOrganization.ID,
+				ServiceGroupInstanceId: uuid.New().String(),
+				ServiceInstances:       []entities.ServiceInstance{{ServiceInstanceId: uuid.New().String()}},
+			}},
+		}
+		gomega.Expect(applicationProvider.AddInstance(targetInstance)).To(gomega.Succeed())
+		gomega.Expect(applicationProvider.AddAppZtNetwork(entities.AppZtNetwork{
+			OrganizationId: targetOrganization.ID,
+			AppInstanceId:  targetInstance.AppInstanceId,
+			ZtNetworkId:    "ztnetwork",
+		})).To(gomega.Succeed())
+
+		targetCluster = entities.Cluster{OrganizationId: targetOrganization.ID, ClusterId: uuid.New().String(), Name: "cluster"}
+		gomega.Expect(clusters.Add(targetCluster)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should keep the members and proxies of existing services", func() {
+		group := targetInstance.Groups[0]
+		addMember(targetInstance.AppInstanceId, group.ServiceGroupInstanceId, group.ServiceInstances[0].ServiceInstanceId)
+		addProxy(group.ServiceInstances[0].ServiceInstanceId, targetCluster.ClusterId)
+
+		report, err := manager.Collect(organizationID, false)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Members).To(gomega.BeEmpty())
+		gomega.Expect(report.Proxies).To(gomega.BeEmpty())
+		gomega.Expect(report.Pending).Should(gomega.Equal(0))
+	})
+
+	ginkgo.It("should remove the members of missing instances and services", func() {
+		group := targetInstance.Groups[0]
+		addMember(uuid.New().String(), uuid.New().String(), uuid.New().String())
+		addMember(targetInstance.AppInstanceId, uuid.New().String(), uuid.New().String())
+		addMember(targetInstance.AppInstanceId, group.ServiceGroupInstanceId, uuid.New().String())
+
+		report, err := manager.Collect(organizationID, false)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(report.Members)).Should(gomega.Equal(3))
+		reasons := make([]entities.ZtOrphanReason, 0)
+		for _, member := range report.Members {
+			reasons = append(reasons, member.Reason)
+		}
+		gomega.Expect(reasons).To(gomega.ConsistOf(entities.ZtOrphanInstanceNotFound,
+			entities.ZtOrphanServiceGroupInstanceNotFound, entities.ZtOrphanServiceInstanceNotFound))
+
+		remaining, err := applicationProvider.ListOrganizationZtNetworkMembers(targetOrganization.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(remaining).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should remove the proxies of missing services and clusters", func() {
+		group := targetInstance.Groups[0]
+		addProxy(uuid.New().String(), targetCluster.ClusterId)
+		addProxy(group.ServiceInstances[0].ServiceInstanceId, uuid.New().String())
+
+		report, err := manager.Collect(organizationID, false)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(report.Proxies)).Should(gomega.Equal(2))
+		reasons := []entities.ZtOrphanReason{report.Proxies[0].Reason, report.Proxies[1].Reason}
+		gomega.Expect(reasons).To(gomega.ConsistOf(entities.ZtOrphanServiceInstanceNotFound, entities.ZtOrphanClusterNotFound))
+
+		networks, err := applicationProvider.ListAppZtNetworks(targetOrganization.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(networks)).Should(gomega.Equal(1))
+		gomega.Expect(sortedProxies(networks[0])).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should keep the orphans within the grace period", func() {
+		manager = newManager(time.Hour)
+		addMember(uuid.New().String(), uuid.New().String(), uuid.New().String())
+		addProxy(uuid.New().String(), targetCluster.ClusterId)
+
+		report, err := manager.Collect(organizationID, false)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Members).To(gomega.BeEmpty())
+		gomega.Expect(report.Proxies).To(gomega.BeEmpty())
+		gomega.Expect(report.Pending).Should(gomega.Equal(2))
+	})
+
+	ginkgo.It("should keep the orphans without creation time unless the legacy orphans are collected", func() {
+		gomega.Expect(applicationProvider.AddZtNetworkProxy(entities.ServiceProxy{
+			OrganizationId:         targetOrganization.ID,
+			AppInstanceId:          targetInstance.AppInstanceId,
+			ServiceGroupInstanceId: targetInstance.Groups[0].ServiceGroupInstanceId,
+			ServiceInstanceId:      uuid.New().String(),
+			ClusterId:              targetCluster.ClusterId,
+			FQDN:                   "service.nalej",
+			IP:                     "10.0.0.1",
+		})).To(gomega.Succeed())
+
+		report, err := manager.Collect(organizationID, false)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Proxies).To(gomega.BeEmpty())
+		gomega.Expect(report.Pending).Should(gomega.Equal(0))
+		gomega.Expect(report.Legacy).Should(gomega.Equal(1))
+
+		manager.CollectLegacy = true
+		report, err = manager.Collect(organizationID, false)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(report.Proxies)).Should(gomega.Equal(1))
+		gomega.Expect(report.Legacy).Should(gomega.Equal(0))
+	})
+
+	ginkgo.It("should stop running when the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan bool)
+		go func() {
+			manager.Run(ctx, time.Hour)
+			close(done)
+		}()
+		cancel()
+		gomega.Eventually(done).Should(gomega.BeClosed())
+	})
+
+	ginkgo.It("should only report the orphans on a dry run", func() {
+		addMember(uuid.New().String(), uuid.New().String(), uuid.New().String())
+
+		report, err := manager.Collect(organizationID, true)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.DryRun).To(gomega.BeTrue())
+		gomega.Expect(len(report.Members)).Should(gomega.Equal(1))
+
+		remaining, err := applicationProvider.ListOrganizationZtNetworkMembers(targetOrganization.ID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(remaining)).Should(gomega.Equal(1))
+	})
+
+	ginkgo.It("should collect all the organizations", func() {
+		addMember(uuid.New().String(), uuid.New().String(), uuid.New().String())
+
+		reports, err := manager.CollectAll(false)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(reports)).Should(gomega.Equal(1))
+		gomega.Expect(len(reports[0].Members)).Should(gomega.Equal(1))
+	})
+
+	ginkgo.It("should fail on a missing organization", func() {
+		_, err := manager.Collect(&grpc_organization_go.OrganizationId{OrganizationId: uuid.New().String()}, false)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
@@ -0,0 +1,288 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package topology
+
+import (
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"sort"
+)
+
+// IssueType with the problems detected in the connection graph of an organization.
+type IssueType string
+
+const (
+	// MissingRequiredOutbound is reported for required outbound interfaces without connections.
+	MissingRequiredOutbound IssueType = "missing_required_outbound"
+	// DanglingOutbound is reported for connections from an outbound interface that the source instance does not have.
+	DanglingOutbound IssueType = "dangling_outbound"
+	// DanglingInbound is reported for connections to an inbound interface that the target instance does not have.
+	DanglingInbound IssueType = "dangling_inbound"
+	// UnknownInstance is reported for connections whose source or target instance does not exist.
+	UnknownInstance IssueType = "unknown_instance"
+	// Cycle is reported for each set of instances that depend on each other through their connections.
+	Cycle IssueType = "cycle"
+)
+
+// Interface with a network interface of an application instance.
+type Interface struct {
+	// Name of the interface.
+	Name string `json:"name"`
+	// Required flag of outbound interfaces.
+	Required bool `json:"required,omitempty"`
+}
+
+// Node with an application instance of the graph.
+type Node struct {
+	// AppInstanceId with the application instance identifier.
+	AppInstanceId string `json:"app_instance_id"`
+	// Name of the application instance.
+	Name string `json:"name"`
+	// Inbounds with the inbound network interfaces of the instance.
+	Inbounds []Interface `json:"inbounds,omitempty"`
+	// Outbounds with the outbound network interfaces of the instance.
+	Outbounds []Interface `json:"outbounds,omitempty"`
+}
+
+// Edge with a connection between the outbound interface of an instance and the inbound interface of another one.
+type Edge struct {
+	// ConnectionId with the connection identifier.
+	ConnectionId string `json:"connection_id"`
+	// SourceInstanceId with the instance identifier of the connection source.
+	SourceInstanceId string `json:"source_instance_id"`
+	// OutboundName with the name of the outbound network interface.
+	OutboundName string `json:"outbound_name"`
+	// TargetInstanceId with the instance identifier of the connection target.
+	TargetInstanceId string `json:"target_instance_id"`
+	// InboundName with the name of the inbound network interface.
+	InboundName string `json:"inbound_name"`
+	// Status with the status of the connection.
+	Status entities.ConnectionStatus `json:"status"`
+}
+
+// Issue with a problem detected in the graph.
+type Issue struct {
+	// Type of the issue.
+	Type IssueType `json:"type"`
+	// AppInstanceId with the instance affected by the issue.
+	AppInstanceId string `json:"app_instance_id,omitempty"`
+	// InterfaceName with the network interface affected by the issue.
+	InterfaceName string `json:"interface_name,omitempty"`
+	// ConnectionId with the connection affected by the issue.
+	ConnectionId string `json:"connection_id,omitempty"`
+	// Instances with the identifiers of the instances of a cycle.
+	Instances []string `json:"instances,omitempty"`
+}
+
+// Graph with the connections between the application instances of an organization.
+type Graph struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string `json:"organization_id"`
+	// Nodes sorted by name and identifier.
+	Nodes []Node `json:"nodes"`
+	// Edges sorted by source, outbound, target and inbound.
+	Edges []Edge `json:"edges"`
+	// Issues detected in the graph.
+	Issues []Issue `json:"issues"`
+}
+
+// NewGraph builds the connection graph of the instances of an organization and validates it.
+func NewGraph(organizationID string, instances []entities.AppInstance, connections []entities.ConnectionInstance) *Graph {
+	graph := &Graph{
+		OrganizationId: organizationID,
+		Nodes:          make([]Node, 0, len(instances)),
+		Edges:          make([]Edge, 0, len(connections)),
+		Issues:         make([]Issue, 0),
+	}
+	for _, instance := range instances {
+		node := Node{AppInstanceId: instance.AppInstanceId, Name: instance.Name}
+		for _, inbound := range instance.InboundNetInterfaces {
+			node.Inbounds = append(node.Inbounds, Interface{Name: inbound.Name})
+		}
+		for _, outbound := range instance.OutboundNetInterfaces {
+			node.Outbounds = append(node.Outbounds, Interface{Name: outbound.Name, Required: outbound.Required})
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		if graph.Nodes[i].Name != graph.Nodes[j].Name {
+			return graph.Nodes[i].Name < graph.Nodes[j].Name
+		}
+		return graph.Nodes[i].AppInstanceId < graph.Nodes[j].AppInstanceId
+	})
+
+	for _, connection := range connections {
+		graph.Edges = append(graph.Edges, Edge{
+			ConnectionId:     connection.ConnectionId,
+			SourceInstanceId: connection.SourceInstanceId,
+			OutboundName:     connection.OutboundName,
+			TargetInstanceId: connection.TargetInstanceId,
+			InboundName:      connection.InboundName,
+			Status:           connection.Status,
+		})
+	}
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.SourceInstanceId != b.SourceInstanceId {
+			return a.SourceInstanceId < b.SourceInstanceId
+		}
+		if a.OutboundName != b.OutboundName {
+			return a.OutboundName < b.OutboundName
+		}
+		if a.TargetInstanceId != b.TargetInstanceId {
+			return a.TargetInstanceId < b.TargetInstanceId
+		}
+		return a.InboundName < b.InboundName
+	})
+
+	graph.validateEdges()
+	graph.validateRequiredOutbounds()
+	graph.detectCycles()
+	return graph
+}
+
+// Valid checks if no issues have been detected in the graph.
+func (g *Graph) Valid() bool {
+	return len(g.Issues) == 0
+}
+
+// node returns the node of an instance, or nil if the instance is not part of the graph.
+func (g *Graph) node(appInstanceID string) *Node {
+	for index := range g.Nodes {
+		if g.Nodes[index].AppInstanceId == appInstanceID {
+			return &g.Nodes[index]
+		}
+	}
+	return nil
+}
+
+// hasInterface checks if a list of interfaces contains one with the given name.
+func hasInterface(interfaces []Interface, name string) bool {
+	for _, iface := range interfaces {
+		if iface.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateEdges reports the connections to instances or interfaces that no longer exist.
+func (g *Graph) validateEdges() {
+	for _, edge := range g.Edges {
+		source := g.node(edge.SourceInstanceId)
+		if source == nil {
+			g.Issues = append(g.Issues, Issue{Type: UnknownInstance, AppInstanceId: edge.SourceInstanceId, ConnectionId: edge.ConnectionId})
+		} else if !hasInterface(source.Outbounds, edge.OutboundName) {
+			g.Issues = append(g.Issues, Issue{Type: DanglingOutbound, AppInstanceId: edge.SourceInstanceId,
+				InterfaceName: edge.OutboundName, ConnectionId: edge.ConnectionId})
+		}
+		target := g.node(edge.TargetInstanceId)
+		if target == nil {
+			g.Issues = append(g.Issues, Issue{Type: UnknownInstance, AppInstanceId: edge.TargetInstanceId, ConnectionId: edge.ConnectionId})
+		} else if !hasInterface(target.Inbounds, edge.InboundName) {
+			g.Issues = append(g.Issues, Issue{Type: DanglingInbound, AppInstanceId: edge.TargetInstanceId,
+				InterfaceName: edge.InboundName, ConnectionId: edge.ConnectionId})
+		}
+	}
+}
+
+// validateRequiredOutbounds reports the required outbound interfaces without a connection. Terminated connections are
+// not considered.
+func (g *Graph) validateRequiredOutbounds() {
+	connected := make(map[string]map[string]bool, 0)
+	for _, edge := range g.Edges {
+		if edge.Status == entities.ConnectionStatusTerminated {
+			continue
+		}
+		if connected[edge.SourceInstanceId] == nil {
+			connected[edge.SourceInstanceId] = make(map[string]bool, 0)
+		}
+		connected[edge.SourceInstanceId][edge.OutboundName] = true
+	}
+	for _, node := range g.Nodes {
+		for _, outbound := range node.Outbounds {
+			if outbound.Required && !connected[node.AppInstanceId][outbound.Name] {
+				g.Issues = append(g.Issues, Issue{Type: MissingRequiredOutbound, AppInstanceId: node.AppInstanceId,
+					InterfaceName: outbound.Name})
+			}
+		}
+	}
+}
+
+// detectCycles reports the strongly connected components of the graph with more than one instance, and the instances
+// connected to themselves, using Tarjan's algorithm.
+func (g *Graph) detectCycles() {
+	adjacency := make(map[string][]string, 0)
+	selfLoops := make(map[string]bool, 0)
+	for _, edge := range g.Edges {
+		if g.node(edge.SourceInstanceId) == nil || g.node(edge.TargetInstanceId) == nil {
+			continue
+		}
+		if edge.SourceInstanceId == edge.TargetInstanceId {
+			selfLoops[edge.SourceInstanceId] = true
+		}
+		adjacency[edge.SourceInstanceId] = append(adjacency[edge.SourceInstanceId], edge.TargetInstanceId)
+	}
+
+	index := 0
+	indexes := make(map[string]int, 0)
+	lowLinks := make(map[string]int, 0)
+	onStack := make(map[string]bool, 0)
+	stack := make([]string, 0)
+
+	var connect func(id string)
+	connect = func(id string) {
+		indexes[id] = index
+		lowLinks[id] = index
+		index++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, next := range adjacency[id] {
+			if _, visited := indexes[next]; !visited {
+				connect(next)
+				if lowLinks[next] < lowLinks[id] {
+					lowLinks[id] = lowLinks[next]
+				}
+			} else if onStack[next] && indexes[next] < lowLinks[id] {
+				lowLinks[id] = indexes[next]
+			}
+		}
+
+		if lowLinks[id] == indexes[id] {
+			component := make([]string, 0)
+			for {
+				last := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[last] = false
+				component = append(component, last)
+				if last == id {
+					break
+				}
+			}
+			if len(component) > 1 || selfLoops[id] {
+				sort.Strings(component)
+				g.Issues = append(g.Issues, Issue{Type: Cycle, Instances: component})
+			}
+		}
+	}
+
+	for _, node := range g.Nodes {
+		if _, visited := indexes[node.AppInstanceId]; !visited {
+			connect(node.AppInstanceId)
+		}
+	}
+}
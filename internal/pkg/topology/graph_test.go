@@ -0,0 +1,156 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package topology
+
+import (
+	"encoding/json"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"strings"
+)
+
+var _ = ginkgo.Describe("Connection graph", func() {
+
+	const organizationID = "org"
+
+	var instances []entities.AppInstance
+
+	newConnection := func(id string, source string, outbound string, target string, inbound string) entities.ConnectionInstance {
+		return entities.ConnectionInstance{
+			OrganizationId:   organizationID,
+			ConnectionId:     id,
+			SourceInstanceId: source,
+			OutboundName:     outbound,
+			TargetInstanceId: target,
+			InboundName:      inbound,
+			Status:           entities.ConnectionStatusEstablished,
+		}
+	}
+	issuesOfType := func(graph *Graph, issueType IssueType) []Issue {
+		result := make([]Issue, 0)
+		for _, issue := range graph.Issues {
+			if issue.Type == issueType {
+				result = append(result, issue)
+			}
+		}
+		return result
+	}
+
+	ginkgo.BeforeEach(func() {
+		instances = []entities.AppInstance{
+			{
+				AppInstanceId:         "frontend",
+				Name:                  "frontend",
+				OutboundNetInterfaces: []entities.OutboundNetworkInterface{{Name: "api", Required: true}},
+			},
+			{
+				AppInstanceId:         "backend",
+				Name:                  "backend",
+				InboundNetInterfaces:  []entities.InboundNetworkInterface{{Name: "api"}},
+				OutboundNetInterfaces: []entities.OutboundNetworkInterface{{Name: "db", Required: true}},
+			},
+			{
+				AppInstanceId:        "database",
+				Name:                 "database",
+				InboundNetInterfaces: []entities.InboundNetworkInterface{{Name: "sql"}},
+			},
+		}
+	})
+
+	ginkgo.It("should accept a connected graph", func() {
+		graph := NewGraph(organizationID, instances, []entities.ConnectionInstance{
+			newConnection("c1", "frontend", "api", "backend", "api"),
+			newConnection("c2", "backend", "db", "database", "sql"),
+		})
+		gomega.Expect(graph.Valid()).Should(gomega.BeTrue())
+		gomega.Expect(len(graph.Nodes)).Should(gomega.Equal(3))
+		gomega.Expect(graph.Nodes[0].Name).Should(gomega.Equal("backend"))
+		gomega.Expect(len(graph.Edges)).Should(gomega.Equal(2))
+	})
+
+	ginkgo.It("should report required outbounds without connections", func() {
+		terminated := newConnection("c2", "backend", "db", "database", "sql")
+		terminated.Status = entities.ConnectionStatusTerminated
+		graph := NewGraph(organizationID, instances, []entities.ConnectionInstance{
+			newConnection("c1", "frontend", "api", "backend", "api"),
+			terminated,
+		})
+		gomega.Expect(graph.Issues).Should(gomega.Equal([]Issue{
+			{Type: MissingRequiredOutbound, AppInstanceId: "backend", InterfaceName: "db"},
+		}))
+	})
+
+	ginkgo.It("should report connections to interfaces and instances that no longer exist", func() {
+		graph := NewGraph(organizationID, instances, []entities.ConnectionInstance{
+			newConnection("c1", "frontend", "api", "backend", "api"),
+			newConnection("c2", "backend", "db", "database", "postgres"),
+			newConnection("c3", "frontend", "cache", "redis", "cache"),
+		})
+		gomega.Expect(issuesOfType(graph, DanglingInbound)).Should(gomega.Equal([]Issue{
+			{Type: DanglingInbound, AppInstanceId: "database", InterfaceName: "postgres", ConnectionId: "c2"},
+		}))
+		gomega.Expect(issuesOfType(graph, DanglingOutbound)).Should(gomega.Equal([]Issue{
+			{Type: DanglingOutbound, AppInstanceId: "frontend", InterfaceName: "cache", ConnectionId: "c3"},
+		}))
+		gomega.Expect(issuesOfType(graph, UnknownInstance)).Should(gomega.Equal([]Issue{
+			{Type: UnknownInstance, AppInstanceId: "redis", ConnectionId: "c3"},
+		}))
+	})
+
+	ginkgo.It("should detect cycles", func() {
+		instances[2].OutboundNetInterfaces = []entities.OutboundNetworkInterface{{Name: "events"}}
+		instances[0].InboundNetInterfaces = []entities.InboundNetworkInterface{{Name: "events"}}
+		graph := NewGraph(organizationID, instances, []entities.ConnectionInstance{
+			newConnection("c1", "frontend", "api", "backend", "api"),
+			newConnection("c2", "backend", "db", "database", "sql"),
+			newConnection("c3", "database", "events", "frontend", "events"),
+		})
+		gomega.Expect(graph.Issues).Should(gomega.Equal([]Issue{
+			{Type: Cycle, Instances: []string{"backend", "database", "frontend"}},
+		}))
+	})
+
+	ginkgo.Context("rendering", func() {
+		var graph *Graph
+		ginkgo.BeforeEach(func() {
+			graph = NewGraph(organizationID, instances, []entities.ConnectionInstance{
+				newConnection("c1", "frontend", "api", "backend", "api"),
+			})
+		})
+		ginkgo.It("should render the graph as JSON", func() {
+			content, err := graph.Render(JSONFormat)
+			gomega.Expect(err).To(gomega.Succeed())
+			decoded := &Graph{}
+			gomega.Expect(json.Unmarshal(content, decoded)).To(gomega.Succeed())
+			gomega.Expect(decoded).Should(gomega.Equal(graph))
+		})
+		ginkgo.It("should render the graph as DOT", func() {
+			content, err := graph.Render(DOTFormat)
+			gomega.Expect(err).To(gomega.Succeed())
+			dot := string(content)
+			gomega.Expect(dot).Should(gomega.HavePrefix("digraph \"org\" {\n"))
+			gomega.Expect(dot).Should(gomega.ContainSubstring("\t\"frontend\" -> \"backend\" [label=\"api -> api\"];\n"))
+			gomega.Expect(dot).Should(gomega.ContainSubstring("\t\"backend\" [label=\"backend\", color=red];\n"))
+			gomega.Expect(strings.HasSuffix(dot, "}\n")).Should(gomega.BeTrue())
+		})
+		ginkgo.It("should reject unknown formats", func() {
+			_, err := ParseFormat("svg")
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		})
+	})
+})
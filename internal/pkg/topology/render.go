@@ -0,0 +1,114 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package topology
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"strconv"
+	"strings"
+)
+
+// Format of an exported graph.
+type Format string
+
+const (
+	// JSONFormat renders the graph with its issues as JSON.
+	JSONFormat Format = "json"
+	// DOTFormat renders the graph in the Graphviz DOT language.
+	DOTFormat Format = "dot"
+)
+
+// ParseFormat validates the name of a format.
+func ParseFormat(format string) (Format, derrors.Error) {
+	switch strings.ToLower(format) {
+	case "json", "":
+		return JSONFormat, nil
+	case "dot", "graphviz":
+		return DOTFormat, nil
+	}
+	return "", derrors.NewInvalidArgumentError("unsupported graph format").WithParams(format)
+}
+
+// JSON renders the graph as JSON.
+func (g *Graph) JSON() ([]byte, derrors.Error) {
+	content, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return nil, derrors.NewInternalError("cannot serialize graph", err)
+	}
+	return content, nil
+}
+
+// DOT renders the graph in the Graphviz DOT language. Instances and connections with issues are drawn in red, and
+// connections that are not established are dashed.
+func (g *Graph) DOT() []byte {
+	withIssues := make(map[string]bool, 0)
+	for _, issue := range g.Issues {
+		for _, id := range append([]string{issue.AppInstanceId, issue.ConnectionId}, issue.Instances...) {
+			if id != "" {
+				withIssues[id] = true
+			}
+		}
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "digraph %s {\n", strconv.Quote(g.OrganizationId))
+	buffer.WriteString("\trankdir=LR;\n")
+	for _, node := range g.Nodes {
+		attributes := fmt.Sprintf("label=%s", strconv.Quote(node.Name))
+		if withIssues[node.AppInstanceId] {
+			attributes += ", color=red"
+		}
+		fmt.Fprintf(&buffer, "\t%s [%s];\n", strconv.Quote(node.AppInstanceId), attributes)
+	}
+	unknown := make(map[string]bool, 0)
+	for _, edge := range g.Edges {
+		for _, id := range []string{edge.SourceInstanceId, edge.TargetInstanceId} {
+			if g.node(id) == nil && !unknown[id] {
+				unknown[id] = true
+				fmt.Fprintf(&buffer, "\t%s [label=\"unknown\", style=dashed, color=red];\n", strconv.Quote(id))
+			}
+		}
+	}
+	for _, edge := range g.Edges {
+		attributes := fmt.Sprintf("label=%s", strconv.Quote(edge.OutboundName+" -> "+edge.InboundName))
+		if edge.Status != entities.ConnectionStatusEstablished {
+			attributes += ", style=dashed"
+		}
+		if withIssues[edge.ConnectionId] {
+			attributes += ", color=red"
+		}
+		fmt.Fprintf(&buffer, "\t%s -> %s [%s];\n", strconv.Quote(edge.SourceInstanceId),
+			strconv.Quote(edge.TargetInstanceId), attributes)
+	}
+	buffer.WriteString("}\n")
+	return buffer.Bytes()
+}
+
+// Render the graph in the selected format.
+func (g *Graph) Render(format Format) ([]byte, derrors.Error) {
+	switch format {
+	case JSONFormat:
+		return g.JSON()
+	case DOTFormat:
+		return g.DOT(), nil
+	}
+	return nil, derrors.NewInvalidArgumentError("unsupported graph format").WithParams(format)
+}
@@ -0,0 +1,39 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/system-model/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxConflictRetries with the number of times a read-modify-write operation is retried on a version conflict.
+const MaxConflictRetries = 5
+
+// RetryOnConflict executes a read-modify-write operation, executing it again while it fails with a version conflict.
+func RetryOnConflict(operation func() derrors.Error) derrors.Error {
+	var err derrors.Error
+	for attempt := 0; attempt < MaxConflictRetries; attempt++ {
+		err = operation()
+		if !entities.IsVersionConflict(err) {
+			return err
+		}
+		log.Debug().Int("attempt", attempt).Str("err", err.Error()).Msg("version conflict, retrying")
+	}
+	return err
+}
@@ -2,4 +2,7 @@
 echo "Creating database..."
 echo "docker exec -i scylla cqlsh < ./database.cql"
 docker exec -i scylla cqlsh < ./database.cql
+echo "Adding the missing columns..."
+echo "docker exec -i scylla cqlsh < ./migrations.cql"
+docker exec -i scylla cqlsh < ./migrations.cql 2> /dev/null
 echo "Done!"